@@ -0,0 +1,333 @@
+// Package campaign runs bulk audits, e.g. re-auditing every plugin after a sniff bugfix. A
+// campaign enqueues thousands of targets with controlled pacing, tracks their completion in
+// the results store, retries stragglers and produces a summary report.
+//
+// The package has no adapter for a results store, since this repository has no API to read
+// results back. Callers implement Results for the store of their deployment.
+package campaign
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+// Default pacing and retry settings.
+const (
+	DefaultRate         = time.Second
+	DefaultPollInterval = 30 * time.Second
+	DefaultTimeout      = 2 * time.Hour
+	DefaultRetries      = 2
+)
+
+// Statuses of targets in the summary.
+const (
+	StatusCompleted = "completed" // The target has new results.
+	StatusFailed    = "failed"    // The target could not be enqueued, or had no results after the last retry.
+	StatusInvalid   = "invalid"   // The target does not make a valid audit request.
+	StatusCancelled = "cancelled" // The campaign was cancelled before the target completed.
+)
+
+// Using time.Now as a variable so that we can mock it in tests.
+var now = time.Now
+
+// Target is a project to audit. Targets with only a slug are downloaded from WordPress.org.
+type Target struct {
+	Slug        string
+	SourceURL   string
+	Title       string // (Optional) Defaults to the slug.
+	ProjectType string // (Optional) Defaults to the project type of the campaign's request.
+}
+
+// ParseTargets reads targets, one per line: a slug, a url, or a slug and a url separated by
+// whitespace. Blank lines and lines starting with "#" are skipped.
+func ParseTargets(r io.Reader) ([]Target, error) {
+	var targets []Target
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 2:
+			targets = append(targets, Target{Slug: fields[0], SourceURL: fields[1]})
+		case len(fields) == 1 && strings.Contains(fields[0], "://"):
+			targets = append(targets, Target{Slug: slugFromURL(fields[0]), SourceURL: fields[0]})
+		case len(fields) == 1:
+			targets = append(targets, Target{Slug: fields[0]})
+		default:
+			return nil, fmt.Errorf("line %d: expected a slug and a url, got %q", n, line)
+		}
+	}
+
+	return targets, scanner.Err()
+}
+
+// slugFromURL returns the file name of the url without its extensions, e.g. "akismet" for
+// "https://downloads.wordpress.org/plugin/akismet.4.1.zip".
+func slugFromURL(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	name := u.Path[strings.LastIndex(u.Path, "/")+1:]
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// WordPressOrgURL returns the download url of the latest version of a WordPress.org plugin or theme.
+func WordPressOrgURL(slug, projectType string) string {
+	if projectType == "theme" {
+		return "https://downloads.wordpress.org/theme/" + slug + ".zip"
+	}
+	return "https://downloads.wordpress.org/plugin/" + slug + ".zip"
+}
+
+// Queue sends audit messages, e.g. a message.Provider.
+type Queue interface {
+	SendMessage(msg *message.Message) error
+}
+
+// Results checks the results store for the results of a message. It is implemented by the
+// caller, e.g. with a query of the database of the Tide API.
+type Results interface {
+	// HasResultsSince determines if the message has results that were stored after the time,
+	// so that forced re-audits are not completed by their previous results.
+	HasResultsSince(msg *message.Message, since time.Time) (bool, error)
+}
+
+// Campaign audits a list of targets.
+type Campaign struct {
+	Name         string
+	Queue        Queue
+	Results      Results
+	Request      message.AuditRequest // Template of the audit requests. Title, Slug and SourceURL are set for each target.
+	Rate         time.Duration        // (Optional) Wait between two enqueued messages. Defaults to DefaultRate.
+	MaxInFlight  int                  // (Optional) Most enqueued targets without results at the same time. Unlimited if 0.
+	PollInterval time.Duration        // (Optional) Wait between two checks of the results store. Defaults to DefaultPollInterval.
+	Timeout      time.Duration        // (Optional) Time without results after which a target is retried. Defaults to DefaultTimeout.
+	Retries      int                  // (Optional) Retries of a target without results. Defaults to DefaultRetries, none if negative.
+}
+
+// TargetResult is the outcome of a target.
+type TargetResult struct {
+	Slug      string        `json:"slug"`
+	SourceURL string        `json:"source_url"`
+	Status    string        `json:"status"`
+	Attempts  int           `json:"attempts"`
+	Duration  time.Duration `json:"duration,omitempty"` // From the last enqueue to the results.
+	Error     string        `json:"error,omitempty"`
+}
+
+// Summary is the final report of a campaign.
+type Summary struct {
+	Name      string         `json:"name"`
+	Started   time.Time      `json:"started"`
+	Finished  time.Time      `json:"finished"`
+	Targets   int            `json:"targets"`
+	Completed int            `json:"completed"`
+	Failed    int            `json:"failed"`
+	Invalid   int            `json:"invalid"`
+	Cancelled int            `json:"cancelled"`
+	Retried   int            `json:"retried"` // Retries of all targets.
+	Results   []TargetResult `json:"results"` // In the order of the targets.
+}
+
+// Write writes the summary as JSON.
+func (s Summary) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// target is the state of a target while the campaign runs.
+type target struct {
+	result   *TargetResult
+	msg      *message.Message
+	enqueued time.Time
+}
+
+// Run audits the targets and returns the summary once every target has completed or failed.
+// If the context is cancelled, Run returns the summary so far, with the remaining targets
+// cancelled, and the context's error.
+func (c Campaign) Run(ctx context.Context, targets []Target) (*Summary, error) {
+	if c.Queue == nil {
+		return nil, errors.New("no queue to send the campaign to")
+	}
+	if c.Results == nil {
+		return nil, errors.New("no results store to track the campaign")
+	}
+
+	rate := durationOr(c.Rate, DefaultRate)
+	poll := durationOr(c.PollInterval, DefaultPollInterval)
+	timeout := durationOr(c.Timeout, DefaultTimeout)
+	retries := c.Retries
+	if retries == 0 {
+		retries = DefaultRetries
+	}
+
+	summary := &Summary{
+		Name:    c.Name,
+		Started: now(),
+		Targets: len(targets),
+		Results: make([]TargetResult, len(targets)),
+	}
+
+	var pending, inFlight []*target
+	for i, t := range targets {
+		result := &summary.Results[i]
+		result.Slug = t.Slug
+		result.SourceURL = t.SourceURL
+
+		msg, err := c.message(t)
+		if err != nil {
+			result.Status = StatusInvalid
+			result.Error = err.Error()
+			continue
+		}
+		result.SourceURL = msg.SourceURL
+		pending = append(pending, &target{result: result, msg: msg})
+	}
+
+	var nextSend, nextPoll time.Time
+	for len(pending) > 0 || len(inFlight) > 0 {
+		current := now()
+
+		// Check the enqueued targets and retry the stragglers.
+		if !current.Before(nextPoll) && len(inFlight) > 0 {
+			var waiting []*target
+			for _, t := range inFlight {
+				done, err := c.Results.HasResultsSince(t.msg, t.enqueued)
+				switch {
+				case err == nil && done:
+					t.result.Status = StatusCompleted
+					t.result.Duration = current.Sub(t.enqueued)
+					t.result.Error = ""
+				case current.Sub(t.enqueued) < timeout:
+					waiting = append(waiting, t)
+				case t.result.Attempts <= retries:
+					t.result.Error = "no results after " + timeout.String()
+					pending = append(pending, t)
+				default:
+					t.result.Status = StatusFailed
+					t.result.Error = fmt.Sprintf("no results after %d attempts", t.result.Attempts)
+				}
+			}
+			inFlight = waiting
+			nextPoll = current.Add(poll)
+		}
+
+		// Enqueue the next target.
+		canSend := len(pending) > 0 && (c.MaxInFlight <= 0 || len(inFlight) < c.MaxInFlight)
+		if canSend && !current.Before(nextSend) {
+			t := pending[0]
+			pending = pending[1:]
+
+			if t.result.Attempts > 0 {
+				summary.Retried++
+			}
+			t.result.Attempts++
+			t.enqueued = current
+
+			if err := c.Queue.SendMessage(t.msg); err != nil {
+				t.result.Error = err.Error()
+				if t.result.Attempts <= retries {
+					pending = append(pending, t)
+				} else {
+					t.result.Status = StatusFailed
+				}
+			} else {
+				inFlight = append(inFlight, t)
+			}
+			nextSend = current.Add(rate)
+			continue
+		}
+
+		// Wait for the next send or poll.
+		var wake time.Time
+		if len(inFlight) > 0 {
+			wake = nextPoll
+		}
+		if canSend && (wake.IsZero() || nextSend.Before(wake)) {
+			wake = nextSend
+		}
+		select {
+		case <-ctx.Done():
+			for _, t := range append(pending, inFlight...) {
+				t.result.Status = StatusCancelled
+			}
+			c.finish(summary)
+			return summary, ctx.Err()
+		case <-time.After(wake.Sub(now())):
+		}
+	}
+
+	c.finish(summary)
+	return summary, nil
+}
+
+// message returns the audit message of a target.
+func (c Campaign) message(t Target) (*message.Message, error) {
+	req := c.Request
+	req.Slug = t.Slug
+	req.SourceURL = t.SourceURL
+	req.Title = t.Title
+	if req.Title == "" {
+		req.Title = t.Slug
+	}
+	if t.ProjectType != "" {
+		req.ProjectType = t.ProjectType
+	}
+	if req.SourceURL == "" && t.Slug != "" {
+		req.SourceURL = WordPressOrgURL(t.Slug, req.ProjectType)
+	}
+
+	return message.NewAuditRequest(req)
+}
+
+// finish counts the statuses of the summary.
+func (c Campaign) finish(summary *Summary) {
+	summary.Finished = now()
+
+	for _, result := range summary.Results {
+		switch result.Status {
+		case StatusCompleted:
+			summary.Completed++
+		case StatusFailed:
+			summary.Failed++
+		case StatusInvalid:
+			summary.Invalid++
+		case StatusCancelled:
+			summary.Cancelled++
+		}
+	}
+
+	log.Log("Campaign "+c.Name, fmt.Sprintf("%d of %d targets completed, %d failed, %d invalid, %d cancelled",
+		summary.Completed, summary.Targets, summary.Failed, summary.Invalid, summary.Cancelled))
+}
+
+// durationOr returns d, or the default if d is not positive.
+func durationOr(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+	return d
+}
@@ -0,0 +1,235 @@
+package campaign
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// mockStore is a queue that completes the audits of the messages it receives, except for
+// the slugs in stuck, which only complete after the given number of sends.
+type mockStore struct {
+	mu        sync.Mutex
+	sent      []string
+	sendErr   map[string]int // Failed sends by slug.
+	stuck     map[string]int // Sends without results by slug.
+	completed map[string]time.Time
+}
+
+func (m *mockStore) SendMessage(msg *message.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, msg.Slug)
+	if m.sendErr[msg.Slug] > 0 {
+		m.sendErr[msg.Slug]--
+		return errors.New("queue unavailable")
+	}
+	if m.stuck[msg.Slug] > 0 {
+		m.stuck[msg.Slug]--
+		return nil
+	}
+	if m.completed == nil {
+		m.completed = make(map[string]time.Time)
+	}
+	m.completed[msg.Slug] = now().Add(time.Millisecond)
+	return nil
+}
+
+func (m *mockStore) HasResultsSince(msg *message.Message, since time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	at, ok := m.completed[msg.Slug]
+	return ok && at.After(since), nil
+}
+
+func TestParseTargets(t *testing.T) {
+	input := `# Top plugins
+akismet
+
+hello-dolly https://example.com/hello.zip
+https://downloads.wordpress.org/plugin/jetpack.8.0.zip
+`
+	got, err := ParseTargets(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseTargets() error = %v", err)
+	}
+
+	want := []Target{
+		{Slug: "akismet"},
+		{Slug: "hello-dolly", SourceURL: "https://example.com/hello.zip"},
+		{Slug: "jetpack", SourceURL: "https://downloads.wordpress.org/plugin/jetpack.8.0.zip"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseTargets() = %v, want %v", got, want)
+	}
+
+	if _, err := ParseTargets(strings.NewReader("a b c")); err == nil {
+		t.Error("ParseTargets() expected an error for a line with three fields")
+	}
+}
+
+func TestWordPressOrgURL(t *testing.T) {
+	if got := WordPressOrgURL("akismet", "plugin"); got != "https://downloads.wordpress.org/plugin/akismet.zip" {
+		t.Errorf("WordPressOrgURL() = %v", got)
+	}
+	if got := WordPressOrgURL("twentytwenty", "theme"); got != "https://downloads.wordpress.org/theme/twentytwenty.zip" {
+		t.Errorf("WordPressOrgURL() = %v", got)
+	}
+}
+
+func TestCampaign_Run(t *testing.T) {
+	request := message.AuditRequest{
+		ResponseAPIEndpoint: "https://example.com/api/audits",
+		ProjectType:         "plugin",
+		Preset:              message.PresetCompatOnly,
+		Force:               true,
+	}
+
+	tests := []struct {
+		name        string
+		targets     []Target
+		store       *mockStore
+		retries     int
+		maxInFlight int
+		want        map[string]string // Status by slug.
+		wantRetried int
+	}{
+		{
+			"All Completed",
+			[]Target{{Slug: "akismet"}, {Slug: "hello-dolly"}, {Slug: "jetpack"}},
+			&mockStore{},
+			0,
+			2,
+			map[string]string{"akismet": StatusCompleted, "hello-dolly": StatusCompleted, "jetpack": StatusCompleted},
+			0,
+		},
+		{
+			"Straggler Retried",
+			[]Target{{Slug: "akismet"}, {Slug: "slow"}},
+			&mockStore{stuck: map[string]int{"slow": 1}},
+			1,
+			0,
+			map[string]string{"akismet": StatusCompleted, "slow": StatusCompleted},
+			1,
+		},
+		{
+			"Retries Exhausted",
+			[]Target{{Slug: "akismet"}, {Slug: "broken"}},
+			&mockStore{stuck: map[string]int{"broken": 5}},
+			1,
+			0,
+			map[string]string{"akismet": StatusCompleted, "broken": StatusFailed},
+			1,
+		},
+		{
+			"Send Error",
+			[]Target{{Slug: "akismet"}},
+			&mockStore{sendErr: map[string]int{"akismet": 1}},
+			1,
+			0,
+			map[string]string{"akismet": StatusCompleted},
+			1,
+		},
+		{
+			"Invalid Target",
+			[]Target{{Slug: "akismet"}, {Slug: "bad", SourceURL: "not a url"}},
+			&mockStore{},
+			0,
+			0,
+			map[string]string{"akismet": StatusCompleted, "bad": StatusInvalid},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Campaign{
+				Name:         tt.name,
+				Queue:        tt.store,
+				Results:      tt.store,
+				Request:      request,
+				Rate:         time.Millisecond,
+				PollInterval: 2 * time.Millisecond,
+				Timeout:      20 * time.Millisecond,
+				Retries:      tt.retries,
+				MaxInFlight:  tt.maxInFlight,
+			}
+
+			summary, err := c.Run(context.Background(), tt.targets)
+			if err != nil {
+				t.Fatalf("Campaign.Run() error = %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, result := range summary.Results {
+				got[result.Slug] = result.Status
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Campaign.Run() statuses = %v, want %v", got, tt.want)
+			}
+			if summary.Retried != tt.wantRetried {
+				t.Errorf("Campaign.Run() retried = %v, want %v", summary.Retried, tt.wantRetried)
+			}
+			if summary.Targets != len(tt.targets) || summary.Completed+summary.Failed+summary.Invalid != len(tt.targets) {
+				t.Errorf("Campaign.Run() summary = %+v", summary)
+			}
+		})
+	}
+}
+
+func TestCampaign_Run_Cancelled(t *testing.T) {
+	store := &mockStore{stuck: map[string]int{"slow": 1}}
+	c := Campaign{
+		Queue:   store,
+		Results: store,
+		Request: message.AuditRequest{
+			ResponseAPIEndpoint: "https://example.com/api/audits",
+		},
+		Rate:         time.Millisecond,
+		PollInterval: time.Millisecond,
+		Timeout:      time.Hour,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	summary, err := c.Run(ctx, []Target{{Slug: "akismet"}, {Slug: "slow"}})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Campaign.Run() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if summary.Completed != 1 || summary.Cancelled != 1 || summary.Results[1].Status != StatusCancelled {
+		t.Errorf("Campaign.Run() summary = %+v", summary)
+	}
+}
+
+func TestCampaign_Run_Errors(t *testing.T) {
+	if _, err := (Campaign{Results: &mockStore{}}).Run(context.Background(), nil); err == nil {
+		t.Error("Campaign.Run() expected an error without a queue")
+	}
+	if _, err := (Campaign{Queue: &mockStore{}}).Run(context.Background(), nil); err == nil {
+		t.Error("Campaign.Run() expected an error without a results store")
+	}
+}
+
+func TestSummary_Write(t *testing.T) {
+	summary := Summary{Name: "test", Targets: 1, Completed: 1, Results: []TargetResult{{Slug: "akismet", Status: StatusCompleted, Attempts: 1}}}
+
+	var buf bytes.Buffer
+	if err := summary.Write(&buf); err != nil {
+		t.Fatalf("Summary.Write() error = %v", err)
+	}
+
+	var got Summary
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || !reflect.DeepEqual(got.Results, summary.Results) {
+		t.Errorf("Summary.Write() = %s, error = %v", buf.String(), err)
+	}
+}
@@ -0,0 +1,45 @@
+// Package bigquery provides an export.Sink that streams rows into a BigQuery table.
+package bigquery
+
+import (
+	"context"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wptide/pkg/export"
+)
+
+// Putter streams rows into a table, e.g. a *bigquery.Uploader.
+type Putter interface {
+	Put(ctx context.Context, src interface{}) error
+}
+
+// Sink implements export.Sink for BigQuery. Each row is inserted with its export.Row ID as
+// the insert ID, so that BigQuery drops rows that were inserted twice by a retry.
+type Sink struct {
+	Uploader Putter
+}
+
+// NewSink returns a Sink for a table, e.g. created from export.Row with `bigquery.InferSchema`.
+func NewSink(client *bigquery.Client, dataset, table string) *Sink {
+	return &Sink{
+		Uploader: client.Dataset(dataset).Table(table).Uploader(),
+	}
+}
+
+// Insert implements export.Sink.
+func (s Sink) Insert(ctx context.Context, rows []export.Row) error {
+	schema, err := bigquery.InferSchema(export.Row{})
+	if err != nil {
+		return err
+	}
+
+	savers := make([]*bigquery.StructSaver, len(rows))
+	for i, row := range rows {
+		savers[i] = &bigquery.StructSaver{
+			Schema:   schema,
+			Struct:   row,
+			InsertID: row.ID(),
+		}
+	}
+	return s.Uploader.Put(ctx, savers)
+}
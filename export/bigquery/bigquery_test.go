@@ -0,0 +1,40 @@
+package bigquery
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wptide/pkg/export"
+)
+
+type mockUploader struct {
+	src interface{}
+}
+
+func (m *mockUploader) Put(ctx context.Context, src interface{}) error {
+	m.src = src
+	return nil
+}
+
+func TestSink_Insert(t *testing.T) {
+	uploader := &mockUploader{}
+	rows := []export.Row{
+		{Checksum: "checksum", Audit: "phpcs_wordpress", Errors: 3, ExportedAt: time.Unix(1, 0)},
+	}
+
+	if err := (Sink{Uploader: uploader}).Insert(context.Background(), rows); err != nil {
+		t.Fatal(err)
+	}
+
+	savers, ok := uploader.src.([]*bigquery.StructSaver)
+	if !ok || len(savers) != 1 {
+		t.Fatalf("Sink.Insert() put %v", uploader.src)
+	}
+
+	values, insertID, err := savers[0].Save()
+	if err != nil || insertID != rows[0].ID() || values["errors"] != int64(3) {
+		t.Errorf("Sink.Insert() saved %v, %v, %v", values, insertID, err)
+	}
+}
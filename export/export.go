@@ -0,0 +1,279 @@
+// Package export streams completed result documents to a data warehouse, e.g. BigQuery,
+// so that results can be analyzed across the ecosystem with SQL.
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/tide"
+)
+
+// Default batching and retry settings.
+const (
+	DefaultBatchSize = 500
+	DefaultInterval  = time.Minute
+	DefaultRetries   = 3
+	DefaultBackoff   = time.Second
+)
+
+// Using time.Now as a variable so that we can mock it in tests.
+var now = time.Now
+
+// Row is the flattened schema of a result document: one row for each report of an item.
+// Items without reports, e.g. because all audits failed, have a single row without an audit.
+type Row struct {
+	Checksum             string    `json:"checksum" bigquery:"checksum"`
+	Title                string    `json:"title" bigquery:"title"`
+	Slug                 string    `json:"slug" bigquery:"slug"`
+	Version              string    `json:"version" bigquery:"version"`
+	ProjectType          string    `json:"project_type" bigquery:"project_type"`
+	SourceURL            string    `json:"source_url" bigquery:"source_url"`
+	SourceType           string    `json:"source_type" bigquery:"source_type"`
+	RequestClient        string    `json:"request_client" bigquery:"request_client"`
+	Audit                string    `json:"audit" bigquery:"audit"`   // Report key, e.g. "phpcs_wordpress".
+	Status               string    `json:"status" bigquery:"status"` // e.g. tide.AuditStatusSkipped.
+	Error                string    `json:"error" bigquery:"error"`
+	Files                int       `json:"files" bigquery:"files"`
+	Errors               int       `json:"errors" bigquery:"errors"`
+	Warnings             int       `json:"warnings" bigquery:"warnings"`
+	CompatibleVersions   []string  `json:"compatible_versions" bigquery:"compatible_versions"`
+	IncompatibleVersions []string  `json:"incompatible_versions" bigquery:"incompatible_versions"`
+	Scores               []Score   `json:"scores" bigquery:"scores"` // Lighthouse category scores.
+	Verdict              string    `json:"verdict" bigquery:"verdict"`
+	Environment          string    `json:"environment" bigquery:"environment"` // Fingerprint of the runtime environment.
+	ExportedAt           time.Time `json:"exported_at" bigquery:"exported_at"`
+}
+
+// Score is the score of a Lighthouse category.
+type Score struct {
+	Category string  `json:"category" bigquery:"category"`
+	Score    float64 `json:"score" bigquery:"score"`
+}
+
+// ID identifies the row, e.g. so that warehouses can drop rows that were inserted twice by a retry.
+func (r Row) ID() string {
+	return fmt.Sprintf("%s:%s:%d", r.Checksum, r.Audit, r.ExportedAt.UnixNano())
+}
+
+// Flatten returns the rows of an item, ordered by audit.
+func Flatten(item tide.Item, exportedAt time.Time) []Row {
+	base := Row{
+		Checksum:      item.Checksum,
+		Title:         item.Title,
+		Version:       item.Version,
+		ProjectType:   item.ProjectType,
+		SourceURL:     item.SourceURL,
+		SourceType:    item.SourceType,
+		RequestClient: item.RequestClient,
+		ExportedAt:    exportedAt,
+	}
+	if len(item.Project) != 0 {
+		base.Slug = item.Project[0]
+	}
+	if item.Verdict != nil {
+		base.Verdict = item.Verdict.Result
+	}
+	if item.Environment != nil {
+		base.Environment = item.Environment.Fingerprint
+	}
+
+	if len(item.Reports) == 0 {
+		var messages []string
+		for _, failure := range item.Failures {
+			messages = append(messages, failure.Message)
+		}
+		base.Error = strings.Join(messages, "; ")
+		return []Row{base}
+	}
+
+	audits := make([]string, 0, len(item.Reports))
+	for audit := range item.Reports {
+		audits = append(audits, audit)
+	}
+	sort.Strings(audits)
+
+	rows := make([]Row, 0, len(audits))
+	for _, audit := range audits {
+		report := item.Reports[audit]
+
+		row := base
+		row.Audit = audit
+		row.Status = report.Status
+		row.Error = report.Error
+		row.CompatibleVersions = report.CompatibleVersions
+		row.IncompatibleVersions = report.IncompatibleVersions
+
+		if summary := report.Summary.PhpcsSummary; summary != nil {
+			row.Files = summary.FilesCount
+			row.Errors = summary.ErrorsCount
+			row.Warnings = summary.WarningsCount
+		}
+
+		if summary := report.Summary.LighthouseSummary; summary != nil {
+			for category, result := range summary.Categories {
+				row.Scores = append(row.Scores, Score{Category: category, Score: float64(result.Score)})
+			}
+			sort.Slice(row.Scores, func(i, j int) bool { return row.Scores[i].Category < row.Scores[j].Category })
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// Sink inserts rows into a warehouse table.
+type Sink interface {
+	Insert(ctx context.Context, rows []Row) error
+}
+
+// Exporter collects the rows of result documents and inserts them into the sink in batches.
+// Failed inserts are retried with an exponential backoff.
+type Exporter struct {
+	Sink      Sink
+	BatchSize int                         // (Optional) Rows per insert. Defaults to DefaultBatchSize.
+	Interval  time.Duration               // (Optional) Longest time rows wait to be inserted by Run. Defaults to DefaultInterval.
+	Retries   int                         // (Optional) Retries of a failed insert. Defaults to DefaultRetries.
+	Backoff   time.Duration               // (Optional) Wait before the first retry, doubled for every retry. Defaults to DefaultBackoff.
+	OnError   func(rows []Row, err error) // (Optional) Receives the rows that could not be inserted. They are logged otherwise.
+
+	mu   sync.Mutex
+	rows []Row
+	full chan struct{}
+}
+
+// Export adds the rows of a result document to the next batch. It does not block: batches
+// are inserted by Run, or by calling Flush.
+func (e *Exporter) Export(item tide.Item) {
+	rows := Flatten(item, now())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rows = append(e.rows, rows...)
+	if len(e.rows) >= e.batchSize() {
+		select {
+		case e.fullChan() <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pending returns the number of rows waiting to be inserted.
+func (e *Exporter) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.rows)
+}
+
+// Run inserts a batch every Interval, or as soon as a batch is full, until the context is
+// cancelled. The remaining rows are inserted before Run returns.
+func (e *Exporter) Run(ctx context.Context) {
+	interval := e.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	e.mu.Lock()
+	full := e.fullChan()
+	e.mu.Unlock()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Don't lose the rows of a shutting down worker, but don't wait for retries either.
+			e.Flush(context.Background())
+			return
+		case <-ticker.C:
+			e.Flush(ctx)
+		case <-full:
+			e.Flush(ctx)
+		}
+	}
+}
+
+// Flush inserts all pending rows in batches. It returns the last error, after the rows of
+// the failed batches have been passed to OnError.
+func (e *Exporter) Flush(ctx context.Context) error {
+	if e.Sink == nil {
+		return errors.New("no sink to export to")
+	}
+
+	e.mu.Lock()
+	rows := e.rows
+	e.rows = nil
+	e.mu.Unlock()
+
+	var lastErr error
+	for len(rows) > 0 {
+		n := e.batchSize()
+		if n > len(rows) {
+			n = len(rows)
+		}
+		batch := rows[:n]
+		rows = rows[n:]
+
+		if err := e.insert(ctx, batch); err != nil {
+			lastErr = err
+			if e.OnError != nil {
+				e.OnError(batch, err)
+			} else {
+				log.Log("Export", fmt.Sprintf("Could not export %d rows: %s", len(batch), err))
+			}
+		}
+	}
+
+	return lastErr
+}
+
+// insert inserts a batch, retrying failed inserts.
+func (e *Exporter) insert(ctx context.Context, batch []Row) error {
+	retries := e.Retries
+	if retries <= 0 {
+		retries = DefaultRetries
+	}
+	backoff := e.Backoff
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+
+	err := e.Sink.Insert(ctx, batch)
+	for attempt := 0; err != nil && attempt < retries; attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+		err = e.Sink.Insert(ctx, batch)
+	}
+
+	return err
+}
+
+// batchSize returns the number of rows per insert.
+func (e *Exporter) batchSize() int {
+	if e.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return e.BatchSize
+}
+
+// fullChan returns the channel that signals full batches. The lock must be held.
+func (e *Exporter) fullChan() chan struct{} {
+	if e.full == nil {
+		e.full = make(chan struct{}, 1)
+	}
+	return e.full
+}
@@ -0,0 +1,225 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// mockSink records the inserted batches and fails the first `failures` inserts.
+type mockSink struct {
+	mu       sync.Mutex
+	batches  [][]Row
+	failures int
+	calls    int
+}
+
+func (m *mockSink) Insert(ctx context.Context, rows []Row) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.calls++
+	if m.calls <= m.failures {
+		return errors.New("insert error")
+	}
+	m.batches = append(m.batches, rows)
+	return nil
+}
+
+func (m *mockSink) inserted() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := 0
+	for _, batch := range m.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+func testItem() tide.Item {
+	return tide.Item{
+		Title:       "Plugin",
+		Version:     "1.0.0",
+		Checksum:    "checksum",
+		ProjectType: "plugin",
+		SourceURL:   "https://example.com/plugin.zip",
+		SourceType:  "zip",
+		Project:     []string{"plugin"},
+		Verdict:     &tide.Verdict{Result: tide.VerdictPass},
+		Environment: &tide.Environment{Fingerprint: "fingerprint"},
+		Reports: map[string]tide.AuditResult{
+			"phpcs_wordpress": {
+				Summary: tide.AuditSummary{PhpcsSummary: &tide.PhpcsSummary{FilesCount: 2, ErrorsCount: 3, WarningsCount: 4}},
+			},
+			"phpcs_phpcompatibility": {
+				CompatibleVersions:   []string{"7.4", "8.0"},
+				IncompatibleVersions: []string{"8.3"},
+			},
+			"lighthouse": {
+				Summary: tide.AuditSummary{LighthouseSummary: &tide.LighthouseSummary{
+					Categories: map[string]tide.LighthouseCategory{
+						"seo":         {Score: 0.5},
+						"performance": {Score: 1},
+					},
+				}},
+			},
+			"security": {Status: tide.AuditStatusSkipped},
+		},
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	exportedAt := time.Unix(1528894921, 0)
+	base := Row{
+		Checksum:    "checksum",
+		Title:       "Plugin",
+		Slug:        "plugin",
+		Version:     "1.0.0",
+		ProjectType: "plugin",
+		SourceURL:   "https://example.com/plugin.zip",
+		SourceType:  "zip",
+		Verdict:     tide.VerdictPass,
+		Environment: "fingerprint",
+		ExportedAt:  exportedAt,
+	}
+	row := func(fn func(r *Row)) Row {
+		r := base
+		fn(&r)
+		return r
+	}
+
+	tests := []struct {
+		name string
+		item tide.Item
+		want []Row
+	}{
+		{
+			"Reports",
+			testItem(),
+			[]Row{
+				row(func(r *Row) {
+					r.Audit = "lighthouse"
+					r.Scores = []Score{{"performance", 1}, {"seo", 0.5}}
+				}),
+				row(func(r *Row) {
+					r.Audit = "phpcs_phpcompatibility"
+					r.CompatibleVersions = []string{"7.4", "8.0"}
+					r.IncompatibleVersions = []string{"8.3"}
+				}),
+				row(func(r *Row) {
+					r.Audit = "phpcs_wordpress"
+					r.Files, r.Errors, r.Warnings = 2, 3, 4
+				}),
+				row(func(r *Row) {
+					r.Audit = "security"
+					r.Status = tide.AuditStatusSkipped
+				}),
+			},
+		},
+		{
+			"Failures Only",
+			tide.Item{
+				Checksum: "checksum",
+				Failures: []tide.Failure{
+					{Code: tide.FailurePhpcsTimeout, Message: "timed out"},
+					{Code: tide.FailureStorage, Message: "upload error"},
+				},
+			},
+			[]Row{{Checksum: "checksum", Error: "timed out; upload error", ExportedAt: exportedAt}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Flatten(tt.item, exportedAt); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Flatten() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExporter_Flush(t *testing.T) {
+	tests := []struct {
+		name        string
+		sink        *mockSink
+		items       int
+		wantBatches int
+		wantFailed  int
+		wantErr     bool
+	}{
+		{"Batches", &mockSink{}, 3, 4, 0, false},
+		{"Retried", &mockSink{failures: 2}, 1, 2, 0, false},
+		{"Retries Exhausted", &mockSink{failures: 3}, 1, 1, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failed := 0
+			e := &Exporter{
+				Sink:      tt.sink,
+				BatchSize: 3,
+				Retries:   2,
+				Backoff:   time.Millisecond,
+				OnError: func(rows []Row, err error) {
+					failed += len(rows)
+				},
+			}
+			for i := 0; i < tt.items; i++ {
+				e.Export(testItem())
+			}
+
+			if err := e.Flush(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Exporter.Flush() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(tt.sink.batches) != tt.wantBatches || failed != tt.wantFailed || e.Pending() != 0 {
+				t.Errorf("Exporter.Flush() inserted %v batches, failed %v rows", len(tt.sink.batches), failed)
+			}
+		})
+	}
+
+	if err := (&Exporter{}).Flush(context.Background()); err == nil {
+		t.Error("Exporter.Flush() should fail without a sink")
+	}
+}
+
+func TestExporter_Run(t *testing.T) {
+	sink := &mockSink{}
+	e := &Exporter{Sink: sink, BatchSize: 4, Interval: time.Hour}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+
+	// A full batch is inserted without waiting for the interval.
+	e.Export(testItem())
+	deadline := time.Now().Add(time.Second)
+	for sink.inserted() != 4 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := sink.inserted(); got != 4 {
+		t.Fatalf("Exporter.Run() inserted %v rows, want 4", got)
+	}
+
+	// The remaining rows are inserted when the exporter stops.
+	e.Export(tide.Item{Checksum: "failed"})
+	cancelFunc()
+	<-done
+
+	if got := sink.inserted(); got != 5 {
+		t.Errorf("Exporter.Run() inserted %v rows, want 5", got)
+	}
+}
+
+func TestRow_ID(t *testing.T) {
+	r := Row{Checksum: "checksum", Audit: "phpcs_wordpress", ExportedAt: time.Unix(1, 5)}
+	if got := r.ID(); got != "checksum:phpcs_wordpress:1000000005" {
+		t.Errorf("Row.ID() = %v", got)
+	}
+}
@@ -0,0 +1,52 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/wptide/pkg/storage"
+)
+
+// StorageSink is a Sink that uploads each batch as a newline delimited JSON file, which
+// most warehouses can load, e.g. with `bq load --source_format=NEWLINE_DELIMITED_JSON`.
+type StorageSink struct {
+	Provider   storage.Provider // Storage provider for the batch files.
+	Prefix     string           // (Optional) Prefix of the references, e.g. "exports/".
+	TempFolder string           // (Optional) Folder for the temporary batch files. Defaults to os.TempDir().
+}
+
+// Insert implements Sink. The reference of a batch is the Prefix, the time of the first row and
+// the number of rows, e.g. "exports/1528894921000000000-500.jsonl".
+func (s StorageSink) Insert(ctx context.Context, rows []Row) error {
+	if s.Provider == nil {
+		return errors.New("no storage provider for exports")
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	f, err := ioutil.TempFile(s.TempFolder, "export")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	encoder := json.NewEncoder(f)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	reference := s.Prefix + strconv.FormatInt(rows[0].ExportedAt.UnixNano(), 10) + "-" + strconv.Itoa(len(rows)) + ".jsonl"
+
+	return s.Provider.UploadFile(f.Name(), reference)
+}
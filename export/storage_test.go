@@ -0,0 +1,87 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockStorage struct {
+	uploaded map[string]string
+}
+
+func (m *mockStorage) Kind() string {
+	return "mock"
+}
+
+func (m *mockStorage) CollectionRef() string {
+	return "mock-collection"
+}
+
+func (m *mockStorage) UploadFile(filename, reference string) error {
+	if strings.HasPrefix(reference, "error/") {
+		return errors.New("upload error")
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	m.uploaded[reference] = string(data)
+	return nil
+}
+
+func (m *mockStorage) DownloadFile(reference, filename string) error {
+	return nil
+}
+
+func TestStorageSink_Insert(t *testing.T) {
+	rows := Flatten(testItem(), time.Unix(1528894921, 0))
+
+	tests := []struct {
+		name     string
+		prefix   string
+		rows     []Row
+		provider bool
+		want     string
+		wantErr  bool
+	}{
+		{"Batch", "exports/", rows, true, "exports/1528894921000000000-4.jsonl", false},
+		{"Empty Batch", "exports/", nil, true, "", false},
+		{"Upload Error", "error/", rows, true, "", true},
+		{"No Provider", "exports/", rows, false, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &mockStorage{uploaded: make(map[string]string)}
+			s := StorageSink{Prefix: tt.prefix}
+			if tt.provider {
+				s.Provider = provider
+			}
+
+			err := s.Insert(context.Background(), tt.rows)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StorageSink.Insert() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.want == "" {
+				if len(provider.uploaded) != 0 {
+					t.Errorf("StorageSink.Insert() uploaded %v", provider.uploaded)
+				}
+				return
+			}
+
+			lines := strings.Split(strings.TrimSpace(provider.uploaded[tt.want]), "\n")
+			if len(lines) != len(tt.rows) {
+				t.Fatalf("StorageSink.Insert() uploaded %v lines to %v, want %v", len(lines), tt.want, len(tt.rows))
+			}
+			var row Row
+			if err := json.Unmarshal([]byte(lines[0]), &row); err != nil || row.Audit != "lighthouse" {
+				t.Errorf("StorageSink.Insert() first row = %v, %v", lines[0], err)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Package dynamodb implements a lock.Locker backed by a DynamoDB table.
+//
+// The table requires a string partition key named "key". Enabling DynamoDB TTL on the
+// "ttl" attribute removes expired locks.
+package dynamodb
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/wptide/pkg/lock"
+)
+
+var now = time.Now
+
+// Locker implements lock.Locker using conditional writes.
+type Locker struct {
+	// Use dynamodbiface instead of dynamodb.DynamoDB to benefit from the interface.
+	db    dynamodbiface.DynamoDBAPI
+	Table string
+}
+
+// Lock is a lock held in DynamoDB.
+type Lock struct {
+	db    dynamodbiface.DynamoDBAPI
+	table string
+	key   string
+	token string
+}
+
+// Acquire implements lock.Locker. The lock is written only if it does not exist or has expired.
+func (l Locker) Acquire(key string, ttl time.Duration) (lock.Lock, error) {
+	token, err := lock.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	current := now()
+	expires := current.Add(ttl)
+
+	_, err = l.db.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(l.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"key":     {S: aws.String(key)},
+			"owner":   {S: aws.String(token)},
+			"expires": {N: aws.String(strconv.FormatInt(expires.UnixNano(), 10))},
+			"ttl":     {N: aws.String(strconv.FormatInt(expires.Unix(), 10))},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(#key) OR #expires < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#key":     aws.String("key"),
+			"#expires": aws.String("expires"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(current.UnixNano(), 10))},
+		},
+	})
+	if err != nil {
+		if isConditionFailed(err) {
+			return nil, lock.ErrLocked
+		}
+		return nil, err
+	}
+
+	return &Lock{l.db, l.Table, key, token}, nil
+}
+
+// Release deletes the lock if it is still held by this owner.
+func (l *Lock) Release() error {
+	_, err := l.db.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(l.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(l.key)},
+		},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(l.token)},
+		},
+	})
+	if err != nil && isConditionFailed(err) {
+		return errors.New("dynamodb: lock is no longer held")
+	}
+	return err
+}
+
+// isConditionFailed checks if the error is caused by a failed condition expression.
+func isConditionFailed(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+}
+
+// New is a convenience method to return a new *Locker instance.
+func New(region, key, secret, table string) *Locker {
+	sess, _ := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Credentials: credentials.NewStaticCredentials(key, secret, ""),
+	})
+
+	return &Locker{
+		db:    dynamodb.New(sess),
+		Table: table,
+	}
+}
@@ -0,0 +1,113 @@
+package dynamodb
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/wptide/pkg/lock"
+)
+
+// mockDynamoDB evaluates the lock conditions against an in-memory table.
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDynamoDB) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	key := *input.Item["key"].S
+	if key == "error" {
+		return nil, errors.New("connection refused")
+	}
+
+	if existing, ok := m.items[key]; ok {
+		expires, _ := strconv.ParseInt(*existing["expires"].N, 10, 64)
+		current, _ := strconv.ParseInt(*input.ExpressionAttributeValues[":now"].N, 10, 64)
+		if expires >= current {
+			return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+		}
+	}
+
+	m.items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockDynamoDB) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	key := *input.Key["key"].S
+	if key == "deleteerror" {
+		return nil, errors.New("connection refused")
+	}
+
+	existing, ok := m.items[key]
+	if !ok || *existing["owner"].S != *input.ExpressionAttributeValues[":owner"].S {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+
+	delete(m.items, key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestLocker_Acquire(t *testing.T) {
+	current := time.Unix(1500000000, 0)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	db := &mockDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	locker := Locker{db: db, Table: "locks"}
+
+	if _, err := locker.Acquire("key", time.Minute); err != nil {
+		t.Fatalf("Locker.Acquire() error = %v", err)
+	}
+
+	if _, err := locker.Acquire("key", time.Minute); err != lock.ErrLocked {
+		t.Errorf("Locker.Acquire() error = %v, want %v", err, lock.ErrLocked)
+	}
+
+	// Expired locks can be taken over.
+	current = current.Add(2 * time.Minute)
+	if _, err := locker.Acquire("key", time.Minute); err != nil {
+		t.Errorf("Locker.Acquire() expired lock error = %v", err)
+	}
+
+	if ttl := *db.items["key"]["ttl"].N; ttl != strconv.FormatInt(current.Add(time.Minute).Unix(), 10) {
+		t.Errorf("Locker.Acquire() ttl = %v", ttl)
+	}
+
+	if _, err := locker.Acquire("error", time.Minute); err == nil || err == lock.ErrLocked {
+		t.Errorf("Locker.Acquire() error = %v, want a client error", err)
+	}
+}
+
+func TestLock_Release(t *testing.T) {
+	db := &mockDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	locker := Locker{db: db, Table: "locks"}
+
+	held, err := locker.Acquire("key", time.Minute)
+	if err != nil {
+		t.Fatalf("Locker.Acquire() error = %v", err)
+	}
+
+	if err := held.Release(); err != nil {
+		t.Errorf("Lock.Release() error = %v", err)
+	}
+
+	if err := held.Release(); err == nil {
+		t.Errorf("Lock.Release() expected an error for a released lock")
+	}
+
+	failing := &Lock{db: db, table: "locks", key: "deleteerror", token: "token"}
+	if err := failing.Release(); err == nil {
+		t.Errorf("Lock.Release() expected a client error")
+	}
+}
+
+func TestNew(t *testing.T) {
+	locker := New("us-west-2", "key", "secret", "locks")
+	if locker.db == nil || locker.Table != "locks" {
+		t.Errorf("New() = %v", locker)
+	}
+}
@@ -0,0 +1,101 @@
+// Package lock provides locks that coordinate workers so that the same project is never
+// audited by two workers at the same time, even when duplicate messages slip through.
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLocked is returned when the lock is already held by another worker.
+var ErrLocked = errors.New("lock: already held by another worker")
+
+var now = time.Now
+
+// Locker is an interface for lock providers. E.g. redis, dynamodb.
+type Locker interface {
+	// Acquire takes the lock for key. It returns ErrLocked if the lock is held by another owner.
+	// The lock expires after ttl so that crashed workers don't hold locks forever.
+	Acquire(key string, ttl time.Duration) (Lock, error)
+}
+
+// Lock is a lock held by a worker.
+type Lock interface {
+	Release() error
+}
+
+// Key returns the lock key for a project, identified by its slug and a key of the audited
+// version, e.g. its checksum or a hash of its source URL.
+func Key(slug, version string) string {
+	return "tide-lock:" + slug + ":" + version
+}
+
+// NewToken returns a random token to identify the owner of a lock.
+func NewToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Memory implements Locker for workers that run in the same process.
+type Memory struct {
+	mu    sync.Mutex
+	locks map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	token   string
+	expires time.Time
+}
+
+// memoryLock is a lock held in a Memory locker.
+type memoryLock struct {
+	locker *Memory
+	key    string
+	token  string
+}
+
+// Acquire implements Locker.
+func (m *Memory) Acquire(key string, ttl time.Duration) (Lock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.locks == nil {
+		m.locks = make(map[string]memoryEntry)
+	}
+
+	if entry, ok := m.locks[key]; ok && now().Before(entry.expires) {
+		return nil, ErrLocked
+	}
+
+	token, err := NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	m.locks[key] = memoryEntry{
+		token:   token,
+		expires: now().Add(ttl),
+	}
+
+	return &memoryLock{m, key, token}, nil
+}
+
+// Release removes the lock if it is still held by this owner.
+func (l *memoryLock) Release() error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+
+	entry, ok := l.locker.locks[l.key]
+	if !ok || entry.token != l.token {
+		return errors.New("lock: lock is no longer held")
+	}
+
+	delete(l.locker.locks, l.key)
+	return nil
+}
@@ -0,0 +1,95 @@
+package lock
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKey(t *testing.T) {
+	if got, want := Key("dummy-plugin", "abc123"), "tide-lock:dummy-plugin:abc123"; got != want {
+		t.Errorf("Key() = %v, want %v", got, want)
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatalf("NewToken() error = %v", err)
+	}
+	b, _ := NewToken()
+
+	if len(a) != 32 || a == b {
+		t.Errorf("NewToken() = %v, %v, want unique 32 character tokens", a, b)
+	}
+}
+
+func TestMemory_Acquire(t *testing.T) {
+	current := time.Unix(1500000000, 0)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	m := &Memory{}
+
+	first, err := m.Acquire("key", time.Minute)
+	if err != nil {
+		t.Fatalf("Memory.Acquire() error = %v", err)
+	}
+
+	if _, err := m.Acquire("key", time.Minute); err != ErrLocked {
+		t.Errorf("Memory.Acquire() error = %v, want %v", err, ErrLocked)
+	}
+
+	if _, err := m.Acquire("other", time.Minute); err != nil {
+		t.Errorf("Memory.Acquire() other key error = %v", err)
+	}
+
+	// The lock can be taken over once it expires.
+	current = current.Add(2 * time.Minute)
+	second, err := m.Acquire("key", time.Minute)
+	if err != nil {
+		t.Fatalf("Memory.Acquire() expired lock error = %v", err)
+	}
+
+	// The first owner can't release the lock that was taken over.
+	if err := first.Release(); err == nil {
+		t.Errorf("memoryLock.Release() expected an error for a lock that was taken over")
+	}
+
+	if err := second.Release(); err != nil {
+		t.Errorf("memoryLock.Release() error = %v", err)
+	}
+
+	if err := second.Release(); err == nil {
+		t.Errorf("memoryLock.Release() expected an error for a released lock")
+	}
+
+	if _, err := m.Acquire("key", time.Minute); err != nil {
+		t.Errorf("Memory.Acquire() released lock error = %v", err)
+	}
+}
+
+func TestMemory_Acquire_Concurrent(t *testing.T) {
+	m := &Memory{}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	acquired := 0
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Acquire("key", time.Minute); err == nil {
+				mu.Lock()
+				acquired++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired != 1 {
+		t.Errorf("Memory.Acquire() acquired = %v, want %v", acquired, 1)
+	}
+}
@@ -0,0 +1,28 @@
+package redis
+
+import (
+	"time"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// goRedis is a Client of a go-redis client.
+type goRedis struct {
+	client goredis.UniversalClient
+}
+
+// GoRedisClient returns a Client of the go-redis client, e.g. a *redis.Client or a
+// *redis.ClusterClient. The client is closed by its owner.
+func GoRedisClient(client goredis.UniversalClient) Client {
+	return goRedis{client: client}
+}
+
+// SetNX implements Client.
+func (c goRedis) SetNX(key, value string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(key, value, ttl).Result()
+}
+
+// Eval implements Client. Integer replies are returned as int64.
+func (c goRedis) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	return c.client.Eval(script, keys, args...).Result()
+}
@@ -0,0 +1,47 @@
+//go:build integration
+// +build integration
+
+package redis
+
+import (
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/wptide/pkg/lock"
+	"github.com/wptide/pkg/testenv"
+)
+
+func TestLocker_Redis(t *testing.T) {
+	options, err := goredis.ParseURL(testenv.Redis(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := goredis.NewClient(options)
+	defer client.Close()
+
+	locker := New(GoRedisClient(client))
+	key := lock.Key("akismet", "checksum")
+
+	l, err := locker.Acquire(key, time.Minute)
+	if err != nil {
+		t.Fatalf("Locker.Acquire() error = %v", err)
+	}
+	if _, err := locker.Acquire(key, time.Minute); err != lock.ErrLocked {
+		t.Errorf("Locker.Acquire() of a held lock error = %v, want ErrLocked", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Lock.Release() error = %v", err)
+	}
+	if err := l.Release(); err == nil {
+		t.Error("Lock.Release() of a released lock error = nil, want error")
+	}
+
+	// Released locks can be acquired again.
+	l, err = locker.Acquire(key, time.Minute)
+	if err != nil {
+		t.Fatalf("Locker.Acquire() after release error = %v", err)
+	}
+	l.Release()
+}
@@ -0,0 +1,74 @@
+// Package redis implements a lock.Locker backed by Redis.
+package redis
+
+import (
+	"errors"
+	"time"
+
+	"github.com/wptide/pkg/lock"
+)
+
+// releaseScript deletes the key only if it is still held by the owner.
+const releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// Client describes the Redis commands required for locking.
+// Redis clients can be adapted to this interface, see GoRedisClient for go-redis.
+type Client interface {
+	// SetNX sets key to value with an expiry if key does not exist.
+	SetNX(key, value string, ttl time.Duration) (bool, error)
+	// Eval runs a Lua script.
+	Eval(script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Locker implements lock.Locker using SET NX PX.
+type Locker struct {
+	client Client
+}
+
+// Lock is a lock held in Redis.
+type Lock struct {
+	client Client
+	key    string
+	token  string
+}
+
+// New returns a new Redis Locker.
+func New(client Client) *Locker {
+	return &Locker{client: client}
+}
+
+// Acquire implements lock.Locker.
+func (l Locker) Acquire(key string, ttl time.Duration) (lock.Lock, error) {
+	if l.client == nil {
+		return nil, errors.New("redis: no client")
+	}
+
+	token, err := lock.NewToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := l.client.SetNX(key, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, lock.ErrLocked
+	}
+
+	return &Lock{l.client, key, token}, nil
+}
+
+// Release deletes the lock if it is still held by this owner.
+func (l *Lock) Release() error {
+	deleted, err := l.client.Eval(releaseScript, []string{l.key}, l.token)
+	if err != nil {
+		return err
+	}
+
+	if n, ok := deleted.(int64); !ok || n == 0 {
+		return errors.New("redis: lock is no longer held")
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/lock"
+)
+
+// mockClient stores keys in a map and fails for keys named "error".
+type mockClient struct {
+	keys map[string]string
+}
+
+func (m *mockClient) SetNX(key, value string, ttl time.Duration) (bool, error) {
+	if key == "error" {
+		return false, errors.New("connection refused")
+	}
+	if _, ok := m.keys[key]; ok {
+		return false, nil
+	}
+	m.keys[key] = value
+	return true, nil
+}
+
+func (m *mockClient) Eval(script string, keys []string, args ...interface{}) (interface{}, error) {
+	if script != releaseScript {
+		return nil, errors.New("unexpected script")
+	}
+	if keys[0] == "evalerror" {
+		return nil, errors.New("connection refused")
+	}
+	if m.keys[keys[0]] != args[0] {
+		return int64(0), nil
+	}
+	delete(m.keys, keys[0])
+	return int64(1), nil
+}
+
+func TestLocker_Acquire(t *testing.T) {
+	client := &mockClient{keys: map[string]string{"held": "other-token"}}
+	locker := New(client)
+
+	tests := []struct {
+		name    string
+		l       *Locker
+		key     string
+		wantErr error
+	}{
+		{"Acquire", locker, "key", nil},
+		{"Already Held", locker, "held", lock.ErrLocked},
+		{"Client Error", locker, "error", errors.New("connection refused")},
+		{"No Client", New(nil), "key", errors.New("redis: no client")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.l.Acquire(tt.key, time.Minute)
+			if (err != nil) != (tt.wantErr != nil) || (err != nil && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("Locker.Acquire() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got == nil {
+				t.Errorf("Locker.Acquire() returned no lock")
+			}
+		})
+	}
+}
+
+func TestLock_Release(t *testing.T) {
+	client := &mockClient{keys: map[string]string{}}
+	locker := New(client)
+
+	held, err := locker.Acquire("key", time.Minute)
+	if err != nil {
+		t.Fatalf("Locker.Acquire() error = %v", err)
+	}
+
+	if err := held.Release(); err != nil {
+		t.Errorf("Lock.Release() error = %v", err)
+	}
+
+	if _, ok := client.keys["key"]; ok {
+		t.Errorf("Lock.Release() did not delete the key")
+	}
+
+	// Releasing again fails because the lock is no longer held.
+	if err := held.Release(); err == nil {
+		t.Errorf("Lock.Release() expected an error for a released lock")
+	}
+
+	// Locks taken over by another owner are not deleted.
+	taken, _ := locker.Acquire("taken", time.Minute)
+	client.keys["taken"] = "other-token"
+	if err := taken.Release(); err == nil || client.keys["taken"] != "other-token" {
+		t.Errorf("Lock.Release() should not release a lock held by another owner")
+	}
+
+	failing := &Lock{client: client, key: "evalerror", token: "token"}
+	if err := failing.Release(); err == nil {
+		t.Errorf("Lock.Release() expected a client error")
+	}
+}
@@ -0,0 +1,338 @@
+package amqp
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/wptide/pkg/message"
+)
+
+// testProvider returns a provider of the "audits" queue of the broker.
+func testProvider(broker *mockBroker) *Provider {
+	p := NewWithDialer(broker.dial, "audits")
+	p.Timeout = 10 * time.Millisecond
+	return p
+}
+
+func TestProvider_SendMessage(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+
+	msg := &message.Message{Title: "Akismet", Slug: "akismet"}
+	if err := p.SendMessage(msg); err != nil {
+		t.Fatalf("Provider.SendMessage() error = %v", err)
+	}
+
+	if len(broker.published) != 1 {
+		t.Fatalf("Provider.SendMessage() published %v", broker.published)
+	}
+	published := broker.published[0]
+	if published.DeliveryMode != amqp.Persistent || published.ContentType != "application/json" {
+		t.Errorf("Provider.SendMessage() published %+v, want a persistent JSON message", published)
+	}
+
+	var got message.Message
+	if err := json.Unmarshal(published.Body, &got); err != nil || !reflect.DeepEqual(&got, msg) {
+		t.Errorf("Provider.SendMessage() body = %s, want %v", published.Body, msg)
+	}
+
+	// The queue is durable and consumed with manual acknowledgements.
+	ch := broker.channels[0]
+	if !ch.durable || ch.prefetch != 1 {
+		t.Errorf("Provider declared durable %v with prefetch %v, want durable with prefetch 1", ch.durable, ch.prefetch)
+	}
+}
+
+func TestProvider_SendMessage_Reconnect(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+	p.SendMessage(&message.Message{})
+
+	// Publishing on a broken channel dials a new one.
+	broker.channels[0].publishErr = amqp.ErrClosed
+	if err := p.SendMessage(&message.Message{Title: "Again"}); err != nil {
+		t.Fatalf("Provider.SendMessage() error = %v", err)
+	}
+	if len(broker.channels) != 2 || len(broker.published) != 2 || !broker.channels[0].closed {
+		t.Errorf("Provider.SendMessage() dialed %v channels and published %v messages", len(broker.channels), len(broker.published))
+	}
+
+	// Errors of the new channel are returned.
+	broker.channels[1].publishErr = errors.New("something went wrong")
+	broker.dialErr = errors.New("connection refused")
+	if err := p.SendMessage(&message.Message{}); err == nil {
+		t.Error("Provider.SendMessage() error = nil, want error")
+	}
+}
+
+func TestProvider_GetNextMessage(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+	p.Prefetch = 4
+	p.SendMessage(&message.Message{Title: "Success!"})
+	broker.queue = append(broker.queue, []byte("{not json"))
+
+	broker.deliver()
+	got, err := p.GetNextMessage()
+	if err != nil {
+		t.Fatalf("Provider.GetNextMessage() error = %v", err)
+	}
+	ref := "1-1"
+	if want := (&message.Message{Title: "Success!", ExternalRef: &ref}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Provider.GetNextMessage() = %v, want %v", got, want)
+	}
+	if prefetch := broker.channels[0].prefetch; prefetch != 4 {
+		t.Errorf("Provider prefetch = %v, want 4", prefetch)
+	}
+
+	// Messages that don't decode are returned with their reference, so they can be rejected.
+	broker.deliver()
+	got, err = p.GetNextMessage()
+	if err == nil || got == nil || *got.ExternalRef != "1-2" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want undecoded message with error", got, err)
+	}
+
+	// Idle queues time out without a provider error.
+	got, err = p.GetNextMessage()
+	if _, ok := err.(*message.ProviderError); got != nil || err == nil || ok {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want no message", got, err)
+	}
+}
+
+func TestProvider_GetNextMessage_NoWait(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+	p.Timeout = -1
+
+	// Idle queues return at once.
+	start := time.Now()
+	if got, err := p.GetNextMessage(); got != nil || err == nil {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want no message", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Provider.GetNextMessage() waited %v, want no wait", elapsed)
+	}
+
+	p.SendMessage(&message.Message{Title: "Success!"})
+	broker.deliver()
+	if got, err := p.GetNextMessage(); err != nil || got.Title != "Success!" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want the delivered message", got, err)
+	}
+}
+
+func TestProvider_ReceiveWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		poller  *message.Poller
+		want    time.Duration
+	}{
+		{"Default", 0, nil, defaultTimeout},
+		{"Timeout", time.Second, nil, time.Second},
+		{"No Wait", -1, nil, 0},
+		{"Poller", -1, message.NewPoller(time.Second, time.Minute), time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Provider{Timeout: tt.timeout, Poller: tt.poller}
+			if got := p.ReceiveWait(); got != tt.want {
+				t.Errorf("Provider.ReceiveWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvider_GetNextMessage_Reconnect(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+	p.SendMessage(&message.Message{Title: "First"})
+	p.SendMessage(&message.Message{Title: "Second"})
+
+	broker.deliver()
+	first, _ := p.GetNextMessage()
+
+	// The broker closes the channel, e.g. because the connection was lost.
+	broker.channels[0].breakChannel()
+	broker.dialErr = errors.New("connection refused")
+	if msg, err := p.GetNextMessage(); msg != nil || err == nil {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want error", msg, err)
+	} else if _, ok := err.(*message.ProviderError); !ok {
+		t.Errorf("Provider.GetNextMessage() error = %#v, want provider error", err)
+	}
+
+	// Deliveries of the closed channel are delivered again by the broker instead.
+	if err := p.DeleteMessage(first.ExternalRef); err == nil {
+		t.Error("Provider.DeleteMessage() error = nil, want error for a closed channel")
+	}
+
+	// The next receive dials a new channel.
+	broker.dialErr = nil
+	p.GetNextMessage()
+	broker.deliver()
+	second, err := p.GetNextMessage()
+	if err != nil || second.Title != "Second" || *second.ExternalRef != "2-1" {
+		t.Fatalf("Provider.GetNextMessage() = %v, %v", second, err)
+	}
+	if err := p.DeleteMessage(second.ExternalRef); err != nil {
+		t.Errorf("Provider.DeleteMessage() error = %v", err)
+	}
+}
+
+func TestProvider_GetNextMessage_Poller(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	broker := &mockBroker{}
+	p := testProvider(broker)
+	p.Poller = message.NewPoller(time.Second, 4*time.Second)
+
+	// Back off while the queue is idle.
+	for i := 0; i < 3; i++ {
+		p.GetNextMessage()
+	}
+
+	// Receive without waiting once messages are flowing again.
+	p.SendMessage(&message.Message{})
+	p.SendMessage(&message.Message{})
+	broker.deliver()
+	broker.deliver()
+	p.GetNextMessage()
+	p.GetNextMessage()
+
+	want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 0}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept = %v, want %v", slept, want)
+	}
+}
+
+func TestProvider_Settle(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+	p.DeadLetterExchange = "audits-dlx"
+	for i := 0; i < 3; i++ {
+		p.SendMessage(&message.Message{})
+		broker.deliver()
+	}
+
+	var refs []*string
+	for i := 0; i < 3; i++ {
+		msg, err := p.GetNextMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, msg.ExternalRef)
+	}
+
+	if err := p.DeleteMessage(refs[0]); err != nil {
+		t.Errorf("Provider.DeleteMessage() error = %v", err)
+	}
+	if err := p.ReleaseMessage(refs[1]); err != nil {
+		t.Errorf("Provider.ReleaseMessage() error = %v", err)
+	}
+	if err := p.RejectMessage(refs[2]); err != nil {
+		t.Errorf("Provider.RejectMessage() error = %v", err)
+	}
+
+	ch := broker.channels[0]
+	if !reflect.DeepEqual(ch.acked, []uint64{1}) {
+		t.Errorf("Provider acked %v, want [1]", ch.acked)
+	}
+	if want := map[uint64]bool{2: true, 3: false}; !reflect.DeepEqual(ch.nacked, want) {
+		t.Errorf("Provider nacked %v, want %v", ch.nacked, want)
+	}
+	if want := (amqp.Table{"x-dead-letter-exchange": "audits-dlx"}); !reflect.DeepEqual(ch.declared, want) {
+		t.Errorf("Provider declared the queue with %v, want %v", ch.declared, want)
+	}
+
+	invalid := "invalid"
+	for _, ref := range []*string{nil, &invalid} {
+		if err := p.DeleteMessage(ref); err == nil {
+			t.Errorf("Provider.DeleteMessage(%v) error = nil, want error", ref)
+		}
+	}
+}
+
+func TestProvider_Lease(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+	lease := message.NewLeaseProvider(p)
+	for i := 0; i < 3; i++ {
+		lease.SendMessage(&message.Message{})
+		broker.deliver()
+	}
+
+	var refs []*string
+	for i := 0; i < 3; i++ {
+		msg, err := lease.GetNextMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, msg.ExternalRef)
+	}
+
+	if err := lease.ExtendLease(refs[0], time.Hour); err != nil {
+		t.Errorf("Provider.ExtendLease() error = %v", err)
+	}
+	lease.Ack(refs[0])
+	lease.Nack(refs[1], true)
+	lease.Nack(refs[2], false)
+
+	ch := broker.channels[0]
+	if !reflect.DeepEqual(ch.acked, []uint64{1}) {
+		t.Errorf("Provider acked %v, want [1]", ch.acked)
+	}
+	if want := map[uint64]bool{2: true, 3: false}; !reflect.DeepEqual(ch.nacked, want) {
+		t.Errorf("Provider nacked %v, want %v", ch.nacked, want)
+	}
+
+	// Deliveries of closed channels are delivered again, their leases are lost.
+	lease.Close()
+	if err := lease.ExtendLease(refs[0], time.Hour); err == nil {
+		t.Errorf("Provider.ExtendLease() error = nil after Close")
+	}
+}
+
+func TestProvider_Close(t *testing.T) {
+	broker := &mockBroker{}
+	p := testProvider(broker)
+
+	if err := p.Close(); err != nil {
+		t.Errorf("Provider.Close() error = %v", err)
+	}
+
+	p.SendMessage(&message.Message{})
+	if err := p.Close(); err != nil || !broker.channels[0].closed {
+		t.Errorf("Provider.Close() error = %v, closed %v", err, broker.channels[0].closed)
+	}
+}
+
+func Test_parseRef(t *testing.T) {
+	tests := []struct {
+		ref            string
+		wantGeneration uint64
+		wantTag        uint64
+		wantErr        bool
+	}{
+		{"1-42", 1, 42, false},
+		{"12-7", 12, 7, false},
+		{"42", 0, 0, true},
+		{"a-1", 0, 0, true},
+		{"1-", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			generation, tag, err := parseRef(tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if generation != tt.wantGeneration || tag != tt.wantTag {
+				t.Errorf("parseRef() = %v, %v, want %v, %v", generation, tag, tt.wantGeneration, tt.wantTag)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+//go:build integration
+// +build integration
+
+package amqp
+
+import (
+	"testing"
+
+	"github.com/wptide/pkg/message/messagetest"
+	"github.com/wptide/pkg/testenv"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	messagetest.Run(t, New(testenv.RabbitMQ(t), "tide-audits"), messagetest.Options{})
+}
@@ -0,0 +1,122 @@
+package amqp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// mockBroker is an in-memory broker with a single queue. Each dial opens a new mockChannel.
+type mockBroker struct {
+	sync.Mutex
+	queue     [][]byte
+	published []amqp.Publishing
+	channels  []*mockChannel
+	dialErr   error
+}
+
+// dial implements Dialer.
+func (b *mockBroker) dial() (Channel, io.Closer, error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.dialErr != nil {
+		return nil, nil, b.dialErr
+	}
+	ch := &mockChannel{broker: b, deliveries: make(chan amqp.Delivery, 10)}
+	b.channels = append(b.channels, ch)
+	return ch, ch, nil
+}
+
+// deliver delivers the next message of the queue on the latest channel.
+func (b *mockBroker) deliver() {
+	b.Lock()
+	defer b.Unlock()
+	ch := b.channels[len(b.channels)-1]
+	ch.tag++
+	ch.deliveries <- amqp.Delivery{DeliveryTag: ch.tag, Body: b.queue[0]}
+	b.queue = b.queue[1:]
+}
+
+// mockChannel is a channel of the mockBroker.
+type mockChannel struct {
+	broker     *mockBroker
+	deliveries chan amqp.Delivery
+	notify     chan *amqp.Error
+	tag        uint64
+
+	declared   amqp.Table
+	durable    bool
+	prefetch   int
+	acked      []uint64
+	nacked     map[uint64]bool // Requeue of the nacked deliveries.
+	publishErr error
+	closed     bool
+}
+
+func (c *mockChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error) {
+	c.durable = durable
+	c.declared = args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (c *mockChannel) Qos(prefetchCount, prefetchSize int, global bool) error {
+	c.prefetch = prefetchCount
+	return nil
+}
+
+func (c *mockChannel) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if c.publishErr != nil {
+		return c.publishErr
+	}
+	c.broker.Lock()
+	defer c.broker.Unlock()
+	c.broker.published = append(c.broker.published, msg)
+	c.broker.queue = append(c.broker.queue, msg.Body)
+	return nil
+}
+
+func (c *mockChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWait bool, args amqp.Table) (<-chan amqp.Delivery, error) {
+	if autoAck {
+		return nil, errors.New("messages must be acknowledged manually")
+	}
+	return c.deliveries, nil
+}
+
+func (c *mockChannel) Ack(tag uint64, multiple bool) error {
+	if c.closed {
+		return amqp.ErrClosed
+	}
+	c.acked = append(c.acked, tag)
+	return nil
+}
+
+func (c *mockChannel) Nack(tag uint64, multiple, requeue bool) error {
+	if c.closed {
+		return amqp.ErrClosed
+	}
+	if c.nacked == nil {
+		c.nacked = make(map[uint64]bool)
+	}
+	c.nacked[tag] = requeue
+	return nil
+}
+
+func (c *mockChannel) NotifyClose(notify chan *amqp.Error) chan *amqp.Error {
+	c.notify = notify
+	return notify
+}
+
+// breakChannel closes the channel as the broker does, e.g. when the connection is lost.
+func (c *mockChannel) breakChannel() {
+	c.notify <- &amqp.Error{Code: 320, Reason: "CONNECTION_FORCED"}
+	close(c.notify)
+	close(c.deliveries)
+}
+
+func (c *mockChannel) Close() error {
+	c.closed = true
+	return nil
+}
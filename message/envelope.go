@@ -0,0 +1,161 @@
+package message
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Envelope is a message as it was received from a queue, before it is decoded.
+type Envelope struct {
+	Body       []byte
+	Attributes map[string]string // Provider metadata, e.g. Pub/Sub attributes or Kafka headers.
+	Ref        *string           // Reference used to delete the message from the queue.
+}
+
+// EnvelopeProvider is implemented by queues that return messages without decoding them.
+type EnvelopeProvider interface {
+	GetNextEnvelope() (*Envelope, error)
+	DeleteMessage(ref *string) error
+	Close() error
+}
+
+// Decoder converts a provider-specific envelope into a Message.
+type Decoder interface {
+	Decode(env *Envelope) (*Message, error)
+}
+
+// DecoderFunc is an adapter to use ordinary functions as a Decoder.
+type DecoderFunc func(env *Envelope) (*Message, error)
+
+// Decode calls f(env).
+func (f DecoderFunc) Decode(env *Envelope) (*Message, error) {
+	return f(env)
+}
+
+// JSONDecoder decodes envelopes whose body is the message as JSON, e.g. SQS messages.
+// Messages that were delivered to the queue by SNS are unwrapped first.
+type JSONDecoder struct{}
+
+// Decode implements Decoder.
+func (JSONDecoder) Decode(env *Envelope) (*Message, error) {
+	body := env.Body
+
+	var notification struct {
+		Type    string
+		Message string
+	}
+	if json.Unmarshal(body, &notification) == nil && notification.Type == "Notification" {
+		body = []byte(notification.Message)
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	msg.ExternalRef = env.Ref
+
+	return msg, nil
+}
+
+// AttributeDecoder decodes envelopes that carry the message fields as key/value metadata,
+// e.g. Pub/Sub attributes or Kafka headers. The keys are the JSON names of the fields, e.g.
+// "source_url", optionally with a prefix. "audits" and "standards" are JSON encoded.
+//
+// A JSON body is decoded first, so the metadata can override or complete it.
+type AttributeDecoder struct {
+	Prefix string // (Optional) Prefix of the keys, e.g. "tide-".
+}
+
+// Decode implements Decoder.
+func (a AttributeDecoder) Decode(env *Envelope) (*Message, error) {
+	fields := make(map[string]interface{})
+	if len(env.Body) != 0 {
+		if err := json.Unmarshal(env.Body, &fields); err != nil {
+			return nil, errors.New("could not decode message body: " + err.Error())
+		}
+	}
+
+	found := false
+	for key, value := range env.Attributes {
+		if !strings.HasPrefix(key, a.Prefix) {
+			continue
+		}
+		key = strings.TrimPrefix(key, a.Prefix)
+
+		switch key {
+		case "force":
+			force, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, errors.New("invalid force attribute: " + value)
+			}
+			fields[key] = force
+		case "audits", "standards":
+			fields[key] = json.RawMessage(value)
+		default:
+			fields[key] = value
+		}
+		found = true
+	}
+
+	if !found && len(env.Body) == 0 {
+		return nil, errors.New("envelope has no message")
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{}
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	msg.ExternalRef = env.Ref
+
+	return msg, nil
+}
+
+// DecodingProvider is a Provider that decodes the envelopes of an EnvelopeProvider.
+// Messages can only be received, sending returns an error.
+type DecodingProvider struct {
+	Source  EnvelopeProvider
+	Decoder Decoder // (Optional) Defaults to JSONDecoder.
+}
+
+// SendMessage implements Provider.
+func (d DecodingProvider) SendMessage(msg *Message) error {
+	return errors.New("decoding provider can not send messages")
+}
+
+// GetNextMessage implements Provider. The message is returned with its reference when it can
+// not be decoded, so that it can be deleted.
+func (d DecodingProvider) GetNextMessage() (*Message, error) {
+	env, err := d.Source.GetNextEnvelope()
+	if env == nil || err != nil {
+		return nil, err
+	}
+
+	decoder := d.Decoder
+	if decoder == nil {
+		decoder = JSONDecoder{}
+	}
+
+	msg, err := decoder.Decode(env)
+	if err != nil {
+		return &Message{ExternalRef: env.Ref}, err
+	}
+
+	return msg, nil
+}
+
+// DeleteMessage implements Provider.
+func (d DecodingProvider) DeleteMessage(ref *string) error {
+	return d.Source.DeleteMessage(ref)
+}
+
+// Close implements Provider.
+func (d DecodingProvider) Close() error {
+	return d.Source.Close()
+}
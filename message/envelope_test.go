@@ -0,0 +1,207 @@
+package message
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestJSONDecoder_Decode(t *testing.T) {
+	ref := "receipt"
+	tests := []struct {
+		name    string
+		env     *Envelope
+		want    *Message
+		wantErr bool
+	}{
+		{
+			"SQS",
+			&Envelope{Body: []byte(`{"title":"Plugin","source_url":"http://example.com/plugin.zip","force":true}`), Ref: &ref},
+			&Message{Title: "Plugin", SourceURL: "http://example.com/plugin.zip", Force: true, ExternalRef: &ref},
+			false,
+		},
+		{
+			"SNS Notification",
+			&Envelope{Body: []byte(`{"Type":"Notification","Message":"{\"title\":\"Plugin\"}"}`), Ref: &ref},
+			&Message{Title: "Plugin", ExternalRef: &ref},
+			false,
+		},
+		{
+			"Invalid JSON",
+			&Envelope{Body: []byte(`{"title":`)},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := JSONDecoder{}.Decode(tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("JSONDecoder.Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JSONDecoder.Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeDecoder_Decode(t *testing.T) {
+	ref := "ack-id"
+	tests := []struct {
+		name    string
+		decoder AttributeDecoder
+		env     *Envelope
+		want    *Message
+		wantErr bool
+	}{
+		{
+			"Attributes",
+			AttributeDecoder{},
+			&Envelope{
+				Attributes: map[string]string{
+					"title":     "Plugin",
+					"force":     "true",
+					"standards": `["wordpress"]`,
+					"audits":    `[{"type":"phpcs","options":{"standard":"wordpress"}}]`,
+				},
+				Ref: &ref,
+			},
+			&Message{
+				Title:       "Plugin",
+				Force:       true,
+				Standards:   []string{"wordpress"},
+				Audits:      []*Audit{{Type: "phpcs", Options: &AuditOption{Standard: "wordpress"}}},
+				ExternalRef: &ref,
+			},
+			false,
+		},
+		{
+			"Prefixed Headers Override Body",
+			AttributeDecoder{Prefix: "tide-"},
+			&Envelope{
+				Body:       []byte(`{"title":"Plugin","slug":"plugin"}`),
+				Attributes: map[string]string{"tide-title": "Theme", "content-type": "application/json"},
+			},
+			&Message{Title: "Theme", Slug: "plugin"},
+			false,
+		},
+		{
+			"Invalid Force",
+			AttributeDecoder{},
+			&Envelope{Attributes: map[string]string{"force": "maybe"}},
+			nil,
+			true,
+		},
+		{
+			"Invalid Audits",
+			AttributeDecoder{},
+			&Envelope{Attributes: map[string]string{"audits": `[{`}},
+			nil,
+			true,
+		},
+		{
+			"Invalid Body",
+			AttributeDecoder{},
+			&Envelope{Body: []byte(`plain text`)},
+			nil,
+			true,
+		},
+		{
+			"Empty",
+			AttributeDecoder{Prefix: "tide-"},
+			&Envelope{Attributes: map[string]string{"title": "Plugin"}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.decoder.Decode(tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AttributeDecoder.Decode() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AttributeDecoder.Decode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+type mockEnvelopeProvider struct {
+	env     *Envelope
+	err     error
+	deleted *string
+}
+
+func (m *mockEnvelopeProvider) GetNextEnvelope() (*Envelope, error) {
+	return m.env, m.err
+}
+
+func (m *mockEnvelopeProvider) DeleteMessage(ref *string) error {
+	m.deleted = ref
+	return nil
+}
+
+func (m *mockEnvelopeProvider) Close() error {
+	return nil
+}
+
+func TestDecodingProvider_GetNextMessage(t *testing.T) {
+	ref := "receipt"
+	failing := DecoderFunc(func(env *Envelope) (*Message, error) {
+		return nil, errors.New("decode error")
+	})
+
+	tests := []struct {
+		name    string
+		source  *mockEnvelopeProvider
+		decoder Decoder
+		want    *Message
+		wantErr bool
+	}{
+		{
+			"Default Decoder",
+			&mockEnvelopeProvider{env: &Envelope{Body: []byte(`{"title":"Plugin"}`), Ref: &ref}},
+			nil,
+			&Message{Title: "Plugin", ExternalRef: &ref},
+			false,
+		},
+		{
+			"Empty Queue",
+			&mockEnvelopeProvider{err: errors.New("could not retrieve message")},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Decode Error",
+			&mockEnvelopeProvider{env: &Envelope{Body: []byte(`{}`), Ref: &ref}},
+			failing,
+			&Message{ExternalRef: &ref},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := DecodingProvider{Source: tt.source, Decoder: tt.decoder}
+			got, err := d.GetNextMessage()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DecodingProvider.GetNextMessage() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodingProvider.GetNextMessage() = %v, want %v", got, tt.want)
+			}
+
+			if err := d.DeleteMessage(&ref); err != nil || tt.source.deleted != &ref {
+				t.Errorf("DecodingProvider.DeleteMessage() did not delete %v", ref)
+			}
+			if err := d.SendMessage(&Message{}); err == nil {
+				t.Errorf("DecodingProvider.SendMessage() should fail")
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package message
+
+import (
+	"sync"
+	"time"
+)
+
+// Extensions are the running lease extensions of a provider, by message reference. Providers
+// whose leases expire unless they are extended, e.g. Pub/Sub ack deadlines or SQS visibility
+// timeouts, use them to keep messages leased while they are processed.
+//
+// Extensions of a nil *Extensions run without being kept track of.
+type Extensions struct {
+	mu    sync.Mutex
+	stops map[string]func()
+}
+
+// NewExtensions returns an empty set of extensions.
+func NewExtensions() *Extensions {
+	return &Extensions{stops: make(map[string]func())}
+}
+
+// Start calls extend on each tick of the ticker until stop is called or extend returns an
+// error, e.g. once the message was deleted. A running extension of the reference is stopped
+// first. The ticker is stopped with the extension.
+func (e *Extensions) Start(ref string, ticker *time.Ticker, extend func() error) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			e.remove(ref)
+			close(done)
+		})
+		<-exited
+	}
+
+	e.Stop(ref)
+	e.add(ref, stop)
+
+	go func() {
+		defer close(exited)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			// The message may have been settled while waiting.
+			select {
+			case <-done:
+				return
+			default:
+			}
+
+			if err := extend(); err != nil {
+				e.remove(ref)
+				return
+			}
+		}
+	}()
+
+	return stop
+}
+
+// Stop stops the extension of the reference, if it runs.
+func (e *Extensions) Stop(ref string) {
+	if stop := e.remove(ref); stop != nil {
+		stop()
+	}
+}
+
+// StopAll stops all extensions.
+func (e *Extensions) StopAll() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	stops := e.stops
+	e.stops = make(map[string]func())
+	e.mu.Unlock()
+
+	for _, stop := range stops {
+		stop()
+	}
+}
+
+// Len returns the number of running extensions.
+func (e *Extensions) Len() int {
+	if e == nil {
+		return 0
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.stops)
+}
+
+// add remembers how to stop extending the reference.
+func (e *Extensions) add(ref string, stop func()) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stops[ref] = stop
+}
+
+// remove forgets the extension of the reference and returns its stop function, or nil if it
+// does not run.
+func (e *Extensions) remove(ref string) func() {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stop := e.stops[ref]
+	delete(e.stops, ref)
+	return stop
+}
@@ -0,0 +1,106 @@
+package message
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitFor waits until the condition holds.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExtensions_Start(t *testing.T) {
+	e := NewExtensions()
+
+	var calls int32
+	stop := e.Start("ref-1", time.NewTicker(time.Millisecond), func() error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	waitFor(t, "extensions", func() bool { return atomic.LoadInt32(&calls) >= 2 })
+
+	if n := e.Len(); n != 1 {
+		t.Errorf("Extensions.Len() = %v, want 1", n)
+	}
+
+	stop()
+	stop()
+	if n := e.Len(); n != 0 {
+		t.Errorf("Extensions.Len() after stop = %v, want 0", n)
+	}
+
+	// No extensions once stopped.
+	extended := atomic.LoadInt32(&calls)
+	time.Sleep(10 * time.Millisecond)
+	if got := atomic.LoadInt32(&calls); got != extended {
+		t.Errorf("Extensions.Start() extended %v times after stop, want %v", got, extended)
+	}
+}
+
+func TestExtensions_Start_Refused(t *testing.T) {
+	e := NewExtensions()
+
+	stop := e.Start("ref-1", time.NewTicker(time.Millisecond), func() error {
+		return errors.New("message was deleted")
+	})
+	waitFor(t, "refused extension to end", func() bool { return e.Len() == 0 })
+	stop()
+}
+
+func TestExtensions_Start_Restart(t *testing.T) {
+	e := NewExtensions()
+
+	var first, second int32
+	e.Start("ref-1", time.NewTicker(time.Millisecond), func() error {
+		atomic.AddInt32(&first, 1)
+		return nil
+	})
+	e.Start("ref-1", time.NewTicker(time.Millisecond), func() error {
+		atomic.AddInt32(&second, 1)
+		return nil
+	})
+	waitFor(t, "restarted extension", func() bool { return atomic.LoadInt32(&second) >= 1 })
+
+	// The first extension was stopped by the second.
+	extended := atomic.LoadInt32(&first)
+	time.Sleep(10 * time.Millisecond)
+	if got := atomic.LoadInt32(&first); got != extended {
+		t.Errorf("Extensions.Start() kept the previous extension running")
+	}
+	if n := e.Len(); n != 1 {
+		t.Errorf("Extensions.Len() = %v, want 1", n)
+	}
+
+	e.StopAll()
+	if n := e.Len(); n != 0 {
+		t.Errorf("Extensions.Len() after StopAll = %v, want 0", n)
+	}
+}
+
+func TestExtensions_Nil(t *testing.T) {
+	var e *Extensions
+
+	var calls int32
+	stop := e.Start("ref-1", time.NewTicker(time.Millisecond), func() error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	waitFor(t, "untracked extension", func() bool { return atomic.LoadInt32(&calls) >= 1 })
+	stop()
+
+	e.Stop("ref-1")
+	e.StopAll()
+	if n := e.Len(); n != 0 {
+		t.Errorf("Extensions.Len() = %v, want 0", n)
+	}
+}
@@ -25,8 +25,12 @@ type Provider struct {
 	ctx      context.Context
 	client   fsClient.ClientInterface
 	rootPath string
+	Poller   *message.Poller // (Optional) Waits longer between polls while the queue is idle.
 }
 
+// sleep waits between polls.
+var sleep = time.Sleep
+
 // SendMessage sends a message to Firestore.
 func (fs Provider) SendMessage(msg *message.Message) error {
 	return fs.client.AddDoc(fs.rootPath, generateMessage(msg))
@@ -36,7 +40,11 @@ func (fs Provider) SendMessage(msg *message.Message) error {
 //
 // This uses Firestore transactions to update the lock time and
 // available retries for an item.
+//
+// With a Poller it waits for the poller delay before querying, so an idle queue is queried less often.
 func (fs Provider) GetNextMessage() (*message.Message, error) {
+	sleep(fs.Poller.Delay())
+
 	items, err := fs.client.QueryItems(
 		// Collection to get the message from.
 		fs.rootPath,
@@ -88,6 +96,8 @@ func (fs Provider) GetNextMessage() (*message.Message, error) {
 		}
 	}
 
+	fs.Poller.Polled(msg != nil)
+
 	return msg, err
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/wptide/pkg/message"
 	fsClient "github.com/wptide/pkg/wrapper/firestore"
@@ -105,6 +106,30 @@ func TestFirestoreProvider_GetNextMessage(t *testing.T) {
 	}
 }
 
+func TestFirestoreProvider_GetNextMessage_Poller(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	fs, _ := NewWithClient(context.Background(), "mock-client", "empty", &mockClient{})
+	fs.Poller = message.NewPoller(time.Second, 3*time.Second)
+
+	// Back off while the queue is empty.
+	for i := 0; i < 4; i++ {
+		fs.GetNextMessage()
+	}
+
+	// Poll without waiting once messages are flowing again.
+	fs.rootPath = "simple-message"
+	fs.GetNextMessage()
+	fs.GetNextMessage()
+
+	want := []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second, 0}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept = %v, want %v", slept, want)
+	}
+}
+
 func TestFirestoreProvider_DeleteMessage(t *testing.T) {
 	ctx := context.Background()
 	simpleClient, _ := NewWithClient(ctx, "mock-client", "delete-message", &mockClient{})
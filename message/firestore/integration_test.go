@@ -0,0 +1,38 @@
+//go:build integration
+// +build integration
+
+package firestore
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/wptide/pkg/message/messagetest"
+	"github.com/wptide/pkg/testenv"
+	fsClient "github.com/wptide/pkg/wrapper/firestore"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	ctx := context.Background()
+
+	// The emulator is plain gRPC without credentials.
+	client, err := firestore.NewClient(ctx, testenv.ProjectID,
+		option.WithEndpoint(testenv.Firestore(t)),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p, err := NewWithClient(ctx, testenv.ProjectID, "audits", fsClient.Client{Firestore: client, Ctx: ctx})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messagetest.Run(t, p, messagetest.Options{})
+}
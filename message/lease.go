@@ -0,0 +1,179 @@
+package message
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// LeaseProvider is a queue whose received messages are leased to the worker until they are
+// settled: acknowledged once processed, or negatively acknowledged when processing failed.
+// It gives all queues the same lifecycle, whatever their backend calls it.
+type LeaseProvider interface {
+	SendMessage(msg *Message) error
+	GetNextMessage() (*Message, error)
+
+	// Ack removes the message from the queue.
+	Ack(ref *string) error
+	// Nack gives up the message. Requeued messages are delivered again, the others are removed
+	// from the queue, or dead-lettered by queues that can. Queues that can not release messages
+	// deliver requeued messages again once their lease expires.
+	Nack(ref *string, requeue bool) error
+	// ExtendLease keeps the message from being delivered to other workers for the duration.
+	ExtendLease(ref *string, d time.Duration) error
+
+	Close() error
+}
+
+// Releaser is implemented by providers that can deliver a received message again right away.
+type Releaser interface {
+	ReleaseMessage(ref *string) error
+}
+
+// Rejecter is implemented by providers that can dead-letter a received message.
+type Rejecter interface {
+	RejectMessage(ref *string) error
+}
+
+// LeaseExtender is implemented by providers that can extend the lease of a received message.
+type LeaseExtender interface {
+	ExtendLease(ref *string, d time.Duration) error
+}
+
+// NewLeaseProvider returns the provider as a LeaseProvider. Settling a message uses what the
+// provider supports:
+//
+//   - Ack deletes the message,
+//   - Nack with requeue releases the message if the provider is a Releaser, otherwise the
+//     message is delivered again once its lease expires,
+//   - Nack without requeue rejects the message if the provider is a Rejecter, otherwise it
+//     deletes the message,
+//   - ExtendLease returns an error if the provider is not a LeaseExtender.
+//
+// The returned provider is a Provider as well.
+func NewLeaseProvider(p Provider) LeaseProvider {
+	if lp, ok := p.(LeaseProvider); ok {
+		return lp
+	}
+	return leased{p}
+}
+
+// leased adapts a Provider to a LeaseProvider.
+type leased struct {
+	Provider
+}
+
+// Ack implements LeaseProvider.
+func (l leased) Ack(ref *string) error {
+	return l.DeleteMessage(ref)
+}
+
+// Nack implements LeaseProvider. Requeued messages of providers that are not Releasers are
+// left as they are, they are only delivered again once their lease expires.
+func (l leased) Nack(ref *string, requeue bool) error {
+	if requeue {
+		if r, ok := l.Provider.(Releaser); ok {
+			return r.ReleaseMessage(ref)
+		}
+		return nil
+	}
+
+	if r, ok := l.Provider.(Rejecter); ok {
+		return r.RejectMessage(ref)
+	}
+	return l.DeleteMessage(ref)
+}
+
+// ExtendLease implements LeaseProvider.
+func (l leased) ExtendLease(ref *string, d time.Duration) error {
+	if e, ok := l.Provider.(LeaseExtender); ok {
+		return e.ExtendLease(ref, d)
+	}
+	return errors.New("message: the provider can not extend leases")
+}
+
+// Lease is the lease of a received message, carried by the message while it is processed so that
+// it can be settled once the message was processed, e.g. acknowledged once its results were sent.
+// A lease is settled once, settling it again does nothing.
+type Lease struct {
+	provider LeaseProvider
+	ref      *string
+
+	mu       sync.Mutex
+	settled  bool
+	requeued bool
+	done     chan struct{} // Closed once the lease is settled.
+}
+
+// NewLease returns the lease of the received message with the reference.
+func NewLease(p LeaseProvider, ref *string) *Lease {
+	return &Lease{provider: p, ref: ref, done: make(chan struct{})}
+}
+
+// Ack acknowledges the message, see LeaseProvider.Ack.
+func (l *Lease) Ack() error {
+	if !l.settle(false) {
+		return nil
+	}
+	return l.provider.Ack(l.ref)
+}
+
+// Nack gives up the message, see LeaseProvider.Nack.
+func (l *Lease) Nack(requeue bool) error {
+	if !l.settle(requeue) {
+		return nil
+	}
+	return l.provider.Nack(l.ref, requeue)
+}
+
+// Requeued determines if the message was given up to be delivered again.
+func (l *Lease) Requeued() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.requeued
+}
+
+// KeepAlive extends the lease by the duration each time half of it has passed, until the lease
+// is settled or an extension fails, e.g. because the provider can not extend leases.
+func (l *Lease) KeepAlive(d time.Duration) {
+	if l == nil || d <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(d / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.done:
+				return
+			case <-ticker.C:
+			}
+
+			if err := l.provider.ExtendLease(l.ref, d); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// settle marks the lease as settled. It returns false if it was settled before.
+func (l *Lease) settle(requeue bool) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.settled {
+		return false
+	}
+	l.settled = true
+	l.requeued = requeue
+	close(l.done)
+	return true
+}
@@ -0,0 +1,129 @@
+package message
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// settlingProvider records how its messages are settled.
+type settlingProvider struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *settlingProvider) SendMessage(msg *Message) error { return nil }
+func (p *settlingProvider) GetNextMessage() (*Message, error) {
+	return nil, errors.New("could not retrieve message")
+}
+func (p *settlingProvider) Close() error                    { return nil }
+func (p *settlingProvider) DeleteMessage(ref *string) error { return p.call("delete", ref) }
+
+func (p *settlingProvider) settled() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.calls...)
+}
+
+func (p *settlingProvider) call(name string, ref *string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, name+" "+*ref)
+	return nil
+}
+
+// fullProvider supports every way of settling a message.
+type fullProvider struct {
+	settlingProvider
+}
+
+func (p *fullProvider) ReleaseMessage(ref *string) error { return p.call("release", ref) }
+func (p *fullProvider) RejectMessage(ref *string) error  { return p.call("reject", ref) }
+func (p *fullProvider) ExtendLease(ref *string, d time.Duration) error {
+	return p.call("extend "+d.String(), ref)
+}
+
+// leaseProvider is a LeaseProvider already.
+type leaseProvider struct {
+	fullProvider
+}
+
+func (p *leaseProvider) Ack(ref *string) error                { return p.call("ack", ref) }
+func (p *leaseProvider) Nack(ref *string, requeue bool) error { return p.call("nack", ref) }
+
+func TestNewLeaseProvider(t *testing.T) {
+	tests := []struct {
+		name      string
+		provider  Provider
+		wantCalls []string
+		wantErr   bool
+	}{
+		{
+			"Provider",
+			&settlingProvider{},
+			[]string{"delete ref-1", "delete ref-1"},
+			true,
+		},
+		{
+			"Releaser, Rejecter and LeaseExtender",
+			&fullProvider{},
+			[]string{"delete ref-1", "release ref-1", "reject ref-1", "extend 1m0s ref-1"},
+			false,
+		},
+		{
+			"LeaseProvider",
+			&leaseProvider{},
+			[]string{"ack ref-1", "nack ref-1", "nack ref-1", "extend 1m0s ref-1"},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref := "ref-1"
+			lp := NewLeaseProvider(tt.provider)
+			lp.Ack(&ref)
+			lp.Nack(&ref, true)
+			lp.Nack(&ref, false)
+
+			if err := lp.ExtendLease(&ref, time.Minute); (err != nil) != tt.wantErr {
+				t.Errorf("LeaseProvider.ExtendLease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.provider.(interface{ settled() []string }).settled(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Errorf("LeaseProvider settled with %v, want %v", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestLease(t *testing.T) {
+	ref := "ref-1"
+	provider := &fullProvider{}
+	lease := NewLease(NewLeaseProvider(provider), &ref)
+
+	// The lease is extended while the message is processed.
+	lease.KeepAlive(100 * time.Millisecond)
+	time.Sleep(75 * time.Millisecond)
+
+	if err := lease.Nack(true); err != nil {
+		t.Fatalf("Lease.Nack() error = %v", err)
+	}
+	if !lease.Requeued() {
+		t.Errorf("Lease.Requeued() = false, want true")
+	}
+
+	// Settled leases are neither settled again nor extended.
+	lease.Ack()
+	time.Sleep(60 * time.Millisecond)
+
+	want := []string{"extend 100ms ref-1", "release ref-1"}
+	if got := provider.settled(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Lease settled with %v, want %v", got, want)
+	}
+
+	var none *Lease
+	if none.Requeued() || none.Ack() != nil || none.Nack(false) != nil {
+		t.Errorf("Lease of no message should do nothing")
+	}
+}
@@ -0,0 +1,196 @@
+// Package local provides message providers for development, so the full pipeline can run on
+// a single machine without a cloud queue:
+//
+//   - Provider queues messages as JSON files in a directory, so messages can be dropped with
+//     Drop or by copying files into the directory,
+//   - Memory queues messages in a buffered channel, e.g. for tests of a pipeline.
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// processingDir is the directory of the messages being processed, inside the queue directory.
+const processingDir = ".processing"
+
+// sequence makes the names of messages dropped in the same nanosecond unique.
+var sequence uint64
+
+// Provider is a queue of JSON message files in a directory. Files are received in the order
+// of their names, dropped files are named so that they are received in the order they were
+// dropped. Files that don't end in ".json" are ignored.
+//
+// Received files are moved to a ".processing" directory until they are deleted, so that every
+// file is received by one worker only.
+type Provider struct {
+	Dir    string          // Directory of the queue.
+	Poller *message.Poller // (Optional) Waits longer between directory scans while the queue is empty.
+}
+
+// sleep waits between directory scans.
+var sleep = time.Sleep
+
+// New returns a Provider for the directory, which is created if it doesn't exist. Messages
+// that were being processed when the last run stopped are queued again.
+func New(dir string) (*Provider, error) {
+	if err := os.MkdirAll(filepath.Join(dir, processingDir), 0755); err != nil {
+		return nil, err
+	}
+
+	p := &Provider{Dir: dir}
+	names, err := p.files(filepath.Join(dir, processingDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := os.Rename(filepath.Join(dir, processingDir, name), filepath.Join(dir, name)); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Drop writes the message as a JSON file into the queue directory and returns the name of the
+// file, e.g. to queue test messages for a local pipeline.
+func Drop(dir string, msg *message.Message) (string, error) {
+	data, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("%019d-%06d.json", time.Now().UnixNano(), atomic.AddUint64(&sequence, 1)%1000000)
+
+	// Write to a hidden file first, so that the message is never received half written.
+	temp := filepath.Join(dir, "."+name)
+	if err := ioutil.WriteFile(temp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(temp, filepath.Join(dir, name)); err != nil {
+		os.Remove(temp)
+		return "", err
+	}
+	return name, nil
+}
+
+// SendMessage drops the message into the queue directory.
+func (p Provider) SendMessage(msg *message.Message) error {
+	_, err := Drop(p.Dir, msg)
+	return err
+}
+
+// GetNextMessage receives the oldest message file of the directory. The name of the file is
+// the ExternalRef of the message.
+//
+// With a Poller it waits for the poller delay before scanning the directory, so an empty queue
+// is scanned less often.
+func (p Provider) GetNextMessage() (*message.Message, error) {
+	sleep(p.Poller.Delay())
+
+	names, err := p.files(p.Dir)
+	if err != nil {
+		p.Poller.Polled(false)
+		pErr := message.NewProviderError(err.Error())
+		pErr.Type = message.ErrCritcal
+		return nil, pErr
+	}
+
+	for _, name := range names {
+		// Another worker may have received the file first.
+		if err := os.Rename(filepath.Join(p.Dir, name), p.processing(name)); err != nil {
+			continue
+		}
+		p.Poller.Polled(true)
+
+		msg := &message.Message{}
+		data, err := ioutil.ReadFile(p.processing(name))
+		if err == nil {
+			err = json.Unmarshal(data, msg)
+		}
+
+		// Return the file name so that the message can be deleted.
+		ref := name
+		msg.ExternalRef = &ref
+		return msg, err
+	}
+
+	p.Poller.Polled(false)
+	return nil, errors.New("could not retrieve message")
+}
+
+// DeleteMessage removes the file of a received message.
+func (p Provider) DeleteMessage(ref *string) error {
+	name, err := refName(ref)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p.processing(name))
+}
+
+// ReleaseMessage queues a received message again, e.g. when its audit failed.
+func (p Provider) ReleaseMessage(ref *string) error {
+	name, err := refName(ref)
+	if err != nil {
+		return err
+	}
+	return os.Rename(p.processing(name), filepath.Join(p.Dir, name))
+}
+
+// ExtendLease implemented to satisfy message.LeaseExtender. Received files are not delivered
+// again until they are released, so their leases never expire.
+func (p Provider) ExtendLease(ref *string, d time.Duration) error {
+	_, err := refName(ref)
+	return err
+}
+
+// Close implemented to satisfy Provider interface.
+func (p Provider) Close() error {
+	return nil
+}
+
+// files returns the names of the message files of the directory, sorted.
+func (p Provider) files(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || strings.HasPrefix(name, ".") || strings.ToLower(filepath.Ext(name)) != ".json" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// processing returns the path of a received message file.
+func (p Provider) processing(name string) string {
+	return filepath.Join(p.Dir, processingDir, name)
+}
+
+// refName returns the file name of a message reference. References can't point outside the
+// processing directory.
+func refName(ref *string) (string, error) {
+	if ref == nil || *ref == "" {
+		return "", errors.New("local: no message reference")
+	}
+	if name := filepath.Base(*ref); name != *ref || strings.HasPrefix(name, ".") {
+		return "", errors.New("local: invalid message reference " + *ref)
+	}
+	return *ref, nil
+}
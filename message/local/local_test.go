@@ -0,0 +1,179 @@
+package local
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
+)
+
+// tempQueue returns a Provider for a new temporary directory and a func that removes it.
+func tempQueue(t *testing.T) (*Provider, func()) {
+	dir, err := ioutil.TempDir("", "tide-local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return p, func() { os.RemoveAll(dir) }
+}
+
+func TestProvider_Conformance(t *testing.T) {
+	p, remove := tempQueue(t)
+	defer remove()
+
+	messagetest.Run(t, p, messagetest.Options{})
+}
+
+func TestProvider_GetNextMessage(t *testing.T) {
+	p, remove := tempQueue(t)
+	defer remove()
+
+	for _, title := range []string{"First", "Second"} {
+		if _, err := Drop(p.Dir, &message.Message{Title: title}); err != nil {
+			t.Fatalf("Drop() error = %v", err)
+		}
+	}
+	ioutil.WriteFile(filepath.Join(p.Dir, "notes.txt"), []byte("not a message"), 0644)
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"First Dropped", "First", false},
+		{"Second Dropped", "Second", false},
+		{"Empty", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.GetNextMessage()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provider.GetNextMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Title != tt.want {
+				t.Errorf("Provider.GetNextMessage() title = %v, want %v", got.Title, tt.want)
+			}
+			if _, err := os.Stat(p.processing(*got.ExternalRef)); err != nil {
+				t.Errorf("Provider.GetNextMessage() did not move the message to processing: %v", err)
+			}
+		})
+	}
+}
+
+func TestProvider_Invalid(t *testing.T) {
+	p, remove := tempQueue(t)
+	defer remove()
+
+	ioutil.WriteFile(filepath.Join(p.Dir, "broken.json"), []byte("{"), 0644)
+
+	msg, err := p.GetNextMessage()
+	if err == nil {
+		t.Fatal("Provider.GetNextMessage() expected a decoding error")
+	}
+	if msg == nil || msg.ExternalRef == nil || *msg.ExternalRef != "broken.json" {
+		t.Fatalf("Provider.GetNextMessage() = %v, want the reference of the file", msg)
+	}
+	if err := p.DeleteMessage(msg.ExternalRef); err != nil {
+		t.Errorf("Provider.DeleteMessage() error = %v", err)
+	}
+}
+
+func TestProvider_ReleaseMessage(t *testing.T) {
+	p, remove := tempQueue(t)
+	defer remove()
+
+	p.SendMessage(&message.Message{Title: "Retry"})
+	lease := message.NewLeaseProvider(p)
+	msg, err := lease.GetNextMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lease.ExtendLease(msg.ExternalRef, time.Hour); err != nil {
+		t.Errorf("Provider.ExtendLease() error = %v", err)
+	}
+	if err := lease.Nack(msg.ExternalRef, true); err != nil {
+		t.Fatalf("Provider.Nack() error = %v", err)
+	}
+
+	got, err := p.GetNextMessage()
+	if err != nil || got.Title != "Retry" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want the released message", got, err)
+	}
+}
+
+func TestNew_Recover(t *testing.T) {
+	p, remove := tempQueue(t)
+	defer remove()
+
+	p.SendMessage(&message.Message{Title: "Interrupted"})
+	if _, err := p.GetNextMessage(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new run queues the messages that were being processed.
+	restarted, err := New(p.Dir)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	got, err := restarted.GetNextMessage()
+	if err != nil || got.Title != "Interrupted" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want the interrupted message", got, err)
+	}
+}
+
+func TestProvider_Poller(t *testing.T) {
+	p, remove := tempQueue(t)
+	defer remove()
+
+	var slept []time.Duration
+	oldSleep := sleep
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = oldSleep }()
+
+	p.Poller = message.NewPoller(time.Second, 4*time.Second)
+	p.GetNextMessage()
+	p.GetNextMessage()
+	p.SendMessage(&message.Message{Title: "Wake"})
+	p.GetNextMessage()
+	p.GetNextMessage()
+
+	want := []time.Duration{0, time.Second, 2 * time.Second, 0}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept %v, want %v", slept, want)
+	}
+}
+
+func Test_refName(t *testing.T) {
+	ref := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		ref     *string
+		wantErr bool
+	}{
+		{"File", ref("1-1.json"), false},
+		{"Nil", nil, true},
+		{"Empty", ref(""), true},
+		{"Path", ref("../1-1.json"), true},
+		{"Hidden", ref(".1-1.json"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := refName(tt.ref); (err != nil) != tt.wantErr {
+				t.Errorf("refName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package local
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// DefaultMemorySize is the number of messages a Memory queue holds by default.
+const DefaultMemorySize = 100
+
+// Memory is a queue of messages in a buffered channel. Messages are stored encoded, so that
+// received messages never share data with the sent ones.
+type Memory struct {
+	queue chan []byte
+
+	mu       sync.Mutex
+	inFlight map[string][]byte // Received messages that were not deleted yet.
+	next     int
+}
+
+// NewMemory returns a Memory queue that holds up to size messages, or DefaultMemorySize if
+// size is not positive.
+func NewMemory(size int) *Memory {
+	if size <= 0 {
+		size = DefaultMemorySize
+	}
+	return &Memory{
+		queue:    make(chan []byte, size),
+		inFlight: make(map[string][]byte),
+	}
+}
+
+// SendMessage queues the message. It returns an error if the queue is full.
+func (m *Memory) SendMessage(msg *message.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return m.push(data)
+}
+
+// GetNextMessage receives the next message without waiting.
+func (m *Memory) GetNextMessage() (*message.Message, error) {
+	var data []byte
+	select {
+	case data = <-m.queue:
+	default:
+		return nil, errors.New("could not retrieve message")
+	}
+
+	m.mu.Lock()
+	m.next++
+	ref := strconv.Itoa(m.next)
+	m.inFlight[ref] = data
+	m.mu.Unlock()
+
+	msg := &message.Message{}
+	err := json.Unmarshal(data, msg)
+	msg.ExternalRef = &ref
+	return msg, err
+}
+
+// DeleteMessage forgets a received message.
+func (m *Memory) DeleteMessage(ref *string) error {
+	_, err := m.take(ref)
+	return err
+}
+
+// ReleaseMessage queues a received message again, e.g. when its audit failed.
+func (m *Memory) ReleaseMessage(ref *string) error {
+	data, err := m.take(ref)
+	if err != nil {
+		return err
+	}
+	return m.push(data)
+}
+
+// Len returns the number of queued messages, not counting the received ones.
+func (m *Memory) Len() int {
+	return len(m.queue)
+}
+
+// ExtendLease implemented to satisfy message.LeaseExtender. Received messages are not delivered
+// again until they are released, so their leases never expire.
+func (m *Memory) ExtendLease(ref *string, d time.Duration) error {
+	if ref == nil {
+		return errors.New("local: no message reference")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.inFlight[*ref]; !ok {
+		return errors.New("local: unknown message reference " + *ref)
+	}
+	return nil
+}
+
+// Close implemented to satisfy Provider interface.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// push queues an encoded message.
+func (m *Memory) push(data []byte) error {
+	select {
+	case m.queue <- data:
+		return nil
+	default:
+		return errors.New("local: memory queue is full")
+	}
+}
+
+// take removes a received message from the messages in flight.
+func (m *Memory) take(ref *string) ([]byte, error) {
+	if ref == nil {
+		return nil, errors.New("local: no message reference")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.inFlight[*ref]
+	if !ok {
+		return nil, errors.New("local: unknown message reference " + *ref)
+	}
+	delete(m.inFlight, *ref)
+	return data, nil
+}
@@ -0,0 +1,77 @@
+package local
+
+import (
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/message/messagetest"
+)
+
+func TestMemory_Conformance(t *testing.T) {
+	messagetest.Run(t, NewMemory(0), messagetest.Options{})
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory(2)
+
+	tests := []struct {
+		name    string
+		send    string
+		wantErr bool
+		wantLen int
+	}{
+		{"First", "First", false, 1},
+		{"Second", "Second", false, 2},
+		{"Full", "Third", true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := m.SendMessage(&message.Message{Title: tt.send})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Memory.SendMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := m.Len(); got != tt.wantLen {
+				t.Errorf("Memory.Len() = %v, want %v", got, tt.wantLen)
+			}
+		})
+	}
+
+	first, err := m.GetNextMessage()
+	if err != nil || first.Title != "First" {
+		t.Fatalf("Memory.GetNextMessage() = %v, %v, want First", first, err)
+	}
+
+	if err := m.ExtendLease(first.ExternalRef, time.Hour); err != nil {
+		t.Errorf("Memory.ExtendLease() error = %v", err)
+	}
+
+	// Received messages don't share data with the queue.
+	first.Title = "Changed"
+	if err := m.ReleaseMessage(first.ExternalRef); err != nil {
+		t.Fatalf("Memory.ReleaseMessage() error = %v", err)
+	}
+	if err := m.DeleteMessage(first.ExternalRef); err == nil {
+		t.Errorf("Memory.DeleteMessage() expected an error for a released message")
+	}
+
+	for _, want := range []string{"Second", "First"} {
+		got, err := m.GetNextMessage()
+		if err != nil || got.Title != want {
+			t.Fatalf("Memory.GetNextMessage() = %v, %v, want %v", got, err, want)
+		}
+		if err := m.DeleteMessage(got.ExternalRef); err != nil {
+			t.Errorf("Memory.DeleteMessage() error = %v", err)
+		}
+	}
+
+	if _, err := m.GetNextMessage(); err == nil {
+		t.Errorf("Memory.GetNextMessage() expected an error for an empty queue")
+	}
+	if err := m.ExtendLease(first.ExternalRef, time.Hour); err == nil {
+		t.Errorf("Memory.ExtendLease() expected an error for a deleted message")
+	}
+	if err := m.DeleteMessage(nil); err == nil {
+		t.Errorf("Memory.DeleteMessage() expected an error without a reference")
+	}
+}
@@ -12,23 +12,32 @@ type QueueMessage struct {
 
 // Message represents a task to read from or send to a queue.
 type Message struct {
-	ResponseAPIEndpoint string  `json:"response_api_endpoint"`
-	PayloadType         string  `json:"payload_type"`
-	Title               string  `json:"title"`
-	Content             string  `json:"content"`
-	Slug                string  `json:"slug"`
-	ProjectType         string  `json:"project_type,omitempty"`
-	SourceURL           string  `json:"source_url"`
-	SourceType          string  `json:"source_type"`
-	RequestClient       string  `json:"request_client"`
-	Force               bool    `json:"force"`
-	Visibility          string  `json:"visibility"`
-	ExternalRef         *string `json:"external_ref,omitempty"`
+	ResponseAPIEndpoint string    `json:"response_api_endpoint"`
+	PayloadType         string    `json:"payload_type"`
+	Title               string    `json:"title"`
+	Content             string    `json:"content"`
+	Slug                string    `json:"slug"`
+	ProjectType         string    `json:"project_type,omitempty"`
+	SourceURL           string    `json:"source_url"`
+	SourceType          string    `json:"source_type"`
+	RequestClient       string    `json:"request_client"`
+	Force               bool      `json:"force"`
+	Visibility          string    `json:"visibility"`
+	Locale              string    `json:"locale,omitempty"` // (Optional) Locale of translated report messages, e.g. "de_DE".
+	ExternalRef         *string   `json:"external_ref,omitempty"`
+	Attempts            []Attempt `json:"attempts,omitempty"`       // Failed attempts to audit the message, see process.Retry.
+	Lease               *Lease    `json:"-" bson:"-" firestore:"-"` // Lease of a received message, settled once the message was processed. Not sent to queues.
 	// @todo: Legacy fields. Need to deprecate over time.
 	Standards []string `json:"standards,omitempty"`
 	Audits    []*Audit `json:"audits,omitempty"`
 }
 
+// Attempt is a failed attempt to audit a message, kept when the message is retried.
+type Attempt struct {
+	Time   int64    `json:"time"`   // Unix time of the failure.
+	Errors []string `json:"errors"` // Errors of the attempt, e.g. "PHPCS Error: could not upload report".
+}
+
 // Audit describes an audit type with its options.
 type Audit struct {
 	Type    string       `json:"type"`
@@ -37,12 +46,19 @@ type Audit struct {
 
 // AuditOption describes specific options for an Audit.
 type AuditOption struct {
-	Standard         string `json:"standard,omitempty"`
-	Report           string `json:"report,omitempty"`
-	Encoding         string `json:"encoding,omitempty"`
-	RuntimeSet       string `json:"runtime-set,omitempty"`
-	Ignore           string `json:"ignore,omitempty"`
-	StandardOverride string `json:"standard-override,omitempty"`
+	Standard         string            `json:"standard,omitempty"`
+	Report           string            `json:"report,omitempty"` // (Optional) Comma separated report formats, e.g. "json,checkstyle,junit". Formats other than "json" are uploaded along with the raw report.
+	Encoding         string            `json:"encoding,omitempty"`
+	RuntimeSet       string            `json:"runtime-set,omitempty"`
+	Ignore           string            `json:"ignore,omitempty"`  // (Optional) Comma separated patterns of the paths not to audit, e.g. "*/vendor/*,*/node_modules/*".
+	Include          string            `json:"include,omitempty"` // (Optional) Comma separated patterns of the paths to audit, e.g. "*/src/*". Other paths are not audited.
+	Sniffs           string            `json:"sniffs,omitempty"`  // (Optional) Comma separated sniffs to restrict the audit to, e.g. "WordPress.Security.EscapeOutput" or "WordPress.Security.*".
+	Exclude          string            `json:"exclude,omitempty"` // (Optional) Comma separated sniffs to exclude from the audit, e.g. "Squiz.Commenting.*".
+	StandardOverride string            `json:"standard-override,omitempty"`
+	Versions         map[string]string `json:"versions,omitempty"`     // Pinned component versions, e.g. {"wpcs": "3.0.x"}.
+	Timeout          int               `json:"timeout,omitempty"`      // (Optional) Seconds the audit may run. Overrides the timeout of the worker.
+	Parallel         int               `json:"parallel,omitempty"`     // (Optional) Number of PHPCS processes, up to the CPUs of the worker. Overrides the parallelism of the worker.
+	MemoryLimit      string            `json:"memory-limit,omitempty"` // (Optional) PHP memory limit of PHPCS, e.g. "2G", up to the memory limit of the worker.
 }
 
 // Provider is an interface for creating new providers. E.g. firestore, mongo, sqs.
@@ -0,0 +1,112 @@
+// Package messagetest is a conformance suite for message providers. Every message.Provider
+// runs the same suite, so that providers can't ship with subtly different semantics, e.g.
+// for the fields that survive a round trip or messages that are delivered again.
+package messagetest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// DefaultTimeout is the default time to wait for a sent message to be received.
+const DefaultTimeout = 30 * time.Second
+
+// Options configure the conformance suite.
+type Options struct {
+	Timeout time.Duration // (Optional) Time to wait for a sent message, e.g. for delayed queues. Defaults to DefaultTimeout.
+}
+
+// Run runs the conformance suite against the provider, which must start with an empty queue.
+func Run(t *testing.T, provider message.Provider, options Options) {
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	sent := &message.Message{
+		ResponseAPIEndpoint: "https://example.com/api/audits",
+		PayloadType:         "tide",
+		Title:               fmt.Sprintf("Conformance %d", time.Now().UnixNano()),
+		Content:             "Conformance test message",
+		Slug:                "conformance",
+		ProjectType:         "plugin",
+		SourceURL:           "https://downloads.wordpress.org/plugin/conformance.zip",
+		SourceType:          "zip",
+		RequestClient:       "wporg",
+		Force:               true,
+		Visibility:          "public",
+		Locale:              "de_DE",
+		Audits: []*message.Audit{
+			{Type: "phpcs", Options: &message.AuditOption{Standard: "phpcompatibility", Report: "json"}},
+			{Type: "lighthouse"},
+		},
+	}
+
+	// receive polls for the next message until the timeout.
+	receive := func(timeout time.Duration) *message.Message {
+		deadline := time.Now().Add(timeout)
+		for {
+			msg, err := provider.GetNextMessage()
+			if err == nil && msg != nil {
+				return msg
+			}
+			if time.Now().After(deadline) {
+				return nil
+			}
+			time.Sleep(500 * time.Millisecond)
+		}
+	}
+
+	var received *message.Message
+
+	t.Run("Round Trip", func(t *testing.T) {
+		if err := provider.SendMessage(sent); err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+
+		received = receive(timeout)
+		if received == nil {
+			t.Fatalf("GetNextMessage() did not receive the message within %v", timeout)
+		}
+		if received.ExternalRef == nil {
+			t.Errorf("GetNextMessage() has no reference to delete the message")
+		}
+
+		got := *received
+		got.ExternalRef = nil
+		if !reflect.DeepEqual(&got, sent) {
+			t.Errorf("GetNextMessage() = %+v, want %+v", got, *sent)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if received == nil {
+			t.Skip("no message was received")
+		}
+		if err := provider.DeleteMessage(received.ExternalRef); err != nil {
+			t.Fatalf("DeleteMessage() error = %v", err)
+		}
+
+		// A deleted message is not delivered again.
+		if msg := receive(2 * time.Second); msg != nil && msg.Title == sent.Title {
+			t.Errorf("GetNextMessage() delivered the deleted message again")
+		}
+	})
+
+	t.Run("Empty Queue", func(t *testing.T) {
+		// Providers may return an error or nothing, but never a message.
+		if msg, _ := provider.GetNextMessage(); msg != nil {
+			t.Errorf("GetNextMessage() = %+v for an empty queue", *msg)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		if err := provider.Close(); err != nil {
+			t.Errorf("Close() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package messagetest
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+)
+
+// memoryProvider is a queue that keeps the encoded messages in memory.
+type memoryProvider struct {
+	queue    [][]byte
+	inFlight map[string][]byte
+	next     int
+}
+
+func (m *memoryProvider) SendMessage(msg *message.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	m.queue = append(m.queue, data)
+	return nil
+}
+
+func (m *memoryProvider) GetNextMessage() (*message.Message, error) {
+	if len(m.queue) == 0 {
+		return nil, errors.New("could not retrieve message")
+	}
+
+	data := m.queue[0]
+	m.queue = m.queue[1:]
+
+	var msg message.Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+
+	m.next++
+	ref := strconv.Itoa(m.next)
+	if m.inFlight == nil {
+		m.inFlight = make(map[string][]byte)
+	}
+	m.inFlight[ref] = data
+	msg.ExternalRef = &ref
+
+	return &msg, nil
+}
+
+func (m *memoryProvider) DeleteMessage(ref *string) error {
+	if ref == nil {
+		return errors.New("no reference")
+	}
+	if _, ok := m.inFlight[*ref]; !ok {
+		return errors.New("unknown reference " + *ref)
+	}
+	delete(m.inFlight, *ref)
+	return nil
+}
+
+func (m *memoryProvider) Close() error {
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	Run(t, &memoryProvider{}, Options{})
+}
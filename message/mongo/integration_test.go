@@ -0,0 +1,23 @@
+//go:build integration
+// +build integration
+
+package mongo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/wptide/pkg/message/messagetest"
+	"github.com/wptide/pkg/testenv"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	ctx := context.Background()
+
+	p, err := New(ctx, "", "", testenv.MongoDB(t), "tide", "audits", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messagetest.Run(t, p, messagetest.Options{})
+}
@@ -27,8 +27,12 @@ type Provider struct {
 	client     wrapper.Client
 	database   string
 	collection string
+	Poller     *message.Poller // (Optional) Waits longer between polls while the queue is idle.
 }
 
+// sleep waits between polls.
+var sleep = time.Sleep
+
 // SendMessage sends a message to MongoDB.
 func (m Provider) SendMessage(msg *message.Message) error {
 	collection := m.client.Database(m.database).Collection(m.collection)
@@ -37,7 +41,11 @@ func (m Provider) SendMessage(msg *message.Message) error {
 }
 
 // GetNextMessage gets the next message from MongoDB.
+//
+// With a Poller it waits for the poller delay before querying, so an idle queue is queried less often.
 func (m Provider) GetNextMessage() (*message.Message, error) {
+	sleep(m.Poller.Delay())
+
 	collection := m.client.Database(m.database).Collection(m.collection)
 
 	// Query.
@@ -53,6 +61,7 @@ func (m Provider) GetNextMessage() (*message.Message, error) {
 
 	result := collection.FindOne(m.ctx, filter, sort)
 	qm, err := ResultToQueueMessage(result)
+	m.Poller.Polled(err == nil)
 	if err != nil {
 		return nil, err
 
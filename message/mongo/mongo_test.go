@@ -7,6 +7,7 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/mongodb/mongo-go-driver/mongo"
 	"github.com/wptide/pkg/message"
@@ -164,6 +165,32 @@ func TestMongoProvider_GetNextMessage(t *testing.T) {
 	}
 }
 
+func TestMongoProvider_GetNextMessage_Poller(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	poller := message.NewPoller(time.Second, 3*time.Second)
+
+	// Back off while the queue is empty.
+	empty, _ := NewWithClient(context.Background(), "test", "test-no-records", &MockClient{"test-no-records"})
+	empty.Poller = poller
+	for i := 0; i < 4; i++ {
+		empty.GetNextMessage()
+	}
+
+	// Poll without waiting once messages are flowing again.
+	valid, _ := NewWithClient(context.Background(), "test", "test-valid-message", &MockClient{"test-valid-message"})
+	valid.Poller = poller
+	valid.GetNextMessage()
+	valid.GetNextMessage()
+
+	want := []time.Duration{0, time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second, 0}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept = %v, want %v", slept, want)
+	}
+}
+
 func TestMongoProvider_DeleteMessage(t *testing.T) {
 	type fields struct {
 		ctx        context.Context
@@ -0,0 +1,99 @@
+package message
+
+import (
+	"sync"
+	"time"
+)
+
+// Default polling bounds.
+const (
+	DefaultPollMin    = time.Second
+	DefaultPollMax    = time.Minute
+	DefaultPollFactor = 2.0
+)
+
+// Poller adapts how long a provider waits before looking for the next message.
+// While messages are flowing the provider polls without waiting. After an empty poll it
+// waits Min and every further empty poll multiplies the wait by Factor, up to Max.
+//
+// A nil Poller never waits, so providers without one keep polling as fast as they are called.
+// A Poller is safe for concurrent use and can be shared by the copies of a provider.
+type Poller struct {
+	Min    time.Duration // (Optional) Wait after the first empty poll. Defaults to DefaultPollMin.
+	Max    time.Duration // (Optional) Longest wait. Defaults to DefaultPollMax.
+	Factor float64       // (Optional) Growth of the wait after each empty poll. Defaults to DefaultPollFactor.
+
+	mu    sync.Mutex
+	delay time.Duration
+}
+
+// Waiter is implemented by providers whose GetNextMessage can wait for a message to arrive, e.g.
+// with a long poll or a Poller.
+type Waiter interface {
+	// ReceiveWait returns the longest GetNextMessage waits while there is no message, or zero if
+	// it returns at once.
+	ReceiveWait() time.Duration
+}
+
+// NewPoller returns a Poller with the given bounds.
+func NewPoller(min, max time.Duration) *Poller {
+	return &Poller{
+		Min: min,
+		Max: max,
+	}
+}
+
+// Delay returns how long the provider should wait before the next poll.
+func (p *Poller) Delay() time.Duration {
+	if p == nil {
+		return 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.delay
+}
+
+// MaxDelay returns the longest the provider waits before a poll. A nil Poller never waits.
+func (p *Poller) MaxDelay() time.Duration {
+	if p == nil {
+		return 0
+	}
+	if p.Max <= 0 {
+		return DefaultPollMax
+	}
+	return p.Max
+}
+
+// Polled records the outcome of a poll: received is true if a message was returned.
+func (p *Poller) Polled(received bool) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if received {
+		p.delay = 0
+		return
+	}
+
+	min, max, factor := p.Min, p.MaxDelay(), p.Factor
+	if min <= 0 {
+		min = DefaultPollMin
+	}
+	if factor <= 1 {
+		factor = DefaultPollFactor
+	}
+
+	if p.delay == 0 {
+		p.delay = min
+	} else {
+		p.delay = time.Duration(float64(p.delay) * factor)
+	}
+
+	if p.delay > max {
+		p.delay = max
+	}
+}
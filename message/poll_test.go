@@ -0,0 +1,78 @@
+package message
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPoller_Polled(t *testing.T) {
+	tests := []struct {
+		name   string
+		poller *Poller
+		polls  []bool
+		want   []time.Duration
+	}{
+		{
+			"Defaults",
+			&Poller{},
+			[]bool{false, false, false},
+			[]time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			"Bounded",
+			NewPoller(10*time.Second, 30*time.Second),
+			[]bool{false, false, false, false},
+			[]time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second},
+		},
+		{
+			"Factor",
+			&Poller{Min: time.Second, Max: time.Minute, Factor: 3},
+			[]bool{false, false, false},
+			[]time.Duration{time.Second, 3 * time.Second, 9 * time.Second},
+		},
+		{
+			"Messages Flowing",
+			&Poller{},
+			[]bool{false, false, true, false},
+			[]time.Duration{time.Second, 2 * time.Second, 0, time.Second},
+		},
+		{
+			"Nil Poller",
+			nil,
+			[]bool{false, false},
+			[]time.Duration{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.poller.Delay(); got != 0 {
+				t.Errorf("Poller.Delay() = %v before polling, want 0", got)
+			}
+			for i, received := range tt.polls {
+				tt.poller.Polled(received)
+				if got := tt.poller.Delay(); got != tt.want[i] {
+					t.Errorf("Poller.Delay() after poll %d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPoller_MaxDelay(t *testing.T) {
+	tests := []struct {
+		name   string
+		poller *Poller
+		want   time.Duration
+	}{
+		{"Default", &Poller{}, DefaultPollMax},
+		{"Max", NewPoller(time.Second, 30*time.Second), 30 * time.Second},
+		{"Nil Poller", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.poller.MaxDelay(); got != tt.want {
+				t.Errorf("Poller.MaxDelay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
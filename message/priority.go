@@ -0,0 +1,219 @@
+package message
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriorityQueue is a queue of a PriorityProvider.
+type PriorityQueue struct {
+	Name     string   // Name of the queue, e.g. "high". Can't contain ":".
+	Provider Provider // Queue to receive messages from.
+	Weight   int      // (Optional) Share of the polls that try this queue first. Defaults to 1.
+}
+
+// PriorityProvider multiplexes several queues, e.g. "high" for audits requested by users and
+// "low" for bulk re-audits, so that interactive requests aren't starved behind bulk jobs.
+//
+// Each GetNextMessage tries the queues in turn until one returns a message. Which queue is tried
+// first follows the weights of the queues: with weights 3 and 1, three out of four polls try
+// the first queue first. The other queues are then tried in their order, so no poll is wasted
+// on an empty queue while another one has messages, and no queue is starved while the others
+// are busy.
+//
+// Queues are polled one after another, so a queue that waits for messages would hold up the
+// others: a high priority message would wait behind the long poll of a low priority queue.
+// Providers that are a Waiter must not wait and have no Poller, e.g. SQS without a WaitTime,
+// or AMQP, Redis and Pub/Sub with a negative Timeout, Block or PullTimeout. The Poller of the
+// consumer backs off instead.
+//
+// The references of received messages are prefixed with the name of their queue, e.g.
+// "high:<receipt handle>".
+type PriorityProvider struct {
+	Queues []PriorityQueue           // Queues from the highest priority to the lowest.
+	Route  func(msg *Message) string // (Optional) Name of the queue a message is sent to. Defaults to the first queue.
+
+	mu      sync.Mutex
+	current []int // Smooth weighted round-robin state, by queue.
+}
+
+// NewPriorityProvider returns a PriorityProvider for the queues, from the highest priority to
+// the lowest. Queues whose provider waits for messages are rejected, see PriorityProvider.
+func NewPriorityProvider(queues ...PriorityQueue) (*PriorityProvider, error) {
+	if len(queues) == 0 {
+		return nil, errors.New("priority provider requires a queue")
+	}
+
+	names := make(map[string]bool)
+	for _, q := range queues {
+		if q.Name == "" || strings.Contains(q.Name, ":") {
+			return nil, errors.New("invalid priority queue name: " + q.Name)
+		}
+		if names[q.Name] {
+			return nil, errors.New("duplicate priority queue: " + q.Name)
+		}
+		if q.Provider == nil {
+			return nil, errors.New("priority queue has no provider: " + q.Name)
+		}
+		if w, ok := q.Provider.(Waiter); ok && w.ReceiveWait() > 0 {
+			return nil, errors.New("priority queue waits for messages: " + q.Name)
+		}
+		names[q.Name] = true
+	}
+
+	return &PriorityProvider{Queues: queues}, nil
+}
+
+// SendMessage sends the message to the queue chosen by Route.
+func (p *PriorityProvider) SendMessage(msg *Message) error {
+	name := ""
+	if p.Route != nil {
+		name = p.Route(msg)
+	}
+	if name == "" && len(p.Queues) != 0 {
+		name = p.Queues[0].Name
+	}
+
+	q, err := p.queue(name)
+	if err != nil {
+		return err
+	}
+	return q.Provider.SendMessage(msg)
+}
+
+// GetNextMessage receives the next message of the queues, see PriorityProvider. Provider errors
+// of a queue don't keep the other queues from being tried, they are returned if no queue
+// returned a message.
+func (p *PriorityProvider) GetNextMessage() (*Message, error) {
+	var pErr error
+
+	for _, i := range p.order() {
+		q := p.Queues[i]
+		msg, err := q.Provider.GetNextMessage()
+		if msg == nil {
+			if _, ok := err.(*ProviderError); ok && pErr == nil {
+				pErr = err
+			}
+			continue
+		}
+
+		if msg.ExternalRef != nil {
+			ref := q.Name + ":" + *msg.ExternalRef
+			msg.ExternalRef = &ref
+		}
+		return msg, err
+	}
+
+	if pErr != nil {
+		return nil, pErr
+	}
+	return nil, errors.New("could not retrieve message")
+}
+
+// DeleteMessage deletes the message from its queue.
+func (p *PriorityProvider) DeleteMessage(ref *string) error {
+	return p.Ack(ref)
+}
+
+// Ack implements LeaseProvider.
+func (p *PriorityProvider) Ack(ref *string) error {
+	q, queueRef, err := p.route(ref)
+	if err != nil {
+		return err
+	}
+	return q.Ack(queueRef)
+}
+
+// Nack implements LeaseProvider.
+func (p *PriorityProvider) Nack(ref *string, requeue bool) error {
+	q, queueRef, err := p.route(ref)
+	if err != nil {
+		return err
+	}
+	return q.Nack(queueRef, requeue)
+}
+
+// ExtendLease implements LeaseProvider.
+func (p *PriorityProvider) ExtendLease(ref *string, d time.Duration) error {
+	q, queueRef, err := p.route(ref)
+	if err != nil {
+		return err
+	}
+	return q.ExtendLease(queueRef, d)
+}
+
+// Close closes all queues and returns the first error.
+func (p *PriorityProvider) Close() error {
+	var err error
+	for _, q := range p.Queues {
+		if cErr := q.Provider.Close(); err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
+
+// order returns the indexes of the queues in the order to try them: the queue picked by smooth
+// weighted round-robin first, then the others from the highest priority to the lowest.
+func (p *PriorityProvider) order() []int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.current) != len(p.Queues) {
+		p.current = make([]int, len(p.Queues))
+	}
+
+	first, total := 0, 0
+	for i, q := range p.Queues {
+		weight := q.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		p.current[i] += weight
+		total += weight
+		if p.current[i] > p.current[first] {
+			first = i
+		}
+	}
+	p.current[first] -= total
+
+	order := []int{first}
+	for i := range p.Queues {
+		if i != first {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// queue returns the queue with the name.
+func (p *PriorityProvider) queue(name string) (*PriorityQueue, error) {
+	for i := range p.Queues {
+		if p.Queues[i].Name == name {
+			return &p.Queues[i], nil
+		}
+	}
+	return nil, errors.New("unknown priority queue: " + name)
+}
+
+// route returns the queue of a message reference, as a LeaseProvider, and the reference of the
+// message in that queue.
+func (p *PriorityProvider) route(ref *string) (LeaseProvider, *string, error) {
+	if ref == nil {
+		return nil, nil, errors.New("no message reference")
+	}
+
+	parts := strings.SplitN(*ref, ":", 2)
+	if len(parts) != 2 {
+		return nil, nil, errors.New("invalid priority message reference: " + *ref)
+	}
+	q, err := p.queue(parts[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	queueRef := parts[1]
+	return NewLeaseProvider(q.Provider), &queueRef, nil
+}
@@ -0,0 +1,202 @@
+package message
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sliceProvider is a queue of messages in a slice.
+type sliceProvider struct {
+	name     string
+	messages []*Message
+	deleted  []string
+	err      error
+	closed   bool
+}
+
+func (s *sliceProvider) SendMessage(msg *Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func (s *sliceProvider) GetNextMessage() (*Message, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if len(s.messages) == 0 {
+		return nil, errors.New("could not retrieve message")
+	}
+	msg := s.messages[0]
+	s.messages = s.messages[1:]
+	return msg, nil
+}
+
+func (s *sliceProvider) DeleteMessage(ref *string) error {
+	s.deleted = append(s.deleted, *ref)
+	return nil
+}
+
+func (s *sliceProvider) Close() error {
+	s.closed = true
+	return nil
+}
+
+// fill queues n messages titled by the name of the queue.
+func (s *sliceProvider) fill(n int) *sliceProvider {
+	for i := 0; i < n; i++ {
+		ref := strconv.Itoa(i)
+		s.messages = append(s.messages, &Message{Title: s.name, ExternalRef: &ref})
+	}
+	return s
+}
+
+// waitingProvider is a queue that waits for messages to arrive.
+type waitingProvider struct {
+	sliceProvider
+	wait time.Duration
+}
+
+func (w *waitingProvider) ReceiveWait() time.Duration { return w.wait }
+
+func TestNewPriorityProvider(t *testing.T) {
+	queue := &sliceProvider{}
+
+	tests := []struct {
+		name    string
+		queues  []PriorityQueue
+		wantErr bool
+	}{
+		{"Queues", []PriorityQueue{{Name: "high", Provider: queue}, {Name: "low", Provider: queue}}, false},
+		{"No Queues", nil, true},
+		{"No Name", []PriorityQueue{{Provider: queue}}, true},
+		{"Invalid Name", []PriorityQueue{{Name: "high:1", Provider: queue}}, true},
+		{"Duplicate", []PriorityQueue{{Name: "high", Provider: queue}, {Name: "high", Provider: queue}}, true},
+		{"No Provider", []PriorityQueue{{Name: "high"}}, true},
+		{"Not Waiting", []PriorityQueue{{Name: "high", Provider: &waitingProvider{}}}, false},
+		{"Waiting", []PriorityQueue{{Name: "high", Provider: queue}, {Name: "low", Provider: &waitingProvider{wait: time.Second}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPriorityProvider(tt.queues...); (err != nil) != tt.wantErr {
+				t.Errorf("NewPriorityProvider() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPriorityProvider_GetNextMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		high, low int // Queued messages.
+		weight    int // Weight of the high queue.
+		polls     int
+		want      string
+		wantErr   bool
+	}{
+		{"Weighted", 10, 10, 3, 8, "high high low high high high low high", false},
+		{"Equal Weights", 10, 10, 0, 4, "high low high low", false},
+		{"High Empty", 0, 10, 3, 3, "low low low", false},
+		{"Low Empty", 10, 0, 1, 3, "high high high", false},
+		{"Drained", 1, 1, 1, 3, "high low", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, _ := NewPriorityProvider(
+				PriorityQueue{Name: "high", Provider: (&sliceProvider{name: "high"}).fill(tt.high), Weight: tt.weight},
+				PriorityQueue{Name: "low", Provider: (&sliceProvider{name: "low"}).fill(tt.low)},
+			)
+
+			var got []string
+			var err error
+			for i := 0; i < tt.polls; i++ {
+				var msg *Message
+				if msg, err = p.GetNextMessage(); msg != nil {
+					got = append(got, msg.Title)
+				}
+			}
+
+			if joined := strings.Join(got, " "); joined != tt.want {
+				t.Errorf("PriorityProvider.GetNextMessage() = %v, want %v", joined, tt.want)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PriorityProvider.GetNextMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPriorityProvider_ProviderError(t *testing.T) {
+	pErr := NewProviderError("throttled")
+	high := &sliceProvider{name: "high", err: pErr}
+	low := (&sliceProvider{name: "low"}).fill(1)
+	p, _ := NewPriorityProvider(PriorityQueue{Name: "high", Provider: high}, PriorityQueue{Name: "low", Provider: low})
+
+	// Failing queues don't keep the others from being received.
+	if msg, err := p.GetNextMessage(); err != nil || msg.Title != "low" {
+		t.Errorf("PriorityProvider.GetNextMessage() = %v, %v, want the low message", msg, err)
+	}
+	if _, err := p.GetNextMessage(); err != pErr {
+		t.Errorf("PriorityProvider.GetNextMessage() error = %v, want %v", err, pErr)
+	}
+}
+
+func TestPriorityProvider_Settle(t *testing.T) {
+	high := (&sliceProvider{name: "high"}).fill(1)
+	low := (&sliceProvider{name: "low"}).fill(1)
+	p, _ := NewPriorityProvider(PriorityQueue{Name: "high", Provider: high}, PriorityQueue{Name: "low", Provider: low})
+
+	for i := 0; i < 2; i++ {
+		msg, _ := p.GetNextMessage()
+		if want := msg.Title + ":0"; *msg.ExternalRef != want {
+			t.Errorf("PriorityProvider.GetNextMessage() reference = %v, want %v", *msg.ExternalRef, want)
+		}
+		if err := p.DeleteMessage(msg.ExternalRef); err != nil {
+			t.Errorf("PriorityProvider.DeleteMessage() error = %v", err)
+		}
+	}
+
+	if !reflect.DeepEqual(high.deleted, []string{"0"}) || !reflect.DeepEqual(low.deleted, []string{"0"}) {
+		t.Errorf("PriorityProvider.DeleteMessage() deleted %v and %v, want [0] of each queue", high.deleted, low.deleted)
+	}
+
+	for _, ref := range []string{"0", "medium:0"} {
+		if err := p.Nack(&ref, true); err == nil {
+			t.Errorf("PriorityProvider.Nack(%v) error = nil, want error", ref)
+		}
+	}
+
+	p.Close()
+	if !high.closed || !low.closed {
+		t.Errorf("PriorityProvider.Close() did not close all queues")
+	}
+}
+
+func TestPriorityProvider_SendMessage(t *testing.T) {
+	high := &sliceProvider{}
+	low := &sliceProvider{}
+	p, _ := NewPriorityProvider(PriorityQueue{Name: "high", Provider: high}, PriorityQueue{Name: "low", Provider: low})
+
+	p.SendMessage(&Message{Title: "Default"})
+
+	p.Route = func(msg *Message) string {
+		if msg.Force {
+			return "low"
+		}
+		return "high"
+	}
+	p.SendMessage(&Message{Title: "User"})
+	p.SendMessage(&Message{Title: "Bulk", Force: true})
+
+	if len(high.messages) != 2 || len(low.messages) != 1 || low.messages[0].Title != "Bulk" {
+		t.Errorf("PriorityProvider.SendMessage() sent %v high and %v low messages, want 2 and 1", len(high.messages), len(low.messages))
+	}
+
+	p.Route = func(msg *Message) string { return "medium" }
+	if err := p.SendMessage(&Message{}); err == nil {
+		t.Errorf("PriorityProvider.SendMessage() error = nil for an unknown queue")
+	}
+}
@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"context"
+	"os"
+
+	api "cloud.google.com/go/pubsub/apiv1"
+	"google.golang.org/api/option"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc"
+)
+
+// EmulatorHostEnv is the environment variable with the address of the Pub/Sub emulator, e.g.
+// "localhost:8085". The emulator is used instead of Google Cloud when it is set.
+const EmulatorHostEnv = "PUBSUB_EMULATOR_HOST"
+
+// apiClient is a Client for the Pub/Sub API.
+type apiClient struct {
+	publisher  *api.PublisherClient
+	subscriber *api.SubscriberClient
+}
+
+// NewClient returns a Client for the Pub/Sub API, or for the emulator if EmulatorHostEnv is
+// set. Without options the default credentials are used.
+func NewClient(ctx context.Context, opts ...option.ClientOption) (Client, error) {
+	if host := os.Getenv(EmulatorHostEnv); host != "" {
+		opts = append([]option.ClientOption{
+			option.WithEndpoint(host),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithInsecure()),
+		}, opts...)
+	}
+
+	publisher, err := api.NewPublisherClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	subscriber, err := api.NewSubscriberClient(ctx, opts...)
+	if err != nil {
+		publisher.Close()
+		return nil, err
+	}
+
+	return apiClient{publisher: publisher, subscriber: subscriber}, nil
+}
+
+// Publish implements Client.
+func (c apiClient) Publish(ctx context.Context, topic string, messages []*pb.PubsubMessage) ([]string, error) {
+	resp, err := c.publisher.Publish(ctx, &pb.PublishRequest{Topic: topic, Messages: messages})
+	if err != nil {
+		return nil, err
+	}
+	return resp.MessageIds, nil
+}
+
+// Pull implements Client.
+func (c apiClient) Pull(ctx context.Context, subscription string, max int, returnImmediately bool) ([]*pb.ReceivedMessage, error) {
+	resp, err := c.subscriber.Pull(ctx, &pb.PullRequest{
+		Subscription:      subscription,
+		MaxMessages:       int32(max),
+		ReturnImmediately: returnImmediately,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.ReceivedMessages, nil
+}
+
+// Acknowledge implements Client.
+func (c apiClient) Acknowledge(ctx context.Context, subscription string, ackIDs []string) error {
+	return c.subscriber.Acknowledge(ctx, &pb.AcknowledgeRequest{Subscription: subscription, AckIds: ackIDs})
+}
+
+// ModifyAckDeadline implements Client.
+func (c apiClient) ModifyAckDeadline(ctx context.Context, subscription string, ackIDs []string, seconds int32) error {
+	return c.subscriber.ModifyAckDeadline(ctx, &pb.ModifyAckDeadlineRequest{
+		Subscription:       subscription,
+		AckIds:             ackIDs,
+		AckDeadlineSeconds: seconds,
+	})
+}
+
+// Close implements Client.
+func (c apiClient) Close() error {
+	err := c.subscriber.Close()
+	if pErr := c.publisher.Close(); err == nil {
+		err = pErr
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+)
+
+// newTicker ticks when the ack deadline of a message is extended.
+var newTicker = time.NewTicker
+
+// ExtendAckDeadline keeps the message with the ack ID from being delivered to other workers
+// until stop is called, e.g. while a long PHPCS audit of the message runs. The ack deadline is
+// extended each time half of it has passed.
+//
+// Extensions end by themselves when Pub/Sub refuses them, e.g. once the message was
+// acknowledged.
+func (p Provider) ExtendAckDeadline(ref *string) (stop func()) {
+	if ref == nil {
+		return func() {}
+	}
+	ackID := *ref
+	deadline := p.ackDeadline()
+
+	return p.extensions.Start(ackID, newTicker(deadline/2), func() error {
+		return p.modifyAckDeadline(ackID, deadline)
+	})
+}
+
+// autoExtend extends the ack deadline of a pulled message if the provider extends messages
+// automatically.
+func (p Provider) autoExtend(ref *string) {
+	if p.AutoExtend && p.extensions != nil {
+		p.ExtendAckDeadline(ref)
+	}
+}
+
+// stopExtending stops extending the ack deadline of the message.
+func (p Provider) stopExtending(ref *string) {
+	if ref == nil {
+		return
+	}
+	p.extensions.Stop(*ref)
+}
+
+// ReleaseMessage stops extending the message and makes Pub/Sub deliver it again right away,
+// e.g. when its audit failed and should be retried without waiting for the ack deadline.
+func (p Provider) ReleaseMessage(ref *string) error {
+	if ref == nil {
+		return nil
+	}
+	p.stopExtending(ref)
+
+	return p.modifyAckDeadline(*ref, 0)
+}
+
+// ExtendLease sets the ack deadline of the message with the ack ID to the duration from now,
+// up to the 10 minutes that Pub/Sub allows.
+func (p Provider) ExtendLease(ref *string, d time.Duration) error {
+	if ref == nil {
+		return errors.New("pubsub: no ack ID")
+	}
+	if d > maxAckDeadline {
+		d = maxAckDeadline
+	}
+
+	return p.modifyAckDeadline(*ref, d)
+}
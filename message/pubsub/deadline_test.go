@@ -0,0 +1,167 @@
+package pubsub
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// fastTicker extends ack deadlines every millisecond until restored.
+func fastTicker() (restore func()) {
+	newTicker = func(time.Duration) *time.Ticker { return time.NewTicker(time.Millisecond) }
+	return func() { newTicker = time.NewTicker }
+}
+
+// waitForDeadlines waits until the ack deadline of the message was set at least n times.
+func waitForDeadlines(t *testing.T, client *mockClient, ackID string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(client.ackDeadlines(ackID)) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("message %v was extended %v times, want %v", ackID, len(client.ackDeadlines(ackID)), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// extending returns the number of messages the provider extends.
+func extending(p *Provider) int {
+	return p.extensions.Len()
+}
+
+func TestProvider_ExtendAckDeadline(t *testing.T) {
+	defer fastTicker()()
+
+	client := newMockClient()
+	p := testProvider(t, client)
+	p.AckDeadline = 2 * time.Minute
+
+	ackID := "ack-1"
+	stop := p.ExtendAckDeadline(&ackID)
+	waitForDeadlines(t, client, ackID, 2)
+	stop()
+	stop()
+
+	deadlines := client.ackDeadlines(ackID)
+	for _, seconds := range deadlines {
+		if seconds != 120 {
+			t.Errorf("Provider.ExtendAckDeadline() deadlines = %v, want 120 seconds", deadlines)
+			break
+		}
+	}
+
+	// No extensions once stopped.
+	time.Sleep(10 * time.Millisecond)
+	if got := client.ackDeadlines(ackID); len(got) != len(deadlines) {
+		t.Errorf("Provider.ExtendAckDeadline() extended %v times after stop, want %v", len(got), len(deadlines))
+	}
+}
+
+func TestProvider_ExtendAckDeadline_Refused(t *testing.T) {
+	defer fastTicker()()
+
+	client := newMockClient()
+	p := testProvider(t, client)
+
+	// Messages that were acknowledged can't be extended.
+	client.acked = []string{"ack-1"}
+	ackID := "ack-1"
+	stop := p.ExtendAckDeadline(&ackID)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for extending(p) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Provider.ExtendAckDeadline() still extends a refused message")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+}
+
+func TestProvider_AutoExtend(t *testing.T) {
+	defer fastTicker()()
+
+	client := newMockClient()
+	p := testProvider(t, client)
+	p.AutoExtend = true
+	client.add(`{}`, `{}`, `{}`)
+
+	var refs []*string
+	for i := 0; i < 3; i++ {
+		msg, err := p.GetNextMessage()
+		if err != nil {
+			t.Fatalf("Provider.GetNextMessage() error = %v", err)
+		}
+		refs = append(refs, msg.ExternalRef)
+	}
+
+	// Messages are extended while their audits run, after the deadline set when pulled.
+	for _, ref := range refs {
+		waitForDeadlines(t, client, *ref, 2)
+	}
+
+	if err := p.DeleteMessage(refs[0]); err != nil {
+		t.Fatalf("Provider.DeleteMessage() error = %v", err)
+	}
+	if err := p.ReleaseMessage(refs[1]); err != nil {
+		t.Fatalf("Provider.ReleaseMessage() error = %v", err)
+	}
+	p.Close()
+
+	// Deleting, releasing and closing stop the extensions.
+	if n := extending(p); n != 0 {
+		t.Errorf("Provider extends %v messages, want none", n)
+	}
+
+	released := client.ackDeadlines(*refs[1])
+	if released[len(released)-1] != 0 {
+		t.Errorf("Provider.ReleaseMessage() deadlines = %v, want 0 last", released)
+	}
+
+	extended := client.ackDeadlines(*refs[2])
+	time.Sleep(10 * time.Millisecond)
+	if got := client.ackDeadlines(*refs[2]); len(got) != len(extended) {
+		t.Errorf("Provider.Close() extended %v times after close, want %v", len(got), len(extended))
+	}
+}
+
+func TestProvider_AutoExtend_Disabled(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client)
+	client.add(`{}`)
+
+	if _, err := p.GetNextMessage(); err != nil {
+		t.Fatalf("Provider.GetNextMessage() error = %v", err)
+	}
+	if n := extending(p); n != 0 {
+		t.Errorf("Provider extends %v messages, want none", n)
+	}
+}
+
+func TestProvider_Lease(t *testing.T) {
+	client := newMockClient()
+	lease := message.NewLeaseProvider(testProvider(t, client))
+
+	ackID := "ack-1"
+	if err := lease.ExtendLease(&ackID, 5*time.Minute); err != nil {
+		t.Fatalf("Provider.ExtendLease() error = %v", err)
+	}
+	if err := lease.ExtendLease(&ackID, time.Hour); err != nil {
+		t.Fatalf("Provider.ExtendLease() error = %v", err)
+	}
+	if err := lease.Nack(&ackID, true); err != nil {
+		t.Fatalf("Provider.Nack() error = %v", err)
+	}
+
+	// Leases are capped at the longest ack deadline, nacked messages are delivered right away.
+	want := []int32{300, 600, 0}
+	if got := client.ackDeadlines(ackID); !reflect.DeepEqual(got, want) {
+		t.Errorf("Provider ack deadlines = %v, want %v", got, want)
+	}
+
+	if err := lease.ExtendLease(nil, time.Minute); err == nil {
+		t.Errorf("Provider.ExtendLease() expected an error without an ack ID")
+	}
+}
@@ -0,0 +1,66 @@
+//go:build integration
+// +build integration
+
+package pubsub
+
+import (
+	"context"
+	"testing"
+
+	api "cloud.google.com/go/pubsub/apiv1"
+	"github.com/wptide/pkg/message/messagetest"
+	"github.com/wptide/pkg/testenv"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	t.Setenv(EmulatorHostEnv, testenv.PubSub(t))
+	ctx := context.Background()
+
+	publisher, err := api.NewPublisherClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer publisher.Close()
+	subscriber, err := api.NewSubscriberClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscriber.Close()
+
+	tests := []struct {
+		name    string
+		topic   string
+		ordered bool
+	}{
+		{"Unordered", "tide-audits", false},
+		{"Ordered", "tide-audits-ordered", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic := resourceName(testenv.ProjectID, "topics", tt.topic)
+			subscription := resourceName(testenv.ProjectID, "subscriptions", tt.topic+"-worker")
+
+			if _, err := publisher.CreateTopic(ctx, &pb.Topic{Name: topic}); err != nil {
+				t.Fatalf("could not create topic: %v", err)
+			}
+			_, err := subscriber.CreateSubscription(ctx, &pb.Subscription{
+				Name:                  subscription,
+				Topic:                 topic,
+				AckDeadlineSeconds:    10,
+				EnableMessageOrdering: tt.ordered,
+			})
+			if err != nil {
+				t.Fatalf("could not create subscription: %v", err)
+			}
+
+			p, err := New(ctx, testenv.ProjectID, topic, subscription)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Ordered = tt.ordered
+
+			messagetest.Run(t, p, messagetest.Options{})
+		})
+	}
+}
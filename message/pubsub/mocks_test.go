@@ -0,0 +1,115 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// mockClient is an in-memory Pub/Sub topic with a single subscription. Pulled messages are
+// removed from the subscription.
+type mockClient struct {
+	sync.Mutex
+	messages  []*pb.ReceivedMessage
+	published []*pb.PubsubMessage
+	acked     []string
+	deadlines map[string][]int32 // Ack deadlines set, by ack ID.
+	pullErr   error
+	closed    bool
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{deadlines: make(map[string][]int32)}
+}
+
+// add adds messages with the data to the subscription.
+func (m *mockClient) add(data ...string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, d := range data {
+		id := strconv.Itoa(len(m.messages) + len(m.acked) + 1)
+		m.messages = append(m.messages, &pb.ReceivedMessage{
+			AckId:   "ack-" + id,
+			Message: &pb.PubsubMessage{MessageId: id, Data: []byte(d), Attributes: map[string]string{"slug": "plugin-" + id}},
+		})
+	}
+}
+
+// ackDeadlines returns the ack deadlines set for the message.
+func (m *mockClient) ackDeadlines(ackID string) []int32 {
+	m.Lock()
+	defer m.Unlock()
+	return append([]int32(nil), m.deadlines[ackID]...)
+}
+
+func (m *mockClient) Publish(ctx context.Context, topic string, messages []*pb.PubsubMessage) ([]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if topic == "projects/tide/topics/fail" {
+		return nil, status.Error(codes.NotFound, "topic not found")
+	}
+	m.published = append(m.published, messages...)
+	return []string{strconv.Itoa(len(m.published))}, nil
+}
+
+func (m *mockClient) Pull(ctx context.Context, subscription string, max int, returnImmediately bool) ([]*pb.ReceivedMessage, error) {
+	m.Lock()
+	if m.pullErr != nil {
+		m.Unlock()
+		return nil, m.pullErr
+	}
+	if len(m.messages) == 0 && returnImmediately {
+		m.Unlock()
+		return nil, nil
+	}
+	if len(m.messages) == 0 {
+		m.Unlock()
+		// Pulls wait for messages until their deadline.
+		<-ctx.Done()
+		return nil, status.Error(codes.DeadlineExceeded, ctx.Err().Error())
+	}
+	defer m.Unlock()
+
+	if max > len(m.messages) {
+		max = len(m.messages)
+	}
+	pulled := m.messages[:max]
+	m.messages = m.messages[max:]
+	return pulled, nil
+}
+
+func (m *mockClient) Acknowledge(ctx context.Context, subscription string, ackIDs []string) error {
+	m.Lock()
+	defer m.Unlock()
+	for _, ackID := range ackIDs {
+		if ackID == "fail-id" {
+			return errors.New("something went wrong")
+		}
+	}
+	m.acked = append(m.acked, ackIDs...)
+	return nil
+}
+
+func (m *mockClient) ModifyAckDeadline(ctx context.Context, subscription string, ackIDs []string, seconds int32) error {
+	m.Lock()
+	defer m.Unlock()
+	for _, ackID := range ackIDs {
+		for _, acked := range m.acked {
+			if acked == ackID {
+				return status.Error(codes.InvalidArgument, "the ack ID is no longer valid")
+			}
+		}
+		m.deadlines[ackID] = append(m.deadlines[ackID], seconds)
+	}
+	return nil
+}
+
+func (m *mockClient) Close() error {
+	m.closed = true
+	return nil
+}
@@ -0,0 +1,235 @@
+// Package pubsub provides a message.Provider backed by a Google Cloud Pub/Sub topic and its
+// subscription. Messages are published to the topic as JSON and pulled from the subscription;
+// deleting a message acknowledges it.
+//
+// Set PUBSUB_EMULATOR_HOST to use the Pub/Sub emulator, e.g. in tests.
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	pb "google.golang.org/genproto/googleapis/pubsub/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Client is the part of the Pub/Sub API used by the Provider. Names are the full resource
+// names, e.g. "projects/tide/topics/audits".
+type Client interface {
+	Publish(ctx context.Context, topic string, messages []*pb.PubsubMessage) ([]string, error)
+	Pull(ctx context.Context, subscription string, max int, returnImmediately bool) ([]*pb.ReceivedMessage, error)
+	Acknowledge(ctx context.Context, subscription string, ackIDs []string) error
+	ModifyAckDeadline(ctx context.Context, subscription string, ackIDs []string, seconds int32) error
+	Close() error
+}
+
+// Provider represents a Pub/Sub topic and its subscription.
+type Provider struct {
+	ctx          context.Context
+	client       Client
+	Topic        string          // Topic that messages are published to, e.g. "projects/tide/topics/audits".
+	Subscription string          // Subscription that messages are pulled from, e.g. "projects/tide/subscriptions/audits".
+	Poller       *message.Poller // (Optional) Waits longer between pulls while the subscription is idle.
+	PullTimeout  time.Duration   // (Optional) How long a pull waits for messages. Defaults to 10 seconds, negative returns at once.
+
+	AckDeadline time.Duration // (Optional) How long pulled messages are hidden from other workers. Defaults to 10 minutes, the longest Pub/Sub allows.
+	AutoExtend  bool          // (Optional) Extends the ack deadline of pulled messages until they are deleted or released.
+	Ordered     bool          // (Optional) Publishes the messages of a project in order. The subscription must have message ordering enabled.
+
+	extensions *message.Extensions // Running ack deadline extensions.
+}
+
+// defaultPullTimeout is how long a pull waits for messages by default.
+const defaultPullTimeout = 10 * time.Second
+
+// maxAckDeadline is the longest ack deadline allowed by Pub/Sub.
+const maxAckDeadline = 600 * time.Second
+
+// sleep waits between pulls.
+var sleep = time.Sleep
+
+// SendMessage publishes the message to the topic. Ordered providers publish the messages of a
+// project with the same ordering key, "<request client>-<slug>", so they are delivered in order.
+func (p Provider) SendMessage(msg *message.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	published := &pb.PubsubMessage{Data: data}
+	if p.Ordered {
+		published.OrderingKey = orderingKey(msg)
+	}
+
+	_, err = p.client.Publish(p.context(), p.Topic, []*pb.PubsubMessage{published})
+	return err
+}
+
+// GetNextMessage pulls the next message from the subscription and decodes its JSON body. The
+// ack ID of the message is its ExternalRef.
+//
+// With a Poller it waits for the poller delay before pulling, so an idle subscription is
+// pulled less often.
+func (p Provider) GetNextMessage() (*message.Message, error) {
+	// Messages that can't be decoded are returned with their ack ID, so they can be deleted.
+	return message.DecodingProvider{Source: p}.GetNextMessage()
+}
+
+// GetNextEnvelope pulls the next message from the subscription without decoding it, e.g. for
+// a message.DecodingProvider with a message.AttributeDecoder. Its attributes are the Pub/Sub
+// attributes of the message.
+func (p Provider) GetNextEnvelope() (*message.Envelope, error) {
+	sleep(p.Poller.Delay())
+
+	// Pulls that return at once still have a deadline, in case Pub/Sub doesn't answer.
+	timeout := p.pullTimeout()
+	returnImmediately := timeout <= 0
+	if returnImmediately {
+		timeout = defaultPullTimeout
+	}
+	ctx, cancel := context.WithTimeout(p.context(), timeout)
+	defer cancel()
+
+	received, err := p.client.Pull(ctx, p.Subscription, 1, returnImmediately)
+	p.Poller.Polled(err == nil && len(received) != 0)
+
+	if err != nil {
+		// Pulls that time out on an idle subscription are not an error.
+		if ctx.Err() == context.DeadlineExceeded && p.context().Err() == nil {
+			return nil, errors.New("could not retrieve message")
+		}
+
+		// If we get a critical Pub/Sub error, issue a new provider error.
+		pErr := message.NewProviderError(err.Error())
+		if status.Code(err) != codes.ResourceExhausted {
+			pErr.Type = message.ErrCritcal
+		} else {
+			pErr.Type = message.ErrOverQuota
+		}
+		return nil, pErr
+	}
+
+	if len(received) == 0 || received[0].Message == nil {
+		return nil, errors.New("could not retrieve message")
+	}
+
+	ackID := received[0].AckId
+	env := &message.Envelope{
+		Body:       received[0].Message.Data,
+		Attributes: received[0].Message.Attributes,
+		Ref:        &ackID,
+	}
+
+	// Pulled messages have the ack deadline of the subscription, which is usually shorter.
+	if err := p.modifyAckDeadline(ackID, p.ackDeadline()); err != nil {
+		return nil, err
+	}
+	p.autoExtend(env.Ref)
+
+	return env, nil
+}
+
+// DeleteMessage acknowledges the message with the ack ID, so that it is not delivered again.
+func (p Provider) DeleteMessage(ref *string) error {
+	if ref == nil {
+		return errors.New("pubsub: no ack ID")
+	}
+	p.stopExtending(ref)
+
+	return p.client.Acknowledge(p.context(), p.Subscription, []string{*ref})
+}
+
+// Close stops extending the ack deadlines of pulled messages and closes the client. Messages
+// that were not deleted are delivered again.
+func (p Provider) Close() error {
+	p.extensions.StopAll()
+	if p.client != nil {
+		return p.client.Close()
+	}
+	return nil
+}
+
+// context returns the context of the provider.
+func (p Provider) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// ReceiveWait implements message.Waiter.
+func (p Provider) ReceiveWait() time.Duration {
+	return p.Poller.MaxDelay() + p.pullTimeout()
+}
+
+// pullTimeout returns how long a pull waits for messages, zero if it returns at once.
+func (p Provider) pullTimeout() time.Duration {
+	if p.PullTimeout < 0 {
+		return 0
+	}
+	if p.PullTimeout > 0 {
+		return p.PullTimeout
+	}
+	return defaultPullTimeout
+}
+
+// ackDeadline returns how long pulled messages are hidden from other workers.
+func (p Provider) ackDeadline() time.Duration {
+	if p.AckDeadline > 0 && p.AckDeadline < maxAckDeadline {
+		return p.AckDeadline
+	}
+	return maxAckDeadline
+}
+
+// modifyAckDeadline sets the ack deadline of the message to the duration from now.
+func (p Provider) modifyAckDeadline(ackID string, deadline time.Duration) error {
+	return p.client.ModifyAckDeadline(p.context(), p.Subscription, []string{ackID}, int32(deadline/time.Second))
+}
+
+// orderingKey returns the ordering key of the messages of the project, like the message group
+// of FIFO queues.
+func orderingKey(msg *message.Message) string {
+	return fmt.Sprintf("%s-%s", msg.RequestClient, msg.Slug)
+}
+
+// New returns a Provider for the topic and subscription of the Google Cloud project, e.g.
+// New(ctx, "tide", "audits", "audits-worker").
+func New(ctx context.Context, projectID, topic, subscription string) (*Provider, error) {
+	client, err := NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithClient(ctx, projectID, topic, subscription, client)
+}
+
+// NewWithClient returns a Provider using the client, e.g. a mock Client in tests.
+func NewWithClient(ctx context.Context, projectID, topic, subscription string, client Client) (*Provider, error) {
+	if client == nil {
+		return nil, errors.New("pubsub: no client")
+	}
+	if projectID == "" || topic == "" || subscription == "" {
+		return nil, errors.New("pubsub: a project, topic and subscription are required")
+	}
+
+	return &Provider{
+		ctx:          ctx,
+		client:       client,
+		Topic:        resourceName(projectID, "topics", topic),
+		Subscription: resourceName(projectID, "subscriptions", subscription),
+		extensions:   message.NewExtensions(),
+	}, nil
+}
+
+// resourceName returns the full name of a topic or subscription. Full names are kept.
+func resourceName(projectID, collection, name string) string {
+	if strings.HasPrefix(name, "projects/") {
+		return name
+	}
+	return "projects/" + projectID + "/" + collection + "/" + name
+}
@@ -0,0 +1,327 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testProvider returns a provider of the "tide" project using the client.
+func testProvider(t *testing.T, client Client) *Provider {
+	p, err := NewWithClient(context.Background(), "tide", "audits", "audits-worker", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.PullTimeout = 10 * time.Millisecond
+	return p
+}
+
+func TestNewWithClient(t *testing.T) {
+	tests := []struct {
+		name             string
+		topic            string
+		subscription     string
+		client           Client
+		wantTopic        string
+		wantSubscription string
+		wantErr          bool
+	}{
+		{
+			name:             "Short Names",
+			topic:            "audits",
+			subscription:     "audits-worker",
+			client:           newMockClient(),
+			wantTopic:        "projects/tide/topics/audits",
+			wantSubscription: "projects/tide/subscriptions/audits-worker",
+		},
+		{
+			name:             "Full Names",
+			topic:            "projects/shared/topics/audits",
+			subscription:     "projects/shared/subscriptions/audits-worker",
+			client:           newMockClient(),
+			wantTopic:        "projects/shared/topics/audits",
+			wantSubscription: "projects/shared/subscriptions/audits-worker",
+		},
+		{
+			name:         "No Client",
+			topic:        "audits",
+			subscription: "audits-worker",
+			wantErr:      true,
+		},
+		{
+			name:    "No Subscription",
+			topic:   "audits",
+			client:  newMockClient(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewWithClient(context.Background(), "tide", tt.topic, tt.subscription, tt.client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewWithClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Topic != tt.wantTopic || got.Subscription != tt.wantSubscription {
+				t.Errorf("NewWithClient() = %v, %v, want %v, %v", got.Topic, got.Subscription, tt.wantTopic, tt.wantSubscription)
+			}
+		})
+	}
+}
+
+func TestProvider_SendMessage(t *testing.T) {
+	tests := []struct {
+		name            string
+		topic           string
+		ordered         bool
+		wantOrderingKey string
+		wantErr         bool
+	}{
+		{
+			name:  "Unordered",
+			topic: "audits",
+		},
+		{
+			name:            "Ordered By Project",
+			topic:           "audits",
+			ordered:         true,
+			wantOrderingKey: "wporg-akismet",
+		},
+		{
+			name:    "Publish Error",
+			topic:   "fail",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockClient()
+			p, _ := NewWithClient(context.Background(), "tide", tt.topic, "audits-worker", client)
+			p.Ordered = tt.ordered
+
+			msg := &message.Message{Title: "Akismet", Slug: "akismet", RequestClient: "wporg"}
+			if err := p.SendMessage(msg); (err != nil) != tt.wantErr {
+				t.Fatalf("Provider.SendMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(client.published) != 1 {
+				t.Fatalf("Provider.SendMessage() published %v", client.published)
+			}
+			published := client.published[0]
+			if published.OrderingKey != tt.wantOrderingKey {
+				t.Errorf("Provider.SendMessage() ordering key = %v, want %v", published.OrderingKey, tt.wantOrderingKey)
+			}
+
+			var got message.Message
+			if err := json.Unmarshal(published.Data, &got); err != nil || !reflect.DeepEqual(&got, msg) {
+				t.Errorf("Provider.SendMessage() data = %s, want %v", published.Data, msg)
+			}
+		})
+	}
+}
+
+func TestProvider_GetNextMessage(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client)
+	p.AckDeadline = 5 * time.Minute
+	client.add(`{"title":"Success!"}`, `{not json`)
+
+	got, err := p.GetNextMessage()
+	if err != nil {
+		t.Fatalf("Provider.GetNextMessage() error = %v", err)
+	}
+	ref := "ack-1"
+	if want := (&message.Message{Title: "Success!", ExternalRef: &ref}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Provider.GetNextMessage() = %v, want %v", got, want)
+	}
+
+	// The ack deadline of pulled messages is set right away.
+	if deadlines := client.ackDeadlines("ack-1"); !reflect.DeepEqual(deadlines, []int32{300}) {
+		t.Errorf("Provider.GetNextMessage() ack deadlines = %v, want [300]", deadlines)
+	}
+
+	// Messages that don't decode are returned with their ack ID, so they can be deleted.
+	got, err = p.GetNextMessage()
+	if err == nil || got == nil || *got.ExternalRef != "ack-2" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want undecoded message with error", got, err)
+	}
+
+	// Idle subscriptions time out without a provider error.
+	got, err = p.GetNextMessage()
+	if _, ok := err.(*message.ProviderError); got != nil || err == nil || ok {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want no message", got, err)
+	}
+}
+
+func TestProvider_GetNextMessage_NoWait(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client)
+	p.PullTimeout = -1
+
+	// Idle subscriptions return at once.
+	start := time.Now()
+	got, err := p.GetNextMessage()
+	if _, ok := err.(*message.ProviderError); got != nil || err == nil || ok {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want no message", got, err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Provider.GetNextMessage() waited %v, want no wait", elapsed)
+	}
+
+	client.add(`{"title":"Success!"}`)
+	if got, err := p.GetNextMessage(); err != nil || got.Title != "Success!" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want the pulled message", got, err)
+	}
+}
+
+func TestProvider_ReceiveWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		poller  *message.Poller
+		want    time.Duration
+	}{
+		{"Default", 0, nil, defaultPullTimeout},
+		{"Timeout", time.Second, nil, time.Second},
+		{"No Wait", -1, nil, 0},
+		{"Poller", -1, message.NewPoller(time.Second, time.Minute), time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Provider{PullTimeout: tt.timeout, Poller: tt.poller}
+			if got := p.ReceiveWait(); got != tt.want {
+				t.Errorf("Provider.ReceiveWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvider_GetNextMessage_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantType int
+	}{
+		{
+			name:     "Critical Error",
+			err:      status.Error(codes.NotFound, "subscription not found"),
+			wantType: message.ErrCritcal,
+		},
+		{
+			name:     "Over Quota",
+			err:      status.Error(codes.ResourceExhausted, "quota exceeded"),
+			wantType: message.ErrOverQuota,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockClient()
+			client.pullErr = tt.err
+
+			_, err := testProvider(t, client).GetNextMessage()
+			pErr, ok := err.(*message.ProviderError)
+			if !ok || pErr.Type != tt.wantType {
+				t.Errorf("Provider.GetNextMessage() error = %#v, want provider error of type %v", err, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestProvider_GetNextMessage_Poller(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	client := newMockClient()
+	p := testProvider(t, client)
+	p.Poller = message.NewPoller(time.Second, 4*time.Second)
+
+	// Back off while the subscription is idle.
+	for i := 0; i < 3; i++ {
+		p.GetNextMessage()
+	}
+
+	// Pull without waiting once messages are flowing again.
+	client.add(`{}`, `{}`)
+	p.GetNextMessage()
+	p.GetNextMessage()
+
+	want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 0}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept = %v, want %v", slept, want)
+	}
+}
+
+func TestProvider_GetNextEnvelope(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client)
+	client.add(`{"title":"Plugin"}`)
+
+	decoding := message.DecodingProvider{Source: p, Decoder: message.AttributeDecoder{}}
+	got, err := decoding.GetNextMessage()
+	if err != nil {
+		t.Fatalf("DecodingProvider.GetNextMessage() error = %v", err)
+	}
+
+	// Attributes complete the body.
+	if got.Title != "Plugin" || got.Slug != "plugin-1" || *got.ExternalRef != "ack-1" {
+		t.Errorf("DecodingProvider.GetNextMessage() = %+v", got)
+	}
+}
+
+func TestProvider_DeleteMessage(t *testing.T) {
+	successID := "success-id"
+	failID := "fail-id"
+
+	tests := []struct {
+		name    string
+		ref     *string
+		wantErr bool
+	}{
+		{
+			name: "Acknowledge",
+			ref:  &successID,
+		},
+		{
+			name:    "Acknowledge Error",
+			ref:     &failID,
+			wantErr: true,
+		},
+		{
+			name:    "No Ack ID",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockClient()
+			if err := testProvider(t, client).DeleteMessage(tt.ref); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.DeleteMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(client.acked, []string{*tt.ref}) {
+				t.Errorf("Provider.DeleteMessage() acked %v, want %v", client.acked, *tt.ref)
+			}
+		})
+	}
+}
+
+func TestProvider_Close(t *testing.T) {
+	client := newMockClient()
+	if err := testProvider(t, client).Close(); err != nil || !client.closed {
+		t.Errorf("Provider.Close() error = %v, closed %v", err, client.closed)
+	}
+	if err := (Provider{}).Close(); err != nil {
+		t.Errorf("Provider.Close() error = %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package redis
+
+import (
+	"time"
+
+	goredis "github.com/go-redis/redis"
+)
+
+// goRedis is a Client of a go-redis client.
+type goRedis struct {
+	client goredis.UniversalClient
+}
+
+// GoRedisClient returns a Client of the go-redis client, e.g. a *redis.Client or a
+// *redis.ClusterClient. The client is closed by its owner.
+func GoRedisClient(client goredis.UniversalClient) Client {
+	return goRedis{client: client}
+}
+
+// XGroupCreateMkStream implements Client.
+func (c goRedis) XGroupCreateMkStream(stream, group, start string) error {
+	return c.client.XGroupCreateMkStream(stream, group, start).Err()
+}
+
+// XAdd implements Client.
+func (c goRedis) XAdd(stream string, values map[string]interface{}) (string, error) {
+	return c.client.XAdd(&goredis.XAddArgs{Stream: stream, Values: values}).Result()
+}
+
+// XReadGroup implements Client. Reads that time out return no entries. go-redis leaves out the
+// BLOCK of a negative block.
+func (c goRedis) XReadGroup(group, consumer, stream string, count int64, block time.Duration) ([]Entry, error) {
+	streams, err := c.client.XReadGroup(&goredis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{stream, ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, s := range streams {
+		entries = append(entries, toEntries(s.Messages)...)
+	}
+	return entries, nil
+}
+
+// XPending implements Client. The oldest count pending entries are listed and those that are not
+// idle for long enough are left out, as XPENDING only filters by idle time since Redis 6.2.
+func (c goRedis) XPending(stream, group string, minIdle time.Duration, count int64) ([]Pending, error) {
+	pending, err := c.client.XPendingExt(&goredis.XPendingExtArgs{
+		Stream: stream,
+		Group:  group,
+		Start:  "-",
+		End:    "+",
+		Count:  count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var idle []Pending
+	for _, entry := range pending {
+		if entry.Idle >= minIdle {
+			idle = append(idle, Pending{ID: entry.Id, Consumer: entry.Consumer, Idle: entry.Idle, Deliveries: entry.RetryCount})
+		}
+	}
+	return idle, nil
+}
+
+// XClaim implements Client.
+func (c goRedis) XClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]Entry, error) {
+	messages, err := c.client.XClaim(&goredis.XClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Messages: ids,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return toEntries(messages), nil
+}
+
+// XClaimJustID implements Client. go-redis has no arguments for the IDLE option.
+func (c goRedis) XClaimJustID(stream, group, consumer string, idle time.Duration, ids ...string) ([]string, error) {
+	args := []interface{}{"xclaim", stream, group, consumer, 0}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, "idle", int64(idle/time.Millisecond), "justid")
+
+	cmd := goredis.NewStringSliceCmd(args...)
+	if err := c.client.Process(cmd); err != nil {
+		return nil, err
+	}
+	return cmd.Result()
+}
+
+// XAck implements Client.
+func (c goRedis) XAck(stream, group string, ids ...string) (int64, error) {
+	return c.client.XAck(stream, group, ids...).Result()
+}
+
+// XDel implements Client.
+func (c goRedis) XDel(stream string, ids ...string) (int64, error) {
+	return c.client.XDel(stream, ids...).Result()
+}
+
+// toEntries returns the entries of go-redis messages.
+func toEntries(messages []goredis.XMessage) []Entry {
+	entries := make([]Entry, 0, len(messages))
+	for _, msg := range messages {
+		entries = append(entries, Entry{ID: msg.ID, Values: msg.Values})
+	}
+	return entries
+}
@@ -0,0 +1,30 @@
+//go:build integration
+// +build integration
+
+package redis
+
+import (
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/wptide/pkg/message/messagetest"
+	"github.com/wptide/pkg/testenv"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	options, err := goredis.ParseURL(testenv.Redis(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := goredis.NewClient(options)
+	defer client.Close()
+
+	p, err := New(GoRedisClient(client), "tide-audits", "workers", "worker-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Block = time.Second
+
+	messagetest.Run(t, p, messagetest.Options{})
+}
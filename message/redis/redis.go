@@ -0,0 +1,316 @@
+// Package redis implements a message.Provider over a Redis Stream with a consumer group, a
+// lightweight queue for small deployments.
+//
+// Each worker reads new entries as a consumer of the group and acknowledges them once they are
+// deleted. Entries that stay pending for too long, e.g. of a crashed worker, are claimed by the
+// next worker that asks for a message. Entries that were delivered too often are moved to a
+// dead-letter stream instead.
+//
+// Workers keep their entries by claiming them again, see Provider.ExtendLease, and release them
+// to the next worker that asks for a message, see Provider.ReleaseMessage. Clients of go-redis
+// are adapted with GoRedisClient.
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Entry is an entry of a stream.
+type Entry struct {
+	ID     string
+	Values map[string]interface{}
+}
+
+// Pending is an entry that was delivered to a consumer of a group but not acknowledged yet.
+type Pending struct {
+	ID         string
+	Consumer   string
+	Idle       time.Duration // Time since the entry was last delivered.
+	Deliveries int64         // Number of times the entry was delivered.
+}
+
+// Client describes the Redis stream commands required by the Provider.
+// Redis clients (e.g. go-redis, see GoRedisClient) can be adapted to this interface.
+type Client interface {
+	// XGroupCreateMkStream creates the group, and the stream if it doesn't exist (XGROUP CREATE ... MKSTREAM).
+	XGroupCreateMkStream(stream, group, start string) error
+	// XAdd appends an entry to the stream and returns its ID.
+	XAdd(stream string, values map[string]interface{}) (string, error)
+	// XReadGroup reads new entries for the consumer of the group (XREADGROUP ... STREAMS stream >),
+	// blocking for up to block while there are none. A negative block does not block.
+	XReadGroup(group, consumer, stream string, count int64, block time.Duration) ([]Entry, error)
+	// XPending lists pending entries of the group that have been idle for at least minIdle.
+	XPending(stream, group string, minIdle time.Duration, count int64) ([]Pending, error)
+	// XClaim transfers pending entries that are idle for at least minIdle to the consumer.
+	XClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]Entry, error)
+	// XClaimJustID transfers pending entries to the consumer and sets their idle time, without
+	// counting a delivery (XCLAIM ... 0 ids IDLE idle JUSTID). It returns the IDs of the entries.
+	XClaimJustID(stream, group, consumer string, idle time.Duration, ids ...string) ([]string, error)
+	// XAck acknowledges entries of the group.
+	XAck(stream, group string, ids ...string) (int64, error)
+	// XDel deletes entries from the stream.
+	XDel(stream string, ids ...string) (int64, error)
+}
+
+// Provider is a queue over a Redis Stream.
+type Provider struct {
+	client           Client
+	Stream           string          // Stream of the messages, e.g. "tide:audits".
+	Group            string          // Consumer group of the workers.
+	Consumer         string          // Name of this worker in the group, unique per worker.
+	Block            time.Duration   // (Optional) How long GetNextMessage waits for new entries. Defaults to 5 seconds, negative returns at once.
+	ClaimIdle        time.Duration   // (Optional) How long an entry is pending before other workers claim it. Defaults to 10 minutes.
+	MaxRetries       int64           // (Optional) Deliveries after the first before an entry is dead-lettered. Defaults to 3.
+	DeadLetterStream string          // (Optional) Stream of the dead-lettered entries. Defaults to the stream with a ":dead" suffix.
+	Poller           *message.Poller // (Optional) Waits longer between reads while the stream is idle.
+}
+
+// Defaults of the Provider.
+const (
+	DefaultBlock      = 5 * time.Second
+	DefaultClaimIdle  = 10 * time.Minute
+	DefaultMaxRetries = 3
+)
+
+// messageField is the field of the entries with the message as JSON.
+const messageField = "message"
+
+// claimBatch is the number of pending entries checked per message.
+const claimBatch = 10
+
+// sleep waits between reads.
+var sleep = time.Sleep
+
+// New returns a Provider for the consumer of the group of the stream. The group is created,
+// with the stream, if it doesn't exist.
+func New(client Client, stream, group, consumer string) (*Provider, error) {
+	if client == nil {
+		return nil, errors.New("redis: no client")
+	}
+	if stream == "" || group == "" || consumer == "" {
+		return nil, errors.New("redis: a stream, group and consumer are required")
+	}
+
+	// Groups that exist already are used as they are.
+	if err := client.XGroupCreateMkStream(stream, group, "0"); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+
+	return &Provider{
+		client:   client,
+		Stream:   stream,
+		Group:    group,
+		Consumer: consumer,
+	}, nil
+}
+
+// SendMessage appends the message to the stream.
+func (p Provider) SendMessage(msg *message.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.client.XAdd(p.Stream, map[string]interface{}{messageField: string(data)})
+	return err
+}
+
+// GetNextMessage returns the next message for the consumer. Entries that other consumers left
+// pending for longer than ClaimIdle are claimed first, then new entries are read. The entry ID
+// is the ExternalRef of the message.
+//
+// With a Poller it waits for the poller delay before reading, so an idle stream is read less
+// often.
+func (p Provider) GetNextMessage() (*message.Message, error) {
+	sleep(p.Poller.Delay())
+
+	entry, err := p.claim()
+	if err == nil && entry == nil {
+		var entries []Entry
+		entries, err = p.client.XReadGroup(p.Group, p.Consumer, p.Stream, 1, p.block())
+		if len(entries) != 0 {
+			entry = &entries[0]
+		}
+	}
+	p.Poller.Polled(err == nil && entry != nil)
+
+	if err != nil {
+		pErr := message.NewProviderError(err.Error())
+		pErr.Type = message.ErrCritcal
+		return nil, pErr
+	}
+	if entry == nil {
+		return nil, errors.New("could not retrieve message")
+	}
+
+	return decode(*entry)
+}
+
+// claim claims the oldest pending entry that has been idle for long enough, or returns nil if
+// there is none. Entries that were retried too often are moved to the dead-letter stream.
+func (p Provider) claim() (*Entry, error) {
+	pending, err := p.client.XPending(p.Stream, p.Group, p.claimIdle(), claimBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range pending {
+		claimed, err := p.client.XClaim(p.Stream, p.Group, p.Consumer, p.claimIdle(), entry.ID)
+		if err != nil {
+			return nil, err
+		}
+		// Another worker claimed the entry first, or it was deleted.
+		if len(claimed) == 0 {
+			continue
+		}
+
+		if entry.Deliveries > p.maxRetries() {
+			if err := p.deadLetter(claimed[0], entry.Deliveries); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return &claimed[0], nil
+	}
+	return nil, nil
+}
+
+// deadLetter moves the entry to the dead-letter stream, with its ID and deliveries.
+func (p Provider) deadLetter(entry Entry, deliveries int64) error {
+	values := map[string]interface{}{
+		"id":         entry.ID,
+		"stream":     p.Stream,
+		"group":      p.Group,
+		"deliveries": strconv.FormatInt(deliveries, 10),
+	}
+	for field, value := range entry.Values {
+		values[field] = value
+	}
+
+	if _, err := p.client.XAdd(p.deadLetterStream(), values); err != nil {
+		return err
+	}
+	return p.remove(entry.ID)
+}
+
+// DeleteMessage acknowledges the entry and deletes it from the stream.
+func (p Provider) DeleteMessage(ref *string) error {
+	if ref == nil {
+		return errors.New("redis: no entry ID")
+	}
+	return p.remove(*ref)
+}
+
+// ReleaseMessage implements message.Releaser. The entry is left pending with the idle time of
+// ClaimIdle, so the next worker that asks for a message claims it right away, as a retry.
+func (p Provider) ReleaseMessage(ref *string) error {
+	return p.setIdle(ref, p.claimIdle())
+}
+
+// ExtendLease implements message.LeaseExtender. The entry is claimed again by the consumer, so
+// other workers claim it once the duration has passed. Leases are at most ClaimIdle long.
+func (p Provider) ExtendLease(ref *string, d time.Duration) error {
+	idle := p.claimIdle() - d
+	if idle < 0 {
+		idle = 0
+	}
+	return p.setIdle(ref, idle)
+}
+
+// setIdle claims the pending entry for the consumer with the idle time.
+func (p Provider) setIdle(ref *string, idle time.Duration) error {
+	if ref == nil {
+		return errors.New("redis: no entry ID")
+	}
+
+	ids, err := p.client.XClaimJustID(p.Stream, p.Group, p.Consumer, idle, *ref)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return errors.New("redis: entry " + *ref + " is not pending")
+	}
+	return nil
+}
+
+// Close implemented to satisfy Provider interface. The client is closed by its owner.
+func (p Provider) Close() error {
+	return nil
+}
+
+// remove acknowledges the entry and deletes it from the stream.
+func (p Provider) remove(id string) error {
+	if _, err := p.client.XAck(p.Stream, p.Group, id); err != nil {
+		return err
+	}
+	_, err := p.client.XDel(p.Stream, id)
+	return err
+}
+
+// ReceiveWait implements message.Waiter.
+func (p Provider) ReceiveWait() time.Duration {
+	wait := p.Poller.MaxDelay()
+	if block := p.block(); block > 0 {
+		wait += block
+	}
+	return wait
+}
+
+// block returns how long GetNextMessage waits for new entries, negative if it doesn't wait.
+// XREADGROUP blocks forever with a block of zero.
+func (p Provider) block() time.Duration {
+	if p.Block < 0 {
+		return -1
+	}
+	if p.Block > 0 {
+		return p.Block
+	}
+	return DefaultBlock
+}
+
+// claimIdle returns how long an entry is pending before it is claimed.
+func (p Provider) claimIdle() time.Duration {
+	if p.ClaimIdle > 0 {
+		return p.ClaimIdle
+	}
+	return DefaultClaimIdle
+}
+
+// maxRetries returns the deliveries after the first before an entry is dead-lettered.
+func (p Provider) maxRetries() int64 {
+	if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return DefaultMaxRetries
+}
+
+// deadLetterStream returns the stream of the dead-lettered entries.
+func (p Provider) deadLetterStream() string {
+	if p.DeadLetterStream != "" {
+		return p.DeadLetterStream
+	}
+	return p.Stream + ":dead"
+}
+
+// decode returns the message of the entry. Entries that can't be decoded are returned with
+// their ID, so that they can be deleted.
+func decode(entry Entry) (*message.Message, error) {
+	id := entry.ID
+	msg := &message.Message{}
+
+	data, ok := entry.Values[messageField].(string)
+	if !ok {
+		msg.ExternalRef = &id
+		return msg, errors.New("redis: entry " + id + " has no message")
+	}
+
+	err := json.Unmarshal([]byte(data), msg)
+	msg.ExternalRef = &id
+	return msg, err
+}
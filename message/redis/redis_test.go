@@ -0,0 +1,464 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// pendingEntry is the delivery state of an entry of mockClient.
+type pendingEntry struct {
+	consumer   string
+	delivered  time.Time
+	deliveries int64
+}
+
+// mockClient is an in-memory Redis with streams and a single consumer group per stream. Its
+// clock only moves when a test advances it.
+type mockClient struct {
+	now     time.Time
+	streams map[string][]Entry
+	read    map[string]int // Entries read by the group, by stream.
+	pending map[string]*pendingEntry
+	groups  map[string]bool
+	nextID  int
+	err     error
+	block   time.Duration // Block of the last XReadGroup.
+}
+
+func newMockClient() *mockClient {
+	return &mockClient{
+		now:     time.Unix(0, 0),
+		streams: make(map[string][]Entry),
+		read:    make(map[string]int),
+		pending: make(map[string]*pendingEntry),
+		groups:  make(map[string]bool),
+	}
+}
+
+func (m *mockClient) XGroupCreateMkStream(stream, group, start string) error {
+	if m.groups[stream+"/"+group] {
+		return errors.New("BUSYGROUP Consumer Group name already exists")
+	}
+	if stream == "error" {
+		return errors.New("connection refused")
+	}
+	m.groups[stream+"/"+group] = true
+	return nil
+}
+
+func (m *mockClient) XAdd(stream string, values map[string]interface{}) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
+	m.nextID++
+	id := strconv.Itoa(m.nextID) + "-0"
+	m.streams[stream] = append(m.streams[stream], Entry{ID: id, Values: values})
+	return id, nil
+}
+
+func (m *mockClient) XReadGroup(group, consumer, stream string, count int64, block time.Duration) ([]Entry, error) {
+	m.block = block
+	if m.err != nil {
+		return nil, m.err
+	}
+	entries := m.streams[stream][m.read[stream]:]
+	if int64(len(entries)) > count {
+		entries = entries[:count]
+	}
+	m.read[stream] += len(entries)
+	for _, entry := range entries {
+		m.pending[entry.ID] = &pendingEntry{consumer: consumer, delivered: m.now, deliveries: 1}
+	}
+	return entries, nil
+}
+
+func (m *mockClient) XPending(stream, group string, minIdle time.Duration, count int64) ([]Pending, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	pending := []Pending{}
+	for id, entry := range m.pending {
+		if idle := m.now.Sub(entry.delivered); idle >= minIdle {
+			pending = append(pending, Pending{ID: id, Consumer: entry.consumer, Idle: idle, Deliveries: entry.deliveries})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].ID < pending[j].ID })
+	if int64(len(pending)) > count {
+		pending = pending[:count]
+	}
+	return pending, nil
+}
+
+func (m *mockClient) XClaim(stream, group, consumer string, minIdle time.Duration, ids ...string) ([]Entry, error) {
+	claimed := []Entry{}
+	for _, id := range ids {
+		entry, ok := m.pending[id]
+		if !ok || m.now.Sub(entry.delivered) < minIdle {
+			continue
+		}
+		entry.consumer, entry.delivered = consumer, m.now
+		entry.deliveries++
+		for _, e := range m.streams[stream] {
+			if e.ID == id {
+				claimed = append(claimed, e)
+			}
+		}
+	}
+	return claimed, nil
+}
+
+func (m *mockClient) XClaimJustID(stream, group, consumer string, idle time.Duration, ids ...string) ([]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	claimed := []string{}
+	for _, id := range ids {
+		if entry, ok := m.pending[id]; ok {
+			entry.consumer, entry.delivered = consumer, m.now.Add(-idle)
+			claimed = append(claimed, id)
+		}
+	}
+	return claimed, nil
+}
+
+func (m *mockClient) XAck(stream, group string, ids ...string) (int64, error) {
+	if m.err != nil {
+		return 0, m.err
+	}
+	var acked int64
+	for _, id := range ids {
+		if _, ok := m.pending[id]; ok {
+			delete(m.pending, id)
+			acked++
+		}
+	}
+	return acked, nil
+}
+
+func (m *mockClient) XDel(stream string, ids ...string) (int64, error) {
+	var deleted int64
+	for _, id := range ids {
+		entries := m.streams[stream]
+		for i, e := range entries {
+			if e.ID == id {
+				m.streams[stream] = append(entries[:i:i], entries[i+1:]...)
+				if i < m.read[stream] {
+					m.read[stream]--
+				}
+				deleted++
+				break
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// testProvider returns a provider of the "audits" stream for the consumer.
+func testProvider(t *testing.T, client *mockClient, consumer string) *Provider {
+	p, err := New(client, "audits", "workers", consumer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNew(t *testing.T) {
+	client := newMockClient()
+
+	tests := []struct {
+		name     string
+		client   Client
+		stream   string
+		consumer string
+		wantErr  bool
+	}{
+		{"New Group", client, "audits", "worker-1", false},
+		{"Existing Group", client, "audits", "worker-2", false},
+		{"No Client", nil, "audits", "worker-1", true},
+		{"No Consumer", client, "audits", "", true},
+		{"Redis Error", client, "error", "worker-1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.client, tt.stream, "workers", tt.consumer); (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_SendMessage(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client, "worker-1")
+
+	msg := &message.Message{Title: "Akismet", Slug: "akismet"}
+	if err := p.SendMessage(msg); err != nil {
+		t.Fatalf("Provider.SendMessage() error = %v", err)
+	}
+
+	var got message.Message
+	data, _ := client.streams["audits"][0].Values[messageField].(string)
+	if err := json.Unmarshal([]byte(data), &got); err != nil || !reflect.DeepEqual(&got, msg) {
+		t.Errorf("Provider.SendMessage() entry = %v, want %v", client.streams["audits"][0], msg)
+	}
+
+	client.err = errors.New("connection refused")
+	if err := p.SendMessage(msg); err == nil {
+		t.Error("Provider.SendMessage() error = nil, want error")
+	}
+}
+
+func TestProvider_GetNextMessage(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client, "worker-1")
+	p.SendMessage(&message.Message{Title: "Success!"})
+	client.XAdd("audits", map[string]interface{}{"other": "field"})
+
+	got, err := p.GetNextMessage()
+	if err != nil {
+		t.Fatalf("Provider.GetNextMessage() error = %v", err)
+	}
+	ref := "1-0"
+	if want := (&message.Message{Title: "Success!", ExternalRef: &ref}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Provider.GetNextMessage() = %v, want %v", got, want)
+	}
+
+	// Entries without a message are returned with their ID, so they can be deleted.
+	got, err = p.GetNextMessage()
+	if err == nil || got == nil || *got.ExternalRef != "2-0" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want undecoded message with error", got, err)
+	}
+
+	// Idle streams are not a provider error.
+	got, err = p.GetNextMessage()
+	if _, ok := err.(*message.ProviderError); got != nil || err == nil || ok {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want no message", got, err)
+	}
+
+	client.err = errors.New("connection refused")
+	if _, err := p.GetNextMessage(); err == nil {
+		t.Error("Provider.GetNextMessage() error = nil, want provider error")
+	} else if _, ok := err.(*message.ProviderError); !ok {
+		t.Errorf("Provider.GetNextMessage() error = %#v, want provider error", err)
+	}
+}
+
+func TestProvider_ReceiveWait(t *testing.T) {
+	tests := []struct {
+		name      string
+		block     time.Duration
+		poller    *message.Poller
+		want      time.Duration
+		wantBlock time.Duration
+	}{
+		{"Default", 0, nil, DefaultBlock, DefaultBlock},
+		{"Block", time.Second, nil, time.Second, time.Second},
+		{"No Wait", -time.Second, nil, 0, -1},
+		{"Poller", -1, message.NewPoller(time.Second, time.Minute), time.Minute, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockClient()
+			p := testProvider(t, client, "worker-1")
+			p.Block = tt.block
+			p.Poller = tt.poller
+
+			if got := p.ReceiveWait(); got != tt.want {
+				t.Errorf("Provider.ReceiveWait() = %v, want %v", got, tt.want)
+			}
+
+			// Reads without a wait leave out the block, XREADGROUP blocks forever with zero.
+			p.Poller = nil
+			p.GetNextMessage()
+			if client.block != tt.wantBlock {
+				t.Errorf("Provider.GetNextMessage() block = %v, want %v", client.block, tt.wantBlock)
+			}
+		})
+	}
+}
+
+func TestProvider_GetNextMessage_Claim(t *testing.T) {
+	client := newMockClient()
+	crashed := testProvider(t, client, "worker-1")
+	p := testProvider(t, client, "worker-2")
+
+	crashed.SendMessage(&message.Message{Title: "First"})
+	crashed.SendMessage(&message.Message{Title: "Second"})
+	if msg, _ := crashed.GetNextMessage(); msg == nil || msg.Title != "First" {
+		t.Fatalf("Provider.GetNextMessage() = %v", msg)
+	}
+
+	// Entries of other workers are not claimed while they are running.
+	client.now = client.now.Add(time.Minute)
+	msg, _ := p.GetNextMessage()
+	if msg == nil || msg.Title != "Second" {
+		t.Fatalf("Provider.GetNextMessage() = %v, want the second message", msg)
+	}
+	p.DeleteMessage(msg.ExternalRef)
+
+	// Entries of crashed workers are claimed once they have been idle for long enough.
+	client.now = client.now.Add(DefaultClaimIdle)
+	msg, err := p.GetNextMessage()
+	if err != nil || msg.Title != "First" {
+		t.Fatalf("Provider.GetNextMessage() = %v, %v, want the first message", msg, err)
+	}
+	if pending := client.pending["1-0"]; pending.consumer != "worker-2" || pending.deliveries != 2 {
+		t.Errorf("Provider.GetNextMessage() claimed for %v with %v deliveries", pending.consumer, pending.deliveries)
+	}
+}
+
+func TestProvider_GetNextMessage_DeadLetter(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client, "worker-1")
+	p.ClaimIdle = time.Minute
+	p.MaxRetries = 2
+	p.SendMessage(&message.Message{Title: "Crashes"})
+
+	// The first delivery and two retries.
+	for delivery := 1; delivery <= 3; delivery++ {
+		msg, err := p.GetNextMessage()
+		if err != nil || msg.Title != "Crashes" {
+			t.Fatalf("Provider.GetNextMessage() delivery %v = %v, %v", delivery, msg, err)
+		}
+		client.now = client.now.Add(time.Minute)
+	}
+
+	// The entry is dead-lettered instead of a third retry.
+	if msg, err := p.GetNextMessage(); msg != nil || err == nil {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want no message", msg, err)
+	}
+	if len(client.streams["audits"]) != 0 || len(client.pending) != 0 {
+		t.Errorf("Provider kept entries %v, pending %v", client.streams["audits"], client.pending)
+	}
+
+	dead := client.streams["audits:dead"]
+	if len(dead) != 1 {
+		t.Fatalf("Provider dead-lettered %v, want one entry", dead)
+	}
+	values := dead[0].Values
+	if values["id"] != "1-0" || values["deliveries"] != "3" || values["group"] != "workers" || values[messageField] == nil {
+		t.Errorf("Provider dead-lettered %v", values)
+	}
+}
+
+func TestProvider_GetNextMessage_Poller(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	client := newMockClient()
+	p := testProvider(t, client, "worker-1")
+	p.Poller = message.NewPoller(time.Second, 4*time.Second)
+
+	// Back off while the stream is idle.
+	for i := 0; i < 3; i++ {
+		p.GetNextMessage()
+	}
+
+	// Read without waiting once messages are flowing again.
+	p.SendMessage(&message.Message{})
+	p.SendMessage(&message.Message{})
+	p.GetNextMessage()
+	p.GetNextMessage()
+
+	want := []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 0}
+	if !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept = %v, want %v", slept, want)
+	}
+}
+
+func TestProvider_DeleteMessage(t *testing.T) {
+	client := newMockClient()
+	p := testProvider(t, client, "worker-1")
+	p.SendMessage(&message.Message{})
+	msg, _ := p.GetNextMessage()
+
+	if err := p.DeleteMessage(msg.ExternalRef); err != nil {
+		t.Fatalf("Provider.DeleteMessage() error = %v", err)
+	}
+	if len(client.streams["audits"]) != 0 || len(client.pending) != 0 {
+		t.Errorf("Provider.DeleteMessage() kept entries %v, pending %v", client.streams["audits"], client.pending)
+	}
+
+	if err := p.DeleteMessage(nil); err == nil {
+		t.Error("Provider.DeleteMessage() error = nil, want error")
+	}
+	client.err = errors.New("connection refused")
+	if err := p.DeleteMessage(msg.ExternalRef); err == nil {
+		t.Error("Provider.DeleteMessage() error = nil, want error")
+	}
+}
+
+func TestProvider_ReleaseMessage(t *testing.T) {
+	client := newMockClient()
+	released := testProvider(t, client, "worker-1")
+	p := testProvider(t, client, "worker-2")
+	released.SendMessage(&message.Message{Title: "Released"})
+	msg, _ := released.GetNextMessage()
+
+	// Released entries are claimed right away, as a retry.
+	if err := message.NewLeaseProvider(released).Nack(msg.ExternalRef, true); err != nil {
+		t.Fatalf("Provider.ReleaseMessage() error = %v", err)
+	}
+	got, err := p.GetNextMessage()
+	if err != nil || got.Title != "Released" {
+		t.Fatalf("Provider.GetNextMessage() = %v, %v, want the released message", got, err)
+	}
+	if pending := client.pending["1-0"]; pending.consumer != "worker-2" || pending.deliveries != 2 {
+		t.Errorf("Provider.GetNextMessage() claimed for %v with %v deliveries", pending.consumer, pending.deliveries)
+	}
+
+	p.DeleteMessage(got.ExternalRef)
+	if err := p.ReleaseMessage(got.ExternalRef); err == nil {
+		t.Error("Provider.ReleaseMessage() error = nil for a deleted entry")
+	}
+	if err := p.ReleaseMessage(nil); err == nil {
+		t.Error("Provider.ReleaseMessage() error = nil, want error")
+	}
+}
+
+func TestProvider_ExtendLease(t *testing.T) {
+	client := newMockClient()
+	worker := testProvider(t, client, "worker-1")
+	p := testProvider(t, client, "worker-2")
+	worker.SendMessage(&message.Message{Title: "Long Audit"})
+	msg, _ := worker.GetNextMessage()
+
+	client.now = client.now.Add(DefaultClaimIdle - time.Minute)
+	if err := worker.ExtendLease(msg.ExternalRef, 5*time.Minute); err != nil {
+		t.Fatalf("Provider.ExtendLease() error = %v", err)
+	}
+
+	// The entry is not claimed while the lease runs.
+	client.now = client.now.Add(4 * time.Minute)
+	if got, _ := p.GetNextMessage(); got != nil {
+		t.Fatalf("Provider.GetNextMessage() = %v during the lease", got)
+	}
+	client.now = client.now.Add(time.Minute)
+	if got, err := p.GetNextMessage(); err != nil || got.Title != "Long Audit" {
+		t.Errorf("Provider.GetNextMessage() = %v, %v, want the message once the lease expired", got, err)
+	}
+	if pending := client.pending["1-0"]; pending.deliveries != 2 {
+		t.Errorf("Provider.ExtendLease() counted %v deliveries, want 2", pending.deliveries)
+	}
+
+	// Leases are at most ClaimIdle long.
+	if err := p.ExtendLease(msg.ExternalRef, time.Hour); err != nil {
+		t.Fatalf("Provider.ExtendLease() error = %v", err)
+	}
+	if idle := client.now.Sub(client.pending["1-0"].delivered); idle != 0 {
+		t.Errorf("Provider.ExtendLease() idle = %v, want 0", idle)
+	}
+
+	client.err = errors.New("connection refused")
+	if err := p.ExtendLease(msg.ExternalRef, time.Minute); err == nil {
+		t.Error("Provider.ExtendLease() error = nil, want error")
+	}
+}
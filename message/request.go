@@ -0,0 +1,186 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Audit presets for NewAuditRequest.
+const (
+	PresetFull       = "full"        // Coding standards, compatibility, security, database and Lighthouse audits.
+	PresetCompatOnly = "compat-only" // PHP compatibility only.
+	PresetSecurity   = "security"    // Security and database audits.
+)
+
+// Defaults of an AuditRequest.
+const (
+	DefaultRequestClient = "wporg"
+	DefaultVisibility    = "public"
+)
+
+// Known values of the message fields, used by Validate.
+var (
+	ProjectTypes = []string{"plugin", "theme", "mu-plugin", "dropin"}
+	Visibilities = []string{"public", "private"}
+	AuditTypes   = []string{"phpcs", "lighthouse", "security", "database", "phplint", "themecheck", "plugincheck", "eslint", "stylelint"}
+)
+
+// AuditRequest describes an audit to request with NewAuditRequest.
+type AuditRequest struct {
+	Title               string
+	Slug                string
+	SourceURL           string
+	ResponseAPIEndpoint string   // Endpoint the results are sent to.
+	SourceType          string   // (Optional) e.g. "zip" or "svn". Defaults to the extension of SourceURL.
+	ProjectType         string   // (Optional) "plugin", "theme", "mu-plugin" or "dropin".
+	RequestClient       string   // (Optional) Defaults to DefaultRequestClient.
+	Visibility          string   // (Optional) Defaults to DefaultVisibility.
+	PayloadType         string   // (Optional) e.g. "tide" or "webhook".
+	Locale              string   // (Optional) Locale of translated report messages, e.g. "de_DE".
+	Force               bool     // (Optional) Audit even if there are results for the checksum.
+	Preset              string   // (Optional) Audits to run. Defaults to PresetFull.
+	Audits              []*Audit // (Optional) Audits to run in addition to the preset.
+}
+
+// PresetAudits returns the audits of a preset.
+func PresetAudits(preset string) ([]*Audit, error) {
+	phpcs := func(standard string) *Audit {
+		return &Audit{
+			Type:    "phpcs",
+			Options: &AuditOption{Standard: standard, Report: "json"},
+		}
+	}
+
+	switch preset {
+	case PresetFull, "":
+		return []*Audit{
+			phpcs("wordpress"),
+			phpcs("phpcompatibility"),
+			{Type: "security"},
+			{Type: "database"},
+			{Type: "lighthouse"},
+		}, nil
+	case PresetCompatOnly:
+		return []*Audit{phpcs("phpcompatibility")}, nil
+	case PresetSecurity:
+		return []*Audit{{Type: "security"}, {Type: "database"}}, nil
+	}
+	return nil, errors.New("unknown audit preset: " + preset)
+}
+
+// NewAuditRequest returns a message for the request that is ready to send, with the defaults
+// filled in and the audits of the preset. It returns an error if the message is not valid.
+func NewAuditRequest(req AuditRequest) (*Message, error) {
+	audits, err := PresetAudits(req.Preset)
+	if err != nil {
+		return nil, err
+	}
+	audits = append(audits, req.Audits...)
+
+	msg := &Message{
+		ResponseAPIEndpoint: req.ResponseAPIEndpoint,
+		PayloadType:         req.PayloadType,
+		Title:               req.Title,
+		Slug:                req.Slug,
+		ProjectType:         req.ProjectType,
+		SourceURL:           req.SourceURL,
+		SourceType:          req.SourceType,
+		RequestClient:       req.RequestClient,
+		Force:               req.Force,
+		Visibility:          req.Visibility,
+		Locale:              req.Locale,
+		Audits:              audits,
+	}
+
+	if msg.SourceType == "" {
+		msg.SourceType = sourceType(msg.SourceURL)
+	}
+	if msg.RequestClient == "" {
+		msg.RequestClient = DefaultRequestClient
+	}
+	if msg.Visibility == "" {
+		msg.Visibility = DefaultVisibility
+	}
+
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// Validate checks that the message has the required fields and that the fields with known
+// values have one of them.
+func (m Message) Validate() error {
+	if m.Title == "" {
+		return errors.New("message does not have a title")
+	}
+
+	if err := validateURL(m.ResponseAPIEndpoint, "endpoint"); err != nil {
+		return errors.New(m.Title + ": " + err.Error())
+	}
+
+	if err := validateURL(m.SourceURL, "source url"); err != nil {
+		return errors.New(m.Title + ": " + err.Error())
+	}
+
+	if m.SourceType == "" {
+		return errors.New(m.Title + ": source type is empty (e.g. zip, git)")
+	}
+
+	if m.ProjectType != "" && !contains(ProjectTypes, m.ProjectType) {
+		return fmt.Errorf("%s: unknown project type %q", m.Title, m.ProjectType)
+	}
+
+	if m.Visibility != "" && !contains(Visibilities, m.Visibility) {
+		return fmt.Errorf("%s: unknown visibility %q", m.Title, m.Visibility)
+	}
+
+	for _, audit := range m.Audits {
+		if audit == nil {
+			return errors.New(m.Title + ": audit is empty")
+		}
+		if !contains(AuditTypes, audit.Type) {
+			return fmt.Errorf("%s: unknown audit type %q", m.Title, audit.Type)
+		}
+		if audit.Type == "phpcs" && (audit.Options == nil || audit.Options.Standard == "") {
+			return errors.New(m.Title + ": phpcs audit does not have a standard")
+		}
+	}
+
+	return nil
+}
+
+// validateURL checks that rawurl is an absolute url.
+func validateURL(rawurl, name string) error {
+	if rawurl == "" {
+		return errors.New(name + " is empty")
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("%s %q is not an absolute url", name, rawurl)
+	}
+	return nil
+}
+
+// sourceType returns the extension of the url's path, e.g. "zip" for "https://example.com/plugin.zip".
+func sourceType(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+}
+
+// contains determines if the value is one of the values.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package message
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewAuditRequest(t *testing.T) {
+	compat := &Audit{Type: "phpcs", Options: &AuditOption{Standard: "phpcompatibility", Report: "json"}}
+
+	tests := []struct {
+		name    string
+		req     AuditRequest
+		want    *Message
+		wantErr bool
+	}{
+		{
+			"Defaults",
+			AuditRequest{
+				Title:               "Plugin",
+				Slug:                "plugin",
+				SourceURL:           "https://downloads.wordpress.org/plugin/plugin.1.0.0.zip",
+				ResponseAPIEndpoint: "https://api.example.com/v1/audit",
+				Preset:              PresetCompatOnly,
+			},
+			&Message{
+				Title:               "Plugin",
+				Slug:                "plugin",
+				SourceURL:           "https://downloads.wordpress.org/plugin/plugin.1.0.0.zip",
+				SourceType:          "zip",
+				ResponseAPIEndpoint: "https://api.example.com/v1/audit",
+				RequestClient:       DefaultRequestClient,
+				Visibility:          DefaultVisibility,
+				Audits:              []*Audit{compat},
+			},
+			false,
+		},
+		{
+			"Preset And Audits",
+			AuditRequest{
+				Title:               "Theme",
+				SourceURL:           "https://themes.svn.wordpress.org/theme",
+				SourceType:          "svn",
+				ProjectType:         "theme",
+				ResponseAPIEndpoint: "https://api.example.com/v1/audit",
+				RequestClient:       "tide",
+				Visibility:          "private",
+				Force:               true,
+				Preset:              PresetSecurity,
+				Audits:              []*Audit{{Type: "lighthouse"}},
+			},
+			&Message{
+				Title:               "Theme",
+				SourceURL:           "https://themes.svn.wordpress.org/theme",
+				SourceType:          "svn",
+				ProjectType:         "theme",
+				ResponseAPIEndpoint: "https://api.example.com/v1/audit",
+				RequestClient:       "tide",
+				Visibility:          "private",
+				Force:               true,
+				Audits:              []*Audit{{Type: "security"}, {Type: "database"}, {Type: "lighthouse"}},
+			},
+			false,
+		},
+		{
+			"Missing Endpoint",
+			AuditRequest{
+				Title:     "Plugin",
+				SourceURL: "https://example.com/plugin.zip",
+			},
+			nil,
+			true,
+		},
+		{
+			"Unknown Preset",
+			AuditRequest{
+				Title:               "Plugin",
+				SourceURL:           "https://example.com/plugin.zip",
+				ResponseAPIEndpoint: "https://api.example.com/v1/audit",
+				Preset:              "everything",
+			},
+			nil,
+			true,
+		},
+		{
+			"Unknown Source Type",
+			AuditRequest{
+				Title:               "Plugin",
+				SourceURL:           "https://example.com/plugin",
+				ResponseAPIEndpoint: "https://api.example.com/v1/audit",
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewAuditRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewAuditRequest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewAuditRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPresetAudits(t *testing.T) {
+	full, err := PresetAudits(PresetFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []string
+	for _, audit := range full {
+		kind := audit.Type
+		if audit.Options != nil {
+			kind += "_" + audit.Options.Standard
+		}
+		kinds = append(kinds, kind)
+	}
+	want := []string{"phpcs_wordpress", "phpcs_phpcompatibility", "security", "database", "lighthouse"}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("PresetAudits() = %v, want %v", kinds, want)
+	}
+
+	// Presets must not share audits between messages.
+	again, _ := PresetAudits(PresetFull)
+	again[0].Options.Standard = "changed"
+	if full[0].Options.Standard != "wordpress" {
+		t.Error("PresetAudits() returned shared audits")
+	}
+}
+
+func TestMessage_Validate(t *testing.T) {
+	valid := Message{
+		Title:               "Plugin",
+		SourceURL:           "https://example.com/plugin.zip",
+		SourceType:          "zip",
+		ResponseAPIEndpoint: "https://api.example.com/v1/audit",
+	}
+	with := func(fn func(m *Message)) Message {
+		m := valid
+		fn(&m)
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		msg     Message
+		wantErr bool
+	}{
+		{"Valid", valid, false},
+		{"No Title", with(func(m *Message) { m.Title = "" }), true},
+		{"Relative Endpoint", with(func(m *Message) { m.ResponseAPIEndpoint = "/v1/audit" }), true},
+		{"No Source URL", with(func(m *Message) { m.SourceURL = "" }), true},
+		{"No Source Type", with(func(m *Message) { m.SourceType = "" }), true},
+		{"Unknown Project Type", with(func(m *Message) { m.ProjectType = "library" }), true},
+		{"Unknown Visibility", with(func(m *Message) { m.Visibility = "hidden" }), true},
+		{"Unknown Audit", with(func(m *Message) { m.Audits = []*Audit{{Type: "phpstan"}} }), true},
+		{"Nil Audit", with(func(m *Message) { m.Audits = []*Audit{nil} }), true},
+		{"Phpcs Without Standard", with(func(m *Message) { m.Audits = []*Audit{{Type: "phpcs"}} }), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.msg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Message.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
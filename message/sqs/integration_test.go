@@ -0,0 +1,42 @@
+//go:build integration
+// +build integration
+
+package sqs
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/wptide/pkg/message/messagetest"
+	"github.com/wptide/pkg/testenv"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	sess := testenv.AWSSession(t, testenv.LocalStack(t, "sqs"))
+
+	tests := []struct {
+		name       string
+		queue      string
+		attributes map[string]*string
+	}{
+		{"Standard Queue", "tide-audits", nil},
+		{"FIFO Queue", "tide-audits.fifo", map[string]*string{
+			sqs.QueueAttributeNameFifoQueue:                 aws.String("true"),
+			sqs.QueueAttributeNameContentBasedDeduplication: aws.String("true"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sqs.New(sess).CreateQueue(&sqs.CreateQueueInput{
+				QueueName:  aws.String(tt.queue),
+				Attributes: tt.attributes,
+			})
+			if err != nil {
+				t.Fatalf("could not create queue: %v", err)
+			}
+
+			messagetest.Run(t, NewSqsProviderWithSession(sess, tt.queue), messagetest.Options{})
+		})
+	}
+}
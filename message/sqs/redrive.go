@@ -0,0 +1,142 @@
+package sqs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// redrivePolicy is the RedrivePolicy attribute of a queue.
+type redrivePolicy struct {
+	DeadLetterTargetArn string `json:"deadLetterTargetArn"`
+	MaxReceiveCount     int    `json:"maxReceiveCount,string"`
+}
+
+// SetDeadLetterQueue makes SQS move messages that were received more than maxReceiveCount
+// times to the dead-letter queue, e.g. the messages of audits that keep failing or crashing
+// workers. The dead-letter queue must exist, and be a FIFO queue if the queue is one.
+func (mgr Provider) SetDeadLetterQueue(queue string, maxReceiveCount int) error {
+	if maxReceiveCount < 1 {
+		return errors.New("maxReceiveCount must be at least 1")
+	}
+
+	dlqURL, err := getQueueURL(mgr.sqs, queue)
+	if err != nil {
+		return err
+	}
+
+	attributes, err := mgr.sqs.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(dlqURL),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameQueueArn)},
+	})
+	if err != nil {
+		return err
+	}
+	arn := aws.StringValue(attributes.Attributes[sqs.QueueAttributeNameQueueArn])
+	if arn == "" {
+		return errors.New("could not get the ARN of " + queue)
+	}
+
+	policy, _ := json.Marshal(redrivePolicy{DeadLetterTargetArn: arn, MaxReceiveCount: maxReceiveCount})
+
+	_, err = mgr.sqs.SetQueueAttributes(&sqs.SetQueueAttributesInput{
+		QueueUrl: mgr.QueueURL,
+		Attributes: map[string]*string{
+			sqs.QueueAttributeNameRedrivePolicy: aws.String(string(policy)),
+		},
+	})
+	return err
+}
+
+// Redrive moves up to max messages from the dead-letter queue back to the queue, e.g. once the
+// cause of their failures has been fixed. A max below 1 moves all messages. Messages keep their
+// body, attributes and message group. It returns the number of messages moved.
+func (mgr Provider) Redrive(queue string, max int) (int, error) {
+	dlqURL, err := getQueueURL(mgr.sqs, queue)
+	if err != nil {
+		return 0, err
+	}
+
+	fifo := strings.HasSuffix(aws.StringValue(mgr.QueueName), ".fifo")
+
+	moved := 0
+	for max < 1 || moved < max {
+		n := maxBatchSize
+		if max > 0 && max-moved < n {
+			n = max - moved
+		}
+
+		messages, err := mgr.receiveAll(aws.String(dlqURL), n)
+		if err != nil {
+			return moved, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		input := &sqs.SendMessageBatchInput{QueueUrl: mgr.QueueURL}
+		for i, msg := range messages {
+			entry := &sqs.SendMessageBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				MessageBody:       msg.Body,
+				MessageAttributes: msg.MessageAttributes,
+			}
+			if fifo {
+				entry.MessageGroupId = msg.Attributes[sqs.MessageSystemAttributeNameMessageGroupId]
+				entry.MessageDeduplicationId = msg.MessageId
+			}
+			input.Entries = append(input.Entries, entry)
+		}
+
+		result, err := mgr.sqs.SendMessageBatch(input)
+		if err != nil {
+			return moved, err
+		}
+
+		// Only messages that were sent are deleted, the others return to the dead-letter queue.
+		var sent []*string
+		for _, entry := range result.Successful {
+			i, err := strconv.Atoi(aws.StringValue(entry.Id))
+			if err != nil || i < 0 || i >= len(messages) {
+				continue
+			}
+			sent = append(sent, messages[i].ReceiptHandle)
+		}
+		if err := mgr.deleteBatch(aws.String(dlqURL), sent); err != nil {
+			return moved, err
+		}
+		moved += len(sent)
+
+		if len(result.Failed) != 0 {
+			return moved, fmt.Errorf("could not redrive %d messages: %s", len(result.Failed), aws.StringValue(result.Failed[0].Message))
+		}
+	}
+
+	return moved, nil
+}
+
+// receiveAll receives up to max messages from the queue with the attributes needed to send
+// them again. It doesn't long poll, the queue is done once a receive is empty.
+func (mgr Provider) receiveAll(queueURL *string, max int) ([]*sqs.Message, error) {
+	result, err := mgr.sqs.ReceiveMessage(&sqs.ReceiveMessageInput{
+		AttributeNames: []*string{
+			aws.String(sqs.MessageSystemAttributeNameMessageGroupId),
+		},
+		MessageAttributeNames: []*string{
+			aws.String(sqs.QueueAttributeNameAll),
+		},
+		QueueUrl:            queueURL,
+		MaxNumberOfMessages: aws.Int64(int64(max)),
+		VisibilityTimeout:   aws.Int64(seconds(mgr.visibilityTimeout())),
+		WaitTimeSeconds:     aws.Int64(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Messages, nil
+}
@@ -0,0 +1,133 @@
+package sqs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestSqsProvider_SetDeadLetterQueue(t *testing.T) {
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+
+	if err := mgr.SetDeadLetterQueue("test-dlq", 5); err != nil {
+		t.Fatalf("Provider.SetDeadLetterQueue() error = %v", err)
+	}
+
+	want := `{"deadLetterTargetArn":"arn:aws:sqs:us-west-2:000000000000:test-dlq","maxReceiveCount":"5"}`
+	policy := svc.attributes[*mgr.QueueURL][sqs.QueueAttributeNameRedrivePolicy]
+	if aws.StringValue(policy) != want {
+		t.Errorf("Provider.SetDeadLetterQueue() policy = %v, want %v", aws.StringValue(policy), want)
+	}
+
+	if err := mgr.SetDeadLetterQueue("test-dlq", 0); err == nil {
+		t.Error("Provider.SetDeadLetterQueue() error = nil, want error for maxReceiveCount 0")
+	}
+	if err := mgr.SetDeadLetterQueue("missing", 5); err == nil {
+		t.Error("Provider.SetDeadLetterQueue() error = nil, want error for a missing queue")
+	}
+}
+
+func TestSqsProvider_Redrive(t *testing.T) {
+	tests := []struct {
+		name      string
+		queue     string
+		bodies    []string
+		max       int
+		wantMoved int
+		wantLeft  int
+		wantErr   bool
+	}{
+		{
+			name:      "All Messages",
+			queue:     "test",
+			bodies:    []string{"1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"},
+			wantMoved: 12,
+		},
+		{
+			name:      "Max Messages",
+			queue:     "test",
+			bodies:    []string{"1", "2", "3"},
+			max:       2,
+			wantMoved: 2,
+			wantLeft:  1,
+		},
+		{
+			name:      "FIFO Queue",
+			queue:     "test.fifo",
+			bodies:    []string{"1", "2"},
+			wantMoved: 2,
+		},
+		{
+			name:      "Failed Messages",
+			queue:     "test",
+			bodies:    []string{"1", "FAIL", "3"},
+			wantMoved: 2,
+			wantErr:   true,
+		},
+		{
+			name:    "Missing Queue",
+			queue:   "missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := newMemorySqs()
+			mgr := memoryProvider(svc, tt.queue)
+			dlqURL := "http://sqsurl/" + tt.queue + "-dlq"
+			svc.add(dlqURL, tt.bodies...)
+
+			dlq := tt.queue + "-dlq"
+			if tt.queue == "missing" {
+				dlq = "missing"
+			}
+
+			moved, err := mgr.Redrive(dlq, tt.max)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Provider.Redrive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if moved != tt.wantMoved {
+				t.Errorf("Provider.Redrive() moved = %v, want %v", moved, tt.wantMoved)
+			}
+			if got := len(svc.queues[*mgr.QueueURL]); got != tt.wantMoved {
+				t.Errorf("Provider.Redrive() queue has %v messages, want %v", got, tt.wantMoved)
+			}
+			if got := len(svc.queues[dlqURL]); got != tt.wantLeft {
+				t.Errorf("Provider.Redrive() dead-letter queue has %v messages, want %v", got, tt.wantLeft)
+			}
+			if len(svc.deleted) != tt.wantMoved {
+				t.Errorf("Provider.Redrive() deleted %v, want %v messages", svc.deleted, tt.wantMoved)
+			}
+
+			// FIFO messages keep their group, and are deduplicated by their original ID.
+			for _, msg := range svc.queues[*mgr.QueueURL] {
+				group := aws.StringValue(msg.Attributes[sqs.MessageSystemAttributeNameMessageGroupId])
+				if tt.queue == "test.fifo" && (group == "" || group != "group-"+aws.StringValue(msg.MessageId)) {
+					t.Errorf("Provider.Redrive() message %v group = %v", aws.StringValue(msg.MessageId), group)
+				}
+				if tt.queue != "test.fifo" && group != "" {
+					t.Errorf("Provider.Redrive() message group = %v, want none", group)
+				}
+			}
+		})
+	}
+}
+
+func TestSqsProvider_Redrive_Batches(t *testing.T) {
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+	svc.add("http://sqsurl/test-dlq", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12")
+
+	mgr.Redrive("test-dlq", 0)
+
+	// Receives stop at the first empty one.
+	if want := []int64{10, 10, 10}; !reflect.DeepEqual(svc.receives, want) {
+		t.Errorf("Provider.Redrive() receives = %v, want %v", svc.receives, want)
+	}
+	if want := []int{10, 2}; !reflect.DeepEqual(svc.batches, want) {
+		t.Errorf("Provider.Redrive() delete batches = %v, want %v", svc.batches, want)
+	}
+}
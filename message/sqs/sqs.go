@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -22,8 +24,27 @@ type Provider struct {
 	sqs       sqsiface.SQSAPI
 	QueueURL  *string
 	QueueName *string
+	Poller    *message.Poller // (Optional) Long polls for longer while the queue is idle.
+	WaitTime  time.Duration   // (Optional) Long polls for up to 20 seconds without a Poller, so that empty receives return less often.
+
+	VisibilityTimeout time.Duration // (Optional) How long received messages are hidden from other workers. Defaults to 10 minutes.
+	AutoExtend        bool          // (Optional) Extends the visibility timeout of received messages until they are deleted or released.
+
+	extensions *message.Extensions // Running visibility timeout extensions.
 }
 
+// maxWaitTime is the longest long poll allowed by SQS.
+const maxWaitTime = 20 * time.Second
+
+// maxBatchSize is the most messages SQS receives, sends or deletes in one request.
+const maxBatchSize = 10
+
+// defaultVisibilityTimeout hides received messages for long enough for most audits.
+const defaultVisibilityTimeout = 600 * time.Second
+
+// sleep waits between polls when the poller delay is longer than a long poll.
+var sleep = time.Sleep
+
 // SendMessage implements the required interface method to be a Provider.
 // This method sends a new SQS SendMessageInput message to SQS.
 func (mgr Provider) SendMessage(msg *message.Message) error {
@@ -57,9 +78,85 @@ func (mgr Provider) SendMessage(msg *message.Message) error {
 
 // GetNextMessage implements the required interface method to be a Provider.
 // This method sends a ReceiveMessageInput message to SQS and converts the message into a *task.Task object.
+//
+// With a Poller the request long polls for the poller delay, so an idle queue is polled
+// less often. Delays longer than SQS allows are made up by sleeping before the request.
 func (mgr Provider) GetNextMessage() (*message.Message, error) {
 	var returnMessage message.Message
 
+	messages, err := mgr.receive(mgr.QueueURL, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Attempt to unmarshal the message body into the returnTask.
+	if len(messages) != 0 {
+		body := messages[0].Body
+		err = json.Unmarshal([]byte(*body), &returnMessage)
+
+		// Return the queue receipt so that the message can be deleted.
+		returnMessage.ExternalRef = messages[0].ReceiptHandle
+		if err == nil {
+			mgr.autoExtend(returnMessage.ExternalRef)
+		}
+		return &returnMessage, err
+	}
+
+	return nil, errors.New("could not retrieve message")
+}
+
+// ReceiveMessages receives up to max messages in one request, at most 10. An empty queue
+// returns no messages and no error.
+//
+// Messages that can't be decoded are left in the queue, to be moved to the dead-letter queue
+// once they have been received too often, see SetDeadLetterQueue.
+func (mgr Provider) ReceiveMessages(max int) ([]*message.Message, error) {
+	received, err := mgr.receive(mgr.QueueURL, max)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []*message.Message{}
+	for _, msg := range received {
+		var decoded message.Message
+		if msg.Body == nil || json.Unmarshal([]byte(*msg.Body), &decoded) != nil {
+			continue
+		}
+
+		decoded.ExternalRef = msg.ReceiptHandle
+		mgr.autoExtend(decoded.ExternalRef)
+		messages = append(messages, &decoded)
+	}
+	return messages, nil
+}
+
+// ReceiveWait implements message.Waiter. With a Poller, receives long poll for the poller delay
+// instead of the WaitTime.
+func (mgr Provider) ReceiveWait() time.Duration {
+	if mgr.Poller != nil {
+		return mgr.Poller.MaxDelay()
+	}
+	return mgr.WaitTime
+}
+
+// receive receives up to max messages from the queue, long polling when the queue is idle.
+func (mgr Provider) receive(queueURL *string, max int) ([]*sqs.Message, error) {
+	if max < 1 {
+		max = 1
+	}
+	if max > maxBatchSize {
+		max = maxBatchSize
+	}
+
+	wait := mgr.WaitTime
+	if mgr.Poller != nil {
+		wait = mgr.Poller.Delay()
+	}
+	if wait > maxWaitTime {
+		sleep(wait - maxWaitTime)
+		wait = maxWaitTime
+	}
+
 	// Prepare the message
 	messageInput := &sqs.ReceiveMessageInput{
 		AttributeNames: []*string{
@@ -68,14 +165,15 @@ func (mgr Provider) GetNextMessage() (*message.Message, error) {
 		MessageAttributeNames: []*string{
 			aws.String(sqs.QueueAttributeNameAll),
 		},
-		QueueUrl:            mgr.QueueURL,
-		MaxNumberOfMessages: aws.Int64(1),
-		VisibilityTimeout:   aws.Int64(600), // 600 seconds : 10 minutes
-		WaitTimeSeconds:     aws.Int64(0),
+		QueueUrl:            queueURL,
+		MaxNumberOfMessages: aws.Int64(int64(max)),
+		VisibilityTimeout:   aws.Int64(seconds(mgr.visibilityTimeout())),
+		WaitTimeSeconds:     aws.Int64(seconds(wait)),
 	}
 
 	// Retrieve the message from SQS
 	result, err := mgr.sqs.ReceiveMessage(messageInput)
+	mgr.Poller.Polled(err == nil && len(result.Messages) != 0)
 
 	if err != nil {
 		// If we get a critical AWS error, issue a new provider error.
@@ -92,22 +190,14 @@ func (mgr Provider) GetNextMessage() (*message.Message, error) {
 		return nil, err
 	}
 
-	// Attempt to unmarshal the message body into the returnTask.
-	if len(result.Messages) != 0 {
-		body := result.Messages[0].Body
-		err = json.Unmarshal([]byte(*body), &returnMessage)
-
-		// Return the queue receipt so that the message can be deleted.
-		returnMessage.ExternalRef = result.Messages[0].ReceiptHandle
-		return &returnMessage, err
-	}
-
-	return nil, errors.New("could not retrieve message")
+	return result.Messages, nil
 }
 
 // DeleteMessage implements the required interface method to be a Provider.
 // This method deletes a message from the queue.
 func (mgr Provider) DeleteMessage(reference *string) error {
+	mgr.stopExtending(reference)
+
 	_, err := mgr.sqs.DeleteMessage(&sqs.DeleteMessageInput{
 		QueueUrl:      mgr.QueueURL,
 		ReceiptHandle: reference,
@@ -120,8 +210,65 @@ func (mgr Provider) DeleteMessage(reference *string) error {
 	return nil
 }
 
-// Close implemented to satisfy Provider interface.
+// DeleteMessages deletes messages from the queue, up to 10 per request.
+func (mgr Provider) DeleteMessages(references []*string) error {
+	for _, reference := range references {
+		mgr.stopExtending(reference)
+	}
+	return mgr.deleteBatch(mgr.QueueURL, references)
+}
+
+// deleteBatch deletes the messages with the receipt handles from the queue, in batches.
+func (mgr Provider) deleteBatch(queueURL *string, references []*string) error {
+	var failed []string
+
+	for len(references) != 0 {
+		n := len(references)
+		if n > maxBatchSize {
+			n = maxBatchSize
+		}
+
+		input := &sqs.DeleteMessageBatchInput{QueueUrl: queueURL}
+		for i, reference := range references[:n] {
+			input.Entries = append(input.Entries, &sqs.DeleteMessageBatchRequestEntry{
+				Id:            aws.String(strconv.Itoa(i)),
+				ReceiptHandle: reference,
+			})
+		}
+		references = references[n:]
+
+		result, err := mgr.sqs.DeleteMessageBatch(input)
+		if err != nil {
+			return err
+		}
+		for _, entry := range result.Failed {
+			failed = append(failed, aws.StringValue(entry.Message))
+		}
+	}
+
+	if len(failed) != 0 {
+		return fmt.Errorf("could not delete %d messages: %s", len(failed), failed[0])
+	}
+	return nil
+}
+
+// visibilityTimeout returns how long received messages are hidden from other workers.
+func (mgr Provider) visibilityTimeout() time.Duration {
+	if mgr.VisibilityTimeout > 0 {
+		return mgr.VisibilityTimeout
+	}
+	return defaultVisibilityTimeout
+}
+
+// seconds returns the duration in whole seconds, as SQS expects them.
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// Close stops extending the visibility timeout of received messages, so that the messages
+// that were not deleted are delivered again.
 func (mgr Provider) Close() error {
+	mgr.extensions.StopAll()
 	return nil
 }
 
@@ -151,13 +298,20 @@ func getSession(region, key, secret string) (*session.Session, error) {
 func NewSqsProvider(region, key, secret, queue string) *Provider {
 
 	sess, _ := getSession(region, key, secret)
+	return NewSqsProviderWithSession(sess, queue)
+}
+
+// NewSqsProviderWithSession returns a new *Provider instance for a session, e.g. one with
+// the endpoint of a local SQS service.
+func NewSqsProviderWithSession(sess *session.Session, queue string) *Provider {
 	svc := sqs.New(sess)
 	queueURL, _ := getQueueURL(svc, queue)
 
 	return &Provider{
-		session:   sess,
-		sqs:       svc,
-		QueueURL:  &queueURL,
-		QueueName: &queue,
+		session:    sess,
+		sqs:        svc,
+		QueueURL:   &queueURL,
+		QueueName:  &queue,
+		extensions: message.NewExtensions(),
 	}
 }
@@ -4,8 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/sqs"
@@ -17,6 +22,7 @@ type mockSqs struct {
 	sqsiface.SQSAPI
 	sendMessageOutput   *sqs.SendMessageOutput
 	deleteMessageOutput *sqs.DeleteMessageOutput
+	waits               *[]int64
 }
 
 var (
@@ -93,6 +99,10 @@ func (m mockSqs) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessag
 
 	var messages []*sqs.Message
 
+	if m.waits != nil {
+		*m.waits = append(*m.waits, *in.WaitTimeSeconds)
+	}
+
 	switch *in.QueueUrl {
 	case failQueueURL:
 		return nil, awserr.New("Provider Error", "Provider Error", errors.New("provider error"))
@@ -147,6 +157,154 @@ func (m mockSqs) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput,
 	}, nil
 }
 
+// memorySqs is an in-memory SQS service with a queue of messages per URL. Received messages
+// are removed from their queue.
+type memorySqs struct {
+	sqsiface.SQSAPI
+	sync.Mutex
+	queues     map[string][]*sqs.Message
+	nextID     int
+	receives   []int64            // MaxNumberOfMessages of each receive.
+	batches    []int              // Size of each delete batch.
+	deleted    []string           // Receipt handles of the deleted messages.
+	visibility map[string][]int64 // Visibility timeouts set, by receipt handle.
+	attributes map[string]map[string]*string
+}
+
+func newMemorySqs() *memorySqs {
+	return &memorySqs{
+		queues:     make(map[string][]*sqs.Message),
+		visibility: make(map[string][]int64),
+		attributes: make(map[string]map[string]*string),
+	}
+}
+
+// add adds messages with the bodies to the queue.
+func (m *memorySqs) add(queueURL string, bodies ...string) {
+	m.Lock()
+	defer m.Unlock()
+	for _, body := range bodies {
+		m.nextID++
+		id := "id-" + strconv.Itoa(m.nextID)
+		m.queues[queueURL] = append(m.queues[queueURL], &sqs.Message{
+			MessageId:     aws.String(id),
+			ReceiptHandle: aws.String("receipt-" + id),
+			Body:          aws.String(body),
+			Attributes:    map[string]*string{sqs.MessageSystemAttributeNameMessageGroupId: aws.String("group-" + id)},
+		})
+	}
+}
+
+// timeouts returns the visibility timeouts set for the message.
+func (m *memorySqs) timeouts(receipt string) []int64 {
+	m.Lock()
+	defer m.Unlock()
+	return append([]int64(nil), m.visibility[receipt]...)
+}
+
+func (m *memorySqs) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.receives = append(m.receives, *in.MaxNumberOfMessages)
+
+	queue := m.queues[*in.QueueUrl]
+	n := int(*in.MaxNumberOfMessages)
+	if n > len(queue) {
+		n = len(queue)
+	}
+	m.queues[*in.QueueUrl] = queue[n:]
+	return &sqs.ReceiveMessageOutput{Messages: queue[:n]}, nil
+}
+
+func (m *memorySqs) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.deleted = append(m.deleted, *in.ReceiptHandle)
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (m *memorySqs) DeleteMessageBatch(in *sqs.DeleteMessageBatchInput) (*sqs.DeleteMessageBatchOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.batches = append(m.batches, len(in.Entries))
+
+	out := &sqs.DeleteMessageBatchOutput{}
+	for _, entry := range in.Entries {
+		if strings.HasPrefix(*entry.ReceiptHandle, "fail") {
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: entry.Id, Message: aws.String("invalid receipt handle")})
+			continue
+		}
+		m.deleted = append(m.deleted, *entry.ReceiptHandle)
+		out.Successful = append(out.Successful, &sqs.DeleteMessageBatchResultEntry{Id: entry.Id})
+	}
+	return out, nil
+}
+
+func (m *memorySqs) SendMessageBatch(in *sqs.SendMessageBatchInput) (*sqs.SendMessageBatchOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	out := &sqs.SendMessageBatchOutput{}
+	for _, entry := range in.Entries {
+		if *entry.MessageBody == "FAIL" {
+			out.Failed = append(out.Failed, &sqs.BatchResultErrorEntry{Id: entry.Id, Message: aws.String("something went wrong")})
+			continue
+		}
+		m.queues[*in.QueueUrl] = append(m.queues[*in.QueueUrl], &sqs.Message{
+			MessageId:  entry.MessageDeduplicationId,
+			Body:       entry.MessageBody,
+			Attributes: map[string]*string{sqs.MessageSystemAttributeNameMessageGroupId: entry.MessageGroupId},
+		})
+		out.Successful = append(out.Successful, &sqs.SendMessageBatchResultEntry{Id: entry.Id})
+	}
+	return out, nil
+}
+
+func (m *memorySqs) ChangeMessageVisibility(in *sqs.ChangeMessageVisibilityInput) (*sqs.ChangeMessageVisibilityOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+	for _, deleted := range m.deleted {
+		if deleted == *in.ReceiptHandle {
+			return nil, awserr.New(sqs.ErrCodeReceiptHandleIsInvalid, "The receipt handle has expired.", nil)
+		}
+	}
+	m.visibility[*in.ReceiptHandle] = append(m.visibility[*in.ReceiptHandle], *in.VisibilityTimeout)
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (m *memorySqs) GetQueueUrl(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	if *in.QueueName == "missing" {
+		return nil, awserr.New(sqs.ErrCodeQueueDoesNotExist, "The specified queue does not exist.", nil)
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("http://sqsurl/" + *in.QueueName)}, nil
+}
+
+func (m *memorySqs) GetQueueAttributes(in *sqs.GetQueueAttributesInput) (*sqs.GetQueueAttributesOutput, error) {
+	name := strings.TrimPrefix(*in.QueueUrl, "http://sqsurl/")
+	return &sqs.GetQueueAttributesOutput{Attributes: map[string]*string{
+		sqs.QueueAttributeNameQueueArn: aws.String("arn:aws:sqs:us-west-2:000000000000:" + name),
+	}}, nil
+}
+
+func (m *memorySqs) SetQueueAttributes(in *sqs.SetQueueAttributesInput) (*sqs.SetQueueAttributesOutput, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.attributes[*in.QueueUrl] = in.Attributes
+	return &sqs.SetQueueAttributesOutput{}, nil
+}
+
+// memoryProvider returns a provider of the test queue of the service.
+func memoryProvider(svc *memorySqs, queue string) Provider {
+	queueURL := "http://sqsurl/" + queue
+	return Provider{
+		session:    &session.Session{},
+		sqs:        svc,
+		QueueName:  &queue,
+		QueueURL:   &queueURL,
+		extensions: message.NewExtensions(),
+	}
+}
+
 func TestSqsProvider_SendMessage(t *testing.T) {
 	type args struct {
 		msg *message.Message
@@ -247,6 +405,146 @@ func TestSqsProvider_GetNextMessage(t *testing.T) {
 	}
 }
 
+func TestSqsProvider_GetNextMessage_Poller(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	var waits []int64
+	mgr := emptyProvider
+	mgr.sqs = &mockSqs{waits: &waits}
+	mgr.Poller = message.NewPoller(10*time.Second, 40*time.Second)
+
+	// Back off while the queue is empty.
+	for i := 0; i < 4; i++ {
+		mgr.GetNextMessage()
+	}
+
+	// Poll without waiting once messages are flowing again.
+	mgr.QueueURL = testProvider.QueueURL
+	mgr.GetNextMessage()
+	mgr.GetNextMessage()
+
+	if want := []int64{0, 10, 20, 20, 20, 0}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("Provider.GetNextMessage() waits = %v, want %v", waits, want)
+	}
+	if want := []time.Duration{20 * time.Second, 20 * time.Second}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept = %v, want %v", slept, want)
+	}
+}
+
+func TestSqsProvider_GetNextMessage_WaitTime(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(d time.Duration) { slept = append(slept, d) }
+	defer func() { sleep = time.Sleep }()
+
+	var waits []int64
+	mgr := emptyProvider
+	mgr.sqs = &mockSqs{waits: &waits}
+
+	for _, wait := range []time.Duration{5 * time.Second, 30 * time.Second} {
+		mgr.WaitTime = wait
+		mgr.GetNextMessage()
+	}
+
+	if want := []int64{5, 20}; !reflect.DeepEqual(waits, want) {
+		t.Errorf("Provider.GetNextMessage() waits = %v, want %v", waits, want)
+	}
+	if want := []time.Duration{10 * time.Second}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("Provider.GetNextMessage() slept = %v, want %v", slept, want)
+	}
+}
+
+func TestSqsProvider_ReceiveWait(t *testing.T) {
+	tests := []struct {
+		name   string
+		wait   time.Duration
+		poller *message.Poller
+		want   time.Duration
+	}{
+		{"Short Polls", 0, nil, 0},
+		{"Wait Time", 5 * time.Second, nil, 5 * time.Second},
+		{"Poller", 5 * time.Second, message.NewPoller(time.Second, time.Minute), time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := Provider{WaitTime: tt.wait, Poller: tt.poller}
+			if got := mgr.ReceiveWait(); got != tt.want {
+				t.Errorf("Provider.ReceiveWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSqsProvider_ReceiveMessages(t *testing.T) {
+	body := func(title string) string {
+		data, _ := json.Marshal(message.Message{Title: title})
+		return string(data)
+	}
+
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+	svc.add(*mgr.QueueURL, body("First"), "{not json", body("Second"))
+	for i := 0; i < 12; i++ {
+		svc.add(*mgr.QueueURL, body("More"))
+	}
+
+	got, err := mgr.ReceiveMessages(3)
+	if err != nil {
+		t.Fatalf("Provider.ReceiveMessages() error = %v", err)
+	}
+
+	// Messages that don't decode are left to the dead-letter queue.
+	if len(got) != 2 || got[0].Title != "First" || got[1].Title != "Second" {
+		t.Fatalf("Provider.ReceiveMessages() = %v", got)
+	}
+	if *got[0].ExternalRef != "receipt-id-1" || *got[1].ExternalRef != "receipt-id-3" {
+		t.Errorf("Provider.ReceiveMessages() refs = %v, %v", *got[0].ExternalRef, *got[1].ExternalRef)
+	}
+
+	// SQS receives at most 10 messages at a time.
+	if got, _ = mgr.ReceiveMessages(20); len(got) != 10 {
+		t.Errorf("Provider.ReceiveMessages() received %v messages, want 10", len(got))
+	}
+	if want := []int64{3, 10}; !reflect.DeepEqual(svc.receives, want) {
+		t.Errorf("Provider.ReceiveMessages() requested %v, want %v", svc.receives, want)
+	}
+
+	// Empty queues are not an error.
+	mgr.ReceiveMessages(10)
+	if got, err = mgr.ReceiveMessages(10); len(got) != 0 || err != nil {
+		t.Errorf("Provider.ReceiveMessages() = %v, %v, want no messages", got, err)
+	}
+
+	if _, err := failProvider.ReceiveMessages(10); err == nil {
+		t.Error("Provider.ReceiveMessages() error = nil, want provider error")
+	}
+}
+
+func TestSqsProvider_DeleteMessages(t *testing.T) {
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+
+	var references []*string
+	for i := 0; i < 12; i++ {
+		references = append(references, aws.String("receipt-"+strconv.Itoa(i)))
+	}
+	if err := mgr.DeleteMessages(references); err != nil {
+		t.Fatalf("Provider.DeleteMessages() error = %v", err)
+	}
+	if want := []int{10, 2}; !reflect.DeepEqual(svc.batches, want) {
+		t.Errorf("Provider.DeleteMessages() batches = %v, want %v", svc.batches, want)
+	}
+	if len(svc.deleted) != 12 {
+		t.Errorf("Provider.DeleteMessages() deleted %v, want 12 messages", svc.deleted)
+	}
+
+	err := mgr.DeleteMessages([]*string{aws.String("receipt-12"), aws.String("fail-id")})
+	if err == nil || err.Error() != "could not delete 1 messages: invalid receipt handle" {
+		t.Errorf("Provider.DeleteMessages() error = %v", err)
+	}
+}
+
 func TestSqsProvider_DeleteMessage(t *testing.T) {
 	type args struct {
 		reference *string
@@ -0,0 +1,85 @@
+package sqs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// maxVisibilityTimeout is the longest SQS allows a message to be hidden.
+const maxVisibilityTimeout = 12 * time.Hour
+
+// newTicker ticks when the visibility timeout of a message is extended.
+var newTicker = time.NewTicker
+
+// ExtendVisibility keeps the message with the receipt handle hidden from other workers until
+// stop is called, e.g. while a long PHPCS audit of the message runs. The visibility timeout is
+// extended each time half of it has passed.
+//
+// Extensions end by themselves when SQS refuses them, e.g. once the message was deleted or
+// after the 12 hours that SQS allows a message to be hidden.
+func (mgr Provider) ExtendVisibility(reference *string) (stop func()) {
+	if reference == nil {
+		return func() {}
+	}
+	receipt := *reference
+	timeout := mgr.visibilityTimeout()
+
+	return mgr.extensions.Start(receipt, newTicker(timeout/2), func() error {
+		_, err := mgr.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          mgr.QueueURL,
+			ReceiptHandle:     aws.String(receipt),
+			VisibilityTimeout: aws.Int64(seconds(timeout)),
+		})
+		return err
+	})
+}
+
+// autoExtend extends the visibility timeout of a received message if the provider extends
+// messages automatically.
+func (mgr Provider) autoExtend(reference *string) {
+	if mgr.AutoExtend && mgr.extensions != nil {
+		mgr.ExtendVisibility(reference)
+	}
+}
+
+// stopExtending stops extending the visibility timeout of the message.
+func (mgr Provider) stopExtending(reference *string) {
+	if reference == nil {
+		return
+	}
+	mgr.extensions.Stop(*reference)
+}
+
+// ReleaseMessage stops extending the message and makes it visible to other workers again, e.g.
+// when its audit failed and should be retried without waiting for the visibility timeout.
+func (mgr Provider) ReleaseMessage(reference *string) error {
+	mgr.stopExtending(reference)
+
+	_, err := mgr.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          mgr.QueueURL,
+		ReceiptHandle:     reference,
+		VisibilityTimeout: aws.Int64(0),
+	})
+	return err
+}
+
+// ExtendLease hides the message with the receipt handle from other workers for the duration
+// from now, up to the 12 hours that SQS allows.
+func (mgr Provider) ExtendLease(reference *string, d time.Duration) error {
+	if reference == nil {
+		return errors.New("sqs: no receipt handle")
+	}
+	if d > maxVisibilityTimeout {
+		d = maxVisibilityTimeout
+	}
+
+	_, err := mgr.sqs.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          mgr.QueueURL,
+		ReceiptHandle:     reference,
+		VisibilityTimeout: aws.Int64(seconds(d)),
+	})
+	return err
+}
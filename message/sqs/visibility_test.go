@@ -0,0 +1,173 @@
+package sqs
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/wptide/pkg/message"
+)
+
+// fastTicker extends visibility timeouts every millisecond until restored.
+func fastTicker() (restore func()) {
+	newTicker = func(time.Duration) *time.Ticker { return time.NewTicker(time.Millisecond) }
+	return func() { newTicker = time.NewTicker }
+}
+
+// waitForExtensions waits until the message was extended at least n times.
+func waitForExtensions(t *testing.T, svc *memorySqs, receipt string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(svc.timeouts(receipt)) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("message %v was extended %v times, want %v", receipt, len(svc.timeouts(receipt)), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// extending returns the number of messages the provider extends.
+func extending(mgr Provider) int {
+	return mgr.extensions.Len()
+}
+
+func TestSqsProvider_ExtendVisibility(t *testing.T) {
+	defer fastTicker()()
+
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+	mgr.VisibilityTimeout = 2 * time.Minute
+
+	stop := mgr.ExtendVisibility(aws.String("receipt-1"))
+	waitForExtensions(t, svc, "receipt-1", 2)
+	stop()
+	stop()
+
+	timeouts := svc.timeouts("receipt-1")
+	for _, timeout := range timeouts {
+		if timeout != 120 {
+			t.Errorf("Provider.ExtendVisibility() timeouts = %v, want 120 seconds", timeouts)
+			break
+		}
+	}
+
+	// No extensions once stopped.
+	time.Sleep(10 * time.Millisecond)
+	if got := svc.timeouts("receipt-1"); len(got) != len(timeouts) {
+		t.Errorf("Provider.ExtendVisibility() extended %v times after stop, want %v", len(got), len(timeouts))
+	}
+}
+
+func TestSqsProvider_ExtendVisibility_Refused(t *testing.T) {
+	defer fastTicker()()
+
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+
+	// Messages that were deleted by another worker can't be extended.
+	svc.deleted = []string{"receipt-1"}
+	stop := mgr.ExtendVisibility(aws.String("receipt-1"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for extending(mgr) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Provider.ExtendVisibility() still extends a refused message")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+}
+
+func TestSqsProvider_AutoExtend(t *testing.T) {
+	defer fastTicker()()
+
+	body, _ := json.Marshal(message.Message{Title: "Audit"})
+
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+	mgr.AutoExtend = true
+	svc.add(*mgr.QueueURL, string(body), string(body), string(body))
+
+	msg, err := mgr.GetNextMessage()
+	if err != nil {
+		t.Fatalf("Provider.GetNextMessage() error = %v", err)
+	}
+	batch, err := mgr.ReceiveMessages(2)
+	if err != nil || len(batch) != 2 {
+		t.Fatalf("Provider.ReceiveMessages() = %v, %v", batch, err)
+	}
+
+	// Messages are extended while their audits run.
+	for _, receipt := range []string{"receipt-id-1", "receipt-id-2", "receipt-id-3"} {
+		waitForExtensions(t, svc, receipt, 1)
+	}
+
+	if err := mgr.DeleteMessage(msg.ExternalRef); err != nil {
+		t.Fatalf("Provider.DeleteMessage() error = %v", err)
+	}
+	if err := mgr.ReleaseMessage(batch[0].ExternalRef); err != nil {
+		t.Fatalf("Provider.ReleaseMessage() error = %v", err)
+	}
+	mgr.Close()
+
+	// Deleting, releasing and closing stop the extensions.
+	if n := extending(mgr); n != 0 {
+		t.Errorf("Provider extends %v messages, want none", n)
+	}
+
+	released := svc.timeouts("receipt-id-2")
+	if released[len(released)-1] != 0 {
+		t.Errorf("Provider.ReleaseMessage() timeouts = %v, want 0 last", released)
+	}
+
+	extended := svc.timeouts("receipt-id-3")
+	time.Sleep(10 * time.Millisecond)
+	if got := svc.timeouts("receipt-id-3"); len(got) != len(extended) {
+		t.Errorf("Provider.Close() extended %v times after close, want %v", len(got), len(extended))
+	}
+}
+
+func TestSqsProvider_AutoExtend_Disabled(t *testing.T) {
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+	svc.add(*mgr.QueueURL, "{}")
+
+	if _, err := mgr.GetNextMessage(); err != nil {
+		t.Fatalf("Provider.GetNextMessage() error = %v", err)
+	}
+	if n := extending(mgr); n != 0 {
+		t.Errorf("Provider extends %v messages, want none", n)
+	}
+}
+
+func TestSqsProvider_ExtendLease(t *testing.T) {
+	svc := newMemorySqs()
+	mgr := memoryProvider(svc, "test")
+
+	tests := []struct {
+		name    string
+		ref     *string
+		d       time.Duration
+		want    int64
+		wantErr bool
+	}{
+		{"Minutes", aws.String("receipt-1"), 5 * time.Minute, 300, false},
+		{"Longer Than SQS Allows", aws.String("receipt-2"), 24 * time.Hour, 43200, false},
+		{"No Receipt", nil, time.Minute, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lease := message.NewLeaseProvider(mgr)
+			if err := lease.ExtendLease(tt.ref, tt.d); (err != nil) != tt.wantErr {
+				t.Fatalf("Provider.ExtendLease() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := svc.timeouts(*tt.ref); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Provider.ExtendLease() timeouts = %v, want [%v]", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,3 +19,16 @@ type Payloader interface {
 	Sender
 	Builder
 }
+
+// Payload types used to select a destination per message (message.Message.PayloadType).
+const (
+	TypeTide    = "tide"
+	TypeWebhook = "webhook"
+	TypeStorage = "storage"
+)
+
+// Destination pairs a Builder with a Sender so that any payload format can be sent to any destination.
+type Destination struct {
+	Builder
+	Sender
+}
@@ -0,0 +1,52 @@
+package payload
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/wptide/pkg/message"
+)
+
+// PlainItem is the payload built by PlainPayload.
+type PlainItem struct {
+	Title       string                 `json:"title"`
+	Slug        string                 `json:"slug,omitempty"`
+	ProjectType string                 `json:"project_type,omitempty"`
+	SourceURL   string                 `json:"source_url"`
+	SourceType  string                 `json:"source_type"`
+	Checksum    string                 `json:"checksum"`
+	Standards   []string               `json:"standards,omitempty"`
+	ExternalRef *string                `json:"external_ref,omitempty"`
+	Results     map[string]interface{} `json:"results"`
+}
+
+// PlainPayload implements a Builder for plain webhooks. Unlike TidePayload it does not require
+// the Tide API item format and includes everything in the results.
+type PlainPayload struct{}
+
+// BuildPayload generates a plain JSON payload.
+func (p PlainPayload) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	checksum, _ := data["checksum"].(string)
+	if checksum == "" {
+		return nil, errors.New("no checksum for payload")
+	}
+
+	results := make(map[string]interface{})
+	for key, value := range data {
+		if key != "checksum" {
+			results[key] = value
+		}
+	}
+
+	return json.Marshal(PlainItem{
+		Title:       msg.Title,
+		Slug:        msg.Slug,
+		ProjectType: msg.ProjectType,
+		SourceURL:   msg.SourceURL,
+		SourceType:  msg.SourceType,
+		Checksum:    checksum,
+		Standards:   msg.Standards,
+		ExternalRef: msg.ExternalRef,
+		Results:     results,
+	})
+}
@@ -0,0 +1,49 @@
+package payload
+
+import (
+	"testing"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+func TestPlainPayload_BuildPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     message.Message
+		data    map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			"Valid Payload",
+			message.Message{Title: "Plugin", Slug: "plugin", SourceURL: "http://example.com/plugin.zip", SourceType: "zip"},
+			map[string]interface{}{
+				"checksum":   "abcdefg",
+				"lighthouse": tide.AuditResult{Raw: tide.AuditDetails{Type: "mock"}},
+				"inventory":  nil,
+			},
+			`{"title":"Plugin","slug":"plugin","source_url":"http://example.com/plugin.zip","source_type":"zip","checksum":"abcdefg","results":{"inventory":null,"lighthouse":{"raw":{"type":"mock"},"parsed":{},"summary":{}}}}`,
+			false,
+		},
+		{
+			"No Checksum",
+			message.Message{Title: "Plugin"},
+			map[string]interface{}{},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PlainPayload{}.BuildPayload(tt.msg, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PlainPayload.BuildPayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("PlainPayload.BuildPayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
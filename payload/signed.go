@@ -0,0 +1,206 @@
+package payload
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Signature algorithms.
+const (
+	AlgorithmEd25519 = "ed25519"
+)
+
+// DocumentSigner signs result documents so that consumers can verify that they were not
+// modified after the audit ran.
+type DocumentSigner interface {
+	Sign(document []byte) (Signature, error)
+}
+
+// Signature is the signature of a document and the key that can verify it.
+type Signature struct {
+	Algorithm string `json:"algorithm"` // e.g. AlgorithmEd25519.
+	KeyID     string `json:"key_id"`    // Identifies the public key published by the operator.
+	Value     []byte `json:"value"`     // Base64 encoded in JSON.
+}
+
+// SignedDocument wraps a document with its signature. The signature is for the exact bytes
+// of the document, so consumers must verify it before decoding the document.
+type SignedDocument struct {
+	Document  json.RawMessage `json:"document"`
+	Signature Signature       `json:"signature"`
+}
+
+// SignedPayload implements a Payloader that signs the payloads built by another Payloader
+// and sends them as a SignedDocument.
+type SignedPayload struct {
+	Payloader Payloader      // Builds and sends the payloads.
+	Signer    DocumentSigner // Signs the payloads.
+}
+
+// BuildPayload builds the payload with the Payloader and wraps it in a SignedDocument.
+func (s SignedPayload) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	if s.Payloader == nil || s.Signer == nil {
+		return nil, errors.New("signed payloads require a payloader and a signer")
+	}
+
+	document, err := s.Payloader.BuildPayload(msg, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only JSON documents can be embedded in the envelope. Surrounding whitespace is not
+	// kept when the envelope is decoded, so it is not signed either.
+	document = bytes.TrimSpace(document)
+	if !json.Valid(document) {
+		return nil, errors.New("could not sign payload: not a JSON document")
+	}
+
+	signature, err := s.Signer.Sign(document)
+	if err != nil {
+		return nil, err
+	}
+
+	signatureJSON, err := json.Marshal(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	// The envelope is assembled by hand because json.Marshal would reformat the document.
+	var envelope bytes.Buffer
+	envelope.WriteString(`{"document":`)
+	envelope.Write(document)
+	envelope.WriteString(`,"signature":`)
+	envelope.Write(signatureJSON)
+	envelope.WriteString(`}`)
+
+	return envelope.Bytes(), nil
+}
+
+// SendPayload sends the signed payload with the Payloader.
+func (s SignedPayload) SendPayload(destination string, payload []byte) ([]byte, error) {
+	if s.Payloader == nil {
+		return nil, errors.New("signed payloads require a payloader and a signer")
+	}
+	return s.Payloader.SendPayload(destination, payload)
+}
+
+// Ed25519Signer signs documents with an Ed25519 private key.
+type Ed25519Signer struct {
+	Key ed25519.PrivateKey
+	ID  string // (Optional) Key ID. Defaults to KeyID of the public key.
+}
+
+// NewEd25519Signer returns a signer for a PEM encoded PKCS #8 Ed25519 private key,
+// e.g. created with `openssl genpkey -algorithm ed25519`.
+func NewEd25519Signer(pemData []byte) (*Ed25519Signer, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not an Ed25519 key")
+	}
+
+	return &Ed25519Signer{Key: edKey}, nil
+}
+
+// Sign implements DocumentSigner.
+func (e Ed25519Signer) Sign(document []byte) (Signature, error) {
+	if len(e.Key) != ed25519.PrivateKeySize {
+		return Signature{}, errors.New("invalid Ed25519 private key")
+	}
+
+	id := e.ID
+	if id == "" {
+		id = KeyID(e.Key.Public().(ed25519.PublicKey))
+	}
+
+	return Signature{
+		Algorithm: AlgorithmEd25519,
+		KeyID:     id,
+		Value:     ed25519.Sign(e.Key, document),
+	}, nil
+}
+
+// KMSClient signs digests with a key that is managed by a key management service,
+// e.g. Cloud KMS or AWS KMS.
+type KMSClient interface {
+	AsymmetricSign(key string, digest []byte) ([]byte, error)
+}
+
+// KMSSigner signs the SHA-256 digest of documents with a KMS managed key.
+type KMSSigner struct {
+	Client    KMSClient
+	Key       string // Resource name or ARN of the key.
+	Algorithm string // Signing algorithm of the key, e.g. "EC_SIGN_P256_SHA256".
+	ID        string // (Optional) Key ID published to consumers. Defaults to Key.
+}
+
+// Sign implements DocumentSigner.
+func (k KMSSigner) Sign(document []byte) (Signature, error) {
+	if k.Client == nil || k.Key == "" {
+		return Signature{}, errors.New("no KMS client or key to sign with")
+	}
+
+	digest := sha256.Sum256(document)
+	value, err := k.Client.AsymmetricSign(k.Key, digest[:])
+	if err != nil {
+		return Signature{}, err
+	}
+
+	id := k.ID
+	if id == "" {
+		id = k.Key
+	}
+
+	return Signature{Algorithm: k.Algorithm, KeyID: id, Value: value}, nil
+}
+
+// KeyID returns the ID of an Ed25519 public key: the first 8 bytes of its SHA-256 in hex.
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// MarshalPublicKey returns the PEM encoded public key that operators publish for consumers.
+func MarshalPublicKey(publicKey ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// VerifyDocument checks the Ed25519 signature of a SignedDocument and returns the document.
+// Consumers can use this to trust results that were stored or forwarded by others.
+func VerifyDocument(data []byte, publicKey ed25519.PublicKey) (json.RawMessage, error) {
+	var signed SignedDocument
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, err
+	}
+
+	if signed.Signature.Algorithm != AlgorithmEd25519 {
+		return nil, errors.New("unsupported signature algorithm: " + signed.Signature.Algorithm)
+	}
+
+	if len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, signed.Document, signed.Signature.Value) {
+		return nil, errors.New("invalid signature")
+	}
+
+	return signed.Document, nil
+}
@@ -0,0 +1,187 @@
+package payload
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+)
+
+// jsonPayloader builds the message title as a JSON document and records the sent payloads.
+type jsonPayloader struct {
+	sent *[]byte
+}
+
+func (j jsonPayloader) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	if msg.Title == "error" {
+		return nil, errors.New("build error")
+	}
+	return []byte(msg.Title), nil
+}
+
+func (j jsonPayloader) SendPayload(destination string, payload []byte) ([]byte, error) {
+	*j.sent = payload
+	return []byte("ok"), nil
+}
+
+type mockKMS struct {
+	digest []byte
+}
+
+func (m *mockKMS) AsymmetricSign(key string, digest []byte) ([]byte, error) {
+	if key == "error" {
+		return nil, errors.New("kms error")
+	}
+	m.digest = digest
+	return []byte("signed"), nil
+}
+
+func TestSignedPayload(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := Ed25519Signer{Key: privateKey}
+
+	tests := []struct {
+		name    string
+		signer  DocumentSigner
+		title   string
+		want    string
+		wantErr bool
+	}{
+		{"Signed", signer, `{"title": "<plugin> & co"}`, `{"title": "<plugin> & co"}`, false},
+		{"Whitespace", signer, " {\"title\":\"plugin\"}\n", `{"title":"plugin"}`, false},
+		{"Not JSON", signer, "plugin", "", true},
+		{"Build Error", signer, "error", "", true},
+		{"No Signer", nil, `{}`, "", true},
+		{"Signer Error", Ed25519Signer{}, `{}`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []byte
+			s := SignedPayload{Payloader: jsonPayloader{&sent}, Signer: tt.signer}
+
+			data, err := s.BuildPayload(message.Message{Title: tt.title}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SignedPayload.BuildPayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			if _, err := s.SendPayload("destination", data); err != nil || !reflect.DeepEqual(sent, data) {
+				t.Errorf("SignedPayload.SendPayload() sent %s, %v", sent, err)
+			}
+
+			document, err := VerifyDocument(data, publicKey)
+			if err != nil {
+				t.Fatalf("VerifyDocument() error = %v", err)
+			}
+			if string(document) != tt.want {
+				t.Errorf("VerifyDocument() = %s, want %s", document, tt.want)
+			}
+
+			// Any change to the document invalidates the signature.
+			tampered := strings.Replace(string(data), "plugin", "p1ugin", 1)
+			if _, err := VerifyDocument([]byte(tampered), publicKey); err == nil {
+				t.Errorf("VerifyDocument() expected an error for a modified document")
+			}
+		})
+	}
+
+	if _, err := (SignedPayload{}).SendPayload("destination", nil); err == nil {
+		t.Errorf("SignedPayload.SendPayload() expected an error without a payloader")
+	}
+}
+
+func TestVerifyDocument(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	otherKey, _, _ := ed25519.GenerateKey(nil)
+
+	signature, _ := Ed25519Signer{Key: privateKey}.Sign([]byte(`{}`))
+	valid, _ := json.Marshal(SignedDocument{Document: []byte(`{}`), Signature: signature})
+
+	signature.Algorithm = "rsa"
+	unsupported, _ := json.Marshal(SignedDocument{Document: []byte(`{}`), Signature: signature})
+
+	tests := []struct {
+		name    string
+		data    []byte
+		key     ed25519.PublicKey
+		wantErr bool
+	}{
+		{"Valid", valid, publicKey, false},
+		{"Other Key", valid, otherKey, true},
+		{"Invalid Key", valid, nil, true},
+		{"Unsupported Algorithm", unsupported, publicKey, true},
+		{"Invalid JSON", []byte("{"), publicKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := VerifyDocument(tt.data, tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("VerifyDocument() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewEd25519Signer(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	der, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	signer, err := NewEd25519Signer(pemData)
+	if err != nil {
+		t.Fatalf("NewEd25519Signer() error = %v", err)
+	}
+
+	signature, err := signer.Sign([]byte(`{}`))
+	if err != nil || signature.KeyID != KeyID(publicKey) || !ed25519.Verify(publicKey, []byte(`{}`), signature.Value) {
+		t.Errorf("Ed25519Signer.Sign() = %v, %v", signature, err)
+	}
+
+	if _, err := NewEd25519Signer([]byte("not a key")); err == nil {
+		t.Errorf("NewEd25519Signer() expected an error without a PEM block")
+	}
+	if _, err := NewEd25519Signer(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")})); err == nil {
+		t.Errorf("NewEd25519Signer() expected an error for an invalid key")
+	}
+
+	published, err := MarshalPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("MarshalPublicKey() error = %v", err)
+	}
+	block, _ := pem.Decode(published)
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil || !reflect.DeepEqual(parsed, publicKey) {
+		t.Errorf("MarshalPublicKey() = %s, %v", published, err)
+	}
+}
+
+func TestKMSSigner_Sign(t *testing.T) {
+	kms := &mockKMS{}
+
+	signature, err := KMSSigner{Client: kms, Key: "projects/tide/keys/results", Algorithm: "EC_SIGN_P256_SHA256"}.Sign([]byte(`{}`))
+	want := Signature{Algorithm: "EC_SIGN_P256_SHA256", KeyID: "projects/tide/keys/results", Value: []byte("signed")}
+	if err != nil || !reflect.DeepEqual(signature, want) {
+		t.Errorf("KMSSigner.Sign() = %v, %v, want %v", signature, err, want)
+	}
+	if len(kms.digest) != 32 {
+		t.Errorf("KMSSigner.Sign() signed %x, want a SHA-256 digest", kms.digest)
+	}
+
+	if _, err := (KMSSigner{Client: kms, Key: "error"}).Sign([]byte(`{}`)); err == nil {
+		t.Errorf("KMSSigner.Sign() expected the KMS error")
+	}
+	if _, err := (KMSSigner{}).Sign([]byte(`{}`)); err == nil {
+		t.Errorf("KMSSigner.Sign() expected an error without a client")
+	}
+}
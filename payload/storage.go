@@ -0,0 +1,61 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+)
+
+// StoragePayload implements a Payloader that only uploads the payload to a storage provider,
+// e.g. when results are collected from a bucket instead of being sent to an API.
+type StoragePayload struct {
+	Provider   storage.Provider // Storage provider for the payloads.
+	TempFolder string           // (Optional) Folder for the temporary payload file. Defaults to os.TempDir().
+	Builder    Builder          // (Optional) Builds the payload. Defaults to TidePayload.
+}
+
+// BuildPayload uses the Builder or the default TidePayload.
+func (s StoragePayload) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	if s.Builder != nil {
+		return s.Builder.BuildPayload(msg, data)
+	}
+	pl := TidePayload{}
+	return pl.BuildPayload(msg, data)
+}
+
+// SendPayload uploads the payload using the destination as the storage reference and returns
+// the reference. If there is no destination the reference is the SHA256 of the payload.
+func (s StoragePayload) SendPayload(destination string, payload []byte) ([]byte, error) {
+	if s.Provider == nil {
+		return nil, errors.New("no storage provider for payloads")
+	}
+
+	reference := destination
+	if reference == "" {
+		sum := sha256.Sum256(payload)
+		reference = hex.EncodeToString(sum[:]) + ".json"
+	}
+
+	f, err := ioutil.TempFile(s.TempFolder, "payload")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(payload)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Provider.UploadFile(f.Name(), reference); err != nil {
+		return nil, err
+	}
+
+	return []byte(reference), nil
+}
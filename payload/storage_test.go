@@ -0,0 +1,93 @@
+package payload
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+)
+
+type mockStorage struct {
+	uploaded map[string]string
+}
+
+func (m *mockStorage) Kind() string {
+	return "mock"
+}
+
+func (m *mockStorage) CollectionRef() string {
+	return "mock-collection"
+}
+
+func (m *mockStorage) UploadFile(filename, reference string) error {
+	if reference == "uploaderror.json" {
+		return errors.New("upload error")
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	m.uploaded[reference] = string(data)
+	return nil
+}
+
+func (m *mockStorage) DownloadFile(reference, filename string) error {
+	return nil
+}
+
+func TestStoragePayload_SendPayload(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination string
+		payload     string
+		noProvider  bool
+		want        string
+		wantErr     bool
+	}{
+		{"Destination", "results/abcdefg.json", `{"checksum":"abcdefg"}`, false, "results/abcdefg.json", false},
+		{"No Destination", "", `{}`, false, "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a.json", false},
+		{"Upload Error", "uploaderror.json", `{}`, false, "", true},
+		{"No Provider", "results/abcdefg.json", `{}`, true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &mockStorage{uploaded: make(map[string]string)}
+			s := StoragePayload{Provider: provider}
+			if tt.noProvider {
+				s.Provider = nil
+			}
+
+			got, err := s.SendPayload(tt.destination, []byte(tt.payload))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StoragePayload.SendPayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if string(got) != tt.want {
+				t.Errorf("StoragePayload.SendPayload() = %s, want %s", got, tt.want)
+			}
+			if provider.uploaded[tt.want] != tt.payload {
+				t.Errorf("StoragePayload.SendPayload() uploaded %v, want %v", provider.uploaded[tt.want], tt.payload)
+			}
+		})
+	}
+}
+
+func TestStoragePayload_BuildPayload(t *testing.T) {
+	data := map[string]interface{}{"checksum": "abcdefg"}
+
+	if _, err := (StoragePayload{}).BuildPayload(message.Message{}, data); err == nil {
+		t.Errorf("StoragePayload.BuildPayload() expected the TidePayload error without code info")
+	}
+
+	got, err := StoragePayload{Builder: PlainPayload{}}.BuildPayload(message.Message{Title: "Plugin"}, data)
+	if err != nil {
+		t.Fatalf("StoragePayload.BuildPayload() error = %v", err)
+	}
+	if want := `{"title":"Plugin","source_url":"","source_type":"","checksum":"abcdefg","results":{}}`; string(got) != want {
+		t.Errorf("StoragePayload.BuildPayload() = %s, want %s", got, want)
+	}
+}
@@ -15,6 +15,16 @@ type TidePayload struct {
 
 // BuildPayload implements payload.Builder interface to generate Tide API payload.
 func (t TidePayload) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	payloadItem, err := NewItem(msg, data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(payloadItem)
+}
+
+// NewItem returns the result document for the message and the result data, e.g. to send it
+// to the Tide API or to export it.
+func NewItem(msg message.Message, data map[string]interface{}) (*tide.Item, error) {
 
 	codeInfo, ok := data["info"].(tide.CodeInfo)
 	if !ok {
@@ -35,7 +45,10 @@ func (t TidePayload) BuildPayload(msg message.Message, data map[string]interface
 		results[key] = r
 	}
 
-	if len(results) == 0 {
+	// Failed audits are still sent so that users can see why they failed.
+	failures, _ := data["failures"].([]tide.Failure)
+
+	if len(results) == 0 && len(failures) == 0 {
 		return nil, errors.New("no results to send to Tide API")
 	}
 
@@ -58,7 +71,25 @@ func (t TidePayload) BuildPayload(msg message.Message, data map[string]interface
 		payloadItem.Project = []string{msg.Slug}
 	}
 
-	return json.Marshal(payloadItem)
+	if verdict, ok := data["verdict"].(tide.Verdict); ok {
+		payloadItem.Verdict = &verdict
+	}
+
+	payloadItem.Failures = failures
+
+	if environment, ok := data["environment"].(tide.Environment); ok {
+		payloadItem.Environment = &environment
+	}
+
+	if exclude, ok := data["checksumExclude"].([]string); ok {
+		payloadItem.ChecksumExclude = exclude
+	}
+
+	if trend, ok := data["trend"].(tide.Trend); ok {
+		payloadItem.Trend = &trend
+	}
+
+	return payloadItem, nil
 }
 
 func fallbackValue(value ...interface{}) interface{} {
@@ -126,6 +126,51 @@ func TestTidePayload_BuildPayload(t *testing.T) {
 			[]byte(`{"title":"","content":"","version":"","checksum":"abcdefg","visibility":"","project_type":"plugin","source_url":"","source_type":"","code_info":{"type":"plugin","details":[],"cloc":{}},"reports":{"phpcs_demo":{"raw":{"type":"mock","filename":"mock","path":"mock"},"parsed":{"type":"mock","filename":"mock","path":"mock"},"summary":{}}},"project":["project-one"]}`),
 			false,
 		},
+		{
+			"Some Results - With Verdict",
+			fields{
+				&MockTideClient{},
+			},
+			args{
+				data: map[string]interface{}{
+					"info": mockInfo,
+					"phpcs_demo": tide.AuditResult{
+						Raw: tide.AuditDetails{
+							Type:     "mock",
+							FileName: "mock",
+							Path:     "mock",
+						},
+					},
+					"checksum": "abcdefg",
+					"verdict": tide.Verdict{
+						Result:  tide.VerdictFail,
+						Policy:  "directory",
+						Reasons: []string{"project is not compatible with PHP 7.4"},
+					},
+				},
+			},
+			[]byte(`{"title":"","content":"","version":"","checksum":"abcdefg","visibility":"","project_type":"plugin","source_url":"","source_type":"","code_info":{"type":"plugin","details":[],"cloc":{}},"reports":{"phpcs_demo":{"raw":{"type":"mock","filename":"mock","path":"mock"},"parsed":{},"summary":{}}},"verdict":{"result":"fail","policy":"directory","reasons":["project is not compatible with PHP 7.4"]}}`),
+			false,
+		},
+		{
+			"No Results - With Failures, Environment and Checksum Exclusions",
+			fields{
+				&MockTideClient{},
+			},
+			args{
+				data: map[string]interface{}{
+					"info":     mockInfo,
+					"checksum": "abcdefg",
+					"failures": []tide.Failure{
+						{Code: tide.FailureStandardMissing, Process: "PHPCS", Message: "could not determine PHPCS versions"},
+					},
+					"environment":     tide.Environment{OS: "linux/amd64", Fingerprint: "abc"},
+					"checksumExclude": []string{"*.mo"},
+				},
+			},
+			[]byte(`{"title":"","content":"","version":"","checksum":"abcdefg","visibility":"","project_type":"plugin","source_url":"","source_type":"","code_info":{"type":"plugin","details":[],"cloc":{}},"failures":[{"code":"STANDARD_MISSING","process":"PHPCS","message":"could not determine PHPCS versions"}],"environment":{"os":"linux/amd64","fingerprint":"abc"},"checksum_exclude":["*.mo"]}`),
+			false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,213 @@
+package payload
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Headers of webhook deliveries.
+const (
+	DefaultSignatureHeader = "X-Tide-Signature" // The HMAC signature of the timestamp and the payload.
+	DefaultTimestampHeader = "X-Tide-Timestamp" // The Unix time the payload was signed at.
+)
+
+var (
+	sleep = time.Sleep
+	now   = time.Now
+)
+
+// WebhookPayload implements a Payloader that sends signed payloads to a webhook.
+// Failed deliveries are retried with an exponential backoff and undeliverable
+// payloads are recorded in a dead-letter store. Each attempt is signed along with the time of
+// the attempt, so that consumers can reject replayed deliveries, see VerifySignature.
+type WebhookPayload struct {
+	Secret          string          // Shared secret used to sign payloads.
+	Header          string          // (Optional) Signature header. Defaults to DefaultSignatureHeader.
+	TimestampHeader string          // (Optional) Timestamp header. Defaults to DefaultTimestampHeader.
+	Client          *http.Client    // (Optional) HTTP client. Defaults to http.DefaultClient.
+	Retries         int             // (Optional) Retries after the first attempt. Payloads are not retried if 0.
+	Backoff         time.Duration   // (Optional) Delay before the first retry, doubled for each retry. Defaults to 1 second.
+	DeadLetter      DeadLetterStore // (Optional) Stores payloads that could not be delivered.
+	Builder         Builder         // (Optional) Builds the payload. Defaults to TidePayload.
+}
+
+// DeadLetter describes a payload that could not be delivered.
+type DeadLetter struct {
+	Destination string    `json:"destination"`
+	Payload     string    `json:"payload"`
+	Attempts    int       `json:"attempts"`
+	Error       string    `json:"error"`
+	Time        time.Time `json:"time"`
+}
+
+// DeadLetterStore is an interface for recording undeliverable payloads.
+type DeadLetterStore interface {
+	Store(letter DeadLetter) error
+}
+
+// DeadLetterFile implements DeadLetterStore by appending JSON lines to a file.
+type DeadLetterFile struct {
+	Filename string
+	mu       sync.Mutex
+}
+
+// Store appends the dead letter to the file.
+func (d *DeadLetterFile) Store(letter DeadLetter) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(d.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Sign returns the signature for a payload sent at the timestamp, in the form "sha256=<hex hmac>".
+// The HMAC covers the timestamp, a dot and the payload.
+func Sign(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature and the timestamp of a payload. Consumers can use this to
+// trust webhook payloads. Payloads signed longer than maxAge ago are rejected as replays, unless
+// maxAge is 0.
+func VerifySignature(secret, timestamp string, payload []byte, signature string, maxAge time.Duration) bool {
+	if !hmac.Equal([]byte(Sign(secret, timestamp, payload)), []byte(signature)) {
+		return false
+	}
+	if maxAge <= 0 {
+		return true
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := now().Sub(time.Unix(seconds, 0))
+	return age <= maxAge && age >= -maxAge
+}
+
+// BuildPayload uses the Builder or the default TidePayload.
+func (w WebhookPayload) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	if w.Builder != nil {
+		return w.Builder.BuildPayload(msg, data)
+	}
+	pl := TidePayload{}
+	return pl.BuildPayload(msg, data)
+}
+
+// SendPayload delivers the signed payload to the webhook.
+func (w WebhookPayload) SendPayload(destination string, payload []byte) ([]byte, error) {
+	if w.Secret == "" {
+		return nil, errors.New("no secret to sign webhook payloads")
+	}
+
+	retries := w.Retries
+	backoff := w.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	var reply []byte
+	var err error
+	var retry bool
+	attempts := 0
+
+	for attempts <= retries {
+		if attempts > 0 {
+			sleep(backoff)
+			backoff *= 2
+		}
+		attempts++
+
+		reply, retry, err = w.deliver(destination, payload)
+		if err == nil || !retry {
+			break
+		}
+	}
+
+	if err != nil && w.DeadLetter != nil {
+		letter := DeadLetter{
+			Destination: destination,
+			Payload:     string(payload),
+			Attempts:    attempts,
+			Error:       err.Error(),
+			Time:        now(),
+		}
+		if storeErr := w.DeadLetter.Store(letter); storeErr != nil {
+			err = errors.New(err.Error() + ", and the dead letter could not be stored: " + storeErr.Error())
+		}
+	}
+
+	return reply, err
+}
+
+// deliver makes a single delivery attempt. The bool is true if the attempt can be retried.
+func (w WebhookPayload) deliver(destination string, payload []byte) ([]byte, bool, error) {
+	req, err := http.NewRequest("POST", destination, bytes.NewReader(payload))
+	if err != nil {
+		return nil, false, err
+	}
+
+	header := w.Header
+	if header == "" {
+		header = DefaultSignatureHeader
+	}
+	timestampHeader := w.TimestampHeader
+	if timestampHeader == "" {
+		timestampHeader = DefaultTimestampHeader
+	}
+
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(header, Sign(w.Secret, timestamp, payload))
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// Network errors are usually temporary.
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := errors.New("webhook: unexpected status code: " + resp.Status + " " + strings.TrimSpace(string(body)))
+		// Server errors and rate limits are worth retrying, other client errors are not.
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return nil, retry, err
+	}
+
+	return body, false, nil
+}
@@ -0,0 +1,341 @@
+package payload
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+type mockDeadLetter struct {
+	letters []DeadLetter
+	err     error
+}
+
+func (m *mockDeadLetter) Store(letter DeadLetter) error {
+	m.letters = append(m.letters, letter)
+	return m.err
+}
+
+type mockBuilder struct{}
+
+func (m mockBuilder) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	return []byte(msg.Title), nil
+}
+
+func TestSign(t *testing.T) {
+	now = func() time.Time { return time.Unix(1500000060, 0) }
+	defer func() { now = time.Now }()
+
+	payload := []byte(`{"title":"test"}`)
+	signature := Sign("secret", "1500000000", payload)
+
+	if !strings.HasPrefix(signature, "sha256=") || len(signature) != len("sha256=")+64 {
+		t.Errorf("Sign() = %v, want a sha256 signature", signature)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		payload   []byte
+		signature string
+		maxAge    time.Duration
+		want      bool
+	}{
+		{"Valid Signature", "secret", "1500000000", payload, signature, 0, true},
+		{"Wrong Secret", "other", "1500000000", payload, signature, 0, false},
+		{"Tampered Payload", "secret", "1500000000", []byte(`{"title":"tampered"}`), signature, 0, false},
+		{"Tampered Timestamp", "secret", "1500000060", payload, signature, 0, false},
+		{"Empty Signature", "secret", "1500000000", payload, "", 0, false},
+		{"Recent Timestamp", "secret", "1500000000", payload, signature, 5 * time.Minute, true},
+		{"Replayed", "secret", "1500000000", payload, signature, 30 * time.Second, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, tt.timestamp, tt.payload, tt.signature, tt.maxAge); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookPayload_SendPayload(t *testing.T) {
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	now = func() time.Time { return time.Unix(1500000000, 0) }
+	defer func() {
+		sleep = time.Sleep
+		now = time.Now
+	}()
+
+	payload := []byte(`{"title":"test"}`)
+
+	// The server replies with the given status codes in order and then succeeds.
+	newServer := func(header string, statuses ...int) (*httptest.Server, *int) {
+		calls := 0
+		var mu sync.Mutex
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			body, _ := ioutil.ReadAll(r.Body)
+			if !VerifySignature("secret", r.Header.Get(DefaultTimestampHeader), body, r.Header.Get(header), time.Minute) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			calls++
+			if calls <= len(statuses) {
+				w.WriteHeader(statuses[calls-1])
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+		return server, &calls
+	}
+
+	tests := []struct {
+		name           string
+		w              WebhookPayload
+		header         string
+		statuses       []int
+		want           []byte
+		wantCalls      int
+		wantDelays     []time.Duration
+		wantDeadLetter bool
+		wantErr        bool
+	}{
+		{
+			"Delivered",
+			WebhookPayload{Secret: "secret"},
+			DefaultSignatureHeader,
+			nil,
+			[]byte("ok"),
+			1,
+			nil,
+			false,
+			false,
+		},
+		{
+			"Custom Header",
+			WebhookPayload{Secret: "secret", Header: "X-Hub-Signature"},
+			"X-Hub-Signature",
+			nil,
+			[]byte("ok"),
+			1,
+			nil,
+			false,
+			false,
+		},
+		{
+			"Delivered After Retries",
+			WebhookPayload{Secret: "secret", Retries: 2, Backoff: time.Millisecond},
+			DefaultSignatureHeader,
+			[]int{http.StatusBadGateway, http.StatusTooManyRequests},
+			[]byte("ok"),
+			3,
+			[]time.Duration{time.Millisecond, 2 * time.Millisecond},
+			false,
+			false,
+		},
+		{
+			"Retries Exhausted",
+			WebhookPayload{Secret: "secret", Retries: 2},
+			DefaultSignatureHeader,
+			[]int{500, 500, 500},
+			nil,
+			3,
+			[]time.Duration{time.Second, 2 * time.Second},
+			true,
+			true,
+		},
+		{
+			"No Retries",
+			WebhookPayload{Secret: "secret"},
+			DefaultSignatureHeader,
+			[]int{500},
+			nil,
+			1,
+			nil,
+			true,
+			true,
+		},
+		{
+			"Client Error Is Not Retried",
+			WebhookPayload{Secret: "secret", Retries: 2},
+			DefaultSignatureHeader,
+			[]int{http.StatusBadRequest},
+			nil,
+			1,
+			nil,
+			true,
+			true,
+		},
+		{
+			"No Secret",
+			WebhookPayload{},
+			DefaultSignatureHeader,
+			nil,
+			nil,
+			0,
+			nil,
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delays = nil
+			deadLetter := &mockDeadLetter{}
+			tt.w.DeadLetter = deadLetter
+
+			server, calls := newServer(tt.header, tt.statuses...)
+			defer server.Close()
+
+			got, err := tt.w.SendPayload(server.URL, payload)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WebhookPayload.SendPayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WebhookPayload.SendPayload() = %s, want %s", got, tt.want)
+			}
+			if *calls != tt.wantCalls {
+				t.Errorf("WebhookPayload.SendPayload() calls = %v, want %v", *calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(delays, tt.wantDelays) {
+				t.Errorf("WebhookPayload.SendPayload() delays = %v, want %v", delays, tt.wantDelays)
+			}
+
+			if (len(deadLetter.letters) != 0) != tt.wantDeadLetter {
+				t.Errorf("WebhookPayload.SendPayload() dead letters = %v, wantDeadLetter %v", deadLetter.letters, tt.wantDeadLetter)
+			}
+			if tt.wantDeadLetter {
+				letter := deadLetter.letters[0]
+				if letter.Destination != server.URL || letter.Payload != string(payload) || letter.Attempts != tt.wantCalls || letter.Error == "" {
+					t.Errorf("WebhookPayload.SendPayload() dead letter = %v", letter)
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookPayload_SendPayload_NetworkError(t *testing.T) {
+	sleep = func(d time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	deadLetter := &mockDeadLetter{}
+	w := WebhookPayload{Secret: "secret", Retries: 1, DeadLetter: deadLetter}
+
+	// Nothing is listening on this port.
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	if _, err := w.SendPayload(server.URL, []byte("{}")); err == nil {
+		t.Errorf("WebhookPayload.SendPayload() expected an error")
+	}
+	if len(deadLetter.letters) != 1 || deadLetter.letters[0].Attempts != 2 {
+		t.Errorf("WebhookPayload.SendPayload() dead letters = %v, want 1 letter after 2 attempts", deadLetter.letters)
+	}
+
+	if _, err := w.SendPayload("://invalid", []byte("{}")); err == nil {
+		t.Errorf("WebhookPayload.SendPayload() expected an error for an invalid destination")
+	}
+
+	// Letters that could not be stored are reported.
+	deadLetter.err = errors.New("disk full")
+	if _, err := w.SendPayload(server.URL, []byte("{}")); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("WebhookPayload.SendPayload() error = %v, want the dead letter error", err)
+	}
+}
+
+func TestWebhookPayload_BuildPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		w       WebhookPayload
+		want    []byte
+		wantErr bool
+	}{
+		{
+			"Custom Builder",
+			WebhookPayload{Builder: mockBuilder{}},
+			[]byte("Test"),
+			false,
+		},
+		{
+			"Default Builder",
+			WebhookPayload{},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.w.BuildPayload(message.Message{Title: "Test"}, map[string]interface{}{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WebhookPayload.BuildPayload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WebhookPayload.BuildPayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeadLetterFile_Store(t *testing.T) {
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+	defer os.RemoveAll("./testdata/tmp")
+
+	letter := DeadLetter{
+		Destination: "http://example.com/webhook",
+		Payload:     "{}",
+		Attempts:    4,
+		Error:       "webhook: unexpected status code: 500",
+		Time:        time.Unix(1500000000, 0).UTC(),
+	}
+
+	tests := []struct {
+		name      string
+		filename  string
+		wantLines int
+		wantErr   bool
+	}{
+		{"First Letter", "./testdata/tmp/dead-letters.json", 1, false},
+		{"Appended Letter", "./testdata/tmp/dead-letters.json", 2, false},
+		{"Invalid File", "./testdata/tmp", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DeadLetterFile{Filename: tt.filename}
+			if err := d.Store(letter); (err != nil) != tt.wantErr {
+				t.Errorf("DeadLetterFile.Store() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			data, _ := ioutil.ReadFile(tt.filename)
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != tt.wantLines {
+				t.Errorf("DeadLetterFile.Store() lines = %v, want %v", len(lines), tt.wantLines)
+			}
+
+			var got DeadLetter
+			if err := json.Unmarshal([]byte(lines[len(lines)-1]), &got); err != nil || !reflect.DeepEqual(got, letter) {
+				t.Errorf("DeadLetterFile.Store() = %v, want %v (%v)", got, letter, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package phpcompat
+
+import (
+	"sort"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// Annotation is a violation of a PHPCompatibility sniff at a line of a file, with the PHP
+// versions it affects, e.g. for inline display in code review tools.
+type Annotation struct {
+	File    string   `json:"file"`
+	Line    int      `json:"line"`
+	Column  int      `json:"column"`
+	Source  string   `json:"source"`
+	Message string   `json:"message"`
+	Type    string   `json:"type"`
+	Breaks  []string `json:"breaks,omitempty"` // Versions the violation breaks, oldest first.
+	Warns   []string `json:"warns,omitempty"`  // Versions the violation warns about, oldest first.
+}
+
+// FileAnnotations are the annotations of a file, by line.
+type FileAnnotations struct {
+	File        string       `json:"file"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// Annotations are the annotations of a PHPCompatibility report and the compatibility of the
+// whole project.
+type Annotations struct {
+	Files   []FileAnnotations   `json:"files"` // Files with violations, by name.
+	Verdict CompatibilityReport `json:"verdict"`
+}
+
+// Annotate returns the annotations of the violations of a PHPCompatibility report.
+func Annotate(results tide.PhpcsResults) Annotations {
+	names := make([]string, 0, len(results.Files))
+	for name, file := range results.Files {
+		if len(file.Messages) != 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	versions := newVersionCache()
+	annotations := Annotations{Files: []FileAnnotations{}}
+	var all []Annotation
+
+	for _, name := range names {
+		file := FileAnnotations{File: name}
+		for _, msg := range results.Files[name].Messages {
+			file.Annotations = append(file.Annotations, versions.annotate(name, msg))
+		}
+		sort.SliceStable(file.Annotations, func(i, j int) bool {
+			a, b := file.Annotations[i], file.Annotations[j]
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.Column < b.Column
+		})
+
+		annotations.Files = append(annotations.Files, file)
+		all = append(all, file.Annotations...)
+	}
+
+	annotations.Verdict = computeCompatibility(all)
+	return annotations
+}
+
+// versionCache remembers the versions of the violations, which repeat across files.
+type versionCache map[tide.PhpcsFilesMessage]Annotation
+
+// newVersionCache returns an empty versionCache.
+func newVersionCache() versionCache {
+	return make(versionCache)
+}
+
+// annotate returns the annotation of a violation in the file.
+func (c versionCache) annotate(file string, msg tide.PhpcsFilesMessage) Annotation {
+	key := tide.PhpcsFilesMessage{Message: msg.Message, Source: msg.Source, Type: msg.Type}
+
+	versions, ok := c[key]
+	if !ok {
+		versions = Annotation{Breaks: BreaksVersions(msg), Warns: NonBreakingVersions(msg)}
+		c[key] = versions
+	}
+
+	return Annotation{
+		File:    file,
+		Line:    msg.Line,
+		Column:  msg.Column,
+		Source:  msg.Source,
+		Message: msg.Message,
+		Type:    msg.Type,
+		Breaks:  versions.Breaks,
+		Warns:   versions.Warns,
+	}
+}
@@ -0,0 +1,76 @@
+package phpcompat
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func TestAnnotate(t *testing.T) {
+	randomBytes := testMessages["PHPCompatibility.PHP.NewFunctions.random_bytesFound"]
+	deprecated := testMessages["PHPCompatibility.PHP.RemovedConstants.intl_idna_variant_2003Deprecated"]
+
+	at := func(msg tide.PhpcsFilesMessage, line, column int) tide.PhpcsFilesMessage {
+		msg.Line, msg.Column = line, column
+		return msg
+	}
+
+	results := tide.PhpcsResults{}
+	results.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{
+		"plugin.php":    {Messages: []tide.PhpcsFilesMessage{at(deprecated, 12, 1), at(randomBytes, 3, 9), at(randomBytes, 3, 2)}},
+		"inc/admin.php": {Messages: []tide.PhpcsFilesMessage{at(randomBytes, 7, 5)}},
+		"readme.php":    {},
+	}
+
+	got := Annotate(results)
+
+	breaks := []string{"5.2", "5.3", "5.4", "5.5", "5.6"}
+	warns := []string{"7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"}
+	annotation := func(file string, msg tide.PhpcsFilesMessage, line, column int) Annotation {
+		a := Annotation{File: file, Line: line, Column: column, Source: msg.Source, Message: msg.Message, Type: msg.Type}
+		if msg.Type == "ERROR" {
+			a.Breaks = breaks
+		} else {
+			a.Warns = warns
+		}
+		return a
+	}
+
+	want := []FileAnnotations{
+		{File: "inc/admin.php", Annotations: []Annotation{annotation("inc/admin.php", randomBytes, 7, 5)}},
+		{File: "plugin.php", Annotations: []Annotation{
+			annotation("plugin.php", randomBytes, 3, 2),
+			annotation("plugin.php", randomBytes, 3, 9),
+			annotation("plugin.php", deprecated, 12, 1),
+		}},
+	}
+	if len(got.Files) != len(want) {
+		t.Fatalf("Annotate() files = %+v, want %+v", got.Files, want)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got.Files[i], want[i]) {
+			t.Errorf("Annotate() file = %+v, want %+v", got.Files[i], want[i])
+		}
+	}
+
+	// The verdict is the compatibility of every violation of the project.
+	messages := []tide.PhpcsFilesMessage{randomBytes, deprecated}
+	if wantVerdict := ComputeCompatibility(messages); !reflect.DeepEqual(got.Verdict, wantVerdict) {
+		t.Errorf("Annotate() verdict = %+v, want %+v", got.Verdict, wantVerdict)
+	}
+	if got.Verdict.Range != "7.0–8.3" {
+		t.Errorf("Annotate() verdict range = %v, want 7.0–8.3", got.Verdict.Range)
+	}
+}
+
+func TestAnnotate_Empty(t *testing.T) {
+	got := Annotate(tide.PhpcsResults{})
+	if len(got.Files) != 0 || got.Files == nil || got.Verdict.Range != "5.2–8.3" {
+		t.Errorf("Annotate() = %+v", got)
+	}
+}
@@ -0,0 +1,229 @@
+package phpcompat
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// Rule maps the violations of a PHPCompatibility sniff to the PHP versions they break or warn
+// about, for sniffs whose messages Parse can't read, e.g. the sniffs of a newer PHPCompatibility
+// release. Errors use the Breaks range and warnings the Warns range, a rule without a range for
+// the type of a violation does not apply to it.
+type Rule struct {
+	Source  string        `json:"source"`            // Sniff code, or a prefix of it ending in ".", e.g. "PHPCompatibility.Attributes.".
+	Message string        `json:"message,omitempty"` // (Optional) Regular expression that the message must match.
+	Breaks  *VersionRange `json:"breaks,omitempty"`
+	Warns   *VersionRange `json:"warns,omitempty"`
+}
+
+// VersionRange are the PHP versions of a Rule, as "major.minor" or full versions. Ranges start
+// at PHP 5.2 and end at PhpLatest unless Low or High are set. A "major.minor" High includes the
+// last release of the version, e.g. "5.6" includes 5.6.40.
+type VersionRange struct {
+	Low  string `json:"low,omitempty"`
+	High string `json:"high,omitempty"`
+}
+
+// Database is a list of rules. The first rule that matches a violation applies.
+type Database struct {
+	Rules []Rule `json:"rules"`
+
+	messages []*regexp.Regexp // Compiled Message expressions of the rules.
+}
+
+// defaultRules is the bundled compatibility database, for the sniffs whose messages don't say
+// which versions they are about.
+const defaultRules = `{
+	"rules": [
+		{"source": "PHPCompatibility.PHP.ValidIntegers.InvalidOctalIntegerFound", "breaks": {"low": "7.0"}},
+		{"source": "PHPCompatibility.PHP.TernaryOperators.MiddleMissing", "breaks": {"high": "5.2"}},
+		{"source": "PHPCompatibility.Operators.NewShortTernary.MiddleMissing", "breaks": {"high": "5.2"}}
+	]
+}`
+
+// defaultDatabase is the parsed bundled database.
+var defaultDatabase = mustParseDatabase(defaultRules)
+
+// database is the Database used by Parse.
+var database = struct {
+	sync.RWMutex
+	db *Database
+}{db: defaultDatabase}
+
+// DefaultDatabase returns the bundled compatibility database.
+func DefaultDatabase() *Database {
+	return defaultDatabase
+}
+
+// mustParseDatabase parses a database that is known to be valid.
+func mustParseDatabase(data string) *Database {
+	db, err := ParseDatabase([]byte(data))
+	if err != nil {
+		panic("phpcompat: invalid database: " + err.Error())
+	}
+	return db
+}
+
+// ParseDatabase parses a JSON compatibility database, e.g.
+//
+//	{"rules": [{"source": "PHPCompatibility.Attributes.NewAttributes", "breaks": {"high": "7.4"}}]}
+func ParseDatabase(data []byte) (*Database, error) {
+	var db Database
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	if err := db.compile(); err != nil {
+		return nil, err
+	}
+	return &db, nil
+}
+
+// LoadDatabase reads the JSON compatibility database at the path, e.g. to map the sniffs of a
+// newer PHPCompatibility release without a new build. Its rules override the bundled rules.
+func LoadDatabase(path string) (*Database, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := ParseDatabase(data)
+	if err != nil {
+		return nil, errors.New("invalid compatibility database " + path + ": " + err.Error())
+	}
+
+	db.Rules = append(db.Rules, defaultDatabase.Rules...)
+	db.messages = append(db.messages, defaultDatabase.messages...)
+	return db, nil
+}
+
+// UseDatabase sets the database used by Parse. A nil database restores the bundled database.
+func UseDatabase(db *Database) {
+	if db == nil {
+		db = defaultDatabase
+	}
+
+	database.Lock()
+	defer database.Unlock()
+	database.db = db
+}
+
+// currentDatabase returns the database used by Parse.
+func currentDatabase() *Database {
+	database.RLock()
+	defer database.RUnlock()
+	return database.db
+}
+
+// compile validates the rules and compiles their message expressions.
+func (db *Database) compile() error {
+	db.messages = make([]*regexp.Regexp, len(db.Rules))
+	for i, rule := range db.Rules {
+		if rule.Source == "" {
+			return errors.New("rule without a source")
+		}
+		if rule.Breaks == nil && rule.Warns == nil {
+			return errors.New("rule " + rule.Source + " has no versions")
+		}
+		for _, r := range []*VersionRange{rule.Breaks, rule.Warns} {
+			if r == nil {
+				continue
+			}
+			if _, err := r.compatibilityRange(); err != nil {
+				return errors.New("rule " + rule.Source + ": " + err.Error())
+			}
+		}
+
+		if rule.Message != "" {
+			re, err := regexp.Compile(rule.Message)
+			if err != nil {
+				return errors.New("rule " + rule.Source + ": " + err.Error())
+			}
+			db.messages[i] = re
+		}
+	}
+	return nil
+}
+
+// Lookup returns the compatibility of the violation from the first rule that applies to it.
+func (db *Database) Lookup(e tide.PhpcsFilesMessage) (Compatibility, bool) {
+	for i, rule := range db.Rules {
+		if !matchSource(rule.Source, e.Source) {
+			continue
+		}
+		if i < len(db.messages) && db.messages[i] != nil && !db.messages[i].MatchString(e.Message) {
+			continue
+		}
+
+		// Ranges of versions that are not in the version table don't apply.
+		var err error
+		compat := Compatibility{Source: e.Source}
+		switch strings.ToLower(e.Type) {
+		case "error":
+			if rule.Breaks == nil {
+				continue
+			}
+			compat.Breaks, err = rule.Breaks.compatibilityRange()
+			if err == nil && rule.Warns != nil {
+				compat.Warns, err = rule.Warns.compatibilityRange()
+			}
+		case "warning":
+			if rule.Warns == nil {
+				continue
+			}
+			compat.Warns, err = rule.Warns.compatibilityRange()
+		default:
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		return compat, true
+	}
+	return Compatibility{}, false
+}
+
+// compatibilityRange returns the range as a CompatibilityRange of full versions.
+func (r VersionRange) compatibilityRange() (*CompatibilityRange, error) {
+	table := currentVersions()
+	low := table.oldest()
+	if r.Low != "" {
+		var err error
+		if low, err = parseVersion(r.Low); err != nil {
+			return nil, err
+		}
+	}
+
+	high, err := parseVersion(table.latest())
+	if err != nil {
+		return nil, err
+	}
+	if r.High != "" {
+		if high, err = parseVersion(r.High); err != nil {
+			return nil, err
+		}
+		if len(strings.Split(r.High, ".")) != 3 {
+			max, ok := table.max[majorMinor(high)]
+			if !ok {
+				return nil, errors.New("unknown PHP version " + r.High)
+			}
+			high = max
+		}
+	}
+
+	if high.LT(low) {
+		return nil, errors.New("version " + high.String() + " is lower than " + low.String())
+	}
+
+	return &CompatibilityRange{
+		Low:        low.String(),
+		High:       high.String(),
+		Reported:   low.String(),
+		MajorMinor: majorMinor(low),
+	}, nil
+}
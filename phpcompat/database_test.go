@@ -0,0 +1,135 @@
+package phpcompat
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func TestParseDatabase(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"Rules", `{"rules": [{"source": "PHPCompatibility.Attributes.", "message": "(?i)attribute", "breaks": {"high": "7.3"}, "warns": {"low": "5.6.1", "high": "7.0"}}]}`, false},
+		{"No Rules", `{}`, false},
+		{"Invalid JSON", `{"rules": `, true},
+		{"No Source", `{"rules": [{"breaks": {"low": "7.0"}}]}`, true},
+		{"No Versions", `{"rules": [{"source": "PHPCompatibility.Attributes."}]}`, true},
+		{"Unknown Version", `{"rules": [{"source": "PHPCompatibility.Attributes.", "breaks": {"high": "4.4"}}]}`, true},
+		{"Invalid Version", `{"rules": [{"source": "PHPCompatibility.Attributes.", "breaks": {"low": "seven"}}]}`, true},
+		{"Inverted Range", `{"rules": [{"source": "PHPCompatibility.Attributes.", "breaks": {"low": "7.2", "high": "7.0"}}]}`, true},
+		{"Invalid Message", `{"rules": [{"source": "PHPCompatibility.Attributes.", "message": "(", "breaks": {"low": "7.0"}}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDatabase([]byte(tt.data)); (err != nil) != tt.wantErr {
+				t.Errorf("ParseDatabase() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatabase_Lookup(t *testing.T) {
+	db, err := ParseDatabase([]byte(`{"rules": [
+		{"source": "PHPCompatibility.Attributes.", "message": "(?i)attribute", "breaks": {"high": "7.3"}},
+		{"source": "PHPCompatibility.FunctionUse.ArgumentFunctionsUsage", "warns": {"low": "5.3"}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		message tide.PhpcsFilesMessage
+		want    Compatibility
+		wantOk  bool
+	}{
+		{
+			"Breaks",
+			tide.PhpcsFilesMessage{Source: "PHPCompatibility.Attributes.NewAttributes.Found", Message: "Attributes are not supported", Type: "ERROR"},
+			Compatibility{Source: "PHPCompatibility.Attributes.NewAttributes.Found", Breaks: &CompatibilityRange{"5.2.0", "7.3.33", "5.2.0", "5.2"}},
+			true,
+		},
+		{
+			"Warns",
+			tide.PhpcsFilesMessage{Source: "PHPCompatibility.FunctionUse.ArgumentFunctionsUsage.InControlStructure", Message: "func_get_args()", Type: "WARNING"},
+			Compatibility{Source: "PHPCompatibility.FunctionUse.ArgumentFunctionsUsage.InControlStructure", Warns: &CompatibilityRange{"5.3.0", PhpLatest, "5.3.0", "5.3"}},
+			true,
+		},
+		{
+			"Other Message",
+			tide.PhpcsFilesMessage{Source: "PHPCompatibility.Attributes.NewAttributes.Found", Message: "Something else", Type: "ERROR"},
+			Compatibility{},
+			false,
+		},
+		{
+			"No Range For Type",
+			tide.PhpcsFilesMessage{Source: "PHPCompatibility.FunctionUse.ArgumentFunctionsUsage.InControlStructure", Message: "func_get_args()", Type: "ERROR"},
+			Compatibility{},
+			false,
+		},
+		{
+			"Other Source",
+			tide.PhpcsFilesMessage{Source: "PHPCompatibility.AttributesOther.Found", Message: "Attributes", Type: "ERROR"},
+			Compatibility{},
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := db.Lookup(tt.message)
+			if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Database.Lookup() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestLoadDatabase(t *testing.T) {
+	f, err := ioutil.TempFile("", "phpcompat-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`{"rules": [
+		{"source": "PHPCompatibility.Attributes.NewAttributes", "breaks": {"high": "7.3"}},
+		{"source": "PHPCompatibility.PHP.TernaryOperators.MiddleMissing", "breaks": {"high": "5.3"}}
+	]}`)
+	f.Close()
+
+	if _, err := LoadDatabase(f.Name() + ".missing"); err == nil {
+		t.Error("LoadDatabase() expected an error for a missing file")
+	}
+
+	db, err := LoadDatabase(f.Name())
+	if err != nil {
+		t.Fatalf("LoadDatabase() error = %v", err)
+	}
+
+	UseDatabase(db)
+	defer UseDatabase(nil)
+
+	// New sniffs are mapped without being parsed, and the rules of the file override the bundled rules.
+	attributes := tide.PhpcsFilesMessage{Source: "PHPCompatibility.Attributes.NewAttributes.Found", Message: "Attributes found", Type: "ERROR"}
+	if got, want := BreaksVersions(attributes), []string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BreaksVersions() = %v, want %v", got, want)
+	}
+	if got, want := BreaksVersions(testMessages["PHPCompatibility.PHP.TernaryOperators.MiddleMissing"]), []string{"5.2", "5.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BreaksVersions() = %v, want %v", got, want)
+	}
+
+	// Rules of the bundled database still apply.
+	if got, want := BreaksVersions(testMessages["PHPCompatibility.PHP.ValidIntegers.InvalidOctalIntegerFound"]), []string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BreaksVersions() = %v, want %v", got, want)
+	}
+
+	UseDatabase(nil)
+	if _, err := Parse(attributes); err == nil {
+		t.Error("Parse() expected an error without the loaded database")
+	}
+}
@@ -31,29 +31,28 @@ var (
 )
 
 // Parse takes a tide.PhpcsFilesMessage message and returns a Compatibility struct.
-// It parses using the above verbs.
+// Messages with a rule in the compatibility database use the rule, the others are parsed
+// using the above verbs. Messages with versions that are not valid or not supported can't
+// be parsed.
 func Parse(e tide.PhpcsFilesMessage) (Compatibility, error) {
 
+	if compat, ok := currentDatabase().Lookup(e); ok {
+		return compat, nil
+	}
+
 	versions := getVersions(e.Message)
+	latest := currentVersions().latest()
 	var breaks *CompatibilityRange
 	var warns *CompatibilityRange
 
+	initial, err := newCompatibilityRange(versions[0], "")
+	if err != nil {
+		return Compatibility{}, err
+	}
 	if strings.ToLower(e.Type) == "error" {
-		low, high, majorMinor, reported := GetVersionParts(versions[0], "")
-		breaks = &CompatibilityRange{
-			low,
-			high,
-			reported,
-			majorMinor,
-		}
+		breaks = initial
 	} else {
-		low, high, majorMinor, reported := GetVersionParts(versions[0], "")
-		warns = &CompatibilityRange{
-			low,
-			high,
-			reported,
-			majorMinor,
-		}
+		warns = initial
 	}
 
 	matchList := strings.Join(verbs, "|")
@@ -61,149 +60,91 @@ func Parse(e tide.PhpcsFilesMessage) (Compatibility, error) {
 	var re = regexp.MustCompile(`(?i)` + matchList + ``)
 	matches := re.FindAllString(e.Message, -1)
 
-	if len(matches) > 0 {
-
-		matches = orderMatches(matches)
-
-		// NOTE: Order is VERY important
-		switch matches[0] {
-		case "not present":
-			low, high, majorMinor, reported := GetVersionParts(versions[0], "5.2.0")
-
-			breaks = &CompatibilityRange{
-				low,
-				high,
-				reported,
-				majorMinor,
-			}
-		case "soft reserved":
-			fallthrough
-		case "deprecated":
-
-			low, _, majorMinor, reported := GetVersionParts(versions[0], versions[0])
-			warns = &CompatibilityRange{
-				low,
-				PhpLatest,
-				reported,
-				majorMinor,
-			}
-
-			if len(versions) > 1 {
-				low, _, majorMinor, reported := GetVersionParts(versions[1], "")
-				breaks = &CompatibilityRange{
-					low,
-					PhpLatest,
-					reported,
-					majorMinor,
-				}
-
-				warns.High = PreviousVersion(breaks.Low)
-			}
-		case "reserved":
-			// We don't want to pick up "soft reserved".
-			if len(matches) == 1 || (len(matches) > 1 && matches[0] != matches[1]) {
-
-				low, _, majorMinor, reported := GetVersionParts(versions[0], versions[0])
-
-				breaks = &CompatibilityRange{
-					low,
-					PhpLatest,
-					reported,
-					majorMinor,
-				}
-			}
-		case "removed in":
-			fallthrough
-		case "removed":
-			// We don't want to pick up "soft reserved" or "deprecated".
-			if len(versions) == 1 {
-
-				low, _, majorMinor, reported := GetVersionParts(versions[0], versions[0])
-
-				breaks = &CompatibilityRange{
-					low,
-					PhpLatest,
-					reported,
-					majorMinor,
-				}
-			}
-		case "prior to":
-
-			// Annoying bad grammar for these errors...
-			if e.Source == "PHPCompatibility.PHP.ValidIntegers.InvalidOctalIntegerFound" {
-				low, _, majorMinor, reported := GetVersionParts(versions[0], versions[0])
-
-				breaks = &CompatibilityRange{
-					low,
-					PhpLatest,
-					reported,
-					majorMinor,
-				}
-			} else {
-				version := PreviousVersion(versions[0])
-				low, high, majorMinor, reported := GetVersionParts(version, "5.2.0")
+	if len(matches) == 0 {
+		return Compatibility{}, errors.New("could not parse message")
+	}
 
-				breaks = &CompatibilityRange{
-					low,
-					high,
-					reported,
-					majorMinor,
-				}
-			}
-		case "<":
-			// Another annoying grammar.
-			if e.Source == "PHPCompatibility.PHP.TernaryOperators.MiddleMissing" {
-				version := PreviousVersion(versions[0])
-				low, high, majorMinor, reported := GetVersionParts(version, "5.2.0")
+	matches = orderMatches(matches)
 
-				breaks = &CompatibilityRange{
-					low,
-					high,
-					reported,
-					majorMinor,
-				}
-			}
-		case "or earlier":
-			fallthrough
-		case "and earlier":
-			fallthrough
-		case "and lower":
-			low, high, majorMinor, reported := GetVersionParts(versions[0], "5.2.0")
+	// NOTE: Order is VERY important
+	switch matches[0] {
+	case "not present":
+		if breaks, err = newCompatibilityRange(versions[0], "5.2.0"); err != nil {
+			return Compatibility{}, err
+		}
+	case "soft reserved":
+		fallthrough
+	case "deprecated":
+		if warns, err = newCompatibilityRange(versions[0], versions[0]); err != nil {
+			return Compatibility{}, err
+		}
+		warns.High = latest
 
-			breaks = &CompatibilityRange{
-				low,
-				high,
-				reported,
-				majorMinor,
+		if len(versions) > 1 {
+			if breaks, err = newCompatibilityRange(versions[1], ""); err != nil {
+				return Compatibility{}, err
 			}
-		case "magic method":
-			low, high, majorMinor, reported := GetVersionParts("all", "")
+			breaks.High = latest
 
-			breaks = &CompatibilityRange{
-				low,
-				high,
-				reported,
-				majorMinor,
+			if warns.High, err = PreviousVersion(breaks.Low); err != nil {
+				return Compatibility{}, err
 			}
-		case "available since":
-			if breaks != nil {
-				breaks.Low = "5.2.1"
-				breaks.High = PreviousVersion(breaks.MajorMinor)
+		}
+	case "reserved":
+		// We don't want to pick up "soft reserved".
+		if len(matches) == 1 || (len(matches) > 1 && matches[0] != matches[1]) {
+			if breaks, err = newCompatibilityRange(versions[0], versions[0]); err != nil {
+				return Compatibility{}, err
 			}
-			if warns != nil {
-				warns.Low = "5.2.1"
-				warns.High = PreviousVersion(warns.MajorMinor)
+			breaks.High = latest
+		}
+	case "removed in":
+		fallthrough
+	case "removed":
+		// We don't want to pick up "soft reserved" or "deprecated".
+		if len(versions) == 1 {
+			if breaks, err = newCompatibilityRange(versions[0], versions[0]); err != nil {
+				return Compatibility{}, err
 			}
-		case "since":
-			if breaks != nil {
-				breaks.High = PhpLatest
+			breaks.High = latest
+		}
+	case "prior to":
+		version, err := PreviousVersion(versions[0])
+		if err != nil {
+			return Compatibility{}, err
+		}
+		if breaks, err = newCompatibilityRange(version, "5.2.0"); err != nil {
+			return Compatibility{}, err
+		}
+	case "or earlier":
+		fallthrough
+	case "and earlier":
+		fallthrough
+	case "and lower":
+		if breaks, err = newCompatibilityRange(versions[0], "5.2.0"); err != nil {
+			return Compatibility{}, err
+		}
+	case "magic method":
+		if breaks, err = newCompatibilityRange("all", ""); err != nil {
+			return Compatibility{}, err
+		}
+	case "available since":
+		for _, compat := range []*CompatibilityRange{breaks, warns} {
+			if compat == nil {
+				continue
 			}
-			if warns != nil {
-				warns.High = PhpLatest
+			compat.Low = "5.2.1"
+			if compat.High, err = PreviousVersion(compat.MajorMinor); err != nil {
+				return Compatibility{}, err
 			}
 		}
-	} else {
-		return Compatibility{}, errors.New("could not parse message")
+	case "since":
+		if breaks != nil {
+			breaks.High = latest
+		}
+		if warns != nil {
+			warns.High = latest
+		}
 	}
 
 	return Compatibility{
@@ -213,6 +154,15 @@ func Parse(e tide.PhpcsFilesMessage) (Compatibility, error) {
 	}, nil
 }
 
+// newCompatibilityRange returns the range of a version reported in a message, see GetVersionParts.
+func newCompatibilityRange(version, lowIn string) (*CompatibilityRange, error) {
+	low, high, majorMinor, reported, err := GetVersionParts(version, lowIn)
+	if err != nil {
+		return nil, err
+	}
+	return &CompatibilityRange{low, high, reported, majorMinor}, nil
+}
+
 // getVersions extracts the versions from a message string.
 func getVersions(line string) []string {
 	pattern := `(?i)((\d+\.)+\d+)|(\ball\b)`
@@ -147,3 +147,29 @@ func TestParse(t *testing.T) {
 	}
 
 }
+
+func FuzzParse(f *testing.F) {
+	for _, message := range testMessages {
+		f.Add(message.Message, message.Source, message.Type)
+	}
+	f.Add("Removed since PHP 1.2.3.4", "PHPCompatibility.Fake", "ERROR")
+	f.Add("Deprecated since PHP 99999999999999999999.0", "PHPCompatibility.Fake", "WARNING")
+	f.Fuzz(func(t *testing.T, message, source, messageType string) {
+		msg := tide.PhpcsFilesMessage{Message: message, Source: source, Type: messageType}
+
+		compat, err := Parse(msg)
+		if err != nil {
+			return
+		}
+		if compat.Breaks == nil && compat.Warns == nil {
+			t.Errorf("Parse(%q) = %v, without a range", message, compat)
+		}
+
+		// Versions are always supported versions.
+		for _, version := range append(BreaksVersions(msg), NonBreakingVersions(msg)...) {
+			if !contains(PhpMajorVersions(), version) {
+				t.Errorf("Parse(%q) reported version %v", message, version)
+			}
+		}
+	})
+}
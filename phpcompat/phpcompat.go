@@ -1,48 +1,126 @@
 package phpcompat
 
 import (
+	"errors"
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/blang/semver"
 	"github.com/wptide/pkg/tide"
 )
 
-var (
-	phpVersions = map[string]map[string]string{
-		"5.2": {
-			"max": "5.2.17",
-		},
-		"5.3": {
-			"max": "5.3.29",
-		},
-		"5.4": {
-			"max": "5.4.45",
-		},
-		"5.5": {
-			"max": "5.5.38",
-		},
-		"5.6": {
-			"max": "5.6.40",
-		},
-		"7.0": {
-			"max": "7.0.33",
-		},
-		"7.1": {
-			"max": "7.1.31",
-		},
-		"7.2": {
-			"max": "7.2.21",
-		},
-		"7.3": {
-			"max": "7.3.8",
-		},
+// PhpVersion is a PHP major.minor version and its latest release.
+type PhpVersion struct {
+	MajorMinor string // e.g. "7.4".
+	Max        string // Latest release, e.g. "7.4.33".
+}
+
+// DefaultPhpVersions are the PHP versions compatibility is reported for, oldest first.
+var DefaultPhpVersions = []PhpVersion{
+	{"5.2", "5.2.17"},
+	{"5.3", "5.3.29"},
+	{"5.4", "5.4.45"},
+	{"5.5", "5.5.38"},
+	{"5.6", "5.6.40"},
+	{"7.0", "7.0.33"},
+	{"7.1", "7.1.33"},
+	{"7.2", "7.2.34"},
+	{"7.3", "7.3.33"},
+	{"7.4", "7.4.33"},
+	{"8.0", "8.0.30"},
+	{"8.1", "8.1.33"},
+	{"8.2", "8.2.29"},
+	{"8.3", "8.3.26"},
+}
+
+// phpVersions is the table of the PHP versions compatibility is reported for. SetPhpVersions
+// replaces it while audits may read it.
+var phpVersions = struct {
+	sync.RWMutex
+	table versionTable
+}{table: newVersionTable(DefaultPhpVersions)}
+
+// PhpLatest represents the latest version of PHP. It changes with SetPhpVersions, so it must not
+// be read while the versions are replaced.
+var PhpLatest = phpVersions.table.latest()
+
+// currentVersions returns the table of the PHP versions compatibility is reported for.
+func currentVersions() versionTable {
+	phpVersions.RLock()
+	defer phpVersions.RUnlock()
+	return phpVersions.table
+}
+
+// versionTable is a list of PHP versions, with their latest releases by major.minor version.
+// Tables are not changed once they are created, SetPhpVersions replaces the table instead.
+type versionTable struct {
+	versions []PhpVersion
+	max      map[string]semver.Version
+}
+
+// newVersionTable returns the table of the versions, in order.
+func newVersionTable(versions []PhpVersion) versionTable {
+	table := versionTable{versions: append([]PhpVersion{}, versions...), max: make(map[string]semver.Version)}
+	sort.SliceStable(table.versions, func(i, j int) bool {
+		return semver.MustParse(table.versions[i].Max).LT(semver.MustParse(table.versions[j].Max))
+	})
+	for _, version := range table.versions {
+		table.max[version.MajorMinor] = semver.MustParse(version.Max)
 	}
-	// PhpLatest represents the latest version of PHP.
-	PhpLatest = "7.3.8"
-)
+	return table
+}
+
+// latest returns the latest release of the table.
+func (t versionTable) latest() string {
+	if len(t.versions) == 0 {
+		return ""
+	}
+	return t.versions[len(t.versions)-1].Max
+}
+
+// oldest returns the first release of the oldest version of the table, the oldest version
+// compatibility is reported for.
+func (t versionTable) oldest() semver.Version {
+	if len(t.versions) == 0 {
+		return semver.Version{Major: 5, Minor: 2}
+	}
+	return semver.MustParse(t.versions[0].MajorMinor + ".0")
+}
+
+// previous returns the latest release of the last major.minor version before the version, and
+// false if there is none.
+func (t versionTable) previous(v semver.Version) (semver.Version, bool) {
+	var previous semver.Version
+	found := false
+	for _, version := range t.versions {
+		first := semver.MustParse(version.MajorMinor + ".0")
+		if first.GTE(semver.Version{Major: v.Major, Minor: v.Minor}) {
+			break
+		}
+		previous, found = t.max[version.MajorMinor], true
+	}
+	return previous, found
+}
+
+// SetPhpVersions replaces the PHP versions compatibility is reported for, e.g. to add a new
+// PHP release or to test against a fixed table, and updates PhpLatest. It returns a function
+// that restores the previous versions. Versions must be valid, e.g. {"8.4", "8.4.1"}.
+func SetPhpVersions(versions []PhpVersion) (restore func()) {
+	table := newVersionTable(versions)
+
+	phpVersions.Lock()
+	defer phpVersions.Unlock()
+	previous, previousLatest := phpVersions.table, PhpLatest
+	phpVersions.table, PhpLatest = table, table.latest()
+
+	return func() {
+		phpVersions.Lock()
+		defer phpVersions.Unlock()
+		phpVersions.table, PhpLatest = previous, previousLatest
+	}
+}
 
 // Compatibility describes a compatibility report with breaking and warning ranges.
 type Compatibility struct {
@@ -59,199 +137,193 @@ type CompatibilityRange struct {
 	MajorMinor string `json:"major_minor"`
 }
 
-// PreviousVersion returns the immediate previous version given a version.
-func PreviousVersion(version string) string {
-
-	if version == "all" {
-		return version
+// parseVersion parses a PHP version with up to three parts, e.g. "7", "7.4" or "7.4.1".
+func parseVersion(version string) (semver.Version, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return semver.Version{}, errors.New("invalid PHP version: " + version)
 	}
-
-	// Only supporting down to 5.2.0.
-	if version == "5.2" || version == "5.2.0" {
-		return "5.2.0"
+	for len(parts) < 3 {
+		parts = append(parts, "0")
 	}
 
-	parts := strings.Split(version, ".")
-	if len(parts) < 3 {
-		parts = append(parts, "0")
+	v, err := semver.Parse(strings.Join(parts, "."))
+	if err != nil || len(v.Pre) != 0 || len(v.Build) != 0 {
+		return semver.Version{}, errors.New("invalid PHP version: " + version)
 	}
+	return v, nil
+}
 
-	var maxPrev []string
+// majorMinor returns the major.minor version of a version, e.g. "7.4" for 7.4.1.
+func majorMinor(v semver.Version) string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
 
-	if parts[1] == "0" {
-		var mMPre string
-		switch parts[0] + "." + parts[1] {
-		case "7.0":
-			mMPre = "5.6"
-		}
-		maxPrev = strings.Split(phpVersions[mMPre]["max"], ".")
-	} else {
-		pre, _ := strconv.Atoi(parts[1])
-		pre--
-		maxPrev = strings.Split(phpVersions[parts[0]+"."+strconv.Itoa(pre)]["max"], ".")
-	}
+// PreviousVersion returns the immediate previous version given a version. The version before
+// the first release of a major.minor version is the latest release of the version before it,
+// e.g. 7.4.33 before 8.0.0. Versions are not lower than the oldest supported version.
+func PreviousVersion(version string) (string, error) {
 
-	// Convert and subtract parts
-	p3, _ := strconv.Atoi(parts[2])
-	p3--
-	if p3 < 0 {
-		parts[2] = maxPrev[2]
+	if version == "all" {
+		return version, nil
+	}
 
-		if parts[1] == "0" {
-			parts[1] = maxPrev[1]
-			parts[0] = maxPrev[0]
-		} else {
+	v, err := parseVersion(version)
+	if err != nil {
+		return "", err
+	}
 
-			p2, _ := strconv.Atoi(parts[1])
-			p2--
-			parts[1] = strconv.Itoa(p2)
+	table := currentVersions()
+	oldest := table.oldest()
+	if v.LTE(oldest) {
+		return oldest.String(), nil
+	}
 
-		}
-	} else {
-		parts[2] = strconv.Itoa(p3)
+	if v.Patch > 0 {
+		v.Patch--
+		return v.String(), nil
 	}
 
-	return strings.Join(parts, ".")
+	if previous, ok := table.previous(v); ok {
+		return previous.String(), nil
+	}
+	return oldest.String(), nil
 }
 
 // VersionParts takes a version given as string and returns each part as an int.
-func VersionParts(version string) (int, int, int) {
+func VersionParts(version string) (int, int, int, error) {
 	if version == "all" {
-		return 0, 0, 0
+		return 0, 0, 0, nil
 	}
-	parts := strings.Split(version, ".")
 
-	major, _ := strconv.Atoi(parts[0])
-	minor, _ := strconv.Atoi(parts[1])
-	patch, _ := strconv.Atoi(parts[2])
+	v, err := parseVersion(version)
+	if err != nil {
+		return 0, 0, 0, err
+	}
 
-	return major, minor, patch
+	return int(v.Major), int(v.Minor), int(v.Patch), nil
 }
 
 // GetVersionParts returns a version range (low and high) as well as the majorMinor for the given version and the given version as `reported`.
-func GetVersionParts(version, lowIn string) (low, high, majorMinor, reported string) {
-	vParts := strings.Split(version, ".")
-
-	majorMinor = "all"
-	high = ""
+// A major.minor version ends at the latest release of the version, which must be supported. Versions before the oldest supported version
+// are reported as the oldest version.
+func GetVersionParts(version, lowIn string) (low, high, majorMinorOut, reported string, err error) {
+	reported = version
 
 	// Version "all" is not helpful.
-	if len(vParts) > 1 {
-		majorMinor = fmt.Sprintf("%s.%s", vParts[0], vParts[1])
+	if version == "all" {
+		if lowIn == "" || lowIn == "all" {
+			return "all", "all", "all", reported, nil
+		}
+		lowVersion, err := parseVersion(lowIn)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		return lowVersion.String(), "all", "all", reported, nil
 	}
 
-	// Is it a Major.Minor? Then get the max.
-	if len(vParts) != 1 && len(vParts) != 3 {
-		high = phpVersions[majorMinor]["max"]
-	} else {
-		high = version
+	v, err := parseVersion(version)
+	if err != nil {
+		return "", "", "", "", err
 	}
+	majorMinorOut = majorMinor(v)
 
-	if lowIn == "" {
-		if majorMinor != "all" {
-			low = fmt.Sprintf("%s.%s", majorMinor, "0")
-		} else {
-			low = "all"
-		}
+	table := currentVersions()
+	oldest := table.oldest()
+	if v.LT(oldest) {
+		return oldest.String(), oldest.String(), majorMinor(oldest), reported, nil
+	}
 
-	} else {
-		lowParts := strings.Split(lowIn, ".")
-		if len(lowParts) == 2 {
-			low = fmt.Sprintf("%s.%s", lowIn, "0")
-		} else {
-			low = lowIn
+	// Is it a Major.Minor? Then get the max.
+	highVersion := v
+	if len(strings.Split(version, ".")) != 3 {
+		max, ok := table.max[majorMinorOut]
+		if !ok {
+			return "", "", "", "", errors.New("unsupported PHP version: " + version)
 		}
+		highVersion = max
 	}
 
-	v, _ := semver.Make(majorMinor + ".0")
-	min, _ := semver.Make("5.2.0")
-
-	if v.LT(min) && majorMinor != "all" {
-		low = "5.2.0"
-		high = low
-		reported = majorMinor
-		majorMinor = "5.2"
+	lowVersion := semver.Version{Major: v.Major, Minor: v.Minor}
+	if lowIn != "" {
+		if lowVersion, err = parseVersion(lowIn); err != nil {
+			return "", "", "", "", err
+		}
 	}
 
-	reported = version
-
-	return
+	return lowVersion.String(), highVersion.String(), majorMinorOut, reported, nil
 }
 
-// BreaksVersions takes a PHPCompatibility sniff code and returns the versions that break for that code.
-func BreaksVersions(message tide.PhpcsFilesMessage) []string {
-
-	compat, err := Parse(message)
-
-	if err != nil || strings.ToLower(message.Type) != "error" {
-		return nil
+// versionRange returns the versions from low to high, where "all" reports every supported version.
+func versionRange(compat *CompatibilityRange) (semver.Range, error) {
+	if compat.Reported == "all" {
+		table := currentVersions()
+		low, high := table.oldest(), semver.MustParse(table.latest())
+		return func(v semver.Version) bool { return v.GTE(low) && v.LTE(high) }, nil
 	}
 
-	broken := []string{}
-
-	var rangeString string
-	if compat.Breaks.Reported == "all" {
-		rangeString = ">=5.2.0" + " <=" + PhpLatest
-	} else {
-		rangeString = ">=" + compat.Breaks.Low + " <=" + compat.Breaks.High
+	low, err := parseVersion(compat.Low)
+	if err != nil {
+		return nil, err
+	}
+	high, err := parseVersion(compat.High)
+	if err != nil {
+		return nil, err
 	}
+	return func(v semver.Version) bool { return v.GTE(low) && v.LTE(high) }, nil
+}
 
-	failRange, _ := semver.ParseRange(rangeString)
+// versionsIn returns the supported major.minor versions whose latest release is in the range.
+func versionsIn(compat *CompatibilityRange) []string {
+	versions := []string{}
 
-	for majorMinor, item := range phpVersions {
+	inRange, err := versionRange(compat)
+	if err != nil {
+		return versions
+	}
 
-		if failRange(semver.MustParse(item["max"])) {
-			broken = append(broken, majorMinor)
+	table := currentVersions()
+	for _, version := range table.versions {
+		if inRange(table.max[version.MajorMinor]) {
+			versions = append(versions, version.MajorMinor)
 		}
 	}
 
-	sort.Strings(broken)
-
-	return broken
+	return versions
 }
 
-// NonBreakingVersions takes a PHPCompatibility sniff code and returns the versions that are warnings.
-func NonBreakingVersions(message tide.PhpcsFilesMessage) []string {
+// BreaksVersions takes a PHPCompatibility sniff code and returns the versions that break for that code.
+func BreaksVersions(message tide.PhpcsFilesMessage) []string {
 
 	compat, err := Parse(message)
 
-	if err != nil || strings.ToLower(message.Type) != "warning" {
+	if err != nil || strings.ToLower(message.Type) != "error" || compat.Breaks == nil {
 		return nil
 	}
 
-	versions := []string{}
-
-	var rangeString string
-	if compat.Warns.Reported == "all" {
-		rangeString = ">=5.2.0" + " <=" + PhpLatest
-	} else {
-		rangeString = ">=" + compat.Warns.Low + " <=" + compat.Warns.High
-	}
+	return versionsIn(compat.Breaks)
+}
 
-	failRange, _ := semver.ParseRange(rangeString)
+// NonBreakingVersions takes a PHPCompatibility sniff code and returns the versions that are warnings.
+func NonBreakingVersions(message tide.PhpcsFilesMessage) []string {
 
-	for majorMinor, item := range phpVersions {
+	compat, err := Parse(message)
 
-		if failRange(semver.MustParse(item["max"])) {
-			versions = append(versions, majorMinor)
-		}
+	if err != nil || strings.ToLower(message.Type) != "warning" || compat.Warns == nil {
+		return nil
 	}
 
-	sort.Strings(versions)
-
-	return versions
+	return versionsIn(compat.Warns)
 }
 
-// PhpMajorVersions returns only the major.minor parts from the `versions` variable as slice of strings.
+// PhpMajorVersions returns the major.minor versions compatibility is reported for, oldest first.
 func PhpMajorVersions() []string {
 	versions := []string{}
 
-	for key := range phpVersions {
-		versions = append(versions, key)
+	for _, version := range currentVersions().versions {
+		versions = append(versions, version.MajorMinor)
 	}
 
-	sort.Strings(versions)
-
 	return versions
 }
 
@@ -2,6 +2,7 @@ package phpcompat
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/wptide/pkg/tide"
@@ -35,28 +36,28 @@ func TestBreaksVersions(t *testing.T) {
 			args{
 				testMessages["PHPCompatibility.PHP.ForbiddenNames.constFound"],
 			},
-			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3"},
+			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.DeprecatedFunctions.mysqli_send_long_dataDeprecatedRemoved",
 			args{
 				testMessages["PHPCompatibility.PHP.DeprecatedFunctions.mysqli_send_long_dataDeprecatedRemoved"],
 			},
-			[]string{"5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3"},
+			[]string{"5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.DeprecatedFunctions.mcrypt_cfbDeprecatedRemoved",
 			args{
 				testMessages["PHPCompatibility.PHP.DeprecatedFunctions.mcrypt_cfbDeprecatedRemoved"],
 			},
-			[]string{"7.0", "7.1", "7.2", "7.3"},
+			[]string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.ForbiddenNames.cloneFound",
 			args{
 				testMessages["PHPCompatibility.PHP.ForbiddenNames.cloneFound"],
 			},
-			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3"},
+			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.DynamicAccessToStatic.Found",
@@ -70,14 +71,14 @@ func TestBreaksVersions(t *testing.T) {
 			args{
 				testMessages["PHPCompatibility.PHP.ValidIntegers.HexNumericStringFound"],
 			},
-			[]string{"7.0", "7.1", "7.2", "7.3"},
+			[]string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.ValidIntegers.InvalidOctalIntegerFound",
 			args{
 				testMessages["PHPCompatibility.PHP.ValidIntegers.InvalidOctalIntegerFound"],
 			},
-			[]string{"7.0", "7.1", "7.2", "7.3"},
+			[]string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.LanguageConstructs.NewEmptyNonVariableFound",
@@ -105,7 +106,7 @@ func TestBreaksVersions(t *testing.T) {
 			args{
 				testMessages["PHPCompatibility.PHP.NonStaticMagicMethods.__getMethodVisibility"],
 			},
-			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3"},
+			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.ShortArray.Found",
@@ -119,7 +120,7 @@ func TestBreaksVersions(t *testing.T) {
 			args{
 				testMessages["PHPCompatibility.PHP.ForbiddenSwitchWithMultipleDefaultBlocks.Found"],
 			},
-			[]string{"7.0", "7.1", "7.2", "7.3"},
+			[]string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			// Warnings only, no breaks.
@@ -168,35 +169,35 @@ func TestNonBreakingVersions(t *testing.T) {
 			args{
 				testMessages["PHPCompatibility.PHP.RemovedConstants.intl_idna_variant_2003Deprecated"],
 			},
-			[]string{"7.2", "7.3"},
+			[]string{"7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.FakeAllWarning",
 			args{
 				testMessages["PHPCompatibility.PHP.FakeAllWarning"],
 			},
-			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3"},
+			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.DeprecatedFunctions.mcrypt_generic_deinitDeprecated",
 			args{
 				testMessages["PHPCompatibility.PHP.DeprecatedFunctions.mcrypt_generic_deinitDeprecated"],
 			},
-			[]string{"7.1", "7.2", "7.3"},
+			[]string{"7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.DeprecatedPHP4StyleConstructors.Found",
 			args{
 				testMessages["PHPCompatibility.PHP.DeprecatedPHP4StyleConstructors.Found"],
 			},
-			[]string{"7.0", "7.1", "7.2", "7.3"},
+			[]string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.ForbiddenNamesAsDeclared.resourceFound",
 			args{
 				testMessages["PHPCompatibility.PHP.ForbiddenNamesAsDeclared.resourceFound"],
 			},
-			[]string{"7.0", "7.1", "7.2", "7.3"},
+			[]string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.FakeAvailableSinceWarning",
@@ -210,7 +211,7 @@ func TestNonBreakingVersions(t *testing.T) {
 			args{
 				testMessages["PHPCompatibility.PHP.FakeSinceWarning"],
 			},
-			[]string{"7.0", "7.1", "7.2", "7.3"},
+			[]string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 		{
 			"PHPCompatibility.PHP.NewFunctions.random_bytesFound",
@@ -267,25 +268,46 @@ func TestPreviousVersion(t *testing.T) {
 			"5.4.45",
 		},
 		{
-			"7.4.0 -> 7.3.8",
+			"8.1.0 -> 8.0.30",
+			args{
+				"8.1",
+			},
+			"8.0.30",
+		},
+		{
+			"8.0.0 -> 7.4.33",
+			args{
+				"8.0.0",
+			},
+			"7.4.33",
+		},
+		{
+			"8.0.5 -> 8.0.4",
+			args{
+				"8.0.5",
+			},
+			"8.0.4",
+		},
+		{
+			"7.4.0 -> 7.3.33",
 			args{
 				"7.4.0",
 			},
-			"7.3.8",
+			"7.3.33",
 		},
 		{
-			"7.3.0 -> 7.2.21",
+			"7.3.0 -> 7.2.34",
 			args{
 				"7.3.0",
 			},
-			"7.2.21",
+			"7.2.34",
 		},
 		{
-			"7.2.0 -> 7.1.31",
+			"7.2.0 -> 7.1.33",
 			args{
 				"7.2.0",
 			},
-			"7.1.31",
+			"7.1.33",
 		},
 		{
 			"7.1.0 -> 7.0.33",
@@ -343,26 +365,62 @@ func TestPreviousVersion(t *testing.T) {
 			},
 			"all",
 		},
+		{
+			"5.1.6 -> 5.2.0",
+			args{
+				"5.1.6",
+			},
+			"5.2.0",
+		},
+		{
+			"7 -> 5.6.40",
+			args{
+				"7",
+			},
+			"5.6.40",
+		},
+		{
+			"8.4.0 -> 8.3.26",
+			args{
+				"8.4.0",
+			},
+			"8.3.26",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := PreviousVersion(tt.args.version); got != tt.want {
+			got, err := PreviousVersion(tt.args.version)
+			if err != nil {
+				t.Fatalf("PreviousVersion() error = %v", err)
+			}
+			if got != tt.want {
 				t.Errorf("PreviousVersion() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
 
+func TestPreviousVersion_Invalid(t *testing.T) {
+	for _, version := range []string{"", "7.x", "7.4.1.2", "-7.0", "7.4.0-beta"} {
+		t.Run(version, func(t *testing.T) {
+			if got, err := PreviousVersion(version); err == nil {
+				t.Errorf("PreviousVersion() = %v, expected an error", got)
+			}
+		})
+	}
+}
+
 func TestVersionParts(t *testing.T) {
 	type args struct {
 		version string
 	}
 	tests := []struct {
-		name  string
-		args  args
-		want  int
-		want1 int
-		want2 int
+		name    string
+		args    args
+		want    int
+		want1   int
+		want2   int
+		wantErr bool
 	}{
 		{
 			"all -> 0 0 0",
@@ -372,6 +430,7 @@ func TestVersionParts(t *testing.T) {
 			0,
 			0,
 			0,
+			false,
 		},
 		{
 			"7.2.1 -> 7 2 1",
@@ -381,11 +440,35 @@ func TestVersionParts(t *testing.T) {
 			7,
 			2,
 			1,
+			false,
+		},
+		{
+			"7.2 -> 7 2 0",
+			args{
+				"7.2",
+			},
+			7,
+			2,
+			0,
+			false,
+		},
+		{
+			"seven -> error",
+			args{
+				"seven",
+			},
+			0,
+			0,
+			0,
+			true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, got1, got2 := VersionParts(tt.args.version)
+			got, got1, got2, err := VersionParts(tt.args.version)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VersionParts() error = %v, wantErr %v", err, tt.wantErr)
+			}
 			if got != tt.want {
 				t.Errorf("VersionParts() got = %v, want %v", got, tt.want)
 			}
@@ -411,6 +494,7 @@ func TestGetVersionParts(t *testing.T) {
 		wantHigh       string
 		wantMajorMinor string
 		wantReported   string
+		wantErr        bool
 	}{
 		{
 			"all",
@@ -422,6 +506,7 @@ func TestGetVersionParts(t *testing.T) {
 			"all",
 			"all",
 			"all",
+			false,
 		},
 		{
 			"5.6.4",
@@ -433,6 +518,7 @@ func TestGetVersionParts(t *testing.T) {
 			"5.6.4",
 			"5.6",
 			"5.6.4",
+			false,
 		},
 		{
 			"5.6.4, 5.5.1",
@@ -444,6 +530,7 @@ func TestGetVersionParts(t *testing.T) {
 			"5.6.4",
 			"5.6",
 			"5.6.4",
+			false,
 		},
 		{
 			"7.2",
@@ -452,9 +539,10 @@ func TestGetVersionParts(t *testing.T) {
 				"",
 			},
 			"7.2.0",
-			"7.2.21",
+			"7.2.34",
 			"7.2",
 			"7.2",
+			false,
 		},
 		{
 			"5.4",
@@ -466,6 +554,7 @@ func TestGetVersionParts(t *testing.T) {
 			"5.4.45",
 			"5.4",
 			"5.4",
+			false,
 		},
 		{
 			"5.0",
@@ -477,11 +566,51 @@ func TestGetVersionParts(t *testing.T) {
 			"5.2.0",
 			"5.2",
 			"5.0",
+			false,
+		},
+		{
+			"Unsupported",
+			args{
+				"9.9",
+				"",
+			},
+			"",
+			"",
+			"",
+			"",
+			true,
+		},
+		{
+			"Invalid",
+			args{
+				"7.4.x",
+				"",
+			},
+			"",
+			"",
+			"",
+			"",
+			true,
+		},
+		{
+			"Invalid Low",
+			args{
+				"7.4",
+				"7..1",
+			},
+			"",
+			"",
+			"",
+			"",
+			true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotLow, gotHigh, gotMajorMinor, gotReported := GetVersionParts(tt.args.version, tt.args.lowIn)
+			gotLow, gotHigh, gotMajorMinor, gotReported, err := GetVersionParts(tt.args.version, tt.args.lowIn)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetVersionParts() error = %v, wantErr %v", err, tt.wantErr)
+			}
 			if gotLow != tt.wantLow {
 				t.Errorf("GetVersionParts() gotLow = %v, want %v", gotLow, tt.wantLow)
 			}
@@ -505,7 +634,7 @@ func TestPhpMajorVersions(t *testing.T) {
 	}{
 		{
 			"Get all major.minor versions",
-			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3"},
+			[]string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
 		},
 	}
 	for _, tt := range tests {
@@ -517,6 +646,60 @@ func TestPhpMajorVersions(t *testing.T) {
 	}
 }
 
+func TestSetPhpVersions(t *testing.T) {
+	restore := SetPhpVersions([]PhpVersion{
+		{"9.0", "9.0.2"},
+		{"8.4", "8.4.12"},
+		{"10.0", "10.0.0"},
+	})
+
+	if PhpLatest != "10.0.0" {
+		t.Errorf("SetPhpVersions() PhpLatest = %v, want 10.0.0", PhpLatest)
+	}
+	if got, want := PhpMajorVersions(), []string{"8.4", "9.0", "10.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PhpMajorVersions() = %v, want %v", got, want)
+	}
+
+	previous := map[string]string{"10.0.0": "9.0.2", "9.0": "8.4.12", "9.0.1": "9.0.0"}
+	for version, want := range previous {
+		if got, _ := PreviousVersion(version); got != want {
+			t.Errorf("PreviousVersion(%v) = %v, want %v", version, got, want)
+		}
+	}
+
+	msg := tide.PhpcsFilesMessage{Message: "The function foo() is not present in PHP version 9.0 or earlier", Source: "PHPCompatibility.FunctionUse.NewFunctions.fooFound", Type: "ERROR"}
+	if got, want := BreaksVersions(msg), []string{"8.4", "9.0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("BreaksVersions() = %v, want %v", got, want)
+	}
+
+	restore()
+	if PhpLatest != "8.3.26" || len(PhpMajorVersions()) != len(DefaultPhpVersions) {
+		t.Errorf("SetPhpVersions() restore = %v, %v", PhpLatest, PhpMajorVersions())
+	}
+}
+
+func TestSetPhpVersions_Concurrent(t *testing.T) {
+	msg := tide.PhpcsFilesMessage{Message: "The function foo() is not present in PHP version 7.0 or earlier", Source: "PHPCompatibility.FunctionUse.NewFunctions.fooFound", Type: "ERROR"}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			SetPhpVersions([]PhpVersion{{"7.0", "7.0.33"}, {"8.4", "8.4.12"}})()
+		}
+	}()
+
+	// Audits read the versions while they are replaced.
+	for i := 0; i < 100; i++ {
+		if got := BreaksVersions(msg); len(got) == 0 || got[len(got)-1] != "7.0" {
+			t.Fatalf("BreaksVersions() = %v", got)
+		}
+		PhpMajorVersions()
+	}
+	wg.Wait()
+}
+
 func TestMergeVersions(t *testing.T) {
 	type args struct {
 		n [][]string
@@ -581,3 +764,51 @@ func TestExcludeVersions(t *testing.T) {
 		})
 	}
 }
+
+func FuzzPreviousVersion(f *testing.F) {
+	for _, seed := range []string{"all", "5.2", "7.0.0", "8.1", "8.0.5", "7", "", "7.x", "1.2.3.4", "99999999999999999999.1"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, version string) {
+		previous, err := PreviousVersion(version)
+		if err != nil || version == "all" {
+			return
+		}
+
+		v, _ := parseVersion(version)
+		p, err := parseVersion(previous)
+		if err != nil {
+			t.Fatalf("PreviousVersion(%q) = %q, not a version", version, previous)
+		}
+		if p.GT(v) && !p.Equals(currentVersions().oldest()) {
+			t.Errorf("PreviousVersion(%q) = %q, a later version", version, previous)
+		}
+	})
+}
+
+func FuzzGetVersionParts(f *testing.F) {
+	f.Add("all", "")
+	f.Add("7.2", "")
+	f.Add("5.4", "5.2")
+	f.Add("5.0", "")
+	f.Add("5.6.4", "5.5.1")
+	f.Add("7.4.x", "")
+	f.Fuzz(func(t *testing.T, version, lowIn string) {
+		low, high, majorMinor, reported, err := GetVersionParts(version, lowIn)
+		if err != nil {
+			if low != "" || high != "" || majorMinor != "" || reported != "" {
+				t.Errorf("GetVersionParts(%q, %q) returned %q, %q, %q, %q with error %v", version, lowIn, low, high, majorMinor, reported, err)
+			}
+			return
+		}
+		if version == "all" {
+			return
+		}
+
+		for _, v := range []string{low, high, majorMinor} {
+			if _, err := parseVersion(v); err != nil {
+				t.Errorf("GetVersionParts(%q, %q) = %q, %q, %q, not versions", version, lowIn, low, high, majorMinor)
+			}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package phpcompat
+
+import (
+	"sort"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// CompatibilityReport is the PHP compatibility of the violations of a project.
+type CompatibilityReport struct {
+	Min          string              `json:"min,omitempty"`   // Oldest version of the supported range, e.g. "7.0".
+	Max          string              `json:"max,omitempty"`   // Newest version of the supported range, e.g. "8.2".
+	Range        string              `json:"range,omitempty"` // The supported range, e.g. "7.0–8.2", or "7.4" for a single version.
+	Compatible   []string            `json:"compatible"`      // Versions without violations that break them, oldest first.
+	Incompatible []string            `json:"incompatible"`    // Versions that violations break, oldest first.
+	Blocking     map[string][]string `json:"blocking"`        // Sniff sources of the violations that break each incompatible version.
+	WarningOnly  []string            `json:"warning_only"`    // Compatible versions with warnings, oldest first.
+}
+
+// ComputeCompatibility returns the compatibility of the violations. The supported range is the
+// longest run of consecutive compatible versions, the newest one if there are several. Projects
+// that break every version have no supported range.
+func ComputeCompatibility(messages []tide.PhpcsFilesMessage) CompatibilityReport {
+	annotations := make([]Annotation, len(messages))
+	versions := newVersionCache()
+	for i, msg := range messages {
+		annotations[i] = versions.annotate("", msg)
+	}
+	return computeCompatibility(annotations)
+}
+
+// computeCompatibility returns the compatibility of the annotated violations.
+func computeCompatibility(annotations []Annotation) CompatibilityReport {
+	blocking := make(map[string]map[string]bool)
+	warned := make(map[string]bool)
+
+	for _, annotation := range annotations {
+		for _, version := range annotation.Breaks {
+			if blocking[version] == nil {
+				blocking[version] = make(map[string]bool)
+			}
+			blocking[version][annotation.Source] = true
+		}
+		for _, version := range annotation.Warns {
+			warned[version] = true
+		}
+	}
+
+	report := CompatibilityReport{
+		Compatible:   []string{},
+		Incompatible: []string{},
+		Blocking:     make(map[string][]string),
+		WarningOnly:  []string{},
+	}
+
+	var run, longest []string
+	for _, version := range PhpMajorVersions() {
+		sources, ok := blocking[version]
+		if !ok {
+			report.Compatible = append(report.Compatible, version)
+			if warned[version] {
+				report.WarningOnly = append(report.WarningOnly, version)
+			}
+			run = append(run, version)
+			if len(run) >= len(longest) {
+				longest = run
+			}
+			continue
+		}
+
+		report.Incompatible = append(report.Incompatible, version)
+		for source := range sources {
+			report.Blocking[version] = append(report.Blocking[version], source)
+		}
+		sort.Strings(report.Blocking[version])
+		run = nil
+	}
+
+	if len(longest) != 0 {
+		report.Min, report.Max = longest[0], longest[len(longest)-1]
+		report.Range = report.Min
+		if report.Max != report.Min {
+			report.Range += "–" + report.Max
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,97 @@
+package phpcompat
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func TestComputeCompatibility(t *testing.T) {
+	randomBytes := testMessages["PHPCompatibility.PHP.NewFunctions.random_bytesFound"]
+	deprecated := testMessages["PHPCompatibility.PHP.RemovedConstants.intl_idna_variant_2003Deprecated"]
+	removedIn := func(version string) tide.PhpcsFilesMessage {
+		return tide.PhpcsFilesMessage{Message: "Function foo() is removed since PHP " + version, Source: "PHPCompatibility.FunctionUse.RemovedFunctions.fooRemoved", Type: "ERROR"}
+	}
+
+	all := PhpMajorVersions()
+
+	tests := []struct {
+		name     string
+		messages []tide.PhpcsFilesMessage
+		want     CompatibilityReport
+	}{
+		{
+			"No Violations",
+			nil,
+			CompatibilityReport{
+				Min: "5.2", Max: "8.3", Range: "5.2–8.3",
+				Compatible: all, Incompatible: []string{}, Blocking: map[string][]string{}, WarningOnly: []string{},
+			},
+		},
+		{
+			"New Function",
+			[]tide.PhpcsFilesMessage{randomBytes, randomBytes, deprecated},
+			CompatibilityReport{
+				Min: "7.0", Max: "8.3", Range: "7.0–8.3",
+				Compatible:   []string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
+				Incompatible: []string{"5.2", "5.3", "5.4", "5.5", "5.6"},
+				Blocking: map[string][]string{
+					"5.2": {randomBytes.Source}, "5.3": {randomBytes.Source}, "5.4": {randomBytes.Source},
+					"5.5": {randomBytes.Source}, "5.6": {randomBytes.Source},
+				},
+				WarningOnly: []string{"7.2", "7.3", "7.4", "8.0", "8.1", "8.2", "8.3"},
+			},
+		},
+		{
+			"Removed Function",
+			[]tide.PhpcsFilesMessage{randomBytes, removedIn("8.3")},
+			CompatibilityReport{
+				Min: "7.0", Max: "8.2", Range: "7.0–8.2",
+				Compatible:   []string{"7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2"},
+				Incompatible: []string{"5.2", "5.3", "5.4", "5.5", "5.6", "8.3"},
+				Blocking: map[string][]string{
+					"5.2": {randomBytes.Source}, "5.3": {randomBytes.Source}, "5.4": {randomBytes.Source},
+					"5.5": {randomBytes.Source}, "5.6": {randomBytes.Source}, "8.3": {"PHPCompatibility.FunctionUse.RemovedFunctions.fooRemoved"},
+				},
+				WarningOnly: []string{},
+			},
+		},
+		{
+			"Single Version",
+			[]tide.PhpcsFilesMessage{{Message: "The function foo() is not present in PHP version 8.2 or earlier", Source: "PHPCompatibility.FunctionUse.NewFunctions.fooFound", Type: "ERROR"}},
+			CompatibilityReport{
+				Min: "8.3", Max: "8.3", Range: "8.3",
+				Compatible:   []string{"8.3"},
+				Incompatible: []string{"5.2", "5.3", "5.4", "5.5", "5.6", "7.0", "7.1", "7.2", "7.3", "7.4", "8.0", "8.1", "8.2"},
+				WarningOnly:  []string{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeCompatibility(tt.messages)
+			if tt.want.Blocking == nil {
+				got.Blocking = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComputeCompatibility() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeCompatibility_Gaps(t *testing.T) {
+	restore := SetPhpVersions([]PhpVersion{{"7.0", "7.0.33"}, {"7.1", "7.1.33"}, {"7.2", "7.2.34"}, {"7.3", "7.3.33"}, {"7.4", "7.4.33"}})
+	defer restore()
+
+	// Only 7.2 is broken, the newest of the equally long runs is the supported range.
+	msg := tide.PhpcsFilesMessage{Message: "Only broken in PHP 7.2", Source: "Fake.Sniff", Type: "ERROR"}
+	UseDatabase(&Database{Rules: []Rule{{Source: "Fake.Sniff", Breaks: &VersionRange{Low: "7.2", High: "7.2"}}}})
+	defer UseDatabase(nil)
+
+	got := ComputeCompatibility([]tide.PhpcsFilesMessage{msg})
+	if got.Range != "7.3–7.4" || !reflect.DeepEqual(got.Compatible, []string{"7.0", "7.1", "7.3", "7.4"}) {
+		t.Errorf("ComputeCompatibility() = %+v", got)
+	}
+}
@@ -0,0 +1,202 @@
+package phpcompat
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// Suppression describes a known false positive of a PHPCompatibility sniff, e.g. a sniff that
+// predates a PHP 8 feature and reports correct PHP 8 code as breaking.
+type Suppression struct {
+	Name    string // Identifies the suppression in the suppressed violations, e.g. "enum-declaration".
+	Source  string // Sniff code, or a prefix of it ending in ".", e.g. "PHPCompatibility.Keywords.".
+	Message string // (Optional) Regular expression that the message must match.
+	Code    string // (Optional) Regular expression that the reported line of code must match.
+	Reason  string // Why the violation is a false positive.
+}
+
+// DefaultSuppressions are the false positives of PHPCompatibility 9 for PHP 8 code, until the
+// upstream sniffs catch up.
+var DefaultSuppressions = []Suppression{
+	{
+		Name:   "attribute-as-comment",
+		Source: "PHPCompatibility.",
+		Code:   `^\s*#\[`,
+		Reason: "PHP 8 attributes are tokenized as comments, so their arguments are sniffed as code",
+	},
+	{
+		Name:    "enum-declaration",
+		Source:  "PHPCompatibility.Keywords.ForbiddenNames",
+		Message: `(?i)'enum'`,
+		Code:    `(?i)^\s*(final\s+|readonly\s+)*enum\s+\w+`,
+		Reason:  "enum declarations are reported as classes named with the reserved keyword",
+	},
+	{
+		Name:    "enum-member-name",
+		Source:  "PHPCompatibility.Keywords.ForbiddenNames",
+		Message: `(?i)'enum'`,
+		Code:    `(?i)(->|::|function\s+&?)enum\s*\(`,
+		Reason:  "enum is a contextual keyword, methods named enum are valid in PHP 8.1",
+	},
+}
+
+// Suppressed describes a violation that was removed as a false positive.
+type Suppressed struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Source      string `json:"source"`
+	Message     string `json:"message"`
+	Suppression string `json:"suppression"` // Name of the matching Suppression.
+}
+
+// LineReader returns a line of a file, counting from 1. The second value is false if the line
+// can't be read.
+type LineReader func(file string, line int) (string, bool)
+
+// Suppressor removes the false positives from PHPCompatibility results.
+type Suppressor struct {
+	Suppressions []Suppression // (Optional) Defaults to DefaultSuppressions.
+	ReadLine     LineReader    // (Optional) Reads the reported code. Defaults to reading the reported file.
+}
+
+// Filter removes the suppressed messages from the results, updates the totals and returns the
+// removed messages. Suppressions that check the code don't match messages whose line can't be read.
+func (s Suppressor) Filter(results *tide.PhpcsResults) ([]Suppressed, error) {
+	suppressions := s.Suppressions
+	if suppressions == nil {
+		suppressions = DefaultSuppressions
+	}
+
+	rules, err := compileSuppressions(suppressions)
+	if err != nil {
+		return nil, err
+	}
+
+	readLine := s.ReadLine
+	if readLine == nil {
+		readLine = fileLineReader()
+	}
+
+	var suppressed []Suppressed
+	for filename, file := range results.Files {
+		var kept []tide.PhpcsFilesMessage
+		for _, msg := range file.Messages {
+			name, ok := matchSuppression(rules, filename, msg, readLine)
+			if !ok {
+				kept = append(kept, msg)
+				continue
+			}
+
+			suppressed = append(suppressed, Suppressed{filename, msg.Line, msg.Source, msg.Message, name})
+			switch strings.ToUpper(msg.Type) {
+			case "ERROR":
+				file.Errors--
+				results.Totals.Errors--
+			case "WARNING":
+				file.Warnings--
+				results.Totals.Warnings--
+			}
+		}
+
+		if len(kept) != len(file.Messages) {
+			file.Messages = kept
+			results.Files[filename] = file
+		}
+	}
+
+	return suppressed, nil
+}
+
+// suppressionRule is a Suppression with compiled expressions.
+type suppressionRule struct {
+	Suppression
+	message *regexp.Regexp
+	code    *regexp.Regexp
+}
+
+// compileSuppressions compiles the expressions of the suppressions.
+func compileSuppressions(suppressions []Suppression) ([]suppressionRule, error) {
+	rules := make([]suppressionRule, len(suppressions))
+	for i, suppression := range suppressions {
+		rules[i].Suppression = suppression
+
+		var err error
+		if suppression.Message != "" {
+			if rules[i].message, err = regexp.Compile(suppression.Message); err != nil {
+				return nil, err
+			}
+		}
+		if suppression.Code != "" {
+			if rules[i].code, err = regexp.Compile(suppression.Code); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rules, nil
+}
+
+// matchSuppression returns the name of the first rule that matches the message.
+func matchSuppression(rules []suppressionRule, filename string, msg tide.PhpcsFilesMessage, readLine LineReader) (string, bool) {
+	for _, rule := range rules {
+		if !matchSource(rule.Source, msg.Source) {
+			continue
+		}
+		if rule.message != nil && !rule.message.MatchString(msg.Message) {
+			continue
+		}
+		if rule.code != nil {
+			line, ok := readLine(filename, msg.Line)
+			if !ok || !rule.code.MatchString(line) {
+				continue
+			}
+		}
+		return rule.Name, true
+	}
+	return "", false
+}
+
+// matchSource determines if the sniff code is the source, or starts with the source if it
+// ends in ".".
+func matchSource(source, code string) bool {
+	if strings.HasSuffix(source, ".") {
+		return strings.HasPrefix(code, source)
+	}
+	return code == source || strings.HasPrefix(code, source+".")
+}
+
+// fileLineReader returns a LineReader that reads each file once.
+func fileLineReader() LineReader {
+	files := make(map[string][]string)
+	return func(file string, line int) (string, bool) {
+		lines, ok := files[file]
+		if !ok {
+			lines = readLines(file)
+			files[file] = lines
+		}
+		if line < 1 || line > len(lines) {
+			return "", false
+		}
+		return lines[line-1], true
+	}
+}
+
+// readLines returns the lines of a file, or nil if it can't be read.
+func readLines(file string) []string {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
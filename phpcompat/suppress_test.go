@@ -0,0 +1,159 @@
+package phpcompat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func suppressResults(messages ...tide.PhpcsFilesMessage) *tide.PhpcsResults {
+	results := &tide.PhpcsResults{}
+	results.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{}
+
+	file := results.Files["plugin.php"]
+	for _, msg := range messages {
+		if msg.Type == "ERROR" {
+			file.Errors++
+		} else {
+			file.Warnings++
+		}
+		file.Messages = append(file.Messages, msg)
+	}
+	results.Files["plugin.php"] = file
+	results.Totals.Errors = file.Errors
+	results.Totals.Warnings = file.Warnings
+
+	return results
+}
+
+func TestSuppressor_Filter(t *testing.T) {
+	code := map[int]string{
+		3: "#[Attribute(Attribute::TARGET_CLASS, flags: true)]",
+		5: "enum Suit: string",
+		7: "    public static function enum( $value ) {",
+		9: "class enum {}",
+	}
+	readLine := func(file string, line int) (string, bool) {
+		text, ok := code[line]
+		return text, ok
+	}
+
+	attribute := tide.PhpcsFilesMessage{Source: "PHPCompatibility.FunctionUse.NewNamedParameters.Found", Message: "Named arguments are not present in PHP version 7.4 or earlier", Type: "ERROR", Line: 3}
+	declaration := tide.PhpcsFilesMessage{Source: "PHPCompatibility.Keywords.ForbiddenNames.enumFound", Message: "'enum' is a reserved keyword as of PHP version 8.1 and cannot be used to name a class", Type: "ERROR", Line: 5}
+	method := tide.PhpcsFilesMessage{Source: "PHPCompatibility.Keywords.ForbiddenNames.enumFound", Message: "'enum' is a reserved keyword as of PHP version 8.1", Type: "WARNING", Line: 7}
+	class := tide.PhpcsFilesMessage{Source: "PHPCompatibility.Keywords.ForbiddenNames.enumFound", Message: "'enum' is a reserved keyword as of PHP version 8.1 and cannot be used to name a class", Type: "ERROR", Line: 9}
+	unreadable := tide.PhpcsFilesMessage{Source: "PHPCompatibility.Keywords.ForbiddenNames.enumFound", Message: "'enum' is a reserved keyword as of PHP version 8.1", Type: "ERROR", Line: 20}
+	other := tide.PhpcsFilesMessage{Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Message: "Not escaped", Type: "ERROR", Line: 3}
+
+	tests := []struct {
+		name         string
+		suppressions []Suppression
+		messages     []tide.PhpcsFilesMessage
+		wantKept     []tide.PhpcsFilesMessage
+		wantNames    []string
+		wantErr      bool
+	}{
+		{
+			"Default Suppressions",
+			nil,
+			[]tide.PhpcsFilesMessage{attribute, declaration, method, class, unreadable, other},
+			[]tide.PhpcsFilesMessage{class, unreadable, other},
+			[]string{"attribute-as-comment", "enum-declaration", "enum-member-name"},
+			false,
+		},
+		{
+			"Message Only",
+			[]Suppression{{Name: "named-arguments", Source: "PHPCompatibility.FunctionUse.", Message: "Named arguments"}},
+			[]tide.PhpcsFilesMessage{attribute, declaration},
+			[]tide.PhpcsFilesMessage{declaration},
+			[]string{"named-arguments"},
+			false,
+		},
+		{
+			"No Suppressions",
+			[]Suppression{},
+			[]tide.PhpcsFilesMessage{attribute, declaration},
+			[]tide.PhpcsFilesMessage{attribute, declaration},
+			nil,
+			false,
+		},
+		{
+			"Invalid Expression",
+			[]Suppression{{Name: "invalid", Source: "PHPCompatibility.", Code: "("}},
+			[]tide.PhpcsFilesMessage{attribute},
+			[]tide.PhpcsFilesMessage{attribute},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := suppressResults(tt.messages...)
+
+			suppressed, err := (Suppressor{Suppressions: tt.suppressions, ReadLine: readLine}).Filter(results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Suppressor.Filter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := results.Files["plugin.php"].Messages; !reflect.DeepEqual(got, tt.wantKept) {
+				t.Errorf("Suppressor.Filter() kept = %v, want %v", got, tt.wantKept)
+			}
+
+			var names []string
+			for _, s := range suppressed {
+				names = append(names, s.Suppression)
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Suppressor.Filter() suppressed = %v, want %v", names, tt.wantNames)
+			}
+
+			want := suppressResults(tt.wantKept...)
+			if !reflect.DeepEqual(results.Totals, want.Totals) || results.Files["plugin.php"].Errors != want.Files["plugin.php"].Errors {
+				t.Errorf("Suppressor.Filter() totals = %v, want %v", results.Totals, want.Totals)
+			}
+		})
+	}
+}
+
+func Test_fileLineReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phpcompat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "plugin.php")
+	ioutil.WriteFile(file, []byte("<?php\n#[ReturnTypeWillChange]\npublic function count() {}\n"), 0644)
+
+	readLine := fileLineReader()
+
+	tests := []struct {
+		name   string
+		file   string
+		line   int
+		want   string
+		wantOk bool
+	}{
+		{"First Line", file, 1, "<?php", true},
+		{"Attribute", file, 2, "#[ReturnTypeWillChange]", true},
+		{"Past The End", file, 4, "", false},
+		{"Zero", file, 0, "", false},
+		{"Missing File", filepath.Join(dir, "missing.php"), 1, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := readLine(tt.file, tt.line)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("fileLineReader() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
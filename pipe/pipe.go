@@ -11,7 +11,6 @@ import (
 // Pipe represents a pipe that contains multiple processes.
 type Pipe struct {
 	processes  []process.Processor
-	errors     []<-chan error
 	context    context.Context
 	cancelFunc context.CancelFunc
 }
@@ -61,15 +60,21 @@ func (p *Pipe) AddProcesses(procs ...process.Processor) error {
 }
 
 // Run iterates over the processes slice and starts each process.
-func (p *Pipe) Run(errc *chan error) error {
-	defer p.cancelFunc()
-
+// Errors raised by any of the processes are reported to the given sink.
+// If a process fails to start, the processes that are already running are stopped.
+func (p *Pipe) Run(sink process.ErrorSink) error {
 	for _, proc := range p.processes {
-		err := proc.Run(errc)
+		err := proc.Run(sink)
 		if err != nil {
+			p.Stop()
 			return err
 		}
 	}
 
 	return nil
 }
+
+// Stop cancels the pipe's context, which closes the processes' out channels and stops them.
+func (p *Pipe) Stop() {
+	p.cancelFunc()
+}
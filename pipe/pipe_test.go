@@ -16,11 +16,11 @@ type mockProcess struct {
 	shouldErr bool
 }
 
-func (m mockProcess) Run(errc *chan error) error {
+func (m mockProcess) Run(sink process.ErrorSink) error {
 
 	switch m.option {
 	case "error":
-		*errc <- errors.New("something went wrong")
+		sink.Report(process.NewError("Mock", message.Message{}, errors.New("something went wrong")))
 	}
 
 	if m.shouldErr {
@@ -31,13 +31,16 @@ func (m mockProcess) Run(errc *chan error) error {
 }
 
 func (m mockProcess) SetContext(ctx context.Context) {}
+func (m mockProcess) Done() <-chan struct{}          { return nil }
 func (m mockProcess) SetMessage(msg message.Message) {}
 func (m mockProcess) GetMessage() message.Message    { return message.Message{} }
 func (m mockProcess) SetResults(res *process.Result) {}
 func (m mockProcess) GetResult() *process.Result     { return nil }
 func (m mockProcess) SetFilesPath(path string)       {}
 func (m mockProcess) GetFilesPath() string           { return "" }
-func (m mockProcess) Do() error                      { return nil }
+func (m mockProcess) Do(ctx context.Context, msg message.Message, res *process.Result) (*process.Result, error) {
+	return res, nil
+}
 
 func TestNew(t *testing.T) {
 	tests := []struct {
@@ -188,7 +191,7 @@ func TestPipe_Run(t *testing.T) {
 
 			var err error
 			var chanError error
-			errc := make(chan error)
+			errc := make(process.ErrorChannel)
 
 			go func() {
 				for {
@@ -200,7 +203,7 @@ func TestPipe_Run(t *testing.T) {
 			}()
 
 			go func() {
-				err = tt.p.Run(&errc)
+				err = tt.p.Run(errc)
 			}()
 
 			// Sleep a short time delay to give process time to start.
@@ -216,3 +219,14 @@ func TestPipe_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestPipe_Stop(t *testing.T) {
+	p := New()
+	p.Stop()
+
+	select {
+	case <-p.context.Done():
+	default:
+		t.Errorf("Pipe.Stop() did not cancel the context")
+	}
+}
@@ -0,0 +1,265 @@
+package pipe
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/process"
+)
+
+// Self-test statuses of a component.
+const (
+	SelfTestPass = "pass"
+	SelfTestFail = "fail"
+	SelfTestSkip = "skip" // An earlier component failed, so the component could not be tested.
+)
+
+// SelfTestSlug identifies the fixture message sent by a self-test.
+const SelfTestSlug = "tide-self-test"
+
+// DefaultSelfTestStandards are the PHPCS standards used to audit the fixture plugin.
+var DefaultSelfTestStandards = []string{"wordpress"}
+
+// DefaultQueueTimeout is how long a self-test waits for the fixture message to be received.
+const DefaultQueueTimeout = 30 * time.Second
+
+// sleep waits between attempts to receive the fixture message.
+var sleep = time.Sleep
+
+// fixtureFiles is a tiny plugin that is audited by a self-test.
+var fixtureFiles = map[string]string{
+	"tide-self-test/tide-self-test.php": `<?php
+/**
+ * Plugin Name: Tide Self-Test
+ * Description: Fixture used to verify a Tide worker.
+ * Version: 1.0.0
+ */
+
+function tide_self_test() {
+	return esc_html__( 'Hello, Tide.', 'tide-self-test' );
+}
+`,
+	"tide-self-test/readme.txt": `=== Tide Self-Test ===
+Stable tag: 1.0.0
+
+Fixture used to verify a Tide worker.
+`,
+}
+
+// SelfTestOptions configures a self-test of the pipe.
+type SelfTestOptions struct {
+	Queue        message.Provider // (Optional) Queue to send the fixture message through. It must not receive real traffic.
+	QueueTimeout time.Duration    // (Optional) How long to wait for the fixture message. Defaults to DefaultQueueTimeout.
+	Standards    []string         // (Optional) PHPCS standards to audit the fixture with. Defaults to DefaultSelfTestStandards.
+	Endpoint     string           // (Optional) Where results are submitted. Defaults to a built-in endpoint that discards them.
+}
+
+// ComponentResult is the outcome of testing a single component.
+type ComponentResult struct {
+	Component string        `json:"component"`
+	Status    string        `json:"status"`
+	Code      string        `json:"code,omitempty"` // Failure code, e.g. "STORAGE_FAILURE".
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+// SelfTestReport describes the outcome of a self-test.
+type SelfTestReport struct {
+	Components []ComponentResult `json:"components"`
+}
+
+// Passed returns true if every component passed.
+func (r SelfTestReport) Passed() bool {
+	for _, c := range r.Components {
+		if c.Status != SelfTestPass {
+			return false
+		}
+	}
+	return true
+}
+
+// Write writes the report as indented JSON.
+func (r SelfTestReport) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// SelfTest runs a built-in fixture plugin through the queue and every process of the pipe,
+// e.g. enqueue → ingest → phpcs (including the report upload) → submit, and reports which
+// components passed. The pipe does not need to be running.
+//
+// The processes are called directly in the order they were added, each with the result of the
+// previous one. Once a component fails the remaining components are skipped.
+func (p *Pipe) SelfTest(opts SelfTestOptions) (*SelfTestReport, error) {
+	fixture, err := fixtureZip()
+	if err != nil {
+		return nil, err
+	}
+
+	// Serve the fixture and the dry-run endpoint locally.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tide-self-test.zip", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fixture)
+	})
+	mux.HandleFunc("/dry-run", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.Write([]byte(`{"dry_run":true}`))
+	})
+	go http.Serve(listener, mux)
+
+	baseURL := "http://" + listener.Addr().String()
+	msg := selfTestMessage(baseURL, opts)
+
+	report := &SelfTestReport{}
+	failed := false
+	check := func(component string, fn func() error) {
+		result := ComponentResult{Component: component, Status: SelfTestSkip}
+		if !failed {
+			started := time.Now()
+			err := fn()
+			result.Duration = time.Since(started)
+			result.Status = SelfTestPass
+			if err != nil {
+				failed = true
+				result.Status = SelfTestFail
+				e := process.NewError(component, msg, err)
+				result.Code = e.Code
+				result.Error = err.Error()
+			}
+		}
+		report.Components = append(report.Components, result)
+	}
+
+	if opts.Queue != nil {
+		check("Queue", func() error {
+			return testQueue(opts.Queue, msg, opts.QueueTimeout)
+		})
+	}
+
+	var res *process.Result
+	for _, proc := range p.processes {
+		proc := proc
+		check(componentName(proc), func() error {
+			var err error
+			res, err = proc.Do(p.context, msg, res)
+			return err
+		})
+	}
+
+	return report, nil
+}
+
+// selfTestMessage returns the message for the fixture plugin.
+func selfTestMessage(baseURL string, opts SelfTestOptions) message.Message {
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = baseURL + "/dry-run"
+	}
+
+	standards := opts.Standards
+	if len(standards) == 0 {
+		standards = DefaultSelfTestStandards
+	}
+
+	msg := message.Message{
+		Title:               "Tide Self-Test",
+		Slug:                SelfTestSlug,
+		ProjectType:         "plugin",
+		SourceURL:           baseURL + "/tide-self-test.zip",
+		SourceType:          "zip",
+		ResponseAPIEndpoint: endpoint,
+		Force:               true,
+	}
+	for _, standard := range standards {
+		msg.Audits = append(msg.Audits, &message.Audit{
+			Type:    "phpcs",
+			Options: &message.AuditOption{Standard: standard, Report: "json"},
+		})
+	}
+
+	return msg
+}
+
+// testQueue sends the fixture message through the queue and deletes it once it is received.
+func testQueue(queue message.Provider, msg message.Message, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = DefaultQueueTimeout
+	}
+
+	if err := queue.SendMessage(&msg); err != nil {
+		return err
+	}
+
+	// Messages can be delayed, e.g. by SQS.
+	deadline := time.Now().Add(timeout)
+	for {
+		received, err := queue.GetNextMessage()
+		if received != nil {
+			if received.Slug != SelfTestSlug {
+				return errors.New("received a message that was not sent by the self-test: " + received.Title)
+			}
+			if received.ExternalRef == nil {
+				return nil
+			}
+			return queue.DeleteMessage(received.ExternalRef)
+		}
+
+		if !time.Now().Before(deadline) {
+			if err == nil {
+				err = errors.New("no message received")
+			}
+			return err
+		}
+		sleep(time.Second)
+	}
+}
+
+// componentName returns the name of a process, e.g. "Ingest" for a *process.Ingest.
+func componentName(proc process.Processor) string {
+	t := reflect.TypeOf(proc)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// fixtureZip returns the fixture plugin as a zip archive.
+func fixtureZip() ([]byte, error) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+
+	// Add the files in a stable order so that the checksum is always the same.
+	for _, name := range []string{"tide-self-test/", "tide-self-test/readme.txt", "tide-self-test/tide-self-test.php"} {
+		f, err := w.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write([]byte(fixtureFiles[name])); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,217 @@
+package pipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/payload"
+	"github.com/wptide/pkg/process"
+)
+
+// mockQueue keeps the messages in memory.
+type mockQueue struct {
+	messages []*message.Message
+	deleted  []string
+	other    bool
+}
+
+func (m *mockQueue) SendMessage(msg *message.Message) error {
+	ref := "ref"
+	sent := *msg
+	sent.ExternalRef = &ref
+	if m.other {
+		sent.Slug = "other"
+	}
+	m.messages = append(m.messages, &sent)
+	return nil
+}
+
+func (m *mockQueue) GetNextMessage() (*message.Message, error) {
+	if len(m.messages) == 0 {
+		return nil, errors.New("could not retrieve message")
+	}
+	msg := m.messages[0]
+	m.messages = m.messages[1:]
+	return msg, nil
+}
+
+func (m *mockQueue) DeleteMessage(ref *string) error {
+	m.deleted = append(m.deleted, *ref)
+	return nil
+}
+
+func (m *mockQueue) Close() error { return nil }
+
+// postPayloader posts the result checksum to the endpoint.
+type postPayloader struct{}
+
+func (p postPayloader) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"checksum": data["checksum"]})
+}
+
+func (p postPayloader) SendPayload(destination string, data []byte) ([]byte, error) {
+	resp, err := http.Post(destination, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// auditProcess checks the fixture audits instead of running PHPCS.
+type auditProcess struct {
+	mockProcess
+	fail bool
+}
+
+func (a auditProcess) Do(ctx context.Context, msg message.Message, res *process.Result) (*process.Result, error) {
+	if a.fail {
+		return res, errors.New("phpcs not installed")
+	}
+	if len(msg.Audits) != 1 || msg.Audits[0].Options.Standard != "wordpress" || len(res.Files) != 2 {
+		return res, errors.New("unexpected fixture")
+	}
+	return res, nil
+}
+
+func TestPipe_SelfTest(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	tempFolder, err := ioutil.TempDir("", "selftest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempFolder)
+
+	tests := []struct {
+		name   string
+		queue  *mockQueue
+		fail   bool
+		want   []string
+		passed bool
+	}{
+		{
+			"Pass",
+			&mockQueue{},
+			false,
+			[]string{"Queue:pass", "Ingest:pass", "auditProcess:pass", "Response:pass"},
+			true,
+		},
+		{
+			"Failed Component",
+			&mockQueue{},
+			true,
+			[]string{"Queue:pass", "Ingest:pass", "auditProcess:fail", "Response:skip"},
+			false,
+		},
+		{
+			"Unexpected Queue Message",
+			&mockQueue{other: true},
+			false,
+			[]string{"Queue:fail", "Ingest:skip", "auditProcess:skip", "Response:skip"},
+			false,
+		},
+		{
+			"Without Queue",
+			nil,
+			false,
+			[]string{"Ingest:pass", "auditProcess:pass", "Response:pass"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := WithProcesses(
+				&process.Ingest{TempFolder: tempFolder},
+				auditProcess{fail: tt.fail},
+				&process.Response{Payloaders: map[string]payload.Payloader{payload.TypeTide: postPayloader{}}},
+			)
+
+			opts := SelfTestOptions{QueueTimeout: time.Millisecond}
+			if tt.queue != nil {
+				opts.Queue = tt.queue
+			}
+
+			report, err := p.SelfTest(opts)
+			if err != nil {
+				t.Fatalf("Pipe.SelfTest() error = %v", err)
+			}
+
+			var got []string
+			for _, c := range report.Components {
+				got = append(got, c.Component+":"+c.Status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Pipe.SelfTest() = %v, want %v", got, tt.want)
+			}
+			if report.Passed() != tt.passed {
+				t.Errorf("Pipe.SelfTest() passed = %v", report.Passed())
+			}
+			if tt.queue != nil && !tt.queue.other && !reflect.DeepEqual(tt.queue.deleted, []string{"ref"}) {
+				t.Errorf("Pipe.SelfTest() did not delete the fixture message: %v", tt.queue.deleted)
+			}
+		})
+	}
+}
+
+func Test_testQueue_Timeout(t *testing.T) {
+	sleep = func(time.Duration) {}
+	defer func() { sleep = time.Sleep }()
+
+	// The message is never received.
+	queue := &lostQueue{}
+	if err := testQueue(queue, message.Message{Slug: SelfTestSlug}, time.Millisecond); err == nil {
+		t.Errorf("testQueue() expected an error")
+	}
+	if queue.polls == 0 {
+		t.Errorf("testQueue() did not poll the queue")
+	}
+}
+
+// lostQueue never returns the messages sent to it.
+type lostQueue struct {
+	mockQueue
+	polls int
+}
+
+func (l *lostQueue) GetNextMessage() (*message.Message, error) {
+	l.polls++
+	return nil, nil
+}
+
+func TestSelfTestReport_Write(t *testing.T) {
+	report := SelfTestReport{
+		Components: []ComponentResult{
+			{Component: "Ingest", Status: SelfTestPass, Duration: time.Second},
+			{Component: "Phpcs", Status: SelfTestFail, Code: "STORAGE_FAILURE", Error: "upload error"},
+		},
+	}
+
+	var b bytes.Buffer
+	if err := report.Write(&b); err != nil {
+		t.Fatalf("SelfTestReport.Write() error = %v", err)
+	}
+
+	var got SelfTestReport
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("SelfTestReport.Write() wrote invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, report) || got.Passed() {
+		t.Errorf("SelfTestReport.Write() = %v, want %v", got, report)
+	}
+}
@@ -0,0 +1,234 @@
+// Package policy evaluates pass/fail rules over audit results so that submissions can be
+// gated automatically, e.g. "fail if any security sniff reports an error or the project
+// breaks PHP 7.4".
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// Match modes of a policy.
+const (
+	MatchAny = "any" // Fail if any rule fails.
+	MatchAll = "all" // Fail only if every rule fails.
+)
+
+// Input contains the audit results that rules are evaluated against.
+type Input struct {
+	Audits  map[string]tide.AuditResult   // Audit results keyed by kind, e.g. "phpcs_wordpress".
+	Reports map[string]*tide.PhpcsResults // Full PHPCS reports keyed by kind. Reports that were too large to keep are nil.
+}
+
+// Rule is a single pass/fail condition.
+type Rule interface {
+	// Evaluate returns true and a reason if the input violates the rule.
+	Evaluate(in Input) (bool, string)
+}
+
+// Policy is a named set of rules.
+type Policy struct {
+	Name  string
+	Match string // (Optional) MatchAny or MatchAll. Defaults to MatchAny.
+	Rules []Rule
+}
+
+// Evaluate applies the rules of the policy and returns the verdict.
+func (p Policy) Evaluate(in Input) tide.Verdict {
+	verdict := tide.Verdict{
+		Result: tide.VerdictPass,
+		Policy: p.Name,
+	}
+
+	failed := 0
+	for _, rule := range p.Rules {
+		if fail, reason := rule.Evaluate(in); fail {
+			failed++
+			verdict.Reasons = append(verdict.Reasons, reason)
+		}
+	}
+
+	switch {
+	case p.Match == MatchAll && failed > 0 && failed == len(p.Rules):
+		verdict.Result = tide.VerdictFail
+	case p.Match != MatchAll && failed > 0:
+		verdict.Result = tide.VerdictFail
+	default:
+		verdict.Reasons = nil
+	}
+
+	return verdict
+}
+
+// PhpcsRule fails if a PHPCS report contains a matching message.
+type PhpcsRule struct {
+	Kind        string // (Optional) Audit kind, e.g. "phpcs_wordpress". Defaults to all PHPCS audits.
+	Source      string // (Optional) Sniff prefix, e.g. "WordPress.Security".
+	Type        string // (Optional) "ERROR" or "WARNING". Defaults to both.
+	MinSeverity int    // (Optional) Minimum severity of the message.
+}
+
+// Evaluate implements Rule. Messages without a severity have the PHPCS default severity of 5.
+// The rule fails if a report it applies to was too large to keep, as its messages are unknown.
+func (r PhpcsRule) Evaluate(in Input) (bool, string) {
+	for _, kind := range sortedKeys(in.Reports) {
+		if r.Kind != "" && kind != r.Kind {
+			continue
+		}
+		report := in.Reports[kind]
+		if report == nil {
+			return true, fmt.Sprintf("%s report was too large to evaluate", kind)
+		}
+
+		files := make([]string, 0, len(report.Files))
+		for file := range report.Files {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+
+		for _, file := range files {
+			for _, msg := range report.Files[file].Messages {
+				severity := msg.Severity
+				if severity == 0 {
+					severity = 5
+				}
+
+				if r.Source != "" && !strings.HasPrefix(msg.Source, r.Source) {
+					continue
+				}
+				if r.Type != "" && !strings.EqualFold(msg.Type, r.Type) {
+					continue
+				}
+				if severity < r.MinSeverity {
+					continue
+				}
+
+				return true, fmt.Sprintf("%s reported %s %s in %s on line %d",
+					kind, strings.ToLower(msg.Type), msg.Source, file, msg.Line)
+			}
+		}
+	}
+
+	return false, ""
+}
+
+// sortedKeys returns the report kinds in order.
+func sortedKeys(reports map[string]*tide.PhpcsResults) []string {
+	keys := make([]string, 0, len(reports))
+	for key := range reports {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CompatibilityRule fails if the project is incompatible with a PHP version.
+type CompatibilityRule struct {
+	Version string // PHP version, e.g. "7.4".
+}
+
+// Evaluate implements Rule.
+func (r CompatibilityRule) Evaluate(in Input) (bool, string) {
+	audit, ok := in.Audits["phpcs_phpcompatibility"]
+	if !ok {
+		return false, ""
+	}
+
+	for _, version := range audit.IncompatibleVersions {
+		if version == r.Version {
+			return true, "project is not compatible with PHP " + r.Version
+		}
+	}
+
+	return false, ""
+}
+
+// LighthouseRule fails if a Lighthouse category scores below a minimum.
+type LighthouseRule struct {
+	Category string  // Category ID, e.g. "performance".
+	MinScore float32 // Minimum score between 0 and 1.
+}
+
+// Evaluate implements Rule.
+func (r LighthouseRule) Evaluate(in Input) (bool, string) {
+	audit, ok := in.Audits["lighthouse"]
+	if !ok || audit.Summary.LighthouseSummary == nil {
+		return false, ""
+	}
+
+	category, ok := audit.Summary.LighthouseSummary.Categories[r.Category]
+	if !ok || category.Score >= r.MinScore {
+		return false, ""
+	}
+
+	return true, fmt.Sprintf("lighthouse %s score %.2f is below %.2f", r.Category, category.Score, r.MinScore)
+}
+
+// definition is the JSON representation of a policy.
+type definition struct {
+	Name  string `json:"name"`
+	Match string `json:"match"`
+	Rules []struct {
+		Type     string  `json:"type"`
+		Kind     string  `json:"kind"`
+		Source   string  `json:"source"`
+		Level    string  `json:"level"`
+		Severity int     `json:"severity"`
+		Version  string  `json:"version"`
+		Category string  `json:"category"`
+		MinScore float32 `json:"min_score"`
+	} `json:"rules"`
+}
+
+// Parse reads a policy from JSON, e.g.
+//
+//	{
+//	  "name": "directory",
+//	  "rules": [
+//	    {"type": "phpcs", "source": "WordPress.Security", "level": "error", "severity": 5},
+//	    {"type": "php_compatibility", "version": "7.4"},
+//	    {"type": "lighthouse", "category": "accessibility", "min_score": 0.8}
+//	  ]
+//	}
+func Parse(data []byte) (*Policy, error) {
+	var def definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, err
+	}
+
+	if def.Match != "" && def.Match != MatchAny && def.Match != MatchAll {
+		return nil, errors.New("policy: unknown match mode " + def.Match)
+	}
+
+	p := &Policy{Name: def.Name, Match: def.Match}
+	for _, rule := range def.Rules {
+		switch rule.Type {
+		case "phpcs":
+			p.Rules = append(p.Rules, PhpcsRule{
+				Kind:        rule.Kind,
+				Source:      rule.Source,
+				Type:        strings.ToUpper(rule.Level),
+				MinSeverity: rule.Severity,
+			})
+		case "php_compatibility":
+			if rule.Version == "" {
+				return nil, errors.New("policy: php_compatibility rule requires a version")
+			}
+			p.Rules = append(p.Rules, CompatibilityRule{Version: rule.Version})
+		case "lighthouse":
+			if rule.Category == "" {
+				return nil, errors.New("policy: lighthouse rule requires a category")
+			}
+			p.Rules = append(p.Rules, LighthouseRule{Category: rule.Category, MinScore: rule.MinScore})
+		default:
+			return nil, errors.New("policy: unknown rule type " + rule.Type)
+		}
+	}
+
+	return p, nil
+}
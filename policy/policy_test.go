@@ -0,0 +1,244 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func testInput() Input {
+	report := &tide.PhpcsResults{}
+	report.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{
+		"plugin.php": {
+			Messages: []tide.PhpcsFilesMessage{
+				{Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "ERROR", Line: 10},
+				{Source: "WordPress.WP.I18n.MissingTranslatorsComment", Type: "WARNING", Severity: 3, Line: 12},
+			},
+		},
+	}
+
+	return Input{
+		Audits: map[string]tide.AuditResult{
+			"phpcs_phpcompatibility": {
+				CompatibleVersions:   []string{"7.0", "7.1"},
+				IncompatibleVersions: []string{"7.4", "8.0"},
+			},
+			"lighthouse": {
+				Summary: tide.AuditSummary{
+					LighthouseSummary: &tide.LighthouseSummary{
+						Categories: map[string]tide.LighthouseCategory{
+							"performance": {ID: "performance", Score: 0.5},
+						},
+					},
+				},
+			},
+		},
+		Reports: map[string]*tide.PhpcsResults{
+			"phpcs_wordpress": report,
+		},
+	}
+}
+
+func TestPhpcsRule_Evaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       PhpcsRule
+		wantFail   bool
+		wantReason string
+	}{
+		{
+			"Security Error",
+			PhpcsRule{Source: "WordPress.Security", Type: "ERROR", MinSeverity: 5},
+			true,
+			"phpcs_wordpress reported error WordPress.Security.EscapeOutput.OutputNotEscaped in plugin.php on line 10",
+		},
+		{
+			"Severity Too Low",
+			PhpcsRule{Source: "WordPress.WP", MinSeverity: 5},
+			false,
+			"",
+		},
+		{
+			"Other Kind",
+			PhpcsRule{Kind: "phpcs_phpcompatibility"},
+			false,
+			"",
+		},
+		{
+			"Any Warning",
+			PhpcsRule{Kind: "phpcs_wordpress", Type: "warning"},
+			true,
+			"phpcs_wordpress reported warning WordPress.WP.I18n.MissingTranslatorsComment in plugin.php on line 12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail, reason := tt.rule.Evaluate(testInput())
+			if fail != tt.wantFail || reason != tt.wantReason {
+				t.Errorf("PhpcsRule.Evaluate() = %v, %v, want %v, %v", fail, reason, tt.wantFail, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestPhpcsRule_Evaluate_Streamed(t *testing.T) {
+	in := testInput()
+	in.Reports["phpcs_wpvip"] = nil
+
+	tests := []struct {
+		name       string
+		rule       PhpcsRule
+		wantFail   bool
+		wantReason string
+	}{
+		{"Any Kind", PhpcsRule{Source: "WordPress.WP", MinSeverity: 5}, true, "phpcs_wpvip report was too large to evaluate"},
+		{"Streamed Kind", PhpcsRule{Kind: "phpcs_wpvip"}, true, "phpcs_wpvip report was too large to evaluate"},
+		{"Other Kind", PhpcsRule{Kind: "phpcs_wordpress", Source: "WordPress.WP", MinSeverity: 5}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fail, reason := tt.rule.Evaluate(in)
+			if fail != tt.wantFail || reason != tt.wantReason {
+				t.Errorf("PhpcsRule.Evaluate() = %v, %v, want %v, %v", fail, reason, tt.wantFail, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestCompatibilityRule_Evaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		in       Input
+		wantFail bool
+	}{
+		{"Incompatible", "7.4", testInput(), true},
+		{"Compatible", "7.1", testInput(), false},
+		{"No Audit", "7.4", Input{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if fail, _ := (CompatibilityRule{Version: tt.version}).Evaluate(tt.in); fail != tt.wantFail {
+				t.Errorf("CompatibilityRule.Evaluate() = %v, want %v", fail, tt.wantFail)
+			}
+		})
+	}
+}
+
+func TestLighthouseRule_Evaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     LighthouseRule
+		in       Input
+		wantFail bool
+	}{
+		{"Below Minimum", LighthouseRule{"performance", 0.8}, testInput(), true},
+		{"Above Minimum", LighthouseRule{"performance", 0.5}, testInput(), false},
+		{"Unknown Category", LighthouseRule{"seo", 0.8}, testInput(), false},
+		{"No Audit", LighthouseRule{"performance", 0.8}, Input{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if fail, _ := tt.rule.Evaluate(tt.in); fail != tt.wantFail {
+				t.Errorf("LighthouseRule.Evaluate() = %v, want %v", fail, tt.wantFail)
+			}
+		})
+	}
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	failing := CompatibilityRule{Version: "7.4"}
+	passing := CompatibilityRule{Version: "7.0"}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		want   tide.Verdict
+	}{
+		{
+			"No Rules",
+			Policy{Name: "empty"},
+			tide.Verdict{Result: tide.VerdictPass, Policy: "empty"},
+		},
+		{
+			"Any - Fail",
+			Policy{Name: "p", Rules: []Rule{passing, failing}},
+			tide.Verdict{Result: tide.VerdictFail, Policy: "p", Reasons: []string{"project is not compatible with PHP 7.4"}},
+		},
+		{
+			"Any - Pass",
+			Policy{Name: "p", Rules: []Rule{passing}},
+			tide.Verdict{Result: tide.VerdictPass, Policy: "p"},
+		},
+		{
+			"All - Pass",
+			Policy{Name: "p", Match: MatchAll, Rules: []Rule{passing, failing}},
+			tide.Verdict{Result: tide.VerdictPass, Policy: "p"},
+		},
+		{
+			"All - Fail",
+			Policy{Name: "p", Match: MatchAll, Rules: []Rule{failing, PhpcsRule{Source: "WordPress.Security"}}},
+			tide.Verdict{Result: tide.VerdictFail, Policy: "p", Reasons: []string{
+				"project is not compatible with PHP 7.4",
+				"phpcs_wordpress reported error WordPress.Security.EscapeOutput.OutputNotEscaped in plugin.php on line 10",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Evaluate(testInput()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Policy.Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    *Policy
+		wantErr bool
+	}{
+		{
+			"Valid",
+			`{"name":"directory","match":"any","rules":[
+				{"type":"phpcs","kind":"phpcs_wordpress","source":"WordPress.Security","level":"error","severity":5},
+				{"type":"php_compatibility","version":"7.4"},
+				{"type":"lighthouse","category":"accessibility","min_score":0.8}
+			]}`,
+			&Policy{
+				Name:  "directory",
+				Match: MatchAny,
+				Rules: []Rule{
+					PhpcsRule{Kind: "phpcs_wordpress", Source: "WordPress.Security", Type: "ERROR", MinSeverity: 5},
+					CompatibilityRule{Version: "7.4"},
+					LighthouseRule{Category: "accessibility", MinScore: 0.8},
+				},
+			},
+			false,
+		},
+		{"Invalid JSON", `{`, nil, true},
+		{"Unknown Match", `{"match":"some"}`, nil, true},
+		{"Unknown Rule", `{"rules":[{"type":"other"}]}`, nil, true},
+		{"No Version", `{"rules":[{"type":"php_compatibility"}]}`, nil, true},
+		{"No Category", `{"rules":[{"type":"lighthouse"}]}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package process
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/wptide/pkg/log"
+)
+
+// Default autotuning bounds.
+const (
+	DefaultTuneInterval    = 30 * time.Second
+	DefaultTuneHighWater   = 0.5
+	DefaultTuneLowWater    = 0.1
+	DefaultTuneMinHeadroom = 0.1
+)
+
+// Using runtime.ReadMemStats as a variable so that we can mock it in tests.
+var readMemStats = runtime.ReadMemStats
+
+// Autotuner moves workers between the pools of a pipeline at runtime. Pools where messages
+// wait for a free worker get another worker, slower stages first, and pools whose workers are
+// idle give one up. No pool grows while the memory headroom is low.
+//
+// E.g. a worker auditing small archives with slow scans shifts capacity from ingest to phpcs.
+type Autotuner struct {
+	Pools       []*Pool       // Pools to tune. Each pool is kept within its Min and Max.
+	Interval    time.Duration // (Optional) Time between adjustments. Defaults to DefaultTuneInterval.
+	MaxWorkers  int           // (Optional) Most workers across all pools. Unlimited if 0.
+	MemoryLimit uint64        // (Optional) Heap size in bytes that the workers should stay below. Unchecked if 0.
+	MinHeadroom float64       // (Optional) Fraction of MemoryLimit to keep free. Defaults to DefaultTuneMinHeadroom.
+	HighWater   float64       // (Optional) Saturation at which a pool grows. Defaults to DefaultTuneHighWater.
+	LowWater    float64       // (Optional) Saturation below which a pool shrinks. Defaults to DefaultTuneLowWater.
+}
+
+// Run adjusts the pools every Interval until the context is cancelled.
+func (a *Autotuner) Run(ctx context.Context) {
+	interval := a.Interval
+	if interval <= 0 {
+		interval = DefaultTuneInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.Tune()
+		}
+	}
+}
+
+// Tune adjusts the pools once, based on their load since the previous adjustment.
+func (a *Autotuner) Tune() {
+	stats := make([]PoolStats, len(a.Pools))
+	for i, pool := range a.Pools {
+		stats[i] = pool.Stats()
+	}
+
+	var mem runtime.MemStats
+	if a.MemoryLimit > 0 {
+		readMemStats(&mem)
+	}
+
+	for i, n := range a.plan(stats, mem.HeapAlloc) {
+		if n == stats[i].Workers {
+			continue
+		}
+
+		log.Log("Autotune", fmt.Sprintf("%s: %d -> %d workers (saturation %.2f, latency %s)",
+			stats[i].Stage, stats[i].Workers, n, stats[i].Saturation, stats[i].Latency))
+
+		if err := a.Pools[i].SetWorkers(n); err != nil {
+			log.Log("Autotune", stats[i].Stage+": "+err.Error())
+		}
+	}
+}
+
+// plan returns the number of workers for each pool.
+func (a *Autotuner) plan(stats []PoolStats, heap uint64) []int {
+	highWater, lowWater, minHeadroom := a.HighWater, a.LowWater, a.MinHeadroom
+	if highWater <= 0 {
+		highWater = DefaultTuneHighWater
+	}
+	if lowWater <= 0 {
+		lowWater = DefaultTuneLowWater
+	}
+	if minHeadroom <= 0 {
+		minHeadroom = DefaultTuneMinHeadroom
+	}
+
+	workers := make([]int, len(stats))
+	total := 0
+	for i, s := range stats {
+		workers[i] = s.Workers
+		total += s.Workers
+	}
+
+	lowMemory := a.MemoryLimit > 0 && float64(heap) > float64(a.MemoryLimit)*(1-minHeadroom)
+
+	// Idle pools give up a worker.
+	for i, s := range stats {
+		if s.Workers > s.Min && s.Queued == 0 && s.Saturation < lowWater {
+			workers[i]--
+			total--
+		}
+	}
+
+	if lowMemory {
+		// Free memory by shrinking the least saturated pool that can still shrink.
+		if total == sumWorkers(stats) {
+			idlest := -1
+			for i, s := range stats {
+				if workers[i] > s.Min && (idlest < 0 || s.Saturation < stats[idlest].Saturation) {
+					idlest = i
+				}
+			}
+			if idlest >= 0 {
+				workers[idlest]--
+			}
+		}
+		return workers
+	}
+
+	// The busiest pool gets a worker. Busy pools with slow messages have the most waiting work.
+	busiest := -1
+	for i, s := range stats {
+		if workers[i] >= s.Max || (s.Saturation < highWater && s.Queued == 0) {
+			continue
+		}
+		if busiest < 0 || pressure(s) > pressure(stats[busiest]) {
+			busiest = i
+		}
+	}
+	if busiest < 0 {
+		return workers
+	}
+
+	if a.MaxWorkers > 0 && total >= a.MaxWorkers {
+		// Take the worker from the least saturated pool that is not waiting for work.
+		idlest := -1
+		for i, s := range stats {
+			if i == busiest || workers[i] <= s.Min || s.Queued > 0 || s.Saturation >= highWater {
+				continue
+			}
+			if idlest < 0 || s.Saturation < stats[idlest].Saturation {
+				idlest = i
+			}
+		}
+		if idlest < 0 {
+			return workers
+		}
+		workers[idlest]--
+	}
+
+	workers[busiest]++
+
+	return workers
+}
+
+// pressure ranks busy pools: the saturation weighted by the latency of their messages.
+func pressure(s PoolStats) float64 {
+	latency := s.Latency
+	if latency <= 0 {
+		latency = time.Millisecond
+	}
+	return (s.Saturation + float64(s.Queued)) * latency.Seconds()
+}
+
+// sumWorkers returns the total number of workers of the pools.
+func sumWorkers(stats []PoolStats) int {
+	total := 0
+	for _, s := range stats {
+		total += s.Workers
+	}
+	return total
+}
@@ -0,0 +1,122 @@
+package process
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAutotuner_plan(t *testing.T) {
+	ingest := PoolStats{Stage: "ingest", Workers: 3, Min: 1, Max: 4, Saturation: 0.05, Latency: time.Second}
+	phpcs := PoolStats{Stage: "phpcs_wordpress", Workers: 1, Min: 1, Max: 4, Saturation: 0.9, Queued: 1, Latency: time.Minute}
+	lighthouse := PoolStats{Stage: "lighthouse", Workers: 1, Min: 1, Max: 4, Saturation: 0.8, Latency: 10 * time.Second}
+
+	tests := []struct {
+		name  string
+		a     *Autotuner
+		stats []PoolStats
+		heap  uint64
+		want  []int
+	}{
+		{
+			"Shift To Slow Stage",
+			&Autotuner{},
+			[]PoolStats{ingest, phpcs, lighthouse},
+			0,
+			[]int{2, 2, 1},
+		},
+		{
+			"Within Max",
+			&Autotuner{},
+			[]PoolStats{{Workers: 4, Min: 1, Max: 4, Saturation: 1}},
+			0,
+			[]int{4},
+		},
+		{
+			"Steady",
+			&Autotuner{},
+			[]PoolStats{{Workers: 2, Min: 1, Max: 4, Saturation: 0.3}},
+			0,
+			[]int{2},
+		},
+		{
+			"Worker Budget",
+			&Autotuner{MaxWorkers: 4},
+			[]PoolStats{
+				{Workers: 2, Min: 1, Max: 4, Saturation: 0.2},
+				{Workers: 2, Min: 1, Max: 4, Saturation: 0.9},
+			},
+			0,
+			[]int{1, 3},
+		},
+		{
+			"Worker Budget Exhausted",
+			&Autotuner{MaxWorkers: 4},
+			[]PoolStats{
+				{Workers: 2, Min: 1, Max: 4, Saturation: 0.6},
+				{Workers: 2, Min: 1, Max: 4, Saturation: 0.9},
+			},
+			0,
+			[]int{2, 2},
+		},
+		{
+			"Low Memory",
+			&Autotuner{MemoryLimit: 1000},
+			[]PoolStats{
+				{Workers: 2, Min: 1, Max: 4, Saturation: 0.6},
+				{Workers: 2, Min: 1, Max: 4, Saturation: 0.9},
+			},
+			950,
+			[]int{1, 2},
+		},
+		{
+			"Low Memory Idle Pool",
+			&Autotuner{MemoryLimit: 1000},
+			[]PoolStats{ingest, phpcs},
+			950,
+			[]int{2, 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.plan(tt.stats, tt.heap); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Autotuner.plan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutotuner_Tune(t *testing.T) {
+	oldReadMemStats := readMemStats
+	readMemStats = func(m *runtime.MemStats) {
+		m.HeapAlloc = 100
+	}
+	defer func() { readMemStats = oldReadMemStats }()
+
+	p := &Pool{
+		In:  make(chan Processor),
+		Out: make(chan Processor),
+		Min: 1,
+		Max: 3,
+		New: func(in <-chan Processor, out chan Processor) Processor {
+			return &mockWorker{In: in, Out: out}
+		},
+	}
+	if err := p.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+	p.SetWorkers(3)
+
+	// Idle workers are stopped one at a time.
+	a := &Autotuner{Pools: []*Pool{p}, MemoryLimit: 1000}
+	a.Tune()
+	if got := p.Workers(); got != 2 {
+		t.Errorf("Autotuner.Tune() left %v workers, want 2", got)
+	}
+	a.Tune()
+	a.Tune()
+	if got := p.Workers(); got != 1 {
+		t.Errorf("Autotuner.Tune() left %v workers, want 1", got)
+	}
+}
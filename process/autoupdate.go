@@ -0,0 +1,356 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/tide"
+)
+
+// DefaultStandardsCheckInterval is how often a StandardsWatcher checks for new releases.
+const DefaultStandardsCheckInterval = 24 * time.Hour
+
+// DefaultWatchedComponents are the PHPCS components that a StandardsWatcher keeps up to date.
+var DefaultWatchedComponents = []string{"phpcs", "wpcs", "phpcompatibility"}
+
+// DefaultReleaseRepos are the GitHub repositories of the PHPCS components.
+var DefaultReleaseRepos = map[string]string{
+	"phpcs":            "PHPCSStandards/PHP_CodeSniffer",
+	"wpcs":             "WordPress/WordPress-Coding-Standards",
+	"phpcompatibility": "PHPCompatibility/PHPCompatibility",
+}
+
+// DefaultComposerPackages are the Composer packages of the PHPCS components.
+var DefaultComposerPackages = map[string]string{
+	"phpcs":              "squizlabs/php_codesniffer",
+	"wpcs":               "wp-coding-standards/wpcs",
+	"phpcompatibility":   "phpcompatibility/php-compatibility",
+	"phpcompatibilitywp": "phpcompatibility/phpcompatibility-wp",
+}
+
+// ReleaseChecker finds the latest release of a PHPCS component, e.g. "wpcs".
+type ReleaseChecker interface {
+	Latest(component string) (string, error)
+}
+
+// StandardsInstaller installs PHPCS components.
+type StandardsInstaller interface {
+	// Install installs the component versions, e.g. {"phpcs": "3.7.2", "wpcs": "3.0.1"},
+	// into path and returns the path of the phpcs executable.
+	Install(path string, components map[string]string) (string, error)
+}
+
+// GitHubReleases is a ReleaseChecker for components that are released on GitHub.
+type GitHubReleases struct {
+	Repos   map[string]string // (Optional) Repository of each component. Defaults to DefaultReleaseRepos.
+	BaseURL string            // (Optional) Defaults to "https://api.github.com".
+	Client  *http.Client      // (Optional) Defaults to http.DefaultClient.
+}
+
+// Latest implements ReleaseChecker.
+func (g GitHubReleases) Latest(component string) (string, error) {
+	repos := g.Repos
+	if repos == nil {
+		repos = DefaultReleaseRepos
+	}
+
+	repo, ok := repos[component]
+	if !ok {
+		return "", errors.New("no release repository for " + component)
+	}
+
+	baseURL := g.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.github.com"
+	}
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Get(strings.TrimRight(baseURL, "/") + "/repos/" + repo + "/releases/latest")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("could not get the latest release of " + component + ": " + resp.Status)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return "", err
+	}
+	if release.TagName == "" {
+		return "", errors.New("no release found for " + component)
+	}
+
+	return strings.TrimPrefix(release.TagName, "v"), nil
+}
+
+// ComposerInstaller is a StandardsInstaller that installs the components with Composer.
+type ComposerInstaller struct {
+	Packages map[string]string // (Optional) Composer package of each component. Defaults to DefaultComposerPackages.
+	Runner   shell.Runner      // (Optional) Runs Composer.
+}
+
+// composerJSON allows the plugin that registers the installed standards with PHPCS.
+const composerJSON = `{"config":{"allow-plugins":{"dealerdirect/phpcodesniffer-composer-installer":true}}}`
+
+// Install implements StandardsInstaller.
+func (c ComposerInstaller) Install(path string, components map[string]string) (string, error) {
+	packages := c.Packages
+	if packages == nil {
+		packages = DefaultComposerPackages
+	}
+
+	args := []string{
+		"require",
+		"--working-dir=" + path,
+		"--no-interaction",
+		"--no-progress",
+		"dealerdirect/phpcodesniffer-composer-installer",
+	}
+	for _, component := range componentNames(components) {
+		pkg, ok := packages[component]
+		if !ok {
+			return "", errors.New("no Composer package for " + component)
+		}
+		args = append(args, pkg+":"+components[component])
+	}
+
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+	if err := writeFile(filepath.Join(path, "composer.json"), []byte(composerJSON), 0644); err != nil {
+		return "", err
+	}
+
+	runner := c.Runner
+	if runner == nil {
+		runner = defaultRunner
+	}
+
+	_, errorBytes, _, err := runner.Run("composer", args...)
+	if err != nil {
+		return "", errors.New("could not install standards: " + strings.TrimSpace(string(errorBytes)))
+	}
+
+	return filepath.Join(path, "vendor", "bin", "phpcs"), nil
+}
+
+// validationFixture is audited with every standard of an installation before it is used.
+const validationFixture = "<?php\necho $_GET['tide'];\n"
+
+// ValidateInstallation audits a fixture with every standard of the installation and returns
+// an error if PHPCS does not produce a JSON report for one of them.
+func ValidateInstallation(runner shell.Runner, installation *StandardsInstallation) error {
+	if runner == nil {
+		runner = defaultRunner
+	}
+
+	fixture := filepath.Join(installation.Path, "tide-validation.php")
+	if err := writeFile(fixture, []byte(validationFixture), 0644); err != nil {
+		return err
+	}
+
+	phpcs := installation.Phpcs
+	if phpcs == "" {
+		phpcs = "phpcs"
+	}
+
+	for _, standard := range standardNames(installation.Standards) {
+		// PHPCS exits with an error when it finds problems, so only the report is checked.
+		resultBytes, errorBytes, _, err := runner.Run(phpcs, "--standard="+standard, "--report=json", "-q", fixture)
+
+		var report tide.PhpcsResults
+		if jsonErr := json.Unmarshal(resultBytes, &report); jsonErr != nil {
+			reason := strings.TrimSpace(string(errorBytes))
+			if reason == "" && err != nil {
+				reason = err.Error()
+			}
+			return errors.New("standard " + standard + " failed validation: " + reason)
+		}
+	}
+
+	return nil
+}
+
+// StandardsWatcher keeps the PHPCS components of the active installation up to date.
+// New releases are installed into a staging folder and validated before audits use them.
+type StandardsWatcher struct {
+	Active        *ActiveStandards                   // Installation used by audits, switched after a successful update.
+	Releases      ReleaseChecker                     // Finds new releases.
+	Installer     StandardsInstaller                 // Installs new releases.
+	StagingFolder string                             // Every update is installed into a new folder inside this folder.
+	Components    []string                           // (Optional) Components to update. Defaults to DefaultWatchedComponents.
+	Validate      func(*StandardsInstallation) error // (Optional) Validates a staged installation. Defaults to ValidateInstallation.
+	Interval      time.Duration                      // (Optional) Time between checks. Defaults to DefaultStandardsCheckInterval.
+}
+
+// Run checks for new releases every interval until the context is cancelled.
+func (w *StandardsWatcher) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultStandardsCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Check(); err != nil {
+				log.Log("Standards", "Update failed: "+err.Error())
+			}
+		}
+	}
+}
+
+// Check installs and validates any new releases and switches the active installation to them.
+// It returns true if the active installation was switched.
+func (w *StandardsWatcher) Check() (bool, error) {
+	if w.Active == nil || w.Releases == nil || w.Installer == nil {
+		return false, errors.New("standards watcher requires active standards, a release checker and an installer")
+	}
+	if w.StagingFolder == "" {
+		return false, errors.New("no staging folder provided for standards")
+	}
+
+	current := w.Active.Current()
+	if current == nil {
+		return false, errors.New("no PHPCS standards installed")
+	}
+
+	components := w.Components
+	if components == nil {
+		components = DefaultWatchedComponents
+	}
+
+	// Only components that are used by one of the standards are updated.
+	installed := installedComponents(current.Standards)
+	updates := make(map[string]string)
+	for _, component := range components {
+		version, ok := installed[component]
+		if !ok {
+			continue
+		}
+
+		latest, err := w.Releases.Latest(component)
+		if err != nil {
+			return false, err
+		}
+		if latest != version {
+			updates[component] = latest
+		}
+	}
+
+	if len(updates) == 0 {
+		return false, nil
+	}
+
+	// Install every component so that the staged installation is complete.
+	versions := make(map[string]string)
+	for component, version := range installed {
+		versions[component] = version
+	}
+	for component, version := range updates {
+		versions[component] = version
+	}
+
+	path := filepath.Join(w.StagingFolder, "standards-"+now().UTC().Format("20060102150405"))
+	phpcs, err := w.Installer.Install(path, versions)
+	if err != nil {
+		return false, err
+	}
+
+	staged := &StandardsInstallation{
+		Path:      path,
+		Phpcs:     phpcs,
+		Standards: updateStandards(current.Standards, updates),
+	}
+
+	validate := w.Validate
+	if validate == nil {
+		validate = func(installation *StandardsInstallation) error {
+			return ValidateInstallation(nil, installation)
+		}
+	}
+	if err := validate(staged); err != nil {
+		return false, err
+	}
+
+	w.Active.Switch(staged)
+
+	var changes []string
+	for _, component := range componentNames(updates) {
+		changes = append(changes, component+" "+installed[component]+" to "+updates[component])
+	}
+	log.Log("Standards", "Switched to "+path+": "+strings.Join(changes, ", "))
+
+	return true, nil
+}
+
+// installedComponents returns the version of every component used by the standards.
+func installedComponents(standards map[string]map[string]string) map[string]string {
+	installed := make(map[string]string)
+	for _, standard := range standardNames(standards) {
+		for component, version := range standards[standard] {
+			if _, ok := installed[component]; !ok {
+				installed[component] = version
+			}
+		}
+	}
+	return installed
+}
+
+// updateStandards returns a copy of the standards with the updated component versions.
+func updateStandards(standards map[string]map[string]string, updates map[string]string) map[string]map[string]string {
+	updated := make(map[string]map[string]string)
+	for standard, components := range standards {
+		updated[standard] = make(map[string]string)
+		for component, version := range components {
+			if newVersion, ok := updates[component]; ok {
+				version = newVersion
+			}
+			updated[standard][component] = version
+		}
+	}
+	return updated
+}
+
+// componentNames returns the names of the components in order.
+func componentNames(components map[string]string) []string {
+	var names []string
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// standardNames returns the names of the standards in order.
+func standardNames(standards map[string]map[string]string) []string {
+	var names []string
+	for name := range standards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
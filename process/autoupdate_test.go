@@ -0,0 +1,304 @@
+package process
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/log"
+)
+
+type mockReleases map[string]string
+
+func (m mockReleases) Latest(component string) (string, error) {
+	version, ok := m[component]
+	if !ok {
+		return "", errors.New("no release")
+	}
+	return version, nil
+}
+
+type mockInstaller struct {
+	installed map[string]string
+	path      string
+	fail      bool
+}
+
+func (m *mockInstaller) Install(path string, components map[string]string) (string, error) {
+	if m.fail {
+		return "", errors.New("install failed")
+	}
+	m.path = path
+	m.installed = components
+	return path + "/vendor/bin/phpcs", nil
+}
+
+// recordingRunner records the commands it runs and returns the output for the command name.
+type recordingRunner struct {
+	commands [][]string
+	output   map[string]string
+	fail     bool
+}
+
+func (r *recordingRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	r.commands = append(r.commands, append([]string{name}, arg...))
+	if r.fail {
+		return nil, []byte("something went wrong"), 1, errors.New("exit status 1")
+	}
+	return []byte(r.output[name]), nil, 0, nil
+}
+
+func TestGitHubReleases_Latest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/WordPress/WordPress-Coding-Standards/releases/latest":
+			w.Write([]byte(`{"tag_name":"3.0.1"}`))
+		case "/repos/PHPCSStandards/PHP_CodeSniffer/releases/latest":
+			w.Write([]byte(`{"tag_name":"v3.7.2"}`))
+		case "/repos/PHPCompatibility/PHPCompatibility/releases/latest":
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		repos     map[string]string
+		component string
+		want      string
+		wantErr   bool
+	}{
+		{"Release", nil, "wpcs", "3.0.1", false},
+		{"Prefixed Tag", nil, "phpcs", "3.7.2", false},
+		{"No Tag", nil, "phpcompatibility", "", true},
+		{"Unknown Component", nil, "phpcompatibilitywp", "", true},
+		{"Not Found", map[string]string{"wpcs": "missing/missing"}, "wpcs", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := GitHubReleases{Repos: tt.repos, BaseURL: server.URL}
+			got, err := g.Latest(tt.component)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GitHubReleases.Latest() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GitHubReleases.Latest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComposerInstaller_Install(t *testing.T) {
+	dir, err := ioutil.TempDir("", "standards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	runner := &recordingRunner{}
+	installer := ComposerInstaller{Runner: runner}
+
+	got, err := installer.Install(dir+"/staged", map[string]string{"wpcs": "3.0.1", "phpcs": "3.7.2"})
+	if err != nil {
+		t.Fatalf("ComposerInstaller.Install() error = %v", err)
+	}
+	if got != dir+"/staged/vendor/bin/phpcs" {
+		t.Errorf("ComposerInstaller.Install() = %v", got)
+	}
+
+	want := []string{
+		"composer", "require", "--working-dir=" + dir + "/staged", "--no-interaction", "--no-progress",
+		"dealerdirect/phpcodesniffer-composer-installer", "squizlabs/php_codesniffer:3.7.2", "wp-coding-standards/wpcs:3.0.1",
+	}
+	if len(runner.commands) != 1 || !reflect.DeepEqual(runner.commands[0], want) {
+		t.Errorf("ComposerInstaller.Install() ran %v, want %v", runner.commands, want)
+	}
+	if data, _ := ioutil.ReadFile(dir + "/staged/composer.json"); string(data) != composerJSON {
+		t.Errorf("ComposerInstaller.Install() composer.json = %s", data)
+	}
+
+	if _, err := installer.Install(dir+"/staged", map[string]string{"unknown": "1.0.0"}); err == nil {
+		t.Errorf("ComposerInstaller.Install() expected an error for an unknown component")
+	}
+
+	runner.fail = true
+	if _, err := installer.Install(dir+"/staged", map[string]string{"wpcs": "3.0.1"}); err == nil || !strings.Contains(err.Error(), "something went wrong") {
+		t.Errorf("ComposerInstaller.Install() error = %v", err)
+	}
+}
+
+func TestValidateInstallation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "standards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	installation := &StandardsInstallation{
+		Path:      dir,
+		Phpcs:     dir + "/vendor/bin/phpcs",
+		Standards: testStandards,
+	}
+
+	runner := &recordingRunner{output: map[string]string{installation.Phpcs: `{"totals":{"errors":1}}`}}
+	if err := ValidateInstallation(runner, installation); err != nil {
+		t.Errorf("ValidateInstallation() error = %v", err)
+	}
+
+	fixture := dir + "/tide-validation.php"
+	want := [][]string{
+		{installation.Phpcs, "--standard=phpcompatibility", "--report=json", "-q", fixture},
+		{installation.Phpcs, "--standard=wordpress", "--report=json", "-q", fixture},
+	}
+	if !reflect.DeepEqual(runner.commands, want) {
+		t.Errorf("ValidateInstallation() ran %v, want %v", runner.commands, want)
+	}
+
+	runner = &recordingRunner{fail: true}
+	if err := ValidateInstallation(runner, installation); err == nil || !strings.Contains(err.Error(), "something went wrong") {
+		t.Errorf("ValidateInstallation() error = %v", err)
+	}
+}
+
+func TestStandardsWatcher_Check(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	current := &StandardsInstallation{
+		Standards: map[string]map[string]string{
+			"wordpress":        {"phpcs": "3.5.0", "wpcs": "2.3.0"},
+			"phpcompatibility": {"phpcs": "3.5.0", "phpcompatibility": "9.3.5"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		releases     mockReleases
+		installFail  bool
+		validateFail bool
+		want         bool
+		wantErr      bool
+		wantActive   map[string]map[string]string
+	}{
+		{
+			"Up To Date",
+			mockReleases{"phpcs": "3.5.0", "wpcs": "2.3.0", "phpcompatibility": "9.3.5"},
+			false,
+			false,
+			false,
+			false,
+			current.Standards,
+		},
+		{
+			"Update",
+			mockReleases{"phpcs": "3.7.2", "wpcs": "3.0.1", "phpcompatibility": "9.3.5"},
+			false,
+			false,
+			true,
+			false,
+			map[string]map[string]string{
+				"wordpress":        {"phpcs": "3.7.2", "wpcs": "3.0.1"},
+				"phpcompatibility": {"phpcs": "3.7.2", "phpcompatibility": "9.3.5"},
+			},
+		},
+		{
+			"Release Error",
+			mockReleases{"phpcs": "3.7.2"},
+			false,
+			false,
+			false,
+			true,
+			current.Standards,
+		},
+		{
+			"Install Error",
+			mockReleases{"phpcs": "3.7.2", "wpcs": "3.0.1", "phpcompatibility": "9.3.5"},
+			true,
+			false,
+			false,
+			true,
+			current.Standards,
+		},
+		{
+			"Validation Error",
+			mockReleases{"phpcs": "3.7.2", "wpcs": "3.0.1", "phpcompatibility": "9.3.5"},
+			false,
+			true,
+			false,
+			true,
+			current.Standards,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			installer := &mockInstaller{fail: tt.installFail}
+			w := &StandardsWatcher{
+				Active:        NewActiveStandards(current),
+				Releases:      tt.releases,
+				Installer:     installer,
+				StagingFolder: "/tmp/standards",
+				Validate: func(installation *StandardsInstallation) error {
+					if tt.validateFail {
+						return errors.New("validation failed")
+					}
+					return nil
+				},
+			}
+
+			got, err := w.Check()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StandardsWatcher.Check() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("StandardsWatcher.Check() = %v, want %v", got, tt.want)
+			}
+
+			active := w.Active.Current()
+			if !reflect.DeepEqual(active.Standards, tt.wantActive) {
+				t.Errorf("StandardsWatcher.Check() active standards = %v, want %v", active.Standards, tt.wantActive)
+			}
+
+			if tt.want {
+				wantInstalled := map[string]string{"phpcs": "3.7.2", "wpcs": "3.0.1", "phpcompatibility": "9.3.5"}
+				if !reflect.DeepEqual(installer.installed, wantInstalled) || installer.path != "/tmp/standards/standards-20260102030405" {
+					t.Errorf("StandardsWatcher.Check() installed %v in %v", installer.installed, installer.path)
+				}
+				if active.Path != installer.path || active.Phpcs != installer.path+"/vendor/bin/phpcs" {
+					t.Errorf("StandardsWatcher.Check() active installation = %v", active)
+				}
+			}
+		})
+	}
+
+	if _, err := (&StandardsWatcher{}).Check(); err == nil {
+		t.Errorf("StandardsWatcher.Check() expected an error without an active installation")
+	}
+}
+
+func TestActiveStandards(t *testing.T) {
+	active := NewActiveStandards(nil)
+	if _, err := active.Versions("wordpress"); err == nil {
+		t.Errorf("ActiveStandards.Versions() expected an error without an installation")
+	}
+
+	active.Switch(&StandardsInstallation{Standards: testStandards})
+	got, err := active.Versions("wordpress")
+	if err != nil || !reflect.DeepEqual(got, testStandards["wordpress"]) {
+		t.Errorf("ActiveStandards.Versions() = %v, %v", got, err)
+	}
+}
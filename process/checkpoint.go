@@ -0,0 +1,203 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+)
+
+// Checkpoint is the result of a message after the stages of a pipeline that completed, so that a
+// restarted pipeline can resume the message from the next stage instead of auditing it again.
+// Checkpoints are kept by checksum.
+//
+// Values that do not survive JSON, e.g. the types of extra values and the parsed PHPCS reports
+// used to evaluate policies, are not restored.
+type Checkpoint struct {
+	Checksum string          `json:"checksum"`
+	Message  message.Message `json:"message"`
+	Stages   []string        `json:"stages"` // Names of the completed stages, in order.
+	Result   *Result         `json:"result"`
+}
+
+// CheckpointStore keeps the checkpoints of the messages in progress.
+type CheckpointStore interface {
+	Save(checkpoint Checkpoint) error
+	Load(checksum string) (*Checkpoint, error) // Returns nil if there is no checkpoint for the checksum.
+	Delete(checksum string) error
+}
+
+// FileCheckpoints is a CheckpointStore that keeps checkpoints as JSON files in a folder, e.g. on
+// a volume that outlives the worker.
+type FileCheckpoints struct {
+	Dir string // Folder of the checkpoints. It is created if it does not exist.
+}
+
+// Save writes the checkpoint to "<dir>/<checksum>.json".
+func (f FileCheckpoints) Save(checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(f.Dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temp file first, so that a worker that is killed meanwhile leaves the previous checkpoint.
+	temp, err := ioutil.TempFile(f.Dir, checkpoint.Checksum+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), f.path(checkpoint.Checksum))
+}
+
+// Load reads the checkpoint of the checksum.
+func (f FileCheckpoints) Load(checksum string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(f.path(checksum))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeCheckpoint(data)
+}
+
+// Delete removes the checkpoint of the checksum.
+func (f FileCheckpoints) Delete(checksum string) error {
+	if err := os.Remove(f.path(checksum)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path returns the path of the checkpoint file of the checksum.
+func (f FileCheckpoints) path(checksum string) string {
+	return filepath.Join(f.Dir, checksum+".json")
+}
+
+// StorageCheckpoints is a CheckpointStore that keeps checkpoints in a storage provider, so that
+// another worker can resume the message. Providers that can't delete files, see storage.Deleter,
+// overwrite deleted checkpoints with an empty checkpoint. Providers that can't check whether a
+// file exists, see storage.Checker, treat checkpoints that can't be downloaded as missing.
+type StorageCheckpoints struct {
+	Provider   storage.Provider // Storage provider to keep the checkpoints in.
+	TempFolder string           // Path to a temp folder where checkpoints are written before they are uploaded.
+	Prefix     string           // (Optional) Prefix of the references. Defaults to "checkpoints/".
+}
+
+// Save uploads the checkpoint as "<prefix><checksum>.json".
+func (s StorageCheckpoints) Save(checkpoint Checkpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	return s.upload(checkpoint.Checksum, data)
+}
+
+// Load downloads the checkpoint of the checksum. It returns an error if the checkpoint exists
+// but can't be downloaded, e.g. while the storage is unavailable.
+func (s StorageCheckpoints) Load(checksum string) (*Checkpoint, error) {
+	reference := s.reference(checksum)
+	exists, err := storage.Exists(s.Provider, reference)
+	checked := err != storage.ErrNotSupported
+	if checked && err != nil {
+		return nil, err
+	}
+	if checked && !exists {
+		return nil, nil
+	}
+
+	path := s.tempPath(checksum)
+	defer os.Remove(path)
+
+	if err := s.Provider.DownloadFile(reference, path); err != nil {
+		if !checked {
+			// A missing checkpoint can't be told apart from a failed download.
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCheckpoint(data)
+}
+
+// Delete deletes the checkpoint of the checksum, or overwrites it with an empty checkpoint if
+// the provider can't delete files.
+func (s StorageCheckpoints) Delete(checksum string) error {
+	err := storage.Delete(s.Provider, s.reference(checksum))
+	if err == storage.ErrNotSupported {
+		return s.upload(checksum, []byte("{}"))
+	}
+	return err
+}
+
+// upload uploads the data as the checkpoint of the checksum.
+func (s StorageCheckpoints) upload(checksum string, data []byte) error {
+	path := s.tempPath(checksum)
+	if err := writeFile(path, data, 0644); err != nil {
+		return errors.New("could not write checkpoint to tempFolder")
+	}
+	defer os.Remove(path)
+
+	return s.Provider.UploadFile(path, s.reference(checksum))
+}
+
+// reference returns the storage reference of the checkpoint of the checksum.
+func (s StorageCheckpoints) reference(checksum string) string {
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "checkpoints/"
+	}
+	return prefix + checksum + ".json"
+}
+
+// tempPath returns the path of the temp file of the checkpoint of the checksum.
+func (s StorageCheckpoints) tempPath(checksum string) string {
+	return strings.TrimRight(s.TempFolder, "/") + "/" + checksum + "-checkpoint.json"
+}
+
+// decodeCheckpoint decodes a stored checkpoint. Empty checkpoints are returned as nil.
+func decodeCheckpoint(data []byte) (*Checkpoint, error) {
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	if checkpoint.Checksum == "" || checkpoint.Result == nil {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+// resumes determines if the message can be resumed from the checkpoint after the stages that
+// completed in this run. The checkpoint has to be for the same audits and must not be complete.
+func (c *Checkpoint) resumes(msg message.Message, completed []string, stages int) bool {
+	if len(c.Stages) <= len(completed) || len(c.Stages) >= stages {
+		return false
+	}
+	if !reflect.DeepEqual(c.Stages[:len(completed)], completed) {
+		return false
+	}
+	return reflect.DeepEqual(c.Message.Audits, msg.Audits)
+}
@@ -0,0 +1,264 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/local"
+	"github.com/wptide/pkg/tide"
+)
+
+// memoryCheckpoints keeps checkpoints in memory and records the stages that were saved.
+type memoryCheckpoints struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+	saved       [][]string
+	deleted     []string
+}
+
+func (m *memoryCheckpoints) Save(checkpoint Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.checkpoints == nil {
+		m.checkpoints = make(map[string]Checkpoint)
+	}
+	m.checkpoints[checkpoint.Checksum] = checkpoint
+	m.saved = append(m.saved, append([]string{}, checkpoint.Stages...))
+	return nil
+}
+
+func (m *memoryCheckpoints) Load(checksum string) (*Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	checkpoint, ok := m.checkpoints[checksum]
+	if !ok {
+		return nil, nil
+	}
+	return &checkpoint, nil
+}
+
+func (m *memoryCheckpoints) Delete(checksum string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.checkpoints, checksum)
+	m.deleted = append(m.deleted, checksum)
+	return nil
+}
+
+// checkpointStores returns a file and a storage CheckpointStore in the folder.
+func checkpointStores(dir string) map[string]CheckpointStore {
+	os.MkdirAll(dir+"/storage/checkpoints", 0755)
+	return map[string]CheckpointStore{
+		"File":    FileCheckpoints{Dir: dir + "/checkpoints"},
+		"Storage": StorageCheckpoints{Provider: local.NewLocalStorage(dir+"/storage", ""), TempFolder: dir},
+	}
+}
+
+func TestCheckpointStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	res := NewResult()
+	res.Checksum = "abc123"
+	res.SetAudit("phpcs_wordpress", tide.AuditResult{Summary: tide.AuditSummary{PhpcsSummary: &tide.PhpcsSummary{ErrorsCount: 3}}})
+	res.AddError(NewError("Lighthouse", message.Message{Title: "Test"}, withCode(tide.FailureStorage, os.ErrNotExist)))
+
+	checkpoint := Checkpoint{
+		Checksum: "abc123",
+		Message:  message.Message{Title: "Test", Slug: "test"},
+		Stages:   []string{"Ingest", "Phpcs"},
+		Result:   res,
+	}
+
+	for name, store := range checkpointStores(dir) {
+		t.Run(name, func(t *testing.T) {
+			if got, err := store.Load("abc123"); got != nil || err != nil {
+				t.Errorf("Load() = %v, %v before the checkpoint was saved", got, err)
+			}
+
+			if err := store.Save(checkpoint); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			got, err := store.Load("abc123")
+			if err != nil || got == nil {
+				t.Fatalf("Load() = %v, %v", got, err)
+			}
+			if !reflect.DeepEqual(got.Stages, checkpoint.Stages) || got.Message.Slug != "test" {
+				t.Errorf("Load() = %v, want %v", got, checkpoint)
+			}
+			if got.Result.Audits["phpcs_wordpress"].Summary.PhpcsSummary.ErrorsCount != 3 || len(got.Result.Errors) != 1 {
+				t.Errorf("Load() result = %v", got.Result)
+			}
+
+			if err := store.Delete("abc123"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+			if got, err := store.Load("abc123"); got != nil || err != nil {
+				t.Errorf("Load() = %v, %v after the checkpoint was deleted", got, err)
+			}
+		})
+	}
+}
+
+// unavailableStorage is a storage provider whose downloads fail, e.g. during an outage.
+type unavailableStorage struct {
+	mockStorage
+}
+
+func (u unavailableStorage) DownloadFile(reference, filename string) error {
+	return errors.New("storage unavailable")
+}
+
+// unavailableChecker is an unavailableStorage that can check whether it has a file.
+type unavailableChecker struct {
+	unavailableStorage
+	exists    bool
+	existsErr error
+}
+
+func (u unavailableChecker) Exists(reference string) (bool, error) {
+	return u.exists, u.existsErr
+}
+
+func TestStorageCheckpoints_Load_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider storage.Provider
+		wantErr  bool
+	}{
+		{"Missing", unavailableChecker{}, false},
+		{"Download Failed", unavailableChecker{exists: true}, true},
+		{"Check Failed", unavailableChecker{existsErr: errors.New("storage unavailable")}, true},
+		{"Not A Checker", unavailableStorage{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := StorageCheckpoints{Provider: tt.provider, TempFolder: os.TempDir()}
+			got, err := store.Load("abc123")
+			if got != nil || (err != nil) != tt.wantErr {
+				t.Errorf("StorageCheckpoints.Load() = %v, %v, wantErr %v", got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStorageCheckpoints_Delete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := checkpointStores(dir)["Storage"]
+	if err := store.Save(Checkpoint{Checksum: "abc123", Result: NewResult()}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Delete("abc123"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// The checkpoint is deleted rather than overwritten.
+	if _, err := os.Stat(dir + "/storage/checkpoints/abc123.json"); !os.IsNotExist(err) {
+		t.Errorf("Delete() kept the checkpoint: %v", err)
+	}
+}
+
+func TestCheckpoint_resumes(t *testing.T) {
+	audits := []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}}}
+	checkpoint := &Checkpoint{Message: message.Message{Audits: audits}, Stages: []string{"Ingest", "Info", "Phpcs"}}
+
+	tests := []struct {
+		name      string
+		msg       message.Message
+		completed []string
+		stages    int
+		want      bool
+	}{
+		{"Resumes", message.Message{Audits: audits}, []string{"Ingest"}, 4, true},
+		{"Other Audits", message.Message{}, []string{"Ingest"}, 4, false},
+		{"Other Stages", message.Message{Audits: audits}, []string{"Consumer"}, 4, false},
+		{"Nothing To Skip", message.Message{Audits: audits}, []string{"Ingest", "Info", "Phpcs"}, 4, false},
+		{"Completed", message.Message{Audits: audits}, []string{"Ingest"}, 3, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkpoint.resumes(tt.msg, tt.completed, tt.stages); got != tt.want {
+				t.Errorf("Checkpoint.resumes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipeline_Run_Checkpoints(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	restored := NewResult()
+	restored.Checksum = "resumed"
+	restored.Set("restored", true)
+
+	store := &memoryCheckpoints{checkpoints: map[string]Checkpoint{
+		"resumed": {Checksum: "resumed", Stages: []string{"relayStage", "relayStage#2"}, Result: restored},
+	}}
+
+	first, second, third := &relayStage{Name: "first"}, &relayStage{Name: "second"}, &relayStage{Name: "third"}
+	out := make(chan Processor, 2)
+	third.Out = out
+
+	pipeline := NewPipeline(first, second, third)
+	pipeline.Checkpoints = store
+
+	in := make(chan Processor, 2)
+	first.In = in
+	for _, checksum := range []string{"new", "resumed"} {
+		res := NewResult()
+		res.Checksum = checksum
+		res.FilesPath = "/tmp/" + checksum
+		in <- &relayStage{Process: Process{Message: message.Message{Title: checksum}, Result: res}}
+	}
+	close(in)
+
+	if err := pipeline.Run(context.Background()); err != nil {
+		t.Fatalf("Pipeline.Run() error = %v", err)
+	}
+
+	// The resumed message skips the second stage.
+	if !reflect.DeepEqual(first.titles, []string{"new", "resumed"}) || !reflect.DeepEqual(second.titles, []string{"new"}) || len(third.titles) != 2 {
+		t.Errorf("Pipeline.Run() stages received %v, %v, %v", first.titles, second.titles, third.titles)
+	}
+
+	// Checkpoints are saved after each stage and deleted once the message completed the pipeline.
+	want := [][]string{{"relayStage"}, {"relayStage", "relayStage#2"}}
+	if !reflect.DeepEqual(store.saved, want) {
+		t.Errorf("Pipeline.Run() saved %v, want %v", store.saved, want)
+	}
+	if len(store.checkpoints) != 0 || len(store.deleted) != 2 {
+		t.Errorf("Pipeline.Run() kept %v, deleted %v", store.checkpoints, store.deleted)
+	}
+
+	// The output of the last stage is still sent to its channel.
+	results := map[string]*Result{}
+	for proc := range out {
+		results[proc.GetMessage().Title] = proc.GetResult()
+	}
+	if len(results) != 2 {
+		t.Fatalf("Pipeline.Run() sent %v", results)
+	}
+	if got, _ := results["resumed"].Get("restored"); got != true || results["resumed"].FilesPath != "/tmp/resumed" {
+		t.Errorf("Pipeline.Run() did not resume from the checkpoint: %v", results["resumed"])
+	}
+}
@@ -0,0 +1,271 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/provision"
+)
+
+// Finding types raised by compliance checks.
+const (
+	FindingUninstall = "uninstall"
+	FindingLayout    = "layout"
+)
+
+var (
+	// Options stored with a literal name, e.g. `add_option( 'myplugin_settings', ... )`.
+	optionRe = regexp.MustCompile(`\b(?:add|update)_option\s*\(\s*['"]([^'"$]+)['"]`)
+	// Uninstall hook registrations, e.g. `register_uninstall_hook( __FILE__, 'myplugin_uninstall' )`.
+	uninstallHookRe = regexp.MustCompile(`\bregister_uninstall_hook\s*\(([^;]*)`)
+	// The constant that WordPress defines before including uninstall.php.
+	uninstallConstantRe = regexp.MustCompile(`\bWP_UNINSTALL_PLUGIN\b`)
+	// Table removal.
+	dropTableRe = regexp.MustCompile(`(?i)DROP\s+TABLE`)
+	// The last identifier in a table name, e.g. "items" in `$wpdb->prefix . 'items'`.
+	tableIdentifierRe = regexp.MustCompile(`(\w+)\W*$`)
+)
+
+// ComplianceCheck checks a project for compliance with the directory guidelines.
+type ComplianceCheck func(msg message.Message, res *Result, source *Source) []Finding
+
+// DefaultComplianceChecks are used when a Compliance process does not provide its own checks.
+var DefaultComplianceChecks = []ComplianceCheck{
+	CheckUninstall,
+	CheckMinified,
+	CheckLibraries,
+}
+
+// DefaultTypeComplianceChecks are used for project types with their own rules when a Compliance
+// process does not provide its own. mu-plugins and dropins are never uninstalled, so they are
+// not checked for uninstall routines.
+var DefaultTypeComplianceChecks = map[string][]ComplianceCheck{
+	ProjectTypeMuPlugin: {CheckMuPlugin, CheckMinified, CheckLibraries},
+	ProjectTypeDropin:   {CheckDropin, CheckMinified, CheckLibraries},
+}
+
+// Compliance defines the structure for our Compliance process.
+// It records directory compliance problems as findings in the result, along with the
+// third-party libraries bundled with the project.
+type Compliance struct {
+	Process                   // Inherits methods from Process.
+	In      <-chan Processor  // Expects a processor channel as input.
+	Out     chan Processor    // Send results to an output channel.
+	Checks  []ComplianceCheck // (Optional) Checks to run. Defaults to DefaultComplianceChecks.
+
+	// (Optional) Checks to run instead of Checks for a project type. Defaults to DefaultTypeComplianceChecks.
+	TypeChecks map[string][]ComplianceCheck
+}
+
+// Run executes the process in a pipe.
+func (cp *Compliance) Run(sink ErrorSink) error {
+	if cp.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if cp.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	cp.runStage(sink, stage{name: "Compliance", in: cp.In, out: cp.Out, do: cp.Do})
+
+	return nil
+}
+
+// Do runs the compliance checks and adds their findings to the result.
+func (cp *Compliance) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if res == nil || res.FilesPath == "" {
+		return res, errors.New("could not determine files path")
+	}
+
+	log.Log(msg.Title, "Running compliance checks...")
+
+	source, err := loadSource(res, pathFilter{})
+	if err != nil {
+		return res, err
+	}
+
+	typeChecks := cp.TypeChecks
+	if typeChecks == nil {
+		typeChecks = DefaultTypeComplianceChecks
+	}
+
+	checks, ok := typeChecks[projectType(msg, res)]
+	if !ok {
+		checks = cp.Checks
+	}
+	if len(checks) == 0 {
+		checks = DefaultComplianceChecks
+	}
+
+	// Record the bundled libraries once, CheckLibraries checks the recorded ones.
+	res.Libraries = bundledLibraries(res)
+
+	count := 0
+	for _, check := range checks {
+		for _, finding := range check(msg, res, source) {
+			finding.Process = "Compliance"
+			res.AddFinding(finding)
+			count++
+		}
+	}
+
+	log.Log(msg.Title, fmt.Sprintf("compliance checks found %d problems", count))
+
+	return res, nil
+}
+
+// CheckUninstall checks that plugins remove the options and tables they create when
+// they are uninstalled, using uninstall.php or an uninstall hook.
+func CheckUninstall(msg message.Message, res *Result, source *Source) []Finding {
+	if projectType(msg, res) != provision.TypePlugin {
+		return nil
+	}
+
+	var findings []Finding
+	warn := func(file, text string) {
+		findings = append(findings, Finding{
+			Type:     FindingUninstall,
+			File:     file,
+			Message:  text,
+			Severity: FindingWarning,
+		})
+	}
+
+	// Collect the code that runs when the plugin is uninstalled.
+	var uninstall []string
+	if src, ok := source.Files["uninstall.php"]; ok {
+		uninstall = append(uninstall, src)
+		if !uninstallConstantRe.MatchString(src) {
+			warn("uninstall.php", "uninstall.php does not check that WP_UNINSTALL_PLUGIN is defined")
+		}
+	}
+
+	for _, file := range sortedFiles(source) {
+		src := source.Files[file]
+		for _, match := range uninstallHookRe.FindAllStringSubmatchIndex(src, -1) {
+			if body, ok := handlerBody(source, src, match[2], match[3]); ok {
+				uninstall = append(uninstall, body)
+			}
+		}
+	}
+	cleanup := strings.Join(uninstall, "\n")
+
+	// Collect the data that is created by the plugin.
+	options := make(map[string]string)
+	var tables []TableSchema
+	for _, file := range sortedFiles(source) {
+		src := source.Files[file]
+		for _, m := range optionRe.FindAllStringSubmatch(src, -1) {
+			if _, ok := options[m[1]]; !ok {
+				options[m[1]] = file
+			}
+		}
+		for _, table := range findTables(src, file) {
+			table.Name = tableIdentifier(src, table.Name)
+			tables = append(tables, table)
+		}
+	}
+
+	if len(options) == 0 && len(tables) == 0 {
+		return findings
+	}
+
+	if len(uninstall) == 0 {
+		warn("", "plugin stores data but has no uninstall.php or uninstall hook")
+	}
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !regexp.MustCompile(`\bdelete_option\s*\(\s*['"]` + regexp.QuoteMeta(name) + `['"]`).MatchString(cleanup) {
+			warn(options[name], "option "+name+" is not deleted on uninstall")
+		}
+	}
+
+	for _, table := range tables {
+		if !dropTableRe.MatchString(cleanup) || !strings.Contains(cleanup, table.Name) {
+			warn(table.File, "table "+table.Name+" is not dropped on uninstall")
+		}
+	}
+
+	return findings
+}
+
+// CheckMuPlugin checks that WordPress loads the mu-plugin. WordPress only loads the PHP files
+// in the root of the mu-plugins folder, so plugins in a folder need a loader.
+func CheckMuPlugin(msg message.Message, res *Result, source *Source) []Finding {
+	if projectType(msg, res) != ProjectTypeMuPlugin {
+		return nil
+	}
+
+	for file := range source.Files {
+		if !strings.Contains(file, "/") && strings.HasSuffix(strings.ToLower(file), ".php") {
+			return nil
+		}
+	}
+
+	return []Finding{{
+		Type:     FindingLayout,
+		Message:  "mu-plugin has no PHP file in the root folder, WordPress does not load mu-plugins in folders",
+		Severity: FindingWarning,
+	}}
+}
+
+// CheckDropin checks that WordPress loads the PHP files in the root of a dropin. Files in
+// folders can be included by the dropins.
+func CheckDropin(msg message.Message, res *Result, source *Source) []Finding {
+	if projectType(msg, res) != ProjectTypeDropin {
+		return nil
+	}
+
+	var findings []Finding
+	for _, file := range sortedFiles(source) {
+		if strings.Contains(file, "/") || isDropin(file) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Type:     FindingLayout,
+			File:     file,
+			Message:  file + " is not a dropin, WordPress does not load it",
+			Severity: FindingWarning,
+		})
+	}
+	return findings
+}
+
+// tableIdentifier returns the literal part of a table name, resolving variables assigned in src.
+// For example `$table` with `$table = $wpdb->prefix . 'items';` becomes "items".
+func tableIdentifier(src, name string) string {
+	variable := strings.Trim(name, "{}")
+	if strings.HasPrefix(variable, "$") && !strings.Contains(variable, "->") {
+		assignment := regexp.MustCompile(regexp.QuoteMeta(variable) + `\s*=\s*([^;]+);`)
+		if m := assignment.FindStringSubmatch(src); m != nil {
+			name = m[1]
+		}
+	}
+
+	if m := tableIdentifierRe.FindStringSubmatch(name); m != nil {
+		return m[1]
+	}
+	return name
+}
+
+// sortedFiles returns the file names of the source in order.
+func sortedFiles(source *Source) []string {
+	files := make([]string, 0, len(source.Files))
+	for file := range source.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
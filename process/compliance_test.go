@@ -0,0 +1,346 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+func TestCompliance_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		cp      *Compliance
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Compliance{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+			},
+			false,
+		},
+		{
+			"No In Channel",
+			&Compliance{
+				Out: make(chan Processor),
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Compliance{
+				In: make(chan Processor),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.cp.SetContext(ctx)
+
+			if err := tt.cp.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Compliance.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompliance_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	custom := func(msg message.Message, res *Result, source *Source) []Finding {
+		return []Finding{{Type: "custom", File: "plugin.php", Message: "custom", Severity: FindingInfo}}
+	}
+
+	tests := []struct {
+		name    string
+		cp      *Compliance
+		res     *Result
+		want    []Finding
+		wantErr bool
+	}{
+		{
+			"Default Checks",
+			&Compliance{},
+			&Result{FilesPath: "./testdata/compliance/none", Files: []string{"./testdata/compliance/none/unzipped/plugin.php"}},
+			[]Finding{
+				{"Compliance", FindingUninstall, "", "plugin stores data but has no uninstall.php or uninstall hook", FindingWarning},
+				{"Compliance", FindingUninstall, "plugin.php", "option cnp_settings is not deleted on uninstall", FindingWarning},
+			},
+			false,
+		},
+		{
+			"Custom Checks",
+			&Compliance{Checks: []ComplianceCheck{custom}},
+			&Result{FilesPath: "./testdata/compliance/none", Files: []string{"./testdata/compliance/none/unzipped/plugin.php"}},
+			[]Finding{
+				{"Compliance", "custom", "plugin.php", "custom", FindingInfo},
+			},
+			false,
+		},
+		{
+			"No Files Path",
+			&Compliance{},
+			&Result{},
+			nil,
+			true,
+		},
+		{
+			"No Result",
+			&Compliance{},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Missing File",
+			&Compliance{},
+			&Result{FilesPath: "./testdata/compliance/none", Files: []string{"./testdata/compliance/none/unzipped/missing.php"}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.cp.Do(context.Background(), message.Message{ProjectType: "plugin"}, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compliance.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(res.Findings, tt.want) {
+				t.Errorf("Compliance.Do() findings = %v, want %v", res.Findings, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckUninstall(t *testing.T) {
+	tests := []struct {
+		name        string
+		projectType string
+		path        string
+		files       []string
+		want        []Finding
+	}{
+		{
+			"uninstall.php",
+			"plugin",
+			"./testdata/compliance/uninstall",
+			[]string{"plugin.php", "uninstall.php"},
+			[]Finding{
+				{"", FindingUninstall, "uninstall.php", "uninstall.php does not check that WP_UNINSTALL_PLUGIN is defined", FindingWarning},
+				{"", FindingUninstall, "plugin.php", "option ctp_version is not deleted on uninstall", FindingWarning},
+			},
+		},
+		{
+			"Uninstall Hook",
+			"plugin",
+			"./testdata/compliance/hook",
+			[]string{"plugin.php"},
+			nil,
+		},
+		{
+			"No Uninstall",
+			"plugin",
+			"./testdata/compliance/none",
+			[]string{"plugin.php"},
+			[]Finding{
+				{"", FindingUninstall, "", "plugin stores data but has no uninstall.php or uninstall hook", FindingWarning},
+				{"", FindingUninstall, "plugin.php", "option cnp_settings is not deleted on uninstall", FindingWarning},
+			},
+		},
+		{
+			"Theme",
+			"theme",
+			"./testdata/compliance/none",
+			[]string{"plugin.php"},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Result{FilesPath: tt.path}
+			for _, file := range tt.files {
+				res.Files = append(res.Files, tt.path+"/unzipped/"+file)
+			}
+
+			source, err := loadSource(res, pathFilter{})
+			if err != nil {
+				t.Fatalf("loadSource() error = %v", err)
+			}
+
+			if got := CheckUninstall(message.Message{ProjectType: tt.projectType}, res, source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckUninstall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompliance_Do_TypeChecks(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	custom := func(msg message.Message, res *Result, source *Source) []Finding {
+		return []Finding{{Type: "custom", Message: "custom", Severity: FindingInfo}}
+	}
+
+	tests := []struct {
+		name        string
+		cp          *Compliance
+		projectType string
+		want        []Finding
+	}{
+		{
+			"Dropin Rules",
+			&Compliance{},
+			ProjectTypeDropin,
+			[]Finding{{"Compliance", FindingLayout, "plugin.php", "plugin.php is not a dropin, WordPress does not load it", FindingWarning}},
+		},
+		{
+			"Custom Type Rules",
+			&Compliance{TypeChecks: map[string][]ComplianceCheck{ProjectTypeMuPlugin: {custom}}},
+			ProjectTypeMuPlugin,
+			[]Finding{{"Compliance", "custom", "", "custom", FindingInfo}},
+		},
+		{
+			"Custom Checks For Other Types",
+			&Compliance{Checks: []ComplianceCheck{custom}, TypeChecks: map[string][]ComplianceCheck{}},
+			ProjectTypeDropin,
+			[]Finding{{"Compliance", "custom", "", "custom", FindingInfo}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The plugin stores data without an uninstall routine, which only matters for plugins.
+			res := &Result{FilesPath: "./testdata/compliance/none", Files: []string{"./testdata/compliance/none/unzipped/plugin.php"}}
+
+			res, err := tt.cp.Do(context.Background(), message.Message{ProjectType: tt.projectType}, res)
+			if err != nil {
+				t.Fatalf("Compliance.Do() error = %v", err)
+			}
+			if !reflect.DeepEqual(res.Findings, tt.want) {
+				t.Errorf("Compliance.Do() findings = %v, want %v", res.Findings, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		check       ComplianceCheck
+		projectType string
+		path        string
+		files       []string
+		want        []Finding
+	}{
+		{
+			"Dropin",
+			CheckDropin,
+			ProjectTypeDropin,
+			"./testdata/info/dropin",
+			[]string{"debug.php", "object-cache.php"},
+			[]Finding{{"", FindingLayout, "debug.php", "debug.php is not a dropin, WordPress does not load it", FindingWarning}},
+		},
+		{
+			"Dropins",
+			CheckDropin,
+			ProjectTypeDropin,
+			"./testdata/info/dropins",
+			[]string{"advanced-cache.php", "object-cache.php"},
+			nil,
+		},
+		{
+			"Not A Dropin",
+			CheckDropin,
+			"plugin",
+			"./testdata/info/dropin",
+			[]string{"debug.php"},
+			nil,
+		},
+		{
+			"MU Plugin Loader",
+			CheckMuPlugin,
+			ProjectTypeMuPlugin,
+			"./testdata/info/mu-plugin",
+			[]string{"loader.php", "my-plugin/my-plugin.php"},
+			nil,
+		},
+		{
+			"MU Plugin Without Loader",
+			CheckMuPlugin,
+			ProjectTypeMuPlugin,
+			"./testdata/info/mu-plugin",
+			[]string{"my-plugin/my-plugin.php"},
+			[]Finding{{"", FindingLayout, "", "mu-plugin has no PHP file in the root folder, WordPress does not load mu-plugins in folders", FindingWarning}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Result{FilesPath: tt.path}
+			for _, file := range tt.files {
+				res.Files = append(res.Files, tt.path+"/unzipped/"+file)
+			}
+
+			source, err := loadSource(res, pathFilter{})
+			if err != nil {
+				t.Fatalf("loadSource() error = %v", err)
+			}
+
+			if got := tt.check(message.Message{ProjectType: tt.projectType}, res, source); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMuPlugin_OtherFiles(t *testing.T) {
+	source := &Source{Files: map[string]string{
+		"README.md":               "",
+		"LICENSE":                 "",
+		"my-plugin/my-plugin.php": "<?php",
+	}}
+
+	// Files that are not PHP files don't load the mu-plugin.
+	want := []Finding{{"", FindingLayout, "", "mu-plugin has no PHP file in the root folder, WordPress does not load mu-plugins in folders", FindingWarning}}
+	if got := CheckMuPlugin(message.Message{ProjectType: ProjectTypeMuPlugin}, &Result{}, source); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckMuPlugin() = %v, want %v", got, want)
+	}
+}
+
+func Test_tableIdentifier(t *testing.T) {
+	src := `$table = $wpdb->prefix . 'items';`
+
+	tests := []struct {
+		name  string
+		table string
+		want  string
+	}{
+		{"Literal", "myplugin_items", "myplugin_items"},
+		{"Prefix", "{$wpdb->prefix}items", "items"},
+		{"Variable", "$table", "items"},
+		{"Unknown Variable", "$unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tableIdentifier(src, tt.table); got != tt.want {
+				t.Errorf("tableIdentifier() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
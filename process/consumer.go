@@ -0,0 +1,129 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Consumer is the first process of a pipeline. It receives messages from a queue and feeds
+// them to the next process, usually Ingest.
+//
+// Providers that return raw envelopes, e.g. Pub/Sub or Kafka, can be used by wrapping them in a
+// message.DecodingProvider with a matching message.Decoder.
+type Consumer struct {
+	Process                         // Inherits methods from Process.
+	Out        chan message.Message // Send messages to the next process, e.g. Ingest.
+	Provider   message.Provider     // Queue to receive messages from.
+	Poller     *message.Poller      // (Optional) Waits between empty polls. Polls without waiting if nil.
+	LeaseRenew time.Duration        // (Optional) Extends the lease of messages by the duration while they are processed. Not extended if 0, e.g. if the provider extends them itself.
+}
+
+// Run polls the provider until the context is cancelled. Each message carries its lease through
+// the pipeline, see message.Lease. Response settles it once the results were sent, so a message
+// is delivered again if the worker stops before. Messages that can not be decoded are reported
+// to the sink and rejected without requeueing, so that they are not delivered again; providers
+// that can dead-letter them do, the others delete them.
+func (c *Consumer) Run(sink ErrorSink) error {
+	if c.Out == nil {
+		return errors.New("requires a next process")
+	}
+	if c.Provider == nil {
+		return errors.New("requires a message provider")
+	}
+
+	c.start()
+	queue := message.NewLeaseProvider(c.Provider)
+
+	go func() {
+		// Close the out channel and signal that we are done when the goroutine exits.
+		defer func() {
+			close(c.Out)
+			c.stop(nil)
+		}()
+
+		for {
+			select {
+			case <-c.getContext().Done():
+				// The pipeline has been cancelled.
+				return
+			default:
+			}
+
+			msg, err := c.Provider.GetNextMessage()
+
+			if msg == nil {
+				// Empty queues are not an error, but providers report them as one.
+				if pErr, ok := err.(*message.ProviderError); ok {
+					reportError(sink, nil, NewError("Consumer", message.Message{}, pErr))
+				}
+
+				c.Poller.Polled(false)
+				if !c.wait(c.Poller.Delay()) {
+					return
+				}
+				continue
+			}
+
+			c.Poller.Polled(true)
+
+			if err != nil {
+				// The message will never decode, delete it instead of receiving it again.
+				reportError(sink, nil, NewError("Consumer", *msg, err))
+				c.settle(sink, *msg, func(ref *string) error {
+					return queue.Nack(ref, false)
+				})
+				continue
+			}
+
+			if msg.ExternalRef != nil {
+				msg.Lease = message.NewLease(queue, msg.ExternalRef)
+				msg.Lease.KeepAlive(c.LeaseRenew)
+			}
+
+			// Send the message to the out channel.
+			select {
+			case c.Out <- *msg:
+			case <-c.getContext().Done():
+				// Let another worker receive the message right away.
+				c.settle(sink, *msg, func(ref *string) error {
+					return msg.Lease.Nack(true)
+				})
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Do passes the message on unchanged. The consumer only receives messages.
+func (c *Consumer) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	return res, nil
+}
+
+// settle acknowledges or rejects the message and reports if that fails.
+func (c *Consumer) settle(sink ErrorSink, msg message.Message, settle func(ref *string) error) {
+	if msg.ExternalRef == nil {
+		return
+	}
+	if err := settle(msg.ExternalRef); err != nil {
+		reportError(sink, nil, NewError("Consumer", msg, err))
+	}
+}
+
+// wait waits for the delay. It returns false if the context was cancelled while waiting.
+func (c *Consumer) wait(delay time.Duration) bool {
+	if delay <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(delay):
+		return true
+	case <-c.getContext().Done():
+		return false
+	}
+}
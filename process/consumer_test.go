@@ -0,0 +1,187 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// mockQueue returns its messages in order and then reports an empty queue.
+type mockQueue struct {
+	mu       sync.Mutex
+	messages []*message.Message
+	errs     []error
+	deleted  []string
+}
+
+func (m *mockQueue) SendMessage(msg *message.Message) error {
+	return nil
+}
+
+func (m *mockQueue) GetNextMessage() (*message.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.messages) == 0 {
+		return nil, errors.New("could not retrieve message")
+	}
+
+	msg, err := m.messages[0], m.errs[0]
+	m.messages, m.errs = m.messages[1:], m.errs[1:]
+	return msg, err
+}
+
+func (m *mockQueue) DeleteMessage(ref *string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if *ref == "delete-error" {
+		return errors.New("could not delete message")
+	}
+	m.deleted = append(m.deleted, *ref)
+	return nil
+}
+
+func (m *mockQueue) Close() error {
+	return nil
+}
+
+func (m *mockQueue) getDeleted() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.deleted...)
+}
+
+func TestConsumer_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		c       *Consumer
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Consumer{
+				Out:      make(chan message.Message),
+				Provider: &mockQueue{},
+				Poller:   message.NewPoller(time.Millisecond, time.Millisecond),
+			},
+			false,
+		},
+		{
+			"No Out Channel",
+			&Consumer{
+				Provider: &mockQueue{},
+			},
+			true,
+		},
+		{
+			"No Provider",
+			&Consumer{
+				Out: make(chan message.Message),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.c.SetContext(ctx)
+
+			if err := tt.c.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Consumer.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConsumer_Messages(t *testing.T) {
+	ref := func(s string) *string { return &s }
+
+	queue := &mockQueue{
+		messages: []*message.Message{
+			{Title: "Plugin", ExternalRef: ref("first")},
+			{ExternalRef: ref("poison")},
+			{Title: "Theme", ExternalRef: ref("delete-error")},
+			nil,
+		},
+		errs: []error{
+			nil,
+			errors.New("could not decode message"),
+			nil,
+			message.NewProviderError("over quota"),
+		},
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	errc := make(ErrorChannel, 10)
+	c := &Consumer{
+		Out:      make(chan message.Message),
+		Provider: queue,
+		Poller:   message.NewPoller(time.Millisecond, time.Millisecond),
+	}
+	c.SetContext(ctx)
+
+	if err := c.Run(errc); err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	var leases []*message.Lease
+	for _, want := range []string{"Plugin", "Theme"} {
+		select {
+		case msg := <-c.Out:
+			titles = append(titles, msg.Title)
+			leases = append(leases, msg.Lease)
+		case <-time.After(time.Second):
+			t.Fatalf("Consumer.Run() did not send %v", want)
+		}
+	}
+
+	if !reflect.DeepEqual(titles, []string{"Plugin", "Theme"}) {
+		t.Errorf("Consumer.Run() sent %v", titles)
+	}
+
+	var reported []string
+	for len(reported) < 2 {
+		select {
+		case err := <-errc:
+			reported = append(reported, err.Err.Error())
+		case <-time.After(time.Second):
+			t.Fatalf("Consumer.Run() reported %v", reported)
+		}
+	}
+
+	want := []string{"could not decode message", "over quota"}
+	if !reflect.DeepEqual(reported, want) {
+		t.Errorf("Consumer.Run() reported %v, want %v", reported, want)
+	}
+
+	// Messages are only acknowledged once they were processed.
+	if deleted := queue.getDeleted(); !reflect.DeepEqual(deleted, []string{"poison"}) {
+		t.Errorf("Consumer.Run() deleted %v", deleted)
+	}
+	if err := leases[0].Ack(); err != nil {
+		t.Errorf("Lease.Ack() error = %v", err)
+	}
+	if err := leases[1].Ack(); err == nil {
+		t.Errorf("Lease.Ack() error = nil, want the error of the queue")
+	}
+	if deleted := queue.getDeleted(); !reflect.DeepEqual(deleted, []string{"poison", "first"}) {
+		t.Errorf("Lease.Ack() deleted %v", deleted)
+	}
+
+	cancelFunc()
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Error("Consumer.Run() did not stop")
+	}
+}
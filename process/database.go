@@ -0,0 +1,321 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+var (
+	// Table definitions, e.g. `CREATE TABLE $table_name (`.
+	createTableRe = regexp.MustCompile(`(?i)CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?`)
+	// Direct queries through $wpdb and schema changes through dbDelta.
+	dbQueryRe = regexp.MustCompile(`\$wpdb\s*->\s*(query|get_results|get_row|get_var|get_col|insert|update|delete|replace)\s*\(|\b(dbDelta)\s*\(`)
+	// Table names that hardcode the default "wp_" prefix.
+	hardcodedPrefixRe = regexp.MustCompile(`(?i)\b(FROM|INTO|UPDATE|JOIN|TABLE)\s+` + "`?" + `wp_\w+`)
+	// The charset and collation of a table.
+	charsetRe = regexp.MustCompile(`(?i)charset_collate|CHARSET|COLLATE`)
+	// Index definitions in a table definition.
+	indexRe = regexp.MustCompile(`(?i)^(PRIMARY|UNIQUE|KEY|INDEX|FULLTEXT|SPATIAL|CONSTRAINT|FOREIGN)\b`)
+)
+
+// TableSchema is a table declared with CREATE TABLE.
+type TableSchema struct {
+	Name    string        `json:"name"` // The table name as written in the source, e.g. "$table_name".
+	Columns []TableColumn `json:"columns"`
+	Indexes []string      `json:"indexes,omitempty"`
+	Charset bool          `json:"charset"` // Whether a charset or collation is declared.
+	File    string        `json:"file"`
+	Line    int           `json:"line"`
+}
+
+// TableColumn is a column of a TableSchema.
+type TableColumn struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// DatabaseQuery is a direct $wpdb query or dbDelta call.
+type DatabaseQuery struct {
+	Method   string `json:"method"`
+	Prepared bool   `json:"prepared"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// Database defines the structure for our Database process.
+// It extracts table schemas and direct queries and flags tables that don't use the
+// WordPress table prefix or declare a charset.
+type Database struct {
+	Process                  // Inherits methods from Process.
+	In      <-chan Processor // Expects a processor channel as input.
+	Out     chan Processor   // Send results to an output channel.
+}
+
+// Run executes the process in a pipe.
+func (db *Database) Run(sink ErrorSink) error {
+	if db.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if db.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	db.runStage(sink, stage{name: "Database", in: db.In, out: db.Out, do: db.Do})
+
+	return nil
+}
+
+// Do runs a database audit if the message requests one and adds the tables, queries
+// and issues to the "database" audit of the result.
+func (db *Database) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if !hasAudit(msg, "database") {
+		return res, nil
+	}
+
+	if res == nil || res.FilesPath == "" {
+		return res, errors.New("could not determine files path")
+	}
+
+	if skipped(res, "database") {
+		return res, nil
+	}
+
+	log.Log(msg.Title, "Running Database Audit...")
+
+	source, err := loadSource(res, newPathFilter(auditOptions(msg, "database")))
+	if err != nil {
+		return res, err
+	}
+
+	tables := []TableSchema{}
+	queries := []DatabaseQuery{}
+	issues := []Issue{}
+
+	for file, src := range source.Files {
+		for _, table := range findTables(src, file) {
+			tables = append(tables, table)
+
+			if !table.Charset {
+				issues = append(issues, Issue{
+					Rule:     "missing-charset",
+					Severity: SeverityMedium,
+					File:     file,
+					Line:     table.Line,
+					Message:  "table " + table.Name + " does not declare a charset or collation",
+				})
+			}
+
+			if !usesPrefix(src, table.Name) {
+				issues = append(issues, Issue{
+					Rule:     "missing-prefix",
+					Severity: SeverityHigh,
+					File:     file,
+					Line:     table.Line,
+					Message:  "table " + table.Name + " does not use the table prefix",
+				})
+			}
+		}
+
+		queries = append(queries, findQueries(src, file)...)
+
+		for i, line := range strings.Split(src, "\n") {
+			if hardcodedPrefixRe.MatchString(line) && !createTableRe.MatchString(line) {
+				issues = append(issues, Issue{
+					Rule:     "hardcoded-prefix",
+					Severity: SeverityHigh,
+					File:     file,
+					Line:     i + 1,
+					Message:  "query uses the hardcoded wp_ table prefix",
+				})
+			}
+		}
+	}
+
+	sortTables(tables)
+	sortQueries(queries)
+	sortIssues(issues)
+
+	res.SetAudit("database", tide.AuditResult{
+		Extra: map[string]interface{}{
+			"tables":  tables,
+			"queries": queries,
+			"issues":  issues,
+		},
+	})
+
+	log.Log(msg.Title, fmt.Sprintf("database audit found %d tables, %d queries and %d issues", len(tables), len(queries), len(issues)))
+
+	return res, nil
+}
+
+// findTables extracts the CREATE TABLE statements in src.
+func findTables(src, file string) []TableSchema {
+	var tables []TableSchema
+
+	for _, match := range createTableRe.FindAllStringIndex(src, -1) {
+		open := strings.Index(src[match[1]:], "(")
+		if open == -1 {
+			continue
+		}
+		open += match[1]
+
+		body := parenBlock(src, open)
+		if body == "" {
+			continue
+		}
+
+		// The table options end with the SQL string.
+		rest := src[open+len(body):]
+		if end := strings.IndexAny(rest, `"';`); end != -1 {
+			rest = rest[:end]
+		}
+
+		table := TableSchema{
+			Name:    tableName(src[match[1]:open]),
+			Columns: []TableColumn{},
+			Charset: charsetRe.MatchString(rest),
+			File:    file,
+			Line:    strings.Count(src[:match[0]], "\n") + 1,
+		}
+
+		for _, definition := range splitDefinitions(body[1 : len(body)-1]) {
+			if indexRe.MatchString(definition) {
+				table.Indexes = append(table.Indexes, definition)
+				continue
+			}
+
+			name := strings.Trim(strings.Fields(definition)[0], "`")
+			table.Columns = append(table.Columns, TableColumn{Name: name, Definition: definition})
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables
+}
+
+// findQueries extracts the direct queries and dbDelta calls in src.
+func findQueries(src, file string) []DatabaseQuery {
+	var queries []DatabaseQuery
+
+	for i, line := range strings.Split(src, "\n") {
+		for _, m := range dbQueryRe.FindAllStringSubmatch(line, -1) {
+			method := m[1]
+			if method == "" {
+				method = m[2]
+			}
+
+			queries = append(queries, DatabaseQuery{
+				Method:   method,
+				Prepared: strings.Contains(line, "prepare("),
+				File:     file,
+				Line:     i + 1,
+			})
+		}
+	}
+
+	return queries
+}
+
+// tableName cleans up the source between CREATE TABLE and the column definitions,
+// e.g. `" . $wpdb->prefix . "items ` becomes `$wpdb->prefix . "items`.
+func tableName(name string) string {
+	return strings.Trim(name, " \t\r\n.'\"`")
+}
+
+// usesPrefix checks if the table name uses $wpdb->prefix, either directly or
+// through a variable that is assigned in src.
+func usesPrefix(src, name string) bool {
+	if strings.Contains(name, "prefix") {
+		return true
+	}
+
+	variable := strings.Trim(name, "{}")
+	if !strings.HasPrefix(variable, "$") {
+		return false
+	}
+
+	assignment := regexp.MustCompile(regexp.QuoteMeta(variable) + `\s*=\s*([^;]+);`)
+	for _, m := range assignment.FindAllStringSubmatch(src, -1) {
+		if strings.Contains(m[1], "prefix") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parenBlock returns the parenthesised block that starts at offset, or an empty string
+// if it is not closed.
+func parenBlock(src string, offset int) string {
+	depth := 0
+	for i := offset; i < len(src); i++ {
+		switch src[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return src[offset : i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// splitDefinitions splits the column and index definitions of a table on top level commas.
+func splitDefinitions(body string) []string {
+	var definitions []string
+
+	depth, start := 0, 0
+	add := func(definition string) {
+		if definition = strings.Join(strings.Fields(definition), " "); definition != "" {
+			definitions = append(definitions, definition)
+		}
+	}
+
+	for i, c := range body {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				add(body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	add(body[start:])
+
+	return definitions
+}
+
+// sortTables orders tables by file and line.
+func sortTables(tables []TableSchema) {
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].File != tables[j].File {
+			return tables[i].File < tables[j].File
+		}
+		return tables[i].Line < tables[j].Line
+	})
+}
+
+// sortQueries orders queries by file and line.
+func sortQueries(queries []DatabaseQuery) {
+	sort.Slice(queries, func(i, j int) bool {
+		if queries[i].File != queries[j].File {
+			return queries[i].File < queries[j].File
+		}
+		return queries[i].Line < queries[j].Line
+	})
+}
@@ -0,0 +1,209 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+func TestDatabase_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		db      *Database
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Database{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+			},
+			false,
+		},
+		{
+			"No In Channel",
+			&Database{
+				Out: make(chan Processor),
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Database{
+				In: make(chan Processor),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.db.SetContext(ctx)
+
+			if err := tt.db.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Database.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDatabase_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	databaseMsg := message.Message{
+		Title:  "Database Test Plugin",
+		Audits: []*message.Audit{{Type: "database"}},
+	}
+
+	files := []string{
+		"./testdata/database/unzipped/install.php",
+		"./testdata/database/unzipped/readme.txt",
+	}
+
+	tests := []struct {
+		name        string
+		msg         message.Message
+		res         *Result
+		wantTables  []TableSchema
+		wantQueries []DatabaseQuery
+		wantIssues  []Issue
+		wantErr     bool
+	}{
+		{
+			"Not Requested",
+			message.Message{Audits: []*message.Audit{{Type: "security"}}},
+			&Result{FilesPath: "./testdata/database", Files: files},
+			nil,
+			nil,
+			nil,
+			false,
+		},
+		{
+			"Plugin",
+			databaseMsg,
+			&Result{FilesPath: "./testdata/database", Files: files},
+			[]TableSchema{
+				{
+					Name: "$table_name",
+					Columns: []TableColumn{
+						{"id", "id mediumint(9) NOT NULL AUTO_INCREMENT"},
+						{"name", "name varchar(55) DEFAULT '' NOT NULL"},
+						{"price", "price decimal(10,2) NOT NULL"},
+					},
+					Indexes: []string{"PRIMARY KEY (id)", "KEY name (name)"},
+					Charset: true,
+					File:    "install.php",
+					Line:    9,
+				},
+				{
+					Name: "dbtp_log",
+					Columns: []TableColumn{
+						{"id", "id bigint(20) NOT NULL"},
+						{"message", "message text"},
+					},
+					File: "install.php",
+					Line: 20,
+				},
+			},
+			[]DatabaseQuery{
+				{"dbDelta", false, "install.php", 18},
+				{"query", false, "install.php", 20},
+				{"get_row", true, "install.php", 28},
+				{"get_results", false, "install.php", 29},
+			},
+			[]Issue{
+				{"missing-prefix", SeverityHigh, "install.php", 20, "table dbtp_log does not use the table prefix"},
+				{"hardcoded-prefix", SeverityHigh, "install.php", 29, "query uses the hardcoded wp_ table prefix"},
+				{"missing-charset", SeverityMedium, "install.php", 20, "table dbtp_log does not declare a charset or collation"},
+			},
+			false,
+		},
+		{
+			"No Files Path",
+			databaseMsg,
+			&Result{Files: files},
+			nil,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Missing File",
+			databaseMsg,
+			&Result{FilesPath: "./testdata/database", Files: []string{"./testdata/database/unzipped/missing.php"}},
+			nil,
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &Database{}
+			res, err := db.Do(context.Background(), tt.msg, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Database.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			audit, ok := res.Audit("database")
+			if tt.wantTables == nil {
+				if ok {
+					t.Errorf("Database.Do() unexpected audit = %v", audit)
+				}
+				return
+			}
+
+			if got := audit.Extra["tables"]; !reflect.DeepEqual(got, tt.wantTables) {
+				t.Errorf("Database.Do() tables = %+v, want %+v", got, tt.wantTables)
+			}
+			if got := audit.Extra["queries"]; !reflect.DeepEqual(got, tt.wantQueries) {
+				t.Errorf("Database.Do() queries = %+v, want %+v", got, tt.wantQueries)
+			}
+			if got := audit.Extra["issues"]; !reflect.DeepEqual(got, tt.wantIssues) {
+				t.Errorf("Database.Do() issues = %+v, want %+v", got, tt.wantIssues)
+			}
+		})
+	}
+}
+
+func Test_usesPrefix(t *testing.T) {
+	src := `$table = $wpdb->prefix . 'items'; $other = 'items';`
+
+	tests := []struct {
+		name  string
+		table string
+		want  bool
+	}{
+		{"Prefix", "{$wpdb->prefix}items", true},
+		{"Concatenated", `$wpdb->prefix . "items`, true},
+		{"Variable", "$table", true},
+		{"Braced Variable", "{$table}", true},
+		{"Unprefixed Variable", "$other", false},
+		{"Unknown Variable", "$unknown", false},
+		{"Literal", "wp_items", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usesPrefix(src, tt.table); got != tt.want {
+				t.Errorf("usesPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_splitDefinitions(t *testing.T) {
+	got := splitDefinitions("\n\tid int(11),\n\tprice decimal(10,2),\n\tPRIMARY KEY  (id),\n")
+	want := []string{"id int(11)", "price decimal(10,2)", "PRIMARY KEY (id)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitDefinitions() = %v, want %v", got, want)
+	}
+}
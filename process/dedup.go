@@ -0,0 +1,151 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/process/phpcs"
+	"github.com/wptide/pkg/tide"
+)
+
+// FindingKey returns the identity of a reported message. Messages with the same identity
+// in different audits describe the same problem.
+type FindingKey func(file string, msg tide.PhpcsFilesMessage) string
+
+// Dedup defines the structure for our Dedup process.
+// It removes findings that were already reported by another audit so that the same problem
+// is not counted twice, e.g. when the wordpress and phpcompatibility standards both report
+// a removed PHP function.
+type Dedup struct {
+	Process                   // Inherits methods from Process.
+	In       <-chan Processor // Expects a processor channel as input.
+	Out      chan Processor   // Send results to an output channel.
+	Priority []string         // (Optional) Audit kinds that keep their findings first. The remaining kinds follow in order.
+	Key      FindingKey       // (Optional) Identity of a finding. Defaults to DefaultFindingKey.
+}
+
+// Run executes the process in a pipe.
+func (dd *Dedup) Run(sink ErrorSink) error {
+	if dd.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if dd.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	dd.runStage(sink, stage{name: "Dedup", in: dd.In, out: dd.Out, do: dd.Do})
+
+	return nil
+}
+
+// Do removes duplicate findings from the PHPCS reports of the result. A finding is kept by
+// the first audit that reported it and removed from the others, whose totals and summaries
+// are updated. The number of removed findings is added to the "duplicates" entry of the
+// audit result's Extra field. Reports that were too large to keep are logged and left as is.
+func (dd *Dedup) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if res == nil {
+		return res, errors.New("no result to deduplicate")
+	}
+
+	key := dd.Key
+	if key == nil {
+		key = DefaultFindingKey
+	}
+
+	// The kind of the audit that first reported each finding.
+	seen := make(map[string]string)
+	removed := 0
+	for _, kind := range dd.order(res.reports) {
+		report := res.reports[kind]
+		if report == nil {
+			log.Log(msg.Title, "The "+kind+" report is too large to deduplicate.")
+			continue
+		}
+
+		duplicates := 0
+		for name, file := range report.Files {
+			var kept []tide.PhpcsFilesMessage
+			for _, m := range file.Messages {
+				id := key(name, m)
+				if owner, ok := seen[id]; ok && owner != kind {
+					duplicates++
+					continue
+				}
+				seen[id] = kind
+				kept = append(kept, m)
+			}
+			file.Messages = kept
+			report.Files[name] = file
+		}
+
+		if duplicates == 0 {
+			continue
+		}
+		removed += duplicates
+
+		countMessages(report)
+
+		if audit, ok := res.Audit(kind); ok {
+			// Only replace summaries that were added by the transformers.
+			if audit.Summary.PhpcsSummary != nil {
+				audit.Summary.PhpcsSummary = phpcs.GetPhpcsSummary(*report)
+			}
+			if audit.Overview != nil {
+				// Removing messages never adds entries, so the longest list keeps the limit.
+				limit := len(audit.Overview.Sources)
+				if len(audit.Overview.Files) > limit {
+					limit = len(audit.Overview.Files)
+				}
+				audit.Overview = phpcs.GetPhpcsOverview(*report, limit)
+			}
+			if audit.Extra == nil {
+				audit.Extra = make(map[string]interface{})
+			}
+			audit.Extra["duplicates"] = duplicates
+			res.SetAudit(kind, audit)
+		}
+	}
+
+	if removed > 0 {
+		log.Log(msg.Title, fmt.Sprintf("Removed %d duplicate findings", removed))
+	}
+
+	return res, nil
+}
+
+// order returns the report kinds in the order they keep their findings.
+func (dd *Dedup) order(reports map[string]*tide.PhpcsResults) []string {
+	var kinds []string
+	added := make(map[string]bool)
+	for _, kind := range dd.Priority {
+		if _, ok := reports[kind]; ok && !added[kind] {
+			kinds = append(kinds, kind)
+			added[kind] = true
+		}
+	}
+
+	var rest []string
+	for kind := range reports {
+		if !added[kind] {
+			rest = append(rest, kind)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(kinds, rest...)
+}
+
+// DefaultFindingKey identifies a finding by its file, line and message. Sniffs from different
+// standards often report the same problem with their own source, so the source is ignored.
+// Messages are compared ignoring case, whitespace and trailing punctuation.
+func DefaultFindingKey(file string, msg tide.PhpcsFilesMessage) string {
+	text := strings.ToLower(strings.Join(strings.Fields(msg.Message), " "))
+	text = strings.TrimRight(text, ".;:")
+	return file + ":" + strconv.Itoa(msg.Line) + ":" + strings.ToUpper(msg.Type) + ":" + text
+}
@@ -0,0 +1,170 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/process/phpcs"
+	"github.com/wptide/pkg/tide"
+)
+
+func TestDedup_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		dd      *Dedup
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Dedup{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+			},
+			false,
+		},
+		{
+			"No In Channel",
+			&Dedup{
+				Out: make(chan Processor),
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Dedup{
+				In: make(chan Processor),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.dd.SetContext(ctx)
+
+			if err := tt.dd.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Dedup.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// dedupReport parses a PHPCS report and adds it to the result with a summary and overview.
+func dedupReport(t *testing.T, res *Result, kind, report string) {
+	results := &tide.PhpcsResults{}
+	if err := json.Unmarshal([]byte(report), results); err != nil {
+		t.Fatal(err)
+	}
+	res.setReport(kind, results)
+	res.SetAudit(kind, tide.AuditResult{
+		Summary:  tide.AuditSummary{PhpcsSummary: phpcs.GetPhpcsSummary(*results)},
+		Overview: phpcs.GetPhpcsOverview(*results, 0),
+	})
+}
+
+func TestDedup_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	wordpress := `{"totals":{"errors":2,"warnings":1},"files":{"plugin.php":{"errors":2,"warnings":1,"messages":[
+		{"message":"Function  create_function() is deprecated since PHP 7.2 and removed since PHP 8.0.","source":"WordPress.PHP.RestrictedPHPFunctions.create_function_create_function","type":"ERROR","line":3},
+		{"message":"All output should be escaped.","source":"WordPress.Security.EscapeOutput.OutputNotEscaped","type":"ERROR","line":5},
+		{"message":"All output should be escaped.","source":"WordPress.Security.EscapeOutput.OutputNotEscaped","type":"WARNING","line":5}
+	]}}}`
+	compatibility := `{"totals":{"errors":2},"files":{"plugin.php":{"errors":2,"messages":[
+		{"message":"function create_function() is deprecated since PHP 7.2 and removed since PHP 8.0","source":"PHPCompatibility.FunctionUse.RemovedFunctions.create_functionDeprecatedRemoved","type":"ERROR","line":3},
+		{"message":"Function each() is removed since PHP 8.0.","source":"PHPCompatibility.FunctionUse.RemovedFunctions.eachDeprecatedRemoved","type":"ERROR","line":4}
+	]}}}`
+
+	tests := []struct {
+		name       string
+		priority   []string
+		want       map[string]int // Remaining errors of each kind.
+		duplicates map[string]int
+	}{
+		{
+			"Default Order",
+			nil,
+			map[string]int{"phpcs_phpcompatibility": 2, "phpcs_wordpress": 1},
+			map[string]int{"phpcs_wordpress": 1},
+		},
+		{
+			"Priority",
+			[]string{"phpcs_wordpress"},
+			map[string]int{"phpcs_phpcompatibility": 1, "phpcs_wordpress": 2},
+			map[string]int{"phpcs_phpcompatibility": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := NewResult()
+			dedupReport(t, res, "phpcs_wordpress", wordpress)
+			dedupReport(t, res, "phpcs_phpcompatibility", compatibility)
+
+			dd := &Dedup{Priority: tt.priority}
+			if _, err := dd.Do(context.Background(), message.Message{}, res); err != nil {
+				t.Fatalf("Dedup.Do() error = %v", err)
+			}
+
+			for kind, want := range tt.want {
+				if got := res.reports[kind].Totals.Errors; got != want {
+					t.Errorf("Dedup.Do() %v errors = %v, want %v", kind, got, want)
+				}
+				audit, _ := res.Audit(kind)
+				if got := audit.Summary.PhpcsSummary.ErrorsCount; got != want {
+					t.Errorf("Dedup.Do() %v summary errors = %v, want %v", kind, got, want)
+				}
+				if got := audit.Overview.Files[0].Count; got != want {
+					t.Errorf("Dedup.Do() %v overview errors = %v, want %v", kind, got, want)
+				}
+				if got, _ := audit.Extra["duplicates"].(int); got != tt.duplicates[kind] {
+					t.Errorf("Dedup.Do() %v duplicates = %v, want %v", kind, got, tt.duplicates[kind])
+				}
+			}
+
+			// The warning on the same line as an error is a different finding.
+			if got := res.reports["phpcs_wordpress"].Totals.Warnings; got != 1 {
+				t.Errorf("Dedup.Do() warnings = %v, want 1", got)
+			}
+		})
+	}
+
+	// Reports that were too large to keep are logged.
+	res := NewResult()
+	res.setReport("phpcs_wordpress", nil)
+	b.Reset()
+	if _, err := (&Dedup{}).Do(context.Background(), message.Message{Title: "Test"}, res); err != nil || !strings.Contains(b.String(), "phpcs_wordpress report is too large to deduplicate") {
+		t.Errorf("Dedup.Do() = %v, logged %q", err, b.String())
+	}
+
+	if _, err := (&Dedup{}).Do(context.Background(), message.Message{}, nil); err == nil {
+		t.Errorf("Dedup.Do() expected an error without a result")
+	}
+}
+
+func TestDefaultFindingKey(t *testing.T) {
+	a := DefaultFindingKey("plugin.php", tide.PhpcsFilesMessage{Message: "Use  of eval() is forbidden.", Type: "error", Line: 2})
+	b := DefaultFindingKey("plugin.php", tide.PhpcsFilesMessage{Message: "use of eval() is forbidden", Type: "ERROR", Line: 2, Source: "Other"})
+	if a != b {
+		t.Errorf("DefaultFindingKey() = %v and %v, want the same key", a, b)
+	}
+
+	others := []string{
+		DefaultFindingKey("other.php", tide.PhpcsFilesMessage{Message: "Use of eval() is forbidden.", Type: "ERROR", Line: 2}),
+		DefaultFindingKey("plugin.php", tide.PhpcsFilesMessage{Message: "Use of eval() is forbidden.", Type: "ERROR", Line: 3}),
+		DefaultFindingKey("plugin.php", tide.PhpcsFilesMessage{Message: "Use of eval() is forbidden.", Type: "WARNING", Line: 2}),
+	}
+	for _, other := range others {
+		if a == other {
+			t.Errorf("DefaultFindingKey() = %v for a different finding", other)
+		}
+	}
+}
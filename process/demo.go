@@ -0,0 +1,50 @@
+package process
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/provision"
+)
+
+// demoURL returns the URL to audit and a function to clean up afterwards.
+// Without a provisioner the hosted theme demo is used.
+func demoURL(ctx context.Context, provisioner provision.Provisioner, msg message.Message, res *Result) (string, func(), error) {
+	if provisioner == nil {
+		return fmt.Sprintf("https://wp-themes.com/%s", msg.Slug), func() {}, nil
+	}
+
+	filesPath := ""
+	if res != nil {
+		filesPath = res.FilesPath
+	}
+
+	log.Log(msg.Title, "Provisioning demo environment...")
+
+	env, err := provisioner.Provision(ctx, provision.Project{
+		Slug: msg.Slug,
+		Type: projectType(msg, res),
+		Path: filesPath + "/unzipped",
+	})
+	if err != nil {
+		return "", nil, messageError(msg, "could not provision demo environment: "+err.Error())
+	}
+
+	teardown := func() {
+		if err := provisioner.Teardown(context.Background(), env); err != nil {
+			log.Log(msg.Title, "Could not tear down demo environment: "+err.Error())
+		}
+	}
+
+	return env.URL, teardown, nil
+}
+
+// projectType returns the detected type of the project, falling back to the type in the message.
+func projectType(msg message.Message, res *Result) string {
+	if res != nil && res.Info != nil && res.Info.Type != "" {
+		return res.Info.Type
+	}
+	return msg.ProjectType
+}
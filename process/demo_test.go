@@ -0,0 +1,140 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/provision"
+	"github.com/wptide/pkg/tide"
+)
+
+func Test_demoURL(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	tests := []struct {
+		name        string
+		provisioner *mockProvisioner
+		msg         message.Message
+		res         *Result
+		want        string
+		wantProject *provision.Project
+		wantErr     bool
+	}{
+		{
+			"Hosted Demo",
+			nil,
+			message.Message{Title: "Test", Slug: "test"},
+			NewResult(),
+			"https://wp-themes.com/test",
+			nil,
+			false,
+		},
+		{
+			"Provisioned Demo - Type From Info",
+			&mockProvisioner{},
+			message.Message{Title: "Test", Slug: "test", ProjectType: "plugin"},
+			&Result{
+				FilesPath: "./testdata/audit",
+				Info:      &tide.CodeInfo{Type: "theme"},
+			},
+			"http://localhost:8080",
+			&provision.Project{Slug: "test", Type: "theme", Path: "./testdata/audit/unzipped"},
+			false,
+		},
+		{
+			"Provisioned Demo - Type From Message",
+			&mockProvisioner{},
+			message.Message{Title: "Test", Slug: "test", ProjectType: "plugin"},
+			&Result{FilesPath: "./testdata/audit"},
+			"http://localhost:8080",
+			&provision.Project{Slug: "test", Type: "plugin", Path: "./testdata/audit/unzipped"},
+			false,
+		},
+		{
+			"Provision Error",
+			&mockProvisioner{},
+			message.Message{Title: "Test", Slug: "error", ProjectType: "plugin"},
+			nil,
+			"",
+			&provision.Project{Slug: "error", Type: "plugin", Path: "/unzipped"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var provisioner provision.Provisioner
+			if tt.provisioner != nil {
+				provisioner = tt.provisioner
+			}
+
+			got, teardown, err := demoURL(context.Background(), provisioner, tt.msg, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("demoURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("demoURL() = %v, want %v", got, tt.want)
+			}
+
+			if tt.provisioner == nil {
+				teardown()
+				return
+			}
+
+			if !reflect.DeepEqual(tt.provisioner.project, tt.wantProject) {
+				t.Errorf("demoURL() project = %v, want %v", tt.provisioner.project, tt.wantProject)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			teardown()
+			if tt.provisioner.teardown == nil || tt.provisioner.teardown.URL != got {
+				t.Errorf("demoURL() teardown = %v, want environment for %v", tt.provisioner.teardown, got)
+			}
+		})
+	}
+}
+
+func Test_projectType(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  message.Message
+		res  *Result
+		want string
+	}{
+		{
+			"Detected Type",
+			message.Message{ProjectType: "plugin"},
+			&Result{Info: &tide.CodeInfo{Type: "theme"}},
+			"theme",
+		},
+		{
+			"Message Type",
+			message.Message{ProjectType: "plugin"},
+			&Result{},
+			"plugin",
+		},
+		{
+			"No Result",
+			message.Message{ProjectType: "theme"},
+			nil,
+			"theme",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := projectType(tt.msg, tt.res); got != tt.want {
+				t.Errorf("projectType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package process
+
+import (
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/report"
+	"github.com/wptide/pkg/tide"
+)
+
+// reportFormats returns the formats the audit options request in addition to the raw report,
+// which is always uploaded in the JSON format of PHPCS.
+func reportFormats(options *message.AuditOption) ([]string, error) {
+	if options == nil {
+		return nil, nil
+	}
+
+	formats, err := report.Formats(options.Report)
+	if err != nil {
+		return nil, err
+	}
+
+	extra := []string{}
+	for _, format := range formats {
+		if format != "json" {
+			extra = append(extra, format)
+		}
+	}
+	return extra, nil
+}
+
+// emitReports uploads the report in each of the formats as "<checksum>-<kind>-<format>.<ext>",
+// and adds their details to the audit.
+func emitReports(phpcsReport *Report, formats []string) error {
+	for _, format := range formats {
+		emitter, ok := report.Lookup(format)
+		if !ok {
+			continue
+		}
+
+		data, err := emitter.Emit(*phpcsReport.Results)
+		if err != nil {
+			return err
+		}
+
+		details, err := phpcsReport.Upload(phpcsReport.Checksum+"-"+phpcsReport.Kind+"-"+format+"."+emitter.Extension(), data)
+		if err != nil {
+			return err
+		}
+
+		if phpcsReport.Audit.Reports == nil {
+			phpcsReport.Audit.Reports = make(map[string]tide.AuditDetails)
+		}
+		phpcsReport.Audit.Reports[format] = details
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package process
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+func TestReportFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *message.AuditOption
+		want    []string
+		wantErr bool
+	}{
+		{"No Options", nil, nil, false},
+		{"JSON", &message.AuditOption{Report: "json"}, []string{}, false},
+		{"Extra Formats", &message.AuditOption{Report: "json,checkstyle, junit,sarif"}, []string{"checkstyle", "junit", "sarif"}, false},
+		{"Unknown Format", &message.AuditOption{Report: "json,html"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := reportFormats(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("reportFormats() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("reportFormats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmitReports(t *testing.T) {
+	uploads := make(map[string][]byte)
+	upload := func(filename string, data []byte) (tide.AuditDetails, error) {
+		uploads[filename] = data
+		return tide.AuditDetails{FileName: filename}, nil
+	}
+
+	var results *tide.PhpcsResults
+	if err := json.Unmarshal([]byte(examplePhpcsWordPressReport()), &results); err != nil {
+		t.Fatal(err)
+	}
+
+	report := &Report{Kind: "phpcs_wordpress", Checksum: "checksum", Results: results, Audit: &tide.AuditResult{}, upload: upload}
+	if err := emitReports(report, []string{"checkstyle", "junit", "sarif"}); err != nil {
+		t.Fatalf("emitReports() error = %v", err)
+	}
+
+	want := map[string]tide.AuditDetails{
+		"checkstyle": {FileName: "checksum-phpcs_wordpress-checkstyle.xml"},
+		"junit":      {FileName: "checksum-phpcs_wordpress-junit.xml"},
+		"sarif":      {FileName: "checksum-phpcs_wordpress-sarif.json"},
+	}
+	if !reflect.DeepEqual(report.Audit.Reports, want) {
+		t.Errorf("emitReports() reports = %v, want %v", report.Audit.Reports, want)
+	}
+	if got := string(uploads["checksum-phpcs_wordpress-checkstyle.xml"]); !strings.Contains(got, "<checkstyle") {
+		t.Errorf("emitReports() uploaded %s", got)
+	}
+	if got := string(uploads["checksum-phpcs_wordpress-junit.xml"]); !strings.Contains(got, "<testsuites") {
+		t.Errorf("emitReports() uploaded %s", got)
+	}
+	if got := string(uploads["checksum-phpcs_wordpress-sarif.json"]); !strings.Contains(got, `"version":"2.1.0"`) {
+		t.Errorf("emitReports() uploaded %s", got)
+	}
+
+	// Uploads that fail fail the audit.
+	report = &Report{Kind: "phpcs_wordpress", Checksum: "checksum", Results: results, Audit: &tide.AuditResult{}}
+	if err := emitReports(report, []string{"junit"}); err == nil {
+		t.Error("emitReports() expected an error without storage")
+	}
+}
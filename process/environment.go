@@ -0,0 +1,83 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/wptide/pkg/env"
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/tide"
+)
+
+// ImageTagEnv is the environment variable that contains the tag of the worker image.
+const ImageTagEnv = "TIDE_IMAGE_TAG"
+
+var (
+	envRunner shell.Runner = defaultRunner
+
+	// The version in `phpcs --version`, e.g. "PHP_CodeSniffer version 3.5.0 (stable) by Squiz".
+	phpcsVersionRe = regexp.MustCompile(`version (\S+)`)
+	// The pretty name in /etc/os-release.
+	osNameRe = regexp.MustCompile(`(?m)^PRETTY_NAME="?([^"\n]+)"?`)
+
+	osReleaseFile = "/etc/os-release"
+)
+
+// EnvironmentOptions describes the parts of the environment that are not detected.
+type EnvironmentOptions struct {
+	PhpcsVersions map[string]map[string]string // (Optional) Installed component versions per standard, e.g. Phpcs.PhpcsVersions.
+	ImageTag      string                       // (Optional) Worker image tag. Defaults to the TIDE_IMAGE_TAG environment variable.
+}
+
+// ComputeEnvironment detects the runtime environment of the worker and returns it with its
+// fingerprint. Tools that are not installed are left empty. Workers compute the environment
+// once and pass it to the Ingest process to record it in every result.
+func ComputeEnvironment(opts EnvironmentOptions) *tide.Environment {
+	environment := &tide.Environment{
+		Standards: opts.PhpcsVersions,
+		Image:     opts.ImageTag,
+		OS:        runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	if environment.Image == "" {
+		environment.Image = env.GetEnv(ImageTagEnv, "")
+	}
+
+	if out, _, _, err := envRunner.Run("phpcs", "--version"); err == nil {
+		if m := phpcsVersionRe.FindSubmatch(out); m != nil {
+			environment.Phpcs = string(m[1])
+		}
+	}
+
+	if out, _, _, err := envRunner.Run("php", "-r", "echo PHP_VERSION;"); err == nil {
+		environment.Php = strings.TrimSpace(string(out))
+	}
+
+	if f, err := fileOpen(osReleaseFile); err == nil {
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		if m := osNameRe.FindSubmatch(data); m != nil {
+			environment.OS += " " + string(m[1])
+		}
+	}
+
+	environment.Fingerprint = Fingerprint(*environment)
+
+	return environment
+}
+
+// Fingerprint returns a hash of the environment that changes whenever any part of it changes.
+func Fingerprint(environment tide.Environment) string {
+	environment.Fingerprint = ""
+
+	// Maps are encoded in key order, so the encoding is stable.
+	data, _ := json.Marshal(environment)
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package process
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/tide"
+)
+
+type mockEnvRunner map[string]string
+
+func (m mockEnvRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	out, ok := m[name]
+	if !ok {
+		return nil, nil, 127, errors.New("executable file not found")
+	}
+	return []byte(out), nil, 0, nil
+}
+
+func TestComputeEnvironment(t *testing.T) {
+	defer func(runner shell.Runner, file string) {
+		envRunner = runner
+		osReleaseFile = file
+	}(envRunner, osReleaseFile)
+
+	standards := map[string]map[string]string{"wordpress": {"phpcs": "3.5.0", "wpcs": "2.3.0"}}
+
+	tests := []struct {
+		name      string
+		runner    mockEnvRunner
+		osRelease string
+		imageEnv  string
+		opts      EnvironmentOptions
+		want      tide.Environment
+	}{
+		{
+			"Full Environment",
+			mockEnvRunner{
+				"phpcs": "PHP_CodeSniffer version 3.5.0 (stable) by Squiz (http://www.squiz.net)\n",
+				"php":   "7.4.33",
+			},
+			"./testdata/environment/os-release",
+			"",
+			EnvironmentOptions{PhpcsVersions: standards, ImageTag: "worker:1.2.3"},
+			tide.Environment{
+				Phpcs:     "3.5.0",
+				Standards: standards,
+				Php:       "7.4.33",
+				Image:     "worker:1.2.3",
+				OS:        runtime.GOOS + "/" + runtime.GOARCH + " Alpine Linux v3.18",
+			},
+		},
+		{
+			"No Tools",
+			mockEnvRunner{},
+			"./testdata/environment/missing",
+			"worker:env",
+			EnvironmentOptions{},
+			tide.Environment{
+				Image: "worker:env",
+				OS:    runtime.GOOS + "/" + runtime.GOARCH,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envRunner = tt.runner
+			osReleaseFile = tt.osRelease
+			os.Setenv(ImageTagEnv, tt.imageEnv)
+			defer os.Unsetenv(ImageTagEnv)
+
+			got := ComputeEnvironment(tt.opts)
+
+			tt.want.Fingerprint = Fingerprint(tt.want)
+			if got.Phpcs != tt.want.Phpcs || got.Php != tt.want.Php || got.Image != tt.want.Image || got.OS != tt.want.OS {
+				t.Errorf("ComputeEnvironment() = %v, want %v", got, tt.want)
+			}
+			if got.Fingerprint != tt.want.Fingerprint {
+				t.Errorf("ComputeEnvironment() fingerprint = %v, want %v", got.Fingerprint, tt.want.Fingerprint)
+			}
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	environment := tide.Environment{
+		Phpcs:     "3.5.0",
+		Standards: map[string]map[string]string{"wordpress": {"phpcs": "3.5.0", "wpcs": "2.3.0"}},
+		Php:       "7.4.33",
+		OS:        "linux/amd64",
+	}
+
+	first := Fingerprint(environment)
+	if len(first) != 64 {
+		t.Errorf("Fingerprint() = %v, want a SHA256 hash", first)
+	}
+
+	// The existing fingerprint is not part of the hash.
+	environment.Fingerprint = first
+	if got := Fingerprint(environment); got != first {
+		t.Errorf("Fingerprint() = %v, want %v", got, first)
+	}
+
+	environment.Standards = map[string]map[string]string{"wordpress": {"phpcs": "3.5.0", "wpcs": "3.0.0"}}
+	if got := Fingerprint(environment); got == first {
+		t.Errorf("Fingerprint() did not change when a standard was upgraded")
+	}
+}
@@ -0,0 +1,286 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/tide"
+)
+
+// Severities of errors, which tell consumers how to handle the message of an error.
+const (
+	SeverityFatal     = "fatal"     // The message cannot be audited as it is, e.g. a broken archive. Alert someone.
+	SeverityRetryable = "retryable" // The failure is transient, e.g. storage was unavailable. Retry the message.
+	SeveritySkip      = "skip"      // The message was deliberately not audited, e.g. malware was found. Nothing to do.
+)
+
+// Error describes an error raised by a process while handling a specific message.
+type Error struct {
+	Process  string // Name of the process that raised the error (e.g. "Ingest").
+	Title    string // Title of the message the error belongs to.
+	Slug     string // Slug of the message the error belongs to.
+	Code     string // Failure code (e.g. tide.FailureStorage).
+	Severity string // How to handle the message (e.g. SeverityRetryable).
+	Err      error  // The underlying error.
+}
+
+// NewError returns a new Error for the given process and message.
+func NewError(process string, msg message.Message, err error) *Error {
+	return &Error{
+		Process:  process,
+		Title:    msg.Title,
+		Slug:     msg.Slug,
+		Code:     errorCode(err),
+		Severity: errorSeverity(err),
+		Err:      err,
+	}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	text := ""
+	if e.Err != nil {
+		text = e.Err.Error()
+	}
+	return e.Process + " Error: " + text
+}
+
+// Unwrap returns the underlying error, so that errors.Is and errors.As can inspect it.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Retryable determines if the message of the error should be retried.
+func (e *Error) Retryable() bool {
+	return e.Severity == SeverityRetryable
+}
+
+// Fatal determines if the message of the error cannot be audited without intervention.
+func (e *Error) Fatal() bool {
+	return e.Severity == SeverityFatal
+}
+
+// errorJSON is the stored representation of an Error.
+type errorJSON struct {
+	Process  string `json:"process"`
+	Title    string `json:"title,omitempty"`
+	Slug     string `json:"slug,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Error    string `json:"error"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	stored := errorJSON{
+		Process:  e.Process,
+		Title:    e.Title,
+		Slug:     e.Slug,
+		Code:     e.Code,
+		Severity: e.Severity,
+	}
+	if e.Err != nil {
+		stored.Error = e.Err.Error()
+	}
+	return json.Marshal(stored)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (e *Error) UnmarshalJSON(data []byte) error {
+	var stored errorJSON
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return err
+	}
+
+	e.Process = stored.Process
+	e.Title = stored.Title
+	e.Slug = stored.Slug
+	e.Code = stored.Code
+	e.Severity = stored.Severity
+	e.Err = errors.New(stored.Error)
+
+	return nil
+}
+
+// Failure returns the machine-readable failure for the error.
+func (e *Error) Failure() tide.Failure {
+	failure := tide.Failure{
+		Code:    e.Code,
+		Process: e.Process,
+	}
+	if failure.Code == "" {
+		failure.Code = tide.FailureUnknown
+	}
+	if e.Err != nil {
+		failure.Message = e.Err.Error()
+	}
+	return failure
+}
+
+// codedError is an error with a failure code.
+type codedError struct {
+	code string
+	err  error
+}
+
+// Error implements the error interface.
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withCode attaches a failure code (e.g. tide.FailureStorage) to an error.
+func withCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code, err}
+}
+
+// errorCode returns the failure code of an error, or of the first error it wraps that has one.
+func errorCode(err error) string {
+	var coded *codedError
+	var download *source.DownloadError
+	var archive *source.ArchiveError
+	var rejected *source.RejectedError
+	var malware *source.MalwareError
+	var scan *source.ScanError
+
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.As(err, &download):
+		return tide.FailureSourceUnreachable
+	case errors.As(err, &archive):
+		return tide.FailureArchiveInvalid
+	case errors.As(err, &rejected):
+		return tide.FailureArchiveRejected
+	case errors.As(err, &malware):
+		return tide.FailureMalwareDetected
+	case errors.As(err, &scan):
+		return tide.FailureScanFailed
+	}
+	return tide.FailureUnknown
+}
+
+// codeSeverities are the severities of the failure codes. Other codes are fatal.
+var codeSeverities = map[string]string{
+	tide.FailureSourceUnreachable: SeverityRetryable,
+	tide.FailureArchiveInvalid:    SeverityFatal,
+	tide.FailureArchiveRejected:   SeverityFatal,
+	tide.FailurePhpcsTimeout:      SeverityRetryable,
+	tide.FailureStorage:           SeverityRetryable,
+	tide.FailureStandardMissing:   SeverityFatal,
+	tide.FailureMalwareDetected:   SeveritySkip,
+	tide.FailureScanFailed:        SeverityRetryable,
+	tide.FailureRulesetInvalid:    SeverityFatal,
+}
+
+// errorSeverity returns the severity of an error. Errors of a cancelled pipeline are retryable,
+// the message can be processed once the pipeline runs again.
+func errorSeverity(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return SeverityRetryable
+	}
+	if severity, ok := codeSeverities[errorCode(err)]; ok {
+		return severity
+	}
+	return SeverityFatal
+}
+
+// ErrorSink receives the errors raised by the processes in a pipeline.
+type ErrorSink interface {
+	Report(err *Error)
+}
+
+// ErrorChannel is an ErrorSink that sends errors to a channel.
+type ErrorChannel chan *Error
+
+// errorSendTimeout is how long ErrorChannel.Report waits for an error to be received.
+var errorSendTimeout = time.Second
+
+// droppedErrors is the number of errors that ErrorChannels dropped.
+var droppedErrors uint64
+
+// Report sends the error to the channel. Errors that are not received in time, e.g. because
+// nobody drains the channel anymore, are logged and dropped, so that the stage is not blocked
+// forever. See DroppedErrors.
+func (c ErrorChannel) Report(err *Error) {
+	select {
+	case c <- err:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(errorSendTimeout)
+	defer timer.Stop()
+
+	select {
+	case c <- err:
+	case <-timer.C:
+		atomic.AddUint64(&droppedErrors, 1)
+		log.Log(err.Title, "Dropped undelivered error: "+err.Error())
+	}
+}
+
+// DroppedErrors returns the number of errors that ErrorChannels dropped because nobody
+// received them.
+func DroppedErrors() uint64 {
+	return atomic.LoadUint64(&droppedErrors)
+}
+
+// ErrorSinkFunc is an ErrorSink that calls a function, e.g. to requeue a message.
+type ErrorSinkFunc func(err *Error)
+
+// Report calls the function with the error.
+func (f ErrorSinkFunc) Report(err *Error) {
+	f(err)
+}
+
+// SeverityRouter is an ErrorSink that reports errors to the sink of their severity, e.g. retryable
+// errors to a sink that sends the message back to the queue and fatal errors to alerting.
+type SeverityRouter struct {
+	Fatal     ErrorSink // (Optional) Receives fatal errors. Defaults to Default.
+	Retryable ErrorSink // (Optional) Receives retryable errors. Defaults to Default.
+	Skip      ErrorSink // (Optional) Receives errors of skipped messages. Defaults to Default.
+	Default   ErrorSink // (Optional) Receives errors without a sink for their severity. They are dropped if nil.
+}
+
+// Report sends the error to the sink of its severity.
+func (r SeverityRouter) Report(err *Error) {
+	var sink ErrorSink
+	switch err.Severity {
+	case SeverityFatal:
+		sink = r.Fatal
+	case SeverityRetryable:
+		sink = r.Retryable
+	case SeveritySkip:
+		sink = r.Skip
+	}
+	if sink == nil {
+		sink = r.Default
+	}
+	if sink != nil {
+		sink.Report(err)
+	}
+}
+
+// reportError attaches the error to the result (if any) and reports it to the sink (if any).
+func reportError(sink ErrorSink, res *Result, err *Error) {
+	if res != nil {
+		res.AddError(err)
+	}
+	if sink != nil {
+		sink.Report(err)
+	}
+}
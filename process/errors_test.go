@@ -0,0 +1,299 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/tide"
+)
+
+func TestError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *Error
+		want string
+	}{
+		{
+			"With Cause",
+			NewError("Ingest", message.Message{Title: "Test"}, errors.New("something went wrong")),
+			"Ingest Error: something went wrong",
+		},
+		{
+			"Without Cause",
+			&Error{Process: "Info"},
+			"Info Error: ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.want {
+				t.Errorf("Error.Error() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_JSON(t *testing.T) {
+	err := NewError("PHPCS", message.Message{Title: "Test", Slug: "test"}, errors.New("something went wrong"))
+
+	b, e := json.Marshal(err)
+	if e != nil {
+		t.Fatalf("json.Marshal() error = %v", e)
+	}
+
+	want := `{"process":"PHPCS","title":"Test","slug":"test","code":"UNKNOWN","severity":"fatal","error":"something went wrong"}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+
+	var got *Error
+	if e := json.Unmarshal(b, &got); e != nil {
+		t.Fatalf("json.Unmarshal() error = %v", e)
+	}
+
+	if !reflect.DeepEqual(got, err) {
+		t.Errorf("json.Unmarshal() = %v, want %v", got, err)
+	}
+}
+
+func TestError_Failure(t *testing.T) {
+	msg := message.Message{Title: "Test"}
+
+	tests := []struct {
+		name string
+		err  *Error
+		want tide.Failure
+	}{
+		{
+			"Coded",
+			NewError("PHPCS", msg, withCode(tide.FailureStorage, errors.New("upload error"))),
+			tide.Failure{Code: tide.FailureStorage, Process: "PHPCS", Message: "upload error"},
+		},
+		{
+			"Download",
+			NewError("Ingest", msg, &source.DownloadError{Err: errors.New("no such host")}),
+			tide.Failure{Code: tide.FailureSourceUnreachable, Process: "Ingest", Message: "could not download source: no such host"},
+		},
+		{
+			"Archive",
+			NewError("Ingest", msg, &source.ArchiveError{Err: errors.New("not a valid zip file")}),
+			tide.Failure{Code: tide.FailureArchiveInvalid, Process: "Ingest", Message: "could not extract source: not a valid zip file"},
+		},
+		{
+			"Rejected",
+			NewError("Ingest", msg, &source.RejectedError{Reason: source.RejectSize, Detail: "too large"}),
+			tide.Failure{Code: tide.FailureArchiveRejected, Process: "Ingest", Message: "source rejected (size): too large"},
+		},
+		{
+			"Malware",
+			NewError("Ingest", msg, &source.MalwareError{Threat: "Win.Test.EICAR_HDB-1"}),
+			tide.Failure{Code: tide.FailureMalwareDetected, Process: "Ingest", Message: "malware detected in source: Win.Test.EICAR_HDB-1"},
+		},
+		{
+			"Scan",
+			NewError("Ingest", msg, &source.ScanError{Err: errors.New("connection refused")}),
+			tide.Failure{Code: tide.FailureScanFailed, Process: "Ingest", Message: "could not scan source: connection refused"},
+		},
+		{
+			"Uncoded",
+			NewError("Info", msg, errors.New("something went wrong")),
+			tide.Failure{Code: tide.FailureUnknown, Process: "Info", Message: "something went wrong"},
+		},
+		{
+			"Without Code",
+			&Error{Process: "Info"},
+			tide.Failure{Code: tide.FailureUnknown, Process: "Info"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Failure(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Error.Failure() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_withCode(t *testing.T) {
+	if withCode(tide.FailureStorage, nil) != nil {
+		t.Errorf("withCode() expected nil for a nil error")
+	}
+
+	err := withCode(tide.FailureStorage, errors.New("upload error"))
+	if err.Error() != "upload error" || errorCode(err) != tide.FailureStorage {
+		t.Errorf("withCode() = %v with code %v", err, errorCode(err))
+	}
+}
+
+func Test_errorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Coded", withCode(tide.FailureStorage, errors.New("upload error")), tide.FailureStorage},
+		{"Source Error", &source.DownloadError{Err: errors.New("timeout")}, tide.FailureSourceUnreachable},
+		{"Wrapped Coded", fmt.Errorf("phpcs: %w", withCode(tide.FailurePhpcsTimeout, errors.New("timeout"))), tide.FailurePhpcsTimeout},
+		{"Wrapped Source Error", fmt.Errorf("ingest: %w", &source.ScanError{Err: errors.New("clamd")}), tide.FailureScanFailed},
+		{"Outer Code", withCode(tide.FailureStorage, &source.DownloadError{Err: errors.New("timeout")}), tide.FailureStorage},
+		{"Unknown", errors.New("other"), tide.FailureUnknown},
+		{"Nil", nil, tide.FailureUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorCode(tt.err); got != tt.want {
+				t.Errorf("errorCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_errorSeverity(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Storage", withCode(tide.FailureStorage, errors.New("upload error")), SeverityRetryable},
+		{"Timeout", withCode(tide.FailurePhpcsTimeout, errors.New("timed out")), SeverityRetryable},
+		{"Download", &source.DownloadError{Err: errors.New("404")}, SeverityRetryable},
+		{"Missing Standard", withCode(tide.FailureStandardMissing, errors.New("missing")), SeverityFatal},
+		{"Malware", &source.MalwareError{Threat: "Win.Test.EICAR_HDB-1"}, SeveritySkip},
+		{"Cancelled", context.Canceled, SeverityRetryable},
+		{"Unknown", errors.New("something went wrong"), SeverityFatal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorSeverity(tt.err); got != tt.want {
+				t.Errorf("errorSeverity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_Severity(t *testing.T) {
+	cause := withCode(tide.FailureStorage, context.Canceled)
+	err := NewError("PHPCS", message.Message{Slug: "test"}, cause)
+
+	if !err.Retryable() || err.Fatal() {
+		t.Errorf("Error severity = %v, want %v", err.Severity, SeverityRetryable)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("errors.Is() could not find the cause of %v", err)
+	}
+	if (&Error{Severity: SeverityFatal}).Retryable() || !(&Error{Severity: SeverityFatal}).Fatal() {
+		t.Errorf("Error.Fatal() expected a fatal error")
+	}
+}
+
+func TestSeverityRouter_Report(t *testing.T) {
+	var fatal, retryable, fallback []string
+	router := SeverityRouter{
+		Fatal:     ErrorSinkFunc(func(err *Error) { fatal = append(fatal, err.Slug) }),
+		Retryable: ErrorSinkFunc(func(err *Error) { retryable = append(retryable, err.Slug) }),
+		Default:   ErrorSinkFunc(func(err *Error) { fallback = append(fallback, err.Slug) }),
+	}
+
+	router.Report(&Error{Slug: "broken", Severity: SeverityFatal})
+	router.Report(&Error{Slug: "offline", Severity: SeverityRetryable})
+	router.Report(&Error{Slug: "malware", Severity: SeveritySkip})
+	router.Report(&Error{Slug: "stored"})
+
+	if !reflect.DeepEqual(fatal, []string{"broken"}) {
+		t.Errorf("SeverityRouter fatal = %v", fatal)
+	}
+	if !reflect.DeepEqual(retryable, []string{"offline"}) {
+		t.Errorf("SeverityRouter retryable = %v", retryable)
+	}
+	if !reflect.DeepEqual(fallback, []string{"malware", "stored"}) {
+		t.Errorf("SeverityRouter default = %v", fallback)
+	}
+
+	// Errors without a sink are dropped.
+	SeverityRouter{}.Report(&Error{Severity: SeverityFatal})
+}
+
+func TestErrorChannel_Report(t *testing.T) {
+	defer func(timeout time.Duration) { errorSendTimeout = timeout }(errorSendTimeout)
+	errorSendTimeout = 10 * time.Millisecond
+
+	err := &Error{Process: "Info", Title: "Test", Err: errors.New("something went wrong")}
+
+	// Errors are sent to receivers that are ready.
+	c := make(ErrorChannel)
+	received := make(chan *Error)
+	go func() { received <- <-c }()
+	c.Report(err)
+	if got := <-received; got != err {
+		t.Errorf("ErrorChannel.Report() sent %v, want %v", got, err)
+	}
+
+	// Errors that nobody receives are dropped instead of blocking the stage.
+	dropped := DroppedErrors()
+	done := make(chan struct{})
+	go func() {
+		make(ErrorChannel).Report(err)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ErrorChannel.Report() blocked without a receiver")
+	}
+	if got := DroppedErrors(); got != dropped+1 {
+		t.Errorf("DroppedErrors() = %v, want %v", got, dropped+1)
+	}
+}
+
+func Test_reportError(t *testing.T) {
+	err := NewError("Info", message.Message{Title: "Test"}, errors.New("something went wrong"))
+
+	tests := []struct {
+		name string
+		sink ErrorChannel
+		res  *Result
+	}{
+		{
+			"Result and Sink",
+			make(ErrorChannel, 1),
+			NewResult(),
+		},
+		{
+			"Sink Only",
+			make(ErrorChannel, 1),
+			nil,
+		},
+		{
+			"Result Only",
+			nil,
+			NewResult(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sink ErrorSink
+			if tt.sink != nil {
+				sink = tt.sink
+			}
+
+			reportError(sink, tt.res, err)
+
+			if tt.res != nil && !reflect.DeepEqual(tt.res.Errors, []*Error{err}) {
+				t.Errorf("reportError() result errors = %v, want %v", tt.res.Errors, []*Error{err})
+			}
+
+			if tt.sink != nil {
+				if got := <-tt.sink; got != err {
+					t.Errorf("reportError() sink = %v, want %v", got, err)
+				}
+			}
+		})
+	}
+}
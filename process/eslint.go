@@ -0,0 +1,254 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/process/phpcs"
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+var (
+	eslintRunner shell.Runner = defaultRunner
+)
+
+// eslintFile is a file in the JSON report of ESLint.
+type eslintFile struct {
+	FilePath string `json:"filePath"`
+	Messages []struct {
+		RuleID   string           `json:"ruleId"`
+		Severity int              `json:"severity"` // 1 for warnings, 2 for errors.
+		Message  string           `json:"message"`
+		Line     int              `json:"line"`
+		Column   int              `json:"column"`
+		Fix      *json.RawMessage `json:"fix"`
+	} `json:"messages"`
+}
+
+// Eslint defines the structure for our ESLint process, which audits the JS assets.
+type Eslint struct {
+	Process                          // Inherits methods from Process.
+	In              <-chan Processor // Expects a processor channel as input.
+	Out             chan Processor   // Send results to an output channel.
+	TempFolder      string           // Path to a temp folder where reports will be generated.
+	StorageProvider storage.Provider // Storage provider to upload reports to.
+	Config          string           // (Optional) Path of the ESLint config, e.g. one with the WordPress rules. Defaults to the config of the project.
+	MaxReportSize   int64            // (Optional) Reports larger than this many bytes are uploaded in chunks.
+	Runner          shell.Runner     // (Optional) Runs ESLint. Defaults to shell.Command.
+	Command         string           // (Optional) Defaults to "eslint".
+}
+
+// Run executes the process in a pipe.
+func (es *Eslint) Run(sink ErrorSink) error {
+	if es.TempFolder == "" {
+		return errors.New("no temp folder provided for eslint reports")
+	}
+
+	if es.StorageProvider == nil {
+		return errors.New("no storage provider for eslint reports")
+	}
+
+	if es.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if es.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	es.runStage(sink, stage{name: "ESLint", in: es.In, out: es.Out, do: es.Do})
+
+	return nil
+}
+
+// Do runs ESLint over the JS files if the message requests an "eslint" audit and returns the
+// result with the audit added.
+func (es *Eslint) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if !hasAudit(msg, "eslint") {
+		return res, nil
+	}
+
+	runner := eslintRunner
+	if es.Runner != nil {
+		runner = es.Runner
+	}
+
+	command := es.Command
+	if command == "" {
+		command = "eslint"
+	}
+
+	lint := assetLint{
+		kind:            "eslint",
+		extensions:      []string{".js", ".jsx", ".mjs"},
+		runner:          runner,
+		tempFolder:      es.TempFolder,
+		storageProvider: es.StorageProvider,
+		maxReportSize:   es.MaxReportSize,
+		filter:          newPathFilter(auditOptions(msg, "eslint")),
+		parse:           parseEslint,
+	}
+
+	return res, lint.run(ctx, msg, res, func(path string) (string, []string) {
+		args := []string{"--format", "json", "--ext", ".js,.jsx,.mjs", "--ignore-pattern", "**/*.min.js", "--no-error-on-unmatched-pattern"}
+		if es.Config != "" {
+			args = append(args, "--no-eslintrc", "--config", es.Config)
+		}
+		return command, append(args, path)
+	})
+}
+
+// parseEslint converts a JSON report of ESLint into the report format of Tide.
+func parseEslint(root string, output []byte) (*tide.PhpcsResults, error) {
+	var files []eslintFile
+	if err := json.Unmarshal(output, &files); err != nil {
+		return nil, err
+	}
+
+	results := &tide.PhpcsResults{}
+	for _, file := range files {
+		name := relativeName(root, file.FilePath)
+		for _, m := range file.Messages {
+			kind := "WARNING"
+			if m.Severity == 2 {
+				kind = "ERROR"
+			}
+			addReportMessage(results, name, tide.PhpcsFilesMessage{
+				Message:  m.Message,
+				Source:   m.RuleID,
+				Severity: 5,
+				Type:     kind,
+				Line:     m.Line,
+				Column:   m.Column,
+				Fixable:  m.Fix != nil,
+			})
+		}
+	}
+	return results, nil
+}
+
+// assetLint runs a linter for JS or CSS assets and records its report as an audit.
+type assetLint struct {
+	kind            string   // Audit kind, e.g. "eslint".
+	extensions      []string // Extensions of the linted files.
+	runner          shell.Runner
+	tempFolder      string
+	storageProvider storage.Provider
+	maxReportSize   int64
+	filter          pathFilter // Files that are linted, other files are removed from the report.
+	parse           func(root string, output []byte) (*tide.PhpcsResults, error)
+}
+
+// run lints the files of the result with the command returned by cmd for the files path, and
+// records the normalized report as the audit of the kind. Projects without files with the
+// extensions are not linted.
+func (l assetLint) run(ctx context.Context, msg message.Message, res *Result, cmd func(path string) (string, []string)) error {
+	if res == nil || res.FilesPath == "" {
+		return errors.New("could not determine files path")
+	}
+
+	if res.Checksum == "" {
+		return errors.New("could not determine checksum")
+	}
+
+	if skipped(res, l.kind) || !l.hasFiles(res) {
+		return nil
+	}
+
+	log.Log(msg.Title, "Running "+l.kind+" Audit...")
+
+	root := res.FilesPath + "/unzipped"
+	name, args := cmd(root)
+
+	done := res.timeStage(l.kind)
+	out, errOut, exitCode, err := runCommand(ctx, res, l.runner, name, args...)
+	done()
+
+	// Linters exit with an error if they found errors, the report is what matters.
+	report := out
+	if len(strings.TrimSpace(string(report))) == 0 {
+		// Some versions write the report to stderr.
+		report = errOut
+	}
+	results, parseErr := l.parse(root, report)
+	if parseErr != nil {
+		if err != nil {
+			return messageError(msg, l.kind+" command failed: "+strings.TrimSpace(string(errOut)+" "+err.Error()))
+		}
+		return parseErr
+	}
+	l.filter.filterReport(results, root)
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	filename := res.Checksum + "-" + l.kind + "-raw.json"
+	path := strings.TrimRight(l.tempFolder, "/") + "/" + filename
+	if err := writeFile(path, data, 0644); err != nil {
+		return errors.New("could not write " + l.kind + " audit to tempFolder")
+	}
+
+	done = res.timeStage("upload")
+	raw, err := uploadReport(reportContext(ctx, msg, l.kind), meterStorage(l.storageProvider, res), path, filename, l.maxReportSize, false)
+	done()
+	if err != nil {
+		return err
+	}
+
+	res.SetAudit(l.kind, tide.AuditResult{
+		Raw:     raw,
+		Summary: tide.AuditSummary{PhpcsSummary: phpcs.GetPhpcsSummary(*results)},
+	})
+
+	log.Log(msg.Title, fmt.Sprintf("%s process completed with exit code: %d", l.kind, exitCode))
+
+	return nil
+}
+
+// hasFiles determines if the result has files with the extensions of the linter that are audited.
+func (l assetLint) hasFiles(res *Result) bool {
+	for _, file := range res.Files {
+		if !l.filter.audits(file) {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(file))
+		for _, e := range l.extensions {
+			if ext == e {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addReportMessage adds a message of the file to the report and updates the totals.
+func addReportMessage(results *tide.PhpcsResults, file string, msg tide.PhpcsFilesMessage) {
+	if results.Files == nil {
+		results.Files = make(map[string]struct {
+			Errors   int                      `json:"errors, omitempty"`
+			Warnings int                      `json:"warnings,omitempty"`
+			Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+		})
+	}
+
+	f := results.Files[file]
+	switch msg.Type {
+	case "ERROR":
+		f.Errors++
+		results.Totals.Errors++
+	case "WARNING":
+		f.Warnings++
+		results.Totals.Warnings++
+	}
+	f.Messages = append(f.Messages, msg)
+	results.Files[file] = f
+}
@@ -0,0 +1,212 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// outputRunner returns the same output for every command and records the arguments.
+type outputRunner struct {
+	out    string
+	errOut string
+	err    error
+	args   []string
+}
+
+func (r *outputRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	r.args = append([]string{name}, arg...)
+	if r.err != nil {
+		return []byte(r.out), []byte(r.errOut), 1, r.err
+	}
+	return []byte(r.out), []byte(r.errOut), 0, nil
+}
+
+const eslintReport = `[
+	{"filePath":"/tmp/abc/unzipped/js/app.js","messages":[
+		{"ruleId":"no-unused-vars","severity":2,"message":"'a' is defined but never used.","line":1,"column":5},
+		{"ruleId":"semi","severity":1,"message":"Missing semicolon.","line":3,"column":10,"fix":{"range":[20,20],"text":";"}}
+	],"errorCount":1,"warningCount":1},
+	{"filePath":"/tmp/abc/unzipped/js/clean.js","messages":[],"errorCount":0,"warningCount":0}
+]`
+
+func TestEslint_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		es      *Eslint
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Eslint{
+				In:              make(chan Processor),
+				Out:             make(chan Processor),
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &recordingStorage{},
+			},
+			false,
+		},
+		{
+			"No Temp Folder",
+			&Eslint{
+				In:              make(chan Processor),
+				Out:             make(chan Processor),
+				StorageProvider: &recordingStorage{},
+			},
+			true,
+		},
+		{
+			"No Storage Provider",
+			&Eslint{
+				In:         make(chan Processor),
+				Out:        make(chan Processor),
+				TempFolder: "./testdata/tmp",
+			},
+			true,
+		},
+		{
+			"No In Channel",
+			&Eslint{
+				Out:             make(chan Processor),
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &recordingStorage{},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.es.SetContext(ctx)
+
+			if err := tt.es.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Eslint.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEslint_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "eslint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	eslintMsg := message.Message{Title: "Test", Audits: []*message.Audit{{Type: "eslint"}}}
+	files := []string{"/tmp/abc/unzipped/plugin.php", "/tmp/abc/unzipped/js/app.js"}
+
+	tests := []struct {
+		name     string
+		runner   *outputRunner
+		msg      message.Message
+		files    []string
+		want     *tide.PhpcsSummary
+		wantRefs []string
+		wantErr  bool
+	}{
+		{
+			"Not Requested",
+			&outputRunner{},
+			message.Message{Audits: []*message.Audit{{Type: "security"}}},
+			files,
+			nil,
+			nil,
+			false,
+		},
+		{
+			"No JS Files",
+			&outputRunner{},
+			eslintMsg,
+			files[:1],
+			nil,
+			nil,
+			false,
+		},
+		{
+			"Lint Errors",
+			// ESLint exits with 1 if it found errors.
+			&outputRunner{out: eslintReport, err: errors.New("exit status 1")},
+			eslintMsg,
+			files,
+			&tide.PhpcsSummary{FilesCount: 1, ErrorsCount: 1, WarningsCount: 1},
+			[]string{"abc-eslint-raw.json"},
+			false,
+		},
+		{
+			"Command Failed",
+			&outputRunner{errOut: "eslint: command not found", err: errors.New("exit status 127")},
+			eslintMsg,
+			files,
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &recordingStorage{}
+			es := &Eslint{TempFolder: dir, StorageProvider: storage, Runner: tt.runner}
+			res := &Result{Checksum: "abc", FilesPath: "/tmp/abc", Files: tt.files}
+
+			res, err := es.Do(context.Background(), tt.msg, res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Eslint.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(storage.refs, tt.wantRefs) {
+				t.Errorf("Eslint.Do() uploaded %v, want %v", storage.refs, tt.wantRefs)
+			}
+
+			audit, ok := res.Audit("eslint")
+			if tt.want == nil {
+				if ok {
+					t.Errorf("Eslint.Do() unexpected audit = %v", audit)
+				}
+				return
+			}
+
+			got := audit.Summary.PhpcsSummary
+			if got == nil || got.FilesCount != tt.want.FilesCount || got.ErrorsCount != tt.want.ErrorsCount || got.WarningsCount != tt.want.WarningsCount {
+				t.Errorf("Eslint.Do() summary = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseEslint(t *testing.T) {
+	got, err := parseEslint("/tmp/abc/unzipped", []byte(eslintReport))
+	if err != nil {
+		t.Fatalf("parseEslint() error = %v", err)
+	}
+
+	want := []tide.PhpcsFilesMessage{
+		{Message: "'a' is defined but never used.", Source: "no-unused-vars", Severity: 5, Type: "ERROR", Line: 1, Column: 5},
+		{Message: "Missing semicolon.", Source: "semi", Severity: 5, Type: "WARNING", Line: 3, Column: 10, Fixable: true},
+	}
+	file := got.Files["js/app.js"]
+	if !reflect.DeepEqual(file.Messages, want) || file.Errors != 1 || file.Warnings != 1 {
+		t.Errorf("parseEslint() js/app.js = %+v, want %v", file, want)
+	}
+	if got.Totals.Errors != 1 || got.Totals.Warnings != 1 || len(got.Files) != 1 {
+		t.Errorf("parseEslint() totals = %+v, files = %v", got.Totals, len(got.Files))
+	}
+
+	if _, err := parseEslint("/tmp/abc/unzipped", []byte("Oops! Something went wrong!")); err == nil {
+		t.Error("parseEslint() expected an error for output that is not a report")
+	}
+}
@@ -0,0 +1,168 @@
+package process
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+// incrementalReport is the PHPCS report of the last audit of a project, kept so that the next
+// audit of the project only has to audit the files that changed.
+type incrementalReport struct {
+	Key       string             `json:"key"`       // Options, versions and standard of the audit, see incrementalKey.
+	Checksums map[string]string  `json:"checksums"` // Checksums of the audited files, by path relative to the source.
+	Report    *tide.PhpcsResults `json:"report"`
+}
+
+// incremental reuses the file reports of the last audit of the same project for the files that
+// have not changed since. Reports are kept in storage by project slug and audit kind.
+type incremental struct {
+	provider  storage.Provider
+	reference string
+	tempPath  string
+	key       string
+	checksums map[string]string
+	previous  *incrementalReport
+	unchanged []string // Files of the previous report that have not changed.
+}
+
+// incrementalKey identifies the audits that produce the same report for the same file.
+func incrementalKey(options *message.AuditOption, versions map[string]string, standard string) string {
+	data, _ := json.Marshal(struct {
+		Options  *message.AuditOption `json:"options"`
+		Versions map[string]string    `json:"versions"`
+		Standard string               `json:"standard"`
+	}{options, versions, standard})
+
+	// Custom rulesets can change while their URL or storage key stays the same.
+	if isRuleset(options.StandardOverride) {
+		if ruleset, err := ioutil.ReadFile(standard); err == nil {
+			data = append(data, ruleset...)
+		}
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// incremental loads the last report of the project for an audit of the kind with the key. Reports
+// that can't be downloaded, or that were produced with other options, are not reused.
+func (cs Phpcs) incremental(ctx context.Context, msg message.Message, res *Result, kind, key string) *incremental {
+	prefix := cs.IncrementalPrefix
+	if prefix == "" {
+		prefix = "incremental/"
+	}
+
+	inc := &incremental{
+		provider:  storage.WithContext(ctx, meterStorage(cs.StorageProvider, res)),
+		reference: prefix + msg.Slug + "-" + kind + ".json",
+		tempPath:  strings.TrimRight(cs.TempFolder, "/") + "/" + res.Checksum + "-" + kind + "-incremental.json",
+		key:       key,
+		checksums: res.FileChecksums,
+	}
+
+	defer os.Remove(inc.tempPath)
+	if err := inc.provider.DownloadFile(inc.reference, inc.tempPath); err != nil {
+		return inc
+	}
+
+	data, err := ioutil.ReadFile(inc.tempPath)
+	if err != nil {
+		return inc
+	}
+
+	var previous incrementalReport
+	if err := json.Unmarshal(data, &previous); err != nil || previous.Key != key || previous.Report == nil {
+		return inc
+	}
+	inc.previous = &previous
+
+	for file := range previous.Report.Files {
+		if sum, ok := res.FileChecksums[file]; ok && sum == previous.Checksums[file] {
+			inc.unchanged = append(inc.unchanged, file)
+		}
+	}
+	sort.Strings(inc.unchanged)
+
+	return inc
+}
+
+// ignored returns the paths of the unchanged files, for PHPCS to ignore them.
+func (inc *incremental) ignored(root string) []string {
+	ignored := []string{}
+	for _, file := range inc.unchanged {
+		ignored = append(ignored, root+"/"+file)
+	}
+	return ignored
+}
+
+// merge adds the reports of the unchanged files to the PHPCS report at the path, and stores the
+// merged report for the next audit of the project.
+func (inc *incremental) merge(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var report *tide.PhpcsResults
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	if report == nil {
+		report = &tide.PhpcsResults{}
+	}
+
+	if len(inc.unchanged) != 0 {
+		if report.Files == nil {
+			report.Files = make(map[string]struct {
+				Errors   int                      `json:"errors, omitempty"`
+				Warnings int                      `json:"warnings,omitempty"`
+				Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+			})
+		}
+		for _, file := range inc.unchanged {
+			report.Files[file] = inc.previous.Report.Files[file]
+		}
+		countMessages(report)
+
+		if data, err = json.Marshal(report); err != nil {
+			return err
+		}
+		if err := writeFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+
+	return inc.save(report)
+}
+
+// save uploads the report with the checksums of its files.
+func (inc *incremental) save(report *tide.PhpcsResults) error {
+	checksums := make(map[string]string)
+	for file := range report.Files {
+		if sum, ok := inc.checksums[file]; ok {
+			checksums[file] = sum
+		}
+	}
+
+	data, err := json.Marshal(incrementalReport{Key: inc.key, Checksums: checksums, Report: report})
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(inc.tempPath, data, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(inc.tempPath)
+
+	return inc.provider.UploadFile(inc.tempPath, inc.reference)
+}
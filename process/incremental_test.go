@@ -0,0 +1,314 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/local"
+	"github.com/wptide/pkg/tide"
+)
+
+// incrementalRunner writes a PHPCS report for the files that are not ignored, with the
+// messages of each file.
+type incrementalRunner struct {
+	files   map[string][]tide.PhpcsFilesMessage
+	audited []string
+}
+
+func (r *incrementalRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	var fileList, report string
+	for _, a := range arg {
+		switch {
+		case strings.HasPrefix(a, "--file-list="):
+			fileList = strings.TrimPrefix(a, "--file-list=")
+		case strings.HasPrefix(a, "--report-json="):
+			report = strings.TrimPrefix(a, "--report-json=")
+		}
+	}
+
+	// Every file is audited without a file list.
+	listed := ""
+	if fileList != "" {
+		data, err := ioutil.ReadFile(fileList)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		listed = string(data)
+	}
+
+	results := &tide.PhpcsResults{}
+	r.audited = nil
+	for file, messages := range r.files {
+		if fileList != "" && !strings.Contains(listed, "/unzipped/"+file+"\n") {
+			continue
+		}
+		r.audited = append(r.audited, file)
+		addReportMessage(results, file, tide.PhpcsFilesMessage{})
+		f := results.Files[file]
+		f.Messages = messages
+		results.Files[file] = f
+	}
+	countMessages(results)
+
+	data, _ := json.Marshal(results)
+	return nil, nil, 0, ioutil.WriteFile(report, data, 0644)
+}
+
+func TestPhpcs_Do_Incremental(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(dir+"/storage/incremental", 0755)
+
+	errorMessage := tide.PhpcsFilesMessage{Type: "ERROR", Source: "WordPress.Security.EscapeOutput.OutputNotEscaped"}
+	warningMessage := tide.PhpcsFilesMessage{Type: "WARNING", Source: "WordPress.DB.PreparedSQL.NotPrepared"}
+
+	runner := &incrementalRunner{}
+	cs := &Phpcs{
+		TempFolder:      dir,
+		StorageProvider: local.NewLocalStorage(dir+"/storage", ""),
+		PhpcsVersions:   map[string]map[string]string{"wordpress": {"phpcs": "3.1.1"}},
+		Runner:          runner,
+		Transformers:    []ReportTransformer{SummaryTransformer{}},
+		Incremental:     true,
+	}
+
+	tests := []struct {
+		name         string
+		standard     string
+		checksums    map[string]string
+		files        map[string][]tide.PhpcsFilesMessage
+		wantAudited  int
+		wantReused   interface{}
+		wantErrors   int
+		wantWarnings int
+	}{
+		{
+			"First Audit",
+			"wordpress",
+			map[string]string{"a.php": "1", "b.php": "2"},
+			map[string][]tide.PhpcsFilesMessage{"a.php": {errorMessage}, "b.php": {warningMessage}},
+			2, nil, 1, 1,
+		},
+		{
+			"Changed File",
+			"wordpress",
+			map[string]string{"a.php": "1", "b.php": "3"},
+			// The error of a.php would only be reported if it was audited again.
+			map[string][]tide.PhpcsFilesMessage{"a.php": {}, "b.php": {}},
+			1, 1, 1, 0,
+		},
+		{
+			"Unchanged",
+			"wordpress",
+			map[string]string{"a.php": "1", "b.php": "3"},
+			map[string][]tide.PhpcsFilesMessage{"a.php": {}, "b.php": {warningMessage}},
+			0, 2, 1, 0,
+		},
+		{
+			"Other Options",
+			"WordPress-Core",
+			map[string]string{"a.php": "1", "b.php": "3"},
+			map[string][]tide.PhpcsFilesMessage{"a.php": {}, "b.php": {warningMessage}},
+			2, nil, 0, 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs.PhpcsVersions[tt.standard] = map[string]string{"phpcs": "3.1.1"}
+			runner.files = tt.files
+
+			res := NewResult()
+			res.Checksum = "checksum"
+			res.FilesPath = dir + "/audit"
+			res.FileChecksums = tt.checksums
+			res.Files = nil
+			for file := range tt.checksums {
+				res.Files = append(res.Files, res.FilesPath+"/unzipped/"+file)
+			}
+			runner.audited = nil
+
+			msg := message.Message{
+				Title:  "Test",
+				Slug:   "test",
+				Audits: []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: tt.standard}}},
+			}
+
+			if _, err := cs.Do(context.Background(), msg, res); err != nil {
+				t.Fatalf("Phpcs.Do() error = %v", err)
+			}
+
+			if len(runner.audited) != tt.wantAudited {
+				t.Errorf("Phpcs.Do() audited %v, want %v files", runner.audited, tt.wantAudited)
+			}
+
+			audit, _ := res.Audit(auditKind(msg.Audits[0]))
+			if got := audit.Extra["reused"]; got != tt.wantReused {
+				t.Errorf("Phpcs.Do() reused %v files, want %v", got, tt.wantReused)
+			}
+			summary := audit.Summary.PhpcsSummary
+			if summary == nil || summary.ErrorsCount != tt.wantErrors || summary.WarningsCount != tt.wantWarnings {
+				t.Errorf("Phpcs.Do() summary = %+v, want %v errors and %v warnings", summary, tt.wantErrors, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestPhpcs_Do_ReuseReports(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "reuse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cs := &Phpcs{
+		TempFolder:      dir,
+		StorageProvider: local.NewLocalStorage(dir+"/storage", ""),
+		PhpcsVersions:   map[string]map[string]string{"wordpress": {"phpcs": "3.1.1"}},
+		Runner:          &incrementalRunner{files: map[string][]tide.PhpcsFilesMessage{"a.php": {}}},
+		Transformers:    []ReportTransformer{SummaryTransformer{}},
+		ReuseReports:    true,
+	}
+
+	redaction := &Redaction{
+		Hostnames: []string{},
+		Clients:   map[string][]string{"wporg": {"secret"}, "other": {"other-secret"}},
+	}
+
+	tests := []struct {
+		name         string
+		checksum     string
+		versions     map[string]string
+		redaction    *Redaction
+		client       string
+		wantCacheHit bool
+	}{
+		{"First Audit", "checksum", map[string]string{"phpcs": "3.1.1"}, nil, "", false},
+		{"Same Audit", "checksum", map[string]string{"phpcs": "3.1.1"}, nil, "", true},
+		{"Other Sources", "other", map[string]string{"phpcs": "3.1.1"}, nil, "", false},
+		{"Other Versions", "checksum", map[string]string{"phpcs": "3.7.2"}, nil, "", false},
+		{"Redacted", "checksum", map[string]string{"phpcs": "3.1.1"}, redaction, "wporg", false},
+		{"Same Redaction", "checksum", map[string]string{"phpcs": "3.1.1"}, redaction, "wporg", true},
+		{"Other Client Patterns", "checksum", map[string]string{"phpcs": "3.1.1"}, redaction, "other", false},
+	}
+
+	var references []string
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs.PhpcsVersions["wordpress"] = tt.versions
+			cs.Redaction = tt.redaction
+
+			res := NewResult()
+			res.Checksum = tt.checksum
+			res.FilesPath = dir + "/audit"
+
+			msg := message.Message{
+				Title:         "Test",
+				RequestClient: tt.client,
+				Audits:        []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}}},
+			}
+
+			if _, err := cs.Do(context.Background(), msg, res); err != nil {
+				t.Fatalf("Phpcs.Do() error = %v", err)
+			}
+
+			audit, _ := res.Audit(auditKind(msg.Audits[0]))
+			if audit.CacheHit != tt.wantCacheHit {
+				t.Errorf("Phpcs.Do() cache hit = %v, want %v", audit.CacheHit, tt.wantCacheHit)
+			}
+			if !strings.HasPrefix(audit.Raw.FileName, tt.checksum+"-phpcs_wordpress-") || !strings.HasSuffix(audit.Raw.FileName, "-raw.json") {
+				t.Errorf("Phpcs.Do() raw report = %v, want a content address", audit.Raw.FileName)
+			}
+			references = append(references, audit.Raw.FileName)
+		})
+	}
+
+	// Only the same audit, redacted the same way, has the same address.
+	if references[0] != references[1] || references[0] == references[2] || references[0] == references[3] ||
+		references[0] == references[4] || references[4] != references[5] || references[4] == references[6] {
+		t.Errorf("Phpcs.Do() raw reports = %v", references)
+	}
+}
+
+func TestPhpcs_Do_Compress(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	provider := local.NewLocalStorage(dir+"/storage", "")
+	cs := &Phpcs{
+		TempFolder:      dir,
+		StorageProvider: provider,
+		PhpcsVersions:   map[string]map[string]string{"wordpress": {"phpcs": "3.1.1"}},
+		Runner:          &incrementalRunner{files: map[string][]tide.PhpcsFilesMessage{"a.php": {}}},
+		Transformers:    []ReportTransformer{SummaryTransformer{}},
+		Compress:        true,
+	}
+
+	res := NewResult()
+	res.Checksum = "checksum"
+	res.FilesPath = dir + "/audit"
+	msg := message.Message{
+		Title:  "Test",
+		Audits: []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}}},
+	}
+	if _, err := cs.Do(context.Background(), msg, res); err != nil {
+		t.Fatalf("Phpcs.Do() error = %v", err)
+	}
+
+	audit, _ := res.Audit(auditKind(msg.Audits[0]))
+	if audit.Raw.Encoding != storage.EncodingGzip {
+		t.Errorf("Phpcs.Do() raw encoding = %q, want gzip", audit.Raw.Encoding)
+	}
+	if stored, _ := ioutil.ReadFile(dir + "/storage/" + audit.Raw.FileName + ".gz"); !bytes.HasPrefix(stored, []byte{0x1f, 0x8b}) {
+		t.Errorf("Phpcs.Do() stored raw report %q, want it gzipped", stored)
+	}
+
+	download := dir + "/download.json"
+	if err := provider.DownloadFile(audit.Raw.FileName, download); err != nil {
+		t.Fatalf("DownloadFile() error = %v", err)
+	}
+	if got, _ := ioutil.ReadFile(download); !json.Valid(got) {
+		t.Errorf("DownloadFile() = %q, want the JSON report", got)
+	}
+}
+
+func Test_incrementalKey(t *testing.T) {
+	options := &message.AuditOption{Standard: "wordpress"}
+	versions := map[string]string{"phpcs": "3.7.2", "wpcs": "3.0.1"}
+	key := incrementalKey(options, versions, "wordpress")
+
+	if incrementalKey(&message.AuditOption{Standard: "wordpress"}, versions, "wordpress") != key {
+		t.Errorf("incrementalKey() differs for the same audit")
+	}
+	if incrementalKey(&message.AuditOption{Standard: "wordpress", Exclude: "Squiz"}, versions, "wordpress") == key {
+		t.Errorf("incrementalKey() is the same for other options")
+	}
+	if incrementalKey(options, map[string]string{"phpcs": "3.7.2", "wpcs": "3.1.0"}, "wordpress") == key {
+		t.Errorf("incrementalKey() is the same for other versions")
+	}
+}
@@ -1,19 +1,51 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/hhatto/gocloc"
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/tide"
-	"golang.org/x/text/transform"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
 )
 
+// Project types of code that WordPress loads without activating it. They don't need a header.
+const (
+	ProjectTypeMuPlugin = "mu-plugin"
+	ProjectTypeDropin   = "dropin"
+)
+
+// Dropins are the files in wp-content that replace parts of WordPress, see _get_dropins().
+var Dropins = []string{
+	"advanced-cache.php",
+	"db.php",
+	"db-error.php",
+	"install.php",
+	"maintenance.php",
+	"object-cache.php",
+	"php-error.php",
+	"fatal-error-handler.php",
+	"sunrise.php",
+	"blog-deleted.php",
+	"blog-inactive.php",
+	"blog-suspended.php",
+}
+
+// A mu-plugin loader that includes a plugin from a folder, e.g. `require_once __DIR__ . '/my-plugin/my-plugin.php';`.
+var muLoaderRe = regexp.MustCompile(`\b(?:require|include)(?:_once)?\s*\(?\s*(?:__DIR__|dirname\s*\(\s*__FILE__\s*\)|WPMU_PLUGIN_DIR)\s*\.\s*['"]/([^'"]+\.php)['"]`)
+
 // Info defines the structure for our Info process.
 type Info struct {
 	Process                  // Inherits methods from Process.
@@ -22,7 +54,7 @@ type Info struct {
 }
 
 // Run executes the process in the pipeline.
-func (info *Info) Run(errc *chan error) error {
+func (info *Info) Run(sink ErrorSink) error {
 
 	if info.In == nil {
 		return errors.New("requires a previous process")
@@ -31,69 +63,96 @@ func (info *Info) Run(errc *chan error) error {
 		return errors.New("requires a next process")
 	}
 
-	go func() {
-
-		for {
-			select {
-			case in := <-info.In:
-
-				// Copy Process fields from `in` process.
-				info.CopyFields(in)
-
-				// Run the process.
-				// If processing produces an error send it up the error channel.
-				if err := info.Do(); err != nil {
-					// Pass the error up the error channel.
-					*errc <- errors.New("Info Error: " + err.Error())
-					// continue so that the message doesn't get passed along.
-					continue
-				}
-
-				// Send process to the out channel.
-				info.Out <- info
-			}
+	// The later stages need the code info, so they only pass on the messages it failed for.
+	do := func(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+		res, err := info.Do(ctx, msg, res)
+		if err != nil {
+			res.abort()
 		}
-
-	}()
+		return res, err
+	}
+	info.runStage(sink, stage{name: "Info", in: info.In, out: info.Out, do: do})
 
 	return nil
 }
 
-// Do runs the actual code for this process.
-func (info *Info) Do() error {
+// Do gathers information about the code base and returns the result populated with the code info.
+func (info *Info) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
 
-	result := *info.Result
-
-	log.Log(info.Message.Title, "Processing CodeInfo")
-
-	// Try to get filesPath from results first.
-	if path, ok := result["filesPath"].(string); ok {
-		info.SetFilesPath(path)
+	if res == nil {
+		return res, errors.New("no result to process")
 	}
 
-	if info.GetFilesPath() == "" {
-		return errors.New("could not determine files path")
+	log.Log(msg.Title, "Processing CodeInfo")
+
+	if res.FilesPath == "" {
+		return res, errors.New("could not determine files path")
 	}
 
-	path := info.GetFilesPath() + "/unzipped"
+	path := res.FilesPath + "/unzipped"
 
 	cloc, err := getCloc(path)
 	if err != nil {
-		return err
+		return res, err
 	}
 
-	projectType, details, _ := getProjectDetails(info.Message, path)
+	projectType, details, _ := getProjectDetails(msg, path)
 
-	result["info"] = tide.CodeInfo{
+	res.Info = &tide.CodeInfo{
 		Type:    projectType,
 		Details: details,
 		Cloc:    cloc,
 	}
-	info.Result = &result
 
-	log.Log(info.Message.Title, "Project is `"+projectType+"`")
+	if readme := getReadmeDetails(path); len(readme) != 0 {
+		res.Set("readme", readme)
+	}
 
-	return nil
+	log.Log(msg.Title, "Project is `"+projectType+"`")
+
+	// There is nothing for the PHP audits to do, so skip them instead of producing empty reports.
+	if !hasPHPSources(res) {
+		skipPHPAudits(msg, res)
+	}
+
+	return res, nil
+}
+
+// skipPHPAudits marks the PHP audits requested by the message as skipped. Other audits,
+// e.g. lighthouse, still run.
+func skipPHPAudits(msg message.Message, res *Result) {
+	for _, audit := range msg.Audits {
+		if audit == nil || !isPHPAudit(audit.Type) {
+			continue
+		}
+
+		kind := auditKind(audit)
+		res.SetAudit(kind, tide.AuditResult{
+			Status: tide.AuditStatusSkipped,
+			Reason: "no PHP sources",
+		})
+		log.Log(msg.Title, "Skipping "+kind+": no PHP sources")
+	}
+}
+
+// hasPHPSources determines if the result contains PHP files that can be audited.
+func hasPHPSources(res *Result) bool {
+	for _, file := range res.Files {
+		if strings.ToLower(filepath.Ext(file)) == ".php" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPHPAudit determines if the audit type only audits PHP sources.
+func isPHPAudit(auditType string) bool {
+	for _, t := range PHPAuditTypes {
+		if t == auditType {
+			return true
+		}
+	}
+	return false
 }
 
 // getProjectDetails attempts to get project details from code base.
@@ -125,14 +184,17 @@ func getProjectDetails(msg message.Message, path string) (string, []tide.InfoDet
 		}
 	}
 
-	// No headers found.
+	// No headers found. mu-plugins and dropins don't need one.
 	if len(extracted) == 0 {
+		if layoutType, layoutDetails := getLayoutDetails(msg, path, files); layoutType != "" {
+			return layoutType, layoutDetails, nil
+		}
 		return projectType, details, errors.New("not a theme or plugin")
 	}
 
 	// A single header found.
 	if len(extracted) == 1 {
-		return extracted[0].projectType, extracted[0].details, err
+		return headerType(msg, extracted[0].projectType), extracted[0].details, err
 	}
 
 	// Multiple headers found, attempt to match the correct one.
@@ -151,8 +213,8 @@ func getProjectDetails(msg message.Message, path string) (string, []tide.InfoDet
 
 		// ... match message slug to text domain or filename part and match project types.
 		if (simplified.TextDomain == msg.Slug || simplified.TextDomain == filenameMatch) &&
-			msg.ProjectType == h.projectType {
-			return h.projectType, h.details, nil
+			msg.ProjectType == headerType(msg, h.projectType) {
+			return msg.ProjectType, h.details, nil
 		}
 	}
 
@@ -160,6 +222,69 @@ func getProjectDetails(msg message.Message, path string) (string, []tide.InfoDet
 	return "", nil, errors.New("multiple headers: could not assert appropriate header for project")
 }
 
+// headerType returns the project type of a header. mu-plugins can have a plugin header,
+// so the plugin is a mu-plugin if the message says so.
+func headerType(msg message.Message, projectType string) string {
+	if projectType == "plugin" && msg.ProjectType == ProjectTypeMuPlugin {
+		return ProjectTypeMuPlugin
+	}
+	return projectType
+}
+
+// getLayoutDetails recognizes the layouts of projects without a header:
+//
+//   - dropins, if all PHP files in the root are dropins, or some are and the message says so.
+//   - mu-plugins, if a PHP file in the root loads a plugin from a folder, or the message says so.
+//     The details are those of the loaded plugin.
+//
+// It returns an empty project type for other layouts.
+func getLayoutDetails(msg message.Message, path string, files []os.FileInfo) (string, []tide.InfoDetails) {
+	var php, dropins []string
+	for _, f := range files {
+		if f.IsDir() || strings.ToLower(filepath.Ext(f.Name())) != ".php" {
+			continue
+		}
+		php = append(php, f.Name())
+		if isDropin(f.Name()) {
+			dropins = append(dropins, f.Name())
+		}
+	}
+
+	if len(dropins) > 0 && (len(dropins) == len(php) || msg.ProjectType == ProjectTypeDropin) {
+		return ProjectTypeDropin, []tide.InfoDetails{
+			{Key: "Dropins", Value: strings.Join(dropins, ", ")},
+		}
+	}
+
+	for _, name := range php {
+		data, err := ioutil.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			continue
+		}
+		for _, m := range muLoaderRe.FindAllStringSubmatch(string(data), -1) {
+			if headerType, details, err := extractHeader(filepath.Join(path, m[1])); err == nil && headerType == "plugin" {
+				return ProjectTypeMuPlugin, details
+			}
+		}
+	}
+
+	if len(php) > 0 && msg.ProjectType == ProjectTypeMuPlugin {
+		return ProjectTypeMuPlugin, []tide.InfoDetails{}
+	}
+
+	return "", nil
+}
+
+// isDropin determines if the file name is the name of a dropin.
+func isDropin(name string) bool {
+	for _, dropin := range Dropins {
+		if strings.EqualFold(name, dropin) {
+			return true
+		}
+	}
+	return false
+}
+
 // getCloc gets the code info for the current code base.
 func getCloc(path string) (map[string]tide.ClocResult, error) {
 
@@ -203,20 +328,119 @@ func getCloc(path string) (map[string]tide.ClocResult, error) {
 	return clocMap, nil
 }
 
-// cr2nl is a transformer to conver \r endings to \n.
-type cr2nl struct{ transform.NopResetter }
-
-// Transform implements transform.Transformer.
-func (cr2nl) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
-	nSrc = copy(dst, src)
-	nDst = nSrc
-	dst = dst[:nDst]
-	for i := range dst {
-		if dst[i] == '\r' {
-			dst[i] = '\n'
+// readmeFields are the fields of the readme.txt header that are recorded, and their keys.
+var readmeFields = []struct{ field, key string }{
+	{"Contributors", "Contributors"},
+	{"Tags", "Tags"},
+	{"Requires at least", "RequiresAtLeast"},
+	{"Tested up to", "TestedUpTo"},
+	{"Requires PHP", "RequiresPHP"},
+	{"Stable tag", "StableTag"},
+	{"License", "License"},
+	{"License URI", "LicenseURI"},
+}
+
+// readmeNameRe finds the name in the first line of a readme.txt, e.g. "=== My Plugin ===".
+var readmeNameRe = regexp.MustCompile(`^===\s*(.+?)\s*===`)
+
+// normalizeText returns data as UTF-8 with "\n" line endings. Byte order marks are removed,
+// UTF-16 is transcoded, and text that is not valid UTF-8 is read as Windows-1252, which is
+// what editors on Windows save readmes and headers in.
+func normalizeText(data []byte) string {
+	var decoder *encoding.Decoder
+	switch detectBOM(data) {
+	case "UTF-8":
+		data = data[3:]
+	case "UTF-16BE":
+		decoder = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder()
+	case "UTF-16LE":
+		decoder = unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()
+	default:
+		// A header that was cut off can end in the middle of a character.
+		if !utf8.Valid(trimPartialRune(data)) {
+			decoder = charmap.Windows1252.NewDecoder()
+		}
+	}
+
+	if decoder != nil {
+		if decoded, err := decoder.Bytes(data); err == nil {
+			data = decoded
+		}
+	}
+
+	text := strings.Replace(string(data), "\r\n", "\n", -1)
+	return strings.Replace(text, "\r", "\n", -1)
+}
+
+// trimPartialRune removes an incomplete UTF-8 character from the end of data.
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
 		}
 	}
-	return nSrc, nDst, nil
+	return data
+}
+
+// readHeader reads the start of a file, where WordPress looks for headers, as normalized text.
+func readHeader(filename string) (string, error) {
+	f, err := fileOpen(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(f, 8192))
+	if err != nil {
+		return "", err
+	}
+	return normalizeText(data), nil
+}
+
+// getReadmeDetails returns the name and header fields of the readme.txt in the path, if any.
+func getReadmeDetails(path string) []tide.InfoDetails {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+
+	for _, f := range files {
+		if f.IsDir() || !strings.EqualFold(f.Name(), "readme.txt") {
+			continue
+		}
+
+		readme, err := readHeader(filepath.Join(path, f.Name()))
+		if err != nil {
+			return nil
+		}
+
+		var details []tide.InfoDetails
+		lines := strings.Split(readme, "\n")
+		if m := readmeNameRe.FindStringSubmatch(strings.TrimSpace(lines[0])); m != nil {
+			details = append(details, tide.InfoDetails{Key: "Name", Value: m[1]})
+		}
+		for _, line := range lines[1:] {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "==") {
+				// The header ends at the first section.
+				break
+			}
+			for _, f := range readmeFields {
+				if value := strings.TrimPrefix(line, f.field+":"); value != line {
+					details = append(details, tide.InfoDetails{
+						Key:   f.key,
+						Value: strings.TrimSpace(value),
+					})
+				}
+			}
+		}
+		return details
+	}
+
+	return nil
 }
 
 // extractHeader scans every .php file in the path to retrieve a possible plugin header, or
@@ -242,20 +466,12 @@ func extractHeader(filename string) (projectType string, details []tide.InfoDeta
 		"Tags",
 	}
 
-	f, _ := fileOpen(filename)
-	defer f.Close()
-
-	// Pass the `f` reader to a transformed reader.
-	tr := transform.NewReader(f, cr2nl{})
-	b1 := make([]byte, 8192)
-	// Use the transform reader instead of file.
-	n1, _ := tr.Read(b1)
+	// Headers from Windows editors can have other encodings and line endings.
+	fileHeader, _ := readHeader(filename)
 
 	isStyleCSS, _ := regexp.Match(`(\/style.css)$`, []byte(filename))
 
-	if n1 > 0 {
-
-		fileHeader := strings.Replace(string(b1), "\r\n", "\n", -1)
+	if len(fileHeader) > 0 {
 
 		validHeader := false
 		for _, field := range headerFields {
@@ -118,7 +118,7 @@ func TestInfo_Run(t *testing.T) {
 					Process: Process{
 						Message: message.Message{Title: "Test Theme"},
 						Result: &Result{
-							"filesPath": "./testdata/info/theme",
+							FilesPath: "./testdata/info/theme",
 						},
 					},
 				},
@@ -178,7 +178,7 @@ func TestInfo_Run(t *testing.T) {
 
 			var err error
 			var chanError error
-			errc := make(chan error)
+			errc := make(ErrorChannel)
 
 			go func() {
 				for {
@@ -190,7 +190,7 @@ func TestInfo_Run(t *testing.T) {
 			}()
 
 			go func() {
-				err = info.Run(&errc)
+				err = info.Run(errc)
 			}()
 
 			// Sleep a short time delay to give process time to start.
@@ -345,6 +345,81 @@ func Test_getProjectDetails(t *testing.T) {
 			nil,
 			true,
 		},
+		{
+			"Dropins",
+			args{
+				path: "./testdata/info/dropins/unzipped",
+			},
+			"dropin",
+			[]tide.InfoDetails{{Key: "Dropins", Value: "advanced-cache.php, object-cache.php"}},
+			false,
+		},
+		{
+			"Dropin With Other Files",
+			args{
+				msg: message.Message{
+					ProjectType: "dropin",
+				},
+				path: "./testdata/info/dropin/unzipped",
+			},
+			"dropin",
+			[]tide.InfoDetails{{Key: "Dropins", Value: "object-cache.php"}},
+			false,
+		},
+		{
+			"Not Only Dropins",
+			args{
+				path: "./testdata/info/dropin/unzipped",
+			},
+			"other",
+			nil,
+			true,
+		},
+		{
+			"MU Plugin Loader",
+			args{
+				path: "./testdata/info/mu-plugin/unzipped",
+			},
+			"mu-plugin",
+			[]tide.InfoDetails{
+				{Key: "Name", Value: "Dummy MU Plugin"},
+				{Key: "Description", Value: "This does nothing."},
+				{Key: "Version", Value: "1.0"},
+				{Key: "TextDomain", Value: "dummy-mu-plugin"},
+			},
+			false,
+		},
+		{
+			"MU Plugin Without Header",
+			args{
+				msg: message.Message{
+					ProjectType: "mu-plugin",
+				},
+				path: "./testdata/info/other/unzipped",
+			},
+			"mu-plugin",
+			[]tide.InfoDetails{},
+			false,
+		},
+		{
+			"MU Plugin With Header",
+			args{
+				msg: message.Message{
+					ProjectType: "mu-plugin",
+				},
+				path: "./testdata/info/r-line-endings/unzipped",
+			},
+			"mu-plugin",
+			[]tide.InfoDetails{
+				{"Name", "大猫评论内容关键词过滤"},
+				{"PluginURI", "http://www.yiduqiang.com/"},
+				{"Description", "过滤评论者或文章中不应该出现的词汇。"},
+				{"Version", "1.0.1"},
+				{"Author", "一堵墙"},
+				{"AuthorURI", "http://www.yiduqiang.com/"},
+			},
+			false,
+		},
 		{
 			"\r line endings",
 			args{
@@ -400,3 +475,146 @@ func Test_getProjectDetails(t *testing.T) {
 		})
 	}
 }
+
+func TestInfo_Do_NoPHP(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	msg := message.Message{
+		Title: "Assets",
+		Audits: []*message.Audit{
+			{Type: "phpcs", Options: &message.AuditOption{Standard: "WordPress"}},
+			{Type: "security"},
+			{Type: "lighthouse"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		filesPath string
+		files     []string
+		want      []string // Skipped audit kinds.
+	}{
+		{
+			"No PHP Sources",
+			"./testdata/info/assets",
+			[]string{"./testdata/info/assets/unzipped/script.js", "./testdata/info/assets/unzipped/style.css"},
+			[]string{"phpcs_wordpress", "security"},
+		},
+		{
+			"PHP Sources",
+			"./testdata/info/theme",
+			[]string{"./testdata/info/theme/unzipped/function.php", "./testdata/info/theme/unzipped/style.css"},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := NewResult()
+			res.FilesPath = tt.filesPath
+			res.Files = tt.files
+
+			if _, err := (&Info{}).Do(context.Background(), msg, res); err != nil {
+				t.Fatalf("Info.Do() error = %v", err)
+			}
+
+			var got []string
+			for _, kind := range []string{"phpcs_wordpress", "security", "lighthouse"} {
+				if skipped(res, kind) {
+					got = append(got, kind)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Info.Do() skipped %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Skipped audits are not run.
+	res := NewResult()
+	res.FilesPath = "./testdata/info/assets"
+	res.Checksum = "checksum"
+	(&Info{}).Do(context.Background(), msg, res)
+
+	oldRunner := phpcsRunner
+	runner := &recordingRunner{}
+	phpcsRunner = runner
+	defer func() { phpcsRunner = oldRunner }()
+
+	if _, err := (&Phpcs{}).Do(context.Background(), msg, res); err != nil || len(runner.commands) != 0 {
+		t.Errorf("Phpcs.Do() ran %v, %v for a skipped audit", runner.commands, err)
+	}
+	if audit, _ := res.Audit("security"); audit.Reason != "no PHP sources" {
+		t.Errorf("Info.Do() security audit = %v", audit)
+	}
+	if _, err := (&Security{}).Do(context.Background(), msg, res); err != nil {
+		t.Errorf("Security.Do() error = %v", err)
+	}
+	if audit, _ := res.Audit("security"); audit.Status != tide.AuditStatusSkipped {
+		t.Errorf("Security.Do() replaced the skipped audit: %v", audit)
+	}
+}
+
+func Test_normalizeText(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"UTF-8", []byte("Café\nMüller"), "Café\nMüller"},
+		{"UTF-8 BOM", []byte("\xef\xbb\xbfCafé"), "Café"},
+		{"UTF-16LE", []byte("\xff\xfeC\x00a\x00f\x00\xe9\x00"), "Café"},
+		{"UTF-16BE", []byte("\xfe\xff\x00C\x00a\x00f\x00\xe9"), "Café"},
+		{"Windows-1252", []byte("Caf\xe9 \x93M\xfcller\x94"), "Café “Müller”"},
+		{"Line Endings", []byte("a\r\nb\rc\n"), "a\nb\nc\n"},
+		{"Cut Off Character", []byte("Café 大\xe7\x8c"), "Café 大\xe7\x8c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.data); got != tt.want {
+				t.Errorf("normalizeText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfo_Do_Encoding(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	wantDetails := []tide.InfoDetails{
+		{"Name", "Café Müller"},
+		{"Description", "Grüße “für” alle"},
+		{"Version", "1.0"},
+		{"Author", "Renée"},
+		{"TextDomain", "cafe-muller"},
+	}
+	wantReadme := []tide.InfoDetails{
+		{"Name", "Café Müller"},
+		{"Contributors", "renée"},
+		{"Tags", "café"},
+		{"RequiresAtLeast", "5.0"},
+		{"TestedUpTo", "6.4"},
+		{"StableTag", "1.0"},
+	}
+
+	for _, encoding := range []string{"windows-1252", "utf-16", "utf-8-bom"} {
+		t.Run(encoding, func(t *testing.T) {
+			res := NewResult()
+			res.FilesPath = "./testdata/info/encoding/" + encoding
+
+			if _, err := (&Info{}).Do(context.Background(), message.Message{Title: encoding}, res); err != nil {
+				t.Fatalf("Info.Do() error = %v", err)
+			}
+
+			if res.Info.Type != "plugin" || !reflect.DeepEqual(res.Info.Details, wantDetails) {
+				t.Errorf("Info.Do() info = %v, want %v", res.Info.Details, wantDetails)
+			}
+			if got, _ := res.Get("readme"); !reflect.DeepEqual(got, wantReadme) {
+				t.Errorf("Info.Do() readme = %v, want %v", got, wantReadme)
+			}
+		})
+	}
+}
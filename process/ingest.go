@@ -1,27 +1,88 @@
 package process
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/wptide/pkg/lock"
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/svn"
+	"github.com/wptide/pkg/source/tar"
 	"github.com/wptide/pkg/source/zip"
+	"github.com/wptide/pkg/tide"
 )
 
+// errProjectLocked is returned for messages of a project that another worker is auditing, e.g.
+// duplicate messages. They are dropped, the other worker sends the results.
+var errProjectLocked = errors.New("project is already being audited by another worker")
+
 // Ingest defines the structure for our Ingest process.
 type Ingest struct {
 	Process                              // Inherits methods from Process.
 	In            <-chan message.Message // Expects a message channel as input.
 	Out           chan Processor         // Send results to an output channel.
 	TempFolder    string                 // Path to a temp folder where files will be extracted.
+	Checksum      source.ChecksumOptions // (Optional) Hash algorithm and concurrency for file checksums.
+	NamePolicy    source.NamePolicy      // (Optional) Handling of file names that are not portable. Defaults to source.NameRename.
+	Scanner       source.Scanner         // (Optional) Scans downloaded archives for malware before they are extracted.
+	Quarantine    string                 // (Optional) Folder that infected archives are moved to. They are deleted otherwise.
+	Download      source.DownloadOptions // (Optional) Retries and timeout of archive downloads.
+	Limits        source.ExtractLimits   // (Optional) Size, file count and compression ratio limits of archives.
+	Extract       source.ExtractOptions  // (Optional) Concurrency and buffer size of archive extraction.
+	Cache         source.CacheProvider   // (Optional) Restores sources that have not changed instead of downloading them again.
+	Locker        lock.Locker            // (Optional) Prevents workers from auditing the same source at the same time, e.g. for duplicate messages.
+	LockTTL       time.Duration          // (Optional) How long a project lock is held for. Defaults to 30 minutes.
+	Environment   *tide.Environment      // (Optional) Runtime environment recorded in every result. See ComputeEnvironment.
 	sourceManager source.Source          // Responsible for getting the code to audit.
 }
 
+func init() {
+	// The built-in source kinds. Archives are recognised by their extension, Subversion
+	// repositories by their host or the source type of the message.
+	source.Register("zip", func(url string, options source.Options) source.Source {
+		z := zip.NewZipWithOptions(url, options.Checksum)
+		z.NamePolicy = options.NamePolicy
+		z.Scanner = options.Scanner
+		z.Download = options.Download
+		z.Context = options.Context
+		z.Limits = options.Limits
+		z.Extract = options.Extract
+		return z
+	}, func(url string) bool {
+		return source.GetKind(url) == "zip"
+	})
+
+	source.Register("tar", func(url string, options source.Options) source.Source {
+		t := tar.NewTarWithOptions(url, options.Checksum)
+		t.NamePolicy = options.NamePolicy
+		t.Scanner = options.Scanner
+		t.Download = options.Download
+		t.Context = options.Context
+		t.Limits = options.Limits
+		t.Extract = options.Extract
+		return t
+	}, func(url string) bool {
+		_, ok := tar.Compression(url)
+		return ok
+	})
+
+	source.Register("svn", func(url string, options source.Options) source.Source {
+		s := svn.NewSvnWithOptions(url, options.Checksum)
+		s.Context = options.Context
+		return s
+	}, svn.IsRepository)
+}
+
 // Run executes the process in the pipeline.
-func (ig *Ingest) Run(errc *chan error) error {
+func (ig *Ingest) Run(sink ErrorSink) error {
 	// If we don't have a temp folder, then we need a fatal.
 	if ig.TempFolder == "" {
 		return errors.New("no temp folder provided for processes")
@@ -33,38 +94,61 @@ func (ig *Ingest) Run(errc *chan error) error {
 		return errors.New("requires a next process")
 	}
 
+	ig.start()
+
 	go func() {
+		// Close the out channel and signal that we are done when the goroutine exits.
+		defer ig.stop(ig.Out)
+
 		for {
 			select {
-			case msg := <-ig.In:
+			case <-ig.getContext().Done():
+				// The pipeline has been cancelled.
+				return
 
-				// Init the Result object.
-				ig.Result = &Result{}
+			case msg, ok := <-ig.In:
+				// The previous process has stopped.
+				if !ok {
+					return
+				}
 
-				// If message is invalid, skip it, but keep listening on the channel.
-				if err := validateMessage(msg); err != nil {
-					// Pass the error up the error channel.
-					*errc <- errors.New("Ingest Error: " + err.Error())
+				// Each message is processed as a job of its own.
+				job := NewJob(ig.getContext(), msg, nil)
 
-					// continue so that the message doesn't get passed along.
+				// Invalid messages can't be audited and have nowhere to send their results to, so
+				// they are given up instead of being passed on.
+				if err := validateMessage(msg); err != nil {
+					reportError(sink, nil, NewError("Ingest", msg, err))
+					drop(msg, false)
 					continue
 				}
 
-				// Get the original message.
-				ig.SetMessage(msg)
-
 				// Run the process.
-				// If processing produces an error send it up the error channel.
-				if err := ig.Do(); err != nil {
-					// Pass the error up the error channel.
-					*errc <- errors.New("Ingest Error: " + err.Error())
-
-					// continue so that the message doesn't get passed along.
+				// If processing produces an error send it to the error sink.
+				job.startTimer()
+				res, err := ig.Do(job.Context(), msg, NewResult())
+				if err == errProjectLocked {
+					// The message is a duplicate, the worker that holds the lock sends the results.
+					e := NewError("Ingest", msg, err)
+					e.Severity = SeveritySkip
+					reportError(sink, nil, e)
+					drop(msg, true)
 					continue
 				}
+				if err != nil {
+					// Record the error against the message and pass it to the error sink.
+					reportError(sink, res, NewError("Ingest", msg, err))
+					// The message is passed on without its files, so that the last stages can
+					// still send and retry it.
+					res.abort()
+				}
+
+				job.output("ingest", res)
 
-				// Send process to the out channel.
-				ig.Out <- ig
+				// Send the job to the out channel.
+				if !ig.send(ig.Out, job) {
+					return
+				}
 			}
 		}
 
@@ -73,51 +157,183 @@ func (ig *Ingest) Run(errc *chan error) error {
 	return nil
 }
 
-// Do runs the actual code for this process.
-func (ig *Ingest) Do() error {
+// Do downloads and extracts the source of the message and returns the result
+// populated with the checksum, files and files path.
+func (ig *Ingest) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
 
-	log.Log(ig.Message.Title, "Ingesting...")
+	log.Log(msg.Title, "Ingesting...")
 
 	// Set the source manager based on message.
-	switch source.GetKind(ig.Message.SourceURL) {
-	case "zip":
-		ig.sourceManager = zip.NewZip(ig.Message.SourceURL)
+	sourceManager := ig.sourceManager
+	if src, ok := source.New(msg.SourceType, msg.SourceURL, source.Options{
+		Context:    ctx,
+		Checksum:   ig.Checksum,
+		NamePolicy: ig.NamePolicy,
+		Scanner:    ig.Scanner,
+		Download:   ig.Download,
+		Limits:     ig.Limits,
+		Extract:    ig.Extract,
+	}); ok {
+		sourceManager = src
 	}
 
 	// Return an error if we don't have a source manager.
-	if ig.sourceManager == nil {
-		return ig.Error("could not get appropriate source manager to handle ingest")
+	if sourceManager == nil {
+		return res, messageError(msg, "could not get appropriate source manager to handle ingest")
+	}
+
+	var cached *source.Cached
+	if ig.Cache != nil {
+		cached = source.NewCached(sourceManager, ig.Cache, msg.SourceURL)
+		cached.Context = ctx
+		sourceManager = cached
 	}
 
 	// Calculate hash of the source url.
 	hasher := sha256.New()
-	hasher.Write([]byte(ig.Message.SourceURL))
+	hasher.Write([]byte(msg.SourceURL))
 
 	// Set the path to where we will extract the files.
-	ig.SetFilesPath(ig.TempFolder + "/audit-" + base64.URLEncoding.EncodeToString(hasher.Sum(nil)))
+	sourceKey := base64.URLEncoding.EncodeToString(hasher.Sum(nil))
+	filesPath := ig.TempFolder + "/audit-" + sourceKey
+
+	if res == nil {
+		res = NewResult()
+	}
+
+	// Make sure that no other worker is auditing the same source, e.g. for a duplicate message,
+	// before the files are downloaded to the folder of the source.
+	// The lock is released once the results have been sent.
+	if ig.Locker != nil {
+		ttl := ig.LockTTL
+		if ttl <= 0 {
+			ttl = 30 * time.Minute
+		}
+
+		held, err := ig.Locker.Acquire(lock.Key(msg.Slug, sourceKey), ttl)
+		if err == lock.ErrLocked {
+			return res, errProjectLocked
+		}
+		if err != nil {
+			return res, err
+		}
+		res.held = held
+	}
 
 	// Download/Prepare the files.
-	err := ig.sourceManager.PrepareFiles(ig.GetFilesPath())
+	err := sourceManager.PrepareFiles(filesPath)
+	if malware, ok := err.(*source.MalwareError); ok {
+		ig.quarantine(msg, filesPath, malware)
+	}
 	if err != nil {
-		return err
+		return res, err
+	}
+
+	if cached != nil && cached.Hit() {
+		log.Log(msg.Title, "Restored source from cache.")
 	}
 
 	// Project checksum.
-	checksum := ig.sourceManager.GetChecksum()
+	checksum := sourceManager.GetChecksum()
 	if checksum == "" {
-		return ig.Error("could not calculate project checksum")
+		return res, messageError(msg, "could not calculate project checksum")
 	}
 
 	// Populate the result.
-	result := *ig.Result
-	result["checksum"] = checksum
-	result["files"] = ig.sourceManager.GetFiles()
-	result["filesPath"] = ig.GetFilesPath()
-	ig.Result = &result
+	res.Checksum = checksum
+	res.ChecksumExclude = ig.Checksum.Exclude
+	res.Files = sourceManager.GetFiles()
+	res.FilesPath = filesPath
+
+	// Keep the checksum of each file, e.g. to audit only the files that changed.
+	if reporter, ok := sourceManager.(source.ChecksumReporter); ok {
+		res.FileChecksums = make(map[string]string)
+		for file, sum := range reporter.GetFileChecksums() {
+			res.FileChecksums[relativeName(filesPath+"/unzipped", file)] = sum
+		}
+	}
+	res.Environment = ig.Environment
 
-	log.Log(ig.Message.Title, "Project checksum: `"+checksum+"`")
+	log.Log(msg.Title, "Project checksum: `"+checksum+"`")
 
-	return nil
+	if reporter, ok := sourceManager.(source.TimingReporter); ok {
+		for stage, d := range reporter.GetTimings() {
+			res.AddTiming(stage, d)
+		}
+	}
+
+	if reporter, ok := sourceManager.(source.DownloadReporter); ok {
+		res.AddUsage(Usage{Downloaded: reporter.GetDownloaded()})
+	}
+
+	// Report the file names that had to be renamed or skipped.
+	if reporter, ok := sourceManager.(source.AnomalyReporter); ok {
+		reportAnomalies(res, reporter.GetAnomalies())
+	}
+
+	// Look for binary files and encoding problems before the files get audited.
+	if err := scanFiles(res); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// drop settles the lease of a message that is not passed on to the next process. It is
+// acknowledged, or given up without being requeued.
+func drop(msg message.Message, ack bool) {
+	if msg.Lease == nil {
+		return
+	}
+
+	var err error
+	if ack {
+		err = msg.Lease.Ack()
+	} else {
+		err = msg.Lease.Nack(false)
+	}
+	if err != nil {
+		log.Log(msg.Title, "Could not settle message: "+err.Error())
+	}
+}
+
+// quarantine moves the infected archive to the quarantine folder, or deletes it, and removes
+// everything else that was downloaded for the message.
+func (ig *Ingest) quarantine(msg message.Message, filesPath string, malware *source.MalwareError) {
+	log.Log(msg.Title, "Malware detected: "+malware.Threat)
+
+	if ig.Quarantine != "" {
+		if err := os.MkdirAll(ig.Quarantine, 0700); err != nil {
+			log.Log(msg.Title, "Could not quarantine source: "+err.Error())
+		} else {
+			name := filepath.Join(ig.Quarantine, filepath.Base(filesPath)+"-"+filepath.Base(malware.Path))
+			if err := os.Rename(malware.Path, name); err != nil {
+				log.Log(msg.Title, "Could not quarantine source: "+err.Error())
+			} else {
+				malware.Path = name
+			}
+		}
+	}
+
+	os.RemoveAll(filesPath)
+}
+
+// reportAnomalies records the file names that were normalized during extraction as findings.
+func reportAnomalies(res *Result, anomalies []source.Anomaly) {
+	for _, anomaly := range anomalies {
+		finding := Finding{
+			Process:  "Ingest",
+			Type:     FindingFilename,
+			File:     anomaly.Path,
+			Message:  "renamed " + anomaly.Name + " (" + strings.Join(anomaly.Reasons, ", ") + ")",
+			Severity: FindingWarning,
+		}
+		if anomaly.Skipped {
+			finding.File = ""
+			finding.Message = "skipped " + anomaly.Name + " (" + strings.Join(anomaly.Reasons, ", ") + ")"
+		}
+		res.AddFinding(finding)
+	}
 }
 
 // validateMessage ensures that a message to be processed has the minimum requirements.
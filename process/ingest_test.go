@@ -1,19 +1,31 @@
 package process
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
 	"testing"
 
 	"bytes"
 	"context"
+	"errors"
 	"time"
 
+	"github.com/wptide/pkg/lock"
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/payload"
 	"github.com/wptide/pkg/source"
+	"github.com/wptide/pkg/source/tar"
+	"github.com/wptide/pkg/source/zip"
+	"github.com/wptide/pkg/tide"
 )
 
 type mockSource struct{}
@@ -22,6 +34,18 @@ func (m mockSource) PrepareFiles(dest string) error { return nil }
 func (m mockSource) GetChecksum() string            { return "" }
 func (m mockSource) GetFiles() []string             { return nil }
 
+type mockAnomalySource struct {
+	mockSource
+}
+
+func (m mockAnomalySource) GetChecksum() string { return "checksum" }
+func (m mockAnomalySource) GetAnomalies() []source.Anomaly {
+	return []source.Anomaly{
+		{Name: `plugin\aux.php`, Path: "plugin/_aux.php", Reasons: []string{source.ReasonBackslash, source.ReasonReserved}},
+		{Name: "plugin/readme.txt.", Reasons: []string{source.ReasonTrailing}, Skipped: true},
+	}
+}
+
 type mockProcess struct {
 	Process
 	In  <-chan Processor
@@ -37,6 +61,9 @@ var ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http
 	case "/test.zip":
 		http.ServeFile(w, r, "./testdata/test.zip")
 		return
+	case "/test.tar.gz":
+		http.ServeFile(w, r, "./testdata/test.tar.gz")
+		return
 	case "/api/audits":
 		http.ServeFile(w, r, `{ "message": "Payload received" }`)
 		return
@@ -136,6 +163,7 @@ func TestIngest_process(t *testing.T) {
 	type options struct {
 		tempFolder string
 		sourceMgr  source.Source
+		locker     *mockLocker
 	}
 
 	tests := []struct {
@@ -144,6 +172,48 @@ func TestIngest_process(t *testing.T) {
 		options options
 		wantErr bool
 	}{
+		{
+			"Valid Ingest - Locked",
+			message.Message{
+				Title:               "Test Ingest",
+				Slug:                "test",
+				ResponseAPIEndpoint: ts.URL + "/api/audits",
+				SourceURL:           ts.URL + "/test.zip",
+				SourceType:          "zip",
+			},
+			options{
+				locker: &mockLocker{},
+			},
+			false,
+		},
+		{
+			"Project Already Locked",
+			message.Message{
+				Title:               "Test Ingest",
+				Slug:                "test",
+				ResponseAPIEndpoint: ts.URL + "/api/audits",
+				SourceURL:           ts.URL + "/test.zip",
+				SourceType:          "zip",
+			},
+			options{
+				locker: &mockLocker{err: lock.ErrLocked},
+			},
+			true,
+		},
+		{
+			"Locker Error",
+			message.Message{
+				Title:               "Test Ingest",
+				Slug:                "test",
+				ResponseAPIEndpoint: ts.URL + "/api/audits",
+				SourceURL:           ts.URL + "/test.zip",
+				SourceType:          "zip",
+			},
+			options{
+				locker: &mockLocker{err: errors.New("connection refused")},
+			},
+			true,
+		},
 		{
 			"Valid Ingest",
 			message.Message{
@@ -155,6 +225,17 @@ func TestIngest_process(t *testing.T) {
 			options{},
 			false,
 		},
+		{
+			"Valid Ingest - Tarball",
+			message.Message{
+				Title:               "Test Ingest",
+				ResponseAPIEndpoint: ts.URL + "/api/audits",
+				SourceURL:           ts.URL + "/test.tar.gz",
+				SourceType:          "tar",
+			},
+			options{},
+			false,
+		},
 		{
 			"No valid source manager",
 			message.Message{
@@ -206,9 +287,30 @@ func TestIngest_process(t *testing.T) {
 				ig.sourceManager = tt.options.sourceMgr
 			}
 
-			ig.Message = tt.message
-			if err := ig.Do(); (err != nil) != tt.wantErr {
+			if tt.options.locker != nil {
+				ig.Locker = tt.options.locker
+			}
+
+			sum := sha256.Sum256([]byte(tt.message.SourceURL))
+			sourceKey := base64.URLEncoding.EncodeToString(sum[:])
+			os.RemoveAll(ig.TempFolder + "/audit-" + sourceKey)
+
+			res, err := ig.Do(context.Background(), tt.message, ig.Result)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("Ingest.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			// The source is locked before it is downloaded.
+			if tt.options.locker != nil && !tt.wantErr {
+				if res.held != tt.options.locker || tt.options.locker.key != lock.Key("test", sourceKey) {
+					t.Errorf("Ingest.Do() did not hold the project lock %v", tt.options.locker.key)
+				}
+			}
+			if err == errProjectLocked {
+				if _, err := os.Stat(ig.TempFolder + "/audit-" + sourceKey); !os.IsNotExist(err) {
+					t.Errorf("Ingest.Do() downloaded the locked source: %v", err)
+				}
 			}
 		})
 	}
@@ -361,7 +463,7 @@ func TestIngest_Run(t *testing.T) {
 
 			var err error
 			var chanError error
-			errc := make(chan error)
+			errc := make(ErrorChannel)
 
 			go func() {
 				for {
@@ -373,7 +475,7 @@ func TestIngest_Run(t *testing.T) {
 			}()
 
 			go func() {
-				err = ig.Run(&errc)
+				err = ig.Run(errc)
 			}()
 
 			// Sleep a short time delay to give process time to start.
@@ -396,6 +498,129 @@ func TestIngest_Run(t *testing.T) {
 	}
 }
 
+func TestIngest_Run_Aborted(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	ig := &Ingest{
+		In: generateMessages([]message.Message{{
+			Title:               "Test",
+			ResponseAPIEndpoint: "http://test.local/api/audits",
+			SourceURL:           ts.URL + "/notfound.zip",
+			SourceType:          "zip",
+		}}),
+		Out:        make(chan Processor),
+		TempFolder: "./testdata/tmp",
+	}
+	if err := ig.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Messages that fail are passed on, so that they can still be sent and retried.
+	job := (<-ig.Out).(*Job)
+	if !job.Result.aborted || len(job.Result.Errors) != 1 || job.Result.Errors[0].Process != "Ingest" {
+		t.Errorf("Ingest.Run() sent %+v, want the aborted message with its error", job.Result)
+	}
+	<-ig.Done()
+}
+
+// recordingPayloader is a MockPayloader that records the destinations of the sent payloads.
+type recordingPayloader struct {
+	MockPayloader
+	mu   sync.Mutex
+	sent []string
+}
+
+func (p *recordingPayloader) SendPayload(destination string, payload []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, destination)
+	return p.MockPayloader.SendPayload(destination, payload)
+}
+
+// severitySink records the severities of the reported errors.
+type severitySink struct {
+	mu         sync.Mutex
+	severities []string
+}
+
+func (s *severitySink) Report(err *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.severities = append(s.severities, err.Severity)
+}
+
+func TestIngest_Run_Dropped(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	os.Mkdir("./testdata/tmp", os.ModePerm)
+	defer os.RemoveAll("./testdata/tmp")
+
+	tests := []struct {
+		name         string
+		msg          message.Message
+		locker       lock.Locker
+		wantSettled  []string
+		wantSeverity string
+	}{
+		{
+			"Missing Endpoint",
+			message.Message{Title: "Test", Slug: "test", PayloadType: "mock", SourceURL: ts.URL + "/test.zip", SourceType: "zip"},
+			nil,
+			[]string{"nack requeue=false"},
+			SeverityFatal,
+		},
+		{
+			"Locked Duplicate",
+			message.Message{Title: "Test", Slug: "test", PayloadType: "mock", ResponseAPIEndpoint: "http://test.local/api/audits", SourceURL: ts.URL + "/test.zip", SourceType: "zip"},
+			&mockLocker{err: lock.ErrLocked},
+			[]string{"ack"},
+			SeveritySkip,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &leaseQueue{}
+			ref := "receipt"
+			tt.msg.Lease = message.NewLease(queue, &ref)
+
+			sink := &severitySink{}
+			ig := &Ingest{
+				In:         generateMessages([]message.Message{tt.msg}),
+				Out:        make(chan Processor),
+				TempFolder: "./testdata/tmp",
+				Locker:     tt.locker,
+			}
+			payloader := &recordingPayloader{}
+			res := &Response{
+				In:         ig.Out,
+				Payloaders: map[string]payload.Payloader{"mock": payloader},
+			}
+			if err := ig.Run(sink); err != nil {
+				t.Fatal(err)
+			}
+			if err := res.Run(sink); err != nil {
+				t.Fatal(err)
+			}
+			<-res.Done()
+
+			// The message is settled without sending any results.
+			if len(payloader.sent) != 0 {
+				t.Errorf("Response sent results to %q", payloader.sent)
+			}
+			if !reflect.DeepEqual(queue.settled, tt.wantSettled) {
+				t.Errorf("Ingest.Run() settled %v, want %v", queue.settled, tt.wantSettled)
+			}
+			if want := []string{tt.wantSeverity}; !reflect.DeepEqual(sink.severities, want) {
+				t.Errorf("Ingest.Run() reported %v, want %v", sink.severities, want)
+			}
+		})
+	}
+}
+
 func generateMessages(messages []message.Message) <-chan message.Message {
 	out := make(chan message.Message, len(messages))
 
@@ -408,3 +633,198 @@ func generateMessages(messages []message.Message) <-chan message.Message {
 
 	return out
 }
+
+func TestIngest_Do_Anomalies(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	environment := &tide.Environment{OS: "linux/amd64", Fingerprint: "abc"}
+	ig := &Ingest{
+		TempFolder:    "./testdata/tmp",
+		Environment:   environment,
+		Checksum:      source.ChecksumOptions{Exclude: []string{"*.mo"}},
+		sourceManager: mockAnomalySource{},
+	}
+
+	res, err := ig.Do(context.Background(), message.Message{Title: "Test", SourceURL: "http://test.local/source"}, nil)
+	if err != nil {
+		t.Fatalf("Ingest.Do() error = %v", err)
+	}
+
+	want := []Finding{
+		{"Ingest", FindingFilename, "plugin/_aux.php", `renamed plugin\aux.php (backslash separator, reserved name)`, FindingWarning},
+		{"Ingest", FindingFilename, "", "skipped plugin/readme.txt. (trailing dot or space)", FindingWarning},
+	}
+	if !reflect.DeepEqual(res.Findings, want) {
+		t.Errorf("Ingest.Do() findings = %v, want %v", res.Findings, want)
+	}
+	if res.Environment != environment {
+		t.Errorf("Ingest.Do() environment = %v, want %v", res.Environment, environment)
+	}
+	if !reflect.DeepEqual(res.ChecksumExclude, []string{"*.mo"}) {
+		t.Errorf("Ingest.Do() checksum exclusions = %v", res.ChecksumExclude)
+	}
+}
+
+func TestIngest_Do_RegisteredSource(t *testing.T) {
+	var got source.Options
+	source.Register("test-custom", func(url string, options source.Options) source.Source {
+		got = options
+		return mockAnomalySource{}
+	}, nil)
+	defer source.Unregister("test-custom")
+
+	ig := &Ingest{
+		TempFolder: "./testdata/tmp",
+		NamePolicy: source.NameSkip,
+	}
+
+	msg := message.Message{Title: "Test", SourceURL: "s3://bucket/plugin", SourceType: "test-custom"}
+	res, err := ig.Do(context.Background(), msg, nil)
+	if err != nil {
+		t.Fatalf("Ingest.Do() error = %v", err)
+	}
+	if res.Checksum != "checksum" || got.NamePolicy != source.NameSkip {
+		t.Errorf("Ingest.Do() checksum = %v, options = %v", res.Checksum, got)
+	}
+}
+
+func TestIngest_SourceOptions(t *testing.T) {
+	options := source.Options{
+		Download: source.DownloadOptions{Retries: 3},
+		Extract:  source.ExtractOptions{BufferSize: 4096},
+	}
+
+	tests := []struct {
+		kind string
+		url  string
+	}{
+		{"zip", "https://example.com/plugin.zip"},
+		{"tar", "https://example.com/plugin.tar.gz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			src, ok := source.New(tt.kind, tt.url, options)
+			if !ok {
+				t.Fatalf("source.New() found no %v source", tt.kind)
+			}
+
+			var download source.DownloadOptions
+			var extract source.ExtractOptions
+			switch s := src.(type) {
+			case *zip.Zip:
+				download, extract = s.Download, s.Extract
+			case *tar.Tar:
+				download, extract = s.Download, s.Extract
+			}
+			if download != options.Download || extract != options.Extract {
+				t.Errorf("source.New() download = %v, extract = %v, want %v, %v", download, extract, options.Download, options.Extract)
+			}
+		})
+	}
+}
+
+func TestIngest_Do_Malware(t *testing.T) {
+	tempFolder := "./testdata/malware"
+	quarantine := "./testdata/quarantine"
+	os.MkdirAll(tempFolder, os.ModePerm)
+	defer os.RemoveAll(tempFolder)
+	defer os.RemoveAll(quarantine)
+
+	tests := []struct {
+		name           string
+		scanner        source.Scanner
+		wantErr        error
+		wantQuarantine int
+	}{
+		{
+			"Clean",
+			source.ScannerFunc(func(path string) (string, error) { return "", nil }),
+			nil,
+			0,
+		},
+		{
+			"Infected",
+			source.ScannerFunc(func(path string) (string, error) { return "Win.Test.EICAR_HDB-1", nil }),
+			&source.MalwareError{},
+			1,
+		},
+		{
+			"Scan Error",
+			source.ScannerFunc(func(path string) (string, error) { return "", errors.New("connection refused") }),
+			&source.ScanError{},
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(quarantine)
+
+			ig := &Ingest{
+				TempFolder: tempFolder,
+				Scanner:    tt.scanner,
+				Quarantine: quarantine,
+			}
+
+			msg := message.Message{Title: "Test", SourceURL: ts.URL + "/test.zip", SourceType: "zip"}
+			res, err := ig.Do(context.Background(), msg, nil)
+			if reflect.TypeOf(err) != reflect.TypeOf(tt.wantErr) {
+				t.Fatalf("Ingest.Do() error = %v, want %T", err, tt.wantErr)
+			}
+
+			if err == nil && res.Timings["scan"] == 0 {
+				t.Error("Ingest.Do() did not record the scan")
+			}
+
+			quarantined, _ := ioutil.ReadDir(quarantine)
+			if len(quarantined) != tt.wantQuarantine {
+				t.Errorf("Ingest.Do() quarantined %v files, want %v", len(quarantined), tt.wantQuarantine)
+			}
+			if malware, ok := err.(*source.MalwareError); ok {
+				if _, statErr := os.Stat(malware.Path); statErr != nil || filepath.Dir(malware.Path) != filepath.Clean(quarantine) {
+					t.Errorf("Ingest.Do() quarantined archive at %v", malware.Path)
+				}
+			}
+		})
+	}
+}
+
+func TestIngest_Do_Cache(t *testing.T) {
+	tempFolder := "./testdata/cache"
+	os.MkdirAll(tempFolder, os.ModePerm)
+	defer os.RemoveAll(tempFolder)
+
+	ig := &Ingest{
+		TempFolder: tempFolder,
+		Cache:      &source.MemoryCache{},
+	}
+	msg := message.Message{Title: "Test", SourceURL: ts.URL + "/test.zip", SourceType: "zip"}
+
+	first, err := ig.Do(context.Background(), msg, nil)
+	if err != nil {
+		t.Fatalf("Ingest.Do() error = %v", err)
+	}
+	if first.Timings["download"] == 0 {
+		t.Errorf("Ingest.Do() timings = %v, want a download", first.Timings)
+	}
+
+	// The same version is restored from the cache.
+	os.RemoveAll(first.FilesPath)
+
+	second, err := ig.Do(context.Background(), msg, nil)
+	if err != nil {
+		t.Fatalf("Ingest.Do() error = %v", err)
+	}
+	if _, ok := second.Timings["download"]; ok {
+		t.Errorf("Ingest.Do() timings = %v, want no download", second.Timings)
+	}
+	if second.Checksum != first.Checksum || !reflect.DeepEqual(second.Files, first.Files) {
+		t.Errorf("Ingest.Do() checksum = %v, files = %v, want %v, %v", second.Checksum, second.Files, first.Checksum, first.Files)
+	}
+	for _, file := range second.Files {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("Ingest.Do() did not restore %v", file)
+		}
+	}
+}
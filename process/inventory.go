@@ -0,0 +1,153 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+var (
+	// Hooks that are registered or declared with a literal name, e.g. `add_action( 'init', ... )`.
+	hookRe = regexp.MustCompile(`\b(add_action|add_filter|do_action_ref_array|do_action|apply_filters_ref_array|apply_filters)\s*\(\s*['"]([^'"]+)['"]`)
+	// Shortcodes, e.g. `add_shortcode( 'gallery', ... )`.
+	shortcodeRe = regexp.MustCompile(`\badd_shortcode\s*\(\s*['"]([^'"]+)['"]`)
+	// REST routes, e.g. `register_rest_route( 'myplugin/v1', '/items', ... )`.
+	restRouteRe = regexp.MustCompile(`\bregister_rest_route\s*\(\s*['"]([^'"]+)['"]\s*,\s*['"]([^'"]+)['"]`)
+	// WP-CLI commands, e.g. `WP_CLI::add_command( 'myplugin', ... )`.
+	commandRe = regexp.MustCompile(`\bWP_CLI\s*::\s*add_command\s*\(\s*['"]([^'"]+)['"]`)
+	// Recurring cron events, e.g. `wp_schedule_event( time(), 'daily', 'myplugin_cron' )`.
+	cronRe = regexp.MustCompile(`\bwp_schedule_event\s*\([^,;]+,\s*['"]([^'"]+)['"]\s*,\s*['"]([^'"]+)['"]`)
+	// Single cron events, e.g. `wp_schedule_single_event( time() + 60, 'myplugin_once' )`.
+	singleCronRe = regexp.MustCompile(`\bwp_schedule_single_event\s*\([^,;]+,\s*['"]([^'"]+)['"]`)
+)
+
+// Declaration is a hook, shortcode, REST route, command or cron event declared in the source.
+type Declaration struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"` // The hook function (e.g. "add_action") or cron recurrence.
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// InventoryReport lists the declarations of a project.
+type InventoryReport struct {
+	Hooks      []Declaration `json:"hooks"`
+	Shortcodes []Declaration `json:"shortcodes"`
+	RestRoutes []Declaration `json:"rest_routes"`
+	Commands   []Declaration `json:"commands"`
+	CronEvents []Declaration `json:"cron_events"`
+}
+
+// Inventory defines the structure for our Inventory process.
+// It records the hooks, shortcodes, REST routes, WP-CLI commands and cron events declared by a project.
+// Only declarations with literal names are recorded.
+type Inventory struct {
+	Process                  // Inherits methods from Process.
+	In      <-chan Processor // Expects a processor channel as input.
+	Out     chan Processor   // Send results to an output channel.
+}
+
+// Run executes the process in a pipe.
+func (inv *Inventory) Run(sink ErrorSink) error {
+	if inv.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if inv.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	inv.runStage(sink, stage{name: "Inventory", in: inv.In, out: inv.Out, do: inv.Do})
+
+	return nil
+}
+
+// Do extracts the declarations from the PHP files of the result and records them in the result.
+func (inv *Inventory) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if res == nil || res.FilesPath == "" {
+		return res, errors.New("could not determine files path")
+	}
+
+	log.Log(msg.Title, "Building inventory...")
+
+	source, err := loadSource(res, pathFilter{})
+	if err != nil {
+		return res, err
+	}
+
+	report := &InventoryReport{
+		Hooks:      []Declaration{},
+		Shortcodes: []Declaration{},
+		RestRoutes: []Declaration{},
+		Commands:   []Declaration{},
+		CronEvents: []Declaration{},
+	}
+
+	for file, src := range source.Files {
+		findDeclarations(src, file, hookRe, &report.Hooks, func(m []string) (string, string) {
+			return m[2], m[1]
+		})
+		findDeclarations(src, file, shortcodeRe, &report.Shortcodes, func(m []string) (string, string) {
+			return m[1], ""
+		})
+		findDeclarations(src, file, restRouteRe, &report.RestRoutes, func(m []string) (string, string) {
+			return "/" + strings.Trim(m[1], "/") + "/" + strings.TrimLeft(m[2], "/"), ""
+		})
+		findDeclarations(src, file, commandRe, &report.Commands, func(m []string) (string, string) {
+			return m[1], ""
+		})
+		findDeclarations(src, file, cronRe, &report.CronEvents, func(m []string) (string, string) {
+			return m[2], m[1]
+		})
+		findDeclarations(src, file, singleCronRe, &report.CronEvents, func(m []string) (string, string) {
+			return m[1], "single"
+		})
+	}
+
+	for _, declarations := range [][]Declaration{report.Hooks, report.Shortcodes, report.RestRoutes, report.Commands, report.CronEvents} {
+		sortDeclarations(declarations)
+	}
+
+	res.Inventory = report
+
+	log.Log(msg.Title, fmt.Sprintf("inventory found %d hooks, %d shortcodes, %d REST routes, %d commands and %d cron events",
+		len(report.Hooks), len(report.Shortcodes), len(report.RestRoutes), len(report.Commands), len(report.CronEvents)))
+
+	return res, nil
+}
+
+// findDeclarations appends a declaration for every match of re in src. The name and type
+// of the declaration are taken from the submatches.
+func findDeclarations(src, file string, re *regexp.Regexp, declarations *[]Declaration, fields func(m []string) (string, string)) {
+	for _, idx := range re.FindAllStringSubmatchIndex(src, -1) {
+		m := make([]string, len(idx)/2)
+		for i := range m {
+			if idx[2*i] != -1 {
+				m[i] = src[idx[2*i]:idx[2*i+1]]
+			}
+		}
+
+		name, kind := fields(m)
+		*declarations = append(*declarations, Declaration{
+			Name: name,
+			Type: kind,
+			File: file,
+			Line: strings.Count(src[:idx[0]], "\n") + 1,
+		})
+	}
+}
+
+// sortDeclarations orders declarations by file and line.
+func sortDeclarations(declarations []Declaration) {
+	sort.Slice(declarations, func(i, j int) bool {
+		if declarations[i].File != declarations[j].File {
+			return declarations[i].File < declarations[j].File
+		}
+		return declarations[i].Line < declarations[j].Line
+	})
+}
@@ -0,0 +1,147 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+func TestInventory_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		inv     *Inventory
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Inventory{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+			},
+			false,
+		},
+		{
+			"No In Channel",
+			&Inventory{
+				Out: make(chan Processor),
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Inventory{
+				In: make(chan Processor),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.inv.SetContext(ctx)
+
+			if err := tt.inv.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInventory_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	files := []string{
+		"./testdata/inventory/unzipped/plugin.php",
+		"./testdata/inventory/unzipped/includes/cli.php",
+	}
+
+	tests := []struct {
+		name    string
+		res     *Result
+		want    *InventoryReport
+		wantErr bool
+	}{
+		{
+			"Plugin",
+			&Result{FilesPath: "./testdata/inventory", Files: files},
+			&InventoryReport{
+				Hooks: []Declaration{
+					{"init", "add_action", "plugin.php", 6},
+					{"the_content", "add_filter", "plugin.php", 7},
+					{"itp_loaded", "do_action", "plugin.php", 12},
+					{"itp_content", "apply_filters", "plugin.php", 16},
+					{"rest_api_init", "add_action", "plugin.php", 19},
+				},
+				Shortcodes: []Declaration{
+					{"itp_gallery", "", "plugin.php", 11},
+				},
+				RestRoutes: []Declaration{
+					{"/itp/v1/items", "", "plugin.php", 20},
+				},
+				Commands: []Declaration{
+					{"itp", "", "includes/cli.php", 4},
+				},
+				CronEvents: []Declaration{
+					{"itp_daily", "daily", "plugin.php", 27},
+					{"itp_once", "single", "plugin.php", 29},
+				},
+			},
+			false,
+		},
+		{
+			"No Files",
+			&Result{FilesPath: "./testdata/inventory"},
+			&InventoryReport{
+				Hooks:      []Declaration{},
+				Shortcodes: []Declaration{},
+				RestRoutes: []Declaration{},
+				Commands:   []Declaration{},
+				CronEvents: []Declaration{},
+			},
+			false,
+		},
+		{
+			"No Files Path",
+			&Result{Files: files},
+			nil,
+			true,
+		},
+		{
+			"No Result",
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Missing File",
+			&Result{FilesPath: "./testdata/inventory", Files: []string{"./testdata/inventory/unzipped/missing.php"}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv := &Inventory{}
+			res, err := inv.Do(context.Background(), message.Message{Title: "Inventory Test Plugin"}, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Inventory.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(res.Inventory, tt.want) {
+				t.Errorf("Inventory.Do() = %+v, want %+v", res.Inventory, tt.want)
+			}
+		})
+	}
+}
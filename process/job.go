@@ -0,0 +1,130 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Job is the state of a single message as it passes through the pipeline: the message, its
+// result, the path of its files and the context it is processed in. Processes send a job to
+// the next process instead of themselves, so that they keep no state of the messages they
+// sent and the next message cannot change the state of a message that is still being
+// processed downstream. The lease of a message received by a Consumer travels with the message
+// of its job until Response settles it, see message.Lease.
+//
+// Job implements Processor, so processes that pass themselves, e.g. custom processes, and
+// processes that pass jobs can be used in the same pipeline.
+type Job struct {
+	Process
+}
+
+// NewJob returns a job for the message and its result.
+func NewJob(ctx context.Context, msg message.Message, res *Result) *Job {
+	job := &Job{}
+	job.SetContext(ctx)
+	job.SetMessage(msg)
+	job.SetResults(res)
+	if res != nil {
+		job.SetFilesPath(res.FilesPath)
+	}
+	return job
+}
+
+// jobFor returns the job of a processor that was received from the previous process, to be
+// processed in the context. Jobs are passed on as they are, the state of other processors is
+// copied to a new job.
+func jobFor(ctx context.Context, proc Processor) *Job {
+	job, ok := proc.(*Job)
+	if !ok {
+		job = &Job{}
+		job.CopyFields(proc)
+	}
+	job.SetContext(ctx)
+	return job
+}
+
+// Context returns the context the job is processed in.
+func (j *Job) Context() context.Context {
+	return j.getContext()
+}
+
+// Run implements Processor. A job only carries state, it cannot run as a process.
+func (j *Job) Run(sink ErrorSink) error {
+	return errors.New("a job cannot run as a process")
+}
+
+// Do implements Processor and returns the result unchanged.
+func (j *Job) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	return res, nil
+}
+
+// doFunc processes the message of a job, usually the Do method of a process.
+type doFunc func(ctx context.Context, msg message.Message, res *Result) (*Result, error)
+
+// stage describes how a process runs as a stage of a pipeline, see Process.runStage.
+type stage struct {
+	name string           // Name of the process in errors, e.g. "PHPCS". The timings and trail use it in lower case.
+	in   <-chan Processor // Receives the jobs from the previous process.
+	out  chan Processor   // Sends the jobs to the next process. Jobs are dropped if nil.
+	do   doFunc           // Processes the message of each job.
+	last bool             // Processes the messages that an earlier stage aborted as well, e.g. to send their errors.
+}
+
+// runStage starts the process as a stage of a pipeline. Each job received from the previous
+// process is processed as a job of its own. Errors are recorded against the result and reported
+// to the sink, and the job is still sent to the next process, since the message is still useful
+// to the other processes. Messages that an earlier stage aborted are passed on as they are,
+// unless the stage is one of the last ones, e.g. Response. The out channel is closed once the previous process stopped or the
+// pipeline was cancelled.
+func (p *Process) runStage(sink ErrorSink, st stage) {
+	p.start()
+
+	go func() {
+		// Close the out channel and signal that we are done when the goroutine exits.
+		defer p.stop(st.out)
+		p.serve(sink, st)
+	}()
+}
+
+// serve processes the jobs of the stage until the previous process stops or the pipeline is
+// cancelled. Several goroutines may serve the same stage.
+func (p *Process) serve(sink ErrorSink, st stage) {
+	for {
+		select {
+		case <-p.getContext().Done():
+			// The pipeline has been cancelled.
+			return
+
+		case in, ok := <-st.in:
+			// The previous process has stopped.
+			if !ok {
+				return
+			}
+
+			if !p.process(sink, st, jobFor(p.getContext(), in)) {
+				return
+			}
+		}
+	}
+}
+
+// process runs the stage for the job and sends it to the next process. It returns false if the
+// pipeline was cancelled before the next process received the job.
+func (p *Process) process(sink ErrorSink, st stage, job *Job) bool {
+	if job.Result != nil && job.Result.aborted && !st.last {
+		return st.out == nil || p.send(st.out, job)
+	}
+
+	res, err := st.do(job.input())
+	if err != nil {
+		// Record the error against the message and pass it to the error sink.
+		reportError(sink, res, NewError(st.name, job.Message, err))
+	}
+
+	job.output(strings.ToLower(st.name), res)
+
+	return st.out == nil || p.send(st.out, job)
+}
@@ -0,0 +1,167 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+)
+
+func TestNewJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	msg := message.Message{Title: "Test Plugin"}
+	res := &Result{FilesPath: "/tmp/abc123"}
+
+	job := NewJob(ctx, msg, res)
+
+	if !reflect.DeepEqual(job.GetMessage(), msg) {
+		t.Errorf("NewJob() message = %v, want %v", job.GetMessage(), msg)
+	}
+	if job.GetResult() != res {
+		t.Errorf("NewJob() result = %v, want %v", job.GetResult(), res)
+	}
+	if job.GetFilesPath() != "/tmp/abc123" {
+		t.Errorf("NewJob() files path = %v", job.GetFilesPath())
+	}
+	if job.Context() != ctx {
+		t.Errorf("NewJob() context = %v, want %v", job.Context(), ctx)
+	}
+	if err := job.Run(nil); err == nil {
+		t.Errorf("Job.Run() error = nil, want an error")
+	}
+}
+
+func Test_jobFor(t *testing.T) {
+	ctx := context.Background()
+	res := &Result{FilesPath: "/tmp/abc123"}
+	job := NewJob(context.TODO(), message.Message{Title: "Job"}, res)
+
+	// Jobs are passed on as they are, in the context of the process.
+	if got := jobFor(ctx, job); got != job || got.Context() != ctx {
+		t.Errorf("jobFor() = %v, want the same job in the context", got)
+	}
+
+	// The state of other processors is copied, so they can process the next message.
+	proc := &PhpLint{}
+	proc.SetMessage(message.Message{Title: "Processor"})
+	proc.SetResults(res)
+	proc.SetFilesPath("/tmp/abc123")
+
+	got := jobFor(ctx, proc)
+	if got.Message.Title != "Processor" || got.Result != res || got.FilesPath != "/tmp/abc123" {
+		t.Errorf("jobFor() = %v, want a copy of the processor state", got)
+	}
+
+	proc.SetMessage(message.Message{Title: "Next"})
+	if got.Message.Title != "Processor" {
+		t.Errorf("jobFor() job changed with the processor: %v", got.Message)
+	}
+}
+
+func TestProcess_SendsJobs(t *testing.T) {
+	in := make(chan Processor)
+	out := make(chan Processor)
+	lint := &PhpLint{In: in, Out: out}
+	if err := lint.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for _, title := range []string{"First", "Second"} {
+			in <- NewJob(context.Background(), message.Message{Title: title}, NewResult())
+		}
+		close(in)
+	}()
+
+	var titles []string
+	for proc := range out {
+		job, ok := proc.(*Job)
+		if !ok {
+			t.Fatalf("PhpLint sent %T, want a *Job", proc)
+		}
+		titles = append(titles, job.Message.Title)
+	}
+
+	if !reflect.DeepEqual(titles, []string{"First", "Second"}) {
+		t.Errorf("PhpLint sent %v", titles)
+	}
+
+	// The process keeps no state of the messages it sent.
+	if lint.Message.Title != "" || lint.Result != nil {
+		t.Errorf("PhpLint kept the state of a message: %v, %v", lint.Message, lint.Result)
+	}
+}
+
+func TestProcess_runStage(t *testing.T) {
+	in := make(chan Processor)
+	out := make(chan Processor)
+	errc := make(ErrorChannel, 1)
+
+	p := &Process{}
+	p.runStage(errc, stage{name: "Test", in: in, out: out, do: func(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+		return res, errors.New("something went wrong")
+	}})
+
+	go func() {
+		in <- NewJob(context.Background(), message.Message{Title: "Failing"}, NewResult())
+		close(in)
+	}()
+
+	// Jobs are sent on with their errors.
+	job := (<-out).(*Job)
+	if len(job.Result.Errors) != 1 || job.Result.Errors[0].Process != "Test" {
+		t.Errorf("runStage() errors = %v, want the error of the stage", job.Result.Errors)
+	}
+	if _, ok := job.Result.Timings["test"]; !ok {
+		t.Errorf("runStage() timings = %v, want the stage", job.Result.Timings)
+	}
+	if err := <-errc; err.Title != "Failing" {
+		t.Errorf("runStage() reported %v", err)
+	}
+
+	// The out channel is closed once the previous process stopped.
+	if _, ok := <-out; ok {
+		t.Errorf("runStage() sent a job after the previous process stopped")
+	}
+	<-p.Done()
+}
+
+func TestProcess_runStage_Aborted(t *testing.T) {
+	tests := []struct {
+		name    string
+		last    bool
+		wantRan bool
+	}{
+		{"Audit Stage", false, false},
+		{"Last Stage", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan Processor, 1)
+			out := make(chan Processor, 1)
+
+			ran := false
+			p := &Process{}
+			p.runStage(nil, stage{name: "Test", in: in, out: out, last: tt.last, do: func(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+				ran = true
+				return res, nil
+			}})
+
+			res := NewResult()
+			res.abort()
+			in <- NewJob(context.Background(), message.Message{Title: "Aborted"}, res)
+			close(in)
+
+			if job := (<-out).(*Job); job.Result != res {
+				t.Errorf("runStage() sent %v, want the aborted job", job.Result)
+			}
+			<-p.Done()
+			if ran != tt.wantRan {
+				t.Errorf("runStage() processed the aborted message = %v, want %v", ran, tt.wantRan)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package process
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/wptide/pkg/message"
+)
+
+// Finding types raised for bundled libraries.
+const (
+	FindingLicense    = "license"
+	FindingVulnerable = "vulnerable"
+)
+
+// Library sources.
+const (
+	LibraryComposer = "composer.lock"
+	LibraryPackage  = "package.json"
+	LibraryHeader   = "header"
+)
+
+// libraryHeaderLen is the number of bytes checked for library headers.
+const libraryHeaderLen = 2048
+
+// Library is a third-party library bundled with a project.
+type Library struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	License string `json:"license,omitempty"` // SPDX license expression, if known.
+	Source  string `json:"source"`            // How the library was detected.
+	File    string `json:"file"`
+}
+
+// LibraryFingerprint detects a library from the header of a bundled file.
+type LibraryFingerprint struct {
+	Name    string
+	License string
+	Pattern *regexp.Regexp // The first submatch, if any, is the version.
+}
+
+// Vulnerability is a known vulnerability affecting library versions below Fixed.
+type Vulnerability struct {
+	Fixed    string
+	Advisory string
+}
+
+// LibraryFingerprints are the headers of commonly bundled libraries.
+var LibraryFingerprints = []LibraryFingerprint{
+	{"jquery", "MIT", regexp.MustCompile(`jQuery (?:JavaScript Library )?v(\d+\.\d+\.\d+)`)},
+	{"bootstrap", "MIT", regexp.MustCompile(`Bootstrap v(\d+\.\d+\.\d+)`)},
+	{"moment", "MIT", regexp.MustCompile(`//! moment\.js\s+//! version : (\d+\.\d+\.\d+)`)},
+	{"select2", "MIT", regexp.MustCompile(`Select2 (\d+\.\d+\.\d+)`)},
+	{"highcharts", "proprietary", regexp.MustCompile(`Highcharts JS v(\d+\.\d+\.\d+)`)},
+	{"phpmailer/phpmailer", "LGPL-2.1", regexp.MustCompile(`PHPMailer - PHP email (?:creation and )?transport class`)},
+	{"tecnickcom/tcpdf", "LGPL-3.0", regexp.MustCompile(`File name\s*:\s*tcpdf\.php\s*//\s*Version\s*:\s*(\d+\.\d+\.\d+)`)},
+}
+
+// VulnerableLibraries maps library names to their known vulnerabilities.
+var VulnerableLibraries = map[string][]Vulnerability{
+	"jquery":              {{"3.5.0", "CVE-2020-11022"}},
+	"bootstrap":           {{"3.4.1", "CVE-2019-8331"}},
+	"lodash":              {{"4.17.21", "CVE-2021-23337"}},
+	"moment":              {{"2.29.4", "CVE-2022-31129"}},
+	"phpmailer/phpmailer": {{"6.5.0", "CVE-2021-34551"}},
+	"tecnickcom/tcpdf":    {{"6.2.22", "CVE-2018-17057"}},
+}
+
+// gplCompatible lists SPDX licenses that can be distributed with GPL projects.
+var gplCompatible = map[string]bool{
+	"0BSD":         true,
+	"APACHE-2.0":   true,
+	"ARTISTIC-2.0": true,
+	"BSD-2-CLAUSE": true,
+	"BSD-3-CLAUSE": true,
+	"CC0-1.0":      true,
+	"GPL-2.0":      true,
+	"GPL-2.0+":     true,
+	"GPL-3.0":      true,
+	"GPL-3.0+":     true,
+	"ISC":          true,
+	"LGPL-2.1":     true,
+	"LGPL-2.1+":    true,
+	"LGPL-3.0":     true,
+	"LGPL-3.0+":    true,
+	"MIT":          true,
+	"MPL-2.0":      true,
+	"UNLICENSE":    true,
+	"WTFPL":        true,
+	"X11":          true,
+	"ZLIB":         true,
+}
+
+// CheckLibraries flags the third-party libraries bundled with a project that are not GPL
+// compatible or have known vulnerabilities. It checks the libraries recorded in the result by
+// Compliance, or finds them if there are none.
+func CheckLibraries(msg message.Message, res *Result, source *Source) []Finding {
+	libraries := res.Libraries
+	if libraries == nil {
+		libraries = bundledLibraries(res)
+	}
+
+	var findings []Finding
+	for _, lib := range libraries {
+		if lib.License != "" && !isGPLCompatible(lib.License) {
+			findings = append(findings, Finding{
+				Type:     FindingLicense,
+				File:     lib.File,
+				Message:  "library " + lib.Name + " is licensed under " + lib.License + ", which is not GPL compatible",
+				Severity: FindingWarning,
+			})
+		}
+
+		for _, advisory := range vulnerabilities(lib) {
+			findings = append(findings, Finding{
+				Type:     FindingVulnerable,
+				File:     lib.File,
+				Message:  "library " + lib.Name + " " + lib.Version + " is affected by " + advisory,
+				Severity: FindingWarning,
+			})
+		}
+	}
+
+	return findings
+}
+
+// bundledLibraries returns the third-party libraries bundled with a project, ordered by name.
+func bundledLibraries(res *Result) []Library {
+	root := res.FilesPath + "/unzipped"
+
+	binary := make(map[string]bool)
+	for _, file := range res.BinaryFiles {
+		binary[file] = true
+	}
+
+	libraries := []Library{}
+	for _, file := range res.Files {
+		if binary[file] {
+			continue
+		}
+
+		name := relativeName(root, file)
+		switch path.Base(name) {
+		case "composer.lock":
+			libraries = append(libraries, composerLibraries(name, readAll(file))...)
+		case "package.json":
+			libraries = append(libraries, packageLibraries(name, readAll(file))...)
+		default:
+			switch strings.ToLower(path.Ext(name)) {
+			case ".js", ".css", ".php":
+				libraries = append(libraries, headerLibraries(name, readAll(file))...)
+			}
+		}
+	}
+
+	sort.SliceStable(libraries, func(i, j int) bool {
+		if libraries[i].Name != libraries[j].Name {
+			return libraries[i].Name < libraries[j].Name
+		}
+		return libraries[i].File < libraries[j].File
+	})
+
+	return libraries
+}
+
+// composerLibraries returns the packages installed with composer.
+func composerLibraries(file string, data []byte) []Library {
+	var lock struct {
+		Packages []struct {
+			Name    string   `json:"name"`
+			Version string   `json:"version"`
+			License []string `json:"license"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil
+	}
+
+	var libraries []Library
+	for _, pkg := range lock.Packages {
+		libraries = append(libraries, Library{
+			Name:    pkg.Name,
+			Version: strings.TrimPrefix(pkg.Version, "v"),
+			License: strings.Join(pkg.License, " OR "),
+			Source:  LibraryComposer,
+			File:    file,
+		})
+	}
+	return libraries
+}
+
+// packageLibraries returns the npm package if it is bundled in node_modules, or the
+// runtime dependencies of the project otherwise. Dependencies are version ranges rather than
+// the installed versions, so their versions are left out.
+func packageLibraries(file string, data []byte) []Library {
+	var pkg struct {
+		Name         string            `json:"name"`
+		Version      string            `json:"version"`
+		License      interface{}       `json:"license"`
+		Dependencies map[string]string `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil
+	}
+
+	if strings.Contains("/"+file, "/node_modules/") {
+		license, _ := pkg.License.(string)
+		return []Library{{
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			License: license,
+			Source:  LibraryPackage,
+			File:    file,
+		}}
+	}
+
+	var libraries []Library
+	for name := range pkg.Dependencies {
+		libraries = append(libraries, Library{
+			Name:   name,
+			Source: LibraryPackage,
+			File:   file,
+		})
+	}
+	return libraries
+}
+
+// headerLibraries returns the libraries identified by the header of a file.
+func headerLibraries(file string, data []byte) []Library {
+	if len(data) > libraryHeaderLen {
+		data = data[:libraryHeaderLen]
+	}
+
+	var libraries []Library
+	for _, fp := range LibraryFingerprints {
+		m := fp.Pattern.FindSubmatch(data)
+		if m == nil {
+			continue
+		}
+
+		lib := Library{Name: fp.Name, License: fp.License, Source: LibraryHeader, File: file}
+		if len(m) > 1 {
+			lib.Version = string(m[1])
+		}
+		libraries = append(libraries, lib)
+	}
+	return libraries
+}
+
+// isGPLCompatible checks a SPDX license expression for GPL compatibility. Alternatives
+// (OR) need one compatible license and combinations (AND) need all licenses to be compatible.
+func isGPLCompatible(expression string) bool {
+	expression = strings.ToUpper(strings.Trim(expression, "()"))
+
+	for _, alternative := range strings.Split(expression, " OR ") {
+		compatible := true
+		for _, license := range strings.Split(alternative, " AND ") {
+			license = strings.TrimSpace(strings.Trim(license, "()"))
+			license = strings.TrimSuffix(strings.TrimSuffix(license, "-ONLY"), "-OR-LATER")
+			if !gplCompatible[license] && !gplCompatible[strings.TrimSuffix(license, "+")] {
+				compatible = false
+				break
+			}
+		}
+		if compatible {
+			return true
+		}
+	}
+
+	return false
+}
+
+// vulnerabilities returns the advisories of the known vulnerabilities affecting the library.
+// Libraries without a parsable version are not checked.
+func vulnerabilities(lib Library) []string {
+	known, ok := VulnerableLibraries[strings.ToLower(lib.Name)]
+	if !ok {
+		return nil
+	}
+
+	version, err := semver.ParseTolerant(lib.Version)
+	if err != nil {
+		return nil
+	}
+
+	var advisories []string
+	for _, v := range known {
+		if fixed, err := semver.ParseTolerant(v.Fixed); err == nil && version.LT(fixed) {
+			advisories = append(advisories, v.Advisory)
+		}
+	}
+	return advisories
+}
@@ -0,0 +1,105 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+)
+
+func TestCheckLibraries(t *testing.T) {
+	root := "./testdata/compliance/libraries"
+	files := []string{
+		"composer.lock",
+		"package.json",
+		"node_modules/left-pad/package.json",
+		"js/jquery.min.js",
+		"js/charts.js",
+		"js/broken.json",
+	}
+
+	res := &Result{FilesPath: root}
+	for _, file := range files {
+		res.Files = append(res.Files, root+"/unzipped/"+file)
+	}
+
+	wantLibraries := []Library{
+		{"acme/commercial", "1.0.0", "proprietary", LibraryComposer, "composer.lock"},
+		{"guzzlehttp/guzzle", "7.4.5", "MIT", LibraryComposer, "composer.lock"},
+		{"highcharts", "9.0.0", "proprietary", LibraryHeader, "js/charts.js"},
+		{"jquery", "3.4.1", "MIT", LibraryHeader, "js/jquery.min.js"},
+		{"left-pad", "1.3.0", "WTFPL", LibraryPackage, "node_modules/left-pad/package.json"},
+		{"moment", "", "", LibraryPackage, "package.json"},
+		{"phpmailer/phpmailer", "6.1.6", "LGPL-2.1-only", LibraryComposer, "composer.lock"},
+	}
+
+	wantFindings := []Finding{
+		{"", FindingLicense, "composer.lock", "library acme/commercial is licensed under proprietary, which is not GPL compatible", FindingWarning},
+		{"", FindingLicense, "js/charts.js", "library highcharts is licensed under proprietary, which is not GPL compatible", FindingWarning},
+		{"", FindingVulnerable, "js/jquery.min.js", "library jquery 3.4.1 is affected by CVE-2020-11022", FindingWarning},
+		{"", FindingVulnerable, "composer.lock", "library phpmailer/phpmailer 6.1.6 is affected by CVE-2021-34551", FindingWarning},
+	}
+
+	// The version range of a dependency is not checked for vulnerabilities.
+	got := CheckLibraries(message.Message{}, res, &Source{})
+	if !reflect.DeepEqual(got, wantFindings) {
+		t.Errorf("CheckLibraries() = %v, want %v", got, wantFindings)
+	}
+	if res.Libraries != nil {
+		t.Errorf("CheckLibraries() recorded libraries %v", res.Libraries)
+	}
+	if got := bundledLibraries(res); !reflect.DeepEqual(got, wantLibraries) {
+		t.Errorf("bundledLibraries() = %v, want %v", got, wantLibraries)
+	}
+
+	// The libraries recorded by Compliance are checked.
+	res.Libraries = []Library{}
+	if got := CheckLibraries(message.Message{}, res, &Source{}); got != nil {
+		t.Errorf("CheckLibraries() = %v, want none", got)
+	}
+}
+
+func Test_isGPLCompatible(t *testing.T) {
+	tests := []struct {
+		license string
+		want    bool
+	}{
+		{"MIT", true},
+		{"GPL-2.0-or-later", true},
+		{"GPL-3.0+", true},
+		{"lgpl-2.1-only", true},
+		{"proprietary", false},
+		{"CC-BY-NC-4.0", false},
+		{"(MIT OR proprietary)", true},
+		{"MIT AND CC-BY-NC-4.0", false},
+		{"(MIT AND BSD-3-Clause)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.license, func(t *testing.T) {
+			if got := isGPLCompatible(tt.license); got != tt.want {
+				t.Errorf("isGPLCompatible(%v) = %v, want %v", tt.license, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_vulnerabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		lib  Library
+		want []string
+	}{
+		{"Vulnerable", Library{Name: "jQuery", Version: "1.12.4"}, []string{"CVE-2020-11022"}},
+		{"Fixed", Library{Name: "jquery", Version: "3.5.0"}, nil},
+		{"Short Version", Library{Name: "bootstrap", Version: "3.3"}, []string{"CVE-2019-8331"}},
+		{"No Version", Library{Name: "jquery"}, nil},
+		{"Unknown Library", Library{Name: "left-pad", Version: "1.0.0"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vulnerabilities(tt.lib); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("vulnerabilities() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
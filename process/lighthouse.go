@@ -1,33 +1,42 @@
 package process
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/provision"
 	"github.com/wptide/pkg/shell"
 	"github.com/wptide/pkg/storage"
 	"github.com/wptide/pkg/tide"
 )
 
 var (
-	lhRunner      shell.Runner
+	lhRunner      shell.Runner = defaultRunner
 	defaultRunner shell.Runner = &shell.Command{}
 )
 
 // Lighthouse defines the structure for our Lighthouse process.
 type Lighthouse struct {
-	Process                          // Inherits methods from Process.
-	In              <-chan Processor // Expects a processor channel as input.
-	Out             chan Processor   // Send results to an output channel.
-	TempFolder      string           // Path to a temp folder where reports will be generated.
-	StorageProvider storage.Provider // Storage provider to upload reports to.
+	Process                               // Inherits methods from Process.
+	In              <-chan Processor      // Expects a processor channel as input.
+	Out             chan Processor        // Send results to an output channel.
+	TempFolder      string                // Path to a temp folder where reports will be generated.
+	StorageProvider storage.Provider      // Storage provider to upload reports to.
+	Provisioner     provision.Provisioner // (Optional) Provisions a demo environment instead of using the hosted theme demos.
+	PreviewURL      string                // (Optional) URL to audit instead of a demo, e.g. "https://preview.example.com/{slug}/". "{slug}" is replaced by the slug of the message.
+	MaxReportSize   int64                 // (Optional) Reports larger than this many bytes are uploaded in chunks.
+	Runner          shell.Runner          // (Optional) Runs Lighthouse. Defaults to shell.Command.
+	Command         string                // (Optional) Command that writes the JSON report of the url in its argument to stdout. Defaults to "lh".
+	Compress        bool                  // (Optional) Gzips reports before they are uploaded, see storage.GzipFile.
 }
 
 // Run runs the process in a pipeline.
-func (lh *Lighthouse) Run(errc *chan error) error {
+func (lh *Lighthouse) Run(sink ErrorSink) error {
 	if lh.TempFolder == "" {
 		return errors.New("no temp folder provided for lighthouse reports")
 	}
@@ -43,78 +52,73 @@ func (lh *Lighthouse) Run(errc *chan error) error {
 		return errors.New("requires a next process")
 	}
 
-	go func() {
-		for {
-			select {
-			case in := <-lh.In:
-
-				// Copy Process fields from `in` process.
-				lh.CopyFields(in)
-
-				// Assume that the rest of the message is also broken.
-				// Don't pass this down the pipe.
-				if lh.Message.Title == "" {
-					*errc <- errors.New("Lighthouse Error: " + lh.Error("invalid message").Error())
-					continue
-				}
-
-				// Run the process.
-				// If processing produces an error send it up the error channel.
-				for _, audit := range lh.Message.Audits {
-					if audit.Type == "lighthouse" {
-						if err := lh.Do(); err != nil {
-							// Pass the error up the error channel.
-							*errc <- errors.New("Lighthouse Error: " + err.Error())
-							// Don't break, the message is still useful to other processes.
-						}
-					}
-				}
-
-				// Send process to the out channel.
-				lh.Out <- lh
-			}
+	// Assume that the rest of a message without a title is also broken.
+	do := func(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+		if msg.Title == "" {
+			res.abort()
+			return res, messageError(msg, "invalid message")
 		}
-
-	}()
+		return lh.Do(ctx, msg, res)
+	}
+	lh.runStage(sink, stage{name: "Lighthouse", in: lh.In, out: lh.Out, do: do})
 
 	return nil
 }
 
-// Do executes the process.
-func (lh *Lighthouse) Do() error {
-	log.Log(lh.Message.Title, "Running Lighthouse Audit...")
+// Do runs a Lighthouse audit if the message requests one and returns the result with the audit added.
+func (lh *Lighthouse) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if !hasAudit(msg, "lighthouse") {
+		return res, nil
+	}
+
+	log.Log(msg.Title, "Running Lighthouse Audit...")
 
-	if lhRunner == nil {
-		lhRunner = defaultRunner
+	runner := lhRunner
+	if lh.Runner != nil {
+		runner = lh.Runner
 	}
 
 	var results *tide.LighthouseSummary
 
-	// Note: This assumes the shell script `lh` is in $PATH and contains the following command:
+	url, teardown, err := lh.previewURL(ctx, msg, res)
+	if err != nil {
+		return res, err
+	}
+	defer teardown()
+
+	// Note: By default this assumes the shell script `lh` is in $PATH and contains the following command:
 	// `lighthouse --quiet --chrome-flags="--headless --disable-gpu --no-sandbox" --output=json --output-path=stdout $@`
-	cmdName := "lh"
-	cmdArgs := []string{fmt.Sprintf("https://wp-themes.com/%s", lh.Message.Slug)}
+	cmdName := lh.Command
+	if cmdName == "" {
+		cmdName = "lh"
+	}
+	cmdArgs := []string{url}
 
 	// Prepare the command and set the stdOut pipe.
-	resultBytes, errorBytes, _, err := lhRunner.Run(cmdName, cmdArgs...)
+	resultBytes, errorBytes, _, err := runCommand(ctx, res, runner, cmdName, cmdArgs...)
 
 	if len(errorBytes) > 0 {
-		return lh.Error("lighthouse command failed: " + string(errorBytes))
+		return res, messageError(msg, "lighthouse command failed: "+string(errorBytes))
+	}
+	if err != nil && len(resultBytes) == 0 {
+		return res, messageError(msg, "lighthouse command failed: "+err.Error())
 	}
 
 	// Unmarshal the body response into a LightHouseReport object.
 	err = json.Unmarshal(resultBytes, &results)
 	if err != nil {
-		return err
+		return res, err
 	}
 
 	auditResult := tide.AuditResult{}
 
 	// Upload and get full results.
-	log.Log(lh.Message.Title, "Uploading results to remote storage.")
-	rawResults, err := lh.uploadToStorage(resultBytes)
+	log.Log(msg.Title, "Uploading results to remote storage.")
+	done := res.timeStage("upload")
+	rawResults, err := lh.uploadToStorage(reportContext(ctx, msg, "lighthouse"), res, resultBytes)
+	done()
 	if err != nil {
-		return err
+		return res, err
 	}
 
 	if rawResults != nil {
@@ -125,26 +129,29 @@ func (lh *Lighthouse) Do() error {
 		LighthouseSummary: results,
 	}
 
-	result := *lh.Result
-	result["lighthouse"] = auditResult
-	lh.Result = &result
+	res.SetAudit("lighthouse", auditResult)
 
-	log.Log(lh.Message.Title, "Lighthouse process complete.")
+	log.Log(msg.Title, "Lighthouse process complete.")
 
-	return nil
+	return res, nil
 }
 
-func (lh Lighthouse) uploadToStorage(buffer []byte) (*tide.AuditResult, error) {
+// previewURL returns the URL to audit and a function to clean up afterwards: the PreviewURL
+// of the slug if there is one, or else a demo.
+func (lh Lighthouse) previewURL(ctx context.Context, msg message.Message, res *Result) (string, func(), error) {
+	if lh.PreviewURL != "" {
+		return strings.Replace(lh.PreviewURL, "{slug}", url.PathEscape(msg.Slug), -1), func() {}, nil
+	}
+	return demoURL(ctx, lh.Provisioner, msg, res)
+}
 
-	var results *tide.AuditResult
+func (lh Lighthouse) uploadToStorage(ctx context.Context, res *Result, buffer []byte) (*tide.AuditResult, error) {
 
-	result := *lh.Result
-	checksum, checksumOk := result["checksum"].(string)
-	if !checksumOk {
+	if res == nil || res.Checksum == "" {
 		return nil, errors.New("there was no checksum to be used for filenames")
 	}
 
-	storageRef := checksum + "-lighthouse-raw.json"
+	storageRef := res.Checksum + "-lighthouse-raw.json"
 	filename := strings.TrimRight(lh.TempFolder, "/") + "/" + storageRef
 
 	err := writeFile(filename, buffer, 0644)
@@ -152,17 +159,12 @@ func (lh Lighthouse) uploadToStorage(buffer []byte) (*tide.AuditResult, error) {
 		return nil, errors.New("could not write lighthouse audit to tempFolder")
 	}
 
-	err = lh.StorageProvider.UploadFile(filename, storageRef)
-
-	if err == nil {
-		results = &tide.AuditResult{
-			Raw: tide.AuditDetails{
-				Type:     lh.StorageProvider.Kind(),
-				FileName: storageRef,
-				Path:     lh.StorageProvider.CollectionRef(),
-			},
-		}
+	raw, err := uploadReport(ctx, meterStorage(lh.StorageProvider, res), filename, storageRef, lh.MaxReportSize, lh.Compress)
+	if err != nil {
+		return nil, err
 	}
 
-	return results, err
+	return &tide.AuditResult{
+		Raw: raw,
+	}, nil
 }
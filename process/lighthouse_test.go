@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -160,7 +161,7 @@ func TestLighthouse_Run(t *testing.T) {
 							Audits: audits,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 					},
 				},
@@ -182,7 +183,7 @@ func TestLighthouse_Run(t *testing.T) {
 					Process: Process{
 						Message: message.Message{},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 					},
 				},
@@ -232,7 +233,7 @@ func TestLighthouse_Run(t *testing.T) {
 							Audits: audits,
 						},
 						Result: &Result{
-							"checksum": "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+							Checksum: "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
 						},
 					},
 				},
@@ -258,7 +259,7 @@ func TestLighthouse_Run(t *testing.T) {
 							Audits: audits,
 						},
 						Result: &Result{
-							"checksum": "1234567890",
+							Checksum: "1234567890",
 						},
 					},
 				},
@@ -284,7 +285,7 @@ func TestLighthouse_Run(t *testing.T) {
 							Audits: audits,
 						},
 						Result: &Result{
-							"checksum": "1234567890",
+							Checksum: "1234567890",
 						},
 					},
 				},
@@ -314,7 +315,7 @@ func TestLighthouse_Run(t *testing.T) {
 							},
 						},
 						Result: &Result{
-							"checksum": "1234567890",
+							Checksum: "1234567890",
 						},
 					},
 				},
@@ -352,7 +353,7 @@ func TestLighthouse_Run(t *testing.T) {
 							Audits: audits,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 					},
 				},
@@ -380,7 +381,7 @@ func TestLighthouse_Run(t *testing.T) {
 
 			if !tt.mockRunner {
 				oldRunner := lhRunner
-				lhRunner = nil
+				lhRunner = defaultRunner
 				defer func() {
 					lhRunner = oldRunner
 				}()
@@ -388,7 +389,7 @@ func TestLighthouse_Run(t *testing.T) {
 
 			var err error
 			var chanError error
-			errc := make(chan error)
+			errc := make(ErrorChannel)
 
 			go func() {
 				for {
@@ -400,7 +401,7 @@ func TestLighthouse_Run(t *testing.T) {
 			}()
 
 			go func() {
-				err = lh.Run(&errc)
+				err = lh.Run(errc)
 			}()
 
 			// Sleep a short time delay to give process time to start.
@@ -418,6 +419,75 @@ func TestLighthouse_Run(t *testing.T) {
 	}
 }
 
+func TestLighthouse_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	os.MkdirAll("./testdata/tmp", os.ModePerm)
+	defer os.RemoveAll("./testdata/tmp")
+	os.MkdirAll("./testdata/upload", os.ModePerm)
+	defer os.RemoveAll("./testdata/upload")
+
+	msg := message.Message{Title: "Test", Slug: "my theme", Audits: []*message.Audit{{Type: "lighthouse"}}}
+
+	tests := []struct {
+		name        string
+		lh          Lighthouse
+		fail        bool
+		wantCommand []string
+		wantErr     bool
+	}{
+		{
+			"Hosted Demo",
+			Lighthouse{},
+			false,
+			[]string{"lh", "https://wp-themes.com/my theme"},
+			false,
+		},
+		{
+			"Preview URL",
+			Lighthouse{PreviewURL: "https://preview.example.com/{slug}/", Command: "lighthouse-json"},
+			false,
+			[]string{"lighthouse-json", "https://preview.example.com/my%20theme/"},
+			false,
+		},
+		{
+			"Command Error",
+			Lighthouse{PreviewURL: "https://preview.example.com/"},
+			true,
+			[]string{"lh", "https://preview.example.com/"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := exampleLighthouseReport()
+			runner := &recordingRunner{output: map[string]string{"lh": report, "lighthouse-json": report}, fail: tt.fail}
+			lh := tt.lh
+			lh.TempFolder = "./testdata/tmp"
+			lh.StorageProvider = &mockStorage{}
+			lh.Runner = runner
+
+			res, err := lh.Do(context.Background(), msg, &Result{Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Lighthouse.Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(runner.commands) != 1 || !reflect.DeepEqual(runner.commands[0], tt.wantCommand) {
+				t.Errorf("Lighthouse.Do() commands = %v, want %v", runner.commands, tt.wantCommand)
+			}
+
+			if !tt.wantErr {
+				audit, ok := res.Audits["lighthouse"]
+				if !ok || audit.Summary.LighthouseSummary == nil {
+					t.Errorf("Lighthouse.Do() audit = %v", audit)
+				}
+			}
+		})
+	}
+}
+
 func exampleLighthouseReport() string {
 	return `{
   "reportCategories": [
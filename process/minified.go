@@ -0,0 +1,200 @@
+package process
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Finding types raised for minified assets.
+const (
+	FindingMinified  = "minified"
+	FindingSourceMap = "sourcemap"
+)
+
+// minifiedLineLength is the average line length above which an asset is considered minified.
+const minifiedLineLength = 500
+
+// sourceMappingRe finds source map references, e.g. `//# sourceMappingURL=app.min.js.map`.
+var sourceMappingRe = regexp.MustCompile(`[#@]\s*sourceMappingURL=(\S+?)\s*(?:\*/)?\s*$`)
+
+// sourceMap contains the fields of a source map that are validated.
+type sourceMap struct {
+	Version        int      `json:"version"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent"`
+}
+
+// CheckMinified checks that minified JS and CSS assets are shipped with their source files
+// or a valid source map, as required by the plugin and theme directories.
+func CheckMinified(msg message.Message, res *Result, source *Source) []Finding {
+	root := res.FilesPath + "/unzipped"
+
+	binary := make(map[string]bool)
+	for _, file := range res.BinaryFiles {
+		binary[file] = true
+	}
+
+	files := make(map[string]string)
+	for _, file := range res.Files {
+		files[relativeName(root, file)] = file
+	}
+
+	var findings []Finding
+	warn := func(kind, file, text string) {
+		findings = append(findings, Finding{
+			Type:     kind,
+			File:     file,
+			Message:  text,
+			Severity: FindingWarning,
+		})
+	}
+
+	for _, file := range res.Files {
+		ext := strings.ToLower(path.Ext(file))
+		if binary[file] || (ext != ".js" && ext != ".css") {
+			continue
+		}
+
+		f, err := fileOpen(file)
+		if err != nil {
+			continue
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			continue
+		}
+
+		name := relativeName(root, file)
+		if !isMinified(name, data) {
+			continue
+		}
+
+		mapName, inline := sourceMapReference(name, data)
+		if _, ok := files[name+".map"]; ok && mapName == "" && inline == nil {
+			mapName = name + ".map"
+		}
+
+		switch {
+		case inline != nil:
+			if err := validateSourceMap(inline, path.Dir(name), files); err != "" {
+				warn(FindingSourceMap, name, "inline source map is invalid: "+err)
+			}
+			continue
+		case mapName != "":
+			mapFile, ok := files[mapName]
+			if !ok {
+				warn(FindingSourceMap, name, "source map "+mapName+" is not included")
+				continue
+			}
+			if err := validateSourceMap(readAll(mapFile), path.Dir(mapName), files); err != "" {
+				warn(FindingSourceMap, name, "source map "+mapName+" is invalid: "+err)
+			}
+			continue
+		}
+
+		if unminified := unminifiedName(name); unminified != name {
+			if _, ok := files[unminified]; ok {
+				continue
+			}
+		}
+
+		warn(FindingMinified, name, "minified asset has no source file or source map")
+	}
+
+	return findings
+}
+
+// isMinified checks if an asset is minified, either by its name or by its line length.
+func isMinified(name string, data []byte) bool {
+	if strings.Contains(path.Base(name), ".min.") {
+		return true
+	}
+
+	lines := bytes.Count(data, []byte("\n")) + 1
+	return len(data)/lines > minifiedLineLength
+}
+
+// unminifiedName returns the name of the source file of a minified asset,
+// e.g. "js/app.min.js" becomes "js/app.js".
+func unminifiedName(name string) string {
+	return strings.Replace(name, ".min.", ".", 1)
+}
+
+// sourceMapReference returns the source map referenced by an asset. The returned name is
+// relative to the project root. Inline source maps are returned decoded.
+func sourceMapReference(name string, data []byte) (string, []byte) {
+	var url string
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := sourceMappingRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+			url = m[1]
+		}
+	}
+
+	if url == "" {
+		return "", nil
+	}
+
+	if strings.HasPrefix(url, "data:") {
+		i := strings.Index(url, ";base64,")
+		if i == -1 {
+			return "", []byte{}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(url[i+len(";base64,"):])
+		if err != nil {
+			return "", []byte{}
+		}
+		return "", decoded
+	}
+
+	return path.Join(path.Dir(name), url), nil
+}
+
+// validateSourceMap checks that a source map can be parsed and that its sources are
+// embedded or included in files. An empty string is returned if the map is valid.
+func validateSourceMap(data []byte, dir string, files map[string]string) string {
+	var sm sourceMap
+	if err := json.Unmarshal(data, &sm); err != nil {
+		return "could not parse source map"
+	}
+
+	if sm.Version != 3 {
+		return "unsupported source map version"
+	}
+
+	if len(sm.Sources) == 0 {
+		return "source map has no sources"
+	}
+
+	if len(sm.SourcesContent) == len(sm.Sources) {
+		return ""
+	}
+
+	for _, src := range sm.Sources {
+		src = strings.TrimPrefix(src, "webpack:///")
+		if _, ok := files[path.Join(dir, src)]; !ok {
+			return "source " + src + " is not included"
+		}
+	}
+
+	return ""
+}
+
+// readAll returns the contents of a file, or nil if it can't be read.
+func readAll(file string) []byte {
+	f, err := fileOpen(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	data, _ := ioutil.ReadAll(f)
+	return data
+}
@@ -0,0 +1,75 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+)
+
+func TestCheckMinified(t *testing.T) {
+	root := "./testdata/compliance/assets"
+	files := []string{
+		"css/inline.min.css",
+		"css/readable.css",
+		"css/style.css",
+		"js/app.js",
+		"js/app.min.js",
+		"js/broken.min.js",
+		"js/broken.min.js.map",
+		"js/implicit.min.js",
+		"js/implicit.min.js.map",
+		"js/lib.min.js",
+		"js/lost.min.js",
+		"js/mapped.min.js",
+		"js/mapped.min.js.map",
+		"src/mapped.js",
+	}
+
+	res := &Result{FilesPath: root}
+	for _, file := range files {
+		res.Files = append(res.Files, root+"/unzipped/"+file)
+	}
+
+	want := []Finding{
+		{"", FindingMinified, "css/style.css", "minified asset has no source file or source map", FindingWarning},
+		{"", FindingSourceMap, "js/broken.min.js", "source map js/broken.min.js.map is invalid: source ../src/missing.js is not included", FindingWarning},
+		{"", FindingMinified, "js/lib.min.js", "minified asset has no source file or source map", FindingWarning},
+		{"", FindingSourceMap, "js/lost.min.js", "source map js/lost.min.js.map is not included", FindingWarning},
+	}
+
+	if got := CheckMinified(message.Message{}, res, &Source{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckMinified() = %v, want %v", got, want)
+	}
+
+	// Binary files are not checked.
+	res.BinaryFiles = res.Files
+	if got := CheckMinified(message.Message{}, res, &Source{}); got != nil {
+		t.Errorf("CheckMinified() binary files = %v, want nil", got)
+	}
+}
+
+func Test_validateSourceMap(t *testing.T) {
+	files := map[string]string{"src/app.js": "/tmp/src/app.js"}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"Valid", `{"version":3,"sources":["../src/app.js"]}`, ""},
+		{"Webpack Sources", `{"version":3,"sources":["webpack:///../src/app.js"]}`, ""},
+		{"Embedded Sources", `{"version":3,"sources":["other.js"],"sourcesContent":["var a;"]}`, ""},
+		{"Invalid JSON", `{`, "could not parse source map"},
+		{"Version", `{"version":2,"sources":["../src/app.js"]}`, "unsupported source map version"},
+		{"No Sources", `{"version":3,"sources":[]}`, "source map has no sources"},
+		{"Missing Source", `{"version":3,"sources":["other.js"]}`, "source other.js is not included"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateSourceMap([]byte(tt.data), "js", files); got != tt.want {
+				t.Errorf("validateSourceMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"io"
 	"os"
+	"time"
+
+	"github.com/wptide/pkg/lock"
+	"github.com/wptide/pkg/provision"
 )
 
 type mockStorage struct{}
@@ -48,3 +53,47 @@ func (m mockStorage) UploadFile(filename, reference string) error {
 func (m mockStorage) DownloadFile(reference, filename string) error {
 	return nil
 }
+
+type mockProvisioner struct {
+	project  *provision.Project
+	teardown *provision.Environment
+}
+
+func (m *mockProvisioner) Provision(ctx context.Context, project provision.Project) (*provision.Environment, error) {
+	m.project = &project
+	if project.Slug == "error" {
+		return nil, errors.New("something went wrong")
+	}
+	return &provision.Environment{
+		ID:  "tide-" + project.Slug,
+		URL: "http://localhost:8080",
+	}, nil
+}
+
+func (m *mockProvisioner) Teardown(ctx context.Context, env *provision.Environment) error {
+	m.teardown = env
+	return errors.New("teardown errors are only logged")
+}
+
+// mockLocker hands out itself as the lock, or fails with err.
+type mockLocker struct {
+	err      error
+	key      string
+	released bool
+}
+
+func (m *mockLocker) Acquire(key string, ttl time.Duration) (lock.Lock, error) {
+	m.key = key
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m, nil
+}
+
+func (m *mockLocker) Release() error {
+	if m.released {
+		return errors.New("already released")
+	}
+	m.released = true
+	return nil
+}
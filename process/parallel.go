@@ -0,0 +1,102 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+)
+
+// Parallel runs a stage of the pipeline by calling the Do() of a single processor for several
+// messages at the same time. The state of each message is kept in its Job instead of on the
+// processor, so the processor only has to be configured once. It is a Pool whose workers share
+// the processor, so its Do() must not change its fields. Messages may be sent to the next
+// process in a different order than they were received.
+type Parallel struct {
+	Process                      // Inherits methods from Process.
+	In          <-chan Processor // Expects a processor channel as input.
+	Out         chan Processor   // Send results to an output channel.
+	Processor   Processor        // Processes the messages, e.g. a configured *Phpcs. Its channels are not used.
+	Stage       string           // Name of the stage in the timings, trail and errors, e.g. "phpcs".
+	Concurrency int              // (Optional) Most messages processed at the same time. Defaults to 1.
+
+	pool Pool
+}
+
+// Run starts the workers of the stage.
+func (p *Parallel) Run(sink ErrorSink) error {
+	if p.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if p.Out == nil {
+		return errors.New("requires a next process")
+	}
+	if p.Processor == nil {
+		return errors.New("requires a processor to run in parallel")
+	}
+	if p.Stage == "" {
+		return errors.New("requires a stage name")
+	}
+
+	p.pool = Pool{
+		In:    p.In,
+		Out:   p.Out,
+		New:   p.newWorker,
+		Stage: strings.ToLower(p.Stage),
+		Min:   p.concurrency(),
+	}
+	p.pool.SetContext(p.getContext())
+	if err := p.pool.Run(sink); err != nil {
+		return err
+	}
+
+	p.start()
+
+	go func() {
+		// The pool closes the out channel, signal that we are done once it stopped.
+		<-p.pool.Done()
+		p.stop(nil)
+	}()
+
+	return nil
+}
+
+// newWorker returns a worker of the pool that runs the stage with the shared processor.
+func (p *Parallel) newWorker(in <-chan Processor, out chan Processor) Processor {
+	return &sharedWorker{stage: stage{name: p.Stage, in: in, out: out, do: p.Processor.Do}}
+}
+
+// Do runs the processor for a single message.
+func (p *Parallel) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if p.Processor == nil {
+		return res, errors.New("requires a processor to run in parallel")
+	}
+	return p.Processor.Do(ctx, msg, res)
+}
+
+// concurrency returns the most messages processed at the same time.
+func (p *Parallel) concurrency() int {
+	if p.Concurrency < 1 {
+		return 1
+	}
+	return p.Concurrency
+}
+
+// sharedWorker processes each message as a job of its own, so that the workers of a Parallel
+// do not share state other than their processor.
+type sharedWorker struct {
+	Process
+	stage stage
+}
+
+// Run starts the worker.
+func (w *sharedWorker) Run(sink ErrorSink) error {
+	w.runStage(sink, w.stage)
+	return nil
+}
+
+// Do runs the shared processor for a single message.
+func (w *sharedWorker) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	return w.stage.do(ctx, msg, res)
+}
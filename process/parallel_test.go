@@ -0,0 +1,152 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// slowProcessor records how many messages it processes at the same time.
+type slowProcessor struct {
+	Process
+	delay time.Duration
+
+	mu      sync.Mutex
+	running int
+	most    int
+}
+
+func (s *slowProcessor) Run(sink ErrorSink) error {
+	return errors.New("not run directly")
+}
+
+func (s *slowProcessor) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	s.mu.Lock()
+	s.running++
+	if s.running > s.most {
+		s.most = s.running
+	}
+	s.mu.Unlock()
+
+	time.Sleep(s.delay)
+
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+
+	res.Set("processed", msg.Title)
+	if msg.Slug == "fail" {
+		return res, errors.New("something went wrong")
+	}
+	return res, nil
+}
+
+func TestParallel_Run(t *testing.T) {
+	tests := []struct {
+		name     string
+		parallel *Parallel
+		wantErr  bool
+	}{
+		{
+			"Valid Process",
+			&Parallel{In: make(chan Processor), Out: make(chan Processor), Processor: &slowProcessor{}, Stage: "slow"},
+			false,
+		},
+		{
+			"No In Channel",
+			&Parallel{Out: make(chan Processor), Processor: &slowProcessor{}, Stage: "slow"},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Parallel{In: make(chan Processor), Processor: &slowProcessor{}, Stage: "slow"},
+			true,
+		},
+		{
+			"No Processor",
+			&Parallel{In: make(chan Processor), Out: make(chan Processor), Stage: "slow"},
+			true,
+		},
+		{
+			"No Stage",
+			&Parallel{In: make(chan Processor), Out: make(chan Processor), Processor: &slowProcessor{}},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.parallel.SetContext(ctx)
+
+			if err := tt.parallel.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Parallel.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParallel_Messages(t *testing.T) {
+	tests := []struct {
+		name        string
+		concurrency int
+		wantMost    int
+	}{
+		{"Default", 0, 1},
+		{"Three Workers", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := &slowProcessor{delay: 20 * time.Millisecond}
+			in := make(chan Processor)
+			out := make(chan Processor)
+			errc := make(ErrorChannel, 6)
+
+			parallel := &Parallel{In: in, Out: out, Processor: processor, Stage: "slow", Concurrency: tt.concurrency}
+			if err := parallel.Run(errc); err != nil {
+				t.Fatal(err)
+			}
+
+			titles := []string{"a", "b", "c", "d", "e", "f"}
+			go func() {
+				for _, title := range titles {
+					slug := title
+					if title == "c" {
+						slug = "fail"
+					}
+					in <- &relayStage{Process: Process{Message: message.Message{Title: title, Slug: slug}}}
+				}
+				close(in)
+			}()
+
+			var got []string
+			for proc := range out {
+				res := proc.GetResult()
+				if processed, _ := res.Get("processed"); processed != proc.GetMessage().Title {
+					t.Errorf("Parallel result of %v processed %v", proc.GetMessage().Title, processed)
+				}
+				if _, ok := res.Timings["slow"]; !ok {
+					t.Errorf("Parallel result of %v has no timing: %v", proc.GetMessage().Title, res.Timings)
+				}
+				got = append(got, proc.GetMessage().Title)
+			}
+			<-parallel.Done()
+
+			sort.Strings(got)
+			if len(got) != len(titles) {
+				t.Errorf("Parallel sent %v, want %v", got, titles)
+			}
+			if processor.most != tt.wantMost {
+				t.Errorf("Parallel processed %v messages at the same time, want %v", processor.most, tt.wantMost)
+			}
+			if len(errc) != 1 {
+				t.Errorf("Parallel reported %v errors, want 1", len(errc))
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package process
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// pathFilter selects the files an audit checks, from the comma separated Ignore and Include
+// patterns of its options. Patterns follow the --ignore patterns of PHPCS: "*" matches any
+// characters, matching is case insensitive and a pattern can match anywhere in the path of a
+// file, e.g. "*/vendor/*" or "node_modules/". The zero value audits every file.
+type pathFilter struct {
+	ignore  []*regexp.Regexp
+	include []*regexp.Regexp
+}
+
+// newPathFilter returns the filter for the audit options, which may be nil.
+func newPathFilter(options *message.AuditOption) pathFilter {
+	if options == nil {
+		return pathFilter{}
+	}
+	return pathFilter{
+		ignore:  compilePatterns(options.Ignore),
+		include: compilePatterns(options.Include),
+	}
+}
+
+// audits determines if the file is audited: it must not match any ignore pattern and, if there
+// are include patterns, it must match one of them.
+func (f pathFilter) audits(file string) bool {
+	file = filepath.ToSlash(file)
+	if matchesAny(f.ignore, file) {
+		return false
+	}
+	return len(f.include) == 0 || matchesAny(f.include, file)
+}
+
+// excluded returns the files with the extension that are not included by the include patterns.
+// Files matching the ignore patterns are left out, they are ignored by the patterns themselves.
+func (f pathFilter) excluded(files []string, ext string) []string {
+	if len(f.include) == 0 {
+		return nil
+	}
+
+	excluded := []string{}
+	for _, file := range files {
+		if strings.ToLower(filepath.Ext(file)) != ext || matchesAny(f.ignore, file) {
+			continue
+		}
+		if !matchesAny(f.include, filepath.ToSlash(file)) {
+			excluded = append(excluded, file)
+		}
+	}
+	return excluded
+}
+
+// writeFileList writes the files with the extension, except the skipped ones, to a PHPCS
+// --file-list at the path and returns how many files it lists. The list has a path per line,
+// so files with line breaks in their paths are rejected.
+func writeFileList(path string, files []string, ext string, skipped []string) (int, error) {
+	skip := make(map[string]bool, len(skipped))
+	for _, file := range skipped {
+		skip[file] = true
+	}
+
+	var list bytes.Buffer
+	listed := 0
+	for _, file := range files {
+		if skip[file] || strings.ToLower(filepath.Ext(file)) != ext {
+			continue
+		}
+		if strings.ContainsAny(file, "\r\n") {
+			return 0, errors.New("can't list file with a line break in its path: " + file)
+		}
+		list.WriteString(file + "\n")
+		listed++
+	}
+
+	return listed, writeFile(path, list.Bytes(), 0644)
+}
+
+// filterReport removes the files that are not audited from a report with paths relative to root,
+// and updates the totals.
+func (f pathFilter) filterReport(results *tide.PhpcsResults, root string) {
+	if results == nil {
+		return
+	}
+	for name, file := range results.Files {
+		if f.audits(root + "/" + name) {
+			continue
+		}
+		results.Totals.Errors -= file.Errors
+		results.Totals.Warnings -= file.Warnings
+		delete(results.Files, name)
+	}
+}
+
+// compilePatterns converts comma separated PHPCS ignore patterns into regular expressions.
+// Patterns that are not valid regular expressions are matched literally.
+func compilePatterns(patterns string) []*regexp.Regexp {
+	compiled := []*regexp.Regexp{}
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+
+		expr := strings.Replace(pattern, "*", ".*", -1)
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			re = regexp.MustCompile("(?i)" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1))
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled
+}
+
+// matchesAny determines if any of the patterns matches the file.
+func matchesAny(patterns []*regexp.Regexp, file string) bool {
+	for _, re := range patterns {
+		if re.MatchString(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditOptions returns the options of the first audit of the type requested by the message,
+// or nil if there are none.
+func auditOptions(msg message.Message, auditType string) *message.AuditOption {
+	for _, audit := range msg.Audits {
+		if audit != nil && audit.Type == auditType {
+			return audit.Options
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+func Test_pathFilter_audits(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *message.AuditOption
+		file    string
+		want    bool
+	}{
+		{"No Options", nil, "/tmp/unzipped/vendor/autoload.php", true},
+		{"No Patterns", &message.AuditOption{}, "/tmp/unzipped/vendor/autoload.php", true},
+		{"Ignored", &message.AuditOption{Ignore: "*/vendor/*,*/node_modules/*"}, "/tmp/unzipped/node_modules/lib/index.js", false},
+		{"Not Ignored", &message.AuditOption{Ignore: "*/vendor/*"}, "/tmp/unzipped/plugin.php", true},
+		{"Case Insensitive", &message.AuditOption{Ignore: "*/Vendor/*"}, "/tmp/unzipped/vendor/autoload.php", false},
+		{"Included", &message.AuditOption{Include: "*/src/*, *.css"}, "/tmp/unzipped/src/plugin.php", true},
+		{"Not Included", &message.AuditOption{Include: "*/src/*"}, "/tmp/unzipped/plugin.php", false},
+		{"Included But Ignored", &message.AuditOption{Include: "*/src/*", Ignore: "*/src/vendor/*"}, "/tmp/unzipped/src/vendor/lib.php", false},
+		{"Invalid Expression", &message.AuditOption{Ignore: "*/lib(/*"}, "/tmp/unzipped/lib(/file.php", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPathFilter(tt.options).audits(tt.file); got != tt.want {
+				t.Errorf("pathFilter.audits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pathFilter_excluded(t *testing.T) {
+	files := []string{"/tmp/unzipped/plugin.php", "/tmp/unzipped/src/a.php", "/tmp/unzipped/src/b.js", "/tmp/unzipped/vendor/c.php"}
+
+	tests := []struct {
+		name    string
+		options *message.AuditOption
+		want    []string
+	}{
+		{"No Include", &message.AuditOption{Ignore: "*/vendor/*"}, nil},
+		{"Include", &message.AuditOption{Include: "*/src/*"}, []string{"/tmp/unzipped/plugin.php", "/tmp/unzipped/vendor/c.php"}},
+		{"Include And Ignore", &message.AuditOption{Include: "*/src/*", Ignore: "*/vendor/*"}, []string{"/tmp/unzipped/plugin.php"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newPathFilter(tt.options).excluded(files, ".php"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pathFilter.excluded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pathFilter_filterReport(t *testing.T) {
+	results := &tide.PhpcsResults{}
+	addReportMessage(results, "js/app.js", tide.PhpcsFilesMessage{Type: "ERROR"})
+	addReportMessage(results, "node_modules/lib/index.js", tide.PhpcsFilesMessage{Type: "ERROR"})
+	addReportMessage(results, "node_modules/lib/index.js", tide.PhpcsFilesMessage{Type: "WARNING"})
+
+	newPathFilter(&message.AuditOption{Ignore: "*/node_modules/*"}).filterReport(results, "/tmp/unzipped")
+
+	if len(results.Files) != 1 || results.Totals.Errors != 1 || results.Totals.Warnings != 0 {
+		t.Errorf("pathFilter.filterReport() = %v", results)
+	}
+}
+
+func TestPhpcs_Do_Include(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	runner := &recordingRunner{fail: true}
+	cs := &Phpcs{
+		TempFolder:      os.TempDir(),
+		StorageProvider: &mockStorage{},
+		PhpcsVersions:   map[string]map[string]string{"wordpress": {"phpcs": "3.1.1"}},
+		Runner:          runner,
+	}
+
+	res := NewResult()
+	res.Checksum = "checksum"
+	res.FilesPath = "/tmp/audit"
+	res.Files = []string{"/tmp/audit/unzipped/plugin.php", "/tmp/audit/unzipped/src/a.php", "/tmp/audit/unzipped/vendor/b.php"}
+	res.BinaryFiles = []string{"/tmp/audit/unzipped/src/image.php"}
+
+	msg := message.Message{
+		Title:  "Test",
+		Audits: []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress", Ignore: "*/vendor/*", Include: "*/src/*"}}},
+	}
+
+	// The files that are not included are left out of the file list.
+	lists := make(map[string]string)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		lists[filename] = string(data)
+		return nil
+	}
+	defer func() { writeFile = ioutil.WriteFile }()
+
+	// The runner fails, only the command is of interest.
+	cs.Do(context.Background(), msg, res)
+
+	fileList := strings.TrimRight(os.TempDir(), "/") + "/checksum-phpcs_wordpress-files.txt"
+	want := `--ignore=*/vendor/*,/tmp/audit/unzipped/src/image\.php --standard=wordpress`
+	if len(runner.commands) != 1 || !strings.Contains(strings.Join(runner.commands[0], " "), want) || !strings.Contains(strings.Join(runner.commands[0], " "), "--file-list="+fileList) {
+		t.Errorf("Phpcs.Do() ran %v, want %v and --file-list=%v", runner.commands, want, fileList)
+	}
+	if got, want := lists[fileList], "/tmp/audit/unzipped/src/a.php\n/tmp/audit/unzipped/vendor/b.php\n"; got != want {
+		t.Errorf("Phpcs.Do() listed %q, want %q", got, want)
+	}
+}
+
+func Test_writeFileList(t *testing.T) {
+	lists := make(map[string]string)
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		lists[filename] = string(data)
+		return nil
+	}
+	defer func() { writeFile = ioutil.WriteFile }()
+
+	tests := []struct {
+		name    string
+		files   []string
+		skipped []string
+		want    string
+		wantN   int
+		wantErr bool
+	}{
+		{"Listed", []string{"/tmp/a.php", "/tmp/b.PHP", "/tmp/c.js"}, nil, "/tmp/a.php\n/tmp/b.PHP\n", 2, false},
+		{"Skipped", []string{"/tmp/a.php", "/tmp/b.php"}, []string{"/tmp/a.php"}, "/tmp/b.php\n", 1, false},
+		{"Nothing Listed", []string{"/tmp/a.php"}, []string{"/tmp/a.php"}, "", 0, false},
+		{"Line Break", []string{"/tmp/a\n.php"}, nil, "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delete(lists, "list")
+			got, err := writeFileList("list", tt.files, ".php", tt.skipped)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeFileList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.wantN || lists["list"] != tt.want {
+				t.Errorf("writeFileList() = %v, %q, want %v, %q", got, lists["list"], tt.wantN, tt.want)
+			}
+		})
+	}
+}
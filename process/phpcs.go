@@ -1,39 +1,74 @@
 package process
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/wptide/pkg/env"
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
-	"github.com/wptide/pkg/process/phpcs"
 	"github.com/wptide/pkg/shell"
 	"github.com/wptide/pkg/storage"
 	"github.com/wptide/pkg/tide"
 )
 
 var (
-	phpcsRunner shell.Runner
+	phpcsRunner shell.Runner = defaultRunner
 )
 
 // Phpcs defines the structure for our Phpcs process.
 type Phpcs struct {
-	Process                                      // Inherits methods from Process.
-	In              <-chan Processor             // Expects a processor channel as input.
-	Out             chan Processor               // Send results to an output channel.
-	Config          Result                       // Additional config.
-	TempFolder      string                       // Path to a temp folder where reports will be generated.
-	StorageProvider storage.Provider             // Storage provider to upload reports to.
-	PhpcsVersions   map[string]map[string]string // PHPCS versions.
+	Process                                        // Inherits methods from Process.
+	In                <-chan Processor             // Expects a processor channel as input.
+	Out               chan Processor               // Send results to an output channel.
+	Config            map[string]interface{}       // Additional config.
+	TempFolder        string                       // Path to a temp folder where reports will be generated.
+	StorageProvider   storage.Provider             // Storage provider to upload reports to.
+	PhpcsVersions     map[string]map[string]string // PHPCS versions.
+	Standards         StandardsManager             // (Optional) Provides the installed standards. Defaults to PhpcsVersions.
+	Transformers      []ReportTransformer          // (Optional) Applied to each report in order. Defaults to DefaultReportTransformers().
+	MaxReportSize     int64                        // (Optional) Reports larger than this many bytes are uploaded in chunks.
+	Sandbox           *PhpSandbox                  // (Optional) Runs PHPCS with a generated php.ini that restricts what the audited code can do.
+	Redaction         *Redaction                   // (Optional) Redacts worker details, e.g. temp paths, from the uploaded reports.
+	Runner            shell.Runner                 // (Optional) Runs PHPCS, e.g. a shell.Command with a low-privilege User. Defaults to shell.Command.
+	Timeout           time.Duration                // (Optional) Stops PHPCS if an audit takes longer. Messages can override it. Defaults to no limit.
+	Rulesets          storage.Provider             // (Optional) Storage provider to download custom rulesets from. Defaults to StorageProvider.
+	Client            *http.Client                 // (Optional) Downloads custom rulesets from URLs. Defaults to http.DefaultClient.
+	RulesetTTL        time.Duration                // (Optional) How long a downloaded ruleset is reused. Defaults to DefaultRulesetTTL.
+	Parallel          int                          // (Optional) Number of PHPCS processes. Defaults to the PHPCS_PARALLEL environment variable, then Config["parallel"], then 1.
+	MemoryLimit       string                       // (Optional) PHP memory limit of PHPCS, e.g. "2G". Defaults to the PHPCS_MEMORY_LIMIT environment variable, then no limit.
+	Incremental       bool                         // (Optional) Only audit the files that changed since the last audit of the project with the same options, and reuse the reports of the others.
+	IncrementalPrefix string                       // (Optional) Prefix of the references of the reports kept for incremental audits. Defaults to "incremental/".
+	StreamReports     int64                        // (Optional) Reports larger than this many bytes are summarized file by file instead of being loaded into memory. Defaults to loading every report.
+	ReuseReports      bool                         // (Optional) Upload raw reports as "<checksum>-<kind>-<standards key>-raw.json" and reuse the uploaded report of the same sources and standards, see AuditResult.CacheHit.
+	Compress          bool                         // (Optional) Gzips reports before they are uploaded, see storage.GzipFile.
+	Replayable        bool                         // (Optional) Keeps the audit options in the results as Extra["options"], so that the audits can be replayed, see the replay package.
 }
 
+// Environment variables with the defaults of the PHPCS resources.
+const (
+	PhpcsParallelEnv    = "PHPCS_PARALLEL"
+	PhpcsMemoryLimitEnv = "PHPCS_MEMORY_LIMIT"
+)
+
+// maxPartialOutput is the most bytes of output kept of an audit that did not finish in time.
+const maxPartialOutput = 4096
+
+// memoryLimit matches the values of the PHP memory_limit setting, e.g. "512M" or "-1" for no limit.
+var memoryLimit = regexp.MustCompile(`^(-1|[0-9]+[KkMmGg]?)$`)
+
 // Run executes the process in a pipe.
-func (cs *Phpcs) Run(errc *chan error) error {
+func (cs *Phpcs) Run(sink ErrorSink) error {
 
 	if cs.TempFolder == "" {
 		return errors.New("no temp folder provided for phpcs reports")
@@ -50,94 +85,104 @@ func (cs *Phpcs) Run(errc *chan error) error {
 		return errors.New("requires a next process")
 	}
 
-	if cs.PhpcsVersions == nil {
+	if cs.PhpcsVersions == nil && cs.Standards == nil {
 		return errors.New("requires a map of PHPCS versions")
 	}
 
-	go func() {
-		for {
-			select {
-			case in := <-cs.In:
-
-				// Copy Process fields from `in` process.
-				cs.CopyFields(in)
-
-				result := *cs.Result
-
-				// Run the process.
-				// If processing produces an error send it up the error channel.
-				for _, audit := range cs.Message.Audits {
-					if audit.Type == "phpcs" {
-						result["phpcsCurrentAudit"] = audit
-						cs.SetResults(&result)
-						if err := cs.Do(); err != nil {
-							// Pass the error up the error channel.
-							*errc <- errors.New("PHPCS Error: " + err.Error())
-							// Don't break, the message is still useful to other processes.
-						}
-					}
-				}
-
-				// Send process to the out channel.
-				cs.Out <- cs
-			}
+	if cs.Redaction != nil {
+		if err := cs.Redaction.Validate(); err != nil {
+			return err
 		}
+	}
 
-	}()
+	cs.runStage(sink, stage{name: "PHPCS", in: cs.In, out: cs.Out, do: cs.Do})
 
 	return nil
 }
 
-// Do executes the process.
-func (cs *Phpcs) Do() error {
+// Do runs every phpcs audit requested by the message and returns the result with the audits added.
+// An error in one audit does not prevent the remaining audits from running.
+func (cs *Phpcs) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
 
-	log.Log(cs.Message.Title, "Running PHPCS Audit...")
+	if res == nil {
+		return res, errors.New("no result to process")
+	}
 
-	if phpcsRunner == nil {
-		phpcsRunner = defaultRunner
+	var errs []string
+	code := ""
+	for _, audit := range msg.Audits {
+		if audit == nil || audit.Type != "phpcs" || skipped(res, auditKind(audit)) {
+			continue
+		}
+
+		if err := cs.audit(ctx, msg, res, audit, auditKind(audit)); err != nil {
+			errs = append(errs, err.Error())
+			// The first failure describes the combined error.
+			if code == "" {
+				code = errorCode(err)
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return res, withCode(code, errors.New(strings.Join(errs, "; ")))
 	}
 
-	result := *cs.Result
+	return res, nil
+}
+
+// audit runs a single phpcs audit and adds the audit result of the kind to res.
+func (cs *Phpcs) audit(ctx context.Context, msg message.Message, res *Result, audit *message.Audit, kind string) error {
 
-	// Get the current audit from the result.
-	audit := result["phpcsCurrentAudit"].(*message.Audit)
+	log.Log(msg.Title, "Running PHPCS Audit...")
+	ctx = reportContext(ctx, msg, kind)
 
-	// Try to get filesPath from results first.
-	if path, ok := result["filesPath"].(string); ok {
-		cs.SetFilesPath(path)
+	runner := phpcsRunner
+	if cs.Runner != nil {
+		runner = cs.Runner
+	}
+
+	if audit.Options == nil {
+		return errors.New("could not determine audit options")
 	}
 
 	standard := audit.Options.Standard
 	if standard == "" {
-		return errors.New("could not determine standard for report")
+		return withCode(tide.FailureStandardMissing, errors.New("could not determine standard for report"))
+	}
+
+	// Use the same installation for the whole audit, even if the active one is switched meanwhile.
+	standards := cs.standards()
+	if active, ok := standards.(*ActiveStandards); ok {
+		if current := active.Current(); current != nil {
+			standards = current
+		}
 	}
 
-	phpcsVersions, ok := cs.PhpcsVersions[standard]
-	if !ok {
-		return errors.New("could not determine PHPCS versions")
+	// Make sure the installed versions match any versions pinned by the message.
+	phpcsVersions, err := resolveVersions(standards, standard, audit.Options.Versions)
+	if err != nil {
+		return withCode(tide.FailureStandardMissing, err)
 	}
 
-	checksum, ok := result["checksum"].(string)
-	if !ok {
+	checksum := res.Checksum
+	if checksum == "" {
 		return errors.New("could not determine checksum")
 	}
 
-	//return errors.New("could not determine files path")
-	if cs.GetFilesPath() == "" {
+	if res.FilesPath == "" {
 		return errors.New("could not determine files path")
 	}
 
-	path := cs.GetFilesPath() + "/unzipped"
+	path := res.FilesPath + "/unzipped"
 
-	kind := strings.ToLower(audit.Type) + "_" + strings.ToLower(standard)
 	filename := checksum + "-" + kind + "-raw.json"
 	pathPrefix := strings.TrimRight(cs.TempFolder, "/") + "/"
 	filepath := pathPrefix + filename
 
-	// Provide in implementation, not from message.
-	parallel, ok := cs.Config["parallel"].(int)
-	if !ok {
-		parallel = 1
+	parallel, memory, err := cs.resources(audit.Options)
+	if err != nil {
+		return err
 	}
 
 	// Get encoding from message and provide a fallback.
@@ -146,23 +191,74 @@ func (cs *Phpcs) Do() error {
 		encoding = "utf-8"
 	}
 
+	// The override is either another installed standard or a custom ruleset to download.
 	cliStandard := standard
-	if audit.Options.StandardOverride != "" {
-		cliStandard = audit.Options.StandardOverride
+	if override := audit.Options.StandardOverride; isRuleset(override) {
+		if cliStandard, err = cs.ruleset(ctx, res, override); err != nil {
+			return err
+		}
+	} else if override != "" {
+		cliStandard = override
+	}
+
+	selection, err := newSniffSelection(audit.Options)
+	if err != nil {
+		return err
+	}
+
+	formats, err := reportFormats(audit.Options)
+	if err != nil {
+		return err
+	}
+
+	// Binary files are ignored along with the patterns.
+	ignore, err := ignorePatterns(audit.Options.Ignore, res.BinaryFiles)
+	if err != nil {
+		return err
+	}
+
+	// The files that are not included are left out of the list of files to audit instead, as
+	// there can be too many of them for the command line.
+	skipped := newPathFilter(audit.Options).excluded(res.Files, ".php")
+
+	// Reuse the reports of the files that did not change since the last audit of the project.
+	var inc *incremental
+	if cs.Incremental && msg.Slug != "" && len(res.FileChecksums) != 0 {
+		inc = cs.incremental(ctx, msg, res, kind, incrementalKey(audit.Options, phpcsVersions, cliStandard))
+		skipped = append(skipped, inc.ignored(path)...)
+	}
+
+	target := path
+	if len(skipped) != 0 {
+		fileList := pathPrefix + checksum + "-" + kind + "-files.txt"
+		listed, err := writeFileList(fileList, res.Files, ".php", append(skipped, res.BinaryFiles...))
+		if err != nil {
+			return err
+		}
+		defer os.Remove(fileList)
+
+		target = "--file-list=" + fileList
+		if listed == 0 {
+			target = ""
+		}
 	}
 
 	cmdName := "phpcs"
+	installation, _ := standards.(*StandardsInstallation)
+	if installation != nil && installation.Phpcs != "" {
+		cmdName = installation.Phpcs
+	}
 	cmdArgs := []string{
 		"--extensions=php",
-		"--ignore=" + audit.Options.Ignore,
+		"--ignore=" + ignore,
 		"--standard=" + cliStandard,
 		"--encoding=" + encoding,
 		"--basepath=" + path, // Remove this part from the filenames in PHPCS report.
 		"--report=json",
 		"--report-json=" + filepath,
 		"--parallel=" + strconv.Itoa(parallel),
-		"-d",              // Required to be before "memory_limit".
-		"memory_limit=-1", // Leave memory handling up to the system.
+		"-d", // Required to be before "memory_limit".
+		"memory_limit=" + memory,
 	}
 
 	// @todo fix message to accept array of options.
@@ -175,100 +271,310 @@ func (cs *Phpcs) Do() error {
 	}
 	//}
 
-	cmdArgs = append(cmdArgs, path)
+	cmdArgs = append(cmdArgs, selection.args()...)
+	cmdArgs = append(cmdArgs, target)
 	cmdArgs = append(cmdArgs, "-q")
 
-	// Prepare the command and set the stdOut pipe.
-	resultBytes, errorBytes, exitCode, err := phpcsRunner.Run(cmdName, cmdArgs...)
+	// Constrain the audited code with a php.ini that only applies to this run.
+	if cs.Sandbox != nil {
+		allowed := []string{res.FilesPath, cs.TempFolder}
+		if installation != nil && installation.Path != "" {
+			allowed = append(allowed, installation.Path)
+		}
+
+		iniPath := pathPrefix + checksum + "-" + kind + "-php.ini"
+		php, phpArgs, err := cs.Sandbox.command(cmdName, iniPath, allowed)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(iniPath)
+
+		cmdName, cmdArgs = php, append(phpArgs, cmdArgs...)
+	}
+
+	// Stop PHPCS if the audit takes too long, so that the next message can be processed.
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout := cs.timeout(audit.Options); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Prepare the command and set the stdOut pipe. PHPCS needs at least one file, so an empty
+	// report is written if every file is left out.
+	var resultBytes, errorBytes []byte
+	var exitCode int
+	if target != "" {
+		done := res.timeStage(kind)
+		resultBytes, errorBytes, exitCode, err = runCommand(ctx, res, runner, cmdName, cmdArgs...)
+		done()
+	} else {
+		log.Log(msg.Title, "Every file is left out of the phpcs ("+standard+") audit.")
+		if err := writeFile(filepath, []byte("{}"), 0644); err != nil {
+			return err
+		}
+	}
+	if err == context.DeadlineExceeded {
+		log.Log(msg.Title, "phpcs ("+standard+") did not finish in time, it has been stopped.")
+		timedOut := tide.AuditResult{
+			Status:        tide.AuditStatusTimeout,
+			Error:         "phpcs did not finish in time",
+			PhpcsVersions: phpcsVersions,
+			Extra: map[string]interface{}{
+				"output": partialOutput(resultBytes, errorBytes),
+			},
+		}
+		if cs.Replayable {
+			timedOut.Extra["options"] = audit.Options
+		}
+		res.SetAudit(kind, timedOut)
+		return withCode(tide.FailurePhpcsTimeout, errors.New("phpcs ("+standard+") did not finish in time"))
+	}
 
 	if len(errorBytes) > 0 {
-		log.Log(cs.Message.Title, fmt.Sprintf("phpcs error:\n %s", strings.TrimSpace(string(errorBytes))))
+		log.Log(msg.Title, fmt.Sprintf("phpcs error:\n %s", strings.TrimSpace(string(errorBytes))))
+	}
+	log.Log(msg.Title, fmt.Sprintf("phpcs output:\n %s", strings.TrimSpace(string(resultBytes))))
+
+	if inc != nil {
+		if err := inc.merge(filepath); err != nil {
+			log.Log(msg.Title, "Could not merge the incremental "+standard+" report: "+err.Error())
+		}
 	}
-	log.Log(cs.Message.Title, fmt.Sprintf("phpcs output:\n %s", strings.TrimSpace(string(resultBytes))))
 
 	// We already have a reference to the report file, so lets upload and get the storage reference in a result.
-	log.Log(cs.Message.Title, "Uploading "+standard+" results to remote storage.")
+	log.Log(msg.Title, "Uploading "+standard+" results to remote storage.")
 
-	fType, fFileName, fPath, err := cs.uploadToStorage(filepath, filename)
+	// The parsed report keeps the paths, only the uploaded copy is redacted.
+	uploadPath := filepath
+	if cs.Redaction != nil {
+		if uploadPath, err = cs.Redaction.redactFile(msg, res.FilesPath, filepath); err != nil {
+			return err
+		}
+		defer os.Remove(uploadPath)
+	}
+
+	// Audits of the same sources with the same options, versions and standard produce the same
+	// raw report, so it is uploaded once. Reports derived from it depend on the transformers and
+	// are uploaded each time.
+	var raw tide.AuditDetails
+	reused := false
+	done := res.timeStage("upload")
+	if cs.ReuseReports {
+		// Compressed reports are other content.
+		key := incrementalKey(audit.Options, phpcsVersions, cliStandard)
+		if cs.Redaction != nil {
+			// Reports redacted for other clients are other content.
+			key += "-" + cs.Redaction.key(msg)
+		}
+		if cs.Compress {
+			key += "-" + storage.EncodingGzip
+		}
+		reference := checksum + "-" + kind + "-" + key + "-raw.json"
+
+		raw, reused, err = uploadAddressedReport(ctx, meterStorage(cs.StorageProvider, res), uploadPath, reference, cs.MaxReportSize, cs.Compress)
+	} else {
+		raw, err = cs.uploadToStorage(ctx, res, uploadPath, filename)
+	}
+	done()
 	if err != nil {
 		return err
 	}
+	if reused {
+		log.Log(msg.Title, "Reusing the uploaded "+standard+" results.")
+	}
 
 	// Initialise the result, set the "Raw" entry to the uploaded file and set the PHPCS version.
 	auditResults := tide.AuditResult{
-		Raw: tide.AuditDetails{
-			Type:     fType,
-			FileName: fFileName,
-			Path:     fPath,
-		},
+		Raw:           raw,
+		CacheHit:      reused,
 		PhpcsVersions: phpcsVersions,
 	}
+	if cs.Replayable {
+		auditResults.Extra = map[string]interface{}{"options": audit.Options}
+	}
+	if inc != nil && len(inc.unchanged) != 0 {
+		if auditResults.Extra == nil {
+			auditResults.Extra = make(map[string]interface{})
+		}
+		auditResults.Extra["reused"] = len(inc.unchanged)
+	}
 
 	// `uploadToStorage` already did the error checking.
 	fileReader, _ := fileOpen(filepath)
 	defer fileReader.Close()
 
-	report, _ := ioutil.ReadAll(fileReader)
-
-	var phpcsResults *tide.PhpcsResults
-	err = json.Unmarshal(report, &phpcsResults)
-	if err != nil {
-		return err
+	phpcsReport := &Report{
+		Kind:     kind,
+		Checksum: checksum,
+		Options:  audit.Options,
+		Locale:   msg.Locale,
+		Audit:    &auditResults,
+		upload:   cs.reportUploader(ctx, msg, res, pathPrefix),
 	}
 
-	// Get the PHPCS Summary.
-	summary := phpcs.GetPhpcsSummary(*phpcsResults)
-	auditResults.Summary = tide.AuditSummary{PhpcsSummary: summary}
-
-	// Only PHPCompatibility provides parsed results.
-	// @todo Abstract this later.
-	if kind == "phpcs_phpcompatibility" {
-		compatibleVersions, incompatibleVersions, compatResults := phpcs.GetPhpcsCompatibility(*phpcsResults)
+	// Large reports are summarized file by file instead of being loaded into memory.
+	// They are not emitted in other formats, and are kept as nil reports so that policies
+	// and Dedup know that they could not be evaluated.
+	if cs.streams(fileReader) {
+		skipped, err := cs.streamReport(fileReader, phpcsReport, selection)
+		if err != nil {
+			return err
+		}
+		if len(skipped) != 0 {
+			log.Log(msg.Title, "The "+standard+" report is too large to apply: "+strings.Join(skipped, ", "))
+		}
+		if len(formats) != 0 {
+			log.Log(msg.Title, "The "+standard+" report is too large to emit in other formats.")
+		}
+		res.setReport(kind, nil)
+	} else {
+		report, _ := ioutil.ReadAll(fileReader)
 
-		resultsJSON, _ := json.Marshal(compatResults)
+		var phpcsResults *tide.PhpcsResults
+		err = json.Unmarshal(report, &phpcsResults)
+		if err != nil {
+			return err
+		}
 
-		fname := checksum + "-" + kind + "-parsed.json"
-		fpath := pathPrefix + fname
+		// Remove the sniffs PHPCS could not exclude, before the report is summarized.
+		selection.filter(phpcsResults)
 
-		err = writeFile(fpath, resultsJSON, os.ModePerm)
-		if err != nil {
+		// Post-process the report before adding it to the result.
+		phpcsReport.Results = phpcsResults
+		if err := transformReport(phpcsReport, cs.transformers()); err != nil {
 			return err
 		}
 
-		fType, fFileName, fPath, err := cs.uploadToStorage(fpath, fname)
-		if err != nil {
+		if err := emitReports(phpcsReport, formats); err != nil {
 			return err
 		}
 
-		auditResults.Parsed = tide.AuditDetails{
-			Type:     fType,
-			FileName: fFileName,
-			Path:     fPath,
+		res.setReport(kind, phpcsResults)
+	}
+
+	res.SetAudit(kind, auditResults)
+
+	log.Log(msg.Title, fmt.Sprintf("phpcs (%s) process completed with exit code: %d\n", standard, exitCode))
+
+	return nil
+}
+
+// standards returns the StandardsManager for the process.
+func (cs Phpcs) standards() StandardsManager {
+	if cs.Standards != nil {
+		return cs.Standards
+	}
+	return StaticStandards(cs.PhpcsVersions)
+}
+
+// resources returns the number of PHPCS processes and the PHP memory limit for an audit with
+// the options. Messages can't use more processes than the worker has CPUs, or more memory than
+// the limit of the worker.
+func (cs Phpcs) resources(options *message.AuditOption) (int, string, error) {
+	parallel := cs.Parallel
+	if parallel < 1 {
+		parallel, _ = strconv.Atoi(env.GetEnv(PhpcsParallelEnv, ""))
+	}
+	if parallel < 1 {
+		parallel, _ = cs.Config["parallel"].(int)
+	}
+	if options != nil && options.Parallel > 0 {
+		parallel = options.Parallel
+		if cpus := runtime.NumCPU(); parallel > cpus {
+			parallel = cpus
 		}
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
 
-		auditResults.CompatibleVersions = compatibleVersions
-		auditResults.IncompatibleVersions = incompatibleVersions
+	// Leave memory handling up to the system, unless there is a limit.
+	memory := cs.MemoryLimit
+	if memory == "" {
+		memory = env.GetEnv(PhpcsMemoryLimitEnv, "-1")
+	}
+	if !memoryLimit.MatchString(memory) {
+		return 0, "", errors.New("invalid memory limit: " + memory)
+	}
+	if options != nil && options.MemoryLimit != "" {
+		if !memoryLimit.MatchString(options.MemoryLimit) {
+			return 0, "", errors.New("invalid memory limit: " + options.MemoryLimit)
+		}
+		if requested, limit := memoryBytes(options.MemoryLimit), memoryBytes(memory); limit < 0 || requested >= 0 && requested <= limit {
+			memory = options.MemoryLimit
+		}
 	}
 
-	// Reset current audit.
-	result["phpcsCurrentAudit"] = nil
+	return parallel, memory, nil
+}
 
-	result[kind] = auditResults
-	cs.Result = &result
+// memoryBytes returns the bytes of a valid PHP memory limit, or -1 for no limit.
+func memoryBytes(limit string) int64 {
+	if limit == "-1" {
+		return -1
+	}
 
-	log.Log(cs.Message.Title, fmt.Sprintf("phpcs (%s) process completed with exit code: %d\n", standard, exitCode))
+	unit := int64(1)
+	switch limit[len(limit)-1] {
+	case 'K', 'k':
+		unit = 1 << 10
+	case 'M', 'm':
+		unit = 1 << 20
+	case 'G', 'g':
+		unit = 1 << 30
+	}
+	n, _ := strconv.ParseInt(strings.TrimRight(limit, "KkMmGg"), 10, 64)
+	return n * unit
+}
 
-	return nil
+// timeout returns how long PHPCS may run for an audit with the options, or 0 if there is no limit.
+func (cs Phpcs) timeout(options *message.AuditOption) time.Duration {
+	if options != nil && options.Timeout > 0 {
+		return time.Duration(options.Timeout) * time.Second
+	}
+	return cs.Timeout
 }
 
-func (cs Phpcs) uploadToStorage(filepath, filename string) (fType, fFileName, fPath string, err error) {
-	err = cs.StorageProvider.UploadFile(filepath, filename)
+// partialOutput returns the end of the output of a command that was stopped, which shows
+// what it was doing when it was stopped.
+func partialOutput(out, errOut []byte) string {
+	output := strings.TrimSpace(string(out) + "\n" + string(errOut))
+	if len(output) > maxPartialOutput {
+		output = output[len(output)-maxPartialOutput:]
+	}
+	return output
+}
 
-	if err == nil {
-		fType = cs.StorageProvider.Kind()
-		fFileName = filename
-		fPath = cs.StorageProvider.CollectionRef()
+// transformers returns the report transformers for the process.
+func (cs Phpcs) transformers() []ReportTransformer {
+	if cs.Transformers != nil {
+		return cs.Transformers
 	}
+	return DefaultReportTransformers()
+}
 
-	return fType, fFileName, fPath, err
+func (cs Phpcs) uploadToStorage(ctx context.Context, res *Result, filepath, filename string) (tide.AuditDetails, error) {
+	return uploadReport(ctx, meterStorage(cs.StorageProvider, res), filepath, filename, cs.MaxReportSize, cs.Compress)
+}
+
+// reportUploader writes report files to the temp folder before uploading them to storage.
+func (cs Phpcs) reportUploader(ctx context.Context, msg message.Message, res *Result, pathPrefix string) func(string, []byte) (tide.AuditDetails, error) {
+	return func(filename string, data []byte) (tide.AuditDetails, error) {
+		if cs.Redaction != nil {
+			red, err := cs.Redaction.redactor(msg, res.FilesPath)
+			if err != nil {
+				return tide.AuditDetails{}, err
+			}
+			data = []byte(red.redact(string(data)))
+		}
+
+		if err := writeFile(pathPrefix+filename, data, os.ModePerm); err != nil {
+			return tide.AuditDetails{}, err
+		}
+
+		return cs.uploadToStorage(ctx, res, pathPrefix+filename, filename)
+	}
 }
@@ -0,0 +1,108 @@
+package phpcs
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// DefaultOverviewLimit is the number of sources and files listed in an overview.
+const DefaultOverviewLimit = 10
+
+// GetPhpcsOverview returns the sniff sources with the most messages, the files with the most
+// errors, the totals of the messages and the percentage of fixable messages. At most limit
+// sources and files are listed.
+func GetPhpcsOverview(fullResults tide.PhpcsResults, limit int) *tide.PhpcsOverview {
+	builder := NewOverviewBuilder(limit)
+	for filename, data := range fullResults.Files {
+		builder.Add(filename, ReportFile(data))
+	}
+	return builder.Overview()
+}
+
+// OverviewBuilder builds the overview of a report one file at a time.
+type OverviewBuilder struct {
+	limit   int
+	sources map[string]*tide.PhpcsCount
+	files   map[string]*tide.PhpcsCount
+	total   tide.PhpcsOverview
+}
+
+// NewOverviewBuilder returns an OverviewBuilder that lists at most limit sources and files.
+func NewOverviewBuilder(limit int) *OverviewBuilder {
+	if limit <= 0 {
+		limit = DefaultOverviewLimit
+	}
+	return &OverviewBuilder{
+		limit:   limit,
+		sources: make(map[string]*tide.PhpcsCount),
+		files:   make(map[string]*tide.PhpcsCount),
+	}
+}
+
+// Add adds a file of the report to the overview.
+func (b *OverviewBuilder) Add(filename string, file ReportFile) {
+	b.total.FilesCount++
+
+	count := &tide.PhpcsCount{Name: filename, Count: file.Errors, Errors: file.Errors, Warnings: file.Warnings}
+	for _, msg := range file.Messages {
+		source, ok := b.sources[msg.Source]
+		if !ok {
+			source = &tide.PhpcsCount{Name: msg.Source}
+			b.sources[msg.Source] = source
+		}
+		source.Count++
+
+		isError := strings.EqualFold(msg.Type, "ERROR")
+		if isError {
+			source.Errors++
+			b.total.Errors++
+		} else {
+			source.Warnings++
+			b.total.Warnings++
+		}
+		if msg.Fixable {
+			source.Fixable++
+			count.Fixable++
+			b.total.Fixable++
+		}
+	}
+
+	if file.Errors > 0 {
+		b.files[filename] = count
+	}
+}
+
+// Overview returns the overview of the files that were added.
+func (b *OverviewBuilder) Overview() *tide.PhpcsOverview {
+	overview := b.total
+	overview.Sources = topCounts(b.sources, b.limit)
+	overview.Files = topCounts(b.files, b.limit)
+	if total := overview.Errors + overview.Warnings; total > 0 {
+		overview.FixablePercent = math.Round(float64(overview.Fixable)*1000/float64(total)) / 10
+	}
+
+	return &overview
+}
+
+// topCounts returns the highest counts in descending order, with ties in name order.
+func topCounts(counts map[string]*tide.PhpcsCount, limit int) []tide.PhpcsCount {
+	top := []tide.PhpcsCount{}
+	for _, count := range counts {
+		top = append(top, *count)
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Name < top[j].Name
+	})
+
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
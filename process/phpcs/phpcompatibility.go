@@ -44,93 +44,122 @@ type FilePosition struct {
 //
 // Process is required to implement audit.PostProcessor.
 func GetPhpcsCompatibility(fullResults tide.PhpcsResults) ([]string, []string, interface{}) {
+	builder := NewCompatibilityBuilder()
 
-	brokenVersions := []string{}
+	// Iterate files and only get summary data.
+	for filename, data := range fullResults.Files {
+		builder.Add(filename, ReportFile(data))
+	}
+
+	compatibleVersion, incompatibleVersion, details := builder.Compatibility()
+	details.Totals["errors"] = fullResults.Totals.Errors
+	details.Totals["warnings"] = fullResults.Totals.Warnings
+	return compatibleVersion, incompatibleVersion, details
+}
 
-	// Dynamically creating our struct for JSON output.
-	details := &PhpCompatDetails{
-		Totals: map[string]int{
-			"errors":   fullResults.Totals.Errors,
-			"warnings": fullResults.Totals.Warnings,
+// CompatibilityBuilder runs the PHPCompatibility post processing one file at a time.
+type CompatibilityBuilder struct {
+	details        *PhpCompatDetails
+	brokenVersions []string
+}
+
+// NewCompatibilityBuilder returns a CompatibilityBuilder without any files.
+func NewCompatibilityBuilder() *CompatibilityBuilder {
+	return &CompatibilityBuilder{
+		// Dynamically creating our struct for JSON output.
+		details: &PhpCompatDetails{
+			Totals: map[string]int{
+				"errors":   0,
+				"warnings": 0,
+			},
+			ErrorMap:   make(map[string][]string),
+			WarningMap: make(map[string][]string),
+			Errors:     make(map[string]PhpCompatDetailsViolation),
+			Warnings:   make(map[string]PhpCompatDetailsViolation),
 		},
-		ErrorMap:   make(map[string][]string),
-		WarningMap: make(map[string][]string),
-		Errors:     make(map[string]PhpCompatDetailsViolation),
-		Warnings:   make(map[string]PhpCompatDetailsViolation),
+		brokenVersions: []string{},
 	}
+}
 
-	// Iterate files and only get summary data.
-	for filename, data := range fullResults.Files {
-		for _, sniffMessage := range data.Messages {
-
-			if sniffMessage.Type == "ERROR" {
-				// Create the new Violation if we don't have it already.
-				// This happens only once because we group failures.
-				if _, ok := details.Errors[sniffMessage.Source]; !ok {
-					// Create the object.
-					violation := PhpCompatDetailsViolation{
-						Message:  sniffMessage.Message,
-						Source:   sniffMessage.Source,
-						Type:     sniffMessage.Type,
-						Severity: sniffMessage.Severity,
-						Files:    make(map[string][]FilePosition),
-					}
-
-					// Get incompatible versions
-					versions := phpcompat.BreaksVersions(sniffMessage)
-					violation.Versions = versions
-
-					// Add the source to each broken version.
-					for _, version := range versions {
-						details.ErrorMap[version] = append(details.ErrorMap[version], sniffMessage.Source)
-					}
-
-					// Add to broken versions so that we can determine compatibility later.
-					brokenVersions = phpcompat.MergeVersions(brokenVersions, versions)
-
-					details.Errors[sniffMessage.Source] = violation
+// Add adds the violations of a file of the report.
+func (b *CompatibilityBuilder) Add(filename string, file ReportFile) {
+	details := b.details
+	details.Totals["errors"] += file.Errors
+	details.Totals["warnings"] += file.Warnings
+
+	for _, sniffMessage := range file.Messages {
+
+		if sniffMessage.Type == "ERROR" {
+			// Create the new Violation if we don't have it already.
+			// This happens only once because we group failures.
+			if _, ok := details.Errors[sniffMessage.Source]; !ok {
+				// Create the object.
+				violation := PhpCompatDetailsViolation{
+					Message:  sniffMessage.Message,
+					Source:   sniffMessage.Source,
+					Type:     sniffMessage.Type,
+					Severity: sniffMessage.Severity,
+					Files:    make(map[string][]FilePosition),
 				}
 
-				// Each violating file needs to be added to the particular violation.
-				details.Errors[sniffMessage.Source].Files[filename] = append(
-					details.Errors[sniffMessage.Source].Files[filename],
-					FilePosition{
-						sniffMessage.Line,
-						sniffMessage.Column,
-					},
-				)
-			} else if sniffMessage.Type == "WARNING"  {
-				if _, ok := details.Warnings[sniffMessage.Source]; !ok {
-					violation := PhpCompatDetailsViolation{
-						Message:  sniffMessage.Message,
-						Source:   sniffMessage.Source,
-						Type:     sniffMessage.Type,
-						Severity: sniffMessage.Severity,
-						Files:    make(map[string][]FilePosition),
-					}
-
-					versions := phpcompat.NonBreakingVersions(sniffMessage)
-					violation.Versions = versions
-
-					for _, version := range versions {
-						details.WarningMap[version] = append(details.WarningMap[version], sniffMessage.Source)
-					}
-
-					details.Warnings[sniffMessage.Source] = violation
+				// Get incompatible versions
+				versions := phpcompat.BreaksVersions(sniffMessage)
+				violation.Versions = versions
+
+				// Add the source to each broken version.
+				for _, version := range versions {
+					details.ErrorMap[version] = append(details.ErrorMap[version], sniffMessage.Source)
 				}
 
-				details.Warnings[sniffMessage.Source].Files[filename] = append(
-					details.Warnings[sniffMessage.Source].Files[filename],
-					FilePosition{
-						sniffMessage.Line,
-						sniffMessage.Column,
-					},
-				)
+				// Add to broken versions so that we can determine compatibility later.
+				b.brokenVersions = phpcompat.MergeVersions(b.brokenVersions, versions)
+
+				details.Errors[sniffMessage.Source] = violation
 			}
+
+			// Each violating file needs to be added to the particular violation.
+			details.Errors[sniffMessage.Source].Files[filename] = append(
+				details.Errors[sniffMessage.Source].Files[filename],
+				FilePosition{
+					sniffMessage.Line,
+					sniffMessage.Column,
+				},
+			)
+		} else if sniffMessage.Type == "WARNING" {
+			if _, ok := details.Warnings[sniffMessage.Source]; !ok {
+				violation := PhpCompatDetailsViolation{
+					Message:  sniffMessage.Message,
+					Source:   sniffMessage.Source,
+					Type:     sniffMessage.Type,
+					Severity: sniffMessage.Severity,
+					Files:    make(map[string][]FilePosition),
+				}
+
+				versions := phpcompat.NonBreakingVersions(sniffMessage)
+				violation.Versions = versions
+
+				for _, version := range versions {
+					details.WarningMap[version] = append(details.WarningMap[version], sniffMessage.Source)
+				}
+
+				details.Warnings[sniffMessage.Source] = violation
+			}
+
+			details.Warnings[sniffMessage.Source].Files[filename] = append(
+				details.Warnings[sniffMessage.Source].Files[filename],
+				FilePosition{
+					sniffMessage.Line,
+					sniffMessage.Column,
+				},
+			)
 		}
 	}
+}
 
-	compatibleVersion := phpcompat.ExcludeVersions(phpcompat.PhpMajorVersions(), brokenVersions)
-	incompatibleVersion := brokenVersions
-	return compatibleVersion, incompatibleVersion, details
+// Compatibility returns the compatible and incompatible versions and the details of the files
+// that were added.
+func (b *CompatibilityBuilder) Compatibility() ([]string, []string, *PhpCompatDetails) {
+	compatibleVersion := phpcompat.ExcludeVersions(phpcompat.PhpMajorVersions(), b.brokenVersions)
+	incompatibleVersion := b.brokenVersions
+	return compatibleVersion, incompatibleVersion, b.details
 }
@@ -0,0 +1,125 @@
+package phpcs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// ReportFile is the report of a single file of a PHPCS JSON report.
+type ReportFile struct {
+	Errors   int                      `json:"errors"`
+	Warnings int                      `json:"warnings"`
+	Messages []tide.PhpcsFilesMessage `json:"messages"`
+}
+
+// ReportTotals are the totals of a PHPCS JSON report.
+type ReportTotals struct {
+	Errors   int `json:"errors"`
+	Warnings int `json:"warnings"`
+}
+
+// Results returns a report with only the file, e.g. to filter its messages like a whole report.
+func (f ReportFile) Results(name string) *tide.PhpcsResults {
+	results := &tide.PhpcsResults{}
+	results.Totals.Errors = f.Errors
+	results.Totals.Warnings = f.Warnings
+	results.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{name: {f.Errors, f.Warnings, f.Messages}}
+	return results
+}
+
+// WalkReport decodes a PHPCS JSON report one file at a time and calls fn for every file, so that
+// only the messages of a single file are in memory. It returns the totals of the report.
+func WalkReport(r io.Reader, fn func(name string, file ReportFile) error) (ReportTotals, error) {
+	var totals ReportTotals
+
+	decoder := json.NewDecoder(r)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return totals, err
+	}
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return totals, err
+		}
+
+		switch key {
+		case "totals":
+			if err := decoder.Decode(&totals); err != nil {
+				return totals, err
+			}
+
+		case "files":
+			if err := walkFiles(decoder, fn); err != nil {
+				return totals, err
+			}
+
+		default:
+			// Skip what is not needed, e.g. the fixable count of newer versions.
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return totals, err
+			}
+		}
+	}
+
+	return totals, expectDelim(decoder, '}')
+}
+
+// walkFiles decodes the "files" object of a report one file at a time.
+func walkFiles(decoder *json.Decoder, fn func(name string, file ReportFile) error) error {
+	// Reports without files have "files": [] or null.
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case nil:
+		return nil
+	case json.Delim('['):
+		return expectDelim(decoder, ']')
+	case json.Delim('{'):
+	default:
+		return errors.New("invalid report: files is not an object")
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		name, ok := token.(string)
+		if !ok {
+			return errors.New("invalid report: file name is not a string")
+		}
+
+		var file ReportFile
+		if err := decoder.Decode(&file); err != nil {
+			return err
+		}
+		if err := fn(name, file); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(decoder, '}')
+}
+
+// expectDelim reads the next token and verifies that it is the delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return errors.New("invalid report: expected " + delim.String())
+	}
+	return nil
+}
@@ -4,25 +4,51 @@ import "github.com/wptide/pkg/tide"
 
 // GetPhpcsSummary loops through all reported files and leaves only summary information.
 func GetPhpcsSummary(fullResults tide.PhpcsResults) *tide.PhpcsSummary {
-	summary := &tide.PhpcsSummary{
-		ErrorsCount:   fullResults.Totals.Errors,
-		WarningsCount: fullResults.Totals.Warnings,
-		FilesCount:    len(fullResults.Files),
-		Files: make(map[string]struct {
-			Errors   int `json:"errors"`
-			Warnings int `json:"warnings"`
-		}),
-	}
+	builder := NewSummaryBuilder()
 
 	// Iterate files and only get summary data.
 	for filename, data := range fullResults.Files {
-		summary.Files[filename] = struct {
-			Errors   int `json:"errors"`
-			Warnings int `json:"warnings"`
-		}{
-			data.Errors,
-			data.Warnings,
-		}
+		builder.Add(filename, ReportFile(data))
 	}
+
+	summary := builder.Summary()
+	summary.ErrorsCount = fullResults.Totals.Errors
+	summary.WarningsCount = fullResults.Totals.Warnings
 	return summary
 }
+
+// SummaryBuilder builds the summary of a report one file at a time.
+type SummaryBuilder struct {
+	summary *tide.PhpcsSummary
+}
+
+// NewSummaryBuilder returns a SummaryBuilder without any files.
+func NewSummaryBuilder() *SummaryBuilder {
+	return &SummaryBuilder{
+		summary: &tide.PhpcsSummary{
+			Files: make(map[string]struct {
+				Errors   int `json:"errors"`
+				Warnings int `json:"warnings"`
+			}),
+		},
+	}
+}
+
+// Add adds a file of the report to the summary.
+func (b *SummaryBuilder) Add(filename string, file ReportFile) {
+	b.summary.Files[filename] = struct {
+		Errors   int `json:"errors"`
+		Warnings int `json:"warnings"`
+	}{
+		file.Errors,
+		file.Warnings,
+	}
+	b.summary.ErrorsCount += file.Errors
+	b.summary.WarningsCount += file.Warnings
+	b.summary.FilesCount = len(b.summary.Files)
+}
+
+// Summary returns the summary of the files that were added.
+func (b *SummaryBuilder) Summary() *tide.PhpcsSummary {
+	return b.summary
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/shell"
 	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
 )
 
 type mockPhpcsRunner struct{}
@@ -198,13 +200,13 @@ func TestPhpcs_Run(t *testing.T) {
 		TempFolder:      "./testdata/tmp",
 		PhpcsVersions: map[string]map[string]string{
 			"phpcompatibility": {
-				"phpcs": "0.0.1-phpcs",
-				"phpcompatibility": "0.0.1-phpcompatibility",
+				"phpcs":              "0.0.1-phpcs",
+				"phpcompatibility":   "0.0.1-phpcompatibility",
 				"phpcompatibilitywp": "0.0.1-phpcompatibilitywp",
 			},
 			"wordpress": {
 				"phpcs": "0.0.1-phpcs",
-				"wpcs": "0.0.1-wpcs",
+				"wpcs":  "0.0.1-wpcs",
 			},
 		},
 	}
@@ -300,7 +302,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibility,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -328,7 +330,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsWordPress,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -345,10 +347,10 @@ func TestPhpcs_Run(t *testing.T) {
 				Out:             make(chan Processor),
 				StorageProvider: &mockStorage{},
 				TempFolder:      "./testdata/tmp",
-				PhpcsVersions:   map[string]map[string]string{
+				PhpcsVersions: map[string]map[string]string{
 					"wordpress": {
 						"phpcs": "0.0.1-phpcs",
-						"wpcs": "0.0.1-wpcs",
+						"wpcs":  "0.0.1-wpcs",
 					},
 				},
 			},
@@ -361,7 +363,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsWordPress,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -439,7 +441,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsWordPress,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "",
 					},
@@ -456,10 +458,10 @@ func TestPhpcs_Run(t *testing.T) {
 				Out:             make(chan Processor),
 				StorageProvider: &mockStorage{},
 				TempFolder:      "./testdata/tmp",
-				PhpcsVersions:   map[string]map[string]string{
+				PhpcsVersions: map[string]map[string]string{
 					"phpcompatibility": {
-						"phpcs": "0.0.1-phpcs",
-						"phpcompatibility": "0.0.1-phpcompatibility",
+						"phpcs":              "0.0.1-phpcs",
+						"phpcompatibility":   "0.0.1-phpcompatibility",
 						"phpcompatibilitywp": "0.0.1-phpcompatibilitywp",
 					},
 				},
@@ -473,7 +475,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibility,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -495,7 +497,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibilityOverride,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -517,7 +519,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsBoth,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -543,7 +545,7 @@ func TestPhpcs_Run(t *testing.T) {
 							},
 						},
 						Result: &Result{
-							"checksum": "1234567890",
+							Checksum: "1234567890",
 						},
 					},
 				},
@@ -564,7 +566,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsWordPress,
 						},
 						Result: &Result{
-							"checksum": "uploaderrorchecksum",
+							Checksum: "uploaderrorchecksum",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -600,7 +602,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsWordPress,
 						},
 						Result: &Result{
-							"checksum": "filereadererror",
+							Checksum: "filereadererror",
 						},
 						FilesPath: "./testdata/info/filereadererror",
 					},
@@ -622,7 +624,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibility,
 						},
 						Result: &Result{
-							"checksum": "phpcompatwriteerror",
+							Checksum: "phpcompatwriteerror",
 						},
 						FilesPath: "./testdata/info/phpcompatwriteerror",
 					},
@@ -644,7 +646,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibility,
 						},
 						Result: &Result{
-							"checksum": "phpcompatinternalerror",
+							Checksum: "phpcompatinternalerror",
 						},
 						FilesPath: "./testdata/info/phpcompatinternalerror",
 					},
@@ -666,7 +668,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibility,
 						},
 						Result: &Result{
-							"checksum": "phpcompatuploaderror",
+							Checksum: "phpcompatuploaderror",
 						},
 						FilesPath: "./testdata/info/phpcompatuploaderror",
 					},
@@ -701,7 +703,7 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibility,
 						},
 						Result: &Result{
-							"checksum": "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							Checksum: "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
 						},
 						FilesPath: "./testdata/info/plugin",
 					},
@@ -723,8 +725,8 @@ func TestPhpcs_Run(t *testing.T) {
 							Audits: auditsPhpCompatibility,
 						},
 						Result: &Result{
-							"checksum":  "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
-							"filesPath": "./testdata/info/plugin",
+							Checksum:  "39c7d71a68565ddd7b6a0fd68d94924d0db449a99541439b3ab8a477c5f1fc4e",
+							FilesPath: "./testdata/info/plugin",
 						},
 					},
 				},
@@ -736,10 +738,16 @@ func TestPhpcs_Run(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// Each process closes its out channel when it stops, so don't share them between tests.
+			out := tt.fields.Out
+			if out != nil {
+				out = make(chan Processor)
+			}
+
 			cs := &Phpcs{
 				Process:         tt.fields.Process,
 				In:              tt.fields.In,
-				Out:             tt.fields.Out,
+				Out:             out,
 				TempFolder:      tt.fields.TempFolder,
 				StorageProvider: tt.fields.StorageProvider,
 				PhpcsVersions:   tt.fields.PhpcsVersions,
@@ -752,7 +760,7 @@ func TestPhpcs_Run(t *testing.T) {
 
 			if !tt.mockRunner {
 				oldRunner := phpcsRunner
-				phpcsRunner = nil
+				phpcsRunner = defaultRunner
 				defer func() {
 					phpcsRunner = oldRunner
 				}()
@@ -760,7 +768,7 @@ func TestPhpcs_Run(t *testing.T) {
 
 			var err error
 			var chanError error
-			errc := make(chan error)
+			errc := make(ErrorChannel)
 
 			go func() {
 				for {
@@ -772,7 +780,7 @@ func TestPhpcs_Run(t *testing.T) {
 			}()
 
 			go func() {
-				err = cs.Run(&errc)
+				err = cs.Run(errc)
 			}()
 
 			// Sleep a short time delay to give process time to start.
@@ -802,3 +810,180 @@ func examplePhpcsPhpCompatibilityReport() string {
 	return `{"totals":{"errors":4,"warnings":0,"fixable":0},"files":{"phpcompat\/compatissues.php":{"errors":4,"warnings":0,"messages":[{"message":"\"namespace\" keyword is not present in PHP version 5.2 or earlier","source":"PHPCompatibility.PHP.NewKeywords.t_namespaceFound","severity":5,"type":"ERROR","line":3,"column":1,"fixable":false},{"message":"\"trait\" keyword is not present in PHP version 5.3 or earlier","source":"PHPCompatibility.PHP.NewKeywords.t_traitFound","severity":5,"type":"ERROR","line":8,"column":1,"fixable":false},{"message":"Short array syntax (open) is available since 5.4","source":"PHPCompatibility.PHP.ShortArray.Found","severity":5,"type":"ERROR","line":9,"column":9,"fixable":false},{"message":"Short array syntax (close) is available since 5.4","source":"PHPCompatibility.PHP.ShortArray.Found","severity":5,"type":"ERROR","line":9,"column":10,"fixable":false}]},"dummy-plugin.php":{"errors":0,"warnings":0,"messages":[]}}}
 `
 }
+
+func TestPhpcs_Do_FailureCode(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	cs := &Phpcs{
+		PhpcsVersions: map[string]map[string]string{
+			"wordpress": {"phpcs": "3.1.1"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		standard string
+		want     string
+	}{
+		{"No Standard", "", tide.FailureStandardMissing},
+		{"Standard Not Installed", "missing", tide.FailureStandardMissing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := message.Message{
+				Title: "Test",
+				Audits: []*message.Audit{
+					{Type: "phpcs", Options: &message.AuditOption{Standard: tt.standard}},
+				},
+			}
+
+			_, err := cs.Do(context.Background(), msg, NewResult())
+			if got := errorCode(err); got != tt.want {
+				t.Errorf("Phpcs.Do() error code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// hangingRunner runs until the context is done, after printing some output.
+type hangingRunner struct{}
+
+func (h hangingRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	return nil, nil, 0, errors.New("requires a context")
+}
+
+func (h hangingRunner) RunContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, error) {
+	<-ctx.Done()
+	return []byte("...."), []byte("Processing plugin.php"), -1, ctx.Err()
+}
+
+func TestPhpcs_Do_Timeout(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	for name, replayable := range map[string]bool{"Not Replayable": false, "Replayable": true} {
+		t.Run(name, func(t *testing.T) {
+			cs := &Phpcs{
+				TempFolder:      os.TempDir(),
+				StorageProvider: &mockStorage{},
+				PhpcsVersions:   map[string]map[string]string{"wordpress": {"phpcs": "3.1.1"}},
+				Runner:          hangingRunner{},
+				Timeout:         10 * time.Millisecond,
+				Replayable:      replayable,
+			}
+
+			msg := message.Message{
+				Title:  "Test",
+				Audits: []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}}},
+			}
+			res := NewResult()
+			res.Checksum = "checksum"
+			res.FilesPath = "/tmp/audit"
+
+			done := make(chan error)
+			go func() {
+				_, err := cs.Do(context.Background(), msg, res)
+				done <- err
+			}()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("Phpcs.Do() did not stop PHPCS")
+			}
+
+			if got := errorCode(err); got != tide.FailurePhpcsTimeout {
+				t.Errorf("Phpcs.Do() error code = %v, want %v", got, tide.FailurePhpcsTimeout)
+			}
+
+			audit, ok := res.Audit("phpcs_wordpress")
+			if !ok || audit.Status != tide.AuditStatusTimeout {
+				t.Fatalf("Phpcs.Do() audit = %v, want a %v status", audit, tide.AuditStatusTimeout)
+			}
+			if output := audit.Extra["output"]; output != "....\nProcessing plugin.php" {
+				t.Errorf("Phpcs.Do() partial output = %q", output)
+			}
+
+			// The options are only kept for replays.
+			if _, ok := audit.Extra["options"]; ok != replayable {
+				t.Errorf("Phpcs.Do() kept the options = %v, want %v", ok, replayable)
+			}
+		})
+	}
+}
+
+func TestPhpcs_timeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		options *message.AuditOption
+		want    time.Duration
+	}{
+		{"No Limit", 0, &message.AuditOption{}, 0},
+		{"Processor", time.Minute, &message.AuditOption{}, time.Minute},
+		{"Message Override", time.Minute, &message.AuditOption{Timeout: 300}, 5 * time.Minute},
+		{"No Options", time.Minute, nil, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (Phpcs{Timeout: tt.timeout}).timeout(tt.options); got != tt.want {
+				t.Errorf("Phpcs.timeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_partialOutput(t *testing.T) {
+	long := strings.Repeat("a", maxPartialOutput) + "end"
+	if got := partialOutput([]byte(long), nil); len(got) != maxPartialOutput || !strings.HasSuffix(got, "end") {
+		t.Errorf("partialOutput() kept %v bytes, want the last %v", len(got), maxPartialOutput)
+	}
+	if got := partialOutput(nil, []byte("Killed\n")); got != "Killed" {
+		t.Errorf("partialOutput() = %q", got)
+	}
+}
+
+func TestPhpcs_resources(t *testing.T) {
+	cpus := runtime.NumCPU()
+
+	tests := []struct {
+		name       string
+		cs         Phpcs
+		env        map[string]string
+		options    *message.AuditOption
+		wantPar    int
+		wantMemory string
+		wantErr    bool
+	}{
+		{"Defaults", Phpcs{}, nil, nil, 1, "-1", false},
+		{"Config", Phpcs{Config: map[string]interface{}{"parallel": 2}}, nil, nil, 2, "-1", false},
+		{"Environment", Phpcs{Config: map[string]interface{}{"parallel": 2}}, map[string]string{PhpcsParallelEnv: "3", PhpcsMemoryLimitEnv: "1G"}, nil, 3, "1G", false},
+		{"Process", Phpcs{Parallel: 4, MemoryLimit: "512M"}, map[string]string{PhpcsParallelEnv: "3", PhpcsMemoryLimitEnv: "1G"}, nil, 4, "512M", false},
+		{"Message", Phpcs{Parallel: 4, MemoryLimit: "1G"}, nil, &message.AuditOption{Parallel: 1, MemoryLimit: "512M"}, 1, "512M", false},
+		{"Message Limited To Worker", Phpcs{MemoryLimit: "512M"}, nil, &message.AuditOption{MemoryLimit: "2G"}, 1, "512M", false},
+		{"Message Without Limit", Phpcs{MemoryLimit: "512M"}, nil, &message.AuditOption{MemoryLimit: "-1"}, 1, "512M", false},
+		{"Worker Without Limit", Phpcs{}, nil, &message.AuditOption{MemoryLimit: "2G"}, 1, "2G", false},
+		{"Message Limited To CPUs", Phpcs{}, nil, &message.AuditOption{Parallel: cpus + 1}, cpus, "-1", false},
+		{"Invalid Memory Limit", Phpcs{}, nil, &message.AuditOption{MemoryLimit: "2G -d allow_url_include=1"}, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				os.Setenv(key, value)
+				defer os.Unsetenv(key)
+			}
+
+			parallel, memory, err := tt.cs.resources(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Phpcs.resources() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if parallel != tt.wantPar || memory != tt.wantMemory {
+				t.Errorf("Phpcs.resources() = %v, %v, want %v, %v", parallel, memory, tt.wantPar, tt.wantMemory)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/tide"
+)
+
+// FindingParseError is raised for PHP files that don't parse.
+const FindingParseError = "parse_error"
+
+var (
+	phplintRunner shell.Runner = defaultRunner
+
+	// Errors reported by `php -l`, e.g. "PHP Parse error:  syntax error, unexpected '}' in plugin.php on line 5".
+	lintErrorRe = regexp.MustCompile(`(?m)^(?:PHP )?(?:Parse|Fatal) error:\s*(.+) in (.+) on line (\d+)\s*$`)
+)
+
+// ParseError describes a PHP file that doesn't parse.
+type ParseError struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// PhpLint defines the structure for our PHP syntax check process. It runs `php -l` on the
+// PHP files before the PHP audits, so that projects that don't parse can be recognized.
+type PhpLint struct {
+	Process                  // Inherits methods from Process.
+	In      <-chan Processor // Expects a processor channel as input.
+	Out     chan Processor   // Send results to an output channel.
+	Runner  shell.Runner     // (Optional) Runs PHP. Defaults to shell.Command.
+	Command string           // (Optional) PHP binary. Defaults to "php".
+}
+
+// Run runs the process in a pipeline.
+func (pl *PhpLint) Run(sink ErrorSink) error {
+	if pl.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if pl.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	pl.runStage(sink, stage{name: "PhpLint", in: pl.In, out: pl.Out, do: pl.Do})
+
+	return nil
+}
+
+// Do checks the syntax of the PHP files if the message requests a "phplint" audit or any of
+// the PHPAuditTypes. The parse errors are recorded in the "phplint" audit and as findings.
+func (pl *PhpLint) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if !hasAudit(msg, "phplint") && !requestsPHPAudit(msg) {
+		return res, nil
+	}
+
+	if res == nil || res.FilesPath == "" {
+		return res, errors.New("could not determine files path")
+	}
+
+	if skipped(res, "phplint") || !hasPHPSources(res) {
+		return res, nil
+	}
+
+	log.Log(msg.Title, "Checking PHP syntax...")
+
+	runner := phplintRunner
+	if pl.Runner != nil {
+		runner = pl.Runner
+	}
+
+	command := pl.Command
+	if command == "" {
+		command = "php"
+	}
+
+	binary := make(map[string]bool)
+	for _, file := range res.BinaryFiles {
+		binary[file] = true
+	}
+
+	root := res.FilesPath + "/unzipped"
+	parseErrors := []ParseError{}
+	checked := 0
+
+	done := res.timeStage("phplint")
+	defer done()
+
+	filter := newPathFilter(auditOptions(msg, "phplint"))
+	for _, file := range res.Files {
+		if binary[file] || strings.ToLower(filepath.Ext(file)) != ".php" || !filter.audits(file) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return res, ctx.Err()
+		default:
+		}
+
+		out, errOut, _, err := runCommand(ctx, res, runner, command, "-l", "-d", "display_errors=1", "-d", "log_errors=0", file)
+		checked++
+
+		output := string(out) + "\n" + string(errOut)
+		if strings.Contains(output, "No syntax errors detected") {
+			continue
+		}
+
+		match := lintErrorRe.FindStringSubmatch(output)
+		if match == nil {
+			if err != nil {
+				return res, messageError(msg, "php lint failed: "+strings.TrimSpace(output+" "+err.Error()))
+			}
+			continue
+		}
+
+		line, _ := strconv.Atoi(match[3])
+		name := relativeName(root, file)
+		parseErrors = append(parseErrors, ParseError{
+			File:    name,
+			Line:    line,
+			Message: strings.TrimSpace(match[1]),
+		})
+		res.AddFinding(Finding{
+			Process:  "PhpLint",
+			Type:     FindingParseError,
+			File:     name,
+			Message:  fmt.Sprintf("line %d: %s", line, strings.TrimSpace(match[1])),
+			Severity: FindingWarning,
+		})
+	}
+
+	res.SetAudit("phplint", tide.AuditResult{
+		Extra: map[string]interface{}{
+			"errors":  parseErrors,
+			"checked": checked,
+		},
+	})
+
+	log.Log(msg.Title, fmt.Sprintf("PHP syntax check found %d of %d files with parse errors", len(parseErrors), checked))
+
+	return res, nil
+}
+
+// ParseErrors returns the parse errors found by PhpLint. Downstream processes can use it to
+// skip or annotate audits of projects that don't parse.
+func (r *Result) ParseErrors() []ParseError {
+	audit, ok := r.Audit("phplint")
+	if !ok {
+		return nil
+	}
+	parseErrors, _ := audit.Extra["errors"].([]ParseError)
+	return parseErrors
+}
+
+// requestsPHPAudit determines if the message requests any of the PHPAuditTypes.
+func requestsPHPAudit(msg message.Message) bool {
+	for _, audit := range msg.Audits {
+		if audit != nil && isPHPAudit(audit.Type) {
+			return true
+		}
+	}
+	return false
+}
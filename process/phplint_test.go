@@ -0,0 +1,195 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+// lintRunner returns the output of `php -l` for the file in its last argument.
+type lintRunner struct {
+	errors  map[string]string // Parse errors by file name.
+	missing bool              // PHP is not installed.
+	files   []string
+}
+
+func (r *lintRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	file := arg[len(arg)-1]
+	r.files = append(r.files, filepath.Base(file))
+	if r.missing {
+		return nil, nil, -1, errors.New(`exec: "php": executable file not found in $PATH`)
+	}
+	if text, ok := r.errors[filepath.Base(file)]; ok {
+		return []byte("PHP Parse error:  " + text + " in " + file + " on line 6\nErrors parsing " + file + "\n"), nil, 255, errors.New("exit status 255")
+	}
+	return []byte("No syntax errors detected in " + file + "\n"), nil, 0, nil
+}
+
+func TestPhpLint_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		lint    *PhpLint
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&PhpLint{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+			},
+			false,
+		},
+		{
+			"No In Channel",
+			&PhpLint{
+				Out: make(chan Processor),
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&PhpLint{
+				In: make(chan Processor),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.lint.SetContext(ctx)
+
+			if err := tt.lint.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("PhpLint.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPhpLint_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	lintMsg := message.Message{
+		Title:  "Lint Test Plugin",
+		Audits: []*message.Audit{{Type: "phplint"}},
+	}
+
+	files := []string{
+		"./testdata/security/unzipped/plugin.php",
+		"./testdata/security/unzipped/includes/class-admin.php",
+		"./testdata/security/unzipped/includes/readme.txt",
+	}
+
+	tests := []struct {
+		name      string
+		runner    *lintRunner
+		msg       message.Message
+		res       *Result
+		want      []ParseError
+		wantFiles []string
+		wantErr   bool
+	}{
+		{
+			"Not Requested",
+			&lintRunner{},
+			message.Message{Audits: []*message.Audit{{Type: "lighthouse"}}},
+			&Result{FilesPath: "./testdata/security", Files: files},
+			nil,
+			nil,
+			false,
+		},
+		{
+			"No Parse Errors",
+			&lintRunner{},
+			lintMsg,
+			&Result{FilesPath: "./testdata/security", Files: files},
+			[]ParseError{},
+			[]string{"plugin.php", "class-admin.php"},
+			false,
+		},
+		{
+			"Parse Error",
+			&lintRunner{errors: map[string]string{"class-admin.php": "syntax error, unexpected '}'"}},
+			lintMsg,
+			&Result{FilesPath: "./testdata/security", Files: files},
+			[]ParseError{{"includes/class-admin.php", 6, "syntax error, unexpected '}'"}},
+			[]string{"plugin.php", "class-admin.php"},
+			false,
+		},
+		{
+			"Before PHPCS",
+			&lintRunner{errors: map[string]string{"plugin.php": "syntax error, unexpected end of file"}},
+			message.Message{Audits: []*message.Audit{{Type: "phpcs"}}},
+			&Result{FilesPath: "./testdata/security", Files: files},
+			[]ParseError{{"plugin.php", 6, "syntax error, unexpected end of file"}},
+			[]string{"plugin.php", "class-admin.php"},
+			false,
+		},
+		{
+			"Binary Files Skipped",
+			&lintRunner{},
+			lintMsg,
+			&Result{FilesPath: "./testdata/security", Files: files, BinaryFiles: files[:1]},
+			[]ParseError{},
+			[]string{"class-admin.php"},
+			false,
+		},
+		{
+			"PHP Missing",
+			&lintRunner{missing: true},
+			lintMsg,
+			&Result{FilesPath: "./testdata/security", Files: files},
+			nil,
+			[]string{"plugin.php"},
+			true,
+		},
+		{
+			"No Files Path",
+			&lintRunner{},
+			lintMsg,
+			&Result{Files: files},
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lint := &PhpLint{Runner: tt.runner}
+			res, err := lint.Do(context.Background(), tt.msg, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PhpLint.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !reflect.DeepEqual(tt.runner.files, tt.wantFiles) {
+				t.Errorf("PhpLint.Do() linted %v, want %v", tt.runner.files, tt.wantFiles)
+			}
+
+			audit, ok := res.Audit("phplint")
+			if tt.want == nil {
+				if ok {
+					t.Errorf("PhpLint.Do() unexpected audit = %v", audit)
+				}
+				return
+			}
+
+			if got := res.ParseErrors(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Result.ParseErrors() = %v, want %v", got, tt.want)
+			}
+			if len(res.Findings) != len(tt.want) {
+				t.Errorf("PhpLint.Do() findings = %v", res.Findings)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/wptide/pkg/log"
+)
+
+// Pipeline runs processes as consecutive stages. The pipeline creates the channels between
+// the stages: the `Out` field of each stage is wired to the `In` field of the next one, so
+// only the input of the first stage, e.g. the message channel of an Ingest, needs to be set.
+//
+//	pipeline := process.NewPipeline(ingest, info, phpcs, response)
+//	err := pipeline.Run(ctx)
+//
+// If the pipeline keeps checkpoints, the result of each message is saved after every stage once
+// it has a checksum. A message that is received again, e.g. after the worker was killed, skips
+// the stages that completed before, from the stage that first set its checksum, e.g. Ingest.
+type Pipeline struct {
+	Errors      ErrorChannel    // (Optional) Shared error channel of the stages. It is closed when the pipeline finishes. Errors are logged if nil.
+	Checkpoints CheckpointStore // (Optional) Keeps the results of the messages in progress, so that they can be resumed.
+
+	stages   []Processor
+	err      error            // The first error while adding stages.
+	forwards []chan Processor // Outputs of the stages, forwarded by the pipeline if it keeps checkpoints.
+	inputs   []chan Processor // Inputs of the stages after the first, if the pipeline keeps checkpoints.
+	output   chan Processor   // Output of the last stage that was set before the pipeline kept checkpoints.
+}
+
+// NewPipeline returns a pipeline with the stages in order.
+func NewPipeline(stages ...Processor) *Pipeline {
+	p := &Pipeline{}
+	for _, stage := range stages {
+		p.AddStage(stage)
+	}
+	return p
+}
+
+// AddStage adds a stage after the stages that were already added and returns the pipeline,
+// so that calls can be chained. Adding a nil stage makes Run fail.
+func (p *Pipeline) AddStage(proc Processor) *Pipeline {
+	if v := reflect.ValueOf(proc); proc == nil || (v.Kind() == reflect.Ptr && v.IsNil()) {
+		if p.err == nil {
+			p.err = fmt.Errorf("could not add nil processor as stage %d", len(p.stages)+1)
+		}
+		return p
+	}
+	p.stages = append(p.stages, proc)
+	return p
+}
+
+// Stages returns the stages of the pipeline in order.
+func (p *Pipeline) Stages() []Processor {
+	return p.stages
+}
+
+// Run wires and starts the stages and blocks until all of them have finished, either because
+// the input of the first stage was closed or because the context was cancelled. If a stage
+// fails to start, the stages that are already running are stopped and the error is returned.
+func (p *Pipeline) Run(ctx context.Context) error {
+	if p.err != nil {
+		return p.err
+	}
+	if len(p.stages) == 0 {
+		return errors.New("pipeline has no stages")
+	}
+
+	if err := p.wire(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var sink ErrorSink = logSink{}
+	if p.Errors != nil {
+		sink = p.Errors
+		defer close(p.Errors)
+	}
+
+	for i, stage := range p.stages {
+		stage.SetContext(ctx)
+		if err := stage.Run(sink); err != nil {
+			// Stop the stages that are already running before reporting the error.
+			cancel()
+			waitStages(p.stages[:i])
+			return fmt.Errorf("stage %d (%s) could not start: %s", i+1, stageName(stage), err)
+		}
+	}
+
+	forwarded := p.forward(ctx)
+
+	waitStages(p.stages)
+	forwarded.Wait()
+
+	return nil
+}
+
+// wire connects the output of each stage to the input of the next one. If the last stage has
+// an output channel, it is drained so that the stage never blocks.
+func (p *Pipeline) wire() error {
+	if p.Checkpoints != nil {
+		return p.wireCheckpoints()
+	}
+
+	for i := 0; i < len(p.stages)-1; i++ {
+		ch := make(chan Processor)
+		if err := setChannel(p.stages[i], "Out", ch); err != nil {
+			return err
+		}
+		if err := setChannel(p.stages[i+1], "In", ch); err != nil {
+			return err
+		}
+	}
+
+	last := p.stages[len(p.stages)-1]
+	if field, ok := channelField(last, "Out"); ok && field.IsNil() {
+		ch := make(chan Processor)
+		field.Set(reflect.ValueOf(ch))
+		go func() {
+			for range ch {
+			}
+		}()
+	}
+
+	return nil
+}
+
+// wireCheckpoints creates separate output and input channels for the stages, so that the
+// pipeline can save checkpoints between the stages and skip the stages that completed before.
+func (p *Pipeline) wireCheckpoints() error {
+	p.forwards = make([]chan Processor, len(p.stages))
+	p.inputs = make([]chan Processor, len(p.stages))
+
+	for i, stage := range p.stages {
+		if i > 0 {
+			p.inputs[i] = make(chan Processor)
+			if err := setChannel(stage, "In", p.inputs[i]); err != nil {
+				return err
+			}
+		}
+
+		field, ok := channelField(stage, "Out")
+		if !ok && i < len(p.stages)-1 {
+			return fmt.Errorf("stage %s has no Out channel of processors", stageName(stage))
+		}
+		if ok {
+			if i == len(p.stages)-1 && !field.IsNil() {
+				output, ok := field.Interface().(chan Processor)
+				if !ok {
+					// The pipeline can't send to the output, so it can't keep track of the last stage.
+					continue
+				}
+				p.output = output
+			}
+			p.forwards[i] = make(chan Processor)
+			field.Set(reflect.ValueOf(p.forwards[i]))
+		}
+	}
+
+	return nil
+}
+
+// forward starts passing the output of each stage to the next stage that the message has not
+// completed, after saving its checkpoint. The wait group is done once all outputs were passed on.
+func (p *Pipeline) forward(ctx context.Context) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	if p.Checkpoints == nil {
+		return &wg
+	}
+
+	names := p.stageNames()
+	for i, out := range p.forwards {
+		if out == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, out chan Processor) {
+			defer wg.Done()
+
+			for proc := range out {
+				next := p.checkpoint(names, i, proc)
+
+				to := p.output
+				if next < len(p.stages) {
+					to = p.inputs[next]
+				}
+				if to == nil {
+					// The last stage completed and nobody reads its output.
+					continue
+				}
+
+				select {
+				case to <- proc:
+				case <-ctx.Done():
+				}
+			}
+
+			if i == len(p.stages)-1 && p.output != nil {
+				close(p.output)
+			}
+
+			// The stage only stops without being cancelled once the previous stages stopped, so
+			// nothing else sends to the next stage.
+			if ctx.Err() == nil && i+1 < len(p.stages) {
+				close(p.inputs[i+1])
+			}
+		}(i, out)
+	}
+
+	return &wg
+}
+
+// checkpoint saves the checkpoint of the message after the stage completed and returns the index
+// of the next stage to run. The checkpoint of a message is looked up once the message has a
+// checksum; if the message completed more stages before, it resumes after them.
+func (p *Pipeline) checkpoint(names []string, stage int, proc Processor) int {
+	res := proc.GetResult()
+	if res == nil || res.Checksum == "" {
+		return stage + 1
+	}
+	msg := proc.GetMessage()
+
+	if !res.checkpointed {
+		res.checkpointed = true
+		res.stages = append([]string{}, names[:stage+1]...)
+
+		cp, err := p.Checkpoints.Load(res.Checksum)
+		if err != nil {
+			log.Log(msg.Title, "Could not load checkpoint: "+err.Error())
+		}
+		if cp != nil && cp.resumes(msg, res.stages, len(names)) {
+			restored := cp.Result
+			restored.FilesPath = res.FilesPath
+			restored.held = res.held
+			restored.checkpointed = true
+			restored.stages = cp.Stages
+			proc.SetResults(restored)
+
+			log.Log(msg.Title, "Resuming after stage "+cp.Stages[len(cp.Stages)-1])
+			return len(cp.Stages)
+		}
+	} else {
+		res.stages = append(res.stages, names[stage])
+	}
+
+	if len(res.stages) >= len(names) {
+		// The message completed the pipeline, it no longer needs a checkpoint.
+		if err := p.Checkpoints.Delete(res.Checksum); err != nil {
+			log.Log(msg.Title, "Could not delete checkpoint: "+err.Error())
+		}
+		return len(names)
+	}
+
+	msg.ExternalRef = nil
+	checkpoint := Checkpoint{Checksum: res.Checksum, Message: msg, Stages: res.stages, Result: res}
+	if err := p.Checkpoints.Save(checkpoint); err != nil {
+		log.Log(msg.Title, "Could not save checkpoint: "+err.Error())
+	}
+
+	return len(res.stages)
+}
+
+// stageNames returns the names of the stages in the checkpoints, e.g. "Phpcs". Stages of the
+// same type are numbered, e.g. "Parallel#2".
+func (p *Pipeline) stageNames() []string {
+	names := make([]string, len(p.stages))
+	seen := make(map[string]int)
+	for i, stage := range p.stages {
+		name := stageName(stage)
+		seen[name]++
+		if seen[name] > 1 {
+			name += "#" + strconv.Itoa(seen[name])
+		}
+		names[i] = name
+	}
+	return names
+}
+
+// channelField returns the settable field of the stage with the name if it is a channel of processors.
+func channelField(proc Processor, name string) (reflect.Value, bool) {
+	v := reflect.ValueOf(proc)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	field := v.Elem().FieldByName(name)
+	if !field.IsValid() || !field.CanSet() || !reflect.TypeOf(make(chan Processor)).AssignableTo(field.Type()) {
+		return reflect.Value{}, false
+	}
+	return field, true
+}
+
+// setChannel sets the channel field of the stage with the name.
+func setChannel(proc Processor, name string, ch chan Processor) error {
+	field, ok := channelField(proc, name)
+	if !ok {
+		return fmt.Errorf("stage %s has no %s channel of processors", stageName(proc), name)
+	}
+	field.Set(reflect.ValueOf(ch))
+	return nil
+}
+
+// waitStages blocks until the stages have stopped. Stages that were not started are ignored.
+func waitStages(stages []Processor) {
+	for _, stage := range stages {
+		if done := stage.Done(); done != nil {
+			<-done
+		}
+	}
+}
+
+// stageName returns the name of the type of the stage, e.g. "Phpcs".
+func stageName(proc Processor) string {
+	t := reflect.TypeOf(proc)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// logSink is an ErrorSink that logs the errors.
+type logSink struct{}
+
+// Report logs the error.
+func (logSink) Report(err *Error) {
+	log.Log(err.Title, err.Error())
+}
@@ -0,0 +1,220 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+// relayStage records the messages it receives and passes them on.
+type relayStage struct {
+	Process
+	In       <-chan Processor
+	Out      chan Processor
+	Name     string
+	Fail     bool // Reports an error for every message.
+	StartErr error
+
+	mu     sync.Mutex
+	titles []string
+}
+
+func (r *relayStage) Run(sink ErrorSink) error {
+	if r.StartErr != nil {
+		return r.StartErr
+	}
+	if r.In == nil || r.Out == nil {
+		return errors.New("requires a previous and a next process")
+	}
+
+	r.start()
+
+	go func() {
+		defer r.stop(r.Out)
+
+		for {
+			select {
+			case <-r.getContext().Done():
+				return
+
+			case in, ok := <-r.In:
+				if !ok {
+					return
+				}
+
+				r.CopyFields(in)
+				res, err := r.Do(r.input())
+				if err != nil {
+					reportError(sink, res, NewError(r.Name, r.Message, err))
+				}
+				r.output(r.Name, res)
+
+				// Pass on a copy, so that the next stage does not race with the next message.
+				if !r.send(r.Out, &relayStage{Process: Process{Message: r.Message, Result: r.Result, FilesPath: r.FilesPath}}) {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (r *relayStage) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	r.mu.Lock()
+	r.titles = append(r.titles, msg.Title)
+	r.mu.Unlock()
+
+	if r.Fail {
+		return res, errors.New("relay failed")
+	}
+	return res, nil
+}
+
+func TestPipeline_Run(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	tests := []struct {
+		name       string
+		stages     []Processor
+		wantErr    bool
+		wantTitles []string // Titles received by every stage.
+	}{
+		{
+			"Three Stages",
+			[]Processor{&relayStage{Name: "first"}, &relayStage{Name: "second"}, &relayStage{Name: "third"}},
+			false,
+			[]string{"one", "two"},
+		},
+		{
+			"Single Stage",
+			[]Processor{&relayStage{Name: "only"}},
+			false,
+			[]string{"one", "two"},
+		},
+		{
+			"No Stages",
+			nil,
+			true,
+			nil,
+		},
+		{
+			"Nil Stage",
+			[]Processor{&relayStage{Name: "first"}, (*relayStage)(nil)},
+			true,
+			nil,
+		},
+		{
+			"Stage Without Channels",
+			[]Processor{&relayStage{Name: "first"}, &Ingest{}},
+			true,
+			nil,
+		},
+		{
+			"Stage Fails To Start",
+			[]Processor{&relayStage{Name: "first"}, &relayStage{Name: "second", StartErr: errors.New("no storage")}},
+			true,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := NewPipeline(tt.stages...)
+
+			// Feed the first stage with the messages and close its input.
+			in := make(chan Processor, 2)
+			if first, ok := firstRelay(tt.stages); ok {
+				first.In = in
+			}
+			for _, title := range []string{"one", "two"} {
+				in <- &relayStage{Process: Process{Message: message.Message{Title: title}}}
+			}
+			close(in)
+
+			err := pipeline.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Pipeline.Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			for _, stage := range pipeline.Stages() {
+				relay := stage.(*relayStage)
+				if !reflect.DeepEqual(relay.titles, tt.wantTitles) {
+					t.Errorf("Pipeline.Run() stage %v received %v, want %v", relay.Name, relay.titles, tt.wantTitles)
+				}
+			}
+		})
+	}
+}
+
+func TestPipeline_Run_Errors(t *testing.T) {
+	errc := make(ErrorChannel)
+	pipeline := NewPipeline().
+		AddStage(&relayStage{Name: "first"}).
+		AddStage(&relayStage{Name: "second", Fail: true})
+	pipeline.Errors = errc
+
+	in := make(chan Processor, 1)
+	pipeline.Stages()[0].(*relayStage).In = in
+	in <- &relayStage{Process: Process{Message: message.Message{Title: "one"}}}
+	close(in)
+
+	done := make(chan error)
+	go func() {
+		done <- pipeline.Run(context.Background())
+	}()
+
+	var got []string
+	for err := range errc {
+		got = append(got, err.Process)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Pipeline.Run() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"second"}) {
+		t.Errorf("Pipeline.Run() errors = %v", got)
+	}
+}
+
+func TestPipeline_Run_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The input is never closed, so the pipeline runs until it is cancelled.
+	in := make(chan Processor)
+	first := &relayStage{Name: "first", In: in}
+	pipeline := NewPipeline(first, &relayStage{Name: "second"})
+
+	done := make(chan error)
+	go func() {
+		done <- pipeline.Run(ctx)
+	}()
+
+	in <- &relayStage{Process: Process{Message: message.Message{Title: "one"}}}
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Errorf("Pipeline.Run() error = %v", err)
+	}
+}
+
+// firstRelay returns the first stage if it is a relayStage.
+func firstRelay(stages []Processor) (*relayStage, bool) {
+	if len(stages) == 0 {
+		return nil, false
+	}
+	first, ok := stages[0].(*relayStage)
+	return first, ok && first != nil
+}
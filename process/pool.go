@@ -0,0 +1,324 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// WorkerFactory returns a new instance of a process that receives from in and sends to out,
+// e.g. `func(in <-chan Processor, out chan Processor) Processor { return &Phpcs{In: in, Out: out, ...} }`.
+type WorkerFactory func(in <-chan Processor, out chan Processor) Processor
+
+// Pool runs a stage of the pipeline with several instances of a process, so that several
+// messages are processed at the same time. The number of workers can be changed while the
+// pool is running, e.g. by an Autotuner.
+type Pool struct {
+	Process                  // Inherits methods from Process.
+	In      <-chan Processor // Expects a processor channel as input.
+	Out     chan Processor   // Send results to an output channel.
+	New     WorkerFactory    // Creates the workers.
+	Stage   string           // (Optional) Timing recorded by the workers, e.g. "phpcs_wordpress". Used to measure latency.
+	Min     int              // (Optional) Fewest workers. Defaults to 1.
+	Max     int              // (Optional) Most workers. Defaults to Min.
+
+	mu        sync.Mutex
+	sink      ErrorSink
+	ins       []chan Processor // Input channels of the running workers.
+	retired   []chan Processor // Input channels of stopped workers, closed by the dispatcher.
+	changed   chan struct{}    // Signals the dispatcher that workers were started or stopped.
+	wg        sync.WaitGroup
+	running   bool
+	closing   bool
+	heldSince time.Time     // When the pool received a message that no worker accepted yet.
+	since     time.Time     // Start of the current stats window.
+	blocked   time.Duration // Time spent waiting for a free worker in the window.
+	latency   time.Duration // Total Stage timing of the messages sent in the window.
+	messages  int           // Number of messages sent in the window.
+}
+
+// PoolStats describes the load of a pool since the previous stats.
+type PoolStats struct {
+	Stage      string
+	Workers    int
+	Min        int
+	Max        int
+	Queued     int           // Messages waiting for a free worker.
+	Saturation float64       // Fraction of the time that messages waited for a free worker.
+	Latency    time.Duration // Average Stage timing of the processed messages.
+	Messages   int           // Number of processed messages.
+}
+
+// Run starts the minimum number of workers.
+func (p *Pool) Run(sink ErrorSink) error {
+	if p.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if p.Out == nil {
+		return errors.New("requires a next process")
+	}
+	if p.New == nil {
+		return errors.New("requires a worker factory")
+	}
+
+	p.mu.Lock()
+	p.sink = sink
+	p.changed = make(chan struct{}, 1)
+	p.since = now()
+	p.running = true
+	p.mu.Unlock()
+
+	if err := p.SetWorkers(p.min()); err != nil {
+		return err
+	}
+
+	p.start()
+
+	// The dispatcher holds the wait group until the previous process stops.
+	p.wg.Add(1)
+
+	go func() {
+		p.wg.Wait()
+		// Close the out channel and signal that we are done once all workers stopped.
+		p.stop(p.Out)
+	}()
+
+	go func() {
+		defer p.wg.Done()
+		defer p.closeInputs()
+
+		for {
+			select {
+			case <-p.getContext().Done():
+				// The pipeline has been cancelled.
+				return
+
+			case <-p.changed:
+				p.closeRetired()
+
+			case in, ok := <-p.In:
+				// The previous process has stopped.
+				if !ok {
+					return
+				}
+
+				if !p.dispatch(in) {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// dispatch hands the processor to the first idle worker. It returns false if the context
+// was cancelled before a worker was free.
+func (p *Pool) dispatch(proc Processor) bool {
+	p.mu.Lock()
+	p.heldSince = now()
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.blocked += now().Sub(p.heldSince)
+		p.heldSince = time.Time{}
+		p.mu.Unlock()
+	}()
+
+	for {
+		p.mu.Lock()
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(p.getContext().Done())},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(p.changed)},
+		}
+		for _, in := range p.ins {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(in), Send: reflect.ValueOf(proc)})
+		}
+		p.mu.Unlock()
+
+		switch chosen, _, _ := reflect.Select(cases); chosen {
+		case 0:
+			return false
+		case 1:
+			p.closeRetired()
+		default:
+			return true
+		}
+	}
+}
+
+// closeRetired closes the input channels of stopped workers, so that they stop once they
+// finished their message. Only the dispatcher sends to the input channels, so only it closes them.
+func (p *Pool) closeRetired() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, in := range p.retired {
+		close(in)
+	}
+	p.retired = nil
+}
+
+// closeInputs stops all workers once the dispatcher stopped.
+func (p *Pool) closeInputs() {
+	p.mu.Lock()
+	p.closing = true
+	p.retired = append(p.retired, p.ins...)
+	p.ins = nil
+	p.mu.Unlock()
+
+	p.closeRetired()
+}
+
+// Do processes a single message with a new worker.
+func (p *Pool) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if p.New == nil {
+		return res, errors.New("requires a worker factory")
+	}
+	return p.New(nil, nil).Do(ctx, msg, res)
+}
+
+// min returns the fewest workers of the pool.
+func (p *Pool) min() int {
+	if p.Min < 1 {
+		return 1
+	}
+	return p.Min
+}
+
+// max returns the most workers of the pool.
+func (p *Pool) max() int {
+	if p.Max < p.min() {
+		return p.min()
+	}
+	return p.Max
+}
+
+// Workers returns the number of running workers.
+func (p *Pool) Workers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ins)
+}
+
+// SetWorkers starts or stops workers until n are running. n is kept within Min and Max.
+// Stopped workers finish the message they are processing first.
+func (p *Pool) SetWorkers(n int) error {
+	if n < p.min() {
+		n = p.min()
+	}
+	if n > p.max() {
+		n = p.max()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.running {
+		return errors.New("pool is not running")
+	}
+	if p.closing {
+		return nil
+	}
+
+	for len(p.ins) < n {
+		in, err := p.addWorker()
+		if err != nil {
+			return err
+		}
+		p.ins = append(p.ins, in)
+	}
+
+	if len(p.ins) > n {
+		p.retired = append(p.retired, p.ins[n:]...)
+		p.ins = p.ins[:n]
+	}
+
+	// Let a waiting dispatcher hand work to the new workers or close the stopped ones.
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// addWorker starts a worker and returns its input channel. The lock must be held.
+func (p *Pool) addWorker() (chan Processor, error) {
+	in := make(chan Processor)
+	out := make(chan Processor)
+
+	worker := p.New(in, out)
+	worker.SetContext(p.getContext())
+	if err := worker.Run(p.sink); err != nil {
+		return nil, err
+	}
+
+	// Pass the results of the worker on until it stopped.
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		for proc := range out {
+			p.record(proc)
+			if !p.send(p.Out, proc) {
+				return
+			}
+		}
+	}()
+
+	return in, nil
+}
+
+// record adds the latency of a processed message to the stats.
+func (p *Pool) record(proc Processor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.messages++
+	if res := proc.GetResult(); res != nil && p.Stage != "" {
+		p.latency += res.Timings[p.Stage]
+	}
+}
+
+// Stats returns the load of the pool since the previous call and starts a new window.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := PoolStats{
+		Stage:    p.Stage,
+		Workers:  len(p.ins),
+		Min:      p.min(),
+		Max:      p.max(),
+		Queued:   len(p.In),
+		Messages: p.messages,
+	}
+	if !p.heldSince.IsZero() {
+		// Count the wait for a worker up to now, the message may wait a long time.
+		stats.Queued++
+		p.blocked += now().Sub(p.heldSince)
+		p.heldSince = now()
+	}
+	if p.messages > 0 {
+		stats.Latency = p.latency / time.Duration(p.messages)
+	}
+	if elapsed := now().Sub(p.since); elapsed > 0 {
+		stats.Saturation = float64(p.blocked) / float64(elapsed)
+		if stats.Saturation > 1 {
+			stats.Saturation = 1
+		}
+	}
+
+	p.since = now()
+	p.blocked = 0
+	p.latency = 0
+	p.messages = 0
+
+	return stats
+}
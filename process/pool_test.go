@@ -0,0 +1,202 @@
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/message"
+)
+
+// mockWorker passes processors on once release receives, and records its "mock" timing.
+type mockWorker struct {
+	Process
+	In      <-chan Processor
+	Out     chan Processor
+	release chan struct{}
+}
+
+func (w *mockWorker) Run(sink ErrorSink) error {
+	w.start()
+
+	go func() {
+		defer w.stop(w.Out)
+
+		for {
+			select {
+			case <-w.getContext().Done():
+				return
+			case in, ok := <-w.In:
+				if !ok {
+					return
+				}
+				w.CopyFields(in)
+				<-w.release
+				res, _ := w.Do(w.input())
+				res.AddTiming("mock", time.Second)
+				w.SetResults(res)
+				if !w.send(w.Out, w) {
+					return
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (w *mockWorker) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	return res, nil
+}
+
+func TestPool_Run(t *testing.T) {
+	factory := func(in <-chan Processor, out chan Processor) Processor {
+		return &mockWorker{In: in, Out: out}
+	}
+
+	tests := []struct {
+		name    string
+		p       *Pool
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Pool{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+				New: factory,
+			},
+			false,
+		},
+		{
+			"No In Channel",
+			&Pool{
+				Out: make(chan Processor),
+				New: factory,
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Pool{
+				In:  make(chan Processor),
+				New: factory,
+			},
+			true,
+		},
+		{
+			"No Factory",
+			&Pool{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.p.SetContext(ctx)
+
+			if err := tt.p.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Pool.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPool_SetWorkers(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+
+	if err := (&Pool{}).SetWorkers(1); err == nil {
+		t.Error("Pool.SetWorkers() should fail before the pool runs")
+	}
+
+	release := make(chan struct{})
+	in := make(chan Processor)
+	p := &Pool{
+		In:    in,
+		Out:   make(chan Processor),
+		Stage: "mock",
+		Min:   1,
+		Max:   3,
+		New: func(in <-chan Processor, out chan Processor) Processor {
+			return &mockWorker{In: in, Out: out, release: release}
+		},
+	}
+	p.SetContext(ctx)
+
+	if err := p.Run(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Workers(); got != 1 {
+		t.Errorf("Pool.Run() started %v workers, want 1", got)
+	}
+
+	// The only worker is busy, so the second message waits.
+	in <- &mockWorker{Process: Process{Message: message.Message{Title: "First"}}}
+	in <- &mockWorker{Process: Process{Message: message.Message{Title: "Second"}}}
+
+	deadline := time.Now().Add(time.Second)
+	stats := p.Stats()
+	for stats.Queued != 1 && time.Now().Before(deadline) {
+		stats = p.Stats()
+	}
+	if stats.Queued != 1 || stats.Workers != 1 {
+		t.Errorf("Pool.Stats() = %+v, want 1 queued message", stats)
+	}
+
+	// Another worker takes the waiting message.
+	p.SetWorkers(10)
+	if got := p.Workers(); got != 3 {
+		t.Errorf("Pool.SetWorkers() started %v workers, want Max", got)
+	}
+
+	titles := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		release <- struct{}{}
+		select {
+		case proc := <-p.Out:
+			titles[proc.GetMessage().Title] = true
+		case <-time.After(time.Second):
+			t.Fatal("Pool did not send the processed message")
+		}
+	}
+	if !titles["First"] || !titles["Second"] {
+		t.Errorf("Pool sent %v", titles)
+	}
+
+	if stats := p.Stats(); stats.Messages != 2 || stats.Latency != time.Second || stats.Queued != 0 {
+		t.Errorf("Pool.Stats() = %+v", stats)
+	}
+
+	p.SetWorkers(0)
+	if got := p.Workers(); got != 1 {
+		t.Errorf("Pool.SetWorkers() kept %v workers, want Min", got)
+	}
+
+	// The remaining worker still processes messages.
+	in <- &mockWorker{Process: Process{Message: message.Message{Title: "Third"}}}
+	release <- struct{}{}
+	select {
+	case proc := <-p.Out:
+		if proc.GetMessage().Title != "Third" {
+			t.Errorf("Pool sent %v", proc.GetMessage().Title)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pool did not send the processed message")
+	}
+
+	// The pool stops with the previous process.
+	close(in)
+	select {
+	case <-p.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Pool did not stop")
+	}
+	if _, ok := <-p.Out; ok {
+		t.Error("Pool did not close the out channel")
+	}
+}
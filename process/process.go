@@ -8,8 +8,11 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
 )
 
 var (
@@ -21,17 +24,20 @@ var (
 
 	// Using os.Open as a variable so that we can mock it in tests.
 	fileOpen = os.Open
-)
 
-// Result is an interface map of the processed results.
-type Result map[string]interface{}
+	// Using time.Now as a variable so that we can mock it in tests.
+	now = time.Now
+)
 
 // Process is the base for all processes.
 type Process struct {
 	context   context.Context
+	done      chan struct{}
 	Message   message.Message // Keeps track of the original message.
 	Result    *Result         // Passes along a Result object.
 	FilesPath string          // Path of files to audit.
+	started   time.Time       // When the current message started processing.
+	recorded  map[string]bool // Audit kinds of the result before the current message was processed.
 }
 
 // Run is a default implementation with an error nag. Not required, but serves as an example.
@@ -47,7 +53,51 @@ func (p *Process) SetContext(ctx context.Context) {
 
 // Error returns a new process error.
 func (p Process) Error(msg string) error {
-	return errors.New(p.Message.Title + ": " + msg)
+	return messageError(p.Message, msg)
+}
+
+// messageError returns a new error for the given message.
+func messageError(msg message.Message, text string) error {
+	return errors.New(msg.Title + ": " + text)
+}
+
+// Done returns a channel that is closed once the process has stopped running,
+// either because its context was cancelled or because its input channel was closed.
+// It returns nil if the process has not been started.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// start prepares the process to be run.
+func (p *Process) start() {
+	p.done = make(chan struct{})
+}
+
+// stop closes the output channel (if any) and signals that the process has stopped.
+func (p *Process) stop(out chan Processor) {
+	if out != nil {
+		close(out)
+	}
+	close(p.done)
+}
+
+// send passes the processor to the output channel. It returns false if the context
+// was cancelled before the next process could receive it.
+func (p *Process) send(out chan Processor, proc Processor) bool {
+	select {
+	case out <- proc:
+		return true
+	case <-p.getContext().Done():
+		return false
+	}
+}
+
+// getContext returns the context of the process, or a background context if none was set.
+func (p *Process) getContext() context.Context {
+	if p.context == nil {
+		return context.Background()
+	}
+	return p.context
 }
 
 // SetMessage is used to set the Message for this process (used for copying the message).
@@ -87,11 +137,107 @@ func (p *Process) CopyFields(proc Processor) {
 	p.SetFilesPath(proc.GetFilesPath())
 }
 
+// startTimer starts timing the current message.
+func (p *Process) startTimer() {
+	p.started = now()
+}
+
+// input returns the context, message and result to pass to Do() for the copied fields.
+// The result always references the files path of the previous process.
+func (p *Process) input() (context.Context, message.Message, *Result) {
+	p.startTimer()
+
+	res := p.Result
+	if res == nil {
+		res = NewResult()
+	}
+	if res.FilesPath == "" {
+		res.FilesPath = p.FilesPath
+	}
+
+	p.recorded = make(map[string]bool)
+	for kind := range res.Audits {
+		p.recorded[kind] = true
+	}
+
+	return p.getContext(), p.Message, res
+}
+
+// output stores the result returned by Do() so that it can be passed to the next process
+// and records how long the stage took and what it did with the requested audits.
+func (p *Process) output(stage string, res *Result) {
+	if res == nil {
+		return
+	}
+	if !p.started.IsZero() {
+		res.AddTiming(stage, now().Sub(p.started))
+	}
+	p.trail(stage, res)
+	p.SetResults(res)
+	p.SetFilesPath(res.FilesPath)
+}
+
+// trail records whether the stage handled, skipped or ignored each audit requested by the message,
+// so that audits that no stage handled can be detected.
+func (p *Process) trail(stage string, res *Result) {
+	for _, audit := range p.Message.Audits {
+		if audit == nil {
+			continue
+		}
+
+		kind := auditKind(audit)
+		entry := TrailEntry{Stage: stage, Status: TrailIgnored, Reason: "not handled"}
+
+		result, ok := res.Audit(kind)
+		switch {
+		case ok && p.recorded[kind]:
+			entry.Reason = "already recorded"
+		case ok && result.Status == tide.AuditStatusSkipped:
+			entry.Status = TrailSkipped
+			entry.Reason = result.Reason
+		case ok:
+			entry.Status = TrailHandled
+			entry.Reason = ""
+		}
+
+		res.addTrail(kind, entry)
+	}
+}
+
+// hasAudit determines if the message requests the given audit type.
+func hasAudit(msg message.Message, auditType string) bool {
+	for _, audit := range msg.Audits {
+		if audit != nil && audit.Type == auditType {
+			return true
+		}
+	}
+	return false
+}
+
+// PHPAuditTypes are the audit types that only audit PHP sources.
+var PHPAuditTypes = []string{"phpcs", "security", "database", "themecheck", "plugincheck"}
+
+// auditKind returns the kind of an audit, e.g. "phpcs_wordpress" or "lighthouse".
+func auditKind(audit *message.Audit) string {
+	kind := strings.ToLower(audit.Type)
+	if audit.Type == "phpcs" && audit.Options != nil {
+		kind += "_" + strings.ToLower(audit.Options.Standard)
+	}
+	return kind
+}
+
+// skipped determines if an earlier process marked the audit kind as skipped.
+func skipped(res *Result, kind string) bool {
+	audit, ok := res.Audit(kind)
+	return ok && audit.Status == tide.AuditStatusSkipped
+}
+
 // Processor is an interface for all processors.
 type Processor interface {
-	Run(*chan error) error
-	Do() error
+	Run(sink ErrorSink) error
+	Do(ctx context.Context, msg message.Message, res *Result) (*Result, error)
 	SetContext(ctx context.Context)
+	Done() <-chan struct{}
 	SetMessage(msg message.Message)
 	GetMessage() message.Message
 	SetResults(res *Result)
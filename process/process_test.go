@@ -4,8 +4,10 @@ import (
 	"context"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
 )
 
 func generateProcs(ctx context.Context, procs []Processor) <-chan Processor {
@@ -58,3 +60,154 @@ func TestProcess_Run(t *testing.T) {
 		})
 	}
 }
+
+func Test_hasAudit(t *testing.T) {
+	msg := message.Message{
+		Audits: []*message.Audit{
+			nil,
+			{
+				Type: "phpcs",
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		auditType string
+		want      bool
+	}{
+		{
+			"Has Audit",
+			"phpcs",
+			true,
+		},
+		{
+			"Missing Audit",
+			"lighthouse",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasAudit(msg, tt.auditType); got != tt.want {
+				t.Errorf("hasAudit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess_Done(t *testing.T) {
+	tests := []struct {
+		name   string
+		cancel bool
+		close  bool
+	}{
+		{
+			"Context Cancelled",
+			true,
+			false,
+		},
+		{
+			"In Channel Closed",
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+
+			in := make(chan Processor)
+			info := &Info{
+				In:  in,
+				Out: make(chan Processor),
+			}
+			info.SetContext(ctx)
+
+			if info.Done() != nil {
+				t.Errorf("Process.Done() should be nil before running")
+			}
+
+			if err := info.Run(nil); err != nil {
+				t.Fatalf("Info.Run() error = %v", err)
+			}
+
+			if tt.cancel {
+				cancelFunc()
+			}
+			if tt.close {
+				close(in)
+			}
+
+			select {
+			case <-info.Done():
+			case <-time.After(time.Second):
+				t.Fatalf("Process.Done() was not closed")
+			}
+
+			if _, ok := <-info.Out; ok {
+				t.Errorf("Info.Out should be closed")
+			}
+		})
+	}
+}
+
+func TestProcess_trail(t *testing.T) {
+	msg := message.Message{
+		Audits: []*message.Audit{
+			{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}},
+			{Type: "lighthouse"},
+			{Type: "security"},
+		},
+	}
+
+	res := NewResult()
+	stages := []struct {
+		stage string
+		do    func(res *Result)
+	}{
+		{"ingest", func(res *Result) {}},
+		{"info", func(res *Result) {
+			res.SetAudit("security", tide.AuditResult{Status: tide.AuditStatusSkipped, Reason: "no PHP sources"})
+		}},
+		{"phpcs", func(res *Result) {
+			res.SetAudit("phpcs_wordpress", tide.AuditResult{})
+		}},
+		{"response", func(res *Result) {}},
+	}
+	for _, s := range stages {
+		p := &Process{Message: msg, Result: res}
+		_, _, res = p.input()
+		s.do(res)
+		p.output(s.stage, res)
+	}
+
+	want := map[string][]TrailEntry{
+		"phpcs_wordpress": {
+			{"ingest", TrailIgnored, "not handled"},
+			{"info", TrailIgnored, "not handled"},
+			{"phpcs", TrailHandled, ""},
+			{"response", TrailIgnored, "already recorded"},
+		},
+		"lighthouse": {
+			{"ingest", TrailIgnored, "not handled"},
+			{"info", TrailIgnored, "not handled"},
+			{"phpcs", TrailIgnored, "not handled"},
+			{"response", TrailIgnored, "not handled"},
+		},
+		"security": {
+			{"ingest", TrailIgnored, "not handled"},
+			{"info", TrailSkipped, "no PHP sources"},
+			{"phpcs", TrailIgnored, "already recorded"},
+			{"response", TrailIgnored, "already recorded"},
+		},
+	}
+	if !reflect.DeepEqual(res.Trail, want) {
+		t.Errorf("Process.output() trail = %v, want %v", res.Trail, want)
+	}
+
+	if got := res.Unhandled(msg); !reflect.DeepEqual(got, []string{"lighthouse"}) {
+		t.Errorf("Result.Unhandled() = %v, want [lighthouse]", got)
+	}
+}
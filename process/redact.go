@@ -0,0 +1,262 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+)
+
+// DefaultRedactionReplacement replaces redacted values.
+const DefaultRedactionReplacement = "[redacted]"
+
+var (
+	// hostname returns the host name of the worker.
+	hostname = os.Hostname
+
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Redaction removes details of the workers from results and raw reports before they are sent,
+// e.g. absolute temp paths, host names and configured sensitive values. Paths below the files
+// path of a result are made relative to the audited project, other paths and values are
+// replaced with the Replacement.
+type Redaction struct {
+	Paths       []string            // (Optional) Absolute paths to redact, e.g. the temp folders of the processes. The files path of each result is always redacted.
+	Hostnames   []string            // (Optional) Host names to redact. Defaults to the host name of the worker.
+	Patterns    []string            // (Optional) Regular expressions of sensitive values, e.g. credentials in URLs.
+	Clients     map[string][]string // (Optional) Additional patterns for the messages of a client, keyed by the message's RequestClient.
+	Replacement string              // (Optional) Defaults to DefaultRedactionReplacement.
+}
+
+// Validate checks that the patterns of the redaction are valid regular expressions.
+func (r *Redaction) Validate() error {
+	patterns := append([]string{}, r.Patterns...)
+	for _, clientPatterns := range r.Clients {
+		patterns = append(patterns, clientPatterns...)
+	}
+
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return errors.New("invalid redaction pattern: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// Redact returns a copy of the payload data with every string redacted for the message.
+// Values keep their types, so payload builders can use the data as before.
+func (r *Redaction) Redact(msg message.Message, filesPath string, data map[string]interface{}) (map[string]interface{}, error) {
+	red, err := r.redactor(msg, filesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	redacted, _ := redactValue(reflect.ValueOf(data), red.redact).Interface().(map[string]interface{})
+	return redacted, nil
+}
+
+// redactFile writes a redacted copy of the file next to it and returns its path.
+func (r *Redaction) redactFile(msg message.Message, filesPath, path string) (string, error) {
+	red, err := r.redactor(msg, filesPath)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	redactedPath := path + ".redacted"
+	if err := writeFile(redactedPath, []byte(red.redact(string(data))), 0644); err != nil {
+		return "", err
+	}
+	return redactedPath, nil
+}
+
+// key returns a hash of the redaction of the message, so that reports redacted with other
+// paths, host names, patterns or replacements are told apart, e.g. for Phpcs.ReuseReports.
+func (r *Redaction) key(msg message.Message) string {
+	replacement := r.Replacement
+	if replacement == "" {
+		replacement = DefaultRedactionReplacement
+	}
+
+	hostnames := r.Hostnames
+	if hostnames == nil {
+		if name, err := hostname(); err == nil && name != "" {
+			hostnames = []string{name}
+		}
+	}
+
+	data, _ := json.Marshal(struct {
+		Paths       []string `json:"paths"`
+		Hostnames   []string `json:"hostnames"`
+		Patterns    []string `json:"patterns"`
+		Client      []string `json:"client"`
+		Replacement string   `json:"replacement"`
+	}{r.Paths, hostnames, r.Patterns, r.Clients[msg.RequestClient], replacement})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactor redacts strings for a message.
+type redactor struct {
+	paths       *strings.Replacer
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+// redactor returns the redactor for the message and the files path of its result.
+func (r *Redaction) redactor(msg message.Message, filesPath string) (*redactor, error) {
+	replacement := r.Replacement
+	if replacement == "" {
+		replacement = DefaultRedactionReplacement
+	}
+
+	paths := r.Paths
+	if filesPath != "" {
+		paths = append([]string{filesPath}, paths...)
+	}
+
+	var pairs []string
+	if filesPath != "" {
+		// The names of the audited files are useful, their location on the worker is not.
+		root := strings.TrimRight(filesPath, "/")
+		pairs = append(pairs, root+"/unzipped/", "", root+"/", "")
+	}
+
+	// Longer paths first, so that nested paths are replaced as a whole.
+	var cleaned []string
+	for _, path := range paths {
+		if path = strings.TrimRight(path, "/"); path != "" {
+			cleaned = append(cleaned, path)
+		}
+	}
+	sort.Slice(cleaned, func(i, j int) bool { return len(cleaned[i]) > len(cleaned[j]) })
+	for _, path := range cleaned {
+		pairs = append(pairs, path, replacement)
+	}
+
+	hostnames := r.Hostnames
+	if hostnames == nil {
+		if name, err := hostname(); err == nil && name != "" {
+			hostnames = []string{name}
+		}
+	}
+
+	patterns := append([]string{}, r.Patterns...)
+	patterns = append(patterns, r.Clients[msg.RequestClient]...)
+	for _, name := range hostnames {
+		if name != "" {
+			patterns = append(patterns, `\b`+regexp.QuoteMeta(name)+`\b`)
+		}
+	}
+
+	red := &redactor{
+		paths:       strings.NewReplacer(pairs...),
+		replacement: replacement,
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.New("invalid redaction pattern: " + err.Error())
+		}
+		red.patterns = append(red.patterns, re)
+	}
+
+	return red, nil
+}
+
+// redact returns the string with the paths and patterns replaced.
+func (r *redactor) redact(s string) string {
+	s = r.paths.Replace(s)
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, r.replacement)
+	}
+	return s
+}
+
+// redactValue returns a copy of the value with every string, including map keys, byte slices
+// and error messages, redacted. Unexported struct fields are copied as they are.
+func redactValue(v reflect.Value, redact func(string) string) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(redact(v.String()))
+		return out
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(redactValue(v.Elem(), redact))
+		return out
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		if v.Type() == errorType {
+			// The fields of errors are usually unexported, so only their message is kept.
+			return reflect.ValueOf(errors.New(redact(v.Interface().(error).Error())))
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(redactValue(v.Elem(), redact))
+		return out
+
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if field := out.Field(i); field.CanSet() {
+				field.Set(redactValue(v.Field(i), redact))
+			}
+		}
+		return out
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			// Byte slices, e.g. json.RawMessage, are redacted as text.
+			return reflect.ValueOf([]byte(redact(string(v.Bytes())))).Convert(v.Type())
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i), redact))
+		}
+		return out
+
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(redactValue(v.Index(i), redact))
+		}
+		return out
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		for _, key := range v.MapKeys() {
+			out.SetMapIndex(redactValue(key, redact), redactValue(v.MapIndex(key), redact))
+		}
+		return out
+	}
+
+	return v
+}
@@ -0,0 +1,229 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/payload"
+	"github.com/wptide/pkg/tide"
+)
+
+// dataPayloader records the data of the payloads it builds.
+type dataPayloader struct {
+	data map[string]interface{}
+}
+
+func (d *dataPayloader) BuildPayload(msg message.Message, data map[string]interface{}) ([]byte, error) {
+	d.data = data
+	return json.Marshal(data)
+}
+
+func (d *dataPayloader) SendPayload(destination string, payload []byte) ([]byte, error) {
+	return []byte(`{"status":"ok"}`), nil
+}
+
+func TestRedaction_Redact(t *testing.T) {
+	oldHostname := hostname
+	hostname = func() (string, error) { return "worker-7", nil }
+	defer func() { hostname = oldHostname }()
+
+	data := map[string]interface{}{
+		"checksum":  "abc123",
+		"filesPath": "/tmp/tide/abc123",
+		"files":     []string{"/tmp/tide/abc123/unzipped/plugin.php"},
+		"info": tide.CodeInfo{
+			Type:    "plugin",
+			Details: []tide.InfoDetails{{Key: "Name", Value: "Test Plugin"}},
+		},
+		"phpcs_wordpress": tide.AuditResult{
+			Raw: tide.AuditDetails{FileName: "abc123-phpcs_wordpress-raw.json"},
+			Extra: map[string]interface{}{
+				"error": "could not read /var/reports/abc123.json on worker-7",
+			},
+		},
+		"errors":   []*Error{{Process: "Ingest", Err: errors.New("token=secret123 rejected")}},
+		"failures": []tide.Failure{{Code: tide.FailureStorage, Message: "token=secret123 rejected"}},
+	}
+
+	tests := []struct {
+		name      string
+		redaction Redaction
+		msg       message.Message
+		want      map[string]string // JSON of the redacted entries.
+		wantErr   bool
+	}{
+		{
+			"Paths And Hostname",
+			Redaction{Paths: []string{"/var/reports/"}},
+			message.Message{},
+			map[string]string{
+				"filesPath":       `"[redacted]"`,
+				"files":           `["plugin.php"]`,
+				"phpcs_wordpress": `could not read [redacted]/abc123.json on [redacted]`,
+				"errors":          `token=secret123 rejected`,
+			},
+			false,
+		},
+		{
+			"Client Patterns",
+			Redaction{
+				Hostnames:   []string{},
+				Clients:     map[string][]string{"wporg": {`token=\w+`}},
+				Replacement: "***",
+			},
+			message.Message{RequestClient: "wporg"},
+			map[string]string{
+				"phpcs_wordpress": `could not read /var/reports/abc123.json on worker-7`,
+				"errors":          `*** rejected`,
+			},
+			false,
+		},
+		{
+			"Other Client",
+			Redaction{
+				Hostnames: []string{},
+				Clients:   map[string][]string{"wporg": {`token=\w+`}},
+			},
+			message.Message{RequestClient: "other"},
+			map[string]string{
+				"errors": `token=secret123 rejected`,
+			},
+			false,
+		},
+		{
+			"Invalid Pattern",
+			Redaction{Patterns: []string{`token=(`}},
+			message.Message{},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.redaction.Redact(tt.msg, "/tmp/tide/abc123/", data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Redaction.Redact() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// Payload builders rely on the types of the values.
+			if failures, ok := got["failures"].([]tide.Failure); !ok || failures[0].Message != got["errors"].([]*Error)[0].Err.Error() {
+				t.Errorf("Redaction.Redact() failures = %v, errors = %v", got["failures"], got["errors"])
+			}
+			if _, ok := got["info"].(tide.CodeInfo); !ok {
+				t.Errorf("Redaction.Redact() info = %T", got["info"])
+			}
+			if _, ok := got["phpcs_wordpress"].(tide.AuditResult); !ok {
+				t.Errorf("Redaction.Redact() audit = %T", got["phpcs_wordpress"])
+			}
+
+			for key, want := range tt.want {
+				value, _ := json.Marshal(got[key])
+				if !strings.Contains(string(value), want) {
+					t.Errorf("Redaction.Redact() %v = %s, want %v", key, value, want)
+				}
+			}
+
+			// The original data is not modified.
+			if data["files"].([]string)[0] != "/tmp/tide/abc123/unzipped/plugin.php" {
+				t.Errorf("Redaction.Redact() modified the data: %v", data["files"])
+			}
+		})
+	}
+}
+
+func TestRedaction_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction *Redaction
+		wantErr   bool
+	}{
+		{"Empty", &Redaction{}, false},
+		{"Valid Patterns", &Redaction{Patterns: []string{`key=\w+`}, Clients: map[string][]string{"wporg": {`\d{4}`}}}, false},
+		{"Invalid Pattern", &Redaction{Patterns: []string{`[`}}, true},
+		{"Invalid Client Pattern", &Redaction{Clients: map[string][]string{"wporg": {`(`}}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.redaction.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Redaction.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedaction_redactFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "redact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.json")
+	report := `{"files":{"` + dir + `/unzipped/plugin.php":{"errors":1}}}`
+	if err := ioutil.WriteFile(path, []byte(report), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	redaction := &Redaction{Hostnames: []string{}}
+	redacted, err := redaction.redactFile(message.Message{}, dir, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := ioutil.ReadFile(redacted)
+	if want := `{"files":{"plugin.php":{"errors":1}}}`; string(got) != want {
+		t.Errorf("Redaction.redactFile() = %s, want %s", got, want)
+	}
+
+	// The report that is parsed keeps its paths.
+	if original, _ := ioutil.ReadFile(path); string(original) != report {
+		t.Errorf("Redaction.redactFile() modified the report: %s", original)
+	}
+}
+
+func TestResponse_Do_Redaction(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	payloader := &dataPayloader{}
+	res := &Response{
+		Payloaders: map[string]payload.Payloader{"mock": payloader},
+		Redaction:  &Redaction{Hostnames: []string{}},
+	}
+
+	result := NewResult()
+	result.FilesPath = "/tmp/tide/abc123"
+	result.Files = []string{"/tmp/tide/abc123/unzipped/plugin.php"}
+	result.Info = &tide.CodeInfo{Type: "plugin"}
+
+	if _, err := res.Do(context.Background(), message.Message{Title: "Test", PayloadType: "mock"}, result); err != nil {
+		t.Fatalf("Response.Do() error = %v", err)
+	}
+
+	if got := payloader.data["files"]; !reflect.DeepEqual(got, []string{"plugin.php"}) {
+		t.Errorf("Response.Do() files = %v", got)
+	}
+	if _, ok := payloader.data["info"].(tide.CodeInfo); !ok {
+		t.Errorf("Response.Do() info = %T", payloader.data["info"])
+	}
+
+	// The result itself is not redacted, later processes may still need the paths.
+	if result.Files[0] != "/tmp/tide/abc123/unzipped/plugin.php" {
+		t.Errorf("Response.Do() modified the result: %v", result.Files)
+	}
+}
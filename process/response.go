@@ -1,23 +1,36 @@
 package process
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/wptide/pkg/export"
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/payload"
+	"github.com/wptide/pkg/policy"
+	"github.com/wptide/pkg/trend"
 )
 
 // Response defines the structure for a Response process.
 // This determines where the processed results will be sent.
 type Response struct {
-	Process                                 // Inherits methods from Process.
-	In         <-chan Processor             // Expects a processor channel as input.
-	Out        chan Processor               // (Optional) Send results to an output channel.
-	Payloaders map[string]payload.Payloader // A map of "Payloader"s for different services.
+	Process                                         // Inherits methods from Process.
+	In                 <-chan Processor             // Expects a processor channel as input.
+	Out                chan Processor               // (Optional) Send results to an output channel.
+	Payloaders         map[string]payload.Payloader // A map of "Payloader"s keyed by payload type, e.g. payload.TypeWebhook.
+	DefaultPayloadType string                       // (Optional) Payload type for messages without one. Defaults to payload.TypeTide.
+	Policy             *policy.Policy               // (Optional) Policy used to add a pass/fail verdict to the results.
+	Meter              *UsageMeter                  // (Optional) Adds up the resources used for each client, keyed by the message's RequestClient.
+	Exporter           *export.Exporter             // (Optional) Exports the result documents that were sent, e.g. to BigQuery.
+	Redaction          *Redaction                   // (Optional) Redacts worker details, e.g. temp paths, from the results before they are sent.
+	Trends             *trend.Tracker               // (Optional) Adds the changes since the previous version of the project to the results.
 }
 
 // Run executes the process in a pipe.
-func (res *Response) Run(errc *chan error) error {
+func (res *Response) Run(sink ErrorSink) error {
 
 	if res.In == nil {
 		return errors.New("requires a previous process")
@@ -27,67 +40,138 @@ func (res *Response) Run(errc *chan error) error {
 		return errors.New("need to provide at least one payload manager")
 	}
 
-	go func() {
-		for {
-			select {
-			case in := <-res.In:
-
-				// Copy Process fields from `in` process.
-				res.CopyFields(in)
-
-				// Run the process.
-				// If processing produces an error send it up the error channel.
-				if err := res.Do(); err != nil {
-					// Pass the error up the error channel.
-					*errc <- errors.New("Response Error: " + err.Error())
-					// Don't break, the message is still useful to other processes.
-				}
-
-				// Send process to the out channel.
-				if res.Out != nil {
-					res.Out <- res
-				}
-			}
+	if res.Redaction != nil {
+		if err := res.Redaction.Validate(); err != nil {
+			return err
 		}
+	}
 
-	}()
+	res.runStage(sink, stage{name: "Response", in: res.In, out: res.Out, do: res.settle, last: true})
 
 	return nil
 }
 
-// Do executes the process.
-func (res *Response) Do() error {
+// settle sends the result and settles the lease of the message: it is acknowledged once the
+// result was sent, and given up otherwise. It is requeued if the error is retryable, so that the
+// queue delivers it again.
+func (res *Response) settle(ctx context.Context, msg message.Message, result *Result) (*Result, error) {
+	result, err := res.Do(ctx, msg, result)
+	if msg.Lease == nil {
+		return result, err
+	}
+
+	var settleErr error
+	if err == nil {
+		settleErr = msg.Lease.Ack()
+	} else {
+		settleErr = msg.Lease.Nack(NewError("Response", msg, err).Retryable())
+	}
+	if settleErr != nil {
+		log.Log(msg.Title, "Could not settle message: "+settleErr.Error())
+	}
+	return result, err
+}
+
+// Do sends the result to the payload destination and returns the result with the response details.
+func (res *Response) Do(ctx context.Context, msg message.Message, result *Result) (*Result, error) {
+
+	if result == nil {
+		return result, errors.New("no result to send")
+	}
 
-	result := *res.Result
+	// This is the last process for the project, so let other workers audit it again.
+	defer func() {
+		if err := result.releaseLock(); err != nil {
+			log.Log(msg.Title, "Could not release project lock: "+err.Error())
+		}
+	}()
 
-	payloadType := res.Message.PayloadType
+	// Each message selects its destination, falling back to the default.
+	payloadType := msg.PayloadType
 	if payloadType == "" {
-		// This is temporary, in future there will be no fallback.
-		// Ensure all tasks include the PayloadType.
-		// @todo An empty payloadType should return an error in future.
-		payloadType = "tide"
+		payloadType = res.DefaultPayloadType
+	}
+	if payloadType == "" {
+		payloadType = payload.TypeTide
 	}
 
 	payloader, ok := res.Payloaders[payloadType]
 	if !ok {
-		return errors.New("Could not find a valid payload generator for task")
+		return result, errors.New("Could not find a valid payload generator for task")
 	}
 
-	p, err := payloader.BuildPayload(res.Message, result)
+	// Audits that no process handled point to a misconfigured pipeline.
+	if unhandled := result.Unhandled(msg); len(unhandled) != 0 {
+		log.Log(msg.Title, "No process handled the requested audits: "+strings.Join(unhandled, ", "))
+	}
+
+	if res.Meter != nil && result.Usage != nil {
+		res.Meter.Record(msg.RequestClient, *result.Usage)
+	}
+
+	if res.Policy != nil {
+		verdict := res.Policy.Evaluate(policy.Input{
+			Audits:  result.Audits,
+			Reports: result.reports,
+		})
+		result.Verdict = &verdict
+		log.Log(msg.Title, fmt.Sprintf("Policy verdict: %s", verdict.Result))
+	}
+
+	if res.Trends != nil && msg.Slug != "" {
+		res.addTrend(msg, result)
+	}
+
+	data := result.Map()
+	if res.Redaction != nil {
+		var err error
+		if data, err = res.Redaction.Redact(msg, result.FilesPath, data); err != nil {
+			return result, err
+		}
+	}
+
+	p, err := payloader.BuildPayload(msg, data)
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	reply, err := payloader.SendPayload(res.Message.ResponseAPIEndpoint, p)
+	done := result.timeStage("submit")
+	reply, err := payloader.SendPayload(msg.ResponseAPIEndpoint, p)
+	done()
 	if err != nil {
-		return err
+		return result, err
 	}
 
-	result["response"] = string(reply)
-	result["responseMessage"] = fmt.Sprintf("'%s' payload submitted successfully.", payloadType)
-	result["responseSuccess"] = true
+	result.Response = string(reply)
+	result.ResponseMessage = fmt.Sprintf("'%s' payload submitted successfully.", payloadType)
+	result.ResponseSuccess = true
 
-	res.Result = &result
+	if res.Exporter != nil {
+		item, err := payload.NewItem(msg, data)
+		if err != nil {
+			log.Log(msg.Title, "Could not export result: "+err.Error())
+		} else {
+			res.Exporter.Export(*item)
+		}
+	}
 
-	return nil
+	return result, nil
+}
+
+// addTrend adds the changes since the previous version of the project to the result. The
+// results are still sent if the trend cannot be determined.
+func (res *Response) addTrend(msg message.Message, result *Result) {
+	item, err := payload.NewItem(msg, result.Map())
+	if err != nil {
+		return
+	}
+
+	t, err := res.Trends.Trend(msg.Slug, *item)
+	if err != nil {
+		log.Log(msg.Title, "Could not determine trend: "+err.Error())
+		return
+	}
+
+	result.Set("trend", *t)
+	log.Log(msg.Title, fmt.Sprintf("Trend since the previous version: %s", t.Direction))
 }
@@ -4,13 +4,19 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/wptide/pkg/export"
 	"github.com/wptide/pkg/log"
 	"github.com/wptide/pkg/message"
 	"github.com/wptide/pkg/payload"
+	"github.com/wptide/pkg/policy"
+	"github.com/wptide/pkg/tide"
+	"github.com/wptide/pkg/trend"
 )
 
 type MockPayloader struct{}
@@ -202,7 +208,7 @@ func TestResponse_Run(t *testing.T) {
 
 			var err error
 			var chanError error
-			errc := make(chan error)
+			errc := make(ErrorChannel)
 
 			go func() {
 				for {
@@ -214,7 +220,7 @@ func TestResponse_Run(t *testing.T) {
 			}()
 
 			go func() {
-				err = tc.Run(&errc)
+				err = tc.Run(errc)
 			}()
 
 			// Sleep a short time delay to give process time to start.
@@ -231,3 +237,376 @@ func TestResponse_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestResponse_Do(t *testing.T) {
+
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	res := &Response{
+		Payloaders: map[string]payload.Payloader{
+			"mock": MockPayloader{},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		msg         message.Message
+		result      *Result
+		wantSuccess bool
+		wantErr     bool
+	}{
+		{
+			"Valid Payload",
+			message.Message{
+				Title:       "Test",
+				PayloadType: "mock",
+			},
+			NewResult(),
+			true,
+			false,
+		},
+		{
+			"No Result",
+			message.Message{
+				Title:       "Test",
+				PayloadType: "mock",
+			},
+			nil,
+			false,
+			true,
+		},
+		{
+			"Send Fail",
+			message.Message{
+				Title:               "Test",
+				PayloadType:         "mock",
+				ResponseAPIEndpoint: "http://test.local/sendfail",
+			},
+			NewResult(),
+			false,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := res.Do(context.Background(), tt.msg, tt.result)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Response.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != nil && got.ResponseSuccess != tt.wantSuccess {
+				t.Errorf("Response.Do() ResponseSuccess = %v, want %v", got.ResponseSuccess, tt.wantSuccess)
+			}
+		})
+	}
+}
+
+// leaseQueue records how its messages are settled.
+type leaseQueue struct {
+	mockQueue
+	settled []string
+}
+
+func (q *leaseQueue) Ack(ref *string) error {
+	q.settled = append(q.settled, "ack")
+	return nil
+}
+
+func (q *leaseQueue) Nack(ref *string, requeue bool) error {
+	q.settled = append(q.settled, fmt.Sprintf("nack requeue=%v", requeue))
+	return nil
+}
+
+func (q *leaseQueue) ExtendLease(ref *string, d time.Duration) error {
+	return nil
+}
+
+func TestResponse_settle(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	res := &Response{
+		Payloaders: map[string]payload.Payloader{
+			"mock": MockPayloader{},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		endpoint    string
+		wantSettled []string
+	}{
+		{"Sent", "", []string{"ack"}},
+		{"Send Fail", "http://test.local/sendfail", []string{"nack requeue=false"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &leaseQueue{}
+			ref := "receipt"
+			msg := message.Message{Title: "Test", PayloadType: "mock", ResponseAPIEndpoint: tt.endpoint, Lease: message.NewLease(queue, &ref)}
+
+			res.settle(context.Background(), msg, NewResult())
+
+			// The lease is only settled once.
+			msg.Lease.Ack()
+			if !reflect.DeepEqual(queue.settled, tt.wantSettled) {
+				t.Errorf("Response.settle() settled %v, want %v", queue.settled, tt.wantSettled)
+			}
+		})
+	}
+
+	// Messages without a lease are sent as they are.
+	if got, err := res.settle(context.Background(), message.Message{Title: "Test", PayloadType: "mock"}, NewResult()); err != nil || !got.ResponseSuccess {
+		t.Errorf("Response.settle() = %v, %v", got, err)
+	}
+}
+
+func TestResponse_Do_ReleasesLock(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	res := &Response{
+		Payloaders: map[string]payload.Payloader{
+			"mock": MockPayloader{},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		endpoint string
+	}{
+		{"Sent", ""},
+		{"Send Fail", "http://test.local/sendfail"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			held := &mockLocker{}
+			result := NewResult()
+			result.held = held
+
+			res.Do(context.Background(), message.Message{Title: "Test", PayloadType: "mock", ResponseAPIEndpoint: tt.endpoint}, result)
+
+			if !held.released || result.held != nil {
+				t.Errorf("Response.Do() did not release the project lock")
+			}
+		})
+	}
+}
+
+func TestResponse_Do_Policy(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	res := &Response{
+		Payloaders: map[string]payload.Payloader{
+			"mock": MockPayloader{},
+		},
+		Policy: &policy.Policy{
+			Name:  "directory",
+			Rules: []policy.Rule{policy.CompatibilityRule{Version: "7.4"}},
+		},
+	}
+
+	result := NewResult()
+	result.SetAudit("phpcs_phpcompatibility", tide.AuditResult{IncompatibleVersions: []string{"7.4"}})
+
+	result, err := res.Do(context.Background(), message.Message{Title: "Test", PayloadType: "mock"}, result)
+	if err != nil {
+		t.Fatalf("Response.Do() error = %v", err)
+	}
+
+	want := tide.Verdict{
+		Result:  tide.VerdictFail,
+		Policy:  "directory",
+		Reasons: []string{"project is not compatible with PHP 7.4"},
+	}
+	if result.Verdict == nil || !reflect.DeepEqual(*result.Verdict, want) {
+		t.Errorf("Response.Do() verdict = %v, want %v", result.Verdict, want)
+	}
+	if got := result.Map()["verdict"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Result.Map() verdict = %v, want %v", got, want)
+	}
+}
+
+func TestResponse_Do_Meter(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	res := &Response{
+		Payloaders: map[string]payload.Payloader{
+			"mock": MockPayloader{},
+		},
+		Meter: &UsageMeter{},
+	}
+
+	msg := message.Message{Title: "Test", PayloadType: "mock", RequestClient: "client"}
+	for i := 0; i < 2; i++ {
+		result := NewResult()
+		result.AddUsage(Usage{CPU: time.Second, Uploaded: 10, Objects: 1})
+		if _, err := res.Do(context.Background(), msg, result); err != nil {
+			t.Fatalf("Response.Do() error = %v", err)
+		}
+	}
+
+	// Results without usage are not recorded.
+	res.Do(context.Background(), message.Message{Title: "Test", PayloadType: "mock", RequestClient: "other"}, NewResult())
+
+	want := map[string]Usage{"client": {CPU: 2 * time.Second, Uploaded: 20, Objects: 2}}
+	if got := res.Meter.Totals(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Response.Do() usage = %v, want %v", got, want)
+	}
+}
+
+func TestResponse_Do_PayloadType(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	payloaders := map[string]payload.Payloader{
+		payload.TypeTide:    MockPayloader{},
+		payload.TypeWebhook: MockPayloader{},
+	}
+
+	tests := []struct {
+		name        string
+		defaultType string
+		payloadType string
+		wantMessage string
+		wantErr     bool
+	}{
+		{"Message Type", payload.TypeTide, payload.TypeWebhook, "'webhook' payload submitted successfully.", false},
+		{"Default Type", payload.TypeWebhook, "", "'webhook' payload submitted successfully.", false},
+		{"Fallback Type", "", "", "'tide' payload submitted successfully.", false},
+		{"Unknown Type", "", payload.TypeStorage, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Response{
+				Payloaders:         payloaders,
+				DefaultPayloadType: tt.defaultType,
+			}
+
+			got, err := res.Do(context.Background(), message.Message{Title: "Test", PayloadType: tt.payloadType}, NewResult())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Response.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got.ResponseMessage != tt.wantMessage {
+				t.Errorf("Response.Do() ResponseMessage = %v, want %v", got.ResponseMessage, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestResponse_Do_Exporter(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	res := &Response{
+		Payloaders: map[string]payload.Payloader{
+			"mock": MockPayloader{},
+		},
+		Exporter: &export.Exporter{},
+	}
+
+	result := NewResult()
+	result.Checksum = "checksum"
+	result.Info = &tide.CodeInfo{Type: "plugin"}
+	result.SetAudit("phpcs_wordpress", tide.AuditResult{})
+
+	msg := message.Message{Title: "Test", Slug: "test", PayloadType: "mock"}
+	if _, err := res.Do(context.Background(), msg, result); err != nil {
+		t.Fatalf("Response.Do() error = %v", err)
+	}
+
+	// Results without code info can't be exported, but are still sent.
+	if _, err := res.Do(context.Background(), msg, NewResult()); err != nil {
+		t.Fatalf("Response.Do() error = %v", err)
+	}
+
+	if got := res.Exporter.Pending(); got != 1 {
+		t.Errorf("Response.Do() exported %v rows, want 1", got)
+	}
+}
+
+// trendStore returns the stored results of every slug.
+type trendStore []tide.Item
+
+func (s trendStore) Items(slug string) ([]tide.Item, error) {
+	return s, nil
+}
+
+func TestResponse_Do_Trend(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	previous := tide.Item{
+		Version:  "1.0",
+		Checksum: "previous",
+		Reports: map[string]tide.AuditResult{
+			"phpcs_wordpress": {Summary: tide.AuditSummary{PhpcsSummary: &tide.PhpcsSummary{ErrorsCount: 5}}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		slug string
+		want *tide.Trend
+	}{
+		{
+			"Improving",
+			"test-plugin",
+			&tide.Trend{
+				Direction:   tide.TrendImproving,
+				Previous:    &tide.TrendPoint{Version: "1.0", Checksum: "previous", Errors: 5},
+				ErrorsDelta: -3,
+				History:     []tide.TrendPoint{{Version: "1.0", Checksum: "previous", Errors: 5}},
+			},
+		},
+		{
+			"No Slug",
+			"",
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payloader := &dataPayloader{}
+			res := &Response{
+				Payloaders: map[string]payload.Payloader{"mock": payloader},
+				Trends:     &trend.Tracker{Store: trendStore{previous}},
+			}
+
+			result := NewResult()
+			result.Checksum = "current"
+			result.Info = &tide.CodeInfo{Type: "plugin", Details: []tide.InfoDetails{{Key: "Version", Value: "1.1"}}}
+			result.SetAudit("phpcs_wordpress", tide.AuditResult{
+				Summary: tide.AuditSummary{PhpcsSummary: &tide.PhpcsSummary{ErrorsCount: 2}},
+			})
+
+			msg := message.Message{Title: "Test", Slug: tt.slug, PayloadType: "mock"}
+			if _, err := res.Do(context.Background(), msg, result); err != nil {
+				t.Fatalf("Response.Do() error = %v", err)
+			}
+
+			got, ok := payloader.data["trend"].(tide.Trend)
+			if tt.want == nil {
+				if ok {
+					t.Errorf("Response.Do() trend = %v", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(&got, tt.want) {
+				t.Errorf("Response.Do() trend = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
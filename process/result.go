@@ -0,0 +1,298 @@
+package process
+
+import (
+	"time"
+
+	"github.com/wptide/pkg/lock"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// Result describes the processed results for a message as it moves through the pipeline.
+type Result struct {
+	Checksum        string                        `json:"checksum,omitempty"`
+	ChecksumExclude []string                      `json:"checksumExclude,omitempty"` // Patterns of files left out of the checksum.
+	Files           []string                      `json:"files,omitempty"`
+	FileChecksums   map[string]string             `json:"fileChecksums,omitempty"` // Checksums of the files, by path relative to the extracted source.
+	FilesPath       string                        `json:"filesPath,omitempty"`
+	BinaryFiles     []string                      `json:"binaryFiles,omitempty"`
+	Info            *tide.CodeInfo                `json:"info,omitempty"`
+	Audits          map[string]tide.AuditResult   `json:"audits,omitempty"`
+	Screenshots     map[string]string             `json:"screenshots,omitempty"`
+	Inventory       *InventoryReport              `json:"inventory,omitempty"`
+	Libraries       []Library                     `json:"libraries,omitempty"`
+	Verdict         *tide.Verdict                 `json:"verdict,omitempty"`
+	Environment     *tide.Environment             `json:"environment,omitempty"`
+	Timings         map[string]time.Duration      `json:"timings,omitempty"` // Durations of the stages, e.g. "download" or "phpcs_wordpress".
+	Usage           *Usage                        `json:"usage,omitempty"`   // Resources used to process the message.
+	Response        string                        `json:"response,omitempty"`
+	ResponseMessage string                        `json:"responseMessage,omitempty"`
+	ResponseSuccess bool                          `json:"responseSuccess,omitempty"`
+	Errors          []*Error                      `json:"errors,omitempty"`
+	Findings        []Finding                     `json:"findings,omitempty"`
+	Trail           map[string][]TrailEntry       `json:"trail,omitempty"` // What each stage did with the requested audits, by audit kind.
+	Extra           map[string]interface{}        `json:"extra,omitempty"`
+	held            lock.Lock                     // Lock held while the project is being audited.
+	aborted         bool                          // An earlier stage could not process the message, see abort.
+	reports         map[string]*tide.PhpcsResults // Parsed PHPCS reports, kept for evaluating policies.
+	checkpointed    bool                          // The checkpoint of the message was looked up.
+	stages          []string                      // Stages of the pipeline that completed, if it keeps checkpoints.
+}
+
+// Statuses of trail entries.
+const (
+	TrailHandled = "handled" // The stage recorded the audit.
+	TrailSkipped = "skipped" // The stage recorded the audit as skipped, e.g. because there are no PHP sources.
+	TrailIgnored = "ignored" // The stage does not run the audit, or it was already recorded.
+)
+
+// TrailEntry records what a stage of the pipeline did with a requested audit.
+type TrailEntry struct {
+	Stage  string `json:"stage"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// NewResult returns an empty Result that is ready to be used.
+func NewResult() *Result {
+	return &Result{
+		Audits: make(map[string]tide.AuditResult),
+		Extra:  make(map[string]interface{}),
+	}
+}
+
+// Audit returns the result for the given audit kind (e.g. "lighthouse" or "phpcs_wordpress").
+func (r *Result) Audit(kind string) (tide.AuditResult, bool) {
+	if r == nil || r.Audits == nil {
+		return tide.AuditResult{}, false
+	}
+	audit, ok := r.Audits[kind]
+	return audit, ok
+}
+
+// SetAudit records the result for the given audit kind.
+func (r *Result) SetAudit(kind string, audit tide.AuditResult) {
+	if r.Audits == nil {
+		r.Audits = make(map[string]tide.AuditResult)
+	}
+	r.Audits[kind] = audit
+}
+
+// setReport keeps the parsed PHPCS report for the given audit kind. A nil report marks a report
+// that was too large to keep, see Phpcs.StreamReports.
+func (r *Result) setReport(kind string, report *tide.PhpcsResults) {
+	if r.reports == nil {
+		r.reports = make(map[string]*tide.PhpcsResults)
+	}
+	r.reports[kind] = report
+}
+
+// SetScreenshot records the storage reference of the screenshot for the given viewport.
+func (r *Result) SetScreenshot(viewport, reference string) {
+	if r.Screenshots == nil {
+		r.Screenshots = make(map[string]string)
+	}
+	r.Screenshots[viewport] = reference
+}
+
+// AddTiming adds the duration of a stage. Stages that run more than once (e.g. "upload") are summed.
+func (r *Result) AddTiming(stage string, d time.Duration) {
+	if r.Timings == nil {
+		r.Timings = make(map[string]time.Duration)
+	}
+	r.Timings[stage] += d
+}
+
+// timeStage starts timing a stage and returns the function that records its duration.
+func (r *Result) timeStage(stage string) func() {
+	started := now()
+	return func() {
+		r.AddTiming(stage, now().Sub(started))
+	}
+}
+
+// AddUsage adds resources used while processing the message.
+func (r *Result) AddUsage(u Usage) {
+	if r.Usage == nil {
+		r.Usage = &Usage{}
+	}
+	r.Usage.Add(u)
+}
+
+// AddError records an error that occurred while processing the message.
+func (r *Result) AddError(err *Error) {
+	r.Errors = append(r.Errors, err)
+}
+
+// Failures returns the machine-readable failures of the errors recorded for the message.
+func (r *Result) Failures() []tide.Failure {
+	var failures []tide.Failure
+	for _, err := range r.Errors {
+		if err != nil {
+			failures = append(failures, err.Failure())
+		}
+	}
+	return failures
+}
+
+// AddFinding records an informational finding about the processed files.
+func (r *Result) AddFinding(finding Finding) {
+	r.Findings = append(r.Findings, finding)
+}
+
+// addTrail records what a stage did with the requested audit kind.
+func (r *Result) addTrail(kind string, entry TrailEntry) {
+	if r.Trail == nil {
+		r.Trail = make(map[string][]TrailEntry)
+	}
+	r.Trail[kind] = append(r.Trail[kind], entry)
+}
+
+// Unhandled returns the audit kinds requested by the message that no stage handled or
+// skipped, e.g. because the pipeline has no process for them.
+func (r *Result) Unhandled(msg message.Message) []string {
+	var kinds []string
+	for _, audit := range msg.Audits {
+		if audit == nil {
+			continue
+		}
+
+		kind := auditKind(audit)
+		accounted := false
+		for _, entry := range r.Trail[kind] {
+			if entry.Status == TrailHandled || entry.Status == TrailSkipped {
+				accounted = true
+				break
+			}
+		}
+		if !accounted {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// abort marks the message as one that the later stages cannot process, e.g. because its source
+// could not be downloaded. The audit stages pass it on, so that its errors are still sent and
+// retried by the stages that settle messages, e.g. Response and Retry.
+func (r *Result) abort() {
+	if r != nil {
+		r.aborted = true
+	}
+}
+
+// releaseLock releases the project lock (if any) so that other workers can audit the project.
+func (r *Result) releaseLock() error {
+	if r == nil || r.held == nil {
+		return nil
+	}
+	err := r.held.Release()
+	r.held = nil
+	return err
+}
+
+// Get returns an extra value that is not covered by the typed fields.
+func (r *Result) Get(key string) (interface{}, bool) {
+	if r == nil || r.Extra == nil {
+		return nil, false
+	}
+	value, ok := r.Extra[key]
+	return value, ok
+}
+
+// GetString returns an extra value as a string. The second value is false if the
+// key does not exist or is not a string.
+func (r *Result) GetString(key string) (string, bool) {
+	value, ok := r.Get(key)
+	if !ok {
+		return "", false
+	}
+	str, ok := value.(string)
+	return str, ok
+}
+
+// Set stores an extra value that is not covered by the typed fields.
+func (r *Result) Set(key string, value interface{}) {
+	if r.Extra == nil {
+		r.Extra = make(map[string]interface{})
+	}
+	r.Extra[key] = value
+}
+
+// Map flattens the result into the map format used by payload builders.
+// Audits are added with their kind as the key.
+func (r *Result) Map() map[string]interface{} {
+	data := make(map[string]interface{})
+	if r == nil {
+		return data
+	}
+
+	for key, value := range r.Extra {
+		data[key] = value
+	}
+
+	for kind, audit := range r.Audits {
+		data[kind] = audit
+	}
+
+	data["checksum"] = r.Checksum
+
+	if len(r.ChecksumExclude) != 0 {
+		data["checksumExclude"] = r.ChecksumExclude
+	}
+	data["files"] = r.Files
+	data["filesPath"] = r.FilesPath
+
+	if r.Info != nil {
+		data["info"] = *r.Info
+	}
+
+	if len(r.Errors) != 0 {
+		data["errors"] = r.Errors
+		data["failures"] = r.Failures()
+	}
+
+	if len(r.Screenshots) != 0 {
+		data["screenshots"] = r.Screenshots
+	}
+
+	if r.Inventory != nil {
+		data["inventory"] = *r.Inventory
+	}
+
+	if len(r.Libraries) != 0 {
+		data["libraries"] = r.Libraries
+	}
+
+	if r.Environment != nil {
+		data["environment"] = *r.Environment
+	}
+
+	if len(r.Timings) != 0 {
+		data["timings"] = r.Timings
+	}
+
+	if r.Usage != nil {
+		data["usage"] = *r.Usage
+	}
+
+	if r.Verdict != nil {
+		data["verdict"] = *r.Verdict
+	}
+
+	if len(r.Findings) != 0 {
+		data["findings"] = r.Findings
+	}
+
+	if len(r.Trail) != 0 {
+		data["trail"] = r.Trail
+	}
+
+	if r.Response != "" {
+		data["response"] = r.Response
+		data["responseMessage"] = r.ResponseMessage
+		data["responseSuccess"] = r.ResponseSuccess
+	}
+
+	return data
+}
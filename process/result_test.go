@@ -0,0 +1,257 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func TestResult_Audit(t *testing.T) {
+	audit := tide.AuditResult{
+		Raw: tide.AuditDetails{
+			Type:     "mock",
+			FileName: "checksum-lighthouse-raw.json",
+		},
+	}
+
+	tests := []struct {
+		name   string
+		result *Result
+		kind   string
+		want   tide.AuditResult
+		wantOk bool
+	}{
+		{
+			"Nil Result",
+			nil,
+			"lighthouse",
+			tide.AuditResult{},
+			false,
+		},
+		{
+			"Empty Result",
+			&Result{},
+			"lighthouse",
+			tide.AuditResult{},
+			false,
+		},
+		{
+			"Existing Audit",
+			&Result{
+				Audits: map[string]tide.AuditResult{
+					"lighthouse": audit,
+				},
+			},
+			"lighthouse",
+			audit,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.result.Audit(tt.kind)
+			if ok != tt.wantOk {
+				t.Errorf("Result.Audit() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Result.Audit() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResult_SetAudit(t *testing.T) {
+	res := &Result{}
+	res.SetAudit("phpcs_wordpress", tide.AuditResult{Error: "none"})
+
+	got, ok := res.Audit("phpcs_wordpress")
+	if !ok || got.Error != "none" {
+		t.Errorf("Result.SetAudit() = %v, want %v", got, "none")
+	}
+}
+
+func TestResult_SetScreenshot(t *testing.T) {
+	res := &Result{}
+	res.SetScreenshot("desktop", "checksum-screenshot-desktop.png")
+
+	want := map[string]string{"desktop": "checksum-screenshot-desktop.png"}
+	if !reflect.DeepEqual(res.Screenshots, want) {
+		t.Errorf("Result.SetScreenshot() = %v, want %v", res.Screenshots, want)
+	}
+}
+
+func TestResult_releaseLock(t *testing.T) {
+	held := &mockLocker{}
+	res := &Result{held: held}
+
+	if err := res.releaseLock(); err != nil || !held.released {
+		t.Errorf("Result.releaseLock() error = %v, released %v", err, held.released)
+	}
+
+	// The lock is only released once.
+	if err := res.releaseLock(); err != nil {
+		t.Errorf("Result.releaseLock() error = %v", err)
+	}
+
+	var empty *Result
+	if err := empty.releaseLock(); err != nil {
+		t.Errorf("Result.releaseLock() error = %v", err)
+	}
+}
+
+func TestResult_GetString(t *testing.T) {
+	res := NewResult()
+	res.Set("string", "value")
+	res.Set("int", 10)
+
+	tests := []struct {
+		name   string
+		key    string
+		want   string
+		wantOk bool
+	}{
+		{
+			"String Value",
+			"string",
+			"value",
+			true,
+		},
+		{
+			"Not a String",
+			"int",
+			"",
+			false,
+		},
+		{
+			"Missing",
+			"missing",
+			"",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := res.GetString(tt.key)
+			if ok != tt.wantOk {
+				t.Errorf("Result.GetString() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if got != tt.want {
+				t.Errorf("Result.GetString() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResult_Map(t *testing.T) {
+	info := tide.CodeInfo{Type: "plugin"}
+	audit := tide.AuditResult{Error: "none"}
+
+	tests := []struct {
+		name   string
+		result *Result
+		want   map[string]interface{}
+	}{
+		{
+			"Nil Result",
+			nil,
+			map[string]interface{}{},
+		},
+		{
+			"Full Result",
+			&Result{
+				Checksum:        "checksum",
+				ChecksumExclude: []string{"*.mo"},
+				Files:           []string{"file.php"},
+				FilesPath:       "/tmp/path",
+				Info:            &info,
+				Audits:          map[string]tide.AuditResult{"lighthouse": audit},
+				Screenshots:     map[string]string{"desktop": "checksum-screenshot-desktop.png"},
+				Inventory:       &InventoryReport{Shortcodes: []Declaration{{Name: "gallery", File: "file.php", Line: 1}}},
+				Libraries:       []Library{{Name: "jquery", Version: "3.5.1", Source: LibraryHeader, File: "jquery.js"}},
+				Environment:     &tide.Environment{OS: "linux/amd64", Fingerprint: "abc"},
+				Usage:           &Usage{Downloaded: 1024, Objects: 1},
+				Response:        "ok",
+				ResponseMessage: "sent",
+				ResponseSuccess: true,
+				Extra:           map[string]interface{}{"extra": 1},
+			},
+			map[string]interface{}{
+				"checksum":        "checksum",
+				"checksumExclude": []string{"*.mo"},
+				"files":           []string{"file.php"},
+				"filesPath":       "/tmp/path",
+				"info":            info,
+				"lighthouse":      audit,
+				"screenshots":     map[string]string{"desktop": "checksum-screenshot-desktop.png"},
+				"inventory":       InventoryReport{Shortcodes: []Declaration{{Name: "gallery", File: "file.php", Line: 1}}},
+				"libraries":       []Library{{Name: "jquery", Version: "3.5.1", Source: LibraryHeader, File: "jquery.js"}},
+				"environment":     tide.Environment{OS: "linux/amd64", Fingerprint: "abc"},
+				"usage":           Usage{Downloaded: 1024, Objects: 1},
+				"response":        "ok",
+				"responseMessage": "sent",
+				"responseSuccess": true,
+				"extra":           1,
+			},
+		},
+		{
+			"With Errors",
+			&Result{
+				Checksum: "checksum",
+				Errors:   []*Error{{Process: "PHPCS", Code: tide.FailureStandardMissing, Err: errors.New("standard missing")}},
+			},
+			map[string]interface{}{
+				"checksum":  "checksum",
+				"files":     []string(nil),
+				"filesPath": "",
+				"errors":    []*Error{{Process: "PHPCS", Code: tide.FailureStandardMissing, Err: errors.New("standard missing")}},
+				"failures":  []tide.Failure{{Code: tide.FailureStandardMissing, Process: "PHPCS", Message: "standard missing"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Map(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Result.Map() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResult_JSON(t *testing.T) {
+	res := &Result{
+		Checksum:  "checksum",
+		Files:     []string{"file.php"},
+		FilesPath: "/tmp/path",
+		Audits: map[string]tide.AuditResult{
+			"lighthouse": {Error: "none"},
+		},
+	}
+
+	b, err := json.Marshal(res)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var got *Result
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(got, res) {
+		t.Errorf("Result JSON = %v, want %v", got, res)
+	}
+}
+
+func TestResult_setReport(t *testing.T) {
+	res := &Result{}
+	report := &tide.PhpcsResults{}
+
+	res.setReport("phpcs_wordpress", report)
+
+	if res.reports["phpcs_wordpress"] != report {
+		t.Errorf("Result.setReport() reports = %v", res.reports)
+	}
+}
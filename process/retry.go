@@ -0,0 +1,224 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+)
+
+// Defaults of the Retry process.
+const (
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = 30 * time.Second
+	DefaultMaxBackoff  = 15 * time.Minute
+)
+
+// Retry sends messages that failed with a retryable error back to the queue, waiting longer before
+// every attempt. Messages that failed with a fatal error, or that used up their attempts, are sent
+// to the dead letter sink instead. The errors of every attempt are kept in the Attempts of the
+// message. Retry is usually the last process of a pipeline, after Response.
+type Retry struct {
+	Process                      // Inherits methods from Process.
+	In          <-chan Processor // Expects a processor channel as input.
+	Out         chan Processor   // (Optional) Send results to an output channel.
+	Queue       message.Provider // Queue to send the messages to retry to.
+	MaxAttempts int              // (Optional) Attempts to audit a message, including the first. Defaults to DefaultMaxAttempts.
+	Backoff     time.Duration    // (Optional) Delay before the first retry, doubled for every further retry. Defaults to DefaultBackoff.
+	MaxBackoff  time.Duration    // (Optional) Longest delay before a retry. Defaults to DefaultMaxBackoff.
+	DeadLetter  DeadLetterSink   // (Optional) Receives the messages that are not retried. They are only logged if nil.
+	pending     sync.WaitGroup   // Retries that are waiting for their delay.
+}
+
+// DeadLetter is a message that could not be audited.
+type DeadLetter struct {
+	Message message.Message `json:"message"` // The message, with the errors of every attempt.
+	Reason  string          `json:"reason"`  // Why the message was not retried.
+	Errors  []*Error        `json:"errors"`  // Errors of the last attempt.
+}
+
+// DeadLetterSink receives the messages that could not be audited, e.g. for someone to look into.
+type DeadLetterSink interface {
+	Send(letter DeadLetter) error
+}
+
+// QueueDeadLetter is a DeadLetterSink that sends the messages to a queue, e.g. a separate
+// "dead-letter" queue that is not consumed by workers.
+type QueueDeadLetter struct {
+	Provider message.Provider
+}
+
+// Send sends the message to the queue.
+func (q QueueDeadLetter) Send(letter DeadLetter) error {
+	msg := letter.Message
+	msg.ExternalRef = nil
+	return q.Provider.SendMessage(&msg)
+}
+
+// StorageDeadLetter is a DeadLetterSink that uploads the letters as JSON files to storage.
+type StorageDeadLetter struct {
+	Provider   storage.Provider // Storage provider to upload the letters to.
+	TempFolder string           // Path to a temp folder where the letters are written before they are uploaded.
+	Prefix     string           // (Optional) Prefix of the references. Defaults to "dead-letters/".
+}
+
+// Send uploads the letter as "<prefix><unix nano time>-<slug>.json".
+func (s StorageDeadLetter) Send(letter DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	prefix := s.Prefix
+	if prefix == "" {
+		prefix = "dead-letters/"
+	}
+
+	filename := strconv.FormatInt(now().UnixNano(), 10) + "-" + letter.Message.Slug + ".json"
+	path := strings.TrimRight(s.TempFolder, "/") + "/" + filename
+	if err := writeFile(path, data, 0644); err != nil {
+		return errors.New("could not write dead letter to tempFolder")
+	}
+	defer os.Remove(path)
+
+	return s.Provider.UploadFile(path, prefix+filename)
+}
+
+// Run executes the process in a pipe.
+func (r *Retry) Run(sink ErrorSink) error {
+	if r.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if r.Queue == nil {
+		return errors.New("requires a queue to retry messages")
+	}
+
+	r.start()
+
+	go func() {
+		// Close the out channel and signal that we are done once the waiting retries were sent.
+		defer func() {
+			r.pending.Wait()
+			r.stop(r.Out)
+		}()
+
+		r.serve(sink, stage{name: "Retry", in: r.In, out: r.Out, do: r.Do, last: true})
+	}()
+
+	return nil
+}
+
+// Do retries the message or sends it to the dead letter sink if the result has errors. Retries
+// are sent to the queue after their delay, or right away if the context is done meanwhile.
+func (r *Retry) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if res == nil || len(res.Errors) == 0 {
+		return res, nil
+	}
+
+	retry, fatal := false, false
+	attempt := message.Attempt{Time: now().Unix()}
+	for _, err := range res.Errors {
+		if err == nil {
+			continue
+		}
+		attempt.Errors = append(attempt.Errors, err.Error())
+		retry = retry || err.Retryable()
+		fatal = fatal || err.Fatal()
+	}
+
+	// Messages that were skipped on purpose are not retried, and the queue delivers the
+	// messages that Response requeued again.
+	if !retry && !fatal || msg.Lease.Requeued() {
+		return res, nil
+	}
+
+	// Copy the history and errors, so that the message and errors of the result are not changed.
+	errs := append([]*Error{}, res.Errors...)
+	msg.Attempts = append(append([]message.Attempt{}, msg.Attempts...), attempt)
+	msg.ExternalRef = nil
+	msg.Lease = nil
+
+	switch {
+	case fatal:
+		return res, r.deadLetter(msg, errs, "fatal error")
+	case len(msg.Attempts) >= r.maxAttempts():
+		return res, r.deadLetter(msg, errs, fmt.Sprintf("failed %d attempts", len(msg.Attempts)))
+	}
+
+	delay := r.backoff(len(msg.Attempts))
+	log.Log(msg.Title, fmt.Sprintf("Retrying in %v (attempt %d of %d).", delay, len(msg.Attempts)+1, r.maxAttempts()))
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			// Don't lose the message if the pipeline stops while waiting.
+		}
+
+		if err := r.Queue.SendMessage(&msg); err != nil {
+			log.Log(msg.Title, "Could not retry message: "+err.Error())
+			if err := r.deadLetter(msg, errs, "could not retry: "+err.Error()); err != nil {
+				log.Log(msg.Title, err.Error())
+			}
+		}
+	}()
+
+	return res, nil
+}
+
+// deadLetter sends the message to the dead letter sink.
+func (r *Retry) deadLetter(msg message.Message, errs []*Error, reason string) error {
+	log.Log(msg.Title, "Giving up on message: "+reason)
+	if r.DeadLetter == nil {
+		return nil
+	}
+
+	letter := DeadLetter{Message: msg, Reason: reason, Errors: errs}
+	if err := r.DeadLetter.Send(letter); err != nil {
+		return errors.New("could not send dead letter: " + err.Error())
+	}
+	return nil
+}
+
+// maxAttempts returns the number of attempts to audit a message.
+func (r *Retry) maxAttempts() int {
+	if r.MaxAttempts < 1 {
+		return DefaultMaxAttempts
+	}
+	return r.MaxAttempts
+}
+
+// backoff returns the delay before the retry that follows the failed attempt.
+func (r *Retry) backoff(failed int) time.Duration {
+	delay, max := r.Backoff, r.MaxBackoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	for i := 1; i < failed && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,270 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// sentQueue records the messages sent to it.
+type sentQueue struct {
+	mockQueue
+	mu   sync.Mutex
+	sent []message.Message
+	fail bool
+}
+
+func (s *sentQueue) SendMessage(msg *message.Message) error {
+	if s.fail {
+		return errors.New("queue unavailable")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, *msg)
+	return nil
+}
+
+// letterBox records the dead letters sent to it.
+type letterBox struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+func (l *letterBox) Send(letter DeadLetter) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.letters = append(l.letters, letter)
+	return nil
+}
+
+func TestRetry_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   *Retry
+		wantErr bool
+	}{
+		{"Valid Process", &Retry{In: make(chan Processor), Queue: &sentQueue{}}, false},
+		{"No In Channel", &Retry{Queue: &sentQueue{}}, true},
+		{"No Queue", &Retry{In: make(chan Processor)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.retry.SetContext(ctx)
+
+			if err := tt.retry.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Retry.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetry_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	retryable := withCode(tide.FailureStorage, errors.New("upload error"))
+	fatal := withCode(tide.FailureStandardMissing, errors.New("standard missing"))
+	malware := &Error{Process: "Ingest", Severity: SeveritySkip, Err: errors.New("malware")}
+	previous := []message.Attempt{{Time: 1, Errors: []string{"PHPCS Error: upload error"}}}
+
+	tests := []struct {
+		name        string
+		errs        []*Error
+		attempts    []message.Attempt
+		wantSent    int
+		wantLetters int
+		wantReason  string
+	}{
+		{"No Errors", nil, nil, 0, 0, ""},
+		{"Retryable", []*Error{NewError("PHPCS", message.Message{}, retryable)}, nil, 1, 0, ""},
+		{"Retryable Again", []*Error{NewError("PHPCS", message.Message{}, retryable)}, previous, 1, 0, ""},
+		{"Attempts Used Up", []*Error{NewError("PHPCS", message.Message{}, retryable)}, append(previous, previous...), 0, 1, "failed 3 attempts"},
+		{"Fatal", []*Error{NewError("PHPCS", message.Message{}, retryable), NewError("PHPCS", message.Message{}, fatal)}, nil, 0, 1, "fatal error"},
+		{"Skipped", []*Error{malware}, nil, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &sentQueue{}
+			box := &letterBox{}
+			retry := &Retry{Queue: queue, DeadLetter: box, Backoff: time.Millisecond}
+
+			ref := "receipt"
+			msg := message.Message{Title: "Test", Slug: "test", ExternalRef: &ref, Attempts: tt.attempts}
+			res := NewResult()
+			res.Errors = tt.errs
+
+			if _, err := retry.Do(context.Background(), msg, res); err != nil {
+				t.Fatalf("Retry.Do() error = %v", err)
+			}
+			retry.pending.Wait()
+
+			if len(queue.sent) != tt.wantSent {
+				t.Fatalf("Retry.Do() retried %v messages, want %v", len(queue.sent), tt.wantSent)
+			}
+			if len(box.letters) != tt.wantLetters {
+				t.Fatalf("Retry.Do() sent %v dead letters, want %v", len(box.letters), tt.wantLetters)
+			}
+
+			if tt.wantSent != 0 {
+				sent := queue.sent[0]
+				if len(sent.Attempts) != len(tt.attempts)+1 || sent.ExternalRef != nil {
+					t.Errorf("Retry.Do() retried %v", sent)
+				}
+				if got := sent.Attempts[len(sent.Attempts)-1].Errors; !reflect.DeepEqual(got, []string{"PHPCS Error: upload error"}) {
+					t.Errorf("Retry.Do() attempt errors = %v", got)
+				}
+			}
+			if tt.wantLetters != 0 {
+				letter := box.letters[0]
+				if letter.Reason != tt.wantReason || len(letter.Errors) != len(tt.errs) {
+					t.Errorf("Retry.Do() dead letter = %v, want reason %q", letter, tt.wantReason)
+				}
+			}
+
+			// The message of the result is not changed.
+			if len(msg.Attempts) != len(tt.attempts) || msg.ExternalRef != &ref {
+				t.Errorf("Retry.Do() changed the message: %v", msg)
+			}
+		})
+	}
+}
+
+func TestRetry_Do_Requeued(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	queue := &sentQueue{}
+	retry := &Retry{Queue: queue, Backoff: time.Millisecond}
+
+	ref := "receipt"
+	lease := message.NewLease(message.NewLeaseProvider(queue), &ref)
+	msg := message.Message{Title: "Test", ExternalRef: &ref, Lease: lease}
+	res := NewResult()
+	res.Errors = []*Error{NewError("Response", msg, withCode(tide.FailureStorage, errors.New("upload error")))}
+
+	// Messages are retried with a lease of their own.
+	retry.Do(context.Background(), msg, res)
+	retry.pending.Wait()
+	if len(queue.sent) != 1 || queue.sent[0].Lease != nil {
+		t.Fatalf("Retry.Do() retried %v", queue.sent)
+	}
+
+	// Messages that were requeued are delivered again by the queue.
+	lease.Nack(true)
+	retry.Do(context.Background(), msg, res)
+	retry.pending.Wait()
+	if len(queue.sent) != 1 {
+		t.Errorf("Retry.Do() retried the requeued message: %v", queue.sent)
+	}
+}
+
+func TestRetry_Do_QueueError(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	box := &letterBox{}
+	retry := &Retry{Queue: &sentQueue{fail: true}, DeadLetter: box, Backoff: time.Millisecond}
+
+	res := NewResult()
+	res.AddError(NewError("PHPCS", message.Message{}, withCode(tide.FailureStorage, errors.New("upload error"))))
+	retry.Do(context.Background(), message.Message{Title: "Test"}, res)
+	retry.pending.Wait()
+
+	if len(box.letters) != 1 || box.letters[0].Reason != "could not retry: queue unavailable" {
+		t.Errorf("Retry.Do() dead letters = %v", box.letters)
+	}
+}
+
+func TestRetry_Do_Cancelled(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	queue := &sentQueue{}
+	retry := &Retry{Queue: queue, Backoff: time.Hour}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res := NewResult()
+	res.AddError(NewError("PHPCS", message.Message{}, withCode(tide.FailureStorage, errors.New("upload error"))))
+	retry.Do(ctx, message.Message{Title: "Test"}, res)
+
+	// Waiting retries are sent right away when the pipeline stops.
+	cancel()
+	retry.pending.Wait()
+
+	if len(queue.sent) != 1 {
+		t.Errorf("Retry.Do() retried %v messages, want 1", len(queue.sent))
+	}
+}
+
+func TestRetry_backoff(t *testing.T) {
+	tests := []struct {
+		name   string
+		retry  *Retry
+		failed int
+		want   time.Duration
+	}{
+		{"Defaults", &Retry{}, 1, DefaultBackoff},
+		{"Doubled", &Retry{Backoff: time.Second}, 3, 4 * time.Second},
+		{"Limited", &Retry{Backoff: time.Second, MaxBackoff: 5 * time.Second}, 10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.retry.backoff(tt.failed); got != tt.want {
+				t.Errorf("Retry.backoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStorageDeadLetter_Send(t *testing.T) {
+	current := time.Unix(0, 42)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	provider := &recordingStorage{}
+	sink := StorageDeadLetter{Provider: provider, TempFolder: os.TempDir()}
+
+	letter := DeadLetter{Message: message.Message{Slug: "akismet"}, Reason: "fatal error"}
+	if err := sink.Send(letter); err != nil {
+		t.Fatalf("StorageDeadLetter.Send() error = %v", err)
+	}
+	if !reflect.DeepEqual(provider.refs, []string{"dead-letters/42-akismet.json"}) {
+		t.Errorf("StorageDeadLetter.Send() uploaded %v", provider.refs)
+	}
+	if _, err := os.Stat(os.TempDir() + "/42-akismet.json"); !os.IsNotExist(err) {
+		t.Errorf("StorageDeadLetter.Send() did not remove the temp file")
+	}
+
+	provider.fail = true
+	if err := sink.Send(letter); err == nil {
+		t.Errorf("StorageDeadLetter.Send() expected an upload error")
+	}
+}
+
+func TestQueueDeadLetter_Send(t *testing.T) {
+	queue := &sentQueue{}
+	ref := "receipt"
+	letter := DeadLetter{Message: message.Message{Slug: "akismet", ExternalRef: &ref}}
+
+	if err := (QueueDeadLetter{Provider: queue}).Send(letter); err != nil {
+		t.Fatalf("QueueDeadLetter.Send() error = %v", err)
+	}
+	if len(queue.sent) != 1 || queue.sent[0].Slug != "akismet" || queue.sent[0].ExternalRef != nil {
+		t.Errorf("QueueDeadLetter.Send() sent %v", queue.sent)
+	}
+}
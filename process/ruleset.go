@@ -0,0 +1,226 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+// AnyStandard is the key of the component versions used for standards that have no versions
+// of their own, e.g. {"*": {"phpcs": "3.3.1"}} lets messages request any standard installed
+// with PHPCS, like "PSR12" or a standard added to its installed_paths.
+const AnyStandard = "*"
+
+// Defaults of custom rulesets.
+const (
+	DefaultRulesetTTL = 10 * time.Minute // How long a downloaded ruleset is reused.
+	maxRulesetSize    = 1 << 20          // Largest ruleset that is downloaded.
+)
+
+// standardName matches the names of installed standards, e.g. "WordPress-Core" or "PSR12".
+var standardName = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// forbiddenRulesetElements are the ruleset elements that could change what PHPCS reads or writes
+// outside of the audited files, e.g. <config name="installed_paths">.
+var forbiddenRulesetElements = map[string]bool{
+	"autoload": true,
+	"config":   true,
+	"file":     true,
+	"ini":      true,
+}
+
+// rulesets remembers when the custom rulesets in the temp folders were downloaded.
+var rulesets = struct {
+	sync.Mutex
+	fetched map[string]time.Time
+}{fetched: make(map[string]time.Time)}
+
+// isRuleset determines if a standard override refers to a custom ruleset, i.e. a URL or the
+// storage key of an XML file, rather than to an installed standard.
+func isRuleset(override string) bool {
+	return isRulesetURL(override) || strings.HasSuffix(strings.ToLower(override), ".xml")
+}
+
+// isRulesetURL determines if a standard override is the URL of a custom ruleset.
+func isRulesetURL(override string) bool {
+	return strings.HasPrefix(override, "https://") || strings.HasPrefix(override, "http://")
+}
+
+// ruleset downloads the custom ruleset of the override into the temp folder and returns its path.
+// Rulesets are downloaded again once they are older than the RulesetTTL.
+func (cs Phpcs) ruleset(ctx context.Context, res *Result, override string) (string, error) {
+	sum := sha1.Sum([]byte(override))
+	path := strings.TrimRight(cs.TempFolder, "/") + "/ruleset-" + hex.EncodeToString(sum[:]) + ".xml"
+
+	ttl := cs.RulesetTTL
+	if ttl <= 0 {
+		ttl = DefaultRulesetTTL
+	}
+
+	rulesets.Lock()
+	defer rulesets.Unlock()
+
+	if fetched, ok := rulesets.fetched[path]; ok && now().Sub(fetched) < ttl {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	var data []byte
+	var err error
+	if isRulesetURL(override) {
+		data, err = cs.fetchRuleset(ctx, override)
+	} else {
+		data, err = cs.downloadRuleset(ctx, res, override, path)
+	}
+	if err != nil {
+		return "", withCode(tide.FailureSourceUnreachable, errors.New("could not download ruleset: "+err.Error()))
+	}
+
+	if err := validateRuleset(data); err != nil {
+		return "", withCode(tide.FailureRulesetInvalid, err)
+	}
+
+	if err := writeFile(path, data, 0644); err != nil {
+		return "", errors.New("could not write ruleset to tempFolder")
+	}
+	rulesets.fetched[path] = now()
+
+	return path, nil
+}
+
+// fetchRuleset downloads a ruleset from a URL.
+func (cs Phpcs) fetchRuleset(ctx context.Context, url string) ([]byte, error) {
+	client := cs.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return readRuleset(resp.Body)
+}
+
+// downloadRuleset downloads a ruleset from storage.
+func (cs Phpcs) downloadRuleset(ctx context.Context, res *Result, key, path string) ([]byte, error) {
+	provider := cs.Rulesets
+	if provider == nil {
+		provider = cs.StorageProvider
+	}
+
+	// Download next to the ruleset, a previous download may still be in use by an audit.
+	download := path + ".download"
+	defer os.Remove(download)
+
+	if err := storage.WithContext(ctx, meterStorage(provider, res)).DownloadFile(key, download); err != nil {
+		return nil, err
+	}
+
+	file, err := fileOpen(download)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return readRuleset(file)
+}
+
+// readRuleset reads a ruleset, up to the largest size allowed.
+func readRuleset(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxRulesetSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxRulesetSize {
+		return nil, fmt.Errorf("ruleset is larger than %d bytes", maxRulesetSize)
+	}
+	return data, nil
+}
+
+// validateRuleset verifies that the data is a PHPCS ruleset that only configures sniffs.
+// Rulesets can't set PHPCS options or refer to files outside of the installed standards.
+func validateRuleset(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	root := ""
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.New("invalid ruleset: " + err.Error())
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		name := element.Name.Local
+		if root == "" {
+			root = name
+			if root != "ruleset" {
+				return errors.New("invalid ruleset: root element is <" + root + ">, expected <ruleset>")
+			}
+			continue
+		}
+
+		if forbiddenRulesetElements[name] {
+			return errors.New("invalid ruleset: <" + name + "> is not allowed")
+		}
+
+		for _, attr := range element.Attr {
+			if name == "arg" && attr.Name.Local == "name" && !allowedRulesetArg(attr.Value) {
+				return errors.New("invalid ruleset: argument \"" + attr.Value + "\" is not allowed")
+			}
+			if name == "rule" && attr.Name.Local == "ref" && (strings.HasPrefix(attr.Value, "/") || strings.Contains(attr.Value, "..")) {
+				return errors.New("invalid ruleset: rule \"" + attr.Value + "\" is not allowed")
+			}
+		}
+	}
+
+	if root == "" {
+		return errors.New("invalid ruleset: no <ruleset> element")
+	}
+
+	return nil
+}
+
+// allowedRulesetArg determines if a ruleset may set the command line argument, i.e. if it only
+// affects which issues are reported.
+func allowedRulesetArg(name string) bool {
+	switch name {
+	case "colors", "p", "s", "n", "severity", "error-severity", "warning-severity", "tab-width":
+		return true
+	}
+	return false
+}
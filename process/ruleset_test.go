@@ -0,0 +1,190 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage/local"
+	"github.com/wptide/pkg/tide"
+)
+
+const testRuleset = `<?xml version="1.0"?>
+<ruleset name="Custom">
+	<rule ref="WordPress-Core">
+		<exclude name="Generic.WhiteSpace.DisallowSpaceIndent"/>
+	</rule>
+	<arg name="severity" value="3"/>
+</ruleset>`
+
+func Test_validateRuleset(t *testing.T) {
+	tests := []struct {
+		name    string
+		ruleset string
+		wantErr bool
+	}{
+		{"Valid Ruleset", testRuleset, false},
+		{"Not XML", `{"standard": "WordPress"}`, true},
+		{"Empty", ``, true},
+		{"Other Root", `<project><rule ref="WordPress"/></project>`, true},
+		{"Installed Paths", `<ruleset><config name="installed_paths" value="/tmp"/></ruleset>`, true},
+		{"Report File", `<ruleset><arg name="report-file" value="/etc/passwd"/></ruleset>`, true},
+		{"Bootstrap", `<ruleset><arg name="bootstrap" value="/tmp/evil.php"/></ruleset>`, true},
+		{"Files", `<ruleset><file>/etc</file></ruleset>`, true},
+		{"Absolute Rule", `<ruleset><rule ref="/tmp/Sniffs/EvilSniff.php"/></ruleset>`, true},
+		{"Relative Rule", `<ruleset><rule ref="../Sniffs"/></ruleset>`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRuleset([]byte(tt.ruleset)); (err != nil) != tt.wantErr {
+				t.Errorf("validateRuleset() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_isRuleset(t *testing.T) {
+	tests := []struct {
+		override string
+		want     bool
+	}{
+		{"", false},
+		{"WordPress-Extra", false},
+		{"mock/override", false},
+		{"https://example.com/phpcs.xml", true},
+		{"http://example.com/ruleset", true},
+		{"rulesets/custom.XML", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.override, func(t *testing.T) {
+			if got := isRuleset(tt.override); got != tt.want {
+				t.Errorf("isRuleset() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhpcs_ruleset_URL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rulesets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/ruleset.xml":
+			w.Write([]byte(testRuleset))
+		case "/invalid.xml":
+			w.Write([]byte(`<ruleset><config name="installed_paths" value="/tmp"/></ruleset>`))
+		case "/large.xml":
+			w.Write([]byte("<ruleset>" + strings.Repeat(" ", maxRulesetSize) + "</ruleset>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	current := time.Unix(1000, 0)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	cs := Phpcs{TempFolder: dir, RulesetTTL: time.Minute}
+
+	path, err := cs.ruleset(context.Background(), nil, server.URL+"/ruleset.xml")
+	if err != nil {
+		t.Fatalf("Phpcs.ruleset() error = %v", err)
+	}
+	if data, _ := ioutil.ReadFile(path); string(data) != testRuleset {
+		t.Errorf("Phpcs.ruleset() wrote %q", data)
+	}
+
+	// The downloaded ruleset is reused until it expires.
+	if again, _ := cs.ruleset(context.Background(), nil, server.URL+"/ruleset.xml"); again != path || requests != 1 {
+		t.Errorf("Phpcs.ruleset() = %v after %v requests, want the cached %v", again, requests, path)
+	}
+	current = current.Add(2 * time.Minute)
+	if _, err := cs.ruleset(context.Background(), nil, server.URL+"/ruleset.xml"); err != nil || requests != 2 {
+		t.Errorf("Phpcs.ruleset() error = %v after %v requests, want the ruleset downloaded again", err, requests)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode string
+	}{
+		{"Invalid Ruleset", "/invalid.xml", tide.FailureRulesetInvalid},
+		{"Too Large", "/large.xml", tide.FailureSourceUnreachable},
+		{"Not Found", "/missing.xml", tide.FailureSourceUnreachable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := cs.ruleset(context.Background(), nil, server.URL+tt.path)
+			if got := errorCode(err); got != tt.wantCode {
+				t.Errorf("Phpcs.ruleset() error = %v, want code %v", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPhpcs_Do_Ruleset(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "rulesets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(dir+"/storage/rulesets", 0755)
+	ioutil.WriteFile(dir+"/storage/rulesets/custom.xml", []byte(testRuleset), 0644)
+
+	runner := &recordingRunner{fail: true}
+	cs := &Phpcs{
+		TempFolder:      dir,
+		StorageProvider: &mockStorage{},
+		Rulesets:        local.NewLocalStorage(dir+"/storage", ""),
+		PhpcsVersions:   map[string]map[string]string{AnyStandard: {"phpcs": "3.7.2"}},
+		Runner:          runner,
+	}
+
+	res := NewResult()
+	res.Checksum = "checksum"
+	res.FilesPath = "/tmp/audit"
+
+	msg := message.Message{
+		Title: "Test",
+		Audits: []*message.Audit{
+			{Type: "phpcs", Options: &message.AuditOption{Standard: "custom", StandardOverride: "rulesets/custom.xml"}},
+			{Type: "phpcs", Options: &message.AuditOption{Standard: "PSR12"}},
+		},
+	}
+
+	// The runner fails, only the commands are of interest.
+	cs.Do(context.Background(), msg, res)
+
+	if len(runner.commands) != 2 {
+		t.Fatalf("Phpcs.Do() ran %v", runner.commands)
+	}
+
+	ruleset, _ := cs.ruleset(context.Background(), res, "rulesets/custom.xml")
+	want := []string{"--standard=" + ruleset, "--standard=PSR12"}
+	for i, command := range runner.commands {
+		if !strings.Contains(strings.Join(command, " "), want[i]) {
+			t.Errorf("Phpcs.Do() ran %v, want %v", command, want[i])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package process
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDisabledFunctions are the PHP functions disabled by a PhpSandbox.
+var DefaultDisabledFunctions = []string{
+	"exec", "passthru", "shell_exec", "system", "proc_open", "popen", "pcntl_exec", "dl", "mail",
+	"curl_exec", "curl_multi_exec", "fsockopen", "pfsockopen", "stream_socket_client",
+}
+
+// DefaultErrorReporting is the error_reporting level used by a PhpSandbox.
+const DefaultErrorReporting = "E_ALL & ~E_DEPRECATED"
+
+// Using exec.LookPath as a variable so that we can mock it in tests.
+var lookPath = exec.LookPath
+
+// PhpSandbox describes the php.ini that is generated for every PHPCS run, so that the analysis of
+// untrusted code is constrained even when PHPCS is not isolated in a container.
+//
+// The generated php.ini limits file access (open_basedir) to the audited files, the temp folder,
+// the PHPCS installation and AllowedPaths, and disables functions that run programs or open connections.
+type PhpSandbox struct {
+	Php              string   // (Optional) PHP executable used to run PHPCS. Defaults to "php".
+	AllowedPaths     []string // (Optional) Other paths PHPCS needs, e.g. the folder the standards are installed in.
+	DisableFunctions []string // (Optional) Defaults to DefaultDisabledFunctions.
+	ErrorReporting   string   // (Optional) Defaults to DefaultErrorReporting.
+}
+
+// Ini returns the php.ini that only allows access to the given paths and AllowedPaths.
+func (s PhpSandbox) Ini(paths []string) string {
+	disabled := s.DisableFunctions
+	if disabled == nil {
+		disabled = DefaultDisabledFunctions
+	}
+
+	errorReporting := s.ErrorReporting
+	if errorReporting == "" {
+		errorReporting = DefaultErrorReporting
+	}
+
+	var allowed []string
+	for _, path := range append(append([]string{}, paths...), s.AllowedPaths...) {
+		if path == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		allowed = append(allowed, path)
+	}
+
+	lines := []string{
+		"; Generated for a single PHPCS run.",
+		"disable_functions = " + strings.Join(disabled, ","),
+		"open_basedir = \"" + strings.Join(allowed, string(filepath.ListSeparator)) + "\"",
+		"error_reporting = " + errorReporting,
+		"display_errors = stderr",
+		"allow_url_fopen = Off",
+		"allow_url_include = Off",
+		"enable_dl = Off",
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// command writes the php.ini to iniPath and returns the command that runs the phpcs script with it.
+func (s PhpSandbox) command(phpcs, iniPath string, paths []string) (string, []string, error) {
+	script, err := lookPath(phpcs)
+	if err != nil {
+		return "", nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(script); err == nil {
+		script = resolved
+	}
+
+	// PHPCS needs access to its own files, e.g. "php_codesniffer" for "php_codesniffer/bin/phpcs".
+	paths = append(paths, filepath.Dir(filepath.Dir(script)), filepath.Dir(iniPath))
+
+	if err := writeFile(iniPath, []byte(s.Ini(paths)), 0600); err != nil {
+		return "", nil, err
+	}
+
+	php := s.Php
+	if php == "" {
+		php = "php"
+	}
+
+	return php, []string{"-c", iniPath, script}, nil
+}
@@ -0,0 +1,185 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+func TestPhpSandbox_Ini(t *testing.T) {
+	tests := []struct {
+		name    string
+		sandbox PhpSandbox
+		paths   []string
+		want    []string
+	}{
+		{
+			"Defaults",
+			PhpSandbox{},
+			[]string{"/tmp/audit", "", "/tmp/reports"},
+			[]string{
+				"disable_functions = " + strings.Join(DefaultDisabledFunctions, ","),
+				`open_basedir = "/tmp/audit:/tmp/reports"`,
+				"error_reporting = E_ALL & ~E_DEPRECATED",
+				"allow_url_fopen = Off",
+			},
+		},
+		{
+			"Custom",
+			PhpSandbox{
+				AllowedPaths:     []string{"/opt/standards"},
+				DisableFunctions: []string{"exec"},
+				ErrorReporting:   "E_ALL",
+			},
+			[]string{"/tmp/audit"},
+			[]string{
+				"disable_functions = exec\n",
+				`open_basedir = "/tmp/audit:/opt/standards"`,
+				"error_reporting = E_ALL\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.sandbox.Ini(tt.paths)
+			for _, line := range tt.want {
+				if !strings.Contains(got, line) {
+					t.Errorf("PhpSandbox.Ini() = %v, want it to contain %v", got, line)
+				}
+			}
+		})
+	}
+}
+
+func TestPhpSandbox_command(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func() { lookPath = exec.LookPath }()
+
+	lookPath = func(file string) (string, error) {
+		return "/opt/phpcs/bin/phpcs", nil
+	}
+
+	iniPath := dir + "/audit-php.ini"
+	php, args, err := PhpSandbox{Php: "/usr/bin/php7.4"}.command("phpcs", iniPath, []string{"/tmp/audit"})
+	if err != nil {
+		t.Fatalf("PhpSandbox.command() error = %v", err)
+	}
+	if php != "/usr/bin/php7.4" || !reflect.DeepEqual(args, []string{"-c", iniPath, "/opt/phpcs/bin/phpcs"}) {
+		t.Errorf("PhpSandbox.command() = %v %v", php, args)
+	}
+
+	ini, _ := ioutil.ReadFile(iniPath)
+	if want := `open_basedir = "/tmp/audit:/opt/phpcs:` + dir + `"`; !strings.Contains(string(ini), want) {
+		t.Errorf("PhpSandbox.command() wrote %s, want it to contain %v", ini, want)
+	}
+
+	lookPath = func(file string) (string, error) {
+		return "", errors.New("executable file not found")
+	}
+	if _, _, err := (PhpSandbox{}).command("phpcs", iniPath, nil); err == nil {
+		t.Errorf("PhpSandbox.command() expected an error when phpcs is not installed")
+	}
+}
+
+func TestPhpcs_Do_Sandbox(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "sandbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	lookPath = func(file string) (string, error) { return "/opt/phpcs/bin/phpcs", nil }
+	oldRunner := phpcsRunner
+	runner := &recordingRunner{}
+	phpcsRunner = runner
+	defer func() {
+		lookPath = exec.LookPath
+		phpcsRunner = oldRunner
+	}()
+
+	cs := &Phpcs{
+		TempFolder:      dir,
+		StorageProvider: &mockStorage{},
+		PhpcsVersions:   map[string]map[string]string{"wordpress": {"phpcs": "3.1.1"}},
+		Sandbox:         &PhpSandbox{},
+	}
+
+	msg := message.Message{
+		Title:  "Test",
+		Audits: []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}}},
+	}
+	res := NewResult()
+	res.Checksum = "checksum"
+	res.FilesPath = dir + "/audit"
+
+	// The report is never written, so the audit fails after running PHPCS.
+	cs.Do(context.Background(), msg, res)
+
+	if len(runner.commands) != 1 {
+		t.Fatalf("Phpcs.Do() ran %v", runner.commands)
+	}
+
+	iniPath := dir + "/checksum-phpcs_wordpress-php.ini"
+	if got := runner.commands[0][:4]; !reflect.DeepEqual(got, []string{"php", "-c", iniPath, "/opt/phpcs/bin/phpcs"}) {
+		t.Errorf("Phpcs.Do() command = %v", got)
+	}
+	if _, err := os.Stat(iniPath); !os.IsNotExist(err) {
+		t.Errorf("Phpcs.Do() did not remove the php.ini")
+	}
+}
+
+func TestPhpcs_Do_Runner(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "runner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldRunner := phpcsRunner
+	phpcsRunner = &recordingRunner{}
+	defer func() { phpcsRunner = oldRunner }()
+
+	runner := &recordingRunner{}
+	cs := &Phpcs{
+		TempFolder:      dir,
+		StorageProvider: &mockStorage{},
+		PhpcsVersions:   map[string]map[string]string{"wordpress": {"phpcs": "3.1.1"}},
+		Runner:          runner,
+	}
+
+	msg := message.Message{
+		Title:  "Test",
+		Audits: []*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}}},
+	}
+	res := NewResult()
+	res.Checksum = "checksum"
+	res.FilesPath = dir + "/audit"
+
+	cs.Do(context.Background(), msg, res)
+
+	if len(runner.commands) != 1 || len(phpcsRunner.(*recordingRunner).commands) != 0 {
+		t.Errorf("Phpcs.Do() did not run PHPCS with the configured runner")
+	}
+}
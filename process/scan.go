@@ -0,0 +1,183 @@
+package process
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Finding severities.
+const (
+	FindingInfo    = "info"
+	FindingWarning = "warning"
+)
+
+// Finding types raised while scanning files.
+const (
+	FindingBOM      = "bom"
+	FindingEncoding = "encoding"
+	FindingFilename = "filename"
+)
+
+// binarySniffLen is the number of bytes checked for NUL bytes to detect binary files.
+const binarySniffLen = 8000
+
+// Byte order marks that can be detected.
+var boms = []struct {
+	name  string
+	bytes []byte
+}{
+	{"UTF-8", []byte{0xEF, 0xBB, 0xBF}},
+	{"UTF-16BE", []byte{0xFE, 0xFF}},
+	{"UTF-16LE", []byte{0xFF, 0xFE}},
+}
+
+// Finding describes an informational finding that is not part of an audit report.
+type Finding struct {
+	Process  string `json:"process"`
+	Type     string `json:"type"`
+	File     string `json:"file"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+}
+
+// scanFiles checks the files for binary data, byte order marks and non UTF-8 encodings.
+// Binary files are recorded so that they can be excluded from PHP audits and encoding
+// anomalies are recorded as informational findings.
+func scanFiles(res *Result) error {
+	root := res.FilesPath + "/unzipped"
+
+	for _, file := range res.Files {
+		bom, binary, valid, err := checkFile(file)
+		if err != nil {
+			return err
+		}
+
+		name := relativeName(root, file)
+
+		switch {
+		case bom != "":
+			res.AddFinding(Finding{
+				Process:  "Ingest",
+				Type:     FindingBOM,
+				File:     name,
+				Message:  "file starts with a " + bom + " byte order mark",
+				Severity: FindingInfo,
+			})
+
+			// UTF-16 files contain NUL bytes and are not valid UTF-8, so don't check any further.
+			if bom != "UTF-8" {
+				continue
+			}
+		case binary:
+			res.BinaryFiles = append(res.BinaryFiles, file)
+			continue
+		}
+
+		if !valid {
+			res.AddFinding(Finding{
+				Process:  "Ingest",
+				Type:     FindingEncoding,
+				File:     name,
+				Message:  "file is not valid UTF-8",
+				Severity: FindingInfo,
+			})
+		}
+	}
+
+	return nil
+}
+
+// checkFile returns the byte order mark of the file, whether it is binary and whether it is
+// valid UTF-8. Only the first binarySniffLen bytes are checked for a byte order mark and binary
+// data, the rest of the file is streamed, so large files are not read into memory. Files with
+// a UTF-16 byte order mark and binary files are not validated.
+func checkFile(file string) (bom string, binary, valid bool, err error) {
+	f, err := fileOpen(file)
+	if err != nil {
+		return "", false, false, err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, binarySniffLen)
+	prefix, err := reader.Peek(binarySniffLen)
+	if err != nil && err != io.EOF {
+		return "", false, false, err
+	}
+
+	bom = detectBOM(prefix)
+	if bom != "" && bom != "UTF-8" {
+		return bom, false, false, nil
+	}
+	if bom == "" && isBinary(prefix) {
+		return "", true, false, nil
+	}
+
+	valid, err = validUTF8(reader)
+	return bom, false, valid, err
+}
+
+// validUTF8 reads the reader to the end and reports whether it is valid UTF-8.
+func validUTF8(reader *bufio.Reader) (bool, error) {
+	for {
+		r, size, err := reader.ReadRune()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		// Encoded U+FFFD characters are valid, invalid bytes are read one at a time.
+		if r == utf8.RuneError && size == 1 {
+			return false, nil
+		}
+	}
+}
+
+// relativeName returns the path of file relative to root, or file if it is outside of root.
+func relativeName(root, file string) string {
+	if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
+
+// detectBOM returns the name of the encoding for the byte order mark at the start of data, if any.
+func detectBOM(data []byte) string {
+	for _, bom := range boms {
+		if bytes.HasPrefix(data, bom.bytes) {
+			return bom.name
+		}
+	}
+	return ""
+}
+
+// isBinary checks the start of data for NUL bytes, which don't appear in text files.
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
+
+// ignorePatterns adds files to a comma separated list of PHPCS ignore patterns. PHPCS matches
+// the patterns as regular expressions, so the paths of the files are quoted. It can't escape
+// the commas that separate the patterns, so files with commas in their paths are rejected.
+func ignorePatterns(ignore string, files []string) (string, error) {
+	patterns := []string{}
+	if ignore != "" {
+		patterns = append(patterns, ignore)
+	}
+	for _, file := range files {
+		if strings.Contains(file, ",") {
+			return "", errors.New("can't ignore file with a comma in its path: " + file)
+		}
+		patterns = append(patterns, regexp.QuoteMeta(file))
+	}
+	return strings.Join(patterns, ","), nil
+}
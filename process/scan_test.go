@@ -0,0 +1,193 @@
+package process
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_scanFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := dir + "/unzipped"
+	os.MkdirAll(root, os.ModePerm)
+
+	// Large files are streamed, so characters can be split between reads.
+	large := bytes.Repeat([]byte("a"), binarySniffLen-1)
+	large = append(large, "é\n<?php\n"...)
+	large = append(large, bytes.Repeat([]byte("b"), 2*binarySniffLen)...)
+
+	files := map[string][]byte{
+		"large.php":        large,
+		"large-latin1.php": append(append([]byte{}, large...), 0xe9),
+		"replacement.php":  []byte("<?php\necho '\uFFFD';\n"),
+		"plugin.php":       []byte("<?php\necho 'héllo';\n"),
+		"bom.php":          append([]byte{0xEF, 0xBB, 0xBF}, []byte("<?php\n")...),
+		"utf16.php":        {0xFF, 0xFE, '<', 0, '?', 0},
+		"latin1.php":       []byte("<?php\necho 'h\xe9llo';\n"),
+		"image.png":        {0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0, 0, 0, 0x0D},
+		"readme.txt":       []byte("=== Plugin ===\n"),
+		"encoded.php":      {'<', '?', 'p', 'h', 'p', 0, 0x01, 0x02},
+		"empty/.keep":      {},
+		"nested/a.php":     []byte("<?php\n"),
+	}
+
+	var paths []string
+	for name, data := range files {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	tests := []struct {
+		name         string
+		files        []string
+		wantBinary   []string
+		wantFindings map[string]string
+		wantErr      bool
+	}{
+		{
+			"Scan Files",
+			paths,
+			[]string{
+				filepath.Join(root, "encoded.php"),
+				filepath.Join(root, "image.png"),
+			},
+			map[string]string{
+				"bom.php":          FindingBOM,
+				"utf16.php":        FindingBOM,
+				"latin1.php":       FindingEncoding,
+				"large-latin1.php": FindingEncoding,
+			},
+			false,
+		},
+		{
+			"Missing File",
+			[]string{filepath.Join(root, "missing.php")},
+			nil,
+			map[string]string{},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &Result{
+				Files:     tt.files,
+				FilesPath: dir,
+			}
+
+			err := scanFiles(res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("scanFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			binary := map[string]bool{}
+			for _, file := range res.BinaryFiles {
+				binary[file] = true
+			}
+			wantBinary := map[string]bool{}
+			for _, file := range tt.wantBinary {
+				wantBinary[file] = true
+			}
+			if !reflect.DeepEqual(binary, wantBinary) {
+				t.Errorf("scanFiles() binary = %v, want %v", res.BinaryFiles, tt.wantBinary)
+			}
+
+			findings := map[string]string{}
+			for _, finding := range res.Findings {
+				if finding.Severity != FindingInfo {
+					t.Errorf("scanFiles() severity = %v, want %v", finding.Severity, FindingInfo)
+				}
+				findings[finding.File] = finding.Type
+			}
+			if !reflect.DeepEqual(findings, tt.wantFindings) {
+				t.Errorf("scanFiles() findings = %v, want %v", findings, tt.wantFindings)
+			}
+		})
+	}
+}
+
+func Test_detectBOM(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"UTF-8", []byte{0xEF, 0xBB, 0xBF, 'a'}, "UTF-8"},
+		{"UTF-16BE", []byte{0xFE, 0xFF, 0, 'a'}, "UTF-16BE"},
+		{"UTF-16LE", []byte{0xFF, 0xFE, 'a', 0}, "UTF-16LE"},
+		{"No BOM", []byte("<?php"), ""},
+		{"Empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectBOM(tt.data); got != tt.want {
+				t.Errorf("detectBOM() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_isBinary(t *testing.T) {
+	late := make([]byte, binarySniffLen+10)
+	for i := range late {
+		late[i] = 'a'
+	}
+	late[binarySniffLen+5] = 0
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"Text", []byte("<?php\necho 'hello';"), false},
+		{"Binary", []byte{'a', 0, 'b'}, true},
+		{"NUL After Sniff Length", late, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBinary(tt.data); got != tt.want {
+				t.Errorf("isBinary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ignorePatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		ignore  string
+		files   []string
+		want    string
+		wantErr bool
+	}{
+		{"Nothing", "", nil, "", false},
+		{"Ignore Only", "*/vendor/*", nil, "*/vendor/*", false},
+		{"Files Only", "", []string{"/tmp/a", "/tmp/b"}, "/tmp/a,/tmp/b", false},
+		{"Ignore And Files", "*/vendor/*", []string{"/tmp/a"}, "*/vendor/*,/tmp/a", false},
+		{"Quoted Files", "", []string{"/tmp/a.php", "/tmp/(b)+.php"}, `/tmp/a\.php,/tmp/\(b\)\+\.php`, false},
+		{"Comma", "", []string{"/tmp/a,b.php"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ignorePatterns(tt.ignore, tt.files)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ignorePatterns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ignorePatterns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/provision"
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+// Viewport describes the size of a rendered screenshot.
+type Viewport struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// DefaultViewports are used when a Screenshot process does not provide its own viewports.
+var DefaultViewports = []Viewport{
+	{"mobile", 375, 667},
+	{"tablet", 768, 1024},
+	{"desktop", 1280, 800},
+}
+
+// Screenshot defines the structure for our Screenshot process.
+// It renders the front page of themes and uploads the screenshots for directory previews.
+type Screenshot struct {
+	Process                               // Inherits methods from Process.
+	In              <-chan Processor      // Expects a processor channel as input.
+	Out             chan Processor        // Send results to an output channel.
+	TempFolder      string                // Path to a temp folder where screenshots will be rendered.
+	StorageProvider storage.Provider      // Storage provider to upload screenshots to.
+	Provisioner     provision.Provisioner // (Optional) Provisions a demo environment instead of using the hosted theme demos.
+	Viewports       []Viewport            // (Optional) Viewports to render. Defaults to DefaultViewports.
+	Chrome          string                // (Optional) Headless Chrome binary. Defaults to "google-chrome".
+	NoSandbox       bool                  // (Optional) Runs Chrome without its sandbox, e.g. as root in a container. Only use it for trusted demos.
+	Runner          shell.Runner          // (Optional) Runs Chrome. Defaults to shell.Command.
+}
+
+// Run executes the process in a pipe.
+func (ss *Screenshot) Run(sink ErrorSink) error {
+	if ss.TempFolder == "" {
+		return errors.New("no temp folder provided for screenshots")
+	}
+
+	if ss.StorageProvider == nil {
+		return errors.New("no storage provider for screenshots")
+	}
+
+	if ss.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if ss.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	ss.runStage(sink, stage{name: "Screenshot", in: ss.In, out: ss.Out, do: ss.Do})
+
+	return nil
+}
+
+// Do renders the front page of a theme at each viewport and records the storage
+// references of the screenshots in the result. Other project types are skipped.
+func (ss *Screenshot) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if projectType(msg, res) != provision.TypeTheme {
+		return res, nil
+	}
+
+	if res == nil || res.Checksum == "" {
+		return res, errors.New("there was no checksum to be used for filenames")
+	}
+
+	log.Log(msg.Title, "Rendering screenshots...")
+
+	url, teardown, err := demoURL(ctx, ss.Provisioner, msg, res)
+	if err != nil {
+		return res, err
+	}
+	defer teardown()
+
+	runner := ss.Runner
+	if runner == nil {
+		runner = defaultRunner
+	}
+
+	chrome := ss.Chrome
+	if chrome == "" {
+		chrome = "google-chrome"
+	}
+
+	viewports := ss.Viewports
+	if len(viewports) == 0 {
+		viewports = DefaultViewports
+	}
+
+	for _, viewport := range viewports {
+		storageRef := res.Checksum + "-screenshot-" + viewport.Name + ".png"
+		filename := strings.TrimRight(ss.TempFolder, "/") + "/" + storageRef
+
+		args := []string{"--headless", "--disable-gpu"}
+		if ss.NoSandbox {
+			args = append(args, "--no-sandbox")
+		}
+		args = append(args,
+			"--hide-scrollbars",
+			"--screenshot="+filename,
+			"--window-size="+strconv.Itoa(viewport.Width)+","+strconv.Itoa(viewport.Height),
+			url,
+		)
+
+		_, errorBytes, _, err := runCommand(ctx, res, runner, chrome, args...)
+		if err != nil {
+			if output := strings.TrimSpace(string(errorBytes)); output != "" {
+				err = errors.New(output)
+			}
+			return res, messageError(msg, fmt.Sprintf("could not render %s screenshot: %s", viewport.Name, err))
+		}
+		defer os.Remove(filename)
+
+		done := res.timeStage("upload")
+		err = storage.WithContext(ctx, meterStorage(ss.StorageProvider, res)).UploadFile(filename, storageRef)
+		done()
+		if err != nil {
+			return res, withCode(tide.FailureStorage, err)
+		}
+
+		res.SetScreenshot(viewport.Name, storageRef)
+	}
+
+	log.Log(msg.Title, "Screenshot process complete.")
+
+	return res, nil
+}
@@ -0,0 +1,286 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// mockScreenshotRunner writes an empty screenshot, unless the URL asks for an error.
+type mockScreenshotRunner struct {
+	args [][]string
+}
+
+func (m *mockScreenshotRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	m.args = append(m.args, arg)
+
+	url := arg[len(arg)-1]
+	if strings.HasSuffix(url, "/error") {
+		return nil, []byte("chrome crashed"), 1, errors.New("exit status 1")
+	}
+	if strings.HasSuffix(url, "/nofile") {
+		return nil, nil, 0, nil
+	}
+
+	for _, a := range arg {
+		if strings.HasPrefix(a, "--screenshot=") {
+			return nil, nil, 0, ioutil.WriteFile(strings.TrimPrefix(a, "--screenshot="), []byte("png"), 0644)
+		}
+	}
+
+	return nil, nil, 1, errors.New("no screenshot path")
+}
+
+func TestScreenshot_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		ss      *Screenshot
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Screenshot{
+				In:              make(chan Processor),
+				Out:             make(chan Processor),
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+			},
+			false,
+		},
+		{
+			"No Temp Folder",
+			&Screenshot{
+				In:              make(chan Processor),
+				Out:             make(chan Processor),
+				StorageProvider: &mockStorage{},
+			},
+			true,
+		},
+		{
+			"No Storage Provider",
+			&Screenshot{
+				In:         make(chan Processor),
+				Out:        make(chan Processor),
+				TempFolder: "./testdata/tmp",
+			},
+			true,
+		},
+		{
+			"No In Channel",
+			&Screenshot{
+				Out:             make(chan Processor),
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Screenshot{
+				In:              make(chan Processor),
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.ss.SetContext(ctx)
+
+			if err := tt.ss.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Screenshot.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScreenshot_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	os.MkdirAll("./testdata/tmp", os.ModePerm)
+	defer os.RemoveAll("./testdata/tmp")
+
+	os.MkdirAll("./testdata/upload", os.ModePerm)
+	defer os.RemoveAll("./testdata/upload")
+
+	theme := &tide.CodeInfo{Type: "theme"}
+
+	tests := []struct {
+		name    string
+		ss      *Screenshot
+		msg     message.Message
+		res     *Result
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			"Plugins Are Skipped",
+			&Screenshot{},
+			message.Message{Title: "Test", Slug: "test", ProjectType: "plugin"},
+			&Result{Checksum: "checksum"},
+			nil,
+			false,
+		},
+		{
+			"No Checksum",
+			&Screenshot{},
+			message.Message{Title: "Test", Slug: "test"},
+			&Result{Info: theme},
+			nil,
+			true,
+		},
+		{
+			"Default Viewports",
+			&Screenshot{
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+				Runner:          &mockScreenshotRunner{},
+			},
+			message.Message{Title: "Test", Slug: "test"},
+			&Result{Checksum: "checksum", Info: theme},
+			map[string]string{
+				"mobile":  "checksum-screenshot-mobile.png",
+				"tablet":  "checksum-screenshot-tablet.png",
+				"desktop": "checksum-screenshot-desktop.png",
+			},
+			false,
+		},
+		{
+			"Custom Viewports With Provisioner",
+			&Screenshot{
+				TempFolder:      "./testdata/tmp/",
+				StorageProvider: &mockStorage{},
+				Runner:          &mockScreenshotRunner{},
+				Provisioner:     &mockProvisioner{},
+				Viewports:       []Viewport{{"wide", 1920, 1080}},
+			},
+			message.Message{Title: "Test", Slug: "test", ProjectType: "theme"},
+			&Result{Checksum: "checksum"},
+			map[string]string{
+				"wide": "checksum-screenshot-wide.png",
+			},
+			false,
+		},
+		{
+			"Provision Error",
+			&Screenshot{
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+				Runner:          &mockScreenshotRunner{},
+				Provisioner:     &mockProvisioner{},
+			},
+			message.Message{Title: "Test", Slug: "error"},
+			&Result{Checksum: "checksum", Info: theme},
+			nil,
+			true,
+		},
+		{
+			"Render Error",
+			&Screenshot{
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+				Runner:          &mockScreenshotRunner{},
+			},
+			message.Message{Title: "Test", Slug: "error"},
+			&Result{Checksum: "checksum", Info: theme},
+			nil,
+			true,
+		},
+		{
+			"Upload Error",
+			&Screenshot{
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+				Runner:          &mockScreenshotRunner{},
+			},
+			message.Message{Title: "Test", Slug: "nofile"},
+			&Result{Checksum: "nofile", Info: theme},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.ss.Do(context.Background(), tt.msg, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Screenshot.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got.Screenshots, tt.want) {
+				t.Errorf("Screenshot.Do() screenshots = %v, want %v", got.Screenshots, tt.want)
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			for _, ref := range tt.want {
+				if _, err := os.Stat("./testdata/upload/" + ref); err != nil {
+					t.Errorf("Screenshot.Do() did not upload %v", ref)
+				}
+				if _, err := os.Stat("./testdata/tmp/" + ref); !os.IsNotExist(err) {
+					t.Errorf("Screenshot.Do() did not remove the rendered %v", ref)
+				}
+			}
+		})
+	}
+}
+
+func TestScreenshot_Do_Sandbox(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	os.MkdirAll("./testdata/tmp", os.ModePerm)
+	defer os.RemoveAll("./testdata/tmp")
+
+	os.MkdirAll("./testdata/upload", os.ModePerm)
+	defer os.RemoveAll("./testdata/upload")
+
+	tests := []struct {
+		name      string
+		noSandbox bool
+	}{
+		{"Sandboxed", false},
+		{"No Sandbox", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &mockScreenshotRunner{}
+			ss := &Screenshot{
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &mockStorage{},
+				Viewports:       []Viewport{{"wide", 1920, 1080}},
+				NoSandbox:       tt.noSandbox,
+				Runner:          runner,
+			}
+
+			msg := message.Message{Title: "Test", Slug: "test"}
+			if _, err := ss.Do(context.Background(), msg, &Result{Checksum: "checksum", Info: &tide.CodeInfo{Type: "theme"}}); err != nil {
+				t.Fatalf("Screenshot.Do() error = %v", err)
+			}
+
+			noSandbox := false
+			for _, arg := range runner.args[0] {
+				noSandbox = noSandbox || arg == "--no-sandbox"
+			}
+			if noSandbox != tt.noSandbox {
+				t.Errorf("Screenshot.Do() ran Chrome with %v", runner.args[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// Issue severities, from most to least severe.
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityMedium   = "medium"
+	SeverityLow      = "low"
+)
+
+// severityRank is used to sort issues by severity.
+var severityRank = map[string]int{
+	SeverityCritical: 4,
+	SeverityHigh:     3,
+	SeverityMedium:   2,
+	SeverityLow:      1,
+}
+
+var (
+	// Hook registrations for admin-post and AJAX handlers, e.g. `add_action( 'wp_ajax_save', 'my_save' );`.
+	handlerHookRe = regexp.MustCompile(`add_action\s*\(\s*['"](wp_ajax_nopriv_|wp_ajax_|admin_post_nopriv_|admin_post_)[\w\-]*['"]\s*,([^;]*)`)
+	// Quoted callback names, including `Class::method` and `array( $this, 'method' )` callbacks.
+	callbackNameRe = regexp.MustCompile(`['"]([\w\\]+(?:::\w+)?)['"]`)
+	// Function and method declarations.
+	functionRe = regexp.MustCompile(`function\s+&?\s*(\w+)\s*\(`)
+	// Request data that is controlled by the user.
+	superglobalRe = regexp.MustCompile(`\$_(GET|POST|REQUEST|COOKIE)\s*\[`)
+	// Database queries, either through $wpdb or raw SQL strings.
+	queryRe = regexp.MustCompile(`(?i)\$wpdb\s*->\s*(query|get_results|get_row|get_var|get_col)\s*\(|['"]\s*(SELECT|INSERT|UPDATE|DELETE)\s[^'"]*\b(FROM|INTO|SET|WHERE)\b`)
+	// Functions that make request data safe to use in a query.
+	sqlSanitizeRe = regexp.MustCompile(`\$wpdb\s*->\s*prepare\s*\(|\b(esc_sql|absint|intval)\s*\(|\(\s*int\s*\)`)
+	// Nonce verification functions.
+	nonceRe = regexp.MustCompile(`\b(wp_verify_nonce|check_admin_referer|check_ajax_referer)\s*\(`)
+	// Capability checks.
+	capabilityRe = regexp.MustCompile(`\bcurrent_user_can\s*\(`)
+)
+
+// Issue is a potential problem found by the Security or Database audits.
+type Issue struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Message  string `json:"message"`
+}
+
+// Source contains the PHP source of a project.
+type Source struct {
+	Files     map[string]string // File contents keyed by the path relative to the project root.
+	Functions map[string]string // Function and method bodies keyed by name.
+}
+
+// SecurityRule checks a file for a vulnerable pattern.
+type SecurityRule struct {
+	ID       string
+	Severity string
+	Message  string
+	// Check returns the lines of the file where the pattern was found.
+	Check func(source *Source, file string) []int
+}
+
+// WordPressSecurityRules are used when a Security process does not provide its own rules.
+var WordPressSecurityRules = []SecurityRule{
+	{
+		ID:       "sql-injection",
+		Severity: SeverityCritical,
+		Message:  "request data is used in a database query without being prepared or sanitized",
+		Check:    checkUnsanitizedQuery,
+	},
+	{
+		ID:       "admin-post-nonce",
+		Severity: SeverityHigh,
+		Message:  "admin-post handler does not verify a nonce",
+		Check:    checkHandlers([]string{"admin_post_", "admin_post_nopriv_"}, nonceRe),
+	},
+	{
+		ID:       "ajax-capability",
+		Severity: SeverityHigh,
+		Message:  "AJAX handler does not check the capabilities of the current user",
+		Check:    checkHandlers([]string{"wp_ajax_"}, capabilityRe),
+	},
+}
+
+// Security defines the structure for our Security process.
+// It checks PHP files for WordPress specific vulnerable patterns.
+type Security struct {
+	Process                  // Inherits methods from Process.
+	In      <-chan Processor // Expects a processor channel as input.
+	Out     chan Processor   // Send results to an output channel.
+	Rules   []SecurityRule   // (Optional) Rules to check. Defaults to WordPressSecurityRules.
+}
+
+// Run executes the process in a pipe.
+func (sec *Security) Run(sink ErrorSink) error {
+	if sec.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if sec.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	sec.runStage(sink, stage{name: "Security", in: sec.In, out: sec.Out, do: sec.Do})
+
+	return nil
+}
+
+// Do runs a security audit if the message requests one and adds the issues, ranked by
+// severity, to the "security" audit of the result.
+func (sec *Security) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if !hasAudit(msg, "security") {
+		return res, nil
+	}
+
+	if res == nil || res.FilesPath == "" {
+		return res, errors.New("could not determine files path")
+	}
+
+	if skipped(res, "security") {
+		return res, nil
+	}
+
+	log.Log(msg.Title, "Running Security Audit...")
+
+	source, err := loadSource(res, newPathFilter(auditOptions(msg, "security")))
+	if err != nil {
+		return res, err
+	}
+
+	rules := sec.Rules
+	if len(rules) == 0 {
+		rules = WordPressSecurityRules
+	}
+
+	issues := []Issue{}
+	counts := make(map[string]int)
+	for file := range source.Files {
+		for _, rule := range rules {
+			for _, line := range rule.Check(source, file) {
+				issues = append(issues, Issue{
+					Rule:     rule.ID,
+					Severity: rule.Severity,
+					File:     file,
+					Line:     line,
+					Message:  rule.Message,
+				})
+				counts[rule.Severity]++
+			}
+		}
+	}
+
+	sortIssues(issues)
+
+	res.SetAudit("security", tide.AuditResult{
+		Extra: map[string]interface{}{
+			"issues": issues,
+			"counts": counts,
+		},
+	})
+
+	log.Log(msg.Title, fmt.Sprintf("security audit found %d issues", len(issues)))
+
+	return res, nil
+}
+
+// loadSource reads the PHP files of the result that the filter audits. Binary files are skipped.
+func loadSource(res *Result, filter pathFilter) (*Source, error) {
+	source := &Source{
+		Files:     make(map[string]string),
+		Functions: make(map[string]string),
+	}
+
+	binary := make(map[string]bool)
+	for _, file := range res.BinaryFiles {
+		binary[file] = true
+	}
+
+	root := res.FilesPath + "/unzipped"
+	for _, file := range res.Files {
+		if binary[file] || strings.ToLower(filepath.Ext(file)) != ".php" || !filter.audits(file) {
+			continue
+		}
+
+		f, err := fileOpen(file)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		src := string(data)
+		source.Files[relativeName(root, file)] = src
+
+		for _, match := range functionRe.FindAllStringSubmatchIndex(src, -1) {
+			name := src[match[2]:match[3]]
+			source.Functions[name] = blockAfter(src, match[1])
+		}
+	}
+
+	return source, nil
+}
+
+// checkUnsanitizedQuery finds lines that pass request data to a query without preparing it.
+func checkUnsanitizedQuery(source *Source, file string) []int {
+	var lines []int
+	for i, line := range strings.Split(source.Files[file], "\n") {
+		if superglobalRe.MatchString(line) && queryRe.MatchString(line) && !sqlSanitizeRe.MatchString(line) {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// checkHandlers returns a check that finds handlers registered for the hook prefixes
+// whose body does not match required. Handlers that can't be found are skipped.
+func checkHandlers(prefixes []string, required *regexp.Regexp) func(*Source, string) []int {
+	return func(source *Source, file string) []int {
+		src := source.Files[file]
+
+		var lines []int
+		for _, match := range handlerHookRe.FindAllStringSubmatchIndex(src, -1) {
+			prefix := src[match[2]:match[3]]
+			if !containsString(prefixes, prefix) {
+				continue
+			}
+
+			body, ok := handlerBody(source, src, match[4], match[5])
+			if !ok || required.MatchString(body) {
+				continue
+			}
+
+			lines = append(lines, strings.Count(src[:match[0]], "\n")+1)
+		}
+		return lines
+	}
+}
+
+// handlerBody returns the body of the callback registered in src[start:end].
+func handlerBody(source *Source, src string, start, end int) (string, bool) {
+	callback := src[start:end]
+
+	// Closures are declared in place.
+	if i := strings.Index(callback, "function"); i != -1 {
+		return blockAfter(src, start+i), true
+	}
+
+	names := callbackNameRe.FindAllStringSubmatch(callback, -1)
+	if len(names) == 0 {
+		return "", false
+	}
+
+	name := names[len(names)-1][1]
+	if i := strings.LastIndex(name, "::"); i != -1 {
+		name = name[i+2:]
+	}
+	if i := strings.LastIndex(name, `\`); i != -1 {
+		name = name[i+1:]
+	}
+
+	body, ok := source.Functions[name]
+	return body, ok
+}
+
+// blockAfter returns the first brace delimited block in src after offset.
+// Braces in strings and comments are not taken into account.
+func blockAfter(src string, offset int) string {
+	start := strings.Index(src[offset:], "{")
+	if start == -1 {
+		return ""
+	}
+	start += offset
+
+	depth := 0
+	for i := start; i < len(src); i++ {
+		switch src[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return src[start : i+1]
+			}
+		}
+	}
+
+	return src[start:]
+}
+
+// sortIssues orders issues by severity, file and line.
+func sortIssues(issues []Issue) {
+	sort.Slice(issues, func(i, j int) bool {
+		a, b := issues[i], issues[j]
+		if severityRank[a.Severity] != severityRank[b.Severity] {
+			return severityRank[a.Severity] > severityRank[b.Severity]
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
+
+// containsString checks if list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+func TestSecurity_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		sec     *Security
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&Security{
+				In:  make(chan Processor),
+				Out: make(chan Processor),
+			},
+			false,
+		},
+		{
+			"No In Channel",
+			&Security{
+				Out: make(chan Processor),
+			},
+			true,
+		},
+		{
+			"No Out Channel",
+			&Security{
+				In: make(chan Processor),
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.sec.SetContext(ctx)
+
+			if err := tt.sec.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("Security.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecurity_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	securityMsg := message.Message{
+		Title:  "Security Test Plugin",
+		Audits: []*message.Audit{{Type: "security"}},
+	}
+
+	files := []string{
+		"./testdata/security/unzipped/plugin.php",
+		"./testdata/security/unzipped/includes/class-admin.php",
+		"./testdata/security/unzipped/includes/readme.txt",
+	}
+
+	tests := []struct {
+		name    string
+		sec     *Security
+		msg     message.Message
+		res     *Result
+		want    []Issue
+		wantErr bool
+	}{
+		{
+			"Not Requested",
+			&Security{},
+			message.Message{Audits: []*message.Audit{{Type: "phpcs"}}},
+			&Result{FilesPath: "./testdata/security", Files: files},
+			nil,
+			false,
+		},
+		{
+			"WordPress Rules",
+			&Security{},
+			securityMsg,
+			&Result{FilesPath: "./testdata/security", Files: files},
+			[]Issue{
+				{"sql-injection", SeverityCritical, "includes/class-admin.php", 6, WordPressSecurityRules[0].Message},
+				{"admin-post-nonce", SeverityHigh, "plugin.php", 6, WordPressSecurityRules[1].Message},
+				{"ajax-capability", SeverityHigh, "plugin.php", 8, WordPressSecurityRules[2].Message},
+				{"admin-post-nonce", SeverityHigh, "plugin.php", 11, WordPressSecurityRules[1].Message},
+			},
+			false,
+		},
+		{
+			"Binary Files Skipped",
+			&Security{},
+			securityMsg,
+			&Result{FilesPath: "./testdata/security", Files: files, BinaryFiles: files},
+			[]Issue{},
+			false,
+		},
+		{
+			"Custom Rules",
+			&Security{Rules: []SecurityRule{
+				{
+					ID:       "eval",
+					Severity: SeverityLow,
+					Message:  "custom",
+					Check: func(source *Source, file string) []int {
+						if file == "plugin.php" {
+							return []int{1}
+						}
+						return nil
+					},
+				},
+			}},
+			securityMsg,
+			&Result{FilesPath: "./testdata/security", Files: files},
+			[]Issue{
+				{"eval", SeverityLow, "plugin.php", 1, "custom"},
+			},
+			false,
+		},
+		{
+			"No Files Path",
+			&Security{},
+			securityMsg,
+			&Result{Files: files},
+			nil,
+			true,
+		},
+		{
+			"No Result",
+			&Security{},
+			securityMsg,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Missing File",
+			&Security{},
+			securityMsg,
+			&Result{FilesPath: "./testdata/security", Files: []string{"./testdata/security/unzipped/missing.php"}},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.sec.Do(context.Background(), tt.msg, tt.res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Security.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			audit, ok := res.Audit("security")
+			if tt.want == nil {
+				if ok {
+					t.Errorf("Security.Do() unexpected audit = %v", audit)
+				}
+				return
+			}
+
+			if got := audit.Extra["issues"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Security.Do() issues = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_blockAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"Nested", "function a() { if ( $b ) { c(); } } d();", "{ if ( $b ) { c(); } }"},
+		{"Unterminated", "function a() { if ( $b ) {", "{ if ( $b ) {"},
+		{"No Block", "function a();", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockAfter(tt.src, 0); got != tt.want {
+				t.Errorf("blockAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadSource_ReadError(t *testing.T) {
+	fileOpen = func(name string) (*os.File, error) {
+		return nil, errors.New("open error")
+	}
+	defer func() { fileOpen = os.Open }()
+
+	if _, err := loadSource(&Result{FilesPath: "./testdata/security", Files: []string{"plugin.php"}}, pathFilter{}); err == nil {
+		t.Errorf("loadSource() expected an error")
+	}
+}
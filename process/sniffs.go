@@ -0,0 +1,120 @@
+package process
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// sniffCode matches the sniff codes of the Sniffs and Exclude options, e.g. "Squiz.Commenting.FileComment",
+// and patterns for whole standards or categories, e.g. "Squiz.Commenting.*" or "Squiz".
+var sniffCode = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_]+){0,2}(\.\*)?$`)
+
+// sniffSelection restricts an audit to some sniffs, or excludes sniffs from it. PHPCS only
+// accepts full sniff codes for --sniffs and --exclude, so patterns for standards and categories
+// are applied to the report instead.
+type sniffSelection struct {
+	sniffs  []string
+	exclude []string
+}
+
+// newSniffSelection returns the sniff selection of the audit options, which may be nil.
+func newSniffSelection(options *message.AuditOption) (sniffSelection, error) {
+	if options == nil {
+		return sniffSelection{}, nil
+	}
+
+	sniffs, err := splitSniffCodes(options.Sniffs)
+	if err != nil {
+		return sniffSelection{}, err
+	}
+	exclude, err := splitSniffCodes(options.Exclude)
+	if err != nil {
+		return sniffSelection{}, err
+	}
+
+	return sniffSelection{sniffs: sniffs, exclude: exclude}, nil
+}
+
+// args returns the PHPCS arguments for the selection.
+func (s sniffSelection) args() []string {
+	args := []string{}
+
+	// Restricting PHPCS to some of the sniffs would drop the messages of the patterns.
+	if len(s.sniffs) != 0 && len(fullSniffCodes(s.sniffs)) == len(s.sniffs) {
+		args = append(args, "--sniffs="+strings.Join(s.sniffs, ","))
+	}
+	if exclude := fullSniffCodes(s.exclude); len(exclude) != 0 {
+		args = append(args, "--exclude="+strings.Join(exclude, ","))
+	}
+
+	return args
+}
+
+// filter removes the messages of the sniffs that are not selected from the report and updates
+// the totals.
+func (s sniffSelection) filter(results *tide.PhpcsResults) {
+	if results == nil || (len(s.sniffs) == 0 && len(s.exclude) == 0) {
+		return
+	}
+
+	for name, file := range results.Files {
+		var kept []tide.PhpcsFilesMessage
+		for _, msg := range file.Messages {
+			if len(s.sniffs) != 0 && !matchesSniff(s.sniffs, msg.Source) {
+				continue
+			}
+			if matchesSniff(s.exclude, msg.Source) {
+				continue
+			}
+			kept = append(kept, msg)
+		}
+
+		file.Messages = kept
+		results.Files[name] = file
+	}
+
+	countMessages(results)
+}
+
+// splitSniffCodes splits comma separated sniff codes and validates them.
+func splitSniffCodes(codes string) ([]string, error) {
+	split := []string{}
+	for _, code := range strings.Split(codes, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if !sniffCode.MatchString(code) {
+			return nil, errors.New("invalid sniff code: " + code)
+		}
+		split = append(split, code)
+	}
+	return split, nil
+}
+
+// fullSniffCodes returns the codes that name a single sniff, i.e. "Standard.Category.Sniff".
+func fullSniffCodes(codes []string) []string {
+	full := []string{}
+	for _, code := range codes {
+		if !strings.HasSuffix(code, "*") && strings.Count(code, ".") == 2 {
+			full = append(full, code)
+		}
+	}
+	return full
+}
+
+// matchesSniff determines if the source of a message, e.g.
+// "Squiz.Commenting.FileComment.Missing", belongs to any of the sniff codes or patterns.
+func matchesSniff(codes []string, source string) bool {
+	for _, code := range codes {
+		code = strings.TrimSuffix(code, ".*")
+		if source == code || strings.HasPrefix(source, code+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+func Test_sniffSelection_args(t *testing.T) {
+	tests := []struct {
+		name    string
+		options *message.AuditOption
+		want    []string
+		wantErr bool
+	}{
+		{"No Options", nil, []string{}, false},
+		{"Sniffs", &message.AuditOption{Sniffs: "WordPress.Security.EscapeOutput, WordPress.Security.NonceVerification"}, []string{"--sniffs=WordPress.Security.EscapeOutput,WordPress.Security.NonceVerification"}, false},
+		{"Sniff Patterns", &message.AuditOption{Sniffs: "WordPress.Security.EscapeOutput,WordPress.DB.*"}, []string{}, false},
+		{"Exclude", &message.AuditOption{Exclude: "Squiz.Commenting.*,Generic.Files.LineLength"}, []string{"--exclude=Generic.Files.LineLength"}, false},
+		{"Invalid Code", &message.AuditOption{Exclude: "Squiz.Commenting --report-file=/etc/passwd"}, nil, true},
+		{"Too Many Parts", &message.AuditOption{Sniffs: "A.B.C.D.E"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, err := newSniffSelection(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSniffSelection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := selection.args(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sniffSelection.args() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_sniffSelection_filter(t *testing.T) {
+	report := func() *tide.PhpcsResults {
+		results := &tide.PhpcsResults{}
+		addReportMessage(results, "plugin.php", tide.PhpcsFilesMessage{Type: "ERROR", Source: "WordPress.Security.EscapeOutput.OutputNotEscaped"})
+		addReportMessage(results, "plugin.php", tide.PhpcsFilesMessage{Type: "WARNING", Source: "WordPress.DB.PreparedSQL.NotPrepared"})
+		addReportMessage(results, "plugin.php", tide.PhpcsFilesMessage{Type: "ERROR", Source: "Squiz.Commenting.FileComment.Missing"})
+		return results
+	}
+
+	tests := []struct {
+		name         string
+		options      *message.AuditOption
+		wantErrors   int
+		wantWarnings int
+	}{
+		{"No Selection", &message.AuditOption{}, 2, 1},
+		{"Exclude Category", &message.AuditOption{Exclude: "Squiz.Commenting.*"}, 1, 1},
+		{"Exclude Standard", &message.AuditOption{Exclude: "Squiz"}, 1, 1},
+		{"Restrict", &message.AuditOption{Sniffs: "WordPress.DB.*"}, 0, 1},
+		{"Restrict And Exclude", &message.AuditOption{Sniffs: "WordPress", Exclude: "WordPress.DB.PreparedSQL"}, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection, _ := newSniffSelection(tt.options)
+			results := report()
+			selection.filter(results)
+			if results.Totals.Errors != tt.wantErrors || results.Totals.Warnings != tt.wantWarnings {
+				t.Errorf("sniffSelection.filter() totals = %v, want %v errors and %v warnings", results.Totals, tt.wantErrors, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package process
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StandardsManager describes the coding standards installed for PHPCS.
+type StandardsManager interface {
+	// Versions returns the installed component versions for a standard,
+	// e.g. {"phpcs": "3.3.1", "wpcs": "0.14.1"} for "wordpress".
+	Versions(standard string) (map[string]string, error)
+}
+
+// StaticStandards is a StandardsManager for a fixed map of standards to component versions.
+type StaticStandards map[string]map[string]string
+
+// Versions implements StandardsManager. Standards without versions of their own use the
+// versions of AnyStandard, if there are any.
+func (s StaticStandards) Versions(standard string) (map[string]string, error) {
+	versions, ok := s[standard]
+	if !ok && standardName.MatchString(standard) {
+		versions, ok = s[AnyStandard]
+	}
+	if !ok {
+		return nil, errors.New("could not determine PHPCS versions")
+	}
+	return versions, nil
+}
+
+// resolveVersions gets the installed versions for a standard and verifies that they
+// satisfy the pinned versions requested by the message (e.g. {"wpcs": "3.0.x"}).
+func resolveVersions(manager StandardsManager, standard string, pinned map[string]string) (map[string]string, error) {
+	installed, err := manager.Versions(standard)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check components in a stable order so that errors are predictable.
+	var components []string
+	for component := range pinned {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		constraint := pinned[component]
+		version, ok := installed[component]
+		if !ok {
+			return nil, errors.New("version unavailable: " + component + " " + constraint + " is not installed")
+		}
+		if !matchVersion(constraint, version) {
+			return nil, errors.New("version unavailable: " + component + " " + constraint + " (installed " + version + ")")
+		}
+	}
+
+	return installed, nil
+}
+
+// matchVersion checks if a version matches a constraint. Constraints are either an exact
+// version or a version with wildcard parts, e.g. "3.0.x", "9.x" or "9".
+func matchVersion(constraint, version string) bool {
+	constraint = strings.TrimSpace(constraint)
+	if constraint == "" || constraint == version {
+		return true
+	}
+
+	// Ignore pre-release and build metadata for wildcard matching.
+	if i := strings.IndexAny(version, "-+"); i != -1 {
+		version = version[:i]
+	}
+
+	versionParts := strings.Split(version, ".")
+	for i, part := range strings.Split(constraint, ".") {
+		switch strings.ToLower(part) {
+		case "x", "*":
+			return true
+		}
+		if i >= len(versionParts) || versionParts[i] != part {
+			return false
+		}
+	}
+
+	return true
+}
+
+// StandardsInstallation is a set of PHPCS components installed in a folder, e.g. by a StandardsWatcher.
+type StandardsInstallation struct {
+	Path      string                       // Folder the components are installed in.
+	Phpcs     string                       // (Optional) Path of the phpcs executable. Defaults to "phpcs" on the PATH.
+	Standards map[string]map[string]string // Component versions per standard, in the same format as Phpcs.PhpcsVersions.
+}
+
+// Versions implements StandardsManager.
+func (i *StandardsInstallation) Versions(standard string) (map[string]string, error) {
+	return StaticStandards(i.Standards).Versions(standard)
+}
+
+// ActiveStandards is a StandardsManager for the installation that is currently in use.
+// The installation can be switched while audits are running: an audit that has already
+// started keeps using the installation it started with.
+type ActiveStandards struct {
+	mu      sync.RWMutex
+	current *StandardsInstallation
+}
+
+// NewActiveStandards returns ActiveStandards that use the given installation.
+func NewActiveStandards(installation *StandardsInstallation) *ActiveStandards {
+	return &ActiveStandards{
+		current: installation,
+	}
+}
+
+// Current returns the installation in use.
+func (a *ActiveStandards) Current() *StandardsInstallation {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.current
+}
+
+// Switch replaces the installation in use.
+func (a *ActiveStandards) Switch(installation *StandardsInstallation) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.current = installation
+}
+
+// Versions implements StandardsManager.
+func (a *ActiveStandards) Versions(standard string) (map[string]string, error) {
+	current := a.Current()
+	if current == nil {
+		return nil, errors.New("no PHPCS standards installed")
+	}
+	return current.Versions(standard)
+}
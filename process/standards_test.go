@@ -0,0 +1,214 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testStandards = StaticStandards{
+	"wordpress": {
+		"phpcs": "3.3.1",
+		"wpcs":  "3.0.1",
+	},
+	"phpcompatibility": {
+		"phpcs":            "3.3.1",
+		"phpcompatibility": "9.1.0-beta",
+	},
+}
+
+func TestStaticStandards_Versions(t *testing.T) {
+	tests := []struct {
+		name     string
+		standard string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			"Installed Standard",
+			"wordpress",
+			testStandards["wordpress"],
+			false,
+		},
+		{
+			"Missing Standard",
+			"psr2",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testStandards.Versions(tt.standard)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StaticStandards.Versions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StaticStandards.Versions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStaticStandards_Versions_AnyStandard(t *testing.T) {
+	standards := StaticStandards{
+		"wordpress": testStandards["wordpress"],
+		AnyStandard: {"phpcs": "3.7.2"},
+	}
+
+	tests := []struct {
+		name     string
+		standard string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{"Own Versions", "wordpress", testStandards["wordpress"], false},
+		{"Installed Standard", "PSR12", map[string]string{"phpcs": "3.7.2"}, false},
+		{"Invalid Name", "../PSR12", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := standards.Versions(tt.standard)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("StaticStandards.Versions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StaticStandards.Versions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_resolveVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		standard string
+		pinned   map[string]string
+		want     map[string]string
+		wantErr  bool
+	}{
+		{
+			"No Pinned Versions",
+			"wordpress",
+			nil,
+			testStandards["wordpress"],
+			false,
+		},
+		{
+			"Pinned Versions Match",
+			"wordpress",
+			map[string]string{
+				"phpcs": "3.x",
+				"wpcs":  "3.0.x",
+			},
+			testStandards["wordpress"],
+			false,
+		},
+		{
+			"Pinned Pre-release Match",
+			"phpcompatibility",
+			map[string]string{
+				"phpcompatibility": "9.x",
+			},
+			testStandards["phpcompatibility"],
+			false,
+		},
+		{
+			"Version Unavailable",
+			"wordpress",
+			map[string]string{
+				"wpcs": "2.x",
+			},
+			nil,
+			true,
+		},
+		{
+			"Component Not Installed",
+			"wordpress",
+			map[string]string{
+				"phpcompatibility": "9.x",
+			},
+			nil,
+			true,
+		},
+		{
+			"Standard Not Installed",
+			"psr2",
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveVersions(testStandards, tt.standard, tt.pinned)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveVersions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchVersion(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "3.0.1", true},
+		{"3.0.1", "3.0.1", true},
+		{"0.0.1-phpcs", "0.0.1-phpcs", true},
+		{"3.0.x", "3.0.1", true},
+		{"3.0.*", "3.0.7", true},
+		{"3.x", "3.5.0", true},
+		{"9", "9.1.0", true},
+		{"9.x", "9.1.0-beta", true},
+		{"3.0.x", "3.1.0", false},
+		{"3.0.1", "3.0.10", false},
+		{"9.x", "10.0.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.constraint+" "+tt.version, func(t *testing.T) {
+			if got := matchVersion(tt.constraint, tt.version); got != tt.want {
+				t.Errorf("matchVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhpcs_standards(t *testing.T) {
+	versions := map[string]map[string]string{
+		"wordpress": {
+			"phpcs": "0.0.1-phpcs",
+		},
+	}
+
+	tests := []struct {
+		name string
+		cs   Phpcs
+		want StandardsManager
+	}{
+		{
+			"Default Standards",
+			Phpcs{PhpcsVersions: versions},
+			StaticStandards(versions),
+		},
+		{
+			"Custom Standards",
+			Phpcs{PhpcsVersions: versions, Standards: testStandards},
+			testStandards,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cs.standards(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Phpcs.standards() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
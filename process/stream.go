@@ -0,0 +1,114 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/wptide/pkg/phpcompat"
+	"github.com/wptide/pkg/process/phpcs"
+	"github.com/wptide/pkg/tide"
+)
+
+// streams determines if the report file is summarized file by file instead of being parsed.
+func (cs Phpcs) streams(file *os.File) bool {
+	if cs.StreamReports <= 0 {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Size() > cs.StreamReports
+}
+
+// streamReport summarizes a report one file at a time, so that only the messages of a single
+// file are in memory. The summary, overview and PHPCompatibility post-processing of the
+// configured transformers are added to the audit of the report, the types of the other
+// transformers are returned as skipped. Sniffs that were not selected, and known false
+// positives, are removed from each file first.
+func (cs Phpcs) streamReport(r io.Reader, report *Report, selection sniffSelection) (skipped []string, err error) {
+	var phpcsSummary *phpcs.SummaryBuilder
+	var overview *phpcs.OverviewBuilder
+	var compatibility *phpcs.CompatibilityBuilder
+	var suppressor *phpcompat.Suppressor
+
+	compat := report.Kind == "phpcs_phpcompatibility"
+	for _, transformer := range cs.transformers() {
+		switch t := transformer.(type) {
+		case SummaryTransformer:
+			phpcsSummary = phpcs.NewSummaryBuilder()
+		case OverviewTransformer:
+			overview = phpcs.NewOverviewBuilder(t.Limit)
+		case CompatibilityTransformer:
+			if compat {
+				compatibility = phpcs.NewCompatibilityBuilder()
+			}
+		case FalsePositiveFilter:
+			if compat {
+				suppressor = &phpcompat.Suppressor{Suppressions: t.Suppressions}
+			}
+		default:
+			skipped = append(skipped, fmt.Sprintf("%T", t))
+		}
+	}
+
+	var suppressed []phpcompat.Suppressed
+	_, err = phpcs.WalkReport(r, func(name string, file phpcs.ReportFile) error {
+		results := file.Results(name)
+		selection.filter(results)
+		if suppressor != nil {
+			s, err := suppressor.Filter(results)
+			if err != nil {
+				return err
+			}
+			suppressed = append(suppressed, s...)
+		}
+		file = phpcs.ReportFile(results.Files[name])
+
+		if phpcsSummary != nil {
+			phpcsSummary.Add(name, file)
+		}
+		if overview != nil {
+			overview.Add(name, file)
+		}
+		if compatibility != nil {
+			compatibility.Add(name, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return skipped, err
+	}
+
+	audit := report.Audit
+	if audit.Extra == nil {
+		audit.Extra = make(map[string]interface{})
+	}
+	audit.Extra["streamed"] = true
+
+	if phpcsSummary != nil {
+		audit.Summary = tide.AuditSummary{PhpcsSummary: phpcsSummary.Summary()}
+	}
+	if overview != nil {
+		audit.Overview = overview.Overview()
+	}
+	if len(suppressed) != 0 {
+		audit.Extra["suppressed"] = suppressed
+	}
+
+	if compatibility != nil {
+		compatibleVersions, incompatibleVersions, details := compatibility.Compatibility()
+
+		resultsJSON, _ := json.Marshal(details)
+
+		parsed, err := report.Upload(report.Checksum+"-"+report.Kind+"-parsed.json", resultsJSON)
+		if err != nil {
+			return skipped, err
+		}
+
+		audit.Parsed = parsed
+		audit.CompatibleVersions = compatibleVersions
+		audit.IncompatibleVersions = incompatibleVersions
+	}
+
+	return skipped, nil
+}
@@ -0,0 +1,125 @@
+package process
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/process/phpcs"
+	"github.com/wptide/pkg/tide"
+)
+
+func TestPhpcs_streamReport(t *testing.T) {
+	upload := func(filename string, data []byte) (tide.AuditDetails, error) {
+		return tide.AuditDetails{FileName: filename}, nil
+	}
+
+	tests := []struct {
+		name    string
+		kind    string
+		report  string
+		options *message.AuditOption
+	}{
+		{"WordPress", "phpcs_wordpress", examplePhpcsWordPressReport(), &message.AuditOption{}},
+		{"PHPCompatibility", "phpcs_phpcompatibility", examplePhpcsPhpCompatibilityReport(), &message.AuditOption{}},
+		{"Excluded Sniffs", "phpcs_wordpress", examplePhpcsWordPressReport(), &message.AuditOption{Exclude: "WordPress.WhiteSpace"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := Phpcs{}
+			selection, _ := newSniffSelection(tt.options)
+
+			// The streamed report has the same summary, overview and compatibility as the parsed report.
+			var results *tide.PhpcsResults
+			if err := json.Unmarshal([]byte(tt.report), &results); err != nil {
+				t.Fatal(err)
+			}
+			selection.filter(results)
+			parsed := &Report{Kind: tt.kind, Checksum: "checksum", Results: results, Audit: &tide.AuditResult{}, upload: upload}
+			if err := transformReport(parsed, cs.transformers()); err != nil {
+				t.Fatalf("transformReport() error = %v", err)
+			}
+
+			streamed := &Report{Kind: tt.kind, Checksum: "checksum", Audit: &tide.AuditResult{}, upload: upload}
+			if skipped, err := cs.streamReport(strings.NewReader(tt.report), streamed, selection); err != nil || len(skipped) != 0 {
+				t.Fatalf("Phpcs.streamReport() = %v, %v", skipped, err)
+			}
+
+			if !reflect.DeepEqual(streamed.Audit.Summary, parsed.Audit.Summary) {
+				t.Errorf("Phpcs.streamReport() summary = %v, want %v", streamed.Audit.Summary.PhpcsSummary, parsed.Audit.Summary.PhpcsSummary)
+			}
+			if !reflect.DeepEqual(streamed.Audit.Overview, parsed.Audit.Overview) {
+				t.Errorf("Phpcs.streamReport() overview = %v, want %v", streamed.Audit.Overview, parsed.Audit.Overview)
+			}
+			if !reflect.DeepEqual(streamed.Audit.CompatibleVersions, parsed.Audit.CompatibleVersions) || streamed.Audit.Parsed != parsed.Audit.Parsed {
+				t.Errorf("Phpcs.streamReport() compatible versions = %v, want %v", streamed.Audit.CompatibleVersions, parsed.Audit.CompatibleVersions)
+			}
+			if streamed.Audit.Extra["streamed"] != true {
+				t.Errorf("Phpcs.streamReport() did not mark the audit as streamed")
+			}
+		})
+	}
+
+	// Transformers that need the whole report are skipped.
+	cs := Phpcs{Transformers: []ReportTransformer{SummaryTransformer{}, SeverityFilter{}, PaginateTransformer{}}}
+	streamed := &Report{Kind: "phpcs_wordpress", Checksum: "checksum", Audit: &tide.AuditResult{}, upload: upload}
+	skipped, err := cs.streamReport(strings.NewReader(examplePhpcsWordPressReport()), streamed, sniffSelection{})
+	if want := []string{"process.SeverityFilter", "process.PaginateTransformer"}; err != nil || !reflect.DeepEqual(skipped, want) {
+		t.Errorf("Phpcs.streamReport() = %v, %v, want %v", skipped, err, want)
+	}
+}
+
+func TestWalkReport(t *testing.T) {
+	tests := []struct {
+		name      string
+		report    string
+		wantFiles int
+		wantErr   bool
+	}{
+		{"Files", `{"totals":{"errors":1,"warnings":0,"fixable":0},"files":{"a.php":{"errors":1,"warnings":0,"messages":[{"message":"m","source":"s","type":"ERROR"}]},"b.php":{"errors":0,"warnings":0,"messages":[]}}}`, 2, false},
+		{"No Files", `{"totals":{"errors":0,"warnings":0},"files":[]}`, 0, false},
+		{"Null Files", `{"totals":{"errors":0,"warnings":0},"files":null}`, 0, false},
+		{"Invalid Files", `{"files":"a.php"}`, 0, true},
+		{"Truncated", `{"totals":{"errors":1,"warnings":0},"files":{"a.php":{"errors":1`, 0, true},
+		{"Not An Object", `[]`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := 0
+			_, err := phpcs.WalkReport(strings.NewReader(tt.report), func(name string, file phpcs.ReportFile) error {
+				files++
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("WalkReport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && files != tt.wantFiles {
+				t.Errorf("WalkReport() walked %v files, want %v", files, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestPhpcs_streams(t *testing.T) {
+	file, err := ioutil.TempFile("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	file.WriteString(examplePhpcsWordPressReport())
+
+	if (Phpcs{}).streams(file) {
+		t.Errorf("Phpcs.streams() = true without a limit")
+	}
+	if !(Phpcs{StreamReports: 100}).streams(file) {
+		t.Errorf("Phpcs.streams() = false for a report over the limit")
+	}
+	if (Phpcs{StreamReports: 1 << 30}).streams(file) {
+		t.Errorf("Phpcs.streams() = true for a report under the limit")
+	}
+}
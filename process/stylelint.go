@@ -0,0 +1,126 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+var (
+	stylelintRunner shell.Runner = defaultRunner
+)
+
+// stylelintFile is a file in the JSON report of Stylelint.
+type stylelintFile struct {
+	Source   string `json:"source"`
+	Warnings []struct {
+		Line     int    `json:"line"`
+		Column   int    `json:"column"`
+		Rule     string `json:"rule"`
+		Severity string `json:"severity"` // "error" or "warning".
+		Text     string `json:"text"`
+	} `json:"warnings"`
+}
+
+// Stylelint defines the structure for our Stylelint process, which audits the CSS assets.
+type Stylelint struct {
+	Process                          // Inherits methods from Process.
+	In              <-chan Processor // Expects a processor channel as input.
+	Out             chan Processor   // Send results to an output channel.
+	TempFolder      string           // Path to a temp folder where reports will be generated.
+	StorageProvider storage.Provider // Storage provider to upload reports to.
+	Config          string           // (Optional) Path of the Stylelint config, e.g. one with the WordPress rules. Defaults to the config of the project.
+	MaxReportSize   int64            // (Optional) Reports larger than this many bytes are uploaded in chunks.
+	Runner          shell.Runner     // (Optional) Runs Stylelint. Defaults to shell.Command.
+	Command         string           // (Optional) Defaults to "stylelint".
+}
+
+// Run executes the process in a pipe.
+func (sl *Stylelint) Run(sink ErrorSink) error {
+	if sl.TempFolder == "" {
+		return errors.New("no temp folder provided for stylelint reports")
+	}
+
+	if sl.StorageProvider == nil {
+		return errors.New("no storage provider for stylelint reports")
+	}
+
+	if sl.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if sl.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	sl.runStage(sink, stage{name: "Stylelint", in: sl.In, out: sl.Out, do: sl.Do})
+
+	return nil
+}
+
+// Do runs Stylelint over the CSS files if the message requests a "stylelint" audit and returns
+// the result with the audit added.
+func (sl *Stylelint) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if !hasAudit(msg, "stylelint") {
+		return res, nil
+	}
+
+	runner := stylelintRunner
+	if sl.Runner != nil {
+		runner = sl.Runner
+	}
+
+	command := sl.Command
+	if command == "" {
+		command = "stylelint"
+	}
+
+	lint := assetLint{
+		kind:            "stylelint",
+		extensions:      []string{".css", ".scss"},
+		runner:          runner,
+		tempFolder:      sl.TempFolder,
+		storageProvider: sl.StorageProvider,
+		maxReportSize:   sl.MaxReportSize,
+		filter:          newPathFilter(auditOptions(msg, "stylelint")),
+		parse:           parseStylelint,
+	}
+
+	return res, lint.run(ctx, msg, res, func(path string) (string, []string) {
+		args := []string{path + "/**/*.{css,scss}", "--formatter", "json", "--ignore-pattern", "**/*.min.css", "--allow-empty-input"}
+		if sl.Config != "" {
+			args = append(args, "--config", sl.Config)
+		}
+		return command, args
+	})
+}
+
+// parseStylelint converts a JSON report of Stylelint into the report format of Tide.
+func parseStylelint(root string, output []byte) (*tide.PhpcsResults, error) {
+	var files []stylelintFile
+	if err := json.Unmarshal(output, &files); err != nil {
+		return nil, err
+	}
+
+	results := &tide.PhpcsResults{}
+	for _, file := range files {
+		name := relativeName(root, file.Source)
+		for _, w := range file.Warnings {
+			addReportMessage(results, name, tide.PhpcsFilesMessage{
+				// Messages end in the rule, e.g. `Unexpected empty block (block-no-empty)`.
+				Message:  strings.TrimSpace(strings.TrimSuffix(w.Text, "("+w.Rule+")")),
+				Source:   w.Rule,
+				Severity: 5,
+				Type:     strings.ToUpper(w.Severity),
+				Line:     w.Line,
+				Column:   w.Column,
+			})
+		}
+	}
+	return results, nil
+}
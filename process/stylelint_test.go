@@ -0,0 +1,102 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+const stylelintReport = `[
+	{"source":"/tmp/abc/unzipped/style.css","errored":true,"warnings":[
+		{"line":4,"column":3,"rule":"block-no-empty","severity":"error","text":"Unexpected empty block (block-no-empty)"},
+		{"line":9,"column":1,"rule":"color-hex-length","severity":"warning","text":"Expected \"#FFFFFF\" to be \"#FFF\" (color-hex-length)"}
+	]}
+]`
+
+func TestStylelint_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "stylelint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	msg := message.Message{Title: "Test", Audits: []*message.Audit{{Type: "stylelint"}}}
+	files := []string{"/tmp/abc/unzipped/style.css"}
+
+	tests := []struct {
+		name    string
+		runner  *outputRunner
+		want    map[string]int // Errors and warnings.
+		wantErr bool
+	}{
+		{
+			"Report On Stdout",
+			&outputRunner{out: stylelintReport, err: errors.New("exit status 2")},
+			map[string]int{"errors": 1, "warnings": 1},
+			false,
+		},
+		{
+			"Report On Stderr",
+			&outputRunner{errOut: stylelintReport, err: errors.New("exit status 2")},
+			map[string]int{"errors": 1, "warnings": 1},
+			false,
+		},
+		{
+			"Command Failed",
+			&outputRunner{errOut: "Error: No configuration provided", err: errors.New("exit status 78")},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sl := &Stylelint{TempFolder: dir, StorageProvider: &recordingStorage{}, Runner: tt.runner, Config: "/etc/stylelint.json"}
+			res := &Result{Checksum: "abc", FilesPath: "/tmp/abc", Files: files}
+
+			res, err := sl.Do(context.Background(), msg, res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Stylelint.Do() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			audit, _ := res.Audit("stylelint")
+			got := map[string]int{"errors": audit.Summary.ErrorsCount, "warnings": audit.Summary.WarningsCount}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stylelint.Do() counts = %v, want %v", got, tt.want)
+			}
+			if tt.runner.args[len(tt.runner.args)-1] != "/etc/stylelint.json" {
+				t.Errorf("Stylelint.Do() args = %v", tt.runner.args)
+			}
+		})
+	}
+}
+
+func Test_parseStylelint(t *testing.T) {
+	got, err := parseStylelint("/tmp/abc/unzipped", []byte(stylelintReport))
+	if err != nil {
+		t.Fatalf("parseStylelint() error = %v", err)
+	}
+
+	want := []tide.PhpcsFilesMessage{
+		{Message: "Unexpected empty block", Source: "block-no-empty", Severity: 5, Type: "ERROR", Line: 4, Column: 3},
+		{Message: `Expected "#FFFFFF" to be "#FFF"`, Source: "color-hex-length", Severity: 5, Type: "WARNING", Line: 9, Column: 1},
+	}
+	if file := got.Files["style.css"]; !reflect.DeepEqual(file.Messages, want) {
+		t.Errorf("parseStylelint() style.css = %+v, want %v", file.Messages, want)
+	}
+}
@@ -0,0 +1 @@
+function app(){return 1}
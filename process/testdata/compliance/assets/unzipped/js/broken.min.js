@@ -0,0 +1,2 @@
+function broken(){return 1}
+//# sourceMappingURL=broken.min.js.map
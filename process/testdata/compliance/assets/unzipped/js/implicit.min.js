@@ -0,0 +1 @@
+function implicit(){return 1}
@@ -0,0 +1 @@
+function lib(){return 1}
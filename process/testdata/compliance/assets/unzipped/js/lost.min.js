@@ -0,0 +1,2 @@
+function lost(){return 1}
+//# sourceMappingURL=lost.min.js.map
@@ -0,0 +1,2 @@
+function mapped(){return 1}
+//# sourceMappingURL=mapped.min.js.map
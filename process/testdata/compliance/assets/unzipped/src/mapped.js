@@ -0,0 +1 @@
+function mapped(){return 1}
@@ -0,0 +1,16 @@
+<?php
+/**
+ * Plugin Name: Compliance Hook Plugin
+ */
+
+register_uninstall_hook( __FILE__, array( 'Chp_Plugin', 'uninstall' ) );
+
+class Chp_Plugin {
+	public static function activate() {
+		add_option( 'chp_settings', array() );
+	}
+
+	public static function uninstall() {
+		delete_option( 'chp_settings' );
+	}
+}
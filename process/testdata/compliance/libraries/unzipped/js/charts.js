@@ -0,0 +1,5 @@
+/*
+ Highcharts JS v9.0.0 (2021-02-02)
+
+ (c) 2009-2021 Torstein Honsi
+*/
@@ -0,0 +1,6 @@
+<?php
+/**
+ * Plugin Name: Compliance None Plugin
+ */
+
+add_option( 'cnp_settings', array() );
@@ -0,0 +1,15 @@
+<?php
+/**
+ * Plugin Name: Compliance Test Plugin
+ */
+
+function ctp_activate() {
+	global $wpdb;
+	add_option( 'ctp_settings', array() );
+	update_option( 'ctp_version', '1.0.0' );
+	update_option( $dynamic, true );
+
+	$table = $wpdb->prefix . 'ctp_items';
+	$sql   = "CREATE TABLE $table ( id int ) {$wpdb->get_charset_collate()};";
+	dbDelta( $sql );
+}
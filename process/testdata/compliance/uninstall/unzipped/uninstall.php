@@ -0,0 +1,5 @@
+<?php
+
+global $wpdb;
+delete_option( 'ctp_settings' );
+$wpdb->query( "DROP TABLE IF EXISTS {$wpdb->prefix}ctp_items" );
@@ -0,0 +1,30 @@
+<?php
+
+function dbtp_install() {
+	global $wpdb;
+
+	$table_name      = $wpdb->prefix . 'dbtp_items';
+	$charset_collate = $wpdb->get_charset_collate();
+
+	$sql = "CREATE TABLE $table_name (
+		id mediumint(9) NOT NULL AUTO_INCREMENT,
+		name varchar(55) DEFAULT '' NOT NULL,
+		price decimal(10,2) NOT NULL,
+		PRIMARY KEY  (id),
+		KEY name (name)
+	) $charset_collate;";
+
+	require_once ABSPATH . 'wp-admin/includes/upgrade.php';
+	dbDelta( $sql );
+
+	$wpdb->query( "CREATE TABLE IF NOT EXISTS dbtp_log (
+		id bigint(20) NOT NULL,
+		message text
+	)" );
+}
+
+function dbtp_items( $id ) {
+	global $wpdb;
+	$wpdb->get_row( $wpdb->prepare( "SELECT * FROM {$wpdb->prefix}dbtp_items WHERE id = %d", $id ) );
+	return $wpdb->get_results( "SELECT * FROM wp_posts" );
+}
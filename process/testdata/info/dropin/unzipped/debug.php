@@ -0,0 +1,2 @@
+<?php
+// Not a dropin, WordPress never loads this file.
@@ -0,0 +1,4 @@
+<?php
+/**
+ * Page cache dropin for testing purposes only.
+ */
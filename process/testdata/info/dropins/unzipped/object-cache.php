@@ -0,0 +1,8 @@
+<?php
+/**
+ * Object cache dropin for testing purposes only.
+ */
+
+function wp_cache_init() {
+	$GLOBALS['wp_object_cache'] = new WP_Object_Cache();
+}
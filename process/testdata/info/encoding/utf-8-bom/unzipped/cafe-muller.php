@@ -0,0 +1,8 @@
+﻿<?php
+/*
+Plugin Name: Café Müller
+Description: Grüße “für” alle
+Version: 1.0
+Author: Renée
+Text Domain: cafe-muller
+*/
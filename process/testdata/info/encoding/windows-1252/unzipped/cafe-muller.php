@@ -0,0 +1,8 @@
+<?php
+/*
+Plugin Name: Caf Mller
+Description: Gre fr alle
+Version: 1.0
+Author: Rene
+Text Domain: cafe-muller
+*/
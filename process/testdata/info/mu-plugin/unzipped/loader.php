@@ -0,0 +1,6 @@
+<?php
+/**
+ * Loads the mu-plugin from its folder, WordPress only loads mu-plugins in the root folder.
+ */
+
+require_once __DIR__ . '/my-plugin/my-plugin.php';
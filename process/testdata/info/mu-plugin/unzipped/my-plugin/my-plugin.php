@@ -0,0 +1,7 @@
+<?php
+/*
+Plugin Name: Dummy MU Plugin
+Description: This does nothing.
+Version: 1.0
+Text Domain: dummy-mu-plugin
+*/
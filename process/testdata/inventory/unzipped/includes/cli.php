@@ -0,0 +1,5 @@
+<?php
+
+if ( defined( 'WP_CLI' ) && WP_CLI ) {
+	WP_CLI::add_command( 'itp', 'Itp_Command' );
+}
@@ -0,0 +1,29 @@
+<?php
+/**
+ * Plugin Name: Inventory Test Plugin
+ */
+
+add_action( 'init', 'itp_init' );
+add_filter( "the_content", 'itp_content' );
+add_action( $dynamic_hook, 'itp_dynamic' );
+
+function itp_init() {
+	add_shortcode( 'itp_gallery', 'itp_gallery' );
+	do_action( 'itp_loaded' );
+}
+
+function itp_content( $content ) {
+	return apply_filters( 'itp_content', $content );
+}
+
+add_action( 'rest_api_init', function () {
+	register_rest_route( 'itp/v1/', '/items', array(
+		'methods'  => 'GET',
+		'callback' => 'itp_items',
+	) );
+} );
+
+if ( ! wp_next_scheduled( 'itp_daily' ) ) {
+	wp_schedule_event( time(), 'daily', 'itp_daily' );
+}
+wp_schedule_single_event( time() + 60, 'itp_once' );
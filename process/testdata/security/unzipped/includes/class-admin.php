@@ -0,0 +1,18 @@
+<?php
+
+class Stp_Admin {
+	public function delete_item() {
+		global $wpdb;
+		$wpdb->query( "DELETE FROM {$wpdb->prefix}items WHERE id = " . $_GET['id'] );
+	}
+
+	public static function update_item() {
+		global $wpdb;
+		if ( ! current_user_can( 'manage_options' ) ) {
+			wp_die();
+		}
+		$wpdb->query( $wpdb->prepare( "UPDATE {$wpdb->prefix}items SET name = %s", $_POST['name'] ) );
+		$id = absint( $_REQUEST['id'] );
+		$wpdb->get_row( "SELECT * FROM {$wpdb->prefix}items WHERE id = " . absint( $_REQUEST['id'] ) );
+	}
+}
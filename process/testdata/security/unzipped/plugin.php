@@ -0,0 +1,29 @@
+<?php
+/**
+ * Plugin Name: Security Test Plugin
+ */
+
+add_action( 'admin_post_save_settings', 'stp_save_settings' );
+add_action( 'admin_post_safe_settings', 'stp_safe_settings' );
+add_action( 'wp_ajax_delete_item', array( $this, 'delete_item' ) );
+add_action( 'wp_ajax_nopriv_public_item', 'stp_public_item' );
+add_action( 'wp_ajax_update_item', 'Stp_Admin::update_item' );
+add_action( 'admin_post_closure', function () {
+	update_option( 'stp_closure', $_POST['value'] );
+} );
+add_action( 'wp_ajax_missing', 'stp_missing_handler' );
+
+function stp_save_settings() {
+	update_option( 'stp_settings', $_POST['settings'] );
+}
+
+function stp_safe_settings() {
+	check_admin_referer( 'stp_safe_settings' );
+	if ( ! empty( $_POST['settings'] ) ) {
+		update_option( 'stp_settings', $_POST['settings'] );
+	}
+}
+
+function stp_public_item() {
+	echo 'public';
+}
@@ -0,0 +1,60 @@
+package process
+
+import (
+	"sort"
+	"time"
+)
+
+// TimingSummary describes the durations of a stage across results.
+type TimingSummary struct {
+	Count int           `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// SummarizeTimings returns the average and percentile durations of each stage in the results,
+// e.g. to compare stored results from before and after a fleet upgrade.
+func SummarizeTimings(results []*Result) map[string]TimingSummary {
+	durations := make(map[string][]time.Duration)
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		for stage, d := range res.Timings {
+			durations[stage] = append(durations[stage], d)
+		}
+	}
+
+	summaries := make(map[string]TimingSummary)
+	for stage, ds := range durations {
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+
+		var total time.Duration
+		for _, d := range ds {
+			total += d
+		}
+
+		summaries[stage] = TimingSummary{
+			Count: len(ds),
+			Mean:  total / time.Duration(len(ds)),
+			P50:   percentile(ds, 50),
+			P90:   percentile(ds, 90),
+			P99:   percentile(ds, 99),
+			Max:   ds[len(ds)-1],
+		}
+	}
+
+	return summaries
+}
+
+// percentile returns the nearest-rank percentile of sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,78 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarizeTimings(t *testing.T) {
+	var results []*Result
+	for i := 1; i <= 10; i++ {
+		res := NewResult()
+		res.AddTiming("phpcs_wordpress", time.Duration(i)*time.Second)
+		results = append(results, res)
+	}
+
+	upload := NewResult()
+	upload.AddTiming("upload", time.Second)
+	upload.AddTiming("upload", 2*time.Second)
+	results = append(results, upload, nil)
+
+	want := map[string]TimingSummary{
+		"phpcs_wordpress": {
+			Count: 10,
+			Mean:  5500 * time.Millisecond,
+			P50:   5 * time.Second,
+			P90:   9 * time.Second,
+			P99:   10 * time.Second,
+			Max:   10 * time.Second,
+		},
+		"upload": {
+			Count: 1,
+			Mean:  3 * time.Second,
+			P50:   3 * time.Second,
+			P90:   3 * time.Second,
+			P99:   3 * time.Second,
+			Max:   3 * time.Second,
+		},
+	}
+
+	if got := SummarizeTimings(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("SummarizeTimings() = %v, want %v", got, want)
+	}
+
+	if got := SummarizeTimings(nil); len(got) != 0 {
+		t.Errorf("SummarizeTimings() = %v, want no stages", got)
+	}
+}
+
+func TestProcess_output_Timing(t *testing.T) {
+	current := time.Unix(1500000000, 0)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	p := &Process{Result: NewResult()}
+
+	_, _, res := p.input()
+	current = current.Add(3 * time.Second)
+	done := res.timeStage("upload")
+	current = current.Add(time.Second)
+	done()
+	p.output("phpcs", res)
+
+	want := map[string]time.Duration{
+		"upload": time.Second,
+		"phpcs":  4 * time.Second,
+	}
+	if !reflect.DeepEqual(p.Result.Timings, want) {
+		t.Errorf("Process.output() timings = %v, want %v", p.Result.Timings, want)
+	}
+
+	// Processes that have not started timing don't record a stage.
+	other := &Process{}
+	other.output("info", NewResult())
+	if other.Result.Timings != nil {
+		t.Errorf("Process.output() timings = %v, want none", other.Result.Timings)
+	}
+}
@@ -0,0 +1,257 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/phpcompat"
+	"github.com/wptide/pkg/process/phpcs"
+	"github.com/wptide/pkg/report/paged"
+	"github.com/wptide/pkg/tide"
+)
+
+// defaultSeverity is the severity PHPCS assigns to messages when none is configured.
+const defaultSeverity = 5
+
+// Report is a parsed PHPCS report passed through the ReportTransformer chain.
+type Report struct {
+	Kind     string               // Audit kind, e.g. "phpcs_wordpress".
+	Checksum string               // Checksum of the audited project.
+	Options  *message.AuditOption // Options of the audit that produced the report.
+	Locale   string               // Locale requested by the message, e.g. "de_DE".
+	Results  *tide.PhpcsResults   // The parsed report. Transformers may modify it.
+	Audit    *tide.AuditResult    // The audit result that will be added to the process result.
+
+	// upload stores additional files created by transformers.
+	upload func(filename string, data []byte) (tide.AuditDetails, error)
+}
+
+// Upload stores data as filename in the storage provider of the process.
+func (r *Report) Upload(filename string, data []byte) (tide.AuditDetails, error) {
+	if r.upload == nil {
+		return tide.AuditDetails{}, errors.New("no storage for report files")
+	}
+	return r.upload(filename, data)
+}
+
+// ReportTransformer post-processes a PHPCS report before it is added to the result.
+type ReportTransformer interface {
+	Transform(report *Report) error
+}
+
+// ReportTransformerFunc is an adapter to use ordinary functions as a ReportTransformer.
+type ReportTransformerFunc func(report *Report) error
+
+// Transform calls f(report).
+func (f ReportTransformerFunc) Transform(report *Report) error {
+	return f(report)
+}
+
+// DefaultReportTransformers returns the transformers used when a Phpcs process has none configured.
+func DefaultReportTransformers() []ReportTransformer {
+	return []ReportTransformer{
+		FalsePositiveFilter{},
+		SummaryTransformer{},
+		OverviewTransformer{},
+		CompatibilityTransformer{},
+	}
+}
+
+// SummaryTransformer adds a summary of the report to the audit result.
+type SummaryTransformer struct{}
+
+// Transform implements ReportTransformer.
+func (SummaryTransformer) Transform(report *Report) error {
+	report.Audit.Summary = tide.AuditSummary{PhpcsSummary: phpcs.GetPhpcsSummary(*report.Results)}
+	return nil
+}
+
+// OverviewTransformer adds the top sniff sources, the top files by errors, the totals of the
+// messages and the percentage of fixable messages to the audit result, so that overviews can be
+// shown without the raw report.
+type OverviewTransformer struct {
+	Limit int // (Optional) Number of sources and files to list. Defaults to phpcs.DefaultOverviewLimit.
+}
+
+// Transform implements ReportTransformer.
+func (o OverviewTransformer) Transform(report *Report) error {
+	report.Audit.Overview = phpcs.GetPhpcsOverview(*report.Results, o.Limit)
+	return nil
+}
+
+// CompatibilityTransformer adds the compatible PHP versions to PHPCompatibility audit results
+// and uploads the parsed compatibility report.
+type CompatibilityTransformer struct{}
+
+// Transform implements ReportTransformer.
+func (CompatibilityTransformer) Transform(report *Report) error {
+	// Only PHPCompatibility provides parsed results.
+	if report.Kind != "phpcs_phpcompatibility" {
+		return nil
+	}
+
+	compatibleVersions, incompatibleVersions, compatResults := phpcs.GetPhpcsCompatibility(*report.Results)
+
+	resultsJSON, _ := json.Marshal(compatResults)
+
+	parsed, err := report.Upload(report.Checksum+"-"+report.Kind+"-parsed.json", resultsJSON)
+	if err != nil {
+		return err
+	}
+
+	report.Audit.Parsed = parsed
+	report.Audit.CompatibleVersions = compatibleVersions
+	report.Audit.IncompatibleVersions = incompatibleVersions
+
+	return nil
+}
+
+// FalsePositiveFilter removes the known false positives from PHPCompatibility reports, e.g.
+// PHP 8 attributes and enums reported as breaking PHP 8.1, before the compatible versions are
+// determined. The removed messages are added to the "suppressed" entry of the audit result's
+// Extra field.
+type FalsePositiveFilter struct {
+	Suppressions []phpcompat.Suppression // (Optional) Defaults to phpcompat.DefaultSuppressions.
+}
+
+// Transform implements ReportTransformer.
+func (f FalsePositiveFilter) Transform(report *Report) error {
+	if report.Kind != "phpcs_phpcompatibility" {
+		return nil
+	}
+
+	suppressed, err := (phpcompat.Suppressor{Suppressions: f.Suppressions}).Filter(report.Results)
+	if err != nil || len(suppressed) == 0 {
+		return err
+	}
+
+	if report.Audit.Extra == nil {
+		report.Audit.Extra = make(map[string]interface{})
+	}
+	report.Audit.Extra["suppressed"] = suppressed
+
+	return nil
+}
+
+// SeverityFilter removes messages below a minimum severity and updates the report totals.
+// Messages without a severity are treated as having the PHPCS default of 5.
+type SeverityFilter struct {
+	MinSeverity int
+}
+
+// Transform implements ReportTransformer.
+func (s SeverityFilter) Transform(report *Report) error {
+	results := report.Results
+
+	for name, file := range results.Files {
+		var kept []tide.PhpcsFilesMessage
+		for _, msg := range file.Messages {
+			severity := msg.Severity
+			if severity == 0 {
+				severity = defaultSeverity
+			}
+			if severity < s.MinSeverity {
+				continue
+			}
+			kept = append(kept, msg)
+		}
+
+		file.Messages = kept
+		results.Files[name] = file
+	}
+
+	countMessages(results)
+
+	return nil
+}
+
+// countMessages updates the file and report totals from the messages of each file.
+func countMessages(results *tide.PhpcsResults) {
+	results.Totals.Errors = 0
+	results.Totals.Warnings = 0
+
+	for name, file := range results.Files {
+		file.Errors = 0
+		file.Warnings = 0
+
+		for _, msg := range file.Messages {
+			switch strings.ToUpper(msg.Type) {
+			case "ERROR":
+				file.Errors++
+			case "WARNING":
+				file.Warnings++
+			}
+		}
+
+		results.Files[name] = file
+		results.Totals.Errors += file.Errors
+		results.Totals.Warnings += file.Warnings
+	}
+}
+
+// DocLinkEnricher adds documentation links for the sniffs found in the report.
+// The links are added to the "docs" entry of the audit result's Extra field.
+type DocLinkEnricher struct {
+	// URLs maps a standard to the base URL of its documentation.
+	// The sniff code, e.g. "WordPress.Security.EscapeOutput", is appended to the base URL.
+	URLs map[string]string
+}
+
+// Transform implements ReportTransformer.
+func (d DocLinkEnricher) Transform(report *Report) error {
+	docs := make(map[string]string)
+
+	for _, file := range report.Results.Files {
+		for _, msg := range file.Messages {
+			parts := strings.Split(msg.Source, ".")
+			base, ok := d.URLs[parts[0]]
+			if !ok || len(parts) < 3 {
+				continue
+			}
+
+			// Drop the error code from the source to link to the sniff.
+			sniff := strings.Join(parts[:3], ".")
+			docs[msg.Source] = base + sniff
+		}
+	}
+
+	if len(docs) == 0 {
+		return nil
+	}
+
+	if report.Audit.Extra == nil {
+		report.Audit.Extra = make(map[string]interface{})
+	}
+	report.Audit.Extra["docs"] = docs
+
+	return nil
+}
+
+// PaginateTransformer uploads the report as a summary, one object per file and an index,
+// so that API consumers can fetch the messages of a single file. It is not one of the
+// DefaultReportTransformers because it uploads an object for every file with messages.
+type PaginateTransformer struct{}
+
+// Transform implements ReportTransformer.
+func (PaginateTransformer) Transform(report *Report) error {
+	index, err := paged.Upload(report.Upload, report.Checksum+"-"+report.Kind, *report.Results)
+	if err != nil {
+		return err
+	}
+
+	report.Audit.Index = &index
+
+	return nil
+}
+
+// transformReport runs the report through each transformer in order, stopping at the first error.
+func transformReport(report *Report, transformers []ReportTransformer) error {
+	for _, t := range transformers {
+		if err := t.Transform(report); err != nil {
+			return err
+		}
+	}
+	return nil
+}
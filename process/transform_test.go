@@ -0,0 +1,346 @@
+package process
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/phpcompat"
+	"github.com/wptide/pkg/tide"
+)
+
+func testPhpcsResults() *tide.PhpcsResults {
+	results := &tide.PhpcsResults{}
+	results.Totals.Errors = 2
+	results.Totals.Warnings = 1
+	results.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{
+		"plugin.php": {
+			Errors:   2,
+			Warnings: 1,
+			Messages: []tide.PhpcsFilesMessage{
+				{Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "ERROR", Severity: 5},
+				{Source: "WordPress.WP.I18n.MissingTranslatorsComment", Type: "WARNING", Severity: 3},
+				{Source: "Generic.PHP.Syntax.PHPSyntax", Type: "ERROR"},
+			},
+		},
+	}
+	return results
+}
+
+func TestSummaryTransformer_Transform(t *testing.T) {
+	report := &Report{Results: testPhpcsResults(), Audit: &tide.AuditResult{}}
+
+	if err := (SummaryTransformer{}).Transform(report); err != nil {
+		t.Fatalf("SummaryTransformer.Transform() error = %v", err)
+	}
+
+	summary := report.Audit.Summary.PhpcsSummary
+	if summary == nil || summary.ErrorsCount != 2 || summary.WarningsCount != 1 || summary.FilesCount != 1 {
+		t.Errorf("SummaryTransformer.Transform() summary = %v", summary)
+	}
+}
+
+func TestOverviewTransformer_Transform(t *testing.T) {
+	results := testPhpcsResults()
+	results.Files["other.php"] = struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{
+		Errors:   3,
+		Warnings: 1,
+		Messages: []tide.PhpcsFilesMessage{
+			{Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "ERROR", Fixable: true},
+			{Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "ERROR"},
+			{Source: "Generic.PHP.Syntax.PHPSyntax", Type: "ERROR"},
+			{Source: "Squiz.PHP.CommentedOutCode.Found", Type: "WARNING", Fixable: true},
+		},
+	}
+	results.Files["clean.php"] = struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  *tide.PhpcsOverview
+	}{
+		{
+			"Default Limit",
+			0,
+			&tide.PhpcsOverview{
+				Sources: []tide.PhpcsCount{
+					{Name: "WordPress.Security.EscapeOutput.OutputNotEscaped", Count: 3, Errors: 3, Fixable: 1},
+					{Name: "Generic.PHP.Syntax.PHPSyntax", Count: 2, Errors: 2},
+					{Name: "Squiz.PHP.CommentedOutCode.Found", Count: 1, Warnings: 1, Fixable: 1},
+					{Name: "WordPress.WP.I18n.MissingTranslatorsComment", Count: 1, Warnings: 1},
+				},
+				Files: []tide.PhpcsCount{
+					{Name: "other.php", Count: 3, Errors: 3, Warnings: 1, Fixable: 2},
+					{Name: "plugin.php", Count: 2, Errors: 2, Warnings: 1},
+				},
+				FixablePercent: 28.6,
+				FilesCount:     3,
+				Errors:         5,
+				Warnings:       2,
+				Fixable:        2,
+			},
+		},
+		{
+			"Limit",
+			1,
+			&tide.PhpcsOverview{
+				Sources:        []tide.PhpcsCount{{Name: "WordPress.Security.EscapeOutput.OutputNotEscaped", Count: 3, Errors: 3, Fixable: 1}},
+				Files:          []tide.PhpcsCount{{Name: "other.php", Count: 3, Errors: 3, Warnings: 1, Fixable: 2}},
+				FixablePercent: 28.6,
+				FilesCount:     3,
+				Errors:         5,
+				Warnings:       2,
+				Fixable:        2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &Report{Results: results, Audit: &tide.AuditResult{}}
+
+			if err := (OverviewTransformer{Limit: tt.limit}).Transform(report); err != nil {
+				t.Fatalf("OverviewTransformer.Transform() error = %v", err)
+			}
+			if !reflect.DeepEqual(report.Audit.Overview, tt.want) {
+				t.Errorf("OverviewTransformer.Transform() overview = %v, want %v", report.Audit.Overview, tt.want)
+			}
+		})
+	}
+
+	report := &Report{Results: &tide.PhpcsResults{}, Audit: &tide.AuditResult{}}
+	(OverviewTransformer{}).Transform(report)
+	want := &tide.PhpcsOverview{Sources: []tide.PhpcsCount{}, Files: []tide.PhpcsCount{}}
+	if !reflect.DeepEqual(report.Audit.Overview, want) {
+		t.Errorf("OverviewTransformer.Transform() overview = %v, want %v", report.Audit.Overview, want)
+	}
+}
+
+func TestCompatibilityTransformer_Transform(t *testing.T) {
+	var uploaded string
+	upload := func(filename string, data []byte) (tide.AuditDetails, error) {
+		if filename == "error-phpcs_phpcompatibility-parsed.json" {
+			return tide.AuditDetails{}, errors.New("upload error")
+		}
+		uploaded = filename
+		return tide.AuditDetails{Type: "mock", FileName: filename}, nil
+	}
+
+	tests := []struct {
+		name     string
+		report   *Report
+		wantFile string
+		wantErr  bool
+	}{
+		{
+			"Other Standard",
+			&Report{Kind: "phpcs_wordpress", Checksum: "abc", Results: testPhpcsResults(), Audit: &tide.AuditResult{}, upload: upload},
+			"",
+			false,
+		},
+		{
+			"PHPCompatibility",
+			&Report{Kind: "phpcs_phpcompatibility", Checksum: "abc", Results: testPhpcsResults(), Audit: &tide.AuditResult{}, upload: upload},
+			"abc-phpcs_phpcompatibility-parsed.json",
+			false,
+		},
+		{
+			"Upload Error",
+			&Report{Kind: "phpcs_phpcompatibility", Checksum: "error", Results: testPhpcsResults(), Audit: &tide.AuditResult{}, upload: upload},
+			"",
+			true,
+		},
+		{
+			"No Storage",
+			&Report{Kind: "phpcs_phpcompatibility", Checksum: "abc", Results: testPhpcsResults(), Audit: &tide.AuditResult{}},
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploaded = ""
+			err := (CompatibilityTransformer{}).Transform(tt.report)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompatibilityTransformer.Transform() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if uploaded != tt.wantFile {
+				t.Errorf("CompatibilityTransformer.Transform() uploaded = %v, want %v", uploaded, tt.wantFile)
+			}
+			if tt.wantFile != "" && tt.report.Audit.Parsed.FileName != tt.wantFile {
+				t.Errorf("CompatibilityTransformer.Transform() parsed = %v", tt.report.Audit.Parsed)
+			}
+		})
+	}
+}
+
+func TestFalsePositiveFilter_Transform(t *testing.T) {
+	results := func() *tide.PhpcsResults {
+		results := testPhpcsResults()
+		file := results.Files["plugin.php"]
+		file.Messages[0] = tide.PhpcsFilesMessage{Source: "PHPCompatibility.Keywords.ForbiddenNames.enumFound", Message: "'enum' is a reserved keyword as of PHP version 8.1", Type: "ERROR"}
+		results.Files["plugin.php"] = file
+		return results
+	}
+	suppressions := []phpcompat.Suppression{{Name: "enum", Source: "PHPCompatibility.Keywords.ForbiddenNames", Message: "'enum'"}}
+
+	tests := []struct {
+		name           string
+		kind           string
+		wantErrors     int
+		wantSuppressed int
+	}{
+		{"Other Standard", "phpcs_wordpress", 2, 0},
+		{"PHPCompatibility", "phpcs_phpcompatibility", 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &Report{Kind: tt.kind, Results: results(), Audit: &tide.AuditResult{}}
+
+			if err := (FalsePositiveFilter{Suppressions: suppressions}).Transform(report); err != nil {
+				t.Fatalf("FalsePositiveFilter.Transform() error = %v", err)
+			}
+
+			if report.Results.Totals.Errors != tt.wantErrors {
+				t.Errorf("FalsePositiveFilter.Transform() errors = %v, want %v", report.Results.Totals.Errors, tt.wantErrors)
+			}
+			suppressed, _ := report.Audit.Extra["suppressed"].([]phpcompat.Suppressed)
+			if len(suppressed) != tt.wantSuppressed {
+				t.Errorf("FalsePositiveFilter.Transform() suppressed = %v", report.Audit.Extra)
+			}
+		})
+	}
+}
+
+func TestSeverityFilter_Transform(t *testing.T) {
+	tests := []struct {
+		name         string
+		minSeverity  int
+		wantErrors   int
+		wantWarnings int
+		wantMessages int
+	}{
+		{"Keep All", 0, 2, 1, 3},
+		{"Default Severity", 5, 2, 0, 2},
+		{"Above Default", 6, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := &Report{Results: testPhpcsResults(), Audit: &tide.AuditResult{}}
+
+			if err := (SeverityFilter{MinSeverity: tt.minSeverity}).Transform(report); err != nil {
+				t.Fatalf("SeverityFilter.Transform() error = %v", err)
+			}
+
+			file := report.Results.Files["plugin.php"]
+			if len(file.Messages) != tt.wantMessages || file.Errors != tt.wantErrors || file.Warnings != tt.wantWarnings {
+				t.Errorf("SeverityFilter.Transform() file = %v", file)
+			}
+			if report.Results.Totals.Errors != tt.wantErrors || report.Results.Totals.Warnings != tt.wantWarnings {
+				t.Errorf("SeverityFilter.Transform() totals = %v", report.Results.Totals)
+			}
+		})
+	}
+}
+
+func TestDocLinkEnricher_Transform(t *testing.T) {
+	d := DocLinkEnricher{URLs: map[string]string{
+		"WordPress": "https://docs.example.com/",
+	}}
+
+	report := &Report{Results: testPhpcsResults(), Audit: &tide.AuditResult{}}
+	if err := d.Transform(report); err != nil {
+		t.Fatalf("DocLinkEnricher.Transform() error = %v", err)
+	}
+
+	want := map[string]string{
+		"WordPress.Security.EscapeOutput.OutputNotEscaped": "https://docs.example.com/WordPress.Security.EscapeOutput",
+		"WordPress.WP.I18n.MissingTranslatorsComment":      "https://docs.example.com/WordPress.WP.I18n",
+	}
+	if got := report.Audit.Extra["docs"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("DocLinkEnricher.Transform() docs = %v, want %v", got, want)
+	}
+
+	// Nothing is added when no standards match.
+	report = &Report{Results: testPhpcsResults(), Audit: &tide.AuditResult{}}
+	if err := (DocLinkEnricher{}).Transform(report); err != nil || report.Audit.Extra != nil {
+		t.Errorf("DocLinkEnricher.Transform() extra = %v, error = %v", report.Audit.Extra, err)
+	}
+}
+
+func TestPaginateTransformer_Transform(t *testing.T) {
+	var uploaded []string
+	upload := func(filename string, data []byte) (tide.AuditDetails, error) {
+		uploaded = append(uploaded, filename)
+		return tide.AuditDetails{Type: "mock", FileName: filename}, nil
+	}
+
+	report := &Report{Kind: "phpcs_wordpress", Checksum: "abc", Results: testPhpcsResults(), Audit: &tide.AuditResult{}, upload: upload}
+	if err := (PaginateTransformer{}).Transform(report); err != nil {
+		t.Fatalf("PaginateTransformer.Transform() error = %v", err)
+	}
+
+	want := &tide.AuditDetails{Type: "mock", FileName: "abc-phpcs_wordpress-index.json"}
+	if !reflect.DeepEqual(report.Audit.Index, want) {
+		t.Errorf("PaginateTransformer.Transform() index = %v, want %v", report.Audit.Index, want)
+	}
+	if len(uploaded) != 3 {
+		t.Errorf("PaginateTransformer.Transform() uploaded = %v", uploaded)
+	}
+
+	// Reports can't be paginated without storage.
+	report = &Report{Kind: "phpcs_wordpress", Checksum: "abc", Results: testPhpcsResults(), Audit: &tide.AuditResult{}}
+	if err := (PaginateTransformer{}).Transform(report); err == nil || report.Audit.Index != nil {
+		t.Errorf("PaginateTransformer.Transform() index = %v, error = %v", report.Audit.Index, err)
+	}
+}
+
+func Test_transformReport(t *testing.T) {
+	var order []string
+	record := func(name string, err error) ReportTransformer {
+		return ReportTransformerFunc(func(report *Report) error {
+			order = append(order, name)
+			return err
+		})
+	}
+
+	report := &Report{Results: testPhpcsResults(), Audit: &tide.AuditResult{}}
+
+	if err := transformReport(report, []ReportTransformer{record("a", nil), record("b", nil)}); err != nil {
+		t.Errorf("transformReport() error = %v", err)
+	}
+	if !reflect.DeepEqual(order, []string{"a", "b"}) {
+		t.Errorf("transformReport() order = %v", order)
+	}
+
+	order = nil
+	if err := transformReport(report, []ReportTransformer{record("a", errors.New("failed")), record("b", nil)}); err == nil {
+		t.Errorf("transformReport() expected an error")
+	}
+	if !reflect.DeepEqual(order, []string{"a"}) {
+		t.Errorf("transformReport() order = %v, want the chain to stop at the error", order)
+	}
+}
+
+func TestPhpcs_transformers(t *testing.T) {
+	if got := (Phpcs{}).transformers(); !reflect.DeepEqual(got, DefaultReportTransformers()) {
+		t.Errorf("Phpcs.transformers() = %v, want the defaults", got)
+	}
+
+	custom := []ReportTransformer{SeverityFilter{MinSeverity: 5}, SummaryTransformer{}}
+	if got := (Phpcs{Transformers: custom}).transformers(); !reflect.DeepEqual(got, custom) {
+		t.Errorf("Phpcs.transformers() = %v, want %v", got, custom)
+	}
+}
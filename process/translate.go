@@ -0,0 +1,91 @@
+package process
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// Translator localizes the messages reported by PHPCS sniffs.
+type Translator interface {
+	// Translate returns the message of the sniff source, e.g. "WordPress.Security.EscapeOutput.OutputNotEscaped",
+	// in the locale, e.g. "de_DE". The second value is false if there is no translation.
+	Translate(locale, source, message string) (string, bool)
+}
+
+// TranslatorFunc is an adapter to use ordinary functions as a Translator.
+type TranslatorFunc func(locale, source, message string) (string, bool)
+
+// Translate calls f(locale, source, message).
+func (f TranslatorFunc) Translate(locale, source, message string) (string, bool) {
+	return f(locale, source, message)
+}
+
+// Catalog is a Translator with a fixed message for each sniff source, keyed by locale and then source.
+// A locale without a region, e.g. "de", is used when there is no catalog for the full locale.
+// The placeholder "{message}" is replaced with the original message.
+type Catalog map[string]map[string]string
+
+// Translate implements Translator.
+func (c Catalog) Translate(locale, source, message string) (string, bool) {
+	for _, l := range []string{locale, strings.SplitN(locale, "_", 2)[0]} {
+		if translated, ok := c[l][source]; ok {
+			return strings.Replace(translated, "{message}", message, -1), true
+		}
+	}
+	return "", false
+}
+
+// TranslationTransformer uploads a copy of the report with the messages translated into the
+// locale requested by the message, the report itself is not changed. The details of the upload
+// are added to the "translated" entry of the audit result's Extra field.
+type TranslationTransformer struct {
+	Translator Translator
+}
+
+// Transform implements ReportTransformer.
+func (t TranslationTransformer) Transform(report *Report) error {
+	if report.Locale == "" || t.Translator == nil {
+		return nil
+	}
+
+	// The other transformers and uploads keep the original messages.
+	results := *report.Results
+	results.Files = make(map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}, len(report.Results.Files))
+
+	translated := 0
+	for name, file := range report.Results.Files {
+		file.Messages = append([]tide.PhpcsFilesMessage(nil), file.Messages...)
+		for i, msg := range file.Messages {
+			if text, ok := t.Translator.Translate(report.Locale, msg.Source, msg.Message); ok {
+				file.Messages[i].Message = text
+				translated++
+			}
+		}
+		results.Files[name] = file
+	}
+
+	if translated == 0 {
+		return nil
+	}
+
+	resultsJSON, _ := json.Marshal(results)
+
+	details, err := report.Upload(report.Checksum+"-"+report.Kind+"-"+report.Locale+".json", resultsJSON)
+	if err != nil {
+		return err
+	}
+
+	if report.Audit.Extra == nil {
+		report.Audit.Extra = make(map[string]interface{})
+	}
+	report.Audit.Extra["locale"] = report.Locale
+	report.Audit.Extra["translated"] = details
+
+	return nil
+}
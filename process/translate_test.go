@@ -0,0 +1,125 @@
+package process
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+var testCatalog = Catalog{
+	"de": {
+		"WordPress.Security.EscapeOutput.OutputNotEscaped": "Alle Ausgaben sollten maskiert werden.",
+	},
+	"de_CH": {
+		"Generic.PHP.Syntax.PHPSyntax": "PHP-Syntaxfehler: {message}",
+	},
+}
+
+func TestCatalog_Translate(t *testing.T) {
+	tests := []struct {
+		name    string
+		locale  string
+		source  string
+		want    string
+		wantOk  bool
+		message string
+	}{
+		{"Language", "de_DE", "WordPress.Security.EscapeOutput.OutputNotEscaped", "Alle Ausgaben sollten maskiert werden.", true, "All output should be escaped."},
+		{"Fallback To Language", "de_CH", "WordPress.Security.EscapeOutput.OutputNotEscaped", "Alle Ausgaben sollten maskiert werden.", true, "All output should be escaped."},
+		{"Placeholder", "de_CH", "Generic.PHP.Syntax.PHPSyntax", "PHP-Syntaxfehler: unexpected '}'", true, "unexpected '}'"},
+		{"Unknown Source", "de_DE", "Generic.PHP.Syntax.PHPSyntax", "", false, "unexpected '}'"},
+		{"Unknown Locale", "fr_FR", "WordPress.Security.EscapeOutput.OutputNotEscaped", "", false, "All output should be escaped."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := testCatalog.Translate(tt.locale, tt.source, tt.message)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Catalog.Translate() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTranslationTransformer_Transform(t *testing.T) {
+	var uploaded map[string][]byte
+	upload := func(filename string, data []byte) (tide.AuditDetails, error) {
+		if filename == "error-phpcs_wordpress-de_DE.json" {
+			return tide.AuditDetails{}, errors.New("upload error")
+		}
+		uploaded[filename] = data
+		return tide.AuditDetails{Type: "mock", FileName: filename}, nil
+	}
+
+	tests := []struct {
+		name       string
+		checksum   string
+		locale     string
+		translator Translator
+		wantFile   string
+		wantErr    bool
+	}{
+		{"Translated", "checksum", "de_DE", testCatalog, "checksum-phpcs_wordpress-de_DE.json", false},
+		{"No Locale", "checksum", "", testCatalog, "", false},
+		{"No Translator", "checksum", "de_DE", nil, "", false},
+		{"No Translations", "checksum", "fr_FR", testCatalog, "", false},
+		{"Upload Error", "error", "de_DE", testCatalog, "", true},
+		{
+			"Translator Func",
+			"checksum",
+			"de_DE",
+			TranslatorFunc(func(locale, source, message string) (string, bool) {
+				return "Alle Ausgaben sollten maskiert werden.", source == "WordPress.Security.EscapeOutput.OutputNotEscaped"
+			}),
+			"checksum-phpcs_wordpress-de_DE.json",
+			false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploaded = make(map[string][]byte)
+			report := &Report{
+				Kind:     "phpcs_wordpress",
+				Checksum: tt.checksum,
+				Locale:   tt.locale,
+				Results:  testPhpcsResults(),
+				Audit:    &tide.AuditResult{},
+				upload:   upload,
+			}
+
+			err := TranslationTransformer{Translator: tt.translator}.Transform(report)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("TranslationTransformer.Transform() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			data, ok := uploaded[tt.wantFile]
+			if tt.wantFile == "" {
+				if len(uploaded) != 0 || report.Audit.Extra != nil {
+					t.Errorf("TranslationTransformer.Transform() uploaded %v", uploaded)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("TranslationTransformer.Transform() did not upload %v", tt.wantFile)
+			}
+
+			var results tide.PhpcsResults
+			json.Unmarshal(data, &results)
+			if got := results.Files["plugin.php"].Messages[0].Message; got != "Alle Ausgaben sollten maskiert werden." {
+				t.Errorf("TranslationTransformer.Transform() message = %v", got)
+			}
+			if got := results.Files["plugin.php"].Messages[1].Message; got != "" {
+				t.Errorf("TranslationTransformer.Transform() translated an unknown source: %v", got)
+			}
+			if got, want := report.Results.Files["plugin.php"].Messages, testPhpcsResults().Files["plugin.php"].Messages; !reflect.DeepEqual(got, want) {
+				t.Errorf("TranslationTransformer.Transform() changed the report messages to %v, want %v", got, want)
+			}
+			if report.Audit.Extra["locale"] != tt.locale || report.Audit.Extra["translated"] != (tide.AuditDetails{Type: "mock", FileName: tt.wantFile}) {
+				t.Errorf("TranslationTransformer.Transform() extra = %v", report.Audit.Extra)
+			}
+		})
+	}
+}
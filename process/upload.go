@@ -0,0 +1,137 @@
+package process
+
+import (
+	"context"
+	"os"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+// uploadReport uploads a report file to storage and returns the details needed to reference it.
+// Reports are gzipped first if compress is set, see storage.GzipFile. Reports that are still
+// larger than maxSize are uploaded in chunks of maxSize bytes and the details reference the chunk
+// manifest instead. A maxSize of 0 disables chunking. Uploads are aborted when the context is
+// done.
+func uploadReport(ctx context.Context, provider storage.Provider, filepath, filename string, maxSize int64, compress bool) (tide.AuditDetails, error) {
+	path, encoding, err := encodeReport(ctx, filepath, compress)
+	if err != nil {
+		return tide.AuditDetails{}, err
+	}
+	if path != filepath {
+		defer os.Remove(path)
+	}
+
+	return uploadEncodedReport(ctx, provider, path, filename, encoding, maxSize)
+}
+
+// uploadAddressedReport uploads a content-addressed report, i.e. one whose reference identifies
+// its content, unless the provider already has it. It returns whether the report of an earlier
+// upload was reused. Reports are uploaded if the provider can't check whether it has them, see
+// storage.Exists.
+func uploadAddressedReport(ctx context.Context, provider storage.Provider, filepath, reference string, maxSize int64, compress bool) (tide.AuditDetails, bool, error) {
+	details := tide.AuditDetails{
+		Type:     provider.Kind(),
+		FileName: reference,
+		Path:     provider.CollectionRef(),
+	}
+	if compress {
+		details.Encoding = storage.EncodingGzip
+	}
+
+	// Look for the report before it is encoded, so that reusing it does no work. Large reports
+	// are found by their chunk manifest.
+	references := []string{reference}
+	if maxSize > 0 {
+		references = append(references, storage.ManifestReference(reference))
+	}
+	for _, ref := range references {
+		if ok, err := storage.Exists(storage.WithContext(ctx, provider), ref); ok && err == nil {
+			details.FileName = ref
+			details.Chunked = ref != reference
+			return details, true, nil
+		}
+	}
+
+	path, encoding, err := encodeReport(ctx, filepath, compress)
+	if err != nil {
+		return tide.AuditDetails{}, false, err
+	}
+	if path != filepath {
+		defer os.Remove(path)
+	}
+
+	details, err = uploadEncodedReport(ctx, provider, path, reference, encoding, maxSize)
+	return details, false, err
+}
+
+// encodeReport returns the path and the content encoding of the report file as it is uploaded,
+// i.e. a gzipped copy if compress is set. The caller removes the copy.
+func encodeReport(ctx context.Context, filepath string, compress bool) (string, string, error) {
+	if !compress {
+		return filepath, "", nil
+	}
+	path, err := storage.GzipFile(ctx, filepath)
+	if err != nil {
+		return "", "", withCode(tide.FailureStorage, err)
+	}
+	return path, storage.EncodingGzip, nil
+}
+
+// uploadEncodedReport uploads a report file of the content encoding, see encodeReport.
+func uploadEncodedReport(ctx context.Context, provider storage.Provider, path, filename, encoding string, maxSize int64) (tide.AuditDetails, error) {
+	details := tide.AuditDetails{
+		Type:     provider.Kind(),
+		FileName: filename,
+		Path:     provider.CollectionRef(),
+		Encoding: encoding,
+	}
+
+	chunked, err := isChunked(path, maxSize)
+	if err != nil {
+		return tide.AuditDetails{}, err
+	}
+	if chunked {
+		// Chunks are parts of the encoded report, so they are uploaded without its encoding. The
+		// manifest records it, see storage.DownloadChunked.
+		if _, err := storage.UploadChunked(storage.WithContext(ctx, provider), path, filename, maxSize); err != nil {
+			return tide.AuditDetails{}, withCode(tide.FailureStorage, err)
+		}
+
+		details.FileName = storage.ManifestReference(filename)
+		details.Chunked = true
+		return details, nil
+	}
+
+	if encoding != "" {
+		ctx = storage.WithMetadata(ctx, storage.Metadata{storage.MetadataContentEncoding: encoding})
+	}
+	if err := storage.WithContext(ctx, provider).UploadFile(path, filename); err != nil {
+		return tide.AuditDetails{}, withCode(tide.FailureStorage, err)
+	}
+
+	return details, nil
+}
+
+// isChunked reports whether a report file is larger than maxSize, so that it is uploaded in
+// chunks. A maxSize of 0 disables chunking.
+func isChunked(path string, maxSize int64) (bool, error) {
+	if maxSize <= 0 {
+		return false, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, withCode(tide.FailureStorage, err)
+	}
+	return info.Size() > maxSize, nil
+}
+
+// reportContext returns the context of the report uploads of the audit of the kind, with the
+// audit type and project slug as storage metadata, e.g. for object metadata of GCS.
+func reportContext(ctx context.Context, msg message.Message, kind string) context.Context {
+	return storage.WithMetadata(ctx, storage.Metadata{
+		storage.MetadataAuditType:   kind,
+		storage.MetadataProjectSlug: msg.Slug,
+	})
+}
@@ -0,0 +1,293 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+// recordingStorage records the references of uploaded files.
+type recordingStorage struct {
+	refs []string
+	fail bool
+}
+
+func (r *recordingStorage) Kind() string          { return "mock" }
+func (r *recordingStorage) CollectionRef() string { return "mock-collection" }
+func (r *recordingStorage) DownloadFile(reference, filename string) error {
+	return errors.New("not implemented")
+}
+
+func (r *recordingStorage) UploadFile(filename, reference string) error {
+	if r.fail {
+		return errors.New("upload error")
+	}
+	r.refs = append(r.refs, reference)
+	return nil
+}
+
+func Test_uploadReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filepath := dir + "/report.json"
+	ioutil.WriteFile(filepath, []byte(`{"report":"0123456789"}`), 0644)
+
+	tests := []struct {
+		name     string
+		maxSize  int64
+		fail     bool
+		want     tide.AuditDetails
+		wantRefs []string
+		wantErr  bool
+	}{
+		{
+			"No Limit",
+			0,
+			false,
+			tide.AuditDetails{Type: "mock", FileName: "report.json", Path: "mock-collection"},
+			[]string{"report.json"},
+			false,
+		},
+		{
+			"Under Limit",
+			1024,
+			false,
+			tide.AuditDetails{Type: "mock", FileName: "report.json", Path: "mock-collection"},
+			[]string{"report.json"},
+			false,
+		},
+		{
+			"Over Limit",
+			10,
+			false,
+			tide.AuditDetails{Type: "mock", FileName: "report.json.manifest.json", Path: "mock-collection", Chunked: true},
+			[]string{"report.json.manifest.json", "report.json.part-0000", "report.json.part-0001", "report.json.part-0002"},
+			false,
+		},
+		{
+			"Upload Error",
+			10,
+			true,
+			tide.AuditDetails{},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &recordingStorage{fail: tt.fail}
+			got, err := uploadReport(context.Background(), provider, filepath, "report.json", tt.maxSize, false)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("uploadReport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && errorCode(err) != tide.FailureStorage {
+				t.Errorf("uploadReport() code = %v, want %v", errorCode(err), tide.FailureStorage)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uploadReport() = %v, want %v", got, tt.want)
+			}
+			sort.Strings(provider.refs)
+			if !reflect.DeepEqual(provider.refs, tt.wantRefs) {
+				t.Errorf("uploadReport() uploaded %v, want %v", provider.refs, tt.wantRefs)
+			}
+		})
+	}
+}
+
+func Test_uploadReport_Compress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filepath := dir + "/report.json"
+	ioutil.WriteFile(filepath, bytes.Repeat([]byte(`{"file":"plugin.php","messages":[]},`), 100), 0644)
+
+	tests := []struct {
+		name         string
+		maxSize      int64
+		want         tide.AuditDetails
+		wantMetadata storage.Metadata
+	}{
+		{
+			"Under Limit Once Compressed",
+			1024,
+			tide.AuditDetails{Type: "mock", FileName: "report.json", Path: "mock-collection", Encoding: storage.EncodingGzip},
+			storage.Metadata{storage.MetadataContentEncoding: storage.EncodingGzip},
+		},
+		{
+			"Over Limit Once Compressed",
+			16,
+			tide.AuditDetails{Type: "mock", FileName: "report.json.manifest.json", Path: "mock-collection", Chunked: true, Encoding: storage.EncodingGzip},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &metadataStorage{}
+			got, err := uploadReport(context.Background(), provider, filepath, "report.json", tt.maxSize, true)
+			if err != nil {
+				t.Fatalf("uploadReport() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("uploadReport() = %v, want %v", got, tt.want)
+			}
+
+			// Chunks are parts of the gzipped report, not gzipped files.
+			if !reflect.DeepEqual(provider.metadata, tt.wantMetadata) {
+				t.Errorf("uploadReport() uploaded with metadata %v, want %v", provider.metadata, tt.wantMetadata)
+			}
+		})
+	}
+}
+
+// checkingStorage is a recordingStorage that can check whether it has a file.
+type checkingStorage struct {
+	recordingStorage
+	existing map[string]bool
+}
+
+func (c *checkingStorage) Exists(reference string) (bool, error) {
+	return c.existing[reference], nil
+}
+
+func Test_uploadAddressedReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filepath := dir + "/report.json"
+	ioutil.WriteFile(filepath, []byte(`{"report":"0123456789"}`), 0644)
+
+	tests := []struct {
+		name       string
+		provider   storage.Provider
+		maxSize    int64
+		want       tide.AuditDetails
+		wantReused bool
+		wantRefs   []string
+	}{
+		{
+			"Uploaded",
+			&checkingStorage{existing: map[string]bool{}},
+			0,
+			tide.AuditDetails{Type: "mock", FileName: "report.json", Path: "mock-collection"},
+			false,
+			[]string{"report.json"},
+		},
+		{
+			"Reused",
+			&checkingStorage{existing: map[string]bool{"report.json": true}},
+			0,
+			tide.AuditDetails{Type: "mock", FileName: "report.json", Path: "mock-collection"},
+			true,
+			nil,
+		},
+		{
+			"Reused Chunks",
+			&checkingStorage{existing: map[string]bool{"report.json.manifest.json": true}},
+			10,
+			tide.AuditDetails{Type: "mock", FileName: "report.json.manifest.json", Path: "mock-collection", Chunked: true},
+			true,
+			nil,
+		},
+		{
+			"Not Supported",
+			&recordingStorage{},
+			0,
+			tide.AuditDetails{Type: "mock", FileName: "report.json", Path: "mock-collection"},
+			false,
+			[]string{"report.json"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reused, err := uploadAddressedReport(context.Background(), tt.provider, filepath, "report.json", tt.maxSize, false)
+			if err != nil {
+				t.Fatalf("uploadAddressedReport() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || reused != tt.wantReused {
+				t.Errorf("uploadAddressedReport() = %v, %v, want %v, %v", got, reused, tt.want, tt.wantReused)
+			}
+
+			var refs []string
+			switch p := tt.provider.(type) {
+			case *checkingStorage:
+				refs = p.refs
+			case *recordingStorage:
+				refs = p.refs
+			}
+			if !reflect.DeepEqual(refs, tt.wantRefs) {
+				t.Errorf("uploadAddressedReport() uploaded %v, want %v", refs, tt.wantRefs)
+			}
+		})
+	}
+}
+
+func Test_uploadAddressedReport_Reused(t *testing.T) {
+	provider := &checkingStorage{existing: map[string]bool{"report.json": true}}
+
+	// Reused reports are neither read nor compressed.
+	got, reused, err := uploadAddressedReport(context.Background(), provider, "notfound.json", "report.json", 10, true)
+	if err != nil {
+		t.Fatalf("uploadAddressedReport() error = %v", err)
+	}
+	want := tide.AuditDetails{Type: "mock", FileName: "report.json", Path: "mock-collection", Encoding: storage.EncodingGzip}
+	if !reflect.DeepEqual(got, want) || !reused {
+		t.Errorf("uploadAddressedReport() = %v, %v, want %v, true", got, reused, want)
+	}
+}
+
+// metadataStorage records the storage metadata of uploads.
+type metadataStorage struct {
+	recordingStorage
+	metadata storage.Metadata
+}
+
+func (m *metadataStorage) UploadFileContext(ctx context.Context, filename, reference string) error {
+	m.metadata = storage.MetadataFromContext(ctx)
+	return m.UploadFile(filename, reference)
+}
+
+func (m *metadataStorage) DownloadFileContext(ctx context.Context, reference, filename string) error {
+	return m.DownloadFile(reference, filename)
+}
+
+func Test_reportContext(t *testing.T) {
+	f, err := ioutil.TempFile("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	provider := &metadataStorage{}
+	ctx := reportContext(context.Background(), message.Message{Slug: "akismet"}, "phpcs_wordpress")
+
+	// Metered uploads keep the metadata of the report.
+	if _, err := uploadReport(ctx, meterStorage(provider, &Result{}), f.Name(), "report.json", 0, false); err != nil {
+		t.Fatalf("uploadReport() error = %v", err)
+	}
+
+	want := storage.Metadata{storage.MetadataAuditType: "phpcs_wordpress", storage.MetadataProjectSlug: "akismet"}
+	if !reflect.DeepEqual(provider.metadata, want) {
+		t.Errorf("uploadReport() uploaded with metadata %v, want %v", provider.metadata, want)
+	}
+}
@@ -0,0 +1,153 @@
+package process
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/storage"
+)
+
+// Usage describes the resources used to audit a message, e.g. to attribute infrastructure
+// cost to the client that requested the audit.
+type Usage struct {
+	CPU        time.Duration `json:"cpu"`        // CPU time of the commands that were run.
+	Downloaded int64         `json:"downloaded"` // Bytes downloaded from the source.
+	Uploaded   int64         `json:"uploaded"`   // Bytes uploaded to storage.
+	Objects    int           `json:"objects"`    // Storage objects written.
+}
+
+// Add adds other to the usage.
+func (u *Usage) Add(other Usage) {
+	u.CPU += other.CPU
+	u.Downloaded += other.Downloaded
+	u.Uploaded += other.Uploaded
+	u.Objects += other.Objects
+}
+
+// runCommand runs a command and adds its CPU time to the result if the runner reports it.
+// Runners that support it stop the command when the context is done.
+func runCommand(ctx context.Context, res *Result, runner shell.Runner, name string, arg ...string) ([]byte, []byte, int, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if res != nil {
+		if metered, ok := runner.(shell.MeteredContextRunner); ok {
+			out, errOut, exitCode, cpu, err := metered.RunMeteredContext(ctx, name, arg...)
+			res.AddUsage(Usage{CPU: cpu})
+			return out, errOut, exitCode, err
+		}
+		if metered, ok := runner.(shell.MeteredRunner); ok {
+			out, errOut, exitCode, cpu, err := metered.RunMetered(name, arg...)
+			res.AddUsage(Usage{CPU: cpu})
+			return out, errOut, exitCode, err
+		}
+	}
+
+	if r, ok := runner.(shell.ContextRunner); ok {
+		return r.RunContext(ctx, name, arg...)
+	}
+	return runner.Run(name, arg...)
+}
+
+// meteredStorage adds the files uploaded to the provider to the usage of a result.
+type meteredStorage struct {
+	storage.Provider
+	res *Result
+}
+
+// meterStorage returns a provider that records uploads in the usage of the result.
+func meterStorage(provider storage.Provider, res *Result) storage.Provider {
+	if provider == nil || res == nil {
+		return provider
+	}
+	return meteredStorage{Provider: provider, res: res}
+}
+
+// UploadFile implements storage.Provider.
+func (m meteredStorage) UploadFile(filename, reference string) error {
+	return m.UploadFileContext(context.Background(), filename, reference)
+}
+
+// UploadFileContext implements storage.ContextProvider.
+func (m meteredStorage) UploadFileContext(ctx context.Context, filename, reference string) error {
+	if err := storage.WithContext(ctx, m.Provider).UploadFile(filename, reference); err != nil {
+		return err
+	}
+
+	u := Usage{Objects: 1}
+	if info, err := os.Stat(filename); err == nil {
+		u.Uploaded = info.Size()
+	}
+	m.res.AddUsage(u)
+
+	return nil
+}
+
+// DownloadFileContext implements storage.ContextProvider.
+func (m meteredStorage) DownloadFileContext(ctx context.Context, reference, filename string) error {
+	return storage.WithContext(ctx, m.Provider).DownloadFile(reference, filename)
+}
+
+// Exists implements storage.Checker.
+func (m meteredStorage) Exists(reference string) (bool, error) {
+	return storage.Exists(m.Provider, reference)
+}
+
+// Delete implements storage.Deleter.
+func (m meteredStorage) Delete(reference string) error {
+	return storage.Delete(m.Provider, reference)
+}
+
+// UsageMeter adds up the usage of the processed messages for each client.
+// It is safe for concurrent use.
+type UsageMeter struct {
+	mu     sync.Mutex
+	totals map[string]Usage
+}
+
+// Record adds the usage of a message requested by client.
+func (m *UsageMeter) Record(client string, u Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.totals == nil {
+		m.totals = make(map[string]Usage)
+	}
+	total := m.totals[client]
+	total.Add(u)
+	m.totals[client] = total
+}
+
+// Totals returns the usage of each client since the meter was created or reset.
+func (m *UsageMeter) Totals() map[string]Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totals := make(map[string]Usage, len(m.totals))
+	for client, u := range m.totals {
+		totals[client] = u
+	}
+	return totals
+}
+
+// Reset clears the totals, e.g. after they have been exported.
+func (m *UsageMeter) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totals = nil
+}
+
+// SummarizeUsage returns the total usage of the results, e.g. of the stored results of a client.
+func SummarizeUsage(results []*Result) Usage {
+	var total Usage
+	for _, res := range results {
+		if res != nil && res.Usage != nil {
+			total.Add(*res.Usage)
+		}
+	}
+	return total
+}
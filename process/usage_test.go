@@ -0,0 +1,197 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/local"
+)
+
+// meteredRunner reports a fixed CPU time for every command.
+type meteredRunner struct {
+	recordingRunner
+}
+
+func (m *meteredRunner) RunMetered(name string, arg ...string) ([]byte, []byte, int, time.Duration, error) {
+	out, errOut, exitCode, err := m.Run(name, arg...)
+	return out, errOut, exitCode, time.Second, err
+}
+
+// contextRunner records the context of the commands it runs.
+type contextRunner struct {
+	recordingRunner
+	ctx context.Context
+}
+
+func (c *contextRunner) RunContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, error) {
+	c.ctx = ctx
+	if err := ctx.Err(); err != nil {
+		return nil, nil, -1, err
+	}
+	return c.Run(name, arg...)
+}
+
+func (c *contextRunner) RunMeteredContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, time.Duration, error) {
+	out, errOut, exitCode, err := c.RunContext(ctx, name, arg...)
+	return out, errOut, exitCode, time.Second, err
+}
+
+// failingStorage fails every upload.
+type failingStorage struct {
+	mockStorage
+}
+
+func (f failingStorage) UploadFile(filename, reference string) error {
+	return errors.New("upload error")
+}
+
+func Test_runCommand(t *testing.T) {
+	res := NewResult()
+
+	runCommand(context.Background(), res, &recordingRunner{}, "phpcs")
+	if res.Usage != nil {
+		t.Errorf("runCommand() usage = %v for a runner that does not report it", res.Usage)
+	}
+
+	runner := &meteredRunner{recordingRunner{fail: true}}
+	runCommand(context.Background(), res, runner, "phpcs", "-q")
+	_, _, _, err := runCommand(context.Background(), res, runner, "phpcs", "-q")
+	if err == nil {
+		t.Errorf("runCommand() expected the error of the command")
+	}
+	if res.Usage == nil || res.Usage.CPU != 2*time.Second {
+		t.Errorf("runCommand() usage = %v, want 2s of CPU time", res.Usage)
+	}
+	if !reflect.DeepEqual(runner.commands[0], []string{"phpcs", "-q"}) {
+		t.Errorf("runCommand() ran %v", runner.commands)
+	}
+
+	// Commands can be run without a result.
+	if _, _, _, err := runCommand(context.Background(), nil, &meteredRunner{}, "phpcs"); err != nil {
+		t.Errorf("runCommand() error = %v", err)
+	}
+}
+
+func Test_runCommand_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res := NewResult()
+	runner := &contextRunner{}
+	if _, _, _, err := runCommand(ctx, res, runner, "phpcs"); err != context.Canceled {
+		t.Errorf("runCommand() error = %v, want %v", err, context.Canceled)
+	}
+	if runner.ctx != ctx || len(runner.commands) != 0 {
+		t.Errorf("runCommand() ran %v in context %v", runner.commands, runner.ctx)
+	}
+	if res.Usage == nil || res.Usage.CPU != time.Second {
+		t.Errorf("runCommand() usage = %v, want 1s of CPU time", res.Usage)
+	}
+
+	// Commands without a result are still run in the context.
+	runner = &contextRunner{}
+	runCommand(ctx, nil, runner, "phpcs")
+	if runner.ctx != ctx {
+		t.Errorf("runCommand() context = %v, want %v", runner.ctx, ctx)
+	}
+}
+
+func Test_meterStorage(t *testing.T) {
+	file, err := ioutil.TempFile("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write([]byte("report"))
+	file.Close()
+
+	os.MkdirAll("./testdata/upload", os.ModePerm)
+	defer os.Remove("./testdata/upload/metered.json")
+
+	res := NewResult()
+	if err := meterStorage(mockStorage{}, res).UploadFile(file.Name(), "metered.json"); err != nil {
+		t.Fatalf("meteredStorage.UploadFile() error = %v", err)
+	}
+	if err := meterStorage(failingStorage{}, res).UploadFile(file.Name(), "metered.json"); err == nil {
+		t.Errorf("meteredStorage.UploadFile() expected an error")
+	}
+
+	want := &Usage{Uploaded: 6, Objects: 1}
+	if !reflect.DeepEqual(res.Usage, want) {
+		t.Errorf("meteredStorage.UploadFile() usage = %v, want %v", res.Usage, want)
+	}
+
+	if got := meterStorage(mockStorage{}, nil); got != (mockStorage{}) {
+		t.Errorf("meterStorage() = %v, want the provider without a result", got)
+	}
+}
+
+func Test_meterStorage_ExistsDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := dir + "/report.json"
+	ioutil.WriteFile(file, []byte("report"), 0644)
+
+	res := NewResult()
+	p := meterStorage(local.NewLocalStorage(dir+"/storage", ""), res)
+	p.UploadFile(file, "report.json")
+
+	if ok, err := storage.Exists(p, "report.json"); !ok || err != nil {
+		t.Errorf("meteredStorage.Exists() = %v, %v, want true", ok, err)
+	}
+	if err := storage.Delete(p, "report.json"); err != nil {
+		t.Errorf("meteredStorage.Delete() error = %v", err)
+	}
+	if ok, err := storage.Exists(p, "report.json"); ok || err != nil {
+		t.Errorf("meteredStorage.Exists() after Delete() = %v, %v, want false", ok, err)
+	}
+
+	// Providers that can't check or delete files aren't supported.
+	if _, err := storage.Exists(meterStorage(mockStorage{}, res), "report.json"); err != storage.ErrNotSupported {
+		t.Errorf("meteredStorage.Exists() error = %v, want %v", err, storage.ErrNotSupported)
+	}
+}
+
+func TestUsageMeter(t *testing.T) {
+	m := &UsageMeter{}
+	m.Record("a", Usage{CPU: time.Second, Downloaded: 100})
+	m.Record("a", Usage{Uploaded: 10, Objects: 2})
+	m.Record("b", Usage{Objects: 1})
+
+	want := map[string]Usage{
+		"a": {CPU: time.Second, Downloaded: 100, Uploaded: 10, Objects: 2},
+		"b": {Objects: 1},
+	}
+	if got := m.Totals(); !reflect.DeepEqual(got, want) {
+		t.Errorf("UsageMeter.Totals() = %v, want %v", got, want)
+	}
+
+	m.Reset()
+	if got := m.Totals(); len(got) != 0 {
+		t.Errorf("UsageMeter.Totals() = %v after a reset", got)
+	}
+}
+
+func TestSummarizeUsage(t *testing.T) {
+	results := []*Result{
+		{Usage: &Usage{CPU: time.Second, Objects: 1}},
+		nil,
+		{},
+		{Usage: &Usage{CPU: time.Second, Downloaded: 5}},
+	}
+
+	want := Usage{CPU: 2 * time.Second, Downloaded: 5, Objects: 1}
+	if got := SummarizeUsage(results); got != want {
+		t.Errorf("SummarizeUsage() = %v, want %v", got, want)
+	}
+}
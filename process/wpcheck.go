@@ -0,0 +1,142 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/storage"
+)
+
+// DefaultCheckStandards are the PHPCS standards of the WordPress.org check audits by audit type.
+var DefaultCheckStandards = map[string]string{
+	"themecheck":  "WPThemeReview",
+	"plugincheck": "PluginCheck",
+}
+
+// WPCheck defines the structure for our Theme Check and Plugin Check process. It runs the
+// rulesets of the WordPress.org directories with PHPCS, like the Phpcs process, but records
+// them as their own "themecheck" and "plugincheck" audits with their own reports.
+type WPCheck struct {
+	Process                                      // Inherits methods from Process.
+	In              <-chan Processor             // Expects a processor channel as input.
+	Out             chan Processor               // Send results to an output channel.
+	Config          map[string]interface{}       // Additional config, as for Phpcs.
+	TempFolder      string                       // Path to a temp folder where reports will be generated.
+	StorageProvider storage.Provider             // Storage provider to upload reports to.
+	PhpcsVersions   map[string]map[string]string // PHPCS versions by standard, e.g. "WPThemeReview".
+	Standards       StandardsManager             // (Optional) Provides the installed standards. Defaults to PhpcsVersions.
+	CheckStandards  map[string]string            // (Optional) PHPCS standard by audit type. Defaults to DefaultCheckStandards.
+	Transformers    []ReportTransformer          // (Optional) Applied to each report in order. Defaults to DefaultReportTransformers().
+	MaxReportSize   int64                        // (Optional) Reports larger than this many bytes are uploaded in chunks.
+	Sandbox         *PhpSandbox                  // (Optional) Runs PHPCS with a generated php.ini that restricts what the audited code can do.
+	Redaction       *Redaction                   // (Optional) Redacts worker details, e.g. temp paths, from the uploaded reports.
+	Runner          shell.Runner                 // (Optional) Runs PHPCS. Defaults to shell.Command.
+	Timeout         time.Duration                // (Optional) Stops PHPCS if an audit takes longer. Messages can override it. Defaults to no limit.
+}
+
+// Run executes the process in a pipe.
+func (wc *WPCheck) Run(sink ErrorSink) error {
+	if wc.TempFolder == "" {
+		return errors.New("no temp folder provided for check reports")
+	}
+
+	if wc.StorageProvider == nil {
+		return errors.New("no storage provider for check reports")
+	}
+
+	if wc.In == nil {
+		return errors.New("requires a previous process")
+	}
+	if wc.Out == nil {
+		return errors.New("requires a next process")
+	}
+
+	if wc.PhpcsVersions == nil && wc.Standards == nil {
+		return errors.New("requires a map of PHPCS versions")
+	}
+
+	if wc.Redaction != nil {
+		if err := wc.Redaction.Validate(); err != nil {
+			return err
+		}
+	}
+
+	wc.runStage(sink, stage{name: "WPCheck", in: wc.In, out: wc.Out, do: wc.Do})
+
+	return nil
+}
+
+// Do runs every Theme Check and Plugin Check audit requested by the message and returns the
+// result with the audits added. An error in one audit does not prevent the other from running.
+func (wc *WPCheck) Do(ctx context.Context, msg message.Message, res *Result) (*Result, error) {
+	if res == nil {
+		return res, errors.New("no result to process")
+	}
+
+	checkStandards := wc.CheckStandards
+	if checkStandards == nil {
+		checkStandards = DefaultCheckStandards
+	}
+
+	cs := &Phpcs{
+		Config:          wc.Config,
+		TempFolder:      wc.TempFolder,
+		StorageProvider: wc.StorageProvider,
+		PhpcsVersions:   wc.PhpcsVersions,
+		Standards:       wc.Standards,
+		Transformers:    wc.Transformers,
+		MaxReportSize:   wc.MaxReportSize,
+		Sandbox:         wc.Sandbox,
+		Redaction:       wc.Redaction,
+		Runner:          wc.Runner,
+		Timeout:         wc.Timeout,
+	}
+
+	var errs []string
+	code := ""
+	for _, audit := range msg.Audits {
+		if audit == nil || skipped(res, audit.Type) {
+			continue
+		}
+		standard, ok := checkStandards[audit.Type]
+		if !ok {
+			continue
+		}
+
+		if err := cs.audit(ctx, msg, res, checkAudit(audit, standard), audit.Type); err != nil {
+			errs = append(errs, err.Error())
+			// The first failure describes the combined error.
+			if code == "" {
+				code = errorCode(err)
+			}
+		}
+	}
+
+	if len(errs) != 0 {
+		return res, withCode(code, errors.New(strings.Join(errs, "; ")))
+	}
+
+	return res, nil
+}
+
+// checkAudit returns the phpcs audit that runs a check audit with the standard. Options of
+// the check audit, e.g. pinned versions or ignored paths, are kept.
+func checkAudit(audit *message.Audit, standard string) *message.Audit {
+	options := message.AuditOption{}
+	if audit.Options != nil {
+		options = *audit.Options
+	}
+	if options.Standard == "" {
+		options.Standard = standard
+	}
+	options.Report = "json"
+
+	return &message.Audit{
+		Type:    "phpcs",
+		Options: &options,
+	}
+}
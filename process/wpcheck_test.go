@@ -0,0 +1,197 @@
+package process
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+// reportRunner writes a PHPCS report to the --report-json file and records the standards it ran.
+type reportRunner struct {
+	standards []string
+}
+
+func (r *reportRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	var standard, report string
+	for _, a := range arg {
+		switch {
+		case strings.HasPrefix(a, "--standard="):
+			standard = strings.TrimPrefix(a, "--standard=")
+		case strings.HasPrefix(a, "--report-json="):
+			report = strings.TrimPrefix(a, "--report-json=")
+		}
+	}
+	r.standards = append(r.standards, standard)
+
+	data := `{"totals":{"errors":1,"warnings":0},"files":{"style.css":{"errors":1,"warnings":0,"messages":[` +
+		`{"message":"Missing Text Domain header","source":"` + standard + `.Headers.TextDomain","severity":5,"type":"ERROR","line":1,"column":1}]}}}`
+	return nil, nil, 1, ioutil.WriteFile(report, []byte(data), 0644)
+}
+
+func TestWPCheck_Run(t *testing.T) {
+	tests := []struct {
+		name    string
+		wc      *WPCheck
+		wantErr bool
+	}{
+		{
+			"Valid Process",
+			&WPCheck{
+				In:              make(chan Processor),
+				Out:             make(chan Processor),
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &recordingStorage{},
+				PhpcsVersions:   map[string]map[string]string{},
+			},
+			false,
+		},
+		{
+			"No Temp Folder",
+			&WPCheck{
+				In:              make(chan Processor),
+				Out:             make(chan Processor),
+				StorageProvider: &recordingStorage{},
+				PhpcsVersions:   map[string]map[string]string{},
+			},
+			true,
+		},
+		{
+			"No Storage Provider",
+			&WPCheck{
+				In:            make(chan Processor),
+				Out:           make(chan Processor),
+				TempFolder:    "./testdata/tmp",
+				PhpcsVersions: map[string]map[string]string{},
+			},
+			true,
+		},
+		{
+			"No Versions",
+			&WPCheck{
+				In:              make(chan Processor),
+				Out:             make(chan Processor),
+				TempFolder:      "./testdata/tmp",
+				StorageProvider: &recordingStorage{},
+			},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancelFunc := context.WithCancel(context.Background())
+			defer cancelFunc()
+			tt.wc.SetContext(ctx)
+
+			if err := tt.wc.Run(nil); (err != nil) != tt.wantErr {
+				t.Errorf("WPCheck.Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWPCheck_Do(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	dir, err := ioutil.TempDir("", "wpcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	versions := map[string]map[string]string{
+		"WPThemeReview": {"phpcs": "3.7.2", "wpthemereview": "0.2.1"},
+		"PluginCheck":   {"phpcs": "3.7.2"},
+	}
+
+	tests := []struct {
+		name          string
+		audits        []*message.Audit
+		want          []string
+		wantStandards []string
+		wantErr       bool
+	}{
+		{
+			"Theme Check",
+			[]*message.Audit{{Type: "themecheck"}, {Type: "lighthouse"}},
+			[]string{"themecheck"},
+			[]string{"WPThemeReview"},
+			false,
+		},
+		{
+			"Both Checks",
+			[]*message.Audit{{Type: "themecheck"}, {Type: "plugincheck"}},
+			[]string{"plugincheck", "themecheck"},
+			[]string{"WPThemeReview", "PluginCheck"},
+			false,
+		},
+		{
+			"Not Requested",
+			[]*message.Audit{{Type: "phpcs", Options: &message.AuditOption{Standard: "wordpress"}}},
+			nil,
+			nil,
+			false,
+		},
+		{
+			"Version Unavailable",
+			[]*message.Audit{
+				{Type: "themecheck", Options: &message.AuditOption{Versions: map[string]string{"wpthemereview": "1.x"}}},
+				{Type: "plugincheck"},
+			},
+			[]string{"plugincheck"},
+			[]string{"PluginCheck"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &reportRunner{}
+			storage := &recordingStorage{}
+			wc := &WPCheck{
+				TempFolder:      dir,
+				StorageProvider: storage,
+				PhpcsVersions:   versions,
+				Runner:          runner,
+			}
+
+			res := &Result{Checksum: "abc123", FilesPath: dir}
+			msg := message.Message{Title: "Test Theme", Audits: tt.audits}
+
+			res, err := wc.Do(context.Background(), msg, res)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WPCheck.Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var got []string
+			for kind := range res.Audits {
+				got = append(got, kind)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WPCheck.Do() audits = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(runner.standards, tt.wantStandards) {
+				t.Errorf("WPCheck.Do() standards = %v, want %v", runner.standards, tt.wantStandards)
+			}
+
+			for _, kind := range tt.want {
+				audit := res.Audits[kind]
+				if audit.Raw.FileName != "abc123-"+kind+"-raw.json" {
+					t.Errorf("WPCheck.Do() %v raw = %v", kind, audit.Raw)
+				}
+				if audit.Summary.PhpcsSummary == nil || audit.Summary.PhpcsSummary.ErrorsCount != 1 {
+					t.Errorf("WPCheck.Do() %v summary = %v", kind, audit.Summary)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package provision
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/shell"
+)
+
+var (
+	writeFile  = ioutil.WriteFile
+	removeFile = os.Remove
+	now        = time.Now
+	freePort   = openPort
+	password   = randomPassword
+	unsafeName = regexp.MustCompile("[^a-z0-9]+")
+)
+
+// composeFile describes a WordPress site with the project mounted into wp-content. It is written
+// as JSON, which is YAML, so that every value is quoted.
+type composeFile struct {
+	Version  string                    `json:"version"`
+	Services map[string]composeService `json:"services"`
+	Volumes  map[string]struct{}       `json:"volumes"`
+}
+
+type composeService struct {
+	Image       string            `json:"image"`
+	User        string            `json:"user,omitempty"`
+	DependsOn   []string          `json:"depends_on,omitempty"`
+	Ports       []string          `json:"ports,omitempty"`
+	Environment map[string]string `json:"environment"`
+	Volumes     []composeVolume   `json:"volumes,omitempty"`
+}
+
+type composeVolume struct {
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// newComposeFile returns the compose file of a project mounted from path. WordPress is published
+// on the port of the address.
+func newComposeFile(image, address string, port int, path string, project Project) composeFile {
+	database := map[string]string{
+		"WORDPRESS_DB_HOST":     "db",
+		"WORDPRESS_DB_USER":     "wordpress",
+		"WORDPRESS_DB_PASSWORD": "wordpress",
+		"WORDPRESS_DB_NAME":     "wordpress",
+	}
+	volumes := []composeVolume{
+		{Type: "volume", Source: "wordpress", Target: "/var/www/html"},
+		{Type: "bind", Source: path, Target: "/var/www/html/wp-content/" + project.Type + "s/" + project.Slug, ReadOnly: true},
+	}
+
+	return composeFile{
+		Version: "3.2",
+		Services: map[string]composeService{
+			"db": {
+				Image: "mysql:5.7",
+				Environment: map[string]string{
+					"MYSQL_ROOT_PASSWORD": "wordpress",
+					"MYSQL_DATABASE":      "wordpress",
+					"MYSQL_USER":          "wordpress",
+					"MYSQL_PASSWORD":      "wordpress",
+				},
+			},
+			"wordpress": {
+				Image:       image,
+				DependsOn:   []string{"db"},
+				Ports:       []string{address + ":" + strconv.Itoa(port) + ":80"},
+				Environment: database,
+				Volumes:     volumes,
+			},
+			"cli": {
+				Image:       "wordpress:cli",
+				User:        "33:33",
+				DependsOn:   []string{"wordpress"},
+				Environment: database,
+				Volumes:     volumes,
+			},
+		},
+		Volumes: map[string]struct{}{"wordpress": {}},
+	}
+}
+
+// DockerCompose implements Provisioner by running WordPress with docker-compose.
+type DockerCompose struct {
+	Runner   shell.Runner  // (Optional) Runs the docker-compose commands. Defaults to shell.Command.
+	Folder   string        // Path to a folder where compose files are written.
+	Image    string        // (Optional) WordPress image. Defaults to "wordpress".
+	Host     string        // (Optional) Host the environment is reachable on. Defaults to "localhost".
+	Address  string        // (Optional) Address to publish WordPress on. Defaults to "127.0.0.1", so that the audited code can't be reached from other hosts.
+	Port     int           // (Optional) Port to publish WordPress on. Defaults to a free port, so that environments can run at once.
+	Attempts int           // (Optional) Attempts to install WordPress while the database starts. Defaults to 10.
+	Delay    time.Duration // (Optional) Delay between install attempts. Defaults to 3 seconds.
+}
+
+// Provision starts a WordPress environment with the project installed and activated.
+func (d DockerCompose) Provision(ctx context.Context, project Project) (*Environment, error) {
+	if err := project.validate(); err != nil {
+		return nil, err
+	}
+
+	if d.Folder == "" {
+		return nil, errors.New("no folder provided for compose files")
+	}
+
+	path, err := filepath.Abs(project.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	port := d.Port
+	if port == 0 {
+		if port, err = freePort(); err != nil {
+			return nil, err
+		}
+	}
+
+	adminPassword, err := password()
+	if err != nil {
+		return nil, err
+	}
+
+	id := "tide-" + strings.Trim(unsafeName.ReplaceAllString(strings.ToLower(project.Slug), "-"), "-") + "-" + strconv.FormatInt(now().UnixNano(), 36)
+	env := &Environment{
+		ID:            id,
+		URL:           "http://" + d.host() + ":" + strconv.Itoa(port),
+		AdminPassword: adminPassword,
+	}
+
+	compose, err := json.MarshalIndent(newComposeFile(d.image(), d.address(), port, path, project), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFile(d.composeFile(env), compose, 0644); err != nil {
+		return nil, err
+	}
+
+	// Tear down what was started if anything below fails, even if provisioning was cancelled.
+	fail := func(err error) (*Environment, error) {
+		d.Teardown(context.Background(), env)
+		return nil, err
+	}
+
+	if err := d.compose(ctx, env, "up", "-d", "db", "wordpress"); err != nil {
+		return fail(err)
+	}
+
+	// The database takes a while to start, so keep trying to install WordPress.
+	err = d.retry(ctx, func() error {
+		return d.wp(ctx, env, "core", "install",
+			"--url="+env.URL,
+			"--title="+project.Slug,
+			"--admin_user=admin",
+			"--admin_password="+env.AdminPassword,
+			"--admin_email=admin@example.com",
+			"--skip-email",
+		)
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := d.wp(ctx, env, project.Type, "activate", "--", project.Slug); err != nil {
+		return fail(err)
+	}
+
+	return env, nil
+}
+
+// Teardown stops the environment and removes its containers, volumes and compose file.
+func (d DockerCompose) Teardown(ctx context.Context, env *Environment) error {
+	if env == nil || env.ID == "" {
+		return errors.New("no environment to tear down")
+	}
+
+	err := d.compose(ctx, env, "down", "-v")
+	removeFile(d.composeFile(env))
+
+	return err
+}
+
+// wp runs a WP-CLI command inside the environment.
+func (d DockerCompose) wp(ctx context.Context, env *Environment, args ...string) error {
+	return d.compose(ctx, env, append([]string{"run", "--rm", "cli", "wp"}, args...)...)
+}
+
+// compose runs a docker-compose command for the environment. The command is stopped when the
+// context is done if the runner is a shell.ContextRunner.
+func (d DockerCompose) compose(ctx context.Context, env *Environment, args ...string) error {
+	runner := d.Runner
+	if runner == nil {
+		runner = &shell.Command{}
+	}
+
+	cmdArgs := append([]string{"-p", env.ID, "-f", d.composeFile(env)}, args...)
+	var errorBytes []byte
+	var err error
+	if r, ok := runner.(shell.ContextRunner); ok && ctx != nil {
+		_, errorBytes, _, err = r.RunContext(ctx, "docker-compose", cmdArgs...)
+	} else {
+		_, errorBytes, _, err = runner.Run("docker-compose", cmdArgs...)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(string(errorBytes)); msg != "" {
+			return errors.New("docker-compose " + args[0] + " failed: " + msg)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// retry calls fn until it succeeds, the attempts run out or the context is cancelled.
+func (d DockerCompose) retry(ctx context.Context, fn func() error) error {
+	attempts := d.Attempts
+	if attempts <= 0 {
+		attempts = 10
+	}
+	delay := d.Delay
+	if delay <= 0 {
+		delay = 3 * time.Second
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+
+		if i == attempts-1 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	return err
+}
+
+func (d DockerCompose) composeFile(env *Environment) string {
+	return strings.TrimRight(d.Folder, "/") + "/" + env.ID + ".yml"
+}
+
+func (d DockerCompose) image() string {
+	if d.Image == "" {
+		return "wordpress"
+	}
+	return d.Image
+}
+
+func (d DockerCompose) address() string {
+	if d.Address == "" {
+		return "127.0.0.1"
+	}
+	return d.Address
+}
+
+func (d DockerCompose) host() string {
+	if d.Host == "" {
+		return "localhost"
+	}
+	return d.Host
+}
+
+// openPort returns a port that is free on the host.
+func openPort() (int, error) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// randomPassword returns a random password for the admin user of an environment.
+func randomPassword() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
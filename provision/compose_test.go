@@ -0,0 +1,338 @@
+package provision
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockRunner records the commands it runs and fails the commands that contain `fail`.
+type mockRunner struct {
+	commands []string
+	fail     string
+	failures int // Number of times to fail before succeeding. Fails forever if 0, succeeds once it is used up.
+}
+
+func (m *mockRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	// Drop the project and file arguments to keep the commands readable.
+	command := strings.Join(arg[4:], " ")
+	m.commands = append(m.commands, command)
+
+	if m.fail != "" && strings.Contains(command, m.fail) {
+		switch {
+		case m.failures == 0:
+			return nil, []byte("something went wrong"), 1, errors.New("exit status 1")
+		case m.failures > 0:
+			// Mark as used up so that the next call succeeds.
+			if m.failures--; m.failures == 0 {
+				m.failures = -1
+			}
+			return nil, nil, 1, errors.New("exit status 1")
+		}
+	}
+
+	return []byte("ok"), nil, 0, nil
+}
+
+func (m *mockRunner) RunContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, error) {
+	if err := ctx.Err(); err != nil {
+		m.commands = append(m.commands, strings.Join(arg[4:], " "))
+		return nil, nil, -1, err
+	}
+	return m.Run(name, arg...)
+}
+
+func TestDockerCompose_Provision(t *testing.T) {
+	var written string
+	writeFile = func(filename string, data []byte, perm os.FileMode) error {
+		if strings.Contains(filename, "unwritable") {
+			return errors.New("could not write file")
+		}
+		written = string(data)
+		return nil
+	}
+	removeFile = func(name string) error { return nil }
+	now = func() time.Time { return time.Unix(0, 42) }
+	freePort = func() (int, error) { return 8080, nil }
+	password = func() (string, error) { return "secret", nil }
+	defer func() {
+		writeFile = ioutil.WriteFile
+		removeFile = os.Remove
+		now = time.Now
+		freePort = openPort
+		password = randomPassword
+	}()
+
+	plugin := Project{Slug: "dummy-plugin", Type: TypePlugin, Path: "/tmp/dummy-plugin"}
+
+	tests := []struct {
+		name         string
+		d            DockerCompose
+		runner       *mockRunner
+		project      Project
+		want         *Environment
+		wantCommands []string
+		wantErr      bool
+	}{
+		{
+			"Provision Plugin",
+			DockerCompose{Folder: "/tmp/", Port: 8888, Delay: time.Millisecond},
+			&mockRunner{},
+			plugin,
+			&Environment{ID: "tide-dummy-plugin-16", URL: "http://localhost:8888", AdminPassword: "secret"},
+			[]string{
+				"up -d db wordpress",
+				"run --rm cli wp core install --url=http://localhost:8888 --title=dummy-plugin --admin_user=admin --admin_password=secret --admin_email=admin@example.com --skip-email",
+				"run --rm cli wp plugin activate -- dummy-plugin",
+			},
+			false,
+		},
+		{
+			"Provision Theme - Database Starting",
+			DockerCompose{Folder: "/tmp", Host: "wp.local", Delay: time.Millisecond},
+			&mockRunner{fail: "core install", failures: 2},
+			Project{Slug: "dummy-theme", Type: TypeTheme, Path: "/tmp/dummy-theme"},
+			&Environment{ID: "tide-dummy-theme-16", URL: "http://wp.local:8080", AdminPassword: "secret"},
+			[]string{
+				"up -d db wordpress",
+				"run --rm cli wp core install --url=http://wp.local:8080 --title=dummy-theme --admin_user=admin --admin_password=secret --admin_email=admin@example.com --skip-email",
+				"run --rm cli wp core install --url=http://wp.local:8080 --title=dummy-theme --admin_user=admin --admin_password=secret --admin_email=admin@example.com --skip-email",
+				"run --rm cli wp core install --url=http://wp.local:8080 --title=dummy-theme --admin_user=admin --admin_password=secret --admin_email=admin@example.com --skip-email",
+				"run --rm cli wp theme activate -- dummy-theme",
+			},
+			false,
+		},
+		{
+			"Install Fails",
+			DockerCompose{Folder: "/tmp", Attempts: 2, Delay: time.Millisecond},
+			&mockRunner{fail: "core install"},
+			plugin,
+			nil,
+			[]string{
+				"up -d db wordpress",
+				"run --rm cli wp core install --url=http://localhost:8080 --title=dummy-plugin --admin_user=admin --admin_password=secret --admin_email=admin@example.com --skip-email",
+				"run --rm cli wp core install --url=http://localhost:8080 --title=dummy-plugin --admin_user=admin --admin_password=secret --admin_email=admin@example.com --skip-email",
+				"down -v",
+			},
+			true,
+		},
+		{
+			"Activate Fails",
+			DockerCompose{Folder: "/tmp"},
+			&mockRunner{fail: "activate"},
+			plugin,
+			nil,
+			[]string{
+				"up -d db wordpress",
+				"run --rm cli wp core install --url=http://localhost:8080 --title=dummy-plugin --admin_user=admin --admin_password=secret --admin_email=admin@example.com --skip-email",
+				"run --rm cli wp plugin activate -- dummy-plugin",
+				"down -v",
+			},
+			true,
+		},
+		{
+			"Up Fails",
+			DockerCompose{Folder: "/tmp"},
+			&mockRunner{fail: "up"},
+			plugin,
+			nil,
+			[]string{
+				"up -d db wordpress",
+				"down -v",
+			},
+			true,
+		},
+		{
+			"Compose File Not Written",
+			DockerCompose{Folder: "/unwritable"},
+			&mockRunner{},
+			plugin,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"No Folder",
+			DockerCompose{},
+			&mockRunner{},
+			plugin,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Unsafe Slug",
+			DockerCompose{Folder: "/tmp"},
+			&mockRunner{},
+			Project{Slug: "../dummy\n    privileged: true", Type: TypePlugin, Path: "/tmp/dummy-plugin"},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Invalid Project",
+			DockerCompose{Folder: "/tmp"},
+			&mockRunner{},
+			Project{Slug: "dummy"},
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.d.Runner = tt.runner
+
+			got, err := tt.d.Provision(context.Background(), tt.project)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("DockerCompose.Provision() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DockerCompose.Provision() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.runner.commands, tt.wantCommands) {
+				t.Errorf("DockerCompose.Provision() commands = %v, want %v", tt.runner.commands, tt.wantCommands)
+			}
+			if got == nil {
+				return
+			}
+
+			var compose composeFile
+			if err := json.Unmarshal([]byte(written), &compose); err != nil {
+				t.Fatalf("DockerCompose.Provision() compose file is invalid: %v", err)
+			}
+			mount := composeVolume{Type: "bind", Source: tt.project.Path, Target: "/var/www/html/wp-content/" + tt.project.Type + "s/" + tt.project.Slug, ReadOnly: true}
+			if volumes := compose.Services["wordpress"].Volumes; len(volumes) != 2 || volumes[1] != mount {
+				t.Errorf("DockerCompose.Provision() compose file does not mount the project:\n%s", written)
+			}
+
+			// WordPress is only published on the loopback interface.
+			if ports := compose.Services["wordpress"].Ports; !reflect.DeepEqual(ports, []string{"127.0.0.1:" + got.URL[strings.LastIndex(got.URL, ":")+1:] + ":80"}) {
+				t.Errorf("DockerCompose.Provision() publishes %v", ports)
+			}
+		})
+	}
+}
+
+func TestDockerCompose_Provision_Cancelled(t *testing.T) {
+	writeFile = func(filename string, data []byte, perm os.FileMode) error { return nil }
+	removeFile = func(name string) error { return nil }
+	defer func() {
+		writeFile = ioutil.WriteFile
+		removeFile = os.Remove
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runner := &mockRunner{}
+	d := DockerCompose{Runner: runner, Folder: "/tmp", Port: 8888}
+	if _, err := d.Provision(ctx, Project{Slug: "dummy-plugin", Type: TypePlugin, Path: "/tmp/dummy-plugin"}); err != context.Canceled {
+		t.Errorf("DockerCompose.Provision() error = %v, want %v", err, context.Canceled)
+	}
+
+	// The environment is still torn down.
+	if want := []string{"up -d db wordpress", "down -v"}; !reflect.DeepEqual(runner.commands, want) {
+		t.Errorf("DockerCompose.Provision() commands = %v, want %v", runner.commands, want)
+	}
+}
+
+func Test_randomPassword(t *testing.T) {
+	first, err := randomPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := randomPassword()
+	if len(first) != 32 || first == second {
+		t.Errorf("randomPassword() = %v, %v", first, second)
+	}
+}
+
+func TestDockerCompose_Teardown(t *testing.T) {
+	removed := ""
+	removeFile = func(name string) error {
+		removed = name
+		return nil
+	}
+	defer func() { removeFile = os.Remove }()
+
+	tests := []struct {
+		name        string
+		runner      *mockRunner
+		env         *Environment
+		wantRemoved string
+		wantErr     bool
+	}{
+		{
+			"Teardown",
+			&mockRunner{},
+			&Environment{ID: "tide-dummy"},
+			"/tmp/tide-dummy.yml",
+			false,
+		},
+		{
+			"Down Fails",
+			&mockRunner{fail: "down"},
+			&Environment{ID: "tide-dummy"},
+			"/tmp/tide-dummy.yml",
+			true,
+		},
+		{
+			"No Environment",
+			&mockRunner{},
+			nil,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removed = ""
+			d := DockerCompose{Runner: tt.runner, Folder: "/tmp"}
+
+			if err := d.Teardown(context.Background(), tt.env); (err != nil) != tt.wantErr {
+				t.Errorf("DockerCompose.Teardown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if removed != tt.wantRemoved {
+				t.Errorf("DockerCompose.Teardown() removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func Test_openPort(t *testing.T) {
+	port, err := openPort()
+	if err != nil {
+		t.Fatalf("openPort() error = %v", err)
+	}
+	if port <= 0 || port > 65535 {
+		t.Errorf("openPort() = %v, want a port", port)
+	}
+}
+
+func TestDockerCompose_retry(t *testing.T) {
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+
+	d := DockerCompose{Attempts: 3, Delay: time.Hour}
+
+	calls := 0
+	err := d.retry(ctx, func() error {
+		calls++
+		return errors.New("not ready")
+	})
+
+	if err != context.Canceled {
+		t.Errorf("DockerCompose.retry() error = %v, want %v", err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Errorf("DockerCompose.retry() calls = %v, want %v", calls, 1)
+	}
+}
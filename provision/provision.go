@@ -0,0 +1,55 @@
+// Package provision spins up ephemeral WordPress environments with a plugin or theme
+// activated so that front-end audits (e.g. Lighthouse) can run against any project.
+package provision
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// safeName matches the slugs and types that can be used in compose files and paths.
+var safeName = regexp.MustCompile("^[a-z0-9-]+$")
+
+// Project types that can be provisioned.
+const (
+	TypePlugin = "plugin"
+	TypeTheme  = "theme"
+)
+
+// Project describes the plugin or theme to activate in an environment.
+type Project struct {
+	Slug string // Directory name of the plugin or theme inside wp-content, e.g. "akismet". Only lowercase letters, digits and dashes.
+	Type string // Either "plugin" or "theme".
+	Path string // Path to the extracted plugin or theme files.
+}
+
+// Environment describes a provisioned WordPress environment.
+type Environment struct {
+	ID            string // Identifier used to tear down the environment.
+	URL           string // Front-end URL of the environment.
+	AdminPassword string // (Optional) Password of the "admin" user, generated for each environment.
+}
+
+// Provisioner is an interface for creating and destroying WordPress environments.
+type Provisioner interface {
+	Provision(ctx context.Context, project Project) (*Environment, error)
+	Teardown(ctx context.Context, env *Environment) error
+}
+
+// validate checks that the project can be provisioned.
+func (p Project) validate() error {
+	if p.Slug == "" {
+		return errors.New("project does not have a slug")
+	}
+	if !safeName.MatchString(p.Slug) {
+		return errors.New("project slug may only contain lowercase letters, digits and dashes")
+	}
+	if p.Path == "" {
+		return errors.New("project does not have a path")
+	}
+	if p.Type != TypePlugin && p.Type != TypeTheme {
+		return errors.New("project type must be a plugin or theme")
+	}
+	return nil
+}
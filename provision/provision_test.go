@@ -0,0 +1,44 @@
+package provision
+
+import "testing"
+
+func TestProject_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		project Project
+		wantErr bool
+	}{
+		{
+			"Valid Plugin",
+			Project{Slug: "dummy-plugin", Type: TypePlugin, Path: "./testdata"},
+			false,
+		},
+		{
+			"Valid Theme",
+			Project{Slug: "dummy-theme", Type: TypeTheme, Path: "./testdata"},
+			false,
+		},
+		{
+			"No Slug",
+			Project{Type: TypePlugin, Path: "./testdata"},
+			true,
+		},
+		{
+			"No Path",
+			Project{Slug: "dummy-plugin", Type: TypePlugin},
+			true,
+		},
+		{
+			"Other Type",
+			Project{Slug: "dummy", Type: "other", Path: "./testdata"},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.project.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Project.validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+// Package reconcile finds audits that are stuck in flight, e.g. because a worker crashed
+// while holding the lease, by comparing the queue state with the audit checkpoints and the
+// results store. Stuck audits are reported and can be re-enqueued automatically.
+package reconcile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+// DefaultMaxAge is the time after which an in-flight audit without progress is considered stuck.
+const DefaultMaxAge = 6 * time.Hour
+
+// Statuses of stale audits.
+const (
+	StatusStuck    = "stuck"    // The audit has no results and has made no progress.
+	StatusComplete = "complete" // The audit has results but its lease was never released.
+)
+
+var now = time.Now
+
+// Lease is a message that has been taken from the queue but not deleted.
+type Lease struct {
+	Ref     string           // Queue reference used to delete the message.
+	Message *message.Message // The leased message.
+	Leased  time.Time        // When the message was taken from the queue.
+}
+
+// Queue is a message provider that can list its in-flight messages.
+type Queue interface {
+	InFlight() ([]Lease, error)
+	SendMessage(msg *message.Message) error
+	DeleteMessage(ref *string) error
+}
+
+// Checkpoints returns the last recorded progress of an in-flight message.
+type Checkpoints interface {
+	// LastCheckpoint returns the last completed stage and when it completed. The bool is false
+	// if there is no checkpoint for the message.
+	LastCheckpoint(ref string) (string, time.Time, bool, error)
+}
+
+// Results checks the results store for the results of a message.
+type Results interface {
+	HasResults(msg *message.Message) (bool, error)
+}
+
+// Reconciler compares the queue, checkpoints and results to find stale audits.
+type Reconciler struct {
+	Queue       Queue
+	Checkpoints Checkpoints   // (Optional) Progress of in-flight messages. Without checkpoints only the lease time is used.
+	Results     Results       // (Optional) Results store. Without results every stale audit is considered stuck.
+	MaxAge      time.Duration // (Optional) Time without progress before an audit is stale. Defaults to DefaultMaxAge.
+	Requeue     bool          // (Optional) Re-enqueue stuck audits and delete their stale lease.
+}
+
+// Stale describes an audit without progress for longer than the maximum age.
+type Stale struct {
+	Ref          string        `json:"ref"`
+	Title        string        `json:"title"`
+	Slug         string        `json:"slug,omitempty"`
+	SourceURL    string        `json:"source_url"`
+	Leased       time.Time     `json:"leased"`
+	LastProgress time.Time     `json:"last_progress"`
+	Stage        string        `json:"stage,omitempty"` // Last completed stage, if known.
+	Age          time.Duration `json:"age"`
+	Status       string        `json:"status"`
+	Requeued     bool          `json:"requeued"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// Report is the result of a reconciliation run.
+type Report struct {
+	Time     time.Time `json:"time"`
+	InFlight int       `json:"in_flight"`
+	Stale    []Stale   `json:"stale"`
+}
+
+// Write writes the report as JSON.
+func (r Report) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Run checks every in-flight message and returns the report of stale audits, oldest first.
+func (r Reconciler) Run() (*Report, error) {
+	if r.Queue == nil {
+		return nil, errors.New("no queue to reconcile")
+	}
+
+	maxAge := r.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	leases, err := r.Queue.InFlight()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Time:     now(),
+		InFlight: len(leases),
+		Stale:    []Stale{},
+	}
+
+	for _, lease := range leases {
+		if lease.Message == nil {
+			continue
+		}
+
+		stale, err := r.check(lease, report.Time, maxAge)
+		if err != nil {
+			return nil, err
+		}
+		if stale == nil {
+			continue
+		}
+
+		if r.Requeue && stale.Status == StatusStuck {
+			if err := r.requeue(lease); err != nil {
+				stale.Error = err.Error()
+			} else {
+				stale.Requeued = true
+			}
+		}
+
+		log.Log(stale.Title, fmt.Sprintf("%s audit without progress for %s (requeued: %t)", stale.Status, stale.Age, stale.Requeued))
+		report.Stale = append(report.Stale, *stale)
+	}
+
+	sort.SliceStable(report.Stale, func(i, j int) bool {
+		return report.Stale[i].Age > report.Stale[j].Age
+	})
+
+	return report, nil
+}
+
+// check returns the stale audit for a lease, or nil if the audit has made progress recently.
+func (r Reconciler) check(lease Lease, current time.Time, maxAge time.Duration) (*Stale, error) {
+	stale := &Stale{
+		Ref:          lease.Ref,
+		Title:        lease.Message.Title,
+		Slug:         lease.Message.Slug,
+		SourceURL:    lease.Message.SourceURL,
+		Leased:       lease.Leased,
+		LastProgress: lease.Leased,
+		Status:       StatusStuck,
+	}
+
+	if r.Checkpoints != nil {
+		stage, at, ok, err := r.Checkpoints.LastCheckpoint(lease.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if ok && at.After(stale.LastProgress) {
+			stale.Stage = stage
+			stale.LastProgress = at
+		}
+	}
+
+	stale.Age = current.Sub(stale.LastProgress)
+	if stale.Age < maxAge {
+		return nil, nil
+	}
+
+	if r.Results != nil {
+		done, err := r.Results.HasResults(lease.Message)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			stale.Status = StatusComplete
+		}
+	}
+
+	return stale, nil
+}
+
+// requeue sends a copy of the leased message and deletes the stale lease.
+func (r Reconciler) requeue(lease Lease) error {
+	msg := *lease.Message
+	msg.ExternalRef = nil
+
+	if err := r.Queue.SendMessage(&msg); err != nil {
+		return err
+	}
+
+	ref := lease.Ref
+	return r.Queue.DeleteMessage(&ref)
+}
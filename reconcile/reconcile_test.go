@@ -0,0 +1,221 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/log"
+	"github.com/wptide/pkg/message"
+)
+
+type mockQueue struct {
+	leases    []Lease
+	sent      []*message.Message
+	deleted   []string
+	listErr   bool
+	sendError bool
+}
+
+func (m *mockQueue) InFlight() ([]Lease, error) {
+	if m.listErr {
+		return nil, errors.New("something went wrong")
+	}
+	return m.leases, nil
+}
+
+func (m *mockQueue) SendMessage(msg *message.Message) error {
+	if m.sendError {
+		return errors.New("send failed")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *mockQueue) DeleteMessage(ref *string) error {
+	m.deleted = append(m.deleted, *ref)
+	return nil
+}
+
+type checkpoint struct {
+	stage string
+	at    time.Time
+}
+
+type mockCheckpoints map[string]checkpoint
+
+func (m mockCheckpoints) LastCheckpoint(ref string) (string, time.Time, bool, error) {
+	if ref == "error" {
+		return "", time.Time{}, false, errors.New("something went wrong")
+	}
+	c, ok := m[ref]
+	return c.stage, c.at, ok, nil
+}
+
+type mockResults map[string]bool
+
+func (m mockResults) HasResults(msg *message.Message) (bool, error) {
+	return m[msg.Slug], nil
+}
+
+func TestReconciler_Run(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	current := time.Unix(1500000000, 0)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	ref := "ref"
+	leases := func() []Lease {
+		return []Lease{
+			{"recent", &message.Message{Title: "Recent", Slug: "recent"}, current.Add(-time.Hour)},
+			{"progress", &message.Message{Title: "Progress", Slug: "progress"}, current.Add(-10 * time.Hour)},
+			{"stuck", &message.Message{Title: "Stuck", Slug: "stuck", ExternalRef: &ref}, current.Add(-8 * time.Hour)},
+			{"complete", &message.Message{Title: "Complete", Slug: "complete"}, current.Add(-12 * time.Hour)},
+		}
+	}
+
+	checkpoints := mockCheckpoints{
+		"progress": {"audit", current.Add(-30 * time.Minute)},
+		"stuck":    {"ingest", current.Add(-7 * time.Hour)},
+	}
+	results := mockResults{"complete": true}
+
+	tests := []struct {
+		name        string
+		r           Reconciler
+		want        []Stale
+		wantSent    int
+		wantDeleted []string
+		wantErr     bool
+	}{
+		{
+			"Report",
+			Reconciler{Checkpoints: checkpoints, Results: results},
+			[]Stale{
+				{Ref: "complete", Title: "Complete", Slug: "complete", Leased: current.Add(-12 * time.Hour), LastProgress: current.Add(-12 * time.Hour), Age: 12 * time.Hour, Status: StatusComplete},
+				{Ref: "stuck", Title: "Stuck", Slug: "stuck", Leased: current.Add(-8 * time.Hour), LastProgress: current.Add(-7 * time.Hour), Stage: "ingest", Age: 7 * time.Hour, Status: StatusStuck},
+			},
+			0,
+			nil,
+			false,
+		},
+		{
+			"Requeue",
+			Reconciler{Checkpoints: checkpoints, Results: results, Requeue: true},
+			[]Stale{
+				{Ref: "complete", Title: "Complete", Slug: "complete", Leased: current.Add(-12 * time.Hour), LastProgress: current.Add(-12 * time.Hour), Age: 12 * time.Hour, Status: StatusComplete},
+				{Ref: "stuck", Title: "Stuck", Slug: "stuck", Leased: current.Add(-8 * time.Hour), LastProgress: current.Add(-7 * time.Hour), Stage: "ingest", Age: 7 * time.Hour, Status: StatusStuck, Requeued: true},
+			},
+			1,
+			[]string{"stuck"},
+			false,
+		},
+		{
+			"Leases Only",
+			Reconciler{MaxAge: 9 * time.Hour},
+			[]Stale{
+				{Ref: "complete", Title: "Complete", Slug: "complete", Leased: current.Add(-12 * time.Hour), LastProgress: current.Add(-12 * time.Hour), Age: 12 * time.Hour, Status: StatusStuck},
+				{Ref: "progress", Title: "Progress", Slug: "progress", Leased: current.Add(-10 * time.Hour), LastProgress: current.Add(-10 * time.Hour), Age: 10 * time.Hour, Status: StatusStuck},
+			},
+			0,
+			nil,
+			false,
+		},
+		{
+			"Checkpoint Error",
+			Reconciler{Checkpoints: mockCheckpoints{}},
+			nil,
+			0,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &mockQueue{leases: leases()}
+			if tt.name == "Checkpoint Error" {
+				queue.leases = []Lease{{"error", &message.Message{}, current}}
+			}
+			tt.r.Queue = queue
+
+			got, err := tt.r.Run()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Reconciler.Run() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(got.Stale, tt.want) {
+				t.Errorf("Reconciler.Run() stale = %v, want %v", got.Stale, tt.want)
+			}
+			if got.InFlight != 4 || !got.Time.Equal(current) {
+				t.Errorf("Reconciler.Run() in flight = %v at %v", got.InFlight, got.Time)
+			}
+			if len(queue.sent) != tt.wantSent || !reflect.DeepEqual(queue.deleted, tt.wantDeleted) {
+				t.Errorf("Reconciler.Run() sent %v and deleted %v", queue.sent, queue.deleted)
+			}
+			for _, msg := range queue.sent {
+				if msg.ExternalRef != nil {
+					t.Errorf("Reconciler.Run() requeued message keeps the stale reference")
+				}
+			}
+		})
+	}
+}
+
+func TestReconciler_Run_Errors(t *testing.T) {
+	b := bytes.Buffer{}
+	log.SetOutput(&b)
+	defer log.SetOutput(os.Stdout)
+
+	if _, err := (Reconciler{}).Run(); err == nil {
+		t.Errorf("Reconciler.Run() expected an error without a queue")
+	}
+
+	if _, err := (Reconciler{Queue: &mockQueue{listErr: true}}).Run(); err == nil {
+		t.Errorf("Reconciler.Run() expected an error when the queue can't be listed")
+	}
+
+	// A failed requeue is recorded in the report and the lease is kept.
+	queue := &mockQueue{
+		leases:    []Lease{{"stuck", &message.Message{Title: "Stuck"}, time.Now().Add(-DefaultMaxAge)}},
+		sendError: true,
+	}
+	report, err := Reconciler{Queue: queue, Requeue: true}.Run()
+	if err != nil {
+		t.Fatalf("Reconciler.Run() error = %v", err)
+	}
+	if len(report.Stale) != 1 || report.Stale[0].Requeued || report.Stale[0].Error != "send failed" || len(queue.deleted) != 0 {
+		t.Errorf("Reconciler.Run() = %v, deleted %v", report.Stale, queue.deleted)
+	}
+}
+
+func TestReport_Write(t *testing.T) {
+	report := Report{
+		Time:     time.Unix(1500000000, 0).UTC(),
+		InFlight: 1,
+		Stale:    []Stale{{Ref: "stuck", Title: "Stuck", Age: time.Hour, Status: StatusStuck}},
+	}
+
+	var b bytes.Buffer
+	if err := report.Write(&b); err != nil {
+		t.Fatalf("Report.Write() error = %v", err)
+	}
+
+	var got Report
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatalf("Report.Write() wrote invalid JSON: %v", err)
+	}
+	if !reflect.DeepEqual(got, report) {
+		t.Errorf("Report.Write() = %v, want %v", got, report)
+	}
+}
@@ -0,0 +1,139 @@
+// Package replay reconstructs audit messages from stored reports so that audits can be
+// re-run (e.g. after a sniff bugfix) without involving the original producer.
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+// Options describes where the results of a replayed message should be sent.
+type Options struct {
+	ResponseAPIEndpoint string // Endpoint to send the new results to.
+	PayloadType         string // Payload type used to build the new results (e.g. "tide").
+}
+
+var readFile = ioutil.ReadFile
+
+// FromFile reads a stored report (as created by the payload builders) and reconstructs its message.
+func FromFile(filename string, opts Options) (*message.Message, error) {
+	data, err := readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var item tide.Item
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+
+	return FromItem(item, opts)
+}
+
+// FromItem reconstructs a message from a stored report. The message uses the same source,
+// audits and audit options as the original message and is always forced so that the
+// existing results get replaced.
+func FromItem(item tide.Item, opts Options) (*message.Message, error) {
+	if item.SourceURL == "" {
+		return nil, errors.New("report has no source url")
+	}
+
+	if len(item.Reports) == 0 {
+		return nil, errors.New("report has no audits to replay")
+	}
+
+	msg := &message.Message{
+		ResponseAPIEndpoint: opts.ResponseAPIEndpoint,
+		PayloadType:         opts.PayloadType,
+		Title:               item.Title,
+		Content:             item.Description,
+		ProjectType:         item.ProjectType,
+		SourceURL:           item.SourceURL,
+		SourceType:          item.SourceType,
+		RequestClient:       item.RequestClient,
+		Force:               true,
+		Visibility:          item.Visibility,
+		Standards:           item.Standards,
+	}
+
+	if len(item.Project) != 0 {
+		msg.Slug = item.Project[0]
+	}
+
+	// Sort the kinds so that the audits are always in the same order.
+	var kinds []string
+	for kind := range item.Reports {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		msg.Audits = append(msg.Audits, auditFromReport(kind, item.Reports[kind]))
+	}
+
+	return msg, nil
+}
+
+// Replay reconstructs the message for a stored report and sends it to the given provider.
+func Replay(provider message.Provider, item tide.Item, opts Options) (*message.Message, error) {
+	if provider == nil {
+		return nil, errors.New("no message provider to replay to")
+	}
+
+	msg, err := FromItem(item, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := provider.SendMessage(msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// auditFromReport reconstructs an audit from the report kind (e.g. "phpcs_wordpress").
+// Options stored with the report, see process.Phpcs.Replayable, are used if available, otherwise the standard is derived from the kind.
+func auditFromReport(kind string, report tide.AuditResult) *message.Audit {
+	parts := strings.SplitN(kind, "_", 2)
+	audit := &message.Audit{
+		Type: parts[0],
+	}
+
+	if options := storedOptions(report); options != nil {
+		audit.Options = options
+	} else if len(parts) == 2 {
+		audit.Options = &message.AuditOption{
+			Standard: parts[1],
+		}
+	}
+
+	return audit
+}
+
+// storedOptions returns the audit options stored with the report, or nil if there are none.
+func storedOptions(report tide.AuditResult) *message.AuditOption {
+	stored, ok := report.Extra["options"]
+	if !ok || stored == nil {
+		return nil
+	}
+
+	// Reports read from storage have the options as a generic map, so convert via JSON.
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return nil
+	}
+
+	var options *message.AuditOption
+	if err := json.Unmarshal(data, &options); err != nil {
+		return nil
+	}
+
+	return options
+}
@@ -0,0 +1,255 @@
+package replay
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/message"
+	"github.com/wptide/pkg/tide"
+)
+
+type mockProvider struct {
+	sent      []*message.Message
+	shouldErr bool
+}
+
+func (m *mockProvider) SendMessage(msg *message.Message) error {
+	if m.shouldErr {
+		return errors.New("something went wrong")
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func (m *mockProvider) GetNextMessage() (*message.Message, error) { return nil, nil }
+func (m *mockProvider) DeleteMessage(ref *string) error           { return nil }
+func (m *mockProvider) Close() error                              { return nil }
+
+var (
+	testOptions = Options{
+		ResponseAPIEndpoint: "http://tide.local/api/v1/audit",
+		PayloadType:         "tide",
+	}
+
+	testItem = tide.Item{
+		Title:         "Dummy Plugin",
+		Description:   "This is a dummy plugin.",
+		Visibility:    "public",
+		ProjectType:   "plugin",
+		SourceURL:     "https://downloads.wordpress.org/plugin/dummy-plugin.zip",
+		SourceType:    "zip",
+		RequestClient: "wporg",
+		Project:       []string{"dummy-plugin"},
+		Reports: map[string]tide.AuditResult{
+			"phpcs_wordpress": {},
+			"phpcs_phpcompatibility": {
+				Extra: map[string]interface{}{
+					"options": map[string]interface{}{
+						"standard":    "phpcompatibility",
+						"report":      "json",
+						"runtime-set": "testVersion 5.2-",
+					},
+				},
+			},
+			"lighthouse": {},
+		},
+	}
+
+	testMessage = &message.Message{
+		ResponseAPIEndpoint: "http://tide.local/api/v1/audit",
+		PayloadType:         "tide",
+		Title:               "Dummy Plugin",
+		Content:             "This is a dummy plugin.",
+		Slug:                "dummy-plugin",
+		ProjectType:         "plugin",
+		SourceURL:           "https://downloads.wordpress.org/plugin/dummy-plugin.zip",
+		SourceType:          "zip",
+		RequestClient:       "wporg",
+		Force:               true,
+		Visibility:          "public",
+		Audits: []*message.Audit{
+			{
+				Type: "lighthouse",
+			},
+			{
+				Type: "phpcs",
+				Options: &message.AuditOption{
+					Standard:   "phpcompatibility",
+					Report:     "json",
+					RuntimeSet: "testVersion 5.2-",
+				},
+			},
+			{
+				Type: "phpcs",
+				Options: &message.AuditOption{
+					Standard: "wordpress",
+				},
+			},
+		},
+	}
+)
+
+func TestFromItem(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    tide.Item
+		want    *message.Message
+		wantErr bool
+	}{
+		{
+			"Valid Report",
+			testItem,
+			testMessage,
+			false,
+		},
+		{
+			"No Source URL",
+			tide.Item{
+				Reports: testItem.Reports,
+			},
+			nil,
+			true,
+		},
+		{
+			"No Reports",
+			tide.Item{
+				SourceURL: testItem.SourceURL,
+			},
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromItem(tt.item, testOptions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FromItem() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FromItem() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		filename  string
+		wantSlug  string
+		wantTypes []string
+		wantErr   bool
+	}{
+		{
+			"Stored Report",
+			"./testdata/report.json",
+			"dummy-plugin",
+			[]string{"lighthouse", "phpcs"},
+			false,
+		},
+		{
+			"Missing Report",
+			"./testdata/missing.json",
+			"",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromFile(tt.filename, testOptions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FromFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.Slug != tt.wantSlug {
+				t.Errorf("FromFile() slug = %v, want %v", got.Slug, tt.wantSlug)
+			}
+
+			var types []string
+			for _, audit := range got.Audits {
+				types = append(types, audit.Type)
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("FromFile() audits = %v, want %v", types, tt.wantTypes)
+			}
+
+			if got.Audits[1].Options.RuntimeSet != "testVersion 5.2-" {
+				t.Errorf("FromFile() runtime-set = %v, want %v", got.Audits[1].Options.RuntimeSet, "testVersion 5.2-")
+			}
+		})
+	}
+}
+
+func TestFromFile_InvalidJSON(t *testing.T) {
+	readFile = func(filename string) ([]byte, error) {
+		return []byte("{invalid"), nil
+	}
+	defer func() { readFile = ioutil.ReadFile }()
+
+	if _, err := FromFile("report.json", testOptions); err == nil {
+		t.Errorf("FromFile() expected an error for invalid JSON")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider *mockProvider
+		item     tide.Item
+		wantErr  bool
+	}{
+		{
+			"Replay Report",
+			&mockProvider{},
+			testItem,
+			false,
+		},
+		{
+			"Invalid Report",
+			&mockProvider{},
+			tide.Item{},
+			true,
+		},
+		{
+			"Provider Error",
+			&mockProvider{shouldErr: true},
+			testItem,
+			true,
+		},
+		{
+			"No Provider",
+			nil,
+			testItem,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var provider message.Provider
+			if tt.provider != nil {
+				provider = tt.provider
+			}
+
+			got, err := Replay(provider, tt.item, testOptions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Replay() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !reflect.DeepEqual(tt.provider.sent, []*message.Message{got}) {
+				t.Errorf("Replay() sent = %v, want %v", tt.provider.sent, []*message.Message{got})
+			}
+		})
+	}
+}
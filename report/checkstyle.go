@@ -0,0 +1,67 @@
+package report
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// CheckstyleVersion is the Checkstyle version the reports claim to be compatible with.
+const CheckstyleVersion = "4.3"
+
+// Checkstyle emits reports in the Checkstyle XML format.
+type Checkstyle struct{}
+
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// Extension implements Emitter.
+func (Checkstyle) Extension() string { return "xml" }
+
+// Emit implements Emitter. Every file of the report is listed, files without messages have no
+// errors.
+func (Checkstyle) Emit(results tide.PhpcsResults) ([]byte, error) {
+	report := checkstyleReport{Version: CheckstyleVersion, Files: []checkstyleFile{}}
+
+	for _, name := range fileNames(results) {
+		file := checkstyleFile{Name: name}
+		for _, msg := range results.Files[name].Messages {
+			file.Errors = append(file.Errors, checkstyleError{
+				Line:     msg.Line,
+				Column:   msg.Column,
+				Severity: strings.ToLower(msg.Type),
+				Message:  msg.Message,
+				Source:   msg.Source,
+			})
+		}
+		report.Files = append(report.Files, file)
+	}
+
+	return marshalXML(report)
+}
+
+// marshalXML returns the indented XML document of v.
+func marshalXML(v interface{}) ([]byte, error) {
+	data, err := xml.MarshalIndent(v, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
@@ -0,0 +1,72 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// JUnit emits reports in the JUnit XML format: a test suite per file, with a failing test case
+// per message. Files without messages have a single passing test case.
+type JUnit struct {
+	Name string // (Optional) Name of the test suites. Defaults to "PHP_CodeSniffer".
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name    string        `xml:"name,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+}
+
+// Extension implements Emitter.
+func (JUnit) Extension() string { return "xml" }
+
+// Emit implements Emitter.
+func (j JUnit) Emit(results tide.PhpcsResults) ([]byte, error) {
+	name := j.Name
+	if name == "" {
+		name = "PHP_CodeSniffer"
+	}
+	report := junitSuites{Name: name, Suites: []junitSuite{}}
+
+	for _, file := range fileNames(results) {
+		suite := junitSuite{Name: file}
+		for _, msg := range results.Files[file].Messages {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:    fmt.Sprintf("%s at %s (%d:%d)", msg.Source, file, msg.Line, msg.Column),
+				Failure: &junitFailure{Type: msg.Type, Message: msg.Message},
+			})
+			suite.Failures++
+		}
+		if len(suite.Cases) == 0 {
+			suite.Cases = append(suite.Cases, junitCase{Name: file})
+		}
+		suite.Tests = len(suite.Cases)
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	return marshalXML(report)
+}
@@ -0,0 +1,204 @@
+// Package paged stores PHPCS reports as a summary plus one detail object per file, listed
+// in an index, so that consumers can fetch the messages of one file without downloading
+// the whole report.
+//
+// A report stored with the prefix "abc-phpcs_wordpress" consists of:
+//
+//	abc-phpcs_wordpress-index.json             The Index.
+//	abc-phpcs_wordpress-summary.json           The Summary.
+//	abc-phpcs_wordpress-files/<FileKey>.json   A File for every file with messages.
+package paged
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/tide"
+)
+
+// Index lists the objects of a paginated report.
+type Index struct {
+	Summary string           `json:"summary"` // Reference of the Summary.
+	Files   map[string]Entry `json:"files"`   // Detail objects by file path.
+}
+
+// Entry references the detail object of a file.
+type Entry struct {
+	Reference string `json:"reference"`
+	Errors    int    `json:"errors"`
+	Warnings  int    `json:"warnings"`
+}
+
+// Summary is the report without its messages.
+type Summary struct {
+	Errors   int          `json:"errors"`
+	Warnings int          `json:"warnings"`
+	Files    []FileTotals `json:"files"` // Ordered by path.
+}
+
+// FileTotals are the message counts of a file.
+type FileTotals struct {
+	Path     string `json:"path"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+}
+
+// File is the detail object of a file.
+type File struct {
+	FileTotals
+	Messages []tide.PhpcsFilesMessage `json:"messages"`
+}
+
+// UploadFunc stores data under the name, e.g. Report.Upload of the process package.
+type UploadFunc func(name string, data []byte) (tide.AuditDetails, error)
+
+// FileKey returns the key of a file's detail object: the hex encoded SHA256 of its path.
+func FileKey(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(sum[:])
+}
+
+// Split returns the summary and the files of a report. Only files with messages have a File.
+func Split(results tide.PhpcsResults) (Summary, []File) {
+	summary := Summary{
+		Errors:   results.Totals.Errors,
+		Warnings: results.Totals.Warnings,
+		Files:    []FileTotals{},
+	}
+
+	var files []File
+	for path, data := range results.Files {
+		totals := FileTotals{Path: path, Errors: data.Errors, Warnings: data.Warnings}
+		summary.Files = append(summary.Files, totals)
+		if len(data.Messages) > 0 {
+			files = append(files, File{FileTotals: totals, Messages: data.Messages})
+		}
+	}
+
+	sort.Slice(summary.Files, func(i, j int) bool { return summary.Files[i].Path < summary.Files[j].Path })
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+
+	return summary, files
+}
+
+// Upload stores the report under the prefix and returns the details of the index.
+// The index is uploaded last, so a readable index always references complete objects.
+func Upload(upload UploadFunc, prefix string, results tide.PhpcsResults) (tide.AuditDetails, error) {
+	summary, files := Split(results)
+
+	index := Index{
+		Summary: prefix + "-summary.json",
+		Files:   make(map[string]Entry),
+	}
+
+	if err := uploadJSON(upload, index.Summary, summary); err != nil {
+		return tide.AuditDetails{}, err
+	}
+
+	for _, file := range files {
+		entry := Entry{
+			Reference: prefix + "-files/" + FileKey(file.Path) + ".json",
+			Errors:    file.Errors,
+			Warnings:  file.Warnings,
+		}
+		if err := uploadJSON(upload, entry.Reference, file); err != nil {
+			return tide.AuditDetails{}, err
+		}
+		index.Files[file.Path] = entry
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return tide.AuditDetails{}, err
+	}
+	return upload(prefix+"-index.json", data)
+}
+
+// uploadJSON stores v as JSON under the name.
+func uploadJSON(upload UploadFunc, name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = upload(name, data)
+	return err
+}
+
+// Reader reads paginated reports from a storage provider.
+type Reader struct {
+	Provider   storage.Provider
+	TempFolder string // (Optional) Folder for downloaded objects. Defaults to os.TempDir().
+}
+
+// Index downloads the index of a report, e.g. the FileName of the report's Index details.
+func (r Reader) Index(reference string) (*Index, error) {
+	var index Index
+	if err := r.download(reference, &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// Summary downloads the summary of a report.
+func (r Reader) Summary(index *Index) (*Summary, error) {
+	var summary Summary
+	if err := r.download(index.Summary, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// File downloads the messages of a file. Files without messages are not in the index, so
+// File returns a File without messages for them instead of downloading it.
+func (r Reader) File(index *Index, path string) (*File, error) {
+	entry, ok := index.Files[path]
+	if !ok {
+		return &File{FileTotals: FileTotals{Path: path}, Messages: []tide.PhpcsFilesMessage{}}, nil
+	}
+
+	var file File
+	if err := r.download(entry.Reference, &file); err != nil {
+		return nil, err
+	}
+	if file.Path != path {
+		return nil, errors.New("detail object of " + path + " belongs to " + file.Path)
+	}
+	return &file, nil
+}
+
+// download downloads an object and decodes it into v.
+func (r Reader) download(reference string, v interface{}) error {
+	if r.Provider == nil {
+		return errors.New("no storage provider to read the report from")
+	}
+
+	folder := r.TempFolder
+	if folder == "" {
+		folder = os.TempDir()
+	}
+
+	f, err := ioutil.TempFile(folder, "report-")
+	if err != nil {
+		return err
+	}
+	filename := f.Name()
+	f.Close()
+	defer os.Remove(filepath.Clean(filename))
+
+	if err := r.Provider.DownloadFile(reference, filename); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
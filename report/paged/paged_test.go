@@ -0,0 +1,196 @@
+package paged
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// memoryProvider stores uploaded files in memory.
+type memoryProvider struct {
+	files map[string][]byte
+}
+
+func (m *memoryProvider) Kind() string          { return "memory" }
+func (m *memoryProvider) CollectionRef() string { return "memory" }
+
+func (m *memoryProvider) UploadFile(filename, reference string) error {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	m.files[reference] = data
+	return nil
+}
+
+func (m *memoryProvider) DownloadFile(reference, filename string) error {
+	data, ok := m.files[reference]
+	if !ok {
+		return errors.New("not found")
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// upload stores the data in the provider, like Report.Upload of the process package.
+func (m *memoryProvider) upload(name string, data []byte) (tide.AuditDetails, error) {
+	m.files[name] = data
+	return tide.AuditDetails{Type: "memory", FileName: name}, nil
+}
+
+func testResults() tide.PhpcsResults {
+	results := tide.PhpcsResults{}
+	results.Totals.Errors = 2
+	results.Totals.Warnings = 1
+	results.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{
+		"plugin.php": {
+			Errors:   2,
+			Warnings: 0,
+			Messages: []tide.PhpcsFilesMessage{
+				{Message: "Not escaped", Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "ERROR", Line: 3},
+				{Message: "Syntax", Source: "Generic.PHP.Syntax.PHPSyntax", Type: "ERROR", Line: 8},
+			},
+		},
+		"inc/admin.php": {
+			Warnings: 1,
+			Messages: []tide.PhpcsFilesMessage{
+				{Message: "No comment", Source: "WordPress.WP.I18n.MissingTranslatorsComment", Type: "WARNING", Line: 12},
+			},
+		},
+		"inc/clean.php": {},
+	}
+	return results
+}
+
+func TestFileKey(t *testing.T) {
+	if got := FileKey("plugin.php"); len(got) != 64 || got != FileKey("plugin.php") || got == FileKey("inc/plugin.php") {
+		t.Errorf("FileKey() = %v", got)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	summary, files := Split(testResults())
+
+	wantSummary := Summary{
+		Errors:   2,
+		Warnings: 1,
+		Files: []FileTotals{
+			{Path: "inc/admin.php", Warnings: 1},
+			{Path: "inc/clean.php"},
+			{Path: "plugin.php", Errors: 2},
+		},
+	}
+	if !reflect.DeepEqual(summary, wantSummary) {
+		t.Errorf("Split() summary = %v, want %v", summary, wantSummary)
+	}
+
+	if len(files) != 2 || files[0].Path != "inc/admin.php" || files[1].Path != "plugin.php" || len(files[1].Messages) != 2 {
+		t.Errorf("Split() files = %v", files)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	provider := &memoryProvider{files: make(map[string][]byte)}
+
+	details, err := Upload(provider.upload, "abc-phpcs_wordpress", testResults())
+	if err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+	if details.FileName != "abc-phpcs_wordpress-index.json" {
+		t.Errorf("Upload() details = %v", details)
+	}
+
+	wantObjects := []string{
+		"abc-phpcs_wordpress-files/" + FileKey("inc/admin.php") + ".json",
+		"abc-phpcs_wordpress-files/" + FileKey("plugin.php") + ".json",
+		"abc-phpcs_wordpress-index.json",
+		"abc-phpcs_wordpress-summary.json",
+	}
+	for _, name := range wantObjects {
+		if _, ok := provider.files[name]; !ok {
+			t.Errorf("Upload() did not upload %v", name)
+		}
+	}
+	if len(provider.files) != len(wantObjects) {
+		t.Errorf("Upload() uploaded %d objects, want %d", len(provider.files), len(wantObjects))
+	}
+
+	// The index is not uploaded if an object can't be uploaded.
+	var uploaded []string
+	failing := func(name string, data []byte) (tide.AuditDetails, error) {
+		uploaded = append(uploaded, name)
+		if len(uploaded) == 2 {
+			return tide.AuditDetails{}, errors.New("upload error")
+		}
+		return tide.AuditDetails{FileName: name}, nil
+	}
+	if _, err := Upload(failing, "abc", testResults()); err == nil || len(uploaded) != 2 {
+		t.Errorf("Upload() error = %v, uploaded = %v", err, uploaded)
+	}
+}
+
+func TestReader(t *testing.T) {
+	provider := &memoryProvider{files: make(map[string][]byte)}
+	details, err := Upload(provider.upload, "abc-phpcs_wordpress", testResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Reader{Provider: provider}
+
+	index, err := r.Index(details.FileName)
+	if err != nil {
+		t.Fatalf("Reader.Index() error = %v", err)
+	}
+	if entry := index.Files["plugin.php"]; entry.Errors != 2 || entry.Reference == "" {
+		t.Errorf("Reader.Index() entry = %v", entry)
+	}
+
+	summary, err := r.Summary(index)
+	if err != nil || summary.Errors != 2 || len(summary.Files) != 3 {
+		t.Errorf("Reader.Summary() = %v, error = %v", summary, err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantMessages int
+		wantErr      bool
+	}{
+		{"File With Messages", "plugin.php", 2, false},
+		{"Other File", "inc/admin.php", 1, false},
+		{"File Without Messages", "inc/clean.php", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := r.File(index, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Reader.File() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if file.Path != tt.path || len(file.Messages) != tt.wantMessages {
+				t.Errorf("Reader.File() = %v", file)
+			}
+		})
+	}
+
+	// Detail objects must belong to the requested file.
+	index.Files["inc/admin.php"] = index.Files["plugin.php"]
+	if _, err := r.File(index, "inc/admin.php"); err == nil {
+		t.Error("Reader.File() expected an error for a mismatched detail object")
+	}
+
+	if _, err := r.Index("missing.json"); err == nil {
+		t.Error("Reader.Index() expected an error for a missing index")
+	}
+
+	if _, err := (Reader{}).Index(details.FileName); err == nil {
+		t.Error("Reader.Index() expected an error without a provider")
+	}
+}
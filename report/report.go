@@ -0,0 +1,91 @@
+// Package report emits PHPCS reports in the formats of other tools, e.g. the Checkstyle and
+// JUnit XML read by many CI systems, or the SARIF logs of GitHub code scanning. Emitters are selected by format name, and other packages
+// can Register emitters of their own.
+package report
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/wptide/pkg/report/sarif"
+	"github.com/wptide/pkg/tide"
+)
+
+// Emitter writes a report in a format.
+type Emitter interface {
+	Extension() string // File extension of the format, e.g. "xml".
+	Emit(results tide.PhpcsResults) ([]byte, error)
+}
+
+// emitters are the registered emitters by format name.
+var emitters = struct {
+	sync.RWMutex
+	byFormat map[string]Emitter
+}{byFormat: map[string]Emitter{
+	"json":       JSON{},
+	"checkstyle": Checkstyle{},
+	"junit":      JUnit{},
+	"sarif": sarif.Emitter{Driver: sarif.Driver{
+		Name:           "PHP_CodeSniffer",
+		InformationURI: "https://github.com/squizlabs/PHP_CodeSniffer",
+	}},
+}}
+
+// Register makes an emitter available under the format name, replacing the emitter registered
+// under the name before.
+func Register(format string, emitter Emitter) {
+	emitters.Lock()
+	defer emitters.Unlock()
+	emitters.byFormat[strings.ToLower(format)] = emitter
+}
+
+// Lookup returns the emitter of the format.
+func Lookup(format string) (Emitter, bool) {
+	emitters.RLock()
+	defer emitters.RUnlock()
+	emitter, ok := emitters.byFormat[strings.ToLower(format)]
+	return emitter, ok
+}
+
+// Formats returns the formats of a comma separated list, e.g. "json,checkstyle", in order and
+// without duplicates. Formats without a registered emitter are an error.
+func Formats(list string) ([]string, error) {
+	formats := []string{}
+	seen := make(map[string]bool)
+	for _, format := range strings.Split(list, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" || seen[format] {
+			continue
+		}
+		if _, ok := Lookup(format); !ok {
+			return nil, errors.New("unknown report format: " + format)
+		}
+		seen[format] = true
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+// JSON emits reports in the JSON format of PHPCS.
+type JSON struct{}
+
+// Extension implements Emitter.
+func (JSON) Extension() string { return "json" }
+
+// Emit implements Emitter.
+func (JSON) Emit(results tide.PhpcsResults) ([]byte, error) {
+	return json.Marshal(results)
+}
+
+// fileNames returns the names of the files of a report, sorted.
+func fileNames(results tide.PhpcsResults) []string {
+	names := make([]string, 0, len(results.Files))
+	for name := range results.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,132 @@
+package report
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func testResults() tide.PhpcsResults {
+	results := tide.PhpcsResults{}
+	results.Totals.Errors = 1
+	results.Totals.Warnings = 1
+	results.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{
+		"plugin.php": {
+			Errors:   1,
+			Warnings: 1,
+			Messages: []tide.PhpcsFilesMessage{
+				{Message: `Use "esc_html()"`, Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "ERROR", Line: 3, Column: 7},
+				{Message: "Unused", Source: "Generic.CodeAnalysis.UnusedFunctionParameter.Found", Type: "WARNING", Line: 9, Column: 1},
+			},
+		},
+		"inc/admin.php": {},
+	}
+	return results
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    string
+		want    []string
+		wantErr bool
+	}{
+		{"Empty", "", []string{}, false},
+		{"Formats", "json, Checkstyle,junit,SARIF", []string{"json", "checkstyle", "junit", "sarif"}, false},
+		{"Duplicates", "junit,,junit", []string{"junit"}, false},
+		{"Unknown", "json,html", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Formats(tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Formats() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Formats() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	if _, ok := Lookup("custom"); ok {
+		t.Fatal("Lookup() found an emitter before it was registered")
+	}
+
+	Register("Custom", JUnit{Name: "Custom"})
+	defer func() {
+		emitters.Lock()
+		delete(emitters.byFormat, "custom")
+		emitters.Unlock()
+	}()
+
+	if got, ok := Lookup("custom"); !ok || got != (JUnit{Name: "Custom"}) {
+		t.Errorf("Lookup() = %v, %v", got, ok)
+	}
+	if _, err := Formats("custom"); err != nil {
+		t.Errorf("Formats() error = %v", err)
+	}
+}
+
+func TestEmitters(t *testing.T) {
+	results := testResults()
+	wantJSON, _ := json.Marshal(results)
+
+	tests := []struct {
+		format string
+		ext    string
+		want   string
+	}{
+		{"json", "json", string(wantJSON)},
+		{"checkstyle", "xml", `<?xml version="1.0" encoding="UTF-8"?>
+<checkstyle version="4.3">
+ <file name="inc/admin.php"></file>
+ <file name="plugin.php">
+  <error line="3" column="7" severity="error" message="Use &#34;esc_html()&#34;" source="WordPress.Security.EscapeOutput.OutputNotEscaped"></error>
+  <error line="9" column="1" severity="warning" message="Unused" source="Generic.CodeAnalysis.UnusedFunctionParameter.Found"></error>
+ </file>
+</checkstyle>
+`},
+		{"junit", "xml", `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="PHP_CodeSniffer" tests="3" failures="2">
+ <testsuite name="inc/admin.php" tests="1" failures="0">
+  <testcase name="inc/admin.php"></testcase>
+ </testsuite>
+ <testsuite name="plugin.php" tests="2" failures="2">
+  <testcase name="WordPress.Security.EscapeOutput.OutputNotEscaped at plugin.php (3:7)">
+   <failure type="ERROR" message="Use &#34;esc_html()&#34;"></failure>
+  </testcase>
+  <testcase name="Generic.CodeAnalysis.UnusedFunctionParameter.Found at plugin.php (9:1)">
+   <failure type="WARNING" message="Unused"></failure>
+  </testcase>
+ </testsuite>
+</testsuites>
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			emitter, ok := Lookup(tt.format)
+			if !ok {
+				t.Fatalf("Lookup(%q) found no emitter", tt.format)
+			}
+			if got := emitter.Extension(); got != tt.ext {
+				t.Errorf("Extension() = %v, want %v", got, tt.ext)
+			}
+
+			got, err := emitter.Emit(results)
+			if err != nil {
+				t.Fatalf("Emit() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Emit() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Package sarif converts PHPCS reports into SARIF 2.1.0 logs, the Static Analysis Results
+// Interchange Format read by GitHub code scanning and other code analysis tools.
+//
+// Every tool that reports in the PHPCS format can be converted, e.g. the lint reports of the
+// process package. Each sniff source becomes a rule and each message a result located at its
+// line and column in the file, relative to the root of the audited source ("%SRCROOT%").
+package sarif
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// Version and Schema identify the SARIF format of the logs.
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SrcRoot is the base of the artifact locations, i.e. the root of the audited source.
+const SrcRoot = "%SRCROOT%"
+
+// Log is a SARIF log with a single run.
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of one tool.
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+}
+
+// Tool describes the tool of a run.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the tool that produced the results, e.g. PHPCS, with the rules it checked.
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules,omitempty"`
+}
+
+// Rule is a sniff source, e.g. "WordPress.Security.EscapeOutput.OutputNotEscaped".
+type Rule struct {
+	ID string `json:"id"`
+}
+
+// Result is a message of the report.
+type Result struct {
+	RuleID    string     `json:"ruleId,omitempty"`
+	RuleIndex *int       `json:"ruleIndex,omitempty"`
+	Level     string     `json:"level"`
+	Message   Message    `json:"message"`
+	Locations []Location `json:"locations"`
+}
+
+// Message is the text of a result.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Location is where a result was found.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is the path of a file relative to its base.
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is the line and column of a result. Both start at 1.
+type Region struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// Builder builds a log one file at a time, e.g. while a report is read file by file.
+type Builder struct {
+	driver  Driver
+	files   map[string][]Result
+	ruleIDs map[string]bool
+}
+
+// NewBuilder returns a Builder of a log for the tool.
+func NewBuilder(driver Driver) *Builder {
+	return &Builder{
+		driver:  driver,
+		files:   make(map[string][]Result),
+		ruleIDs: make(map[string]bool),
+	}
+}
+
+// Add adds the messages of the file to the log.
+func (b *Builder) Add(path string, messages []tide.PhpcsFilesMessage) {
+	uri := strings.TrimPrefix(strings.Replace(path, "\\", "/", -1), "/")
+
+	for _, msg := range messages {
+		result := Result{
+			RuleID:  msg.Source,
+			Level:   level(msg.Type),
+			Message: Message{Text: msg.Message},
+			Locations: []Location{{
+				PhysicalLocation: PhysicalLocation{
+					ArtifactLocation: ArtifactLocation{URI: uri, URIBaseID: SrcRoot},
+				},
+			}},
+		}
+		if msg.Line > 0 {
+			result.Locations[0].PhysicalLocation.Region = &Region{StartLine: msg.Line, StartColumn: msg.Column}
+		}
+		if msg.Source != "" {
+			b.ruleIDs[msg.Source] = true
+		}
+		b.files[path] = append(b.files[path], result)
+	}
+}
+
+// Log returns the log of the files added so far. Rules are sorted by ID and results by file,
+// so that the same report always produces the same log.
+func (b *Builder) Log() *Log {
+	driver := b.driver
+	driver.Rules = []Rule{}
+
+	ids := make([]string, 0, len(b.ruleIDs))
+	for id := range b.ruleIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	index := make(map[string]int)
+	for i, id := range ids {
+		driver.Rules = append(driver.Rules, Rule{ID: id})
+		index[id] = i
+	}
+
+	paths := make([]string, 0, len(b.files))
+	for path := range b.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	results := []Result{}
+	for _, path := range paths {
+		for _, result := range b.files[path] {
+			if i, ok := index[result.RuleID]; ok {
+				result.RuleIndex = &i
+			}
+			results = append(results, result)
+		}
+	}
+
+	return &Log{
+		Schema:  Schema,
+		Version: Version,
+		Runs:    []Run{{Tool: Tool{Driver: driver}, Results: results}},
+	}
+}
+
+// Convert converts a report into a log of the tool.
+func Convert(driver Driver, results tide.PhpcsResults) *Log {
+	b := NewBuilder(driver)
+	for path, file := range results.Files {
+		b.Add(path, file.Messages)
+	}
+	return b.Log()
+}
+
+// Emitter emits reports as logs of the driver. The report package registers it for the "sarif"
+// format.
+type Emitter struct {
+	Driver Driver
+}
+
+// Extension implements report.Emitter.
+func (Emitter) Extension() string { return "json" }
+
+// Emit implements report.Emitter.
+func (e Emitter) Emit(results tide.PhpcsResults) ([]byte, error) {
+	return json.Marshal(Convert(e.Driver, results))
+}
+
+// level returns the SARIF level of a message type, e.g. "error" for "ERROR".
+func level(messageType string) string {
+	switch strings.ToUpper(messageType) {
+	case "ERROR":
+		return "error"
+	case "WARNING":
+		return "warning"
+	}
+	return "note"
+}
@@ -0,0 +1,109 @@
+package sarif
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+func testResults() tide.PhpcsResults {
+	results := tide.PhpcsResults{}
+	results.Totals.Errors = 2
+	results.Totals.Warnings = 1
+	results.Files = map[string]struct {
+		Errors   int                      `json:"errors, omitempty"`
+		Warnings int                      `json:"warnings,omitempty"`
+		Messages []tide.PhpcsFilesMessage `json:"messages,omitempty"`
+	}{
+		"plugin.php": {
+			Errors: 2,
+			Messages: []tide.PhpcsFilesMessage{
+				{Message: "Not escaped", Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "ERROR", Line: 3, Column: 7},
+				{Message: "Syntax", Source: "Generic.PHP.Syntax.PHPSyntax", Type: "ERROR"},
+			},
+		},
+		"inc/admin.php": {
+			Warnings: 1,
+			Messages: []tide.PhpcsFilesMessage{
+				{Message: "Not escaped", Source: "WordPress.Security.EscapeOutput.OutputNotEscaped", Type: "WARNING", Line: 12, Column: 1},
+			},
+		},
+		"readme.php": {},
+	}
+	return results
+}
+
+func TestConvert(t *testing.T) {
+	log := Convert(Driver{Name: "PHP_CodeSniffer", Version: "3.3.1"}, testResults())
+
+	if log.Version != Version || log.Schema != Schema || len(log.Runs) != 1 {
+		t.Fatalf("Convert() = %v", log)
+	}
+
+	driver := log.Runs[0].Tool.Driver
+	wantRules := []Rule{{ID: "Generic.PHP.Syntax.PHPSyntax"}, {ID: "WordPress.Security.EscapeOutput.OutputNotEscaped"}}
+	if driver.Name != "PHP_CodeSniffer" || driver.Version != "3.3.1" || !reflect.DeepEqual(driver.Rules, wantRules) {
+		t.Errorf("Convert() driver = %v, want rules %v", driver, wantRules)
+	}
+
+	zero, one := 0, 1
+	location := func(uri string, region *Region) []Location {
+		return []Location{{PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{URI: uri, URIBaseID: SrcRoot},
+			Region:           region,
+		}}}
+	}
+	want := []Result{
+		{RuleID: "WordPress.Security.EscapeOutput.OutputNotEscaped", RuleIndex: &one, Level: "warning", Message: Message{Text: "Not escaped"}, Locations: location("inc/admin.php", &Region{StartLine: 12, StartColumn: 1})},
+		{RuleID: "WordPress.Security.EscapeOutput.OutputNotEscaped", RuleIndex: &one, Level: "error", Message: Message{Text: "Not escaped"}, Locations: location("plugin.php", &Region{StartLine: 3, StartColumn: 7})},
+		{RuleID: "Generic.PHP.Syntax.PHPSyntax", RuleIndex: &zero, Level: "error", Message: Message{Text: "Syntax"}, Locations: location("plugin.php", nil)},
+	}
+	if got := log.Runs[0].Results; !reflect.DeepEqual(got, want) {
+		t.Errorf("Convert() results = %+v, want %+v", got, want)
+	}
+}
+
+func TestConvert_Empty(t *testing.T) {
+	data, err := json.Marshal(Convert(Driver{Name: "ESLint"}, tide.PhpcsResults{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Consumers require the results array, even without results.
+	want := `{"$schema":"` + Schema + `","version":"2.1.0","runs":[{"tool":{"driver":{"name":"ESLint"}},"results":[]}]}`
+	if string(data) != want {
+		t.Errorf("Convert() = %s, want %s", data, want)
+	}
+}
+
+func TestEmitter(t *testing.T) {
+	e := Emitter{Driver: Driver{Name: "PHP_CodeSniffer"}}
+	if got := e.Extension(); got != "json" {
+		t.Errorf("Emitter.Extension() = %v, want json", got)
+	}
+
+	got, err := e.Emit(testResults())
+	if err != nil {
+		t.Fatalf("Emitter.Emit() error = %v", err)
+	}
+	want, _ := json.Marshal(Convert(e.Driver, testResults()))
+	if string(got) != string(want) {
+		t.Errorf("Emitter.Emit() = %s, want %s", got, want)
+	}
+}
+
+func TestBuilder_Add(t *testing.T) {
+	b := NewBuilder(Driver{Name: "PHP_CodeSniffer"})
+	b.Add(`\inc\admin.php`, []tide.PhpcsFilesMessage{{Message: "Custom", Type: "INFO", Line: 1}})
+
+	results := b.Log().Runs[0].Results
+	if len(results) != 1 {
+		t.Fatalf("Builder.Log() results = %v", results)
+	}
+	got := results[0]
+	if got.Level != "note" || got.RuleIndex != nil || got.RuleID != "" || got.Locations[0].PhysicalLocation.ArtifactLocation.URI != "inc/admin.php" {
+		t.Errorf("Builder.Add() = %+v", got)
+	}
+}
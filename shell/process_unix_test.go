@@ -0,0 +1,70 @@
+//go:build !windows
+// +build !windows
+
+package shell
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommand_RunContext_KillsChildren(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("needs /proc to check the child process")
+	}
+
+	dir, err := ioutil.TempDir("", "shell")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "child.pid")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		// Stop the command once it started its child.
+		for {
+			if _, err := os.Stat(pidFile); err == nil {
+				time.Sleep(10 * time.Millisecond)
+				cancel()
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+
+	c := &Command{execFunc: mockExecCommand}
+	if _, _, _, err := c.RunContext(ctx, "test-fork", pidFile); err != context.Canceled {
+		t.Fatalf("Command.RunContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	data, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, _ := strconv.Atoi(string(data))
+
+	// The child is gone, or a zombie that is waiting to be reaped.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		stat, err := ioutil.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Command.RunContext() did not kill the child process %v", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package shell
+
+import "os/exec"
+
+// setProcessGroup is not supported on Windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// kill kills the started command.
+func kill(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
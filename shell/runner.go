@@ -2,9 +2,13 @@ package shell
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"os/exec"
+	"os/user"
+	"strconv"
 	"sync"
-	"syscall"
+	"time"
 )
 
 // Runner implements an interface for running a shell command.
@@ -12,14 +16,82 @@ type Runner interface {
 	Run(name string, arg ...string) ([]byte, []byte, int, error)
 }
 
-// Command implements Runner.
+// MeteredRunner is implemented by runners that also report the CPU time used by a command,
+// e.g. to attribute the cost of an audit.
+type MeteredRunner interface {
+	RunMetered(name string, arg ...string) ([]byte, []byte, int, time.Duration, error)
+}
+
+// ContextRunner is implemented by runners that stop a command when the context is done,
+// e.g. when the pipeline is cancelled while a long-running audit is in progress.
+type ContextRunner interface {
+	RunContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, error)
+}
+
+// MeteredContextRunner is implemented by runners that are both a MeteredRunner and a
+// ContextRunner.
+type MeteredContextRunner interface {
+	RunMeteredContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, time.Duration, error)
+}
+
+// Command implements Runner, MeteredRunner, ContextRunner and MeteredContextRunner.
 type Command struct {
+	User     *User // (Optional) Runs the commands as this user instead of the worker's user.
 	execFunc func(name string, arg ...string) *exec.Cmd
 	once     sync.Once
 }
 
+// User is the identity that commands are run as, e.g. a dedicated low-privilege user, so that
+// analyzed code or buggy tooling can't modify the files owned by the worker. The user needs read
+// access to the audited files and write access to the folders the command writes reports to.
+//
+// Starting commands as another user requires the worker to run as root or with CAP_SETUID and
+// CAP_SETGID, and is not supported on Windows.
+type User struct {
+	UID    uint32
+	GID    uint32
+	Groups []uint32 // (Optional) Supplementary groups. The worker's groups are dropped if empty.
+}
+
+// LookupUser returns the User for a user name, e.g. "tide-sandbox".
+func LookupUser(name string) (*User, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, errors.New("user " + name + " does not have a numeric uid")
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, errors.New("user " + name + " does not have a numeric gid")
+	}
+
+	return &User{UID: uint32(uid), GID: uint32(gid)}, nil
+}
+
 // Run executes the shell command.
 func (c *Command) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	return c.RunContext(context.Background(), name, arg...)
+}
+
+// RunMetered executes the shell command and also returns the user and system CPU time it used.
+func (c *Command) RunMetered(name string, arg ...string) ([]byte, []byte, int, time.Duration, error) {
+	return c.RunMeteredContext(context.Background(), name, arg...)
+}
+
+// RunContext executes the shell command and kills it when the context is done.
+func (c *Command) RunContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, error) {
+	out, errOut, exitCode, _, err := c.RunMeteredContext(ctx, name, arg...)
+	return out, errOut, exitCode, err
+}
+
+// RunMeteredContext executes the shell command, kills it and the processes it started when the
+// context is done and returns the user and system CPU time it used. The error is the error of
+// the context if the command was killed.
+func (c *Command) RunMeteredContext(ctx context.Context, name string, arg ...string) ([]byte, []byte, int, time.Duration, error) {
 
 	c.once.Do(func() {
 		if c.execFunc == nil {
@@ -27,20 +99,49 @@ func (c *Command) Run(name string, arg ...string) ([]byte, []byte, int, error) {
 		}
 	})
 
+	if err := ctx.Err(); err != nil {
+		return nil, nil, 0, 0, err
+	}
+
 	resultsBuffer := bytes.Buffer{}
 	errorsBuffer := bytes.Buffer{}
 	cmd := c.execFunc(name, arg...)
 	cmd.Stdout = &resultsBuffer
 	cmd.Stderr = &errorsBuffer
+	// Don't wait for children of a killed command that still hold its output open.
+	cmd.WaitDelay = time.Second
+	setProcessGroup(cmd)
 
-	exitCode := 0
-	exitErr := cmd.Run()
-
-	if exitErr, ok := exitErr.(*exec.ExitError); ok {
-		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
-			exitCode = status.ExitStatus()
+	if c.User != nil {
+		if err := setUser(cmd, c.User); err != nil {
+			return nil, nil, 0, 0, err
 		}
 	}
 
-	return resultsBuffer.Bytes(), errorsBuffer.Bytes(), exitCode, exitErr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, 0, 0, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var exitErr error
+	select {
+	case exitErr = <-done:
+	case <-ctx.Done():
+		kill(cmd)
+		<-done
+		exitErr = ctx.Err()
+	}
+
+	exitCode := 0
+	var cpu time.Duration
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+		cpu = cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+	}
+
+	return resultsBuffer.Bytes(), errorsBuffer.Bytes(), exitCode, cpu, exitErr
 }
@@ -1,11 +1,15 @@
 package shell
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func mockExecCommand(command string, args ...string) *exec.Cmd {
@@ -105,6 +109,46 @@ func TestCommand_Run(t *testing.T) {
 	}
 }
 
+func TestCommand_RunMetered(t *testing.T) {
+	c := &Command{execFunc: mockExecCommand}
+
+	out, _, exitCode, cpu, err := c.RunMetered("test-exit")
+	if err == nil || exitCode != 22 || string(out) != "Exit!" {
+		t.Errorf("Command.RunMetered() = %v, %v, %v", string(out), exitCode, err)
+	}
+	if cpu <= 0 {
+		t.Errorf("Command.RunMetered() cpu = %v, want the CPU time of the command", cpu)
+	}
+
+	if _, _, _, cpu, err := (&Command{}).RunMetered(""); err == nil || cpu != 0 {
+		t.Errorf("Command.RunMetered() = %v, %v for a command that could not start", cpu, err)
+	}
+}
+
+func TestCommand_RunContext(t *testing.T) {
+	c := &Command{execFunc: mockExecCommand}
+
+	out, _, exitCode, err := c.RunContext(context.Background(), "test-success")
+	if err != nil || exitCode != 0 || string(out) != "Success!" {
+		t.Errorf("Command.RunContext() = %v, %v, %v", string(out), exitCode, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, _, _, err := c.RunContext(ctx, "test-sleep"); err != context.DeadlineExceeded {
+		t.Errorf("Command.RunContext() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Command.RunContext() took %v to stop the command", elapsed)
+	}
+
+	if _, _, _, err := c.RunContext(ctx, "test-success"); err != context.DeadlineExceeded {
+		t.Errorf("Command.RunContext() error = %v for a done context", err)
+	}
+}
+
 // TestHelperProcess is the fake command.
 func TestHelperProcess(t *testing.T) {
 	// If the helper process var is not set this code should not run.
@@ -140,6 +184,18 @@ func TestHelperProcess(t *testing.T) {
 	case "test-fail":
 		fmt.Fprintf(os.Stderr, "Failed!")
 		os.Exit(0)
+	case "test-sleep":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "test-fork":
+		// Start a child that outlives the command unless it is killed, and write its pid to the file.
+		child := mockExecCommand("test-sleep")
+		if err := child.Start(); err != nil {
+			os.Exit(1)
+		}
+		ioutil.WriteFile(args[0], []byte(strconv.Itoa(child.Process.Pid)), 0644)
+		time.Sleep(time.Minute)
+		os.Exit(0)
 	case "test-exit":
 		fmt.Fprintf(os.Stdout, "Exit!")
 		os.Exit(22)
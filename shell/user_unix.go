@@ -0,0 +1,24 @@
+//go:build !windows
+// +build !windows
+
+package shell
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setUser makes the command start as the user.
+func setUser(cmd *exec.Cmd, u *User) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Credential = &syscall.Credential{
+		Uid:    u.UID,
+		Gid:    u.GID,
+		Groups: u.Groups,
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+//go:build !windows
+// +build !windows
+
+package shell
+
+import (
+	"os/exec"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestCommand_RunAsUser(t *testing.T) {
+	var cmd *exec.Cmd
+	c := &Command{
+		User: &User{UID: 1001, GID: 1002, Groups: []uint32{1003}},
+		execFunc: func(name string, arg ...string) *exec.Cmd {
+			cmd = mockExecCommand(name, arg...)
+			// Fail to start the command, switching users requires privileges.
+			cmd.Path = ""
+			return cmd
+		},
+	}
+
+	c.Run("test-success")
+
+	want := &syscall.Credential{Uid: 1001, Gid: 1002, Groups: []uint32{1003}}
+	if cmd.SysProcAttr == nil || !reflect.DeepEqual(cmd.SysProcAttr.Credential, want) {
+		t.Errorf("Command.Run() credential = %v, want %v", cmd.SysProcAttr, want)
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		want    *User
+		wantErr bool
+	}{
+		{"Root", "root", &User{UID: 0, GID: 0}, false},
+		{"Unknown User", "tide-user-does-not-exist", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LookupUser(tt.user)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LookupUser() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LookupUser() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
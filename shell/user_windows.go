@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package shell
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// setUser is not supported on Windows.
+func setUser(cmd *exec.Cmd, u *User) error {
+	return errors.New("running commands as another user is not supported on windows")
+}
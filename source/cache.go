@@ -0,0 +1,451 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheEntry describes a source in a CacheProvider.
+type CacheEntry struct {
+	Checksum  string            `json:"checksum"`
+	Files     []string          `json:"files"` // Paths relative to the folder the source was prepared in, in the order of the source.
+	Anomalies []Anomaly         `json:"anomalies,omitempty"`
+	Checksums map[string]string `json:"checksums,omitempty"` // Checksums of the files, by the paths in Files.
+}
+
+// CacheProvider caches prepared sources, so that re-audits of the same version skip the download
+// and extraction. Entries are found by key, e.g. a CacheKey, and the files are stored by their
+// combined checksum, so that identical sources at different urls are only stored once.
+type CacheProvider interface {
+	// Get copies the cached files of the key into root and returns the entry.
+	// It returns nil if the key is not cached.
+	Get(key, root string) (*CacheEntry, error)
+
+	// Put caches the files of the entry, which are relative to root, under the key.
+	Put(key, root string, entry CacheEntry) error
+}
+
+// CacheKey returns the key of the source at the url with the validator (ETag or Last-Modified)
+// returned by Validator.
+func CacheKey(url, validator string) string {
+	sum := sha256.Sum256([]byte(url + "\n" + validator))
+	return hex.EncodeToString(sum[:])
+}
+
+// Validator returns the ETag of the file at the url, or else its Last-Modified date, using a
+// HEAD request. It returns an empty string if the server provides neither, e.g. for generated
+// files, which must not be cached by url.
+func Validator(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequest(http.MethodHead, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", errors.New("unexpected status code: " + resp.Status)
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag, nil
+	}
+	return resp.Header.Get("Last-Modified"), nil
+}
+
+// Cached is a source that restores the files from a cache if the source at the url has not
+// changed since it was cached, and prepares and caches them otherwise. Sources that are not
+// served over HTTP, or without a validator, are always prepared.
+type Cached struct {
+	Source  Source
+	Cache   CacheProvider
+	URL     string
+	Context context.Context // (Optional) Cancels the validator request.
+
+	hit       bool
+	checksum  string
+	files     []string
+	checksums map[string]string
+	anomalies []Anomaly
+	timings   map[string]time.Duration
+}
+
+// NewCached returns a new Cached source for the url.
+func NewCached(src Source, cache CacheProvider, url string) *Cached {
+	return &Cached{
+		Source: src,
+		Cache:  cache,
+		URL:    url,
+	}
+}
+
+// PrepareFiles restores or prepares the files in the destination. Failures of the cache
+// don't fail the source: the files are prepared as if they were not cached.
+func (c *Cached) PrepareFiles(dest string) error {
+	c.hit = false
+
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	key := ""
+	if validator, err := Validator(ctx, c.URL); err == nil && validator != "" {
+		key = CacheKey(c.URL, validator)
+	}
+
+	if key != "" {
+		started := time.Now()
+		entry, err := c.Cache.Get(key, dest)
+		if err == nil && entry != nil {
+			c.hit = true
+			c.checksum = entry.Checksum
+			c.anomalies = entry.Anomalies
+			c.files = make([]string, len(entry.Files))
+			c.checksums = make(map[string]string, len(entry.Checksums))
+			for i, file := range entry.Files {
+				c.files[i] = filepath.Join(dest, filepath.FromSlash(file))
+				if sum, ok := entry.Checksums[file]; ok {
+					c.checksums[c.files[i]] = sum
+				}
+			}
+			c.timings = map[string]time.Duration{"cache": time.Since(started)}
+			return nil
+		}
+	}
+
+	if err := c.Source.PrepareFiles(dest); err != nil {
+		return err
+	}
+
+	if key != "" {
+		if entry, err := c.entry(dest); err == nil {
+			c.Cache.Put(key, dest, entry)
+		}
+	}
+
+	return nil
+}
+
+// Hit determines if the files were restored from the cache.
+func (c Cached) Hit() bool {
+	return c.hit
+}
+
+// GetChecksum returns the combined checksum of the files.
+func (c Cached) GetChecksum() string {
+	if c.hit {
+		return c.checksum
+	}
+	return c.Source.GetChecksum()
+}
+
+// GetFiles returns the files of the source.
+func (c Cached) GetFiles() []string {
+	if c.hit {
+		return c.files
+	}
+	return c.Source.GetFiles()
+}
+
+// GetFileChecksums returns the checksums of the files, if the source reports them.
+func (c Cached) GetFileChecksums() map[string]string {
+	if c.hit {
+		return c.checksums
+	}
+	if reporter, ok := c.Source.(ChecksumReporter); ok {
+		return reporter.GetFileChecksums()
+	}
+	return nil
+}
+
+// GetAnomalies returns the file names that had to be normalized, including those of cached sources.
+func (c Cached) GetAnomalies() []Anomaly {
+	if c.hit {
+		return c.anomalies
+	}
+	if reporter, ok := c.Source.(AnomalyReporter); ok {
+		return reporter.GetAnomalies()
+	}
+	return nil
+}
+
+// GetTimings returns how long restoring the files from the cache, or preparing them, took.
+func (c Cached) GetTimings() map[string]time.Duration {
+	if c.hit {
+		return c.timings
+	}
+	if reporter, ok := c.Source.(TimingReporter); ok {
+		return reporter.GetTimings()
+	}
+	return nil
+}
+
+// GetDownloaded returns the bytes downloaded by the source, nothing for cached sources.
+func (c Cached) GetDownloaded() int64 {
+	if reporter, ok := c.Source.(DownloadReporter); ok && !c.hit {
+		return reporter.GetDownloaded()
+	}
+	return 0
+}
+
+// entry returns the cache entry of the prepared source.
+func (c Cached) entry(dest string) (CacheEntry, error) {
+	entry := CacheEntry{Checksum: c.Source.GetChecksum()}
+	if entry.Checksum == "" {
+		return entry, errors.New("source has no checksum")
+	}
+
+	for _, file := range c.Source.GetFiles() {
+		rel, err := filepath.Rel(dest, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return entry, errors.New("file " + file + " is outside of " + dest)
+		}
+		entry.Files = append(entry.Files, filepath.ToSlash(rel))
+	}
+
+	if reporter, ok := c.Source.(ChecksumReporter); ok {
+		entry.Checksums = make(map[string]string)
+		for file, sum := range reporter.GetFileChecksums() {
+			if rel, err := filepath.Rel(dest, file); err == nil {
+				entry.Checksums[filepath.ToSlash(rel)] = sum
+			}
+		}
+	}
+
+	if reporter, ok := c.Source.(AnomalyReporter); ok {
+		entry.Anomalies = reporter.GetAnomalies()
+	}
+
+	return entry, nil
+}
+
+// FileCache is a CacheProvider that stores the sources in a folder, e.g. on a volume that
+// is shared by the workers of a host.
+//
+// The folder has the entries in "keys", as JSON, and the files in "sources/<checksum>".
+type FileCache struct {
+	Dir string // Folder of the cache.
+}
+
+// Get implements CacheProvider.
+func (f FileCache) Get(key, root string) (*CacheEntry, error) {
+	data, err := ioutil.ReadFile(f.keyPath(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	src, err := f.sourcePath(entry.Checksum)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	for _, file := range entry.Files {
+		if err := copyCachedFile(filepath.Join(src, filepath.FromSlash(file)), root, file); err != nil {
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+// Put implements CacheProvider. The files are copied to a temporary folder first, so that
+// other workers never see a partially written source.
+func (f FileCache) Put(key, root string, entry CacheEntry) error {
+	src, err := f.sourcePath(entry.Checksum)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+			return err
+		}
+		tmp, err := ioutil.TempDir(filepath.Dir(src), ".tmp-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+
+		for _, file := range entry.Files {
+			if err := copyCachedFile(filepath.Join(root, filepath.FromSlash(file)), tmp, file); err != nil {
+				return err
+			}
+		}
+
+		// Another worker may have cached the same source in the meantime.
+		if err := os.Rename(tmp, src); err != nil {
+			if _, statErr := os.Stat(src); statErr != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.keyPath(key), data)
+}
+
+// keyPath returns the path of the entry of a key.
+func (f FileCache) keyPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.Dir, "keys", hex.EncodeToString(sum[:])+".json")
+}
+
+// sourcePath returns the folder of the files with the checksum.
+func (f FileCache) sourcePath(checksum string) (string, error) {
+	if checksum == "" || checksum != filepath.Base(checksum) || strings.HasPrefix(checksum, ".") {
+		return "", errors.New("invalid checksum: " + checksum)
+	}
+	return filepath.Join(f.Dir, "sources", checksum), nil
+}
+
+// MemoryCache is a CacheProvider that keeps the sources in memory, for workers that audit
+// the same small projects repeatedly, and for tests.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]CacheEntry
+	sources map[string]map[string][]byte // Contents of the files by checksum and path.
+}
+
+// Get implements CacheProvider.
+func (m *MemoryCache) Get(key, root string) (*CacheEntry, error) {
+	m.mu.Lock()
+	entry, ok := m.entries[key]
+	files := m.sources[entry.Checksum]
+	m.mu.Unlock()
+
+	if !ok || files == nil {
+		return nil, nil
+	}
+
+	for _, file := range entry.Files {
+		path, err := ContainedPath(root, file)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path, files[file], 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+// Put implements CacheProvider.
+func (m *MemoryCache) Put(key, root string, entry CacheEntry) error {
+	m.mu.Lock()
+	_, cached := m.sources[entry.Checksum]
+	m.mu.Unlock()
+
+	var files map[string][]byte
+	if !cached {
+		files = make(map[string][]byte)
+		for _, file := range entry.Files {
+			data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(file)))
+			if err != nil {
+				return err
+			}
+			files[file] = data
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.entries == nil {
+		m.entries = make(map[string]CacheEntry)
+		m.sources = make(map[string]map[string][]byte)
+	}
+	if files != nil {
+		m.sources[entry.Checksum] = files
+	}
+	m.entries[key] = entry
+
+	return nil
+}
+
+// copyCachedFile copies the file at src to the path name in the folder root.
+func copyCachedFile(src, root, name string) error {
+	path, err := ContainedPath(root, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it to filename.
+func writeFileAtomic(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
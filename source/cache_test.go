@@ -0,0 +1,252 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fileSource writes its files to the destination and counts how often it was prepared.
+type fileSource struct {
+	files    map[string]string
+	checksum string
+	prepared int
+	paths    []string
+}
+
+func (s *fileSource) PrepareFiles(dest string) error {
+	s.prepared++
+	s.paths = nil
+	for _, name := range []string{"unzipped/plugin.php", "unzipped/inc/admin.php"} {
+		path := filepath.Join(dest, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(s.files[name]), 0644); err != nil {
+			return err
+		}
+		s.paths = append(s.paths, path)
+	}
+	return nil
+}
+func (s *fileSource) GetChecksum() string { return s.checksum }
+func (s *fileSource) GetFiles() []string  { return s.paths }
+func (s *fileSource) GetFileChecksums() map[string]string {
+	return FileChecksums(s.paths, []string{"sum-plugin", "sum-admin"})
+}
+func (s *fileSource) GetAnomalies() []Anomaly {
+	return []Anomaly{{Name: "plugin/aux.php", Path: "plugin/_aux.php", Reasons: []string{ReasonReserved}}}
+}
+func (s *fileSource) GetTimings() map[string]time.Duration {
+	return map[string]time.Duration{"download": time.Second}
+}
+
+func TestValidator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/etag.zip":
+			w.Header().Set("ETag", `"abc"`)
+			w.Header().Set("Last-Modified", "Fri, 01 Jun 2018 00:00:00 GMT")
+		case "/modified.zip":
+			w.Header().Set("Last-Modified", "Fri, 01 Jun 2018 00:00:00 GMT")
+		case "/missing.zip":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{"ETag", server.URL + "/etag.zip", `"abc"`, false},
+		{"Last-Modified", server.URL + "/modified.zip", "Fri, 01 Jun 2018 00:00:00 GMT", false},
+		{"No Validator", server.URL + "/generated.zip", "", false},
+		{"Not Found", server.URL + "/missing.zip", "", true},
+		{"Not HTTP", "svn://plugins.svn.wordpress.org/plugin", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Validator(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validator() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Validator() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheProviders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	root := filepath.Join(dir, "prepared")
+	os.MkdirAll(filepath.Join(root, "unzipped/inc"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "unzipped/plugin.php"), []byte("<?php // plugin"), 0644)
+	ioutil.WriteFile(filepath.Join(root, "unzipped/inc/admin.php"), []byte("<?php // admin"), 0644)
+
+	entry := CacheEntry{
+		Checksum: "abc123",
+		Files:    []string{"unzipped/plugin.php", "unzipped/inc/admin.php"},
+	}
+
+	tests := []struct {
+		name  string
+		cache CacheProvider
+	}{
+		{"File", FileCache{Dir: filepath.Join(dir, "cache")}},
+		{"Memory", &MemoryCache{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restored := filepath.Join(dir, "restored-"+tt.name)
+
+			if got, err := tt.cache.Get("key", restored); got != nil || err != nil {
+				t.Fatalf("Get() = %v, %v before Put()", got, err)
+			}
+
+			if err := tt.cache.Put("key", root, entry); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+			// Another url with the same source.
+			if err := tt.cache.Put("other", root, entry); err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			for _, key := range []string{"key", "other"} {
+				got, err := tt.cache.Get(key, restored)
+				if err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+				if !reflect.DeepEqual(*got, entry) {
+					t.Errorf("Get() = %v, want %v", *got, entry)
+				}
+			}
+
+			data, err := ioutil.ReadFile(filepath.Join(restored, "unzipped/inc/admin.php"))
+			if err != nil || string(data) != "<?php // admin" {
+				t.Errorf("Get() restored %q, error = %v", data, err)
+			}
+		})
+	}
+
+	// Checksums are folder names of file caches.
+	if err := (FileCache{Dir: dir}).Put("key", root, CacheEntry{Checksum: "../abc"}); err == nil {
+		t.Error("FileCache.Put() expected an error for an invalid checksum")
+	}
+}
+
+func TestCached_PrepareFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/plugin.zip" {
+			w.Header().Set("ETag", etag)
+		}
+	}))
+	defer server.Close()
+
+	cache := &MemoryCache{}
+	src := &fileSource{
+		checksum: "abc123",
+		files: map[string]string{
+			"unzipped/plugin.php":    "<?php // plugin",
+			"unzipped/inc/admin.php": "<?php // admin",
+		},
+	}
+
+	prepare := func(url, dest string) *Cached {
+		c := NewCached(src, cache, url)
+		if err := c.PrepareFiles(dest); err != nil {
+			t.Fatalf("Cached.PrepareFiles() error = %v", err)
+		}
+		return c
+	}
+
+	// The first audit prepares the source.
+	c := prepare(server.URL+"/plugin.zip", filepath.Join(dir, "first"))
+	if c.Hit() || src.prepared != 1 || c.GetTimings()["download"] == 0 {
+		t.Errorf("Cached.PrepareFiles() hit = %v, prepared = %v", c.Hit(), src.prepared)
+	}
+
+	// The second audit restores it.
+	dest := filepath.Join(dir, "second")
+	c = prepare(server.URL+"/plugin.zip", dest)
+	if !c.Hit() || src.prepared != 1 {
+		t.Errorf("Cached.PrepareFiles() hit = %v, prepared = %v", c.Hit(), src.prepared)
+	}
+	wantFiles := []string{filepath.Join(dest, "unzipped/plugin.php"), filepath.Join(dest, "unzipped/inc/admin.php")}
+	if !reflect.DeepEqual(c.GetFiles(), wantFiles) {
+		t.Errorf("Cached.GetFiles() = %v, want %v", c.GetFiles(), wantFiles)
+	}
+	wantChecksums := map[string]string{wantFiles[0]: "sum-plugin", wantFiles[1]: "sum-admin"}
+	if !reflect.DeepEqual(c.GetFileChecksums(), wantChecksums) {
+		t.Errorf("Cached.GetFileChecksums() = %v, want %v", c.GetFileChecksums(), wantChecksums)
+	}
+	if c.GetChecksum() != "abc123" || len(c.GetAnomalies()) != 1 || c.GetDownloaded() != 0 {
+		t.Errorf("Cached checksum = %v, anomalies = %v", c.GetChecksum(), c.GetAnomalies())
+	}
+	if _, ok := c.GetTimings()["cache"]; !ok {
+		t.Errorf("Cached.GetTimings() = %v", c.GetTimings())
+	}
+	if _, err := os.Stat(wantFiles[1]); err != nil {
+		t.Errorf("Cached.PrepareFiles() did not restore %v", wantFiles[1])
+	}
+
+	// A new version is prepared again.
+	etag = `"v2"`
+	if c = prepare(server.URL+"/plugin.zip", filepath.Join(dir, "third")); c.Hit() || src.prepared != 2 {
+		t.Errorf("Cached.PrepareFiles() hit = %v, prepared = %v", c.Hit(), src.prepared)
+	}
+
+	// Sources without a validator are never cached.
+	prepare(server.URL+"/generated.zip", filepath.Join(dir, "fourth"))
+	if c = prepare(server.URL+"/generated.zip", filepath.Join(dir, "fifth")); c.Hit() || src.prepared != 4 {
+		t.Errorf("Cached.PrepareFiles() hit = %v, prepared = %v", c.Hit(), src.prepared)
+	}
+}
+
+// failingCache fails every operation.
+type failingCache struct{}
+
+func (failingCache) Get(key, root string) (*CacheEntry, error) { return nil, errors.New("cache error") }
+func (failingCache) Put(key, root string, entry CacheEntry) error {
+	return errors.New("cache error")
+}
+
+func TestCached_PrepareFiles_CacheError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cached")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+	}))
+	defer server.Close()
+
+	src := &fileSource{checksum: "abc123"}
+	c := NewCached(src, failingCache{}, server.URL+"/plugin.zip")
+	if err := c.PrepareFiles(dir); err != nil || c.Hit() || src.prepared != 1 {
+		t.Errorf("Cached.PrepareFiles() error = %v, hit = %v, prepared = %v", err, c.Hit(), src.prepared)
+	}
+}
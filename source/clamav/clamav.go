@@ -0,0 +1,113 @@
+// Package clamav scans source archives for malware with a ClamAV daemon (clamd).
+package clamav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Default connection settings.
+const (
+	DefaultNetwork   = "tcp"
+	DefaultAddress   = "127.0.0.1:3310"
+	DefaultTimeout   = 2 * time.Minute
+	DefaultChunkSize = 64 * 1024
+)
+
+// Using os.Open as a variable so that we can mock it in tests.
+var openFile = os.Open
+
+// Clamd is a source.Scanner that streams archives to clamd with the INSTREAM command.
+// The archive is streamed, so clamd does not need access to the worker's file system.
+//
+// Archives that are larger than clamd's StreamMaxLength can't be scanned and return an error.
+type Clamd struct {
+	Network   string        // (Optional) "tcp" or "unix". Defaults to DefaultNetwork.
+	Address   string        // (Optional) Address of clamd, e.g. "/run/clamav/clamd.ctl". Defaults to DefaultAddress.
+	Timeout   time.Duration // (Optional) Time to connect and scan an archive. Defaults to DefaultTimeout.
+	ChunkSize int           // (Optional) Bytes per chunk sent to clamd. Defaults to DefaultChunkSize.
+}
+
+// Scan implements source.Scanner. It returns the signature name reported by clamd, e.g.
+// "Win.Test.EICAR_HDB-1", or an empty string if the archive is clean.
+func (c Clamd) Scan(path string) (string, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	network, address, timeout, chunkSize := c.Network, c.Address, c.Timeout, c.ChunkSize
+	if network == "" {
+		network = DefaultNetwork
+	}
+	if address == "" {
+		address = DefaultAddress
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	conn, err := net.DialTimeout(network, address, timeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", err
+	}
+
+	// Each chunk is prefixed with its length, a zero length ends the stream.
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := file.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				// clamd closes the connection once the size limit is exceeded, read its reply.
+				break
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	conn.Write([]byte{0, 0, 0, 0})
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", err
+	}
+
+	return parseReply(reply)
+}
+
+// parseReply returns the threat of a clamd reply, e.g. "stream: Win.Test.EICAR_HDB-1 FOUND".
+func parseReply(reply string) (string, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	case strings.HasSuffix(result, " ERROR"):
+		return "", errors.New("clamd: " + strings.TrimSuffix(result, " ERROR"))
+	}
+	return "", errors.New("clamd: unexpected reply: " + reply)
+}
@@ -0,0 +1,151 @@
+package clamav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeClamd accepts a single INSTREAM scan and replies with the reply function's answer for the
+// streamed bytes.
+func fakeClamd(t *testing.T, reply func(data []byte) string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		if command, err := r.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+			conn.Write([]byte("UNKNOWN COMMAND\x00"))
+			return
+		}
+
+		var data []byte
+		size := make([]byte, 4)
+		for {
+			if _, err := io.ReadFull(r, size); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size)
+			if n == 0 {
+				break
+			}
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			data = append(data, chunk...)
+		}
+
+		conn.Write([]byte(reply(data) + "\x00"))
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestClamd_Scan(t *testing.T) {
+	file, err := ioutil.TempFile("", "clamav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(strings.Repeat("archive ", 100))
+	file.Close()
+
+	tests := []struct {
+		name    string
+		reply   func(data []byte) string
+		want    string
+		wantErr bool
+	}{
+		{
+			"Clean",
+			func(data []byte) string {
+				if string(data) != strings.Repeat("archive ", 100) {
+					return "stream: unexpected data ERROR"
+				}
+				return "stream: OK"
+			},
+			"",
+			false,
+		},
+		{
+			"Infected",
+			func(data []byte) string { return "stream: Win.Test.EICAR_HDB-1 FOUND" },
+			"Win.Test.EICAR_HDB-1",
+			false,
+		},
+		{
+			"Size Limit",
+			func(data []byte) string { return "INSTREAM size limit exceeded. ERROR" },
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Small chunks make sure that the archive is streamed in several chunks.
+			c := Clamd{Address: fakeClamd(t, tt.reply), ChunkSize: 64}
+
+			got, err := c.Scan(file.Name())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Clamd.Scan() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("Clamd.Scan() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClamd_Scan_Errors(t *testing.T) {
+	if _, err := (Clamd{Address: "127.0.0.1:1"}).Scan(os.Args[0]); err == nil {
+		t.Error("Clamd.Scan() should fail without clamd")
+	}
+
+	openFile = func(name string) (*os.File, error) {
+		return nil, errors.New("file not found")
+	}
+	defer func() { openFile = os.Open }()
+
+	if _, err := (Clamd{}).Scan("missing.zip"); err == nil {
+		t.Error("Clamd.Scan() should fail without an archive")
+	}
+}
+
+func Test_parseReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    string
+		wantErr bool
+	}{
+		{"stream: OK\x00", "", false},
+		{"stream: Php.Malware.Agent-123 FOUND\x00", "Php.Malware.Agent-123", false},
+		{"stream: Can't allocate memory ERROR\x00", "", true},
+		{"PONG\x00", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			got, err := parseReply(tt.reply)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("parseReply() = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
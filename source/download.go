@@ -0,0 +1,110 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Provides a way to fail copying the downloaded file. Used for testing.
+var ioCopy = io.Copy
+
+// Download gets the file at the url and writes it to out, retrying failed attempts with an
+// exponential backoff. Retries resume the partial download with a range request if the server
+// supports it, and start over otherwise. Failed downloads return a *DownloadError.
+func Download(ctx context.Context, url string, out *os.File, options DownloadOptions) error {
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	backoff := options.Backoff
+	if backoff <= 0 {
+		backoff = DefaultDownloadBackoff
+	}
+
+	var err error
+	validator := ""
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		validator, retry, err = downloadAttempt(ctx, url, out, validator)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= options.Retries {
+			return &DownloadError{Err: err}
+		}
+
+		select {
+		case <-ctx.Done():
+			return &DownloadError{Err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// downloadAttempt writes the file to out, resuming after the bytes that are already in out if
+// the validator (ETag or Last-Modified) of the previous attempt is known. It returns the validator
+// of the file and whether a failed attempt should be retried.
+func downloadAttempt(ctx context.Context, url string, out *os.File, validator string) (string, bool, error) {
+	offset, err := out.Seek(0, io.SeekEnd)
+	if err != nil {
+		return validator, false, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return validator, false, err
+	}
+	req = req.WithContext(ctx)
+	if offset > 0 && validator != "" {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+
+	// Get file
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return validator, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && req.Header.Get("Range") != "":
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+			// Start over with the whole file.
+			return "", true, errors.New("unexpected content range: " + resp.Header.Get("Content-Range"))
+		}
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		// The whole file, e.g. because the server does not support ranges or the file changed.
+		if err := out.Truncate(0); err != nil {
+			return "", false, err
+		}
+		if _, err := out.Seek(0, io.SeekStart); err != nil {
+			return "", false, err
+		}
+
+		// Weak ETags can't be used to resume.
+		validator = resp.Header.Get("ETag")
+		if validator == "" || strings.HasPrefix(validator, "W/") {
+			validator = resp.Header.Get("Last-Modified")
+		}
+	default:
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+		return validator, retry, errors.New("unexpected status code: " + resp.Status)
+	}
+
+	// Write to file
+	if _, err := ioCopy(out, resp.Body); err != nil {
+		return validator, ctx.Err() == nil, err
+	}
+
+	return validator, false, nil
+}
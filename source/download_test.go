@@ -0,0 +1,124 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDownload(t *testing.T) {
+	want := bytes.Repeat([]byte("0123456789abcdef"), 1024)
+	modified := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// The server fails the first requests in different ways, then serves the file.
+	type attempt func(w http.ResponseWriter, r *http.Request)
+	truncated := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(want)))
+		w.Write(want[:len(want)/2])
+	}
+	unavailable := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	notFound := func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}
+	stalled := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}
+
+	tests := []struct {
+		name       string
+		attempts   []attempt
+		options    DownloadOptions
+		wantRanges []string
+		wantErr    bool
+	}{
+		{"Success", nil, DownloadOptions{}, []string{""}, false},
+		{"Resumed", []attempt{truncated}, DownloadOptions{Retries: 1}, []string{"", fmt.Sprintf("bytes=%d-", len(want)/2)}, false},
+		{"Retried", []attempt{unavailable, unavailable}, DownloadOptions{Retries: 2}, []string{"", "", ""}, false},
+		{"Retries Exhausted", []attempt{unavailable, unavailable}, DownloadOptions{Retries: 1}, []string{"", ""}, true},
+		{"Not Found", []attempt{notFound}, DownloadOptions{Retries: 1}, []string{""}, true},
+		{"Not Retried", []attempt{truncated}, DownloadOptions{}, []string{""}, true},
+		{"Timeout", []attempt{stalled}, DownloadOptions{Retries: 1, Timeout: 50 * time.Millisecond}, []string{""}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := ioutil.TempFile("", "download")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(out.Name())
+			defer out.Close()
+
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ranges = append(ranges, r.Header.Get("Range"))
+				if len(ranges) <= len(tt.attempts) {
+					tt.attempts[len(ranges)-1](w, r)
+					return
+				}
+				http.ServeContent(w, r, "test.zip", modified, bytes.NewReader(want))
+			}))
+
+			tt.options.Backoff = time.Millisecond
+			err = Download(context.Background(), server.URL+"/test.zip", out, tt.options)
+
+			// Wait for stalled handlers before reading the ranges.
+			server.Close()
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Download() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, ok := err.(*DownloadError); err != nil && !ok {
+				t.Errorf("Download() error type = %T, want *DownloadError", err)
+			}
+			if !reflect.DeepEqual(ranges, tt.wantRanges) {
+				t.Errorf("Download() ranges = %q, want %q", ranges, tt.wantRanges)
+			}
+
+			if got, _ := ioutil.ReadFile(out.Name()); !tt.wantErr && !bytes.Equal(got, want) {
+				t.Errorf("Download() wrote %v bytes, want %v bytes", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestDownload_FailCopy(t *testing.T) {
+	oldCopy := ioCopy
+	ioCopy = func(dst io.Writer, src io.Reader) (written int64, err error) {
+		return 0, errors.New("something went wrong")
+	}
+	defer func() {
+		ioCopy = oldCopy
+	}()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("contents"))
+	}))
+	defer server.Close()
+
+	out, err := ioutil.TempFile("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if err := Download(context.Background(), server.URL, out, DownloadOptions{}); err == nil {
+		t.Errorf("Download() error = %v, wantErr true", err)
+	}
+}
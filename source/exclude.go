@@ -0,0 +1,63 @@
+package source
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Excluded reports whether a file is excluded from the combined checksum by the options.
+// The name is the path of the file relative to the root of the source, using "/" separators.
+//
+// Patterns use path.Match syntax. A pattern without a "/" is matched against the base name and
+// every directory name, e.g. "*.mo" or "node_modules". Other patterns are matched against the
+// full path and its parent directories, e.g. "build/*.txt" or "assets/dist".
+func (o ChecksumOptions) Excluded(name string) bool {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	segments := strings.Split(name, "/")
+
+	for _, pattern := range o.Exclude {
+		pattern = strings.Trim(pattern, "/")
+		anywhere := !strings.Contains(pattern, "/")
+
+		for i := range segments {
+			candidate := strings.Join(segments[:i+1], "/")
+			if anywhere {
+				candidate = segments[i]
+			}
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// IncludedChecksums returns the checksums of the files that are not excluded by the options.
+// The checksums are in the same order as the files, which are below the root.
+func (o ChecksumOptions) IncludedChecksums(files, checksums []string, root string) []string {
+	if len(o.Exclude) == 0 {
+		return checksums
+	}
+
+	var included []string
+	for i, file := range files {
+		if rel, err := filepath.Rel(root, file); err == nil && o.Excluded(filepath.ToSlash(rel)) {
+			continue
+		}
+		included = append(included, checksums[i])
+	}
+	return included
+}
+
+// ValidateExclusions returns an error if any of the exclusion patterns is malformed.
+func (o ChecksumOptions) ValidateExclusions() error {
+	for _, pattern := range o.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.New("invalid checksum exclusion pattern: " + pattern)
+		}
+	}
+	return nil
+}
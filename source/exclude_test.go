@@ -0,0 +1,76 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestChecksumOptions_Excluded(t *testing.T) {
+	options := ChecksumOptions{
+		Exclude: []string{"*.mo", "node_modules", "build/*.txt", "/assets/dist/"},
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want bool
+	}{
+		{"Base Name", "languages/plugin-de_DE.mo", true},
+		{"Base Name At Root", "plugin.mo", true},
+		{"Other Extension", "languages/plugin-de_DE.po", false},
+		{"Directory Anywhere", "vendor/node_modules/lib/index.js", true},
+		{"Full Path", "build/timestamp.txt", true},
+		{"Full Path Nested", "src/build/timestamp.txt", false},
+		{"Parent Directory", "assets/dist/app.js", true},
+		{"Similar Directory", "assets/dist-old/app.js", false},
+		{"Leading Slash", "/build/timestamp.txt", true},
+		{"Included", "plugin.php", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := options.Excluded(tt.file); got != tt.want {
+				t.Errorf("ChecksumOptions.Excluded(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+
+	if (ChecksumOptions{}).Excluded("plugin.mo") {
+		t.Errorf("ChecksumOptions.Excluded() excluded a file without patterns")
+	}
+}
+
+func TestChecksumOptions_ValidateExclusions(t *testing.T) {
+	if err := (ChecksumOptions{Exclude: []string{"*.mo", "build/*"}}).ValidateExclusions(); err != nil {
+		t.Errorf("ChecksumOptions.ValidateExclusions() error = %v", err)
+	}
+	if err := (ChecksumOptions{Exclude: []string{"[a-"}}).ValidateExclusions(); err == nil {
+		t.Errorf("ChecksumOptions.ValidateExclusions() expected an error")
+	}
+}
+
+func TestChecksumOptions_IncludedChecksums(t *testing.T) {
+	files := []string{
+		"dest/unzipped/plugin.php",
+		"dest/unzipped/languages/plugin.mo",
+		"dest/unzipped/build/timestamp.txt",
+	}
+	checksums := []string{"a", "b", "c"}
+
+	tests := []struct {
+		name    string
+		exclude []string
+		want    []string
+	}{
+		{"No Exclusions", nil, []string{"a", "b", "c"}},
+		{"Excluded Files", []string{"*.mo", "build/timestamp.txt"}, []string{"a"}},
+		{"No Matches", []string{"*.js"}, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := ChecksumOptions{Exclude: tt.exclude}
+			if got := options.IncludedChecksums(files, checksums, "dest/unzipped"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChecksumOptions.IncludedChecksums() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
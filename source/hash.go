@@ -0,0 +1,82 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"sync"
+)
+
+// DefaultHashAlgorithm is the algorithm used when no algorithm is configured.
+// It matches the checksums calculated by the Tide Audit Server.
+const DefaultHashAlgorithm = "sha256"
+
+var (
+	hashMu sync.RWMutex
+	hashes = map[string]func() hash.Hash{
+		DefaultHashAlgorithm: sha256.New,
+	}
+)
+
+// ChecksumOptions describes how a source calculates its file checksums.
+type ChecksumOptions struct {
+	Algorithm string // Name of a registered hash algorithm, defaults to "sha256".
+	Workers   int    // Number of files hashed concurrently, defaults to 1.
+
+	// Exclude lists patterns of files that are left out of the combined checksum so that
+	// functionally identical builds hash identically, e.g. "*.mo" or "build/timestamp.txt".
+	// See Excluded for how patterns are matched. Excluded files are still extracted and audited.
+	Exclude []string
+}
+
+// RegisterHash makes a hash algorithm available to sources by name.
+func RegisterHash(name string, fn func() hash.Hash) {
+	hashMu.Lock()
+	defer hashMu.Unlock()
+	hashes[name] = fn
+}
+
+// NewHash returns the constructor for the named hash algorithm.
+// An empty name returns the default algorithm.
+func NewHash(name string) (func() hash.Hash, error) {
+	if name == "" {
+		name = DefaultHashAlgorithm
+	}
+
+	hashMu.RLock()
+	defer hashMu.RUnlock()
+
+	fn, ok := hashes[name]
+	if !ok {
+		return nil, errors.New("unsupported hash algorithm: " + name)
+	}
+	return fn, nil
+}
+
+// FileChecksums returns the checksums of the files by file, from the checksums in the same
+// order as the files.
+func FileChecksums(files, checksums []string) map[string]string {
+	sums := make(map[string]string, len(files))
+	for i, file := range files {
+		if i < len(checksums) {
+			sums[file] = checksums[i]
+		}
+	}
+	return sums
+}
+
+// CombinedChecksum sorts the individual file checksums and hashes their JSON
+// representation. With sha256 this is the same technique used by the Tide Audit Server.
+// The checksums are sorted in a copy, so they stay in the order of their files.
+func CombinedChecksum(sums []string, newHash func() hash.Hash) string {
+	sorted := append([]string(nil), sums...)
+	sort.Strings(sorted)
+	jsonChecksums, _ := json.Marshal(sorted)
+
+	h := newHash()
+	h.Write(jsonChecksums)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
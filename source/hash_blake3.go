@@ -0,0 +1,20 @@
+//go:build blake3
+// +build blake3
+
+package source
+
+import (
+	"hash"
+
+	"github.com/zeebo/blake3"
+)
+
+// HashBLAKE3 is the name of the optional BLAKE3 algorithm.
+// It is only available when building with the `blake3` tag.
+const HashBLAKE3 = "blake3"
+
+func init() {
+	RegisterHash(HashBLAKE3, func() hash.Hash {
+		return blake3.New()
+	})
+}
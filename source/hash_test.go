@@ -0,0 +1,91 @@
+package source
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"hash"
+	"testing"
+)
+
+func TestNewHash(t *testing.T) {
+	RegisterHash("md5", md5.New)
+	defer func() {
+		hashMu.Lock()
+		delete(hashes, "md5")
+		hashMu.Unlock()
+	}()
+
+	tests := []struct {
+		name    string
+		algo    string
+		want    hash.Hash
+		wantErr bool
+	}{
+		{
+			"Default",
+			"",
+			sha256.New(),
+			false,
+		},
+		{
+			"SHA256",
+			"sha256",
+			sha256.New(),
+			false,
+		},
+		{
+			"Registered",
+			"md5",
+			md5.New(),
+			false,
+		},
+		{
+			"Unsupported",
+			"crc0",
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewHash(tt.algo)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewHash() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if got().Size() != tt.want.Size() {
+				t.Errorf("NewHash() size = %v, want %v", got().Size(), tt.want.Size())
+			}
+		})
+	}
+}
+
+func TestCombinedChecksum(t *testing.T) {
+	tests := []struct {
+		name    string
+		sums    []string
+		newHash func() hash.Hash
+		want    string
+	}{
+		{
+			"SHA256 - Backwards Compatible",
+			[]string{
+				"f6936912184481f5edd4c304ce27c5a1a827804fc7f329f43d273b8621870776",
+				"27dd8ed44a83ff94d557f9fd0412ed5a8cbca69ea04922d88c01184a07300a5a",
+				"2c8b08da5ce60398e1f19af0e5dccc744df274b826abe585eaba68c525434806",
+			},
+			sha256.New,
+			"5a0c0a95d189c266ca1ed43767dd98f3fb513ce3434e2b08f34828ac11e79a94",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CombinedChecksum(tt.sums, tt.newHash); got != tt.want {
+				t.Errorf("CombinedChecksum() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
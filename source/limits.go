@@ -0,0 +1,86 @@
+package source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Default extraction limits.
+const (
+	DefaultMaxSize  = 1 << 30 // 1 GiB uncompressed.
+	DefaultMaxFiles = 100000
+	DefaultMaxRatio = 100
+
+	// Archives smaller than this are not checked for their compression ratio, small
+	// text files compress very well.
+	minRatioSize = 1 << 20
+)
+
+// Reasons why an archive is rejected.
+const (
+	RejectPathTraversal   = "path_traversal"
+	RejectSize            = "size"
+	RejectFileCount       = "file_count"
+	RejectCompressionRate = "compression_ratio"
+)
+
+// ExtractLimits protect workers from archives that are unsafe to extract, e.g. zip bombs.
+// A negative limit disables the check.
+type ExtractLimits struct {
+	MaxSize  int64   // (Optional) Most uncompressed bytes. Defaults to DefaultMaxSize.
+	MaxFiles int     // (Optional) Most files and folders. Defaults to DefaultMaxFiles.
+	MaxRatio float64 // (Optional) Highest ratio of uncompressed to compressed bytes. Defaults to DefaultMaxRatio.
+}
+
+// RejectedError is returned by PrepareFiles when an archive is unsafe to extract.
+// Rejected archives are not audited.
+type RejectedError struct {
+	Reason string // e.g. RejectPathTraversal.
+	Detail string
+}
+
+// Error implements the error interface.
+func (e *RejectedError) Error() string {
+	return "source rejected (" + e.Reason + "): " + e.Detail
+}
+
+// Check returns a *RejectedError if an archive with the given number of entries and sizes
+// exceeds the limits.
+func (l ExtractLimits) Check(files int, uncompressed, compressed uint64) error {
+	maxSize, maxFiles, maxRatio := l.MaxSize, l.MaxFiles, l.MaxRatio
+	if maxSize == 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxFiles == 0 {
+		maxFiles = DefaultMaxFiles
+	}
+	if maxRatio == 0 {
+		maxRatio = DefaultMaxRatio
+	}
+
+	if maxFiles > 0 && files > maxFiles {
+		return &RejectedError{RejectFileCount, fmt.Sprintf("%d files, the limit is %d", files, maxFiles)}
+	}
+	if maxSize > 0 && uncompressed > uint64(maxSize) {
+		return &RejectedError{RejectSize, fmt.Sprintf("%d bytes uncompressed, the limit is %d", uncompressed, maxSize)}
+	}
+	if maxRatio > 0 && uncompressed > minRatioSize && compressed > 0 {
+		if ratio := float64(uncompressed) / float64(compressed); ratio > maxRatio {
+			return &RejectedError{RejectCompressionRate, fmt.Sprintf("compression ratio %.0f, the limit is %.0f", ratio, maxRatio)}
+		}
+	}
+	return nil
+}
+
+// ContainedPath returns the path of an entry extracted to destination. It returns a
+// *RejectedError if the entry would be written outside of destination, e.g. "../../.bashrc".
+func ContainedPath(destination, name string) (string, error) {
+	root := filepath.Clean(destination)
+	path := filepath.Join(root, name)
+	if path != root && !strings.HasPrefix(path, root+string(os.PathSeparator)) {
+		return "", &RejectedError{RejectPathTraversal, "illegal file path " + name}
+	}
+	return path, nil
+}
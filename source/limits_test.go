@@ -0,0 +1,64 @@
+package source
+
+import (
+	"testing"
+)
+
+func TestExtractLimits_Check(t *testing.T) {
+	tests := []struct {
+		name         string
+		limits       ExtractLimits
+		files        int
+		uncompressed uint64
+		compressed   uint64
+		want         string
+	}{
+		{"Within Defaults", ExtractLimits{}, 100, 10 << 20, 2 << 20, ""},
+		{"Too Many Files", ExtractLimits{MaxFiles: 10}, 11, 100, 50, RejectFileCount},
+		{"Too Large", ExtractLimits{MaxSize: 1 << 20}, 1, 2 << 20, 1 << 20, RejectSize},
+		{"Compression Ratio", ExtractLimits{}, 1, 500 << 20, 1 << 20, RejectCompressionRate},
+		{"Small Archive Ratio", ExtractLimits{}, 1, 1 << 10, 1, ""},
+		{"Disabled", ExtractLimits{MaxSize: -1, MaxFiles: -1, MaxRatio: -1}, 1 << 20, 10 << 30, 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(tt.files, tt.uncompressed, tt.compressed)
+
+			got := ""
+			if rejected, ok := err.(*RejectedError); ok {
+				got = rejected.Reason
+			} else if err != nil {
+				t.Fatalf("ExtractLimits.Check() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ExtractLimits.Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainedPath(t *testing.T) {
+	tests := []struct {
+		destination string
+		name        string
+		want        string
+		wantErr     bool
+	}{
+		{"./out", "plugin.php", "out/plugin.php", false},
+		{"./out", "inc/../plugin.php", "out/plugin.php", false},
+		{"./out", "", "out", false},
+		{"./out", "../evil.php", "", true},
+		{"out", "inc/../../../evil.php", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ContainedPath(tt.destination, tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ContainedPath() = %v, %v, want %v", got, err, tt.want)
+			}
+			if _, ok := err.(*RejectedError); err != nil && !ok {
+				t.Errorf("ContainedPath() error type = %T", err)
+			}
+		})
+	}
+}
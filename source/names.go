@@ -0,0 +1,150 @@
+package source
+
+import (
+	"path"
+	"strings"
+)
+
+// NamePolicy describes how a source handles file names that are not portable,
+// e.g. names that are reserved on Windows or use backslash separators.
+type NamePolicy string
+
+// Name policies.
+const (
+	NameRename NamePolicy = "rename" // Extract the file using the normalized name.
+	NameSkip   NamePolicy = "skip"   // Don't extract the file.
+)
+
+// Anomaly describes a file name that had to be normalized.
+type Anomaly struct {
+	Name    string   `json:"name"`           // The name in the source.
+	Path    string   `json:"path,omitempty"` // The normalized name, empty if the file was skipped.
+	Reasons []string `json:"reasons"`
+	Skipped bool     `json:"skipped"`
+}
+
+// AnomalyReporter is implemented by sources that report the file names they had to normalize.
+type AnomalyReporter interface {
+	GetAnomalies() []Anomaly
+}
+
+// Reasons for normalizing a name.
+const (
+	ReasonBackslash = "backslash separator"
+	ReasonReserved  = "reserved name"
+	ReasonTrailing  = "trailing dot or space"
+	ReasonCharacter = "invalid character"
+	ReasonDuplicate = "duplicate name"
+)
+
+// reservedNames are device names that can't be used as file names on Windows, with or without an extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// NormalizeName returns a portable version of a file name and the reasons it had to change.
+// Backslashes become separators, trailing dots and spaces are removed, invalid characters are
+// replaced with "_" and reserved names get a "_" prefix, e.g. `docs\aux.txt` becomes "docs/_aux.txt".
+func NormalizeName(name string) (string, []string) {
+	var reasons []string
+	reason := func(r string) {
+		for _, existing := range reasons {
+			if existing == r {
+				return
+			}
+		}
+		reasons = append(reasons, r)
+	}
+
+	if strings.Contains(name, `\`) {
+		name = strings.Replace(name, `\`, "/", -1)
+		reason(ReasonBackslash)
+	}
+
+	// Keep a trailing separator so that directories stay directories.
+	dir := strings.HasSuffix(name, "/")
+	segments := strings.Split(strings.TrimSuffix(name, "/"), "/")
+
+	for i, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+
+		if trimmed := strings.TrimRight(segment, ". "); trimmed != segment {
+			segment = trimmed
+			if segment == "" {
+				segment = "_"
+			}
+			reason(ReasonTrailing)
+		}
+
+		if replaced := strings.Map(replaceInvalid, segment); replaced != segment {
+			segment = replaced
+			reason(ReasonCharacter)
+		}
+
+		base := strings.ToUpper(strings.SplitN(segment, ".", 2)[0])
+		if reservedNames[strings.TrimRight(base, " ")] {
+			segment = "_" + segment
+			reason(ReasonReserved)
+		}
+
+		segments[i] = segment
+	}
+
+	name = strings.Join(segments, "/")
+	if dir {
+		name += "/"
+	}
+
+	return name, reasons
+}
+
+// replaceInvalid replaces characters that are not allowed in Windows file names.
+func replaceInvalid(r rune) rune {
+	if r < 32 || strings.ContainsRune(`<>:"|?*`, r) {
+		return '_'
+	}
+	return r
+}
+
+// NameNormalizer applies a NamePolicy to the names of a source and collects the anomalies.
+type NameNormalizer struct {
+	Policy    NamePolicy // (Optional) Defaults to NameRename.
+	Anomalies []Anomaly
+	seen      map[string]bool
+}
+
+// Normalize returns the name to use for a file in the source, or false if the file should be skipped.
+// Names that become the same as an earlier name after normalization are always skipped.
+func (n *NameNormalizer) Normalize(name string) (string, bool) {
+	if n.seen == nil {
+		n.seen = make(map[string]bool)
+	}
+
+	normalized, reasons := NormalizeName(name)
+	key := path.Clean(normalized)
+
+	if n.seen[key] {
+		reasons = append(reasons, ReasonDuplicate)
+	}
+
+	if len(reasons) == 0 {
+		n.seen[key] = true
+		return name, true
+	}
+
+	anomaly := Anomaly{Name: name, Reasons: reasons}
+	if n.Policy == NameSkip || n.seen[key] {
+		anomaly.Skipped = true
+		n.Anomalies = append(n.Anomalies, anomaly)
+		return "", false
+	}
+
+	n.seen[key] = true
+	anomaly.Path = normalized
+	n.Anomalies = append(n.Anomalies, anomaly)
+	return normalized, true
+}
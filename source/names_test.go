@@ -0,0 +1,61 @@
+package source
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		want        string
+		wantReasons []string
+	}{
+		{"Portable", "plugin/plugin.php", "plugin/plugin.php", nil},
+		{"Directory", "plugin/includes/", "plugin/includes/", nil},
+		{"Backslash", `plugin\includes\class.php`, "plugin/includes/class.php", []string{ReasonBackslash}},
+		{"Reserved", "plugin/CON", "plugin/_CON", []string{ReasonReserved}},
+		{"Reserved With Extension", "plugin/aux.php", "plugin/_aux.php", []string{ReasonReserved}},
+		{"Reserved Directory", "plugin/lpt1/file.php", "plugin/_lpt1/file.php", []string{ReasonReserved}},
+		{"Not Reserved", "plugin/console.php", "plugin/console.php", nil},
+		{"Trailing Dot", "plugin/readme.txt.", "plugin/readme.txt", []string{ReasonTrailing}},
+		{"Trailing Space", "plugin/docs /index.php", "plugin/docs/index.php", []string{ReasonTrailing}},
+		{"Only Dots", "plugin/.../index.php", "plugin/_/index.php", []string{ReasonTrailing}},
+		{"Invalid Characters", "plugin/what?.php", "plugin/what_.php", []string{ReasonCharacter}},
+		{"Multiple Reasons", `plugin\nul.txt.`, "plugin/_nul.txt", []string{ReasonBackslash, ReasonTrailing, ReasonReserved}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, reasons := NormalizeName(tt.file)
+			if got != tt.want {
+				t.Errorf("NormalizeName() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(reasons, tt.wantReasons) {
+				t.Errorf("NormalizeName() reasons = %v, want %v", reasons, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func TestNameNormalizer_Normalize(t *testing.T) {
+	n := &NameNormalizer{}
+
+	if got, ok := n.Normalize("plugin/_aux.php"); !ok || got != "plugin/_aux.php" {
+		t.Errorf("NameNormalizer.Normalize() = %v, %v", got, ok)
+	}
+	if got, ok := n.Normalize("plugin/aux.php"); ok {
+		t.Errorf("NameNormalizer.Normalize() = %v, expected duplicate to be skipped", got)
+	}
+	if got, ok := n.Normalize("plugin/prn.php"); !ok || got != "plugin/_prn.php" {
+		t.Errorf("NameNormalizer.Normalize() = %v, %v", got, ok)
+	}
+
+	want := []Anomaly{
+		{Name: "plugin/aux.php", Reasons: []string{ReasonReserved, ReasonDuplicate}, Skipped: true},
+		{Name: "plugin/prn.php", Path: "plugin/_prn.php", Reasons: []string{ReasonReserved}},
+	}
+	if !reflect.DeepEqual(n.Anomalies, want) {
+		t.Errorf("NameNormalizer.Anomalies = %v, want %v", n.Anomalies, want)
+	}
+}
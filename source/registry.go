@@ -0,0 +1,114 @@
+package source
+
+import (
+	"context"
+	"sync"
+)
+
+// Options configure the sources created by a Factory.
+type Options struct {
+	Context    context.Context // Cancels the download, e.g. when the worker shuts down.
+	Checksum   ChecksumOptions
+	NamePolicy NamePolicy
+	Scanner    Scanner         // Scans downloaded archives, if the kind downloads one.
+	Download   DownloadOptions // Retries and timeout of downloads, if the kind supports them.
+	Limits     ExtractLimits   // Limits of extracted archives, if the kind extracts one.
+	Extract    ExtractOptions  // Concurrency and buffer size of the extraction, if the kind supports them.
+}
+
+// Factory returns a new source for the url.
+type Factory func(url string, options Options) Source
+
+// Matcher determines if a url is a source of its kind, e.g. by the extension or host of the url.
+type Matcher func(url string) bool
+
+type registration struct {
+	kind    string
+	factory Factory
+	match   Matcher
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registration
+)
+
+// Register makes a source kind available to New, e.g. `source.Register("git", NewGit, nil)`.
+// Registering a kind again replaces its factory and matcher, so that built-in kinds can be overridden.
+//
+// Kinds are chosen by the url first: the matchers are tried in the order in which the kinds
+// were registered. match is optional, kinds without a matcher are only chosen by name.
+func Register(kind string, factory Factory, match Matcher) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for i, r := range registry {
+		if r.kind == kind {
+			registry[i].factory = factory
+			registry[i].match = match
+			return
+		}
+	}
+	registry = append(registry, registration{kind, factory, match})
+}
+
+// Unregister removes a source kind.
+func Unregister(kind string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for i, r := range registry {
+		if r.kind == kind {
+			registry = append(registry[:i], registry[i+1:]...)
+			return
+		}
+	}
+}
+
+// Kinds returns the registered kinds in the order in which they are tried.
+func Kinds() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	kinds := make([]string, len(registry))
+	for i, r := range registry {
+		kinds[i] = r.kind
+	}
+	return kinds
+}
+
+// Kind returns the kind of source for the url: the first kind whose matcher matches the url,
+// or else kind if it is registered, e.g. the source type of a message. It returns an empty
+// string if no kind can handle the url.
+func Kind(kind, url string) string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, r := range registry {
+		if r.match != nil && r.match(url) {
+			return r.kind
+		}
+	}
+	for _, r := range registry {
+		if r.kind == kind {
+			return r.kind
+		}
+	}
+	return ""
+}
+
+// New returns a new source for the url, using the factory of the kind chosen by Kind.
+// It returns false if no kind can handle the url.
+func New(kind, url string, options Options) (Source, bool) {
+	kind = Kind(kind, url)
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	for _, r := range registry {
+		if r.kind == kind {
+			return r.factory(url, options), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,80 @@
+package source
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mockSource struct {
+	url     string
+	options Options
+}
+
+func (m mockSource) PrepareFiles(dest string) error { return nil }
+func (m mockSource) GetChecksum() string            { return "" }
+func (m mockSource) GetFiles() []string             { return nil }
+
+func mockFactory(url string, options Options) Source {
+	return mockSource{url, options}
+}
+
+func TestRegistry(t *testing.T) {
+	Register("test-local", mockFactory, func(url string) bool {
+		return strings.HasPrefix(url, "file://")
+	})
+	Register("test-git", mockFactory, nil)
+	defer Unregister("test-local")
+	defer Unregister("test-git")
+
+	options := Options{NamePolicy: NameSkip}
+
+	tests := []struct {
+		name     string
+		kind     string
+		url      string
+		wantKind string
+		want     Source
+	}{
+		{"Matcher", "", "file:///plugins/plugin", "test-local", mockSource{"file:///plugins/plugin", options}},
+		{"Matcher Before Kind", "test-git", "file:///plugins/plugin", "test-local", mockSource{"file:///plugins/plugin", options}},
+		{"Kind", "test-git", "https://github.com/wptide/plugin.git", "test-git", mockSource{"https://github.com/wptide/plugin.git", options}},
+		{"Unknown", "rar", "https://example.com/plugin.rar", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Kind(tt.kind, tt.url); got != tt.wantKind {
+				t.Errorf("Kind() = %v, want %v", got, tt.wantKind)
+			}
+
+			got, ok := New(tt.kind, tt.url, options)
+			if ok != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegister_Replace(t *testing.T) {
+	Register("test-first", mockFactory, nil)
+	Register("test-second", mockFactory, nil)
+	defer Unregister("test-first")
+	defer Unregister("test-second")
+
+	// Replacing a kind keeps its position.
+	Register("test-first", mockFactory, func(url string) bool { return true })
+
+	var got []string
+	for _, kind := range Kinds() {
+		if strings.HasPrefix(kind, "test-") {
+			got = append(got, kind)
+		}
+	}
+	if want := []string{"test-first", "test-second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Kinds() = %v, want %v", got, want)
+	}
+
+	if got := Kind("test-second", "https://example.com"); got != "test-first" {
+		t.Errorf("Kind() = %v, want test-first", got)
+	}
+}
@@ -0,0 +1,55 @@
+package source
+
+// Scanner scans a downloaded archive for malware before it is extracted, e.g. with ClamAV.
+// Scan returns the name of the threat that was found, or an empty string if the archive is clean.
+type Scanner interface {
+	Scan(path string) (threat string, err error)
+}
+
+// ScannerFunc is an adapter to use ordinary functions as a Scanner.
+type ScannerFunc func(path string) (string, error)
+
+// Scan calls f(path).
+func (f ScannerFunc) Scan(path string) (string, error) {
+	return f(path)
+}
+
+// MalwareError is returned by PrepareFiles when the Scanner found malware in the archive.
+// The archive is not extracted.
+type MalwareError struct {
+	Threat string // Name of the threat, e.g. "Win.Test.EICAR_HDB-1".
+	Path   string // Path of the infected archive.
+}
+
+// Error implements the error interface.
+func (e *MalwareError) Error() string {
+	return "malware detected in source: " + e.Threat
+}
+
+// ScanError is returned by PrepareFiles when the archive could not be scanned.
+type ScanError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ScanError) Error() string {
+	return "could not scan source: " + e.Err.Error()
+}
+
+// ScanArchive scans the archive at path with the scanner. It returns a *MalwareError if the
+// scanner found a threat and a *ScanError if the archive could not be scanned. A nil scanner
+// does not scan.
+func ScanArchive(scanner Scanner, path string) error {
+	if scanner == nil {
+		return nil
+	}
+
+	threat, err := scanner.Scan(path)
+	if err != nil {
+		return &ScanError{Err: err}
+	}
+	if threat != "" {
+		return &MalwareError{Threat: threat, Path: path}
+	}
+	return nil
+}
@@ -2,8 +2,15 @@ package source
 
 import (
 	"strings"
+	"time"
 )
 
+// DefaultDownloadBackoff is the wait before the first retry of a failed download.
+const DefaultDownloadBackoff = time.Second
+
+// DefaultExtractBufferSize is the size of the buffer that archive entries are read with.
+const DefaultExtractBufferSize = 32 * 1024
+
 // Source interface describes the source for code to be audited.
 type Source interface {
 	PrepareFiles(dest string) error
@@ -11,6 +18,56 @@ type Source interface {
 	GetFiles() []string
 }
 
+// TimingReporter is implemented by sources that report how long preparing the files took,
+// e.g. {"download": 2s, "extract": 1s}.
+type TimingReporter interface {
+	GetTimings() map[string]time.Duration
+}
+
+// ChecksumReporter is implemented by sources that report the checksums of their files, by the
+// paths returned by GetFiles, e.g. to find the files that changed since a previous version.
+type ChecksumReporter interface {
+	GetFileChecksums() map[string]string
+}
+
+// DownloadReporter is implemented by sources that report how many bytes they downloaded.
+type DownloadReporter interface {
+	GetDownloaded() int64
+}
+
+// DownloadOptions configure how a source downloads its archive.
+type DownloadOptions struct {
+	Retries int           // (Optional) Retries of a failed download. Downloads are not retried if 0.
+	Backoff time.Duration // (Optional) Wait before the first retry, doubled for every retry. Defaults to DefaultDownloadBackoff.
+	Timeout time.Duration // (Optional) Longest time for the download, including retries. Unlimited if 0.
+}
+
+// ExtractOptions configure how a source extracts its archive.
+type ExtractOptions struct {
+	Workers    int // (Optional) Entries extracted concurrently. Defaults to the Workers of the checksum options.
+	BufferSize int // (Optional) Size of the buffer each entry is read with. Defaults to DefaultExtractBufferSize.
+}
+
+// DownloadError is returned by PrepareFiles when the source could not be downloaded.
+type DownloadError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *DownloadError) Error() string {
+	return "could not download source: " + e.Err.Error()
+}
+
+// ArchiveError is returned by PrepareFiles when the source could not be extracted.
+type ArchiveError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *ArchiveError) Error() string {
+	return "could not extract source: " + e.Err.Error()
+}
+
 // GetKind uses basic string manipulation to get the type of source file.
 func GetKind(url string) string {
 	var kind string
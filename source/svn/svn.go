@@ -0,0 +1,236 @@
+// Package svn provides a source for Subversion repositories, e.g. the WordPress.org
+// plugin and theme directories.
+package svn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/shell"
+	"github.com/wptide/pkg/source"
+)
+
+// Svn describes a path in a Subversion repository.
+type Svn struct {
+	url        string
+	dest       string
+	files      []string
+	checksum   string
+	checksums  map[string]string
+	options    source.ChecksumOptions
+	timings    map[string]time.Duration
+	downloaded int64
+	Tag        string          // (Optional) Tag to export, e.g. "4.1.2". Defaults to trunk.
+	Revision   string          // (Optional) Revision to export, e.g. "1875412". Defaults to HEAD.
+	Runner     shell.Runner    // (Optional) Runs the svn client. Defaults to shell.Command.
+	Context    context.Context // (Optional) Cancels the export, e.g. when the worker shuts down.
+}
+
+var (
+	// Using a package variable so that we can mock it in tests.
+	defaultRunner shell.Runner = &shell.Command{}
+
+	// Using os.Open as a variable so that we can mock it in tests.
+	openFile = os.Open
+
+	// Hosts of the WordPress.org repositories.
+	wporgHosts = map[string]bool{
+		"plugins.svn.wordpress.org": true,
+		"themes.svn.wordpress.org":  true,
+	}
+)
+
+// IsRepository determines if the url is a Subversion repository that Svn can export,
+// i.e. a WordPress.org plugin or theme repository or an svn:// url.
+func IsRepository(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == "svn" || u.Scheme == "svn+ssh" || wporgHosts[strings.ToLower(u.Host)]
+}
+
+// PrepareFiles exports the repository to a given destination and extracts info about the files.
+func (m *Svn) PrepareFiles(dest string) error {
+
+	newHash, err := source.NewHash(m.options.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	if err := m.options.ValidateExclusions(); err != nil {
+		return err
+	}
+
+	// Prepare destination. The files are exported to the same folder as extracted archives.
+	m.dest = dest
+	if _, err := os.Stat(m.dest); os.IsNotExist(err) {
+		os.Mkdir(m.dest, os.ModePerm)
+	}
+	root := m.dest + "/unzipped"
+
+	// svn export refuses to overwrite files of a previous export.
+	os.RemoveAll(root)
+
+	m.timings = make(map[string]time.Duration)
+
+	started := time.Now()
+	err = m.export(root)
+	m.timings["download"] = time.Since(started)
+	if err != nil {
+		return err
+	}
+
+	started = time.Now()
+	var checksums []string
+	m.files, checksums, m.downloaded, err = hashFiles(root, newHash)
+	m.timings["checksum"] = time.Since(started)
+	if err != nil {
+		return err
+	}
+
+	// Calculate checksum - uses same technique as Tide Audit Server.
+	m.checksum = source.CombinedChecksum(m.options.IncludedChecksums(m.files, checksums, root), newHash)
+	m.checksums = source.FileChecksums(m.files, checksums)
+
+	return nil
+}
+
+// GetChecksum returns the combined checksum for the exported files.
+func (m Svn) GetChecksum() string {
+	return m.checksum
+}
+
+// GetFiles returns the exported files.
+func (m Svn) GetFiles() []string {
+	return m.files
+}
+
+// GetFileChecksums returns the checksums of the exported files.
+func (m Svn) GetFileChecksums() map[string]string {
+	return m.checksums
+}
+
+// GetTimings returns how long exporting and hashing the files took.
+func (m Svn) GetTimings() map[string]time.Duration {
+	return m.timings
+}
+
+// GetDownloaded returns the size of the exported files in bytes.
+func (m Svn) GetDownloaded() int64 {
+	return m.downloaded
+}
+
+// ExportURL returns the url that is exported. Urls of the repository root export trunk or the Tag,
+// urls that already point at trunk, a tag or a branch are exported as is.
+func (m Svn) ExportURL() string {
+	u := strings.TrimSuffix(m.url, "/")
+	if strings.HasSuffix(u, "/trunk") || strings.Contains(u, "/tags/") || strings.Contains(u, "/branches/") {
+		return u
+	}
+	if m.Tag != "" {
+		return u + "/tags/" + m.Tag
+	}
+	return u + "/trunk"
+}
+
+// NewSvn returns a new Svn source.
+func NewSvn(url string) *Svn {
+	return &Svn{
+		url: url,
+	}
+}
+
+// NewSvnWithOptions returns a new Svn source using the given checksum options.
+func NewSvnWithOptions(url string, options source.ChecksumOptions) *Svn {
+	return &Svn{
+		url:     url,
+		options: options,
+	}
+}
+
+// export runs `svn export` to write the files to the destination.
+func (m *Svn) export(destination string) error {
+	runner := m.Runner
+	if runner == nil {
+		runner = defaultRunner
+	}
+
+	args := []string{"export", "--non-interactive", "--quiet"}
+	if m.Revision != "" {
+		args = append(args, "--revision", m.Revision)
+	}
+	// The URL is never read as an option.
+	args = append(args, "--", m.ExportURL(), destination)
+
+	var errOut []byte
+	var exitCode int
+	var err error
+	if r, ok := runner.(shell.ContextRunner); ok && m.Context != nil {
+		_, errOut, exitCode, err = r.RunContext(m.Context, "svn", args...)
+	} else {
+		_, errOut, exitCode, err = runner.Run("svn", args...)
+	}
+	if err != nil || exitCode != 0 {
+		text := strings.TrimSpace(string(errOut))
+		if text == "" && err != nil {
+			text = err.Error()
+		}
+		if text == "" {
+			text = fmt.Sprintf("svn exited with code %d", exitCode)
+		}
+		return &source.DownloadError{Err: errors.New(text)}
+	}
+
+	return nil
+}
+
+// hashFiles returns the files in root in lexical order, their checksums and their total size.
+func hashFiles(root string, newHash func() hash.Hash) (files, checksums []string, size int64, err error) {
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		checksum, err := hashFile(path, newHash)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, path)
+		checksums = append(checksums, checksum)
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return files, checksums, size, nil
+}
+
+// hashFile returns the hex encoded checksum of a single file.
+func hashFile(path string, newHash func() hash.Hash) (string, error) {
+	file, err := openFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := newHash()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
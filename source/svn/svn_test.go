@@ -0,0 +1,179 @@
+package svn
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/source"
+)
+
+// mockRunner records the svn command and exports files named after their contents.
+type mockRunner struct {
+	args     []string
+	files    []string
+	errOut   []byte
+	exitCode int
+	err      error
+}
+
+func (m *mockRunner) Run(name string, arg ...string) ([]byte, []byte, int, error) {
+	m.args = append([]string{name}, arg...)
+
+	dest := arg[len(arg)-1]
+	for _, file := range m.files {
+		path := filepath.Join(dest, file)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		ioutil.WriteFile(path, []byte(file), 0644)
+	}
+
+	return nil, m.errOut, m.exitCode, m.err
+}
+
+func TestIsRepository(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://plugins.svn.wordpress.org/akismet", true},
+		{"https://themes.svn.wordpress.org/twentytwenty/1.2/", true},
+		{"svn://svn.example.com/repo/trunk", true},
+		{"https://downloads.wordpress.org/plugin/akismet.zip", false},
+		{"%zz", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := IsRepository(tt.url); got != tt.want {
+				t.Errorf("IsRepository() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSvn_ExportURL(t *testing.T) {
+	tests := []struct {
+		name string
+		svn  Svn
+		want string
+	}{
+		{"Trunk", Svn{url: "https://plugins.svn.wordpress.org/akismet/"}, "https://plugins.svn.wordpress.org/akismet/trunk"},
+		{"Tag", Svn{url: "https://plugins.svn.wordpress.org/akismet", Tag: "4.1.2"}, "https://plugins.svn.wordpress.org/akismet/tags/4.1.2"},
+		{"Tag Url", Svn{url: "https://plugins.svn.wordpress.org/akismet/tags/4.1.1", Tag: "4.1.2"}, "https://plugins.svn.wordpress.org/akismet/tags/4.1.1"},
+		{"Trunk Url", Svn{url: "https://plugins.svn.wordpress.org/akismet/trunk"}, "https://plugins.svn.wordpress.org/akismet/trunk"},
+		{"Branch Url", Svn{url: "svn://svn.example.com/repo/branches/next"}, "svn://svn.example.com/repo/branches/next"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.svn.ExportURL(); got != tt.want {
+				t.Errorf("Svn.ExportURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSvn_PrepareFiles(t *testing.T) {
+	dest := "testdata/export"
+	defer os.RemoveAll("./testdata")
+
+	sum := func(content string) string {
+		return fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+	}
+
+	tests := []struct {
+		name       string
+		svn        *Svn
+		runner     *mockRunner
+		wantArgs   []string
+		wantFiles  []string
+		wantChecks []string
+		wantErr    bool
+	}{
+		{
+			"Trunk",
+			NewSvn("https://plugins.svn.wordpress.org/akismet"),
+			&mockRunner{files: []string{"akismet.php", "views/notice.php"}},
+			[]string{"svn", "export", "--non-interactive", "--quiet", "--", "https://plugins.svn.wordpress.org/akismet/trunk", dest + "/unzipped"},
+			[]string{dest + "/unzipped/akismet.php", dest + "/unzipped/views/notice.php"},
+			[]string{sum("akismet.php"), sum("views/notice.php")},
+			false,
+		},
+		{
+			"Tag At Revision With Exclusions",
+			&Svn{
+				url:      "https://plugins.svn.wordpress.org/akismet",
+				Tag:      "4.1.2",
+				Revision: "1875412",
+				options:  source.ChecksumOptions{Exclude: []string{"*.mo"}},
+			},
+			&mockRunner{files: []string{"akismet.php", "languages/akismet-de_DE.mo"}},
+			[]string{"svn", "export", "--non-interactive", "--quiet", "--revision", "1875412", "--", "https://plugins.svn.wordpress.org/akismet/tags/4.1.2", dest + "/unzipped"},
+			[]string{dest + "/unzipped/akismet.php", dest + "/unzipped/languages/akismet-de_DE.mo"},
+			[]string{sum("akismet.php")},
+			false,
+		},
+		{
+			"Option-Like URL",
+			NewSvn("--config-dir=/tmp"),
+			&mockRunner{files: []string{"akismet.php"}},
+			[]string{"svn", "export", "--non-interactive", "--quiet", "--", "--config-dir=/tmp/trunk", dest + "/unzipped"},
+			[]string{dest + "/unzipped/akismet.php"},
+			[]string{sum("akismet.php")},
+			false,
+		},
+		{
+			"Export Error",
+			NewSvn("https://plugins.svn.wordpress.org/missing"),
+			&mockRunner{errOut: []byte("svn: E170000: URL doesn't exist\n"), exitCode: 1, err: errors.New("exit status 1")},
+			nil,
+			nil,
+			nil,
+			true,
+		},
+		{
+			"Invalid Exclusion",
+			NewSvnWithOptions("https://plugins.svn.wordpress.org/akismet", source.ChecksumOptions{Exclude: []string{"[a-"}}),
+			&mockRunner{},
+			nil,
+			nil,
+			nil,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll("./testdata")
+			os.MkdirAll("./testdata", 0755)
+
+			tt.svn.Runner = tt.runner
+			err := tt.svn.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Svn.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				if _, ok := err.(*source.DownloadError); tt.runner.args != nil && !ok {
+					t.Errorf("Svn.PrepareFiles() error type = %T, want *source.DownloadError", err)
+				}
+				return
+			}
+
+			if !reflect.DeepEqual(tt.runner.args, tt.wantArgs) {
+				t.Errorf("Svn.PrepareFiles() ran %v, want %v", tt.runner.args, tt.wantArgs)
+			}
+			if !reflect.DeepEqual(tt.svn.GetFiles(), tt.wantFiles) {
+				t.Errorf("Svn.GetFiles() = %v, want %v", tt.svn.GetFiles(), tt.wantFiles)
+			}
+			if want := source.CombinedChecksum(tt.wantChecks, sha256.New); tt.svn.GetChecksum() != want {
+				t.Errorf("Svn.GetChecksum() = %v, want %v", tt.svn.GetChecksum(), want)
+			}
+			if tt.svn.GetDownloaded() <= 0 {
+				t.Errorf("Svn.GetDownloaded() = %v", tt.svn.GetDownloaded())
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+// Package tar provides a source for tarballs, e.g. .tar.gz, .tgz and .tar.bz2 archives.
+// The files and checksum are the same as for a zip file with the same contents.
+package tar
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/wptide/pkg/source"
+)
+
+// Compression formats.
+const (
+	CompressionNone  = "none"
+	CompressionGzip  = "gzip"
+	CompressionBzip2 = "bzip2"
+)
+
+// Tar describes a tarball.
+type Tar struct {
+	url         string
+	dest        string
+	compression string
+	files       []string
+	checksum    string
+	checksums   map[string]string
+	options     source.ChecksumOptions
+	anomalies   []source.Anomaly
+	timings     map[string]time.Duration
+	downloaded  int64
+	NamePolicy  source.NamePolicy      // (Optional) Handling of names that are not portable. Defaults to source.NameRename.
+	Scanner     source.Scanner         // (Optional) Scans the downloaded archive for malware before it is extracted.
+	Download    source.DownloadOptions // (Optional) Retries and timeout of the download.
+	Limits      source.ExtractLimits   // (Optional) Size, file count and compression ratio limits of the tarball.
+	Extract     source.ExtractOptions  // (Optional) Buffer size of the extraction. Tarballs are read in order, so Workers is not used.
+	Context     context.Context        // (Optional) Cancels the download, e.g. when the worker shuts down.
+}
+
+var (
+	// File system operation variables.
+	createFile       = os.Create
+	makeDirectoryAll = os.MkdirAll
+	ioCopy           = io.Copy
+	openFile         = os.OpenFile
+
+	// Default paths.
+	sourceFilename = "source.tar"
+)
+
+// Compression returns the compression of the tarball at the url, e.g. CompressionGzip for
+// "https://example.com/plugin.tar.gz". The second value is false if the url is not a tarball.
+func Compression(url string) (string, bool) {
+	name := strings.ToLower(url)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return CompressionGzip, true
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"), strings.HasSuffix(name, ".tbz"):
+		return CompressionBzip2, true
+	case strings.HasSuffix(name, ".tar"):
+		return CompressionNone, true
+	}
+	return "", false
+}
+
+// PrepareFiles downloads a tarball to a given destination and extracts info about the files in it.
+func (m *Tar) PrepareFiles(dest string) error {
+
+	newHash, err := source.NewHash(m.options.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	if err := m.options.ValidateExclusions(); err != nil {
+		return err
+	}
+
+	// Prepare destination.
+	m.dest = dest
+	if _, err := os.Stat(m.dest); os.IsNotExist(err) {
+		os.Mkdir(m.dest, os.ModePerm)
+	}
+
+	m.timings = make(map[string]time.Duration)
+
+	started := time.Now()
+	ctx := m.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err = downloadFile(ctx, m.url, m.dest+"/"+sourceFilename, m.Download)
+	m.timings["download"] = time.Since(started)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(m.dest + "/" + sourceFilename); err == nil {
+		m.downloaded = info.Size()
+	}
+
+	if m.Scanner != nil {
+		started = time.Now()
+		err = source.ScanArchive(m.Scanner, m.dest+"/"+sourceFilename)
+		m.timings["scan"] = time.Since(started)
+		if err != nil {
+			return err
+		}
+	}
+
+	started = time.Now()
+	var checksums []string
+	m.files, checksums, m.anomalies, err = untar(m.dest+"/"+sourceFilename, m.compression, m.dest+"/unzipped", newHash, m.Extract.BufferSize, m.NamePolicy, m.Limits)
+	m.timings["extract"] = time.Since(started)
+	if rejected, ok := err.(*source.RejectedError); ok {
+		return rejected
+	}
+	if err != nil {
+		return &source.ArchiveError{Err: err}
+	}
+
+	// Calculate checksum - uses same technique as Tide Audit Server.
+	m.checksum = source.CombinedChecksum(m.options.IncludedChecksums(m.files, checksums, m.dest+"/unzipped"), newHash)
+	m.checksums = source.FileChecksums(m.files, checksums)
+
+	return nil
+}
+
+// GetChecksum returns the combined checksum for the tarball.
+func (m Tar) GetChecksum() string {
+	return m.checksum
+}
+
+// GetFiles returns the files contained in the tarball.
+func (m Tar) GetFiles() []string {
+	return m.files
+}
+
+// GetFileChecksums returns the checksums of the files contained in the tarball.
+func (m Tar) GetFileChecksums() map[string]string {
+	return m.checksums
+}
+
+// GetAnomalies returns the file names that had to be normalized while extracting the tarball.
+func (m Tar) GetAnomalies() []source.Anomaly {
+	return m.anomalies
+}
+
+// GetTimings returns how long downloading and extracting the tarball took.
+func (m Tar) GetTimings() map[string]time.Duration {
+	return m.timings
+}
+
+// GetDownloaded returns the size of the downloaded tarball in bytes.
+func (m Tar) GetDownloaded() int64 {
+	return m.downloaded
+}
+
+// NewTar returns a new Tar source. The compression is detected from the url.
+func NewTar(url string) *Tar {
+	return NewTarWithOptions(url, source.ChecksumOptions{})
+}
+
+// NewTarWithOptions returns a new Tar source using the given checksum options.
+// The file hashes are calculated while extracting, so options.Workers is not used.
+func NewTarWithOptions(url string, options source.ChecksumOptions) *Tar {
+	compression, _ := Compression(url)
+	return &Tar{
+		url:         url,
+		compression: compression,
+		options:     options,
+	}
+}
+
+// downloadFile gets a file and saves it to a given destination, see source.Download.
+func downloadFile(ctx context.Context, url string, destination string, options source.DownloadOptions) error {
+	// Create destination
+	out, err := createFile(destination)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return source.Download(ctx, url, out, options)
+}
+
+// openTar opens the tarball for reading. The returned closer closes the file.
+func openTar(src, compression string) (*tar.Reader, io.Closer, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var reader io.Reader = file
+	switch compression {
+	case CompressionGzip:
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		reader = gz
+	case CompressionBzip2:
+		reader = bzip2.NewReader(file)
+	case CompressionNone, "":
+	default:
+		file.Close()
+		return nil, nil, errors.New("unsupported compression: " + compression)
+	}
+
+	return tar.NewReader(reader), file, nil
+}
+
+// scanTar returns the shortest directory in the tarball, which is removed from the extracted paths,
+// e.g. "plugin/" for a tarball of the folder "plugin", and the number and total size of the entries.
+func scanTar(src, compression string, normalizer *source.NameNormalizer) (root string, entries int, size uint64, err error) {
+	reader, closer, err := openTar(src, compression)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	defer closer.Close()
+
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return root, entries, size, nil
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+
+		entries++
+		if header.Size > 0 {
+			size += uint64(header.Size)
+		}
+
+		if header.Typeflag != tar.TypeDir {
+			continue
+		}
+
+		name := entryName(header)
+		if name == "" {
+			continue
+		}
+		name, _ = normalizer.Normalize(name)
+		if name != "" && (root == "" || len(name) < len(root)) {
+			root = name
+		}
+	}
+}
+
+// entryName returns the name of a tarball entry relative to the archive, with a trailing slash
+// for folders like in zip files. It returns an empty string for the archive's own folder, "./".
+func entryName(header *tar.Header) string {
+	name := strings.TrimPrefix(header.Name, "./")
+	if header.Typeflag == tar.TypeDir {
+		name = strings.TrimSuffix(name, "/")
+		if name == "" || name == "." {
+			return ""
+		}
+		name += "/"
+	}
+	return name
+}
+
+// untar will extract a tarball, moving all files and folders to a destination directory.
+// Like zip files, only regular files and folders are extracted, links and devices are skipped.
+//
+// Tarballs can only be read in order, so the archive is read twice: once to find the root
+// folder and check the limits, and once to extract and hash the files. Each file is read with a
+// buffer of bufferSize bytes, see source.ExtractOptions.
+func untar(src, compression, destination string, newHash func() hash.Hash, bufferSize int, policy source.NamePolicy, limits source.ExtractLimits) (filenames, checksums []string, anomalies []source.Anomaly, err error) {
+	if bufferSize <= 0 {
+		bufferSize = source.DefaultExtractBufferSize
+	}
+
+	// The root is found with its own normalizer, so the anomalies are only reported once.
+	root, entries, size, err := scanTar(src, compression, &source.NameNormalizer{Policy: policy})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var compressed uint64
+	if info, err := os.Stat(src); err == nil {
+		compressed = uint64(info.Size())
+	}
+	if err := limits.Check(entries, size, compressed); err != nil {
+		return nil, nil, nil, err
+	}
+
+	reader, closer, err := openTar(src, compression)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer closer.Close()
+
+	if err := makeDirectoryAll(destination, 0755); err != nil {
+		return nil, nil, nil, err
+	}
+
+	normalizer := &source.NameNormalizer{Policy: policy}
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		isDir := header.Typeflag == tar.TypeDir
+		if !isDir && header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := entryName(header)
+		if name == "" {
+			continue
+		}
+		name, ok := normalizer.Normalize(name)
+		if !ok {
+			continue
+		}
+
+		path, err := source.ContainedPath(destination, strings.TrimPrefix(name, root))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		if isDir {
+			makeDirectoryAll(path, os.FileMode(header.Mode).Perm()|0700)
+			continue
+		}
+
+		// Tarballs often have no directory entries.
+		makeDirectoryAll(filepath.Dir(path), 0755)
+
+		checksum, err := extractFile(reader, path, os.FileMode(header.Mode).Perm(), newHash, bufferSize)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		filenames = append(filenames, path)
+		checksums = append(checksums, checksum)
+	}
+
+	return filenames, checksums, normalizer.Anomalies, nil
+}
+
+// extractFile writes the current tarball entry to the given path and returns its checksum.
+func extractFile(reader io.Reader, path string, mode os.FileMode, newHash func() hash.Hash, bufferSize int) (string, error) {
+	targetFile, err := openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return "", err
+	}
+	defer targetFile.Close()
+
+	h := newHash()
+	if _, err := ioCopy(io.MultiWriter(targetFile, h), bufio.NewReaderSize(reader, bufferSize)); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
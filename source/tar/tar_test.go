@@ -0,0 +1,298 @@
+package tar
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/source"
+)
+
+var fileServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.String() {
+	case "/test.tar.gz":
+		http.ServeFile(w, r, "./testdata/test.tar.gz")
+	case "/test.tar.bz2":
+		http.ServeFile(w, r, "./testdata/test.tar.bz2")
+	case "/test.tgz":
+		// Not a tarball.
+		w.Write([]byte("plain text"))
+	default:
+		http.NotFound(w, r)
+	}
+}))
+
+// testChecksum is the checksum of the dummy theme in ../zip/testdata/test.zip.
+var testChecksum = source.CombinedChecksum([]string{
+	"64a43b6ce686b50bbd7eb91b2b1346ed66e7053d42f7f7b9d5562d55a25d1321",
+	"9a8549c5d1f384593788dc25b1c236f8450534e8cb95833003786fef8201b92b",
+	"09679b8abb88b21dd1cf166e1d2745df7882a879d2b8672548f6dc0dc9572fe6",
+}, sha256.New)
+
+func TestCompression(t *testing.T) {
+	tests := []struct {
+		url    string
+		want   string
+		wantOk bool
+	}{
+		{"https://example.com/plugin.tar.gz", CompressionGzip, true},
+		{"https://example.com/plugin.1.0.TGZ?token=abc", CompressionGzip, true},
+		{"https://example.com/plugin.tar.bz2", CompressionBzip2, true},
+		{"https://example.com/plugin.tbz2", CompressionBzip2, true},
+		{"https://example.com/plugin.tar", CompressionNone, true},
+		{"https://example.com/plugin.zip", "", false},
+		{"https://example.com/plugin.gz", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			got, ok := Compression(tt.url)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Compression() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTar_PrepareFiles(t *testing.T) {
+	dest := "./testdata/download"
+	defer os.RemoveAll(dest)
+
+	errorCreate := func(path string) (*os.File, error) {
+		return nil, errors.New("something went wrong")
+	}
+
+	tests := []struct {
+		name       string
+		url        string
+		options    source.ChecksumOptions
+		createFile func(string) (*os.File, error)
+		wantErr    bool
+	}{
+		{"Gzip", fileServer.URL + "/test.tar.gz", source.ChecksumOptions{}, nil, false},
+		{"Bzip2", fileServer.URL + "/test.tar.bz2", source.ChecksumOptions{}, nil, false},
+		{"Error Destination", fileServer.URL + "/test.tar.gz", source.ChecksumOptions{}, errorCreate, true},
+		{"Not Found", fileServer.URL + "/missing.tar.gz", source.ChecksumOptions{}, nil, true},
+		{"Not A Tarball", fileServer.URL + "/test.tgz", source.ChecksumOptions{}, nil, true},
+		{"Invalid Exclusion", fileServer.URL + "/test.tar.gz", source.ChecksumOptions{Exclude: []string{"[a-"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer os.RemoveAll(dest)
+
+			if tt.createFile != nil {
+				oldCreateFile := createFile
+				createFile = tt.createFile
+				defer func() {
+					createFile = oldCreateFile
+				}()
+			}
+
+			m := NewTarWithOptions(tt.url, tt.options)
+			err := m.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Tar.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			files := []string{
+				"testdata/download/unzipped/function.php",
+				"testdata/download/unzipped/style.css",
+				"testdata/download/unzipped/script.js",
+			}
+			if !reflect.DeepEqual(m.GetFiles(), files) {
+				t.Errorf("Tar.GetFiles() = %v, want %v", m.GetFiles(), files)
+			}
+			if m.GetChecksum() != testChecksum {
+				t.Errorf("Tar.GetChecksum() = %v, want the checksum of the same zip %v", m.GetChecksum(), testChecksum)
+			}
+			if sums := m.GetFileChecksums(); len(sums) != len(files) || sums[files[0]] == "" {
+				t.Errorf("Tar.GetFileChecksums() = %v", sums)
+			}
+			if m.GetDownloaded() <= 0 || m.GetTimings()["extract"] <= 0 {
+				t.Errorf("Tar.PrepareFiles() downloaded %v in %v", m.GetDownloaded(), m.GetTimings())
+			}
+		})
+	}
+}
+
+func TestTar_PrepareFiles_Options(t *testing.T) {
+	dest := "./testdata/options"
+	defer os.RemoveAll(dest)
+
+	// The first request of each download fails.
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		http.ServeFile(w, r, "./testdata/test.tar.gz")
+	}))
+	defer server.Close()
+
+	var bufferSizes []int
+	oldIoCopy := ioCopy
+	ioCopy = func(dst io.Writer, src io.Reader) (int64, error) {
+		if reader, ok := src.(*bufio.Reader); ok {
+			bufferSizes = append(bufferSizes, reader.Size())
+		}
+		return io.Copy(dst, src)
+	}
+	defer func() {
+		ioCopy = oldIoCopy
+	}()
+
+	tests := []struct {
+		name           string
+		download       source.DownloadOptions
+		extract        source.ExtractOptions
+		wantErr        bool
+		wantBufferSize int
+	}{
+		{"Not Retried", source.DownloadOptions{}, source.ExtractOptions{}, true, 0},
+		{"Retried", source.DownloadOptions{Retries: 1, Backoff: time.Millisecond}, source.ExtractOptions{}, false, source.DefaultExtractBufferSize},
+		{"Buffer Size", source.DownloadOptions{Retries: 1, Backoff: time.Millisecond}, source.ExtractOptions{BufferSize: 4096}, false, 4096},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(dest)
+			atomic.StoreInt32(&requests, 0)
+			bufferSizes = nil
+
+			m := NewTar(server.URL + "/test.tar.gz")
+			m.Download = tt.download
+			m.Extract = tt.extract
+			err := m.PrepareFiles(dest)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Tar.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(bufferSizes) != len(m.GetFiles()) || bufferSizes[0] != tt.wantBufferSize {
+				t.Errorf("Tar.PrepareFiles() read the files with buffers of %v bytes, want %v", bufferSizes, tt.wantBufferSize)
+			}
+		})
+	}
+}
+
+// writeTar writes a gzipped tarball with the given entries to path.
+func writeTar(t *testing.T, path string, headers []*tar.Header) {
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	defer gz.Close()
+	tw := tar.NewWriter(gz)
+	defer tw.Close()
+
+	for _, header := range headers {
+		if header.Typeflag == tar.TypeReg {
+			header.Size = int64(len(header.Name))
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(header.Name))
+		}
+	}
+}
+
+func Test_untar(t *testing.T) {
+	dir := "./testdata/untar"
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name          string
+		headers       []*tar.Header
+		wantFiles     []string
+		wantAnomalies int
+		wantErr       bool
+	}{
+		{
+			"Dot Root And Links",
+			[]*tar.Header{
+				{Name: "./", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "./plugin/", Typeflag: tar.TypeDir, Mode: 0755},
+				{Name: "./plugin/plugin.php", Typeflag: tar.TypeReg, Mode: 0644},
+				{Name: "./plugin/link.php", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+				{Name: "./plugin/inc/aux.php", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			[]string{"testdata/untar/out/plugin.php", "testdata/untar/out/inc/_aux.php"},
+			1,
+			false,
+		},
+		{
+			"No Directories",
+			[]*tar.Header{
+				{Name: "readme.txt", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			[]string{"testdata/untar/out/readme.txt"},
+			0,
+			false,
+		},
+		{
+			"Path Traversal",
+			[]*tar.Header{
+				{Name: "../../evil.php", Typeflag: tar.TypeReg, Mode: 0644},
+			},
+			nil,
+			0,
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(dir)
+			os.MkdirAll(dir, 0755)
+			writeTar(t, dir+"/test.tar.gz", tt.headers)
+
+			files, checksums, anomalies, err := untar(dir+"/test.tar.gz", CompressionGzip, dir+"/out", sha256.New, 0, source.NameRename, source.ExtractLimits{})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("untar() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(files, tt.wantFiles) || len(checksums) != len(files) || len(anomalies) != tt.wantAnomalies {
+				t.Errorf("untar() = %v, %v, %v", files, checksums, anomalies)
+			}
+		})
+	}
+}
+
+func Test_untar_Limits(t *testing.T) {
+	dir := "./testdata/limits"
+	defer os.RemoveAll(dir)
+	os.MkdirAll(dir, 0755)
+
+	writeTar(t, dir+"/test.tar.gz", []*tar.Header{
+		{Name: "plugin/", Typeflag: tar.TypeDir, Mode: 0755},
+		{Name: "plugin/plugin.php", Typeflag: tar.TypeReg, Mode: 0644},
+		{Name: "plugin/readme.txt", Typeflag: tar.TypeReg, Mode: 0644},
+	})
+
+	_, _, _, err := untar(dir+"/test.tar.gz", CompressionGzip, dir+"/out", sha256.New, 0, source.NameRename, source.ExtractLimits{MaxFiles: 2})
+	if rejected, ok := err.(*source.RejectedError); !ok || rejected.Reason != source.RejectFileCount {
+		t.Errorf("untar() error = %v, want a rejected file count", err)
+	}
+	if _, err := os.Stat(dir + "/out"); err == nil {
+		t.Error("untar() extracted files of a rejected tarball")
+	}
+}
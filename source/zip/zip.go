@@ -2,23 +2,37 @@ package zip
 
 import (
 	"archive/zip"
-	"crypto/sha256"
-	"encoding/json"
+	"bufio"
+	"context"
 	"fmt"
+	"hash"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/wptide/pkg/source"
 )
 
 // Zip describes a zip file.
 type Zip struct {
-	url      string
-	dest     string
-	files    []string
-	checksum string
+	url        string
+	dest       string
+	files      []string
+	checksum   string
+	checksums  map[string]string
+	options    source.ChecksumOptions
+	anomalies  []source.Anomaly
+	timings    map[string]time.Duration
+	downloaded int64
+	NamePolicy source.NamePolicy      // (Optional) Handling of names that are not portable. Defaults to source.NameRename.
+	Scanner    source.Scanner         // (Optional) Scans the downloaded archive for malware before it is extracted.
+	Download   source.DownloadOptions // (Optional) Retries and timeout of the download.
+	Context    context.Context        // (Optional) Cancels the download, e.g. when the worker shuts down.
+	Limits     source.ExtractLimits   // (Optional) Size, file count and compression ratio limits of the archive.
+	Extract    source.ExtractOptions  // (Optional) Concurrency and buffer size of the extraction.
 }
 
 var (
@@ -35,25 +49,66 @@ var (
 // PrepareFiles downloads a zip file to a given destination and extracts info about the files in the zip.
 func (m *Zip) PrepareFiles(dest string) error {
 
+	newHash, err := source.NewHash(m.options.Algorithm)
+	if err != nil {
+		return err
+	}
+
+	if err := m.options.ValidateExclusions(); err != nil {
+		return err
+	}
+
 	// Prepare destination.
 	m.dest = dest
 	if _, err := os.Stat(m.dest); os.IsNotExist(err) {
 		os.Mkdir(m.dest, os.ModePerm)
 	}
 
-	err := downloadFile(m.url, m.dest+"/"+sourceFilename)
+	m.timings = make(map[string]time.Duration)
+
+	started := time.Now()
+	ctx := m.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	err = download(ctx, m.url, m.dest+"/"+sourceFilename, m.Download)
+	m.timings["download"] = time.Since(started)
 	if err != nil {
 		return err
 	}
 
+	if info, err := os.Stat(m.dest + "/" + sourceFilename); err == nil {
+		m.downloaded = info.Size()
+	}
+
+	if m.Scanner != nil {
+		started = time.Now()
+		err = source.ScanArchive(m.Scanner, m.dest+"/"+sourceFilename)
+		m.timings["scan"] = time.Since(started)
+		if err != nil {
+			return err
+		}
+	}
+
+	extract := m.Extract
+	if extract.Workers <= 0 {
+		extract.Workers = m.options.Workers
+	}
+
+	started = time.Now()
 	var checksums []string
-	m.files, checksums, err = unzip(m.dest+"/"+sourceFilename, m.dest+"/unzipped")
+	m.files, checksums, m.anomalies, err = unzip(m.dest+"/"+sourceFilename, m.dest+"/unzipped", newHash, extract, m.NamePolicy, m.Limits)
+	m.timings["extract"] = time.Since(started)
+	if rejected, ok := err.(*source.RejectedError); ok {
+		return rejected
+	}
 	if err != nil {
-		return err
+		return &source.ArchiveError{Err: err}
 	}
 
 	// Calculate checksum - uses same technique as Tide Audit Server.
-	m.checksum = combinedChecksum(checksums)
+	m.checksum = combinedChecksum(m.options.IncludedChecksums(m.files, checksums, m.dest+"/unzipped"), newHash)
+	m.checksums = source.FileChecksums(m.files, checksums)
 
 	return nil
 }
@@ -68,6 +123,26 @@ func (m Zip) GetFiles() []string {
 	return m.files
 }
 
+// GetFileChecksums returns the checksums of the files contained in the zip file.
+func (m Zip) GetFileChecksums() map[string]string {
+	return m.checksums
+}
+
+// GetAnomalies returns the file names that had to be normalized while extracting the zip file.
+func (m Zip) GetAnomalies() []source.Anomaly {
+	return m.anomalies
+}
+
+// GetTimings returns how long downloading and extracting the zip file took.
+func (m Zip) GetTimings() map[string]time.Duration {
+	return m.timings
+}
+
+// GetDownloaded returns the size of the downloaded zip file in bytes.
+func (m Zip) GetDownloaded() int64 {
+	return m.downloaded
+}
+
 // NewZip returns a new Zip source.
 func NewZip(url string) *Zip {
 	return &Zip{
@@ -75,9 +150,21 @@ func NewZip(url string) *Zip {
 	}
 }
 
+// NewZipWithOptions returns a new Zip source using the given checksum options.
+func NewZipWithOptions(url string, options source.ChecksumOptions) *Zip {
+	return &Zip{
+		url:     url,
+		options: options,
+	}
+}
+
 // downloadFile uses an HTTP request to get a file and save it to a given destination folder.
-func downloadFile(source string, destination string) error {
+func downloadFile(url string, destination string) error {
+	return download(context.Background(), url, destination, source.DownloadOptions{})
+}
 
+// download gets a file and saves it to a given destination, see source.Download.
+func download(ctx context.Context, url string, destination string, options source.DownloadOptions) error {
 	// Create destination
 	out, err := createFile(destination)
 	if err != nil {
@@ -85,42 +172,62 @@ func downloadFile(source string, destination string) error {
 	}
 	defer out.Close()
 
-	// Get file
-	resp, err := http.Get(source)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	// Write to file
-	_, err = ioCopy(out, resp.Body)
-
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return source.Download(ctx, url, out, options)
 }
 
 // unzip will un-compress a zip archive,
-// moving all files and folders to a destination directory
+// moving all files and folders to a destination directory.
+//
+// Each entry is read once: its checksum is calculated while it is written. Entries are
+// extracted by a bounded number of workers, the returned files and checksums are in the
+// order of the archive.
+//
+// Names that are not portable (e.g. Windows reserved names or backslash separators) are
+// normalized or skipped according to the policy and returned as anomalies.
+//
+// Archives that exceed the limits or have entries outside of the destination are rejected
+// with a *source.RejectedError before anything is extracted. The zip reader fails entries
+// that are larger than their declared size, so the declared sizes can be trusted.
 //
 // Props to https://golangcode.com/unzip-files-in-go/ and
 // http://blog.ralch.com/tutorial/golang-working-with-zip/
-func unzip(source, destination string) (filenames, checksums []string, err error) {
-	reader, err := zip.OpenReader(source)
+func unzip(src, destination string, newHash func() hash.Hash, options source.ExtractOptions, policy source.NamePolicy, limits source.ExtractLimits) (filenames, checksums []string, anomalies []source.Anomaly, err error) {
+	reader, err := zip.OpenReader(src)
 	if err != nil {
-		return filenames, checksums, err
+		return filenames, checksums, anomalies, err
 	}
+	defer reader.Close()
 
 	if err := makeDirectoryAll(destination, 0755); err != nil {
-		return filenames, checksums, err
+		return filenames, checksums, anomalies, err
 	}
 
-	rootPath := ""
+	// Normalize the names before anything is written.
+	normalizer := &source.NameNormalizer{Policy: policy}
+	names := make(map[*zip.File]string)
+	var files []*zip.File
 	for _, file := range reader.File {
-		path := file.Name
-		if !file.FileInfo().IsDir() {
+		if name, ok := normalizer.Normalize(file.Name); ok {
+			names[file] = name
+			files = append(files, file)
+		}
+	}
+
+	var uncompressed, compressed uint64
+	for _, file := range files {
+		uncompressed += file.UncompressedSize64
+		compressed += file.CompressedSize64
+	}
+	if err := limits.Check(len(files), uncompressed, compressed); err != nil {
+		return nil, nil, nil, err
+	}
+
+	rootPath := ""
+	var entries []*zip.File
+	for _, file := range files {
+		path := names[file]
+		if !file.FileInfo().IsDir() && !strings.HasSuffix(path, "/") {
+			entries = append(entries, file)
 			continue
 		}
 		if len(path) < len(rootPath) || rootPath == "" {
@@ -128,49 +235,113 @@ func unzip(source, destination string) (filenames, checksums []string, err error
 		}
 	}
 
-	for _, file := range reader.File {
-		path := filepath.Join(destination, strings.TrimPrefix(file.Name, rootPath))
-		if file.FileInfo().IsDir() {
-			makeDirectoryAll(path, file.Mode())
-			continue
+	paths := make(map[*zip.File]string)
+	for _, file := range files {
+		path, err := source.ContainedPath(destination, strings.TrimPrefix(names[file], rootPath))
+		if err != nil {
+			return nil, nil, nil, err
 		}
+		paths[file] = path
+	}
 
-		filenames = append(filenames, path)
+	// Create the folders before the entries are extracted concurrently.
+	for _, file := range files {
+		if file.FileInfo().IsDir() || strings.HasSuffix(names[file], "/") {
+			makeDirectoryAll(paths[file], file.Mode())
+		}
+	}
 
-		// This reads the file from the ZIP. It does not yet exist on the system.
-		fileReader, _ := file.Open()
+	for _, file := range entries {
+		filenames = append(filenames, paths[file])
+	}
+
+	checksums, err = extractEntries(entries, filenames, newHash, options)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return filenames, checksums, normalizer.Anomalies, nil
+}
 
-		h := sha256.New()
-		if _, err := ioCopy(h, fileReader); err != nil {
-			fileReader.Close()
-			return nil, nil, err
+// extractEntries writes each entry to its path using a bounded number of workers and returns
+// the checksums in the same order as the entries. No new entries are started after an error.
+func extractEntries(entries []*zip.File, paths []string, newHash func() hash.Hash, options source.ExtractOptions) ([]string, error) {
+	workers := options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	bufferSize := options.BufferSize
+	if bufferSize <= 0 {
+		bufferSize = source.DefaultExtractBufferSize
+	}
+
+	checksums := make([]string, len(entries))
+	errs := make([]error, len(entries))
+
+	indexes := make(chan int)
+	failed := make(chan struct{})
+	var once sync.Once
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				// Archives with backslash separators often have no directory entries.
+				makeDirectoryAll(filepath.Dir(paths[i]), 0755)
+
+				checksums[i], errs[i] = extractFile(entries[i], paths[i], newHash, bufferSize)
+				if errs[i] != nil {
+					once.Do(func() { close(failed) })
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range entries {
+		select {
+		case indexes <- i:
+		case <-failed:
+			break feed
 		}
-		checksums = append(checksums, fmt.Sprintf("%x", h.Sum(nil)))
+	}
+	close(indexes)
+	wg.Wait()
 
-		targetFile, err := openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
+	for _, err := range errs {
 		if err != nil {
-			fileReader.Close()
-			return nil, nil, err
+			return nil, err
 		}
+	}
 
-		// Because the zip package does not implement Seek(), we need to read it again..
-		fileReader, _ = file.Open()
+	return checksums, nil
+}
 
-		if _, err := ioCopy(targetFile, fileReader); err != nil {
-			fileReader.Close()
-			targetFile.Close()
-			return nil, nil, err
-		}
+// extractFile writes a single zip entry to the given path and returns its checksum, which is
+// calculated from the same read.
+func extractFile(file *zip.File, path string, newHash func() hash.Hash, bufferSize int) (string, error) {
+	// This reads the file from the ZIP. It does not yet exist on the system.
+	fileReader, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer fileReader.Close()
 
-		fileReader.Close()
-		targetFile.Close()
+	targetFile, err := openFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, file.Mode())
+	if err != nil {
+		return "", err
 	}
+	defer targetFile.Close()
 
-	return filenames, checksums, err
+	h := newHash()
+	if _, err := ioCopy(targetFile, io.TeeReader(bufio.NewReaderSize(fileReader, bufferSize), h)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-func combinedChecksum(sums []string) string {
-	sort.Strings(sums)
-	jsonChecksums, _ := json.Marshal(sums)
-	return fmt.Sprintf("%x", sha256.Sum256(jsonChecksums))
+func combinedChecksum(sums []string, newHash func() hash.Hash) string {
+	return source.CombinedChecksum(sums, newHash)
 }
@@ -1,13 +1,20 @@
 package zip
 
 import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/wptide/pkg/source"
 )
 
 var fileServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -16,6 +23,8 @@ var fileServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
 		w.Header().Set("Content-Type", "applicaiton/zip")
 		w.Header().Set("Content-Disposition", "attachment; filename='test.zip'")
 		http.ServeFile(w, r, "./testdata/test.zip")
+	case "/missing.zip":
+		http.NotFound(w, r)
 	}
 }))
 
@@ -53,7 +62,7 @@ func Test_combinedChecksum(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := combinedChecksum(tt.args.sums); got != tt.want {
+			if got := combinedChecksum(tt.args.sums, sha256.New); got != tt.want {
 				t.Errorf("combinedChecksum() = %v, want %v", got, tt.want)
 			}
 		})
@@ -105,13 +114,6 @@ func Test_unzip(t *testing.T) {
 		return errors.New("something went wrong")
 	}
 
-	errorCopySHA := func(dst io.Writer, src io.Reader) (written int64, err error) {
-		if reflect.TypeOf(dst).String() != "*sha256.digest" {
-			return io.Copy(dst, src)
-		}
-		return 0, errors.New("something went wrong")
-	}
-
 	errorCopyFile := func(dst io.Writer, src io.Reader) (written int64, err error) {
 		if reflect.TypeOf(dst).String() != "*os.File" {
 			return io.Copy(dst, src)
@@ -129,6 +131,8 @@ func Test_unzip(t *testing.T) {
 		makeDirectoryAll func(path string, perm os.FileMode) error
 		ioCopy           func(dst io.Writer, src io.Reader) (written int64, err error)
 		openFile         func(name string, flag int, perm os.FileMode) (*os.File, error)
+		workers          int
+		bufferSize       int
 	}
 	tests := []struct {
 		name          string
@@ -155,6 +159,45 @@ func Test_unzip(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"Unzip File - Success (Parallel)",
+			args{
+				source:      "./testdata/test.zip",
+				destination: "./testdata/unzipped",
+				workers:     4,
+			},
+			[]string{
+				"testdata/unzipped/function.php",
+				"testdata/unzipped/script.js",
+				"testdata/unzipped/style.css",
+			},
+			[]string{
+				"64a43b6ce686b50bbd7eb91b2b1346ed66e7053d42f7f7b9d5562d55a25d1321",
+				"9a8549c5d1f384593788dc25b1c236f8450534e8cb95833003786fef8201b92b",
+				"09679b8abb88b21dd1cf166e1d2745df7882a879d2b8672548f6dc0dc9572fe6",
+			},
+			false,
+		},
+		{
+			"Unzip File - Success (Small Buffer)",
+			args{
+				source:      "./testdata/test.zip",
+				destination: "./testdata/unzipped",
+				workers:     2,
+				bufferSize:  16,
+			},
+			[]string{
+				"testdata/unzipped/function.php",
+				"testdata/unzipped/script.js",
+				"testdata/unzipped/style.css",
+			},
+			[]string{
+				"64a43b6ce686b50bbd7eb91b2b1346ed66e7053d42f7f7b9d5562d55a25d1321",
+				"9a8549c5d1f384593788dc25b1c236f8450534e8cb95833003786fef8201b92b",
+				"09679b8abb88b21dd1cf166e1d2745df7882a879d2b8672548f6dc0dc9572fe6",
+			},
+			false,
+		},
 		{
 			"Unzip File - File",
 			args{
@@ -176,17 +219,6 @@ func Test_unzip(t *testing.T) {
 			nil,
 			true,
 		},
-		{
-			"Unzip - Faile Copy to Hasher",
-			args{
-				source:      "./testdata/test.zip",
-				destination: "./testdata/unzipped",
-				ioCopy:      errorCopySHA,
-			},
-			nil,
-			nil,
-			true,
-		},
 		{
 			"Unzip - Fail Open Target File",
 			args{
@@ -240,7 +272,7 @@ func Test_unzip(t *testing.T) {
 				}()
 			}
 
-			gotFilenames, gotChecksums, err := unzip(tt.args.source, tt.args.destination)
+			gotFilenames, gotChecksums, _, err := unzip(tt.args.source, tt.args.destination, sha256.New, source.ExtractOptions{Workers: tt.args.workers, BufferSize: tt.args.bufferSize}, source.NameRename, source.ExtractLimits{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("unzip() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -255,6 +287,182 @@ func Test_unzip(t *testing.T) {
 	}
 }
 
+func Test_unzip_Names(t *testing.T) {
+
+	archive := "./testdata/names.zip"
+	dest := "./testdata/names"
+
+	// Clean up after.
+	defer func() {
+		os.Remove(archive)
+		os.RemoveAll(dest)
+	}()
+
+	// Create an archive with names that are not portable.
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := zip.NewWriter(f)
+	for _, name := range []string{"plugin/plugin.php", `plugin\includes\class.php`, "plugin/aux.php", "plugin/readme.txt.", "plugin/_aux.php"} {
+		fw, _ := w.Create(name)
+		fw.Write([]byte(name))
+	}
+	w.Close()
+	f.Close()
+
+	tests := []struct {
+		name          string
+		policy        source.NamePolicy
+		wantFilenames []string
+		wantAnomalies []source.Anomaly
+	}{
+		{
+			"Rename",
+			source.NameRename,
+			[]string{
+				"testdata/names/plugin/plugin.php",
+				"testdata/names/plugin/includes/class.php",
+				"testdata/names/plugin/_aux.php",
+				"testdata/names/plugin/readme.txt",
+			},
+			[]source.Anomaly{
+				{Name: `plugin\includes\class.php`, Path: "plugin/includes/class.php", Reasons: []string{source.ReasonBackslash}},
+				{Name: "plugin/aux.php", Path: "plugin/_aux.php", Reasons: []string{source.ReasonReserved}},
+				{Name: "plugin/readme.txt.", Path: "plugin/readme.txt", Reasons: []string{source.ReasonTrailing}},
+				{Name: "plugin/_aux.php", Reasons: []string{source.ReasonDuplicate}, Skipped: true},
+			},
+		},
+		{
+			"Skip",
+			source.NameSkip,
+			[]string{
+				"testdata/names/plugin/plugin.php",
+				"testdata/names/plugin/_aux.php",
+			},
+			[]source.Anomaly{
+				{Name: `plugin\includes\class.php`, Reasons: []string{source.ReasonBackslash}, Skipped: true},
+				{Name: "plugin/aux.php", Reasons: []string{source.ReasonReserved}, Skipped: true},
+				{Name: "plugin/readme.txt.", Reasons: []string{source.ReasonTrailing}, Skipped: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(dest)
+
+			gotFilenames, gotChecksums, gotAnomalies, err := unzip(archive, dest, sha256.New, source.ExtractOptions{Workers: 1}, tt.policy, source.ExtractLimits{})
+			if err != nil {
+				t.Fatalf("unzip() error = %v", err)
+			}
+			if !reflect.DeepEqual(gotFilenames, tt.wantFilenames) {
+				t.Errorf("unzip() gotFilenames = %v, want %v", gotFilenames, tt.wantFilenames)
+			}
+			if len(gotChecksums) != len(tt.wantFilenames) {
+				t.Errorf("unzip() got %d checksums, want %d", len(gotChecksums), len(tt.wantFilenames))
+			}
+			if !reflect.DeepEqual(gotAnomalies, tt.wantAnomalies) {
+				t.Errorf("unzip() gotAnomalies = %v, want %v", gotAnomalies, tt.wantAnomalies)
+			}
+			for _, file := range gotFilenames {
+				if _, err := os.Stat(file); err != nil {
+					t.Errorf("unzip() did not extract %v", file)
+				}
+			}
+		})
+	}
+}
+
+func Test_unzip_Limits(t *testing.T) {
+	dir := "./testdata/limits"
+	defer os.RemoveAll(dir)
+
+	zeros := bytes.Repeat([]byte{0}, 2<<20)
+
+	tests := []struct {
+		name   string
+		files  map[string][]byte
+		limits source.ExtractLimits
+		want   string
+	}{
+		{"Path Traversal", map[string][]byte{"plugin/plugin.php": nil, "../../evil.php": nil}, source.ExtractLimits{}, source.RejectPathTraversal},
+		{"Too Many Files", map[string][]byte{"a.php": nil, "b.php": nil, "c.php": nil}, source.ExtractLimits{MaxFiles: 2}, source.RejectFileCount},
+		{"Too Large", map[string][]byte{"a.php": []byte("<?php // plugin")}, source.ExtractLimits{MaxSize: 10}, source.RejectSize},
+		{"Zip Bomb", map[string][]byte{"zeros.bin": zeros}, source.ExtractLimits{}, source.RejectCompressionRate},
+		{"Within Limits", map[string][]byte{"zeros.bin": zeros}, source.ExtractLimits{MaxRatio: -1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.RemoveAll(dir)
+			os.MkdirAll(dir, 0755)
+
+			archive := dir + "/test.zip"
+			f, err := os.Create(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := zip.NewWriter(f)
+			for name, data := range tt.files {
+				fw, err := w.Create(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fw.Write(data)
+			}
+			w.Close()
+			f.Close()
+
+			_, _, _, err = unzip(archive, dir+"/out", sha256.New, source.ExtractOptions{Workers: 1}, source.NameRename, tt.limits)
+
+			got := ""
+			if rejected, ok := err.(*source.RejectedError); ok {
+				got = rejected.Reason
+			} else if err != nil {
+				t.Fatalf("unzip() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("unzip() error = %v, want %v", err, tt.want)
+			}
+
+			// Nothing is written for rejected archives.
+			if _, statErr := os.Stat(dir + "/out/plugin.php"); tt.want != "" && statErr == nil {
+				t.Error("unzip() extracted files of a rejected archive")
+			}
+		})
+	}
+}
+
+func Test_unzip_Corrupt(t *testing.T) {
+	dir := "./testdata/corrupt"
+	defer os.RemoveAll(dir)
+	os.MkdirAll(dir, 0755)
+
+	// Create an archive whose last entry does not match its CRC-32.
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := 0; i < 8; i++ {
+		fw, err := w.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("plugin/file-%d.php", i), Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(fmt.Sprintf("<?php // file %d", i)))
+	}
+	w.Close()
+
+	archive := dir + "/test.zip"
+	data := bytes.Replace(buf.Bytes(), []byte("<?php // file 7"), []byte("<?php // evil 7"), 1)
+	if err := ioutil.WriteFile(archive, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 4} {
+		_, _, _, err := unzip(archive, dir+"/out", sha256.New, source.ExtractOptions{Workers: workers}, source.NameRename, source.ExtractLimits{})
+		if err != zip.ErrChecksum {
+			t.Errorf("unzip() workers = %d, error = %v, want %v", workers, err, zip.ErrChecksum)
+		}
+	}
+}
+
 func TestZip_PrepareFiles(t *testing.T) {
 
 	dest := "./testdata/download/"
@@ -275,6 +483,7 @@ func TestZip_PrepareFiles(t *testing.T) {
 		dest     string
 		files    []string
 		checksum string
+		options  source.ChecksumOptions
 	}
 	type args struct {
 		dest           string
@@ -333,6 +542,18 @@ func TestZip_PrepareFiles(t *testing.T) {
 			},
 			true,
 		},
+		{
+			"Invalid Exclusion",
+			fields{
+				url:     fileServer.URL + "/test.zip",
+				dest:    dest,
+				options: source.ChecksumOptions{Exclude: []string{"[a-"}},
+			},
+			args{
+				dest: dest,
+			},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -360,10 +581,57 @@ func TestZip_PrepareFiles(t *testing.T) {
 				dest:     tt.fields.dest,
 				files:    tt.fields.files,
 				checksum: tt.fields.checksum,
+				options:  tt.fields.options,
 			}
-			if err := m.PrepareFiles(tt.args.dest); (err != nil) != tt.wantErr {
+			err := m.PrepareFiles(tt.args.dest)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("Zip.PrepareFiles() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if err == nil && m.GetDownloaded() <= 0 {
+				t.Errorf("Zip.GetDownloaded() = %v, want the size of the zip file", m.GetDownloaded())
+			}
+
+			// Each file keeps its own checksum.
+			for file, sum := range m.GetFileChecksums() {
+				data, _ := ioutil.ReadFile(file)
+				if want := fmt.Sprintf("%x", sha256.Sum256(data)); sum != want {
+					t.Errorf("Zip.GetFileChecksums() %v = %v, want %v", file, sum, want)
+				}
+			}
+		})
+	}
+}
+
+func TestZip_PrepareFiles_ErrorTypes(t *testing.T) {
+
+	dest := "./testdata/error/"
+
+	// Clean up after.
+	defer func() {
+		os.RemoveAll(dest)
+	}()
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"Unreachable", "https://error.err/error.zip", "download"},
+		{"Not Found", fileServer.URL + "/missing.zip", "download"},
+		{"Invalid Archive", fileServer.URL + "/error.zip", "archive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			switch NewZip(tt.url).PrepareFiles(dest).(type) {
+			case *source.DownloadError:
+				got = "download"
+			case *source.ArchiveError:
+				got = "archive"
+			}
+			if got != tt.want {
+				t.Errorf("Zip.PrepareFiles() error type = %v, want %v", got, tt.want)
+			}
 		})
 	}
 }
@@ -377,14 +645,9 @@ func Test_downloadFile(t *testing.T) {
 		os.Remove(dest)
 	}()
 
-	errorCopy := func(dst io.Writer, src io.Reader) (written int64, err error) {
-		return 0, errors.New("something went wrong")
-	}
-
 	type args struct {
 		source      string
 		destination string
-		ioCopy      func(dst io.Writer, src io.Reader) (written int64, err error)
 	}
 	tests := []struct {
 		name    string
@@ -400,11 +663,10 @@ func Test_downloadFile(t *testing.T) {
 			false,
 		},
 		{
-			"Download - Fail Copy to Target",
+			"Download - Not Found",
 			args{
-				source:      fileServer.URL + "/test.zip",
+				source:      fileServer.URL + "/missing.zip",
 				destination: dest,
-				ioCopy:      errorCopy,
 			},
 			true,
 		},
@@ -412,15 +674,6 @@ func Test_downloadFile(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 
-			// Test io.Copy error.
-			if tt.args.ioCopy != nil {
-				oldCopy := ioCopy
-				ioCopy = tt.args.ioCopy
-				defer func() {
-					ioCopy = oldCopy
-				}()
-			}
-
 			if err := downloadFile(tt.args.source, tt.args.destination); (err != nil) != tt.wantErr {
 				t.Errorf("downloadFile() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -0,0 +1,178 @@
+package azure
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	tideStorage "github.com/wptide/pkg/storage"
+)
+
+var (
+	fileCreate = os.Create
+	fileOpen   = os.Open
+)
+
+// Credentials authenticate the provider with Azure Storage, with either a SAS token or the
+// managed identity of the host.
+type Credentials struct {
+	SASToken        string // SAS token of the account or the container, e.g. "sv=2018-03-28&sp=rwc&sig=...".
+	ManagedIdentity bool   // Authenticates with the managed identity of the VM, scale set or App Service.
+	ClientID        string // (Optional) Client ID of a user-assigned managed identity. Defaults to the system-assigned identity.
+	Endpoint        string // (Optional) URL of the blob service, e.g. of the Azurite emulator. Defaults to "https://<account>.blob.core.windows.net/".
+}
+
+// Provider describes the Azure Blob Storage provider.
+//
+// Files are uploaded as block blobs, in blocks uploaded in parallel for large reports. Each blob
+// carries the SHA256 checksum of the file and the metadata of the upload context, see
+// storage.WithMetadata. Metadata names must be C# identifiers, so "-" in names is stored as "_",
+// e.g. "audit_type".
+type Provider struct {
+	client    BlobClient
+	account   string
+	container string
+
+	BlockSize   int64             // (Optional) Size of the blocks of an upload in bytes. Defaults to a size chosen from the size of the file.
+	Parallelism uint16            // (Optional) Blocks uploaded at once. Defaults to 5.
+	Metadata    map[string]string // (Optional) Metadata of all uploaded blobs.
+}
+
+// Kind returns the kind of provider.
+func (p Provider) Kind() string {
+	return "azure"
+}
+
+// CollectionRef returns the name of the container.
+func (p Provider) CollectionRef() string {
+	return p.container
+}
+
+// UploadFile puts the given file to the container.
+func (p Provider) UploadFile(filename, reference string) error {
+	return p.UploadFileContext(context.Background(), filename, reference)
+}
+
+// UploadFileContext puts the given file to the container with the metadata of the context, and
+// aborts the upload when the context is done.
+func (p Provider) UploadFileContext(ctx context.Context, filename, reference string) error {
+
+	// Open file for writing to Blob Storage.
+	file, err := fileOpen(filename)
+
+	// Error if file cannot be opened.
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	opts, err := p.uploadOptions(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	return p.client.Upload(ctx, p.container, reference, file, opts)
+}
+
+// DownloadFile gets the file from the container.
+func (p Provider) DownloadFile(reference, filename string) error {
+	return p.DownloadFileContext(context.Background(), reference, filename)
+}
+
+// DownloadFileContext gets the file from the container and aborts the download when the context
+// is done.
+func (p Provider) DownloadFileContext(ctx context.Context, reference, filename string) error {
+
+	// Create file for writing.
+	file, err := fileCreate(filename)
+
+	// Error if file cannot be created.
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return p.client.Download(ctx, p.container, reference, file)
+}
+
+// Exists implements storage.Checker.
+func (p Provider) Exists(reference string) (bool, error) {
+	return p.client.Exists(context.Background(), p.container, reference)
+}
+
+// Delete implements storage.Deleter.
+func (p Provider) Delete(reference string) error {
+	return p.client.Delete(context.Background(), p.container, reference)
+}
+
+// uploadOptions returns the options to upload the file: its checksum and the metadata of the
+// provider and the context. The file is read to its end and rewound.
+func (p Provider) uploadOptions(ctx context.Context, file *os.File) (UploadOptions, error) {
+	sha := sha256.New()
+	if _, err := io.Copy(sha, file); err != nil {
+		return UploadOptions{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return UploadOptions{}, err
+	}
+
+	contextMetadata := tideStorage.MetadataFromContext(ctx)
+
+	// The encoding is a header of the blob.
+	encoding := contextMetadata[tideStorage.MetadataContentEncoding]
+	delete(contextMetadata, tideStorage.MetadataContentEncoding)
+
+	metadata := map[string]string{}
+	for key, value := range p.Metadata {
+		metadata[metadataName(key)] = value
+	}
+	for key, value := range contextMetadata {
+		metadata[metadataName(key)] = value
+	}
+	metadata[metadataName(tideStorage.MetadataChecksum)] = hex.EncodeToString(sha.Sum(nil))
+
+	return UploadOptions{
+		ContentType:     "application/json",
+		ContentEncoding: encoding,
+		Metadata:        metadata,
+		BlockSize:       p.BlockSize,
+		Parallelism:     p.Parallelism,
+	}, nil
+}
+
+// metadataName returns the name a metadata key is stored with.
+func metadataName(key string) string {
+	return strings.Replace(key, "-", "_", -1)
+}
+
+// NewBlobStorageProvider creates a new Azure Blob Storage provider for a container of the
+// account, and creates the container if it doesn't exist. Credentials that can't create
+// containers, e.g. a SAS token of the container, use the existing container.
+func NewBlobStorageProvider(ctx context.Context, account, container string, creds Credentials) (*Provider, error) {
+	client, err := BlobStorageClient(account, creds)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlobStorageProviderWithClient(ctx, client, account, container)
+}
+
+// NewBlobStorageProviderWithClient creates a new Azure Blob Storage provider with a client, and
+// creates the container if it doesn't exist.
+func NewBlobStorageProviderWithClient(ctx context.Context, client BlobClient, account, container string) (*Provider, error) {
+	if container == "" {
+		return nil, errors.New("azure: a container is required")
+	}
+	if err := client.CreateContainer(ctx, container); err != nil {
+		return nil, err
+	}
+
+	return &Provider{
+		client:    client,
+		account:   account,
+		container: container,
+	}, nil
+}
@@ -0,0 +1,245 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	tideStorage "github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/storagetest"
+)
+
+// memoryClient keeps the blobs of its containers in memory.
+type memoryClient struct {
+	containers map[string]map[string][]byte
+	opts       UploadOptions
+	err        error
+}
+
+func newMemoryClient() *memoryClient {
+	return &memoryClient{containers: make(map[string]map[string][]byte)}
+}
+
+func (m *memoryClient) CreateContainer(ctx context.Context, container string) error {
+	if m.err != nil {
+		return m.err
+	}
+	if m.containers[container] == nil {
+		m.containers[container] = make(map[string][]byte)
+	}
+	return nil
+}
+
+func (m *memoryClient) Upload(ctx context.Context, container, blob string, file *os.File, opts UploadOptions) error {
+	if m.err != nil {
+		return m.err
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	m.containers[container][blob] = data
+	m.opts = opts
+	return nil
+}
+
+func (m *memoryClient) Download(ctx context.Context, container, blob string, file *os.File) error {
+	if m.err != nil {
+		return m.err
+	}
+	data, ok := m.containers[container][blob]
+	if !ok {
+		return errors.New("blob not found")
+	}
+	_, err := file.Write(data)
+	return err
+}
+
+func (m *memoryClient) Exists(ctx context.Context, container, blob string) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	_, ok := m.containers[container][blob]
+	return ok, nil
+}
+
+func (m *memoryClient) Delete(ctx context.Context, container, blob string) error {
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.containers[container], blob)
+	return nil
+}
+
+func mockFileOpen(name string) (*os.File, error) {
+	switch name {
+	case "error.txt":
+		return nil, errors.New("something went wrong")
+	default:
+		return os.Open("./testdata/raw.txt")
+	}
+}
+
+func mockFileCreate(name string) (*os.File, error) {
+	switch name {
+	case "error.txt":
+		return nil, errors.New("something went wrong")
+	default:
+		return ioutil.TempFile("", "azure")
+	}
+}
+
+func TestProvider_Kind(t *testing.T) {
+	t.Run("Storage Provider Kind", func(t *testing.T) {
+		p := Provider{}
+		if got := p.Kind(); got != "azure" {
+			t.Errorf("Provider.Kind() = %v, want azure", got)
+		}
+	})
+}
+
+func TestProvider_CollectionRef(t *testing.T) {
+	t.Run("Collection Reference", func(t *testing.T) {
+		p := Provider{container: "reports"}
+		if got := p.CollectionRef(); got != "reports" {
+			t.Errorf("Provider.CollectionRef() = %v, want reports", got)
+		}
+	})
+}
+
+func TestProvider_UploadFile(t *testing.T) {
+	fileOpen = mockFileOpen
+	defer func() { fileOpen = os.Open }()
+
+	tests := []struct {
+		name      string
+		filename  string
+		clientErr error
+		wantErr   bool
+	}{
+		{"Upload", "upload.txt", nil, false},
+		{"Client Error", "upload.txt", errors.New("service unavailable"), true},
+		{"File Open Error", "error.txt", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMemoryClient()
+			p, _ := NewBlobStorageProviderWithClient(context.Background(), client, "tide", "reports")
+			client.err = tt.clientErr
+
+			if err := p.UploadFile(tt.filename, "report.json"); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.UploadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_DownloadFile(t *testing.T) {
+	fileCreate = mockFileCreate
+	defer func() { fileCreate = os.Create }()
+
+	client := newMemoryClient()
+	p, _ := NewBlobStorageProviderWithClient(context.Background(), client, "tide", "reports")
+	client.containers["reports"]["report.json"] = []byte("{}")
+
+	tests := []struct {
+		name      string
+		reference string
+		filename  string
+		wantErr   bool
+	}{
+		{"Download", "report.json", "download.json", false},
+		{"Missing Blob", "missing.json", "download.json", true},
+		{"File Create Error", "report.json", "error.txt", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := p.DownloadFile(tt.reference, tt.filename); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.DownloadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProvider_UploadOptions(t *testing.T) {
+	client := newMemoryClient()
+	p, _ := NewBlobStorageProviderWithClient(context.Background(), client, "tide", "reports")
+	p.BlockSize = 4 << 20
+	p.Parallelism = 2
+	p.Metadata = map[string]string{"environment": "staging", tideStorage.MetadataAuditType: "default"}
+
+	ctx := tideStorage.WithMetadata(context.Background(), tideStorage.Metadata{
+		tideStorage.MetadataAuditType:   "phpcs_wordpress",
+		tideStorage.MetadataProjectSlug: "akismet",
+	})
+	if err := p.UploadFileContext(ctx, "./testdata/raw.txt", "report.json"); err != nil {
+		t.Fatalf("Provider.UploadFileContext() error = %v", err)
+	}
+
+	want := UploadOptions{
+		ContentType: "application/json",
+		Metadata: map[string]string{
+			"environment":  "staging",
+			"audit_type":   "phpcs_wordpress",
+			"project_slug": "akismet",
+			"checksum":     "eb0ae3b23e1d820c50b5785f617d3118a8e42061ab60de564eb7b7edc7de4093",
+		},
+		BlockSize:   4 << 20,
+		Parallelism: 2,
+	}
+	if !reflect.DeepEqual(client.opts, want) {
+		t.Errorf("Provider.UploadFileContext() options = %v, want %v", client.opts, want)
+	}
+
+	// The encoding is a header of the blob, not metadata.
+	gzipped := tideStorage.WithMetadata(ctx, tideStorage.Metadata{tideStorage.MetadataContentEncoding: tideStorage.EncodingGzip})
+	if err := p.UploadFileContext(gzipped, "./testdata/raw.txt", "report.json"); err != nil {
+		t.Fatalf("Provider.UploadFileContext() error = %v", err)
+	}
+	if _, ok := client.opts.Metadata["content_encoding"]; ok || client.opts.ContentEncoding != "gzip" {
+		t.Errorf("Provider.UploadFileContext() encoding = %q, metadata %v, want gzip", client.opts.ContentEncoding, client.opts.Metadata)
+	}
+
+	// The checksum is computed without consuming the file.
+	if got := string(client.containers["reports"]["report.json"]); got != "Dummy file to test uploading.\n" {
+		t.Errorf("Provider.UploadFileContext() uploaded %q", got)
+	}
+}
+
+func TestNewBlobStorageProviderWithClient(t *testing.T) {
+	tests := []struct {
+		name      string
+		container string
+		clientErr error
+		wantErr   bool
+	}{
+		{"Container", "reports", nil, false},
+		{"No Container", "", nil, true},
+		{"Create Error", "reports", errors.New("authorization failure"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMemoryClient()
+			client.err = tt.clientErr
+
+			p, err := NewBlobStorageProviderWithClient(context.Background(), client, "tide", tt.container)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewBlobStorageProviderWithClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (p.CollectionRef() != tt.container || client.containers[tt.container] == nil) {
+				t.Errorf("NewBlobStorageProviderWithClient() did not create container %v", tt.container)
+			}
+		})
+	}
+}
+
+func TestProvider_Conformance(t *testing.T) {
+	p, err := NewBlobStorageProviderWithClient(context.Background(), newMemoryClient(), "tide", "reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storagetest.Run(t, p)
+}
@@ -0,0 +1,24 @@
+package azure
+
+import (
+	"context"
+	"os"
+)
+
+// BlobClient interface describes the blob operations of the provider.
+type BlobClient interface {
+	CreateContainer(ctx context.Context, container string) error
+	Upload(ctx context.Context, container, blob string, file *os.File, opts UploadOptions) error
+	Download(ctx context.Context, container, blob string, file *os.File) error
+	Exists(ctx context.Context, container, blob string) (bool, error)
+	Delete(ctx context.Context, container, blob string) error
+}
+
+// UploadOptions are the options of an uploaded block blob.
+type UploadOptions struct {
+	ContentType     string
+	ContentEncoding string // Encoding of the blob, e.g. "gzip".
+	Metadata        map[string]string
+	BlockSize       int64  // Size of the blocks of the upload in bytes. 0 uses the default of the client.
+	Parallelism     uint16 // Blocks uploaded at once. 0 uses the default of the client.
+}
@@ -0,0 +1,187 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/Azure/go-autorest/autorest/adal"
+	"github.com/wptide/pkg/log"
+)
+
+// storageResource is the resource of the tokens of managed identities.
+const storageResource = "https://storage.azure.com/"
+
+// refreshMargin is how long before they expire tokens of managed identities are refreshed.
+const refreshMargin = 5 * time.Minute
+
+// Provides a way to return alternate transfers. Used for testing.
+var uploadFileToBlockBlob = azblob.UploadFileToBlockBlob
+var downloadBlobToFile = azblob.DownloadBlobToFile
+
+// msiToken returns the token of the managed identity of the host. The client ID selects a
+// user-assigned identity.
+func msiToken(clientID string) (*adal.ServicePrincipalToken, error) {
+	endpoint, err := adal.GetMSIVMEndpoint()
+	if err != nil {
+		return nil, err
+	}
+	if clientID != "" {
+		return adal.NewServicePrincipalTokenFromMSIWithUserAssignedID(endpoint, storageResource, clientID)
+	}
+	return adal.NewServicePrincipalTokenFromMSI(endpoint, storageResource)
+}
+
+// blobStorage is a BlobClient of the blob service of an account.
+type blobStorage struct {
+	service azblob.ServiceURL
+}
+
+// CreateContainer creates the container, unless it exists. The container is private.
+func (b blobStorage) CreateContainer(ctx context.Context, container string) error {
+	_, err := b.service.NewContainerURL(container).Create(ctx, azblob.Metadata{}, azblob.PublicAccessNone)
+	if err != nil && !isContainerAvailable(err) {
+		return err
+	}
+	return nil
+}
+
+// Service codes of a container that could not be created because it exists, or because the
+// credentials are only authorized for the container itself, e.g. a SAS token of the container.
+var containerAvailableCodes = map[azblob.ServiceCodeType]bool{
+	azblob.ServiceCodeContainerAlreadyExists:                  true,
+	azblob.ServiceCodeType("AuthorizationFailure"):            true,
+	azblob.ServiceCodeType("AuthorizationPermissionMismatch"): true,
+}
+
+// isContainerAvailable reports whether the error of creating a container leaves a container
+// that can be used. Uploads to a container that does not exist fail on their own.
+func isContainerAvailable(err error) bool {
+	sErr, ok := err.(azblob.StorageError)
+	return ok && containerAvailableCodes[sErr.ServiceCode()]
+}
+
+// Upload uploads the file as a block blob. Files larger than the block size are uploaded in
+// blocks, so a failed block is retried without uploading the file again.
+func (b blobStorage) Upload(ctx context.Context, container, blob string, file *os.File, opts UploadOptions) error {
+	blockBlob := b.service.NewContainerURL(container).NewBlockBlobURL(blob)
+
+	_, err := uploadFileToBlockBlob(ctx, file, blockBlob, azblob.UploadToBlockBlobOptions{
+		BlockSize:       opts.BlockSize,
+		Parallelism:     opts.Parallelism,
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: opts.ContentType, ContentEncoding: opts.ContentEncoding},
+		Metadata:        azblob.Metadata(opts.Metadata),
+	})
+	return err
+}
+
+// Download downloads the blob to the file.
+func (b blobStorage) Download(ctx context.Context, container, blob string, file *os.File) error {
+	blobURL := b.service.NewContainerURL(container).NewBlobURL(blob)
+	return downloadBlobToFile(ctx, blobURL, 0, azblob.CountToEnd, file, azblob.DownloadFromBlobOptions{})
+}
+
+// Exists reports whether the blob exists.
+func (b blobStorage) Exists(ctx context.Context, container, blob string) (bool, error) {
+	blobURL := b.service.NewContainerURL(container).NewBlobURL(blob)
+	_, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{})
+	if isBlobNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete deletes the blob and its snapshots. Deleting a missing blob is not an error.
+func (b blobStorage) Delete(ctx context.Context, container, blob string) error {
+	blobURL := b.service.NewContainerURL(container).NewBlobURL(blob)
+	_, err := blobURL.Delete(ctx, azblob.DeleteSnapshotsOptionInclude, azblob.BlobAccessConditions{})
+	if err != nil && !isBlobNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// isBlobNotFound reports whether the error is for a missing blob.
+func isBlobNotFound(err error) bool {
+	sErr, ok := err.(azblob.StorageError)
+	return ok && sErr.ServiceCode() == azblob.ServiceCodeBlobNotFound
+}
+
+// BlobStorageClient returns a BlobClient of the blob service of the account, authenticated with
+// the credentials.
+func BlobStorageClient(account string, creds Credentials) (BlobClient, error) {
+	endpoint := creds.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + account + ".blob.core.windows.net/"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := creds.credential(u)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := azblob.NewPipeline(credential, azblob.PipelineOptions{})
+	return blobStorage{service: azblob.NewServiceURL(*u, pipeline)}, nil
+}
+
+// credential returns the credential of the requests to the blob service. SAS tokens are added to
+// the query of the service URL, which is kept by the URLs of containers and blobs.
+func (c Credentials) credential(u *url.URL) (azblob.Credential, error) {
+	switch {
+	case c.SASToken != "" && c.ManagedIdentity:
+		return nil, errors.New("azure: use either a SAS token or a managed identity")
+	case c.SASToken != "":
+		query, err := url.ParseQuery(strings.TrimPrefix(c.SASToken, "?"))
+		if err != nil {
+			return nil, err
+		}
+		u.RawQuery = query.Encode()
+		return azblob.NewAnonymousCredential(), nil
+	case c.ManagedIdentity:
+		return managedIdentityCredential(c.ClientID)
+	default:
+		return nil, errors.New("azure: a SAS token or a managed identity is required")
+	}
+}
+
+// managedIdentityCredential returns a credential with the token of the managed identity of the
+// host, refreshed before it expires.
+func managedIdentityCredential(clientID string) (azblob.Credential, error) {
+	spt, err := msiToken(clientID)
+	if err != nil {
+		return nil, err
+	}
+	spt.SetRefreshWithin(refreshMargin)
+	if err := spt.EnsureFresh(); err != nil {
+		return nil, err
+	}
+
+	// The refresher is called at once and then when the token is about to expire.
+	return azblob.NewTokenCredential(spt.Token().AccessToken, func(credential azblob.TokenCredential) time.Duration {
+		if err := spt.EnsureFresh(); err != nil {
+			// Try again soon, the current token may still be valid.
+			log.Log("azure", "could not refresh managed identity token: "+err.Error())
+			return time.Minute
+		}
+		token := spt.Token()
+		credential.SetToken(token.AccessToken)
+		return refreshIn(token.Expires())
+	}), nil
+}
+
+// refreshIn returns how long until a token that expires at the time is refreshed.
+func refreshIn(expires time.Time) time.Duration {
+	d := time.Until(expires) - refreshMargin
+	if d < time.Minute {
+		return time.Minute
+	}
+	return d
+}
@@ -0,0 +1,136 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+func TestCredentials_credential(t *testing.T) {
+	tests := []struct {
+		name      string
+		creds     Credentials
+		wantQuery string
+		wantErr   bool
+	}{
+		{"SAS Token", Credentials{SASToken: "sv=2018-03-28&sp=rwc&sig=abc%3D"}, "sig=abc%3D&sp=rwc&sv=2018-03-28", false},
+		{"SAS Token With Question Mark", Credentials{SASToken: "?sv=2018-03-28&sig=abc"}, "sig=abc&sv=2018-03-28", false},
+		{"Invalid SAS Token", Credentials{SASToken: "sig=%zz"}, "", true},
+		{"SAS Token And Managed Identity", Credentials{SASToken: "sig=abc", ManagedIdentity: true}, "", true},
+		{"No Credentials", Credentials{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse("https://tide.blob.core.windows.net/")
+
+			_, err := tt.creds.credential(u)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Credentials.credential() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if u.RawQuery != tt.wantQuery {
+				t.Errorf("Credentials.credential() query = %v, want %v", u.RawQuery, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestBlobStorage_Upload(t *testing.T) {
+	var gotURL url.URL
+	var gotOpts azblob.UploadToBlockBlobOptions
+	uploadFileToBlockBlob = func(ctx context.Context, file *os.File, blockBlobURL azblob.BlockBlobURL, o azblob.UploadToBlockBlobOptions) (azblob.CommonResponse, error) {
+		gotURL, gotOpts = blockBlobURL.URL(), o
+		return nil, nil
+	}
+	defer func() { uploadFileToBlockBlob = azblob.UploadFileToBlockBlob }()
+
+	client, err := BlobStorageClient("tide", Credentials{SASToken: "sig=abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := UploadOptions{
+		ContentType:     "application/json",
+		ContentEncoding: "gzip",
+		Metadata:        map[string]string{"checksum": "abc"},
+		BlockSize:       4 << 20,
+		Parallelism:     2,
+	}
+	if err := client.Upload(context.Background(), "reports", "report.json", nil, opts); err != nil {
+		t.Fatalf("blobStorage.Upload() error = %v", err)
+	}
+
+	if want := "https://tide.blob.core.windows.net/reports/report.json?sig=abc"; gotURL.String() != want {
+		t.Errorf("blobStorage.Upload() URL = %v, want %v", gotURL.String(), want)
+	}
+	want := azblob.UploadToBlockBlobOptions{
+		BlockSize:       4 << 20,
+		Parallelism:     2,
+		BlobHTTPHeaders: azblob.BlobHTTPHeaders{ContentType: "application/json", ContentEncoding: "gzip"},
+		Metadata:        azblob.Metadata{"checksum": "abc"},
+	}
+	if !reflect.DeepEqual(gotOpts, want) {
+		t.Errorf("blobStorage.Upload() options = %v, want %v", gotOpts, want)
+	}
+}
+
+func Test_refreshIn(t *testing.T) {
+	tests := []struct {
+		name    string
+		expires time.Duration
+		want    time.Duration
+	}{
+		{"Valid", time.Hour, 55 * time.Minute},
+		{"Expiring", 3 * time.Minute, time.Minute},
+		{"Expired", -time.Minute, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Allow for the time passed since the expiry was computed.
+			got := refreshIn(time.Now().Add(tt.expires))
+			if got > tt.want || got < tt.want-time.Second {
+				t.Errorf("refreshIn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// storageError is a StorageError with a service code.
+type storageError struct {
+	azblob.StorageError
+	code azblob.ServiceCodeType
+}
+
+func (e storageError) Error() string {
+	return string(e.code)
+}
+
+func (e storageError) ServiceCode() azblob.ServiceCodeType {
+	return e.code
+}
+
+func Test_isContainerAvailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Exists", storageError{code: azblob.ServiceCodeContainerAlreadyExists}, true},
+		{"Container SAS", storageError{code: "AuthorizationFailure"}, true},
+		{"No Create Permission", storageError{code: "AuthorizationPermissionMismatch"}, true},
+		{"Other Service Error", storageError{code: "AccountIsDisabled"}, false},
+		{"Other Error", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isContainerAvailable(tt.err); got != tt.want {
+				t.Errorf("isContainerAvailable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Manifest is the index of a file that was uploaded in chunks.
+type Manifest struct {
+	Reference string  `json:"reference"` // Reference of the original file.
+	Size      int64   `json:"size"`
+	ChunkSize int64   `json:"chunk_size"`
+	Checksum  string  `json:"checksum"`           // SHA256 of the original file.
+	Encoding  string  `json:"encoding,omitempty"` // Content encoding of the original file, e.g. EncodingGzip.
+	Chunks    []Chunk `json:"chunks"`
+}
+
+// Chunk is a part of a file that was uploaded in chunks.
+type Chunk struct {
+	Reference string `json:"reference"`
+	Size      int64  `json:"size"`
+	Checksum  string `json:"checksum"` // SHA256 of the chunk.
+}
+
+// ManifestReference returns the reference of the manifest for a file uploaded in chunks.
+func ManifestReference(reference string) string {
+	return reference + ".manifest.json"
+}
+
+// UploadChunked splits a file into chunks of chunkSize bytes and uploads the chunks followed by
+// a manifest that lists them. Consumers use the manifest (see ManifestReference) to download the file.
+// Gzipped files, see GzipFile, are split as they are and the manifest records their encoding,
+// so that the chunks are joined before the file is decompressed.
+func UploadChunked(provider Provider, filename, reference string, chunkSize int64) (*Manifest, error) {
+	if chunkSize <= 0 {
+		return nil, errors.New("chunk size must be positive")
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest := &Manifest{
+		Reference: reference,
+		ChunkSize: chunkSize,
+	}
+	total := sha256.New()
+
+	for i := 0; ; i++ {
+		data, err := ioutil.ReadAll(io.LimitReader(f, chunkSize))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 && i > 0 {
+			break
+		}
+
+		if i == 0 && bytes.HasPrefix(data, gzipMagic) {
+			manifest.Encoding = EncodingGzip
+		}
+
+		total.Write(data)
+		sum := sha256.Sum256(data)
+		chunk := Chunk{
+			Reference: fmt.Sprintf("%s.part-%04d", reference, i),
+			Size:      int64(len(data)),
+			Checksum:  hex.EncodeToString(sum[:]),
+		}
+
+		if err := uploadData(provider, filename+".chunk", chunk.Reference, data); err != nil {
+			return nil, err
+		}
+
+		manifest.Chunks = append(manifest.Chunks, chunk)
+		manifest.Size += chunk.Size
+
+		if chunk.Size < chunkSize {
+			break
+		}
+	}
+
+	manifest.Checksum = hex.EncodeToString(total.Sum(nil))
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := uploadData(provider, filename+".manifest", ManifestReference(reference), data); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// DownloadChunked downloads the chunks listed in a manifest and joins them into filename.
+// The checksum of every chunk and of the joined file are verified. Gzipped files are
+// decompressed once they are joined.
+func DownloadChunked(provider Provider, manifestReference, filename string) error {
+	if err := provider.DownloadFile(manifestReference, filename); err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+
+	out, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	total := sha256.New()
+	for _, chunk := range manifest.Chunks {
+		if err := provider.DownloadFile(chunk.Reference, filename+".chunk"); err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(filename + ".chunk")
+		os.Remove(filename + ".chunk")
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != chunk.Checksum {
+			return errors.New("checksum mismatch for chunk " + chunk.Reference)
+		}
+
+		total.Write(data)
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if hex.EncodeToString(total.Sum(nil)) != manifest.Checksum {
+		return errors.New("checksum mismatch for " + manifest.Reference)
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if manifest.Encoding == EncodingGzip {
+		return gunzipFile(context.Background(), filename)
+	}
+	return nil
+}
+
+// uploadData writes data to a temporary file and uploads it.
+func uploadData(provider Provider, tempFile, reference string, data []byte) error {
+	if err := ioutil.WriteFile(tempFile, data, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(tempFile)
+
+	return provider.UploadFile(tempFile, reference)
+}
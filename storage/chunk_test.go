@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// memoryProvider stores uploaded files in memory.
+type memoryProvider struct {
+	files     map[string][]byte
+	failAfter int
+}
+
+func (m *memoryProvider) Kind() string          { return "memory" }
+func (m *memoryProvider) CollectionRef() string { return "memory" }
+
+func (m *memoryProvider) UploadFile(filename, reference string) error {
+	if m.failAfter > 0 && len(m.files) >= m.failAfter {
+		return errors.New("upload error")
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	m.files[reference] = data
+	return nil
+}
+
+func (m *memoryProvider) DownloadFile(reference, filename string) error {
+	data, ok := m.files[reference]
+	if !ok {
+		return errors.New("not found")
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+func TestUploadChunked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name       string
+		content    string
+		chunkSize  int64
+		failAfter  int
+		wantChunks []string
+		wantErr    bool
+	}{
+		{"Multiple Chunks", "abcdefghij", 4, 0, []string{"abcd", "efgh", "ij"}, false},
+		{"Exact Chunks", "abcdefgh", 4, 0, []string{"abcd", "efgh"}, false},
+		{"Single Chunk", "abc", 4, 0, []string{"abc"}, false},
+		{"Empty File", "", 4, 0, []string{""}, false},
+		{"Invalid Chunk Size", "abc", 0, 0, nil, true},
+		{"Upload Error", "abcdefghij", 4, 2, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := dir + "/report.json"
+			if err := ioutil.WriteFile(filename, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			provider := &memoryProvider{files: make(map[string][]byte), failAfter: tt.failAfter}
+			manifest, err := UploadChunked(provider, filename, "report.json", tt.chunkSize)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UploadChunked() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got []string
+			for _, chunk := range manifest.Chunks {
+				got = append(got, string(provider.files[chunk.Reference]))
+			}
+			if !reflect.DeepEqual(got, tt.wantChunks) {
+				t.Errorf("UploadChunked() chunks = %q, want %q", got, tt.wantChunks)
+			}
+			if manifest.Size != int64(len(tt.content)) {
+				t.Errorf("UploadChunked() size = %v, want %v", manifest.Size, len(tt.content))
+			}
+			if _, ok := provider.files[ManifestReference("report.json")]; !ok {
+				t.Errorf("UploadChunked() did not upload the manifest")
+			}
+
+			// The chunks can be joined again.
+			joined := dir + "/joined.json"
+			if err := DownloadChunked(provider, ManifestReference("report.json"), joined); err != nil {
+				t.Fatalf("DownloadChunked() error = %v", err)
+			}
+			if data, _ := ioutil.ReadFile(joined); string(data) != tt.content {
+				t.Errorf("DownloadChunked() = %q, want %q", data, tt.content)
+			}
+		})
+	}
+}
+
+func TestDownloadChunked_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chunk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := dir + "/report.json"
+	ioutil.WriteFile(filename, []byte(strings.Repeat("x", 10)), 0644)
+
+	provider := &memoryProvider{files: make(map[string][]byte)}
+	if _, err := UploadChunked(provider, filename, "report.json", 4); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := DownloadChunked(provider, "missing.manifest.json", dir+"/out.json"); err == nil {
+		t.Errorf("DownloadChunked() expected an error for a missing manifest")
+	}
+
+	// Corrupt a chunk.
+	provider.files["report.json.part-0001"] = []byte("yyyy")
+	if err := DownloadChunked(provider, ManifestReference("report.json"), dir+"/out.json"); err == nil {
+		t.Errorf("DownloadChunked() expected a checksum error")
+	}
+
+	// Remove a chunk.
+	delete(provider.files, "report.json.part-0001")
+	if err := DownloadChunked(provider, ManifestReference("report.json"), dir+"/out.json"); err == nil {
+		t.Errorf("DownloadChunked() expected an error for a missing chunk")
+	}
+}
+
+// contextMemoryProvider records the context of its transfers.
+type contextMemoryProvider struct {
+	*memoryProvider
+	contexts int
+}
+
+func (c *contextMemoryProvider) UploadFileContext(ctx context.Context, filename, reference string) error {
+	c.contexts++
+	return c.UploadFile(filename, reference)
+}
+
+func (c *contextMemoryProvider) DownloadFileContext(ctx context.Context, reference, filename string) error {
+	c.contexts++
+	return c.DownloadFile(reference, filename)
+}
+
+func TestWithContext(t *testing.T) {
+	file, err := ioutil.TempFile("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("report")
+	file.Close()
+
+	plain := &memoryProvider{files: map[string][]byte{}}
+	aware := &contextMemoryProvider{memoryProvider: &memoryProvider{files: map[string][]byte{}}}
+
+	for _, provider := range []Provider{plain, aware} {
+		p := WithContext(context.Background(), provider)
+		if err := p.UploadFile(file.Name(), "report.json"); err != nil {
+			t.Errorf("WithContext(%T).UploadFile() error = %v", provider, err)
+		}
+		if err := p.DownloadFile("report.json", file.Name()); err != nil {
+			t.Errorf("WithContext(%T).DownloadFile() error = %v", provider, err)
+		}
+		if p.Kind() != "memory" {
+			t.Errorf("WithContext(%T).Kind() = %v", provider, p.Kind())
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		p = WithContext(ctx, provider)
+		if err := p.UploadFile(file.Name(), "cancelled.json"); err != context.Canceled {
+			t.Errorf("WithContext(%T).UploadFile() error = %v, want %v", provider, err, context.Canceled)
+		}
+		if err := p.DownloadFile("report.json", file.Name()); err != context.Canceled {
+			t.Errorf("WithContext(%T).DownloadFile() error = %v, want %v", provider, err, context.Canceled)
+		}
+	}
+
+	if aware.contexts != 2 {
+		t.Errorf("WithContext() used the context of the provider %v times, want 2", aware.contexts)
+	}
+	if WithContext(context.Background(), nil) != nil {
+		t.Errorf("WithContext() of no provider should be nil")
+	}
+}
+
+func TestReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := Reader(ctx, strings.NewReader("report"))
+
+	buf := make([]byte, 3)
+	if n, err := r.Read(buf); err != nil || string(buf[:n]) != "rep" {
+		t.Errorf("Reader().Read() = %q, %v", buf[:n], err)
+	}
+
+	cancel()
+	if _, err := r.Read(buf); err != context.Canceled {
+		t.Errorf("Reader().Read() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// EncodingGzip is the content encoding of gzipped files, see WithCompression.
+const EncodingGzip = "gzip"
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// compressedProvider gzips files before they are uploaded and decompresses them when they are
+// downloaded.
+type compressedProvider struct {
+	Provider
+}
+
+// WithCompression returns a provider that gzips files before they are uploaded, e.g. raw PHPCS
+// reports, which compress 10-20x. References are unchanged, and uploads carry the
+// MetadataContentEncoding, so providers that support it serve the files with a Content-Encoding
+// header and HTTP clients decompress them.
+//
+// Downloaded files are decompressed if they are gzipped, so files uploaded before compression
+// was enabled can still be downloaded. Reports are JSON, which never starts like a gzip stream.
+func WithCompression(provider Provider) Provider {
+	if provider == nil {
+		return provider
+	}
+	return compressedProvider{Provider: provider}
+}
+
+// UploadFile implements Provider.
+func (p compressedProvider) UploadFile(filename, reference string) error {
+	return p.UploadFileContext(context.Background(), filename, reference)
+}
+
+// UploadFileContext implements ContextProvider.
+func (p compressedProvider) UploadFileContext(ctx context.Context, filename, reference string) error {
+	compressed, err := GzipFile(ctx, filename)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(compressed)
+
+	ctx = WithMetadata(ctx, Metadata{MetadataContentEncoding: EncodingGzip})
+	return WithContext(ctx, p.Provider).UploadFile(compressed, reference)
+}
+
+// DownloadFile implements Provider.
+func (p compressedProvider) DownloadFile(reference, filename string) error {
+	return p.DownloadFileContext(context.Background(), reference, filename)
+}
+
+// DownloadFileContext implements ContextProvider.
+func (p compressedProvider) DownloadFileContext(ctx context.Context, reference, filename string) error {
+	if err := WithContext(ctx, p.Provider).DownloadFile(reference, filename); err != nil {
+		return err
+	}
+	return gunzipFile(ctx, filename)
+}
+
+// Exists implements Checker.
+func (p compressedProvider) Exists(reference string) (bool, error) {
+	return Exists(p.Provider, reference)
+}
+
+// Delete implements Deleter.
+func (p compressedProvider) Delete(reference string) error {
+	return Delete(p.Provider, reference)
+}
+
+// GzipFile writes the gzipped file to a temporary file and returns its path, e.g. to upload
+// the file in chunks if it is still too large once it is compressed, see UploadChunked. The
+// caller removes the temporary file.
+func GzipFile(ctx context.Context, filename string) (string, error) {
+	in, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile("", filepath.Base(filename)+".gz-")
+	if err != nil {
+		return "", err
+	}
+
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, Reader(ctx, in))
+	if err == nil {
+		err = gz.Close()
+	}
+	if cErr := out.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// gunzipFile decompresses the file in place, if it is gzipped.
+func gunzipFile(ctx context.Context, filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r := bufio.NewReader(in)
+	if magic, _ := r.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	// The file is replaced once it is decompressed.
+	out, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if _, err := io.Copy(out, Reader(ctx, gz)); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), filename)
+}
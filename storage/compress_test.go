@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// metadataMemoryProvider records the metadata of its uploads.
+type metadataMemoryProvider struct {
+	*memoryProvider
+	metadata Metadata
+}
+
+func (m *metadataMemoryProvider) UploadFileContext(ctx context.Context, filename, reference string) error {
+	m.metadata = MetadataFromContext(ctx)
+	return m.UploadFile(filename, reference)
+}
+
+func (m *metadataMemoryProvider) DownloadFileContext(ctx context.Context, reference, filename string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return m.DownloadFile(reference, filename)
+}
+
+func TestWithCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	report := bytes.Repeat([]byte(`{"file":"plugin.php","messages":[]},`), 100)
+	upload := filepath.Join(dir, "report.json")
+	ioutil.WriteFile(upload, report, 0644)
+
+	inner := &metadataMemoryProvider{memoryProvider: &memoryProvider{files: map[string][]byte{}}}
+	p := WithCompression(inner)
+
+	ctx := WithMetadata(context.Background(), Metadata{MetadataAuditType: "phpcs_wordpress"})
+	if err := WithContext(ctx, p).UploadFile(upload, "report.json"); err != nil {
+		t.Fatalf("WithCompression().UploadFile() error = %v", err)
+	}
+
+	stored := inner.files["report.json"]
+	if !bytes.HasPrefix(stored, gzipMagic) || len(stored) >= len(report)/10 {
+		t.Errorf("WithCompression().UploadFile() stored %v bytes of %v, want them gzipped", len(stored), len(report))
+	}
+	if inner.metadata[MetadataContentEncoding] != EncodingGzip || inner.metadata[MetadataAuditType] != "phpcs_wordpress" {
+		t.Errorf("WithCompression().UploadFile() metadata = %v, want the encoding and the metadata of the context", inner.metadata)
+	}
+
+	// Files are decompressed, whether they were uploaded compressed or not.
+	inner.files["plain.json"] = []byte(`{"plain":true}`)
+	tests := []struct {
+		name      string
+		reference string
+		want      []byte
+	}{
+		{"Compressed", "report.json", report},
+		{"Uncompressed", "plain.json", []byte(`{"plain":true}`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			download := filepath.Join(dir, "download.json")
+			if err := p.DownloadFile(tt.reference, download); err != nil {
+				t.Fatalf("WithCompression().DownloadFile() error = %v", err)
+			}
+			if got, _ := ioutil.ReadFile(download); !bytes.Equal(got, tt.want) {
+				t.Errorf("WithCompression().DownloadFile() = %v bytes, want %v bytes", len(got), len(tt.want))
+			}
+		})
+	}
+
+	// Files are compressed before they are split, and joined before they are decompressed.
+	compressed, err := GzipFile(context.Background(), upload)
+	if err != nil {
+		t.Fatalf("GzipFile() error = %v", err)
+	}
+	defer os.Remove(compressed)
+	manifest, err := UploadChunked(inner, compressed, "chunked.json", 32)
+	if err != nil {
+		t.Fatalf("UploadChunked() error = %v", err)
+	}
+	if manifest.Encoding != EncodingGzip || len(manifest.Chunks) < 2 {
+		t.Errorf("UploadChunked() = %v chunks of encoding %q, want gzipped chunks", len(manifest.Chunks), manifest.Encoding)
+	}
+	download := filepath.Join(dir, "chunked.json")
+	if err := DownloadChunked(inner, ManifestReference("chunked.json"), download); err != nil {
+		t.Fatalf("DownloadChunked() error = %v", err)
+	}
+	if got, _ := ioutil.ReadFile(download); !bytes.Equal(got, report) {
+		t.Errorf("DownloadChunked() = %v bytes, want %v bytes", len(got), len(report))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WithContext(ctx, p).UploadFile(upload, "cancelled.json"); err != context.Canceled {
+		t.Errorf("WithCompression().UploadFile() error = %v, want %v", err, context.Canceled)
+	}
+	if err := WithContext(ctx, p).DownloadFile("report.json", filepath.Join(dir, "cancelled.json")); err != context.Canceled {
+		t.Errorf("WithCompression().DownloadFile() error = %v, want %v", err, context.Canceled)
+	}
+
+	if WithCompression(nil) != nil {
+		t.Errorf("WithCompression() of no provider should be nil")
+	}
+}
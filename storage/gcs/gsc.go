@@ -2,8 +2,13 @@ package gcs
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/crc32"
 	"io"
 	"os"
+
+	tideStorage "github.com/wptide/pkg/storage"
 )
 
 var (
@@ -13,11 +18,21 @@ var (
 )
 
 // Provider describes the GCS provider.
+//
+// Uploads are resumable and verified with the CRC32C checksum of the file. Each object carries
+// the SHA256 checksum of the file and the metadata of the upload context, e.g. the audit type
+// and project slug of a report, see storage.WithMetadata.
 type Provider struct {
 	ctx        context.Context
 	client     *client
 	projectID  *string
 	bucketName *string
+
+	ChunkSize      int               // (Optional) Size of the chunks of resumable uploads in bytes, a multiple of 256 KiB. Defaults to 16 MiB.
+	KMSKeyName     string            // (Optional) Customer-managed encryption key of uploaded objects, e.g. "projects/tide/locations/global/keyRings/reports/cryptoKeys/raw".
+	Metadata       map[string]string // (Optional) Metadata of all uploaded objects.
+	GoogleAccessID string            // (Optional) Email of the service account that signs URLs, see SignedURL.
+	PrivateKey     []byte            // (Optional) PEM encoded private key of the service account that signs URLs.
 }
 
 // Kind returns the kind of provider.
@@ -33,6 +48,12 @@ func (p Provider) CollectionRef() string {
 
 // UploadFile puts the given file to the storage provider.
 func (p Provider) UploadFile(filename, reference string) error {
+	return p.UploadFileContext(p.context(), filename, reference)
+}
+
+// UploadFileContext puts the given file to the storage provider with the metadata of the
+// context, and aborts the upload when the context is done.
+func (p Provider) UploadFileContext(ctx context.Context, filename, reference string) error {
 
 	// Open file for writing to Cloud Storage.
 	file, err := fileOpen(filename)
@@ -43,6 +64,10 @@ func (p Provider) UploadFile(filename, reference string) error {
 	}
 	defer file.Close()
 
+	if oc, ok := storageObject.(OptionsClient); ok {
+		return p.upload(ctx, oc, file, reference)
+	}
+
 	w, _ := storageObject.GetWriteCloser(*p.bucketName, reference)
 	defer w.Close()
 
@@ -77,6 +102,86 @@ func (p Provider) DownloadFile(reference, filename string) error {
 	return nil
 }
 
+// Exists implements storage.Checker.
+func (p Provider) Exists(reference string) (bool, error) {
+	oc, ok := storageObject.(ObjectClient)
+	if !ok {
+		return false, tideStorage.ErrNotSupported
+	}
+	return oc.ObjectExists(p.context(), *p.bucketName, reference)
+}
+
+// Delete implements storage.Deleter.
+func (p Provider) Delete(reference string) error {
+	oc, ok := storageObject.(ObjectClient)
+	if !ok {
+		return tideStorage.ErrNotSupported
+	}
+	return oc.DeleteObject(p.context(), *p.bucketName, reference)
+}
+
+// upload writes the file to the object with the options of the provider. The upload is only
+// complete once the writer is closed, so errors of Close are returned.
+func (p Provider) upload(ctx context.Context, oc OptionsClient, file *os.File, reference string) error {
+	opts, err := p.writeOptions(ctx, file)
+	if err != nil {
+		return err
+	}
+
+	w, err := oc.GetWriteCloserWithOptions(ctx, *p.bucketName, reference, opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, file); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writeOptions returns the options to upload the file: its checksums and the metadata of the
+// provider and the context. The file is read to its end and rewound.
+func (p Provider) writeOptions(ctx context.Context, file *os.File) (WriteOptions, error) {
+	sha := sha256.New()
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	if _, err := io.Copy(io.MultiWriter(sha, crc), file); err != nil {
+		return WriteOptions{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return WriteOptions{}, err
+	}
+
+	metadata := map[string]string{}
+	for key, value := range p.Metadata {
+		metadata[key] = value
+	}
+	for key, value := range tideStorage.MetadataFromContext(ctx) {
+		metadata[key] = value
+	}
+	metadata[tideStorage.MetadataChecksum] = hex.EncodeToString(sha.Sum(nil))
+
+	// The encoding is an attribute of the object.
+	encoding := metadata[tideStorage.MetadataContentEncoding]
+	delete(metadata, tideStorage.MetadataContentEncoding)
+
+	return WriteOptions{
+		ContentEncoding: encoding,
+		Metadata:        metadata,
+		ChunkSize:       p.ChunkSize,
+		KMSKeyName:      p.KMSKeyName,
+		CRC32C:          crc.Sum32(),
+	}, nil
+}
+
+// context returns the context of the provider.
+func (p Provider) context() context.Context {
+	if p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
 // NewCloudStorageProvider creates a new GCS provider.
 func NewCloudStorageProvider(ctx context.Context, projectID string, bucketName string) *Provider {
 	return &Provider{
@@ -0,0 +1,36 @@
+//go:build integration
+// +build integration
+
+package gcs
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/wptide/pkg/storage/storagetest"
+	"github.com/wptide/pkg/testenv"
+	"google.golang.org/api/option"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	ctx := context.Background()
+	endpoint := testenv.GCS(t)
+
+	client, err := storage.NewClient(ctx, option.WithEndpoint(endpoint+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	bucket := "tide-reports"
+	if err := client.Bucket(bucket).Create(ctx, testenv.ProjectID, nil); err != nil {
+		t.Fatalf("could not create bucket: %v", err)
+	}
+
+	old := storageObject
+	storageObject = &Storage{client: client, ctx: ctx}
+	defer func() { storageObject = old }()
+
+	storagetest.Run(t, NewCloudStorageProvider(ctx, testenv.ProjectID, bucket))
+}
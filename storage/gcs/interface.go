@@ -1,9 +1,33 @@
 package gcs
 
-import "io"
+import (
+	"context"
+	"io"
+)
 
 // StorageClient interface describes a new storage client.
 type StorageClient interface {
 	GetWriteCloser(bucket, ref string) (io.WriteCloser, error)
 	GetReadCloser(bucket, ref string) (io.ReadCloser, error)
 }
+
+// WriteOptions are the options of an uploaded object.
+type WriteOptions struct {
+	ContentType     string
+	ContentEncoding string // Encoding of the object, e.g. "gzip". Cloud Storage decompresses it for clients that don't accept the encoding.
+	Metadata        map[string]string
+	ChunkSize       int    // Size of the chunks of a resumable upload in bytes. 0 uses the default of the client.
+	KMSKeyName      string // Customer-managed encryption key, e.g. "projects/p/locations/l/keyRings/r/cryptoKeys/k".
+	CRC32C          uint32 // CRC32C checksum of the object, verified by Cloud Storage. 0 skips the verification.
+}
+
+// OptionsClient is implemented by storage clients that write objects with options.
+type OptionsClient interface {
+	GetWriteCloserWithOptions(ctx context.Context, bucket, ref string, opts WriteOptions) (io.WriteCloser, error)
+}
+
+// ObjectClient is implemented by storage clients that check and delete objects.
+type ObjectClient interface {
+	ObjectExists(ctx context.Context, bucket, ref string) (bool, error)
+	DeleteObject(ctx context.Context, bucket, ref string) error
+}
@@ -0,0 +1,30 @@
+package gcs
+
+import (
+	"errors"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// signURL signs URLs, replaced in tests.
+var signURL = storage.SignedURL
+
+// SignedURL returns a URL to download the object for the duration, e.g. so that the Tide API
+// can hand clients a temporary link to a raw report of a private bucket. URLs are signed with
+// the GoogleAccessID and PrivateKey of the provider.
+func (p Provider) SignedURL(reference string, expires time.Duration) (string, error) {
+	if p.GoogleAccessID == "" || len(p.PrivateKey) == 0 {
+		return "", errors.New("gcs: a service account and private key are required to sign URLs")
+	}
+	if expires <= 0 {
+		return "", errors.New("gcs: signed URLs must expire in the future")
+	}
+
+	return signURL(*p.bucketName, reference, &storage.SignedURLOptions{
+		GoogleAccessID: p.GoogleAccessID,
+		PrivateKey:     p.PrivateKey,
+		Method:         "GET",
+		Expires:        time.Now().Add(expires),
+	})
+}
@@ -10,6 +10,9 @@ import (
 // Provides a way to return an alternate objectHandle. Used for testing.
 var objectWriterInterface = objectWriter
 var objectReaderInterface = objectReader
+var objectWriterWithOptionsInterface = objectWriterWithOptions
+var objectExistsInterface = objectExists
+var objectDeleteInterface = objectDelete
 
 // Interface which storage.Client implicitly implements.
 type client interface {
@@ -23,6 +26,8 @@ type bucketHandle interface {
 type objectHandle interface {
 	NewReader(ctx context.Context) (*storage.Reader, error)
 	NewWriter(ctx context.Context) *storage.Writer
+	Attrs(ctx context.Context) (*storage.ObjectAttrs, error)
+	Delete(ctx context.Context) error
 }
 
 // Storage describes a new GCS client storage object.
@@ -40,13 +45,39 @@ func (s Storage) getObject(bucket bucketHandle, ref string) objectHandle {
 }
 
 func objectWriter(ctx context.Context, obj objectHandle) (io.WriteCloser, error) {
+	return objectWriterWithOptions(ctx, obj, WriteOptions{})
+}
+
+// objectWriterWithOptions returns a writer of the object with the options. Objects are uploaded
+// in resumable chunks, so a failed chunk is retried without uploading the object again.
+func objectWriterWithOptions(ctx context.Context, obj objectHandle, opts WriteOptions) (io.WriteCloser, error) {
 	w := obj.NewWriter(ctx)
 
 	// Set object meta.
 	w.ContentType = "application/json"
+	if opts.ContentType != "" {
+		w.ContentType = opts.ContentType
+	}
+	if opts.ContentEncoding != "" {
+		w.ContentEncoding = opts.ContentEncoding
+	}
 	w.Metadata = map[string]string{
 		"x-goog-acl": "public-read",
 	}
+	for key, value := range opts.Metadata {
+		w.Metadata[key] = value
+	}
+
+	if opts.ChunkSize > 0 {
+		w.ChunkSize = opts.ChunkSize
+	}
+	if opts.KMSKeyName != "" {
+		w.KMSKeyName = opts.KMSKeyName
+	}
+	if opts.CRC32C != 0 {
+		w.CRC32C = opts.CRC32C
+		w.SendCRC32C = true
+	}
 
 	return w, nil
 }
@@ -55,18 +86,54 @@ func objectReader(ctx context.Context, obj objectHandle) (io.ReadCloser, error)
 	return obj.NewReader(ctx)
 }
 
+// objectExists reports whether the object exists.
+func objectExists(ctx context.Context, obj objectHandle) (bool, error) {
+	_, err := obj.Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// objectDelete deletes the object. Deleting a missing object is not an error.
+func objectDelete(ctx context.Context, obj objectHandle) error {
+	if err := obj.Delete(ctx); err != nil && err != storage.ErrObjectNotExist {
+		return err
+	}
+	return nil
+}
+
 // GetWriteCloser gets a new io.WriteCloser for the storage client.
 func (s *Storage) GetWriteCloser(bucket, ref string) (io.WriteCloser, error) {
 	obj := s.getObject(s.getBucket(bucket), ref)
 	return objectWriterInterface(s.ctx, obj)
 }
 
+// GetWriteCloserWithOptions gets a new io.WriteCloser for the storage client that writes the
+// object with the options, and aborts the upload when the context is done.
+func (s *Storage) GetWriteCloserWithOptions(ctx context.Context, bucket, ref string, opts WriteOptions) (io.WriteCloser, error) {
+	obj := s.getObject(s.getBucket(bucket), ref)
+	return objectWriterWithOptionsInterface(ctx, obj, opts)
+}
+
 // GetReadCloser gets a new io.ReadCloser for the storage client.
 func (s *Storage) GetReadCloser(bucket, ref string) (io.ReadCloser, error) {
 	obj := s.getObject(s.getBucket(bucket), ref)
 	return objectReaderInterface(s.ctx, obj)
 }
 
+// ObjectExists reports whether the object exists in the bucket.
+func (s *Storage) ObjectExists(ctx context.Context, bucket, ref string) (bool, error) {
+	obj := s.getObject(s.getBucket(bucket), ref)
+	return objectExistsInterface(ctx, obj)
+}
+
+// DeleteObject deletes the object from the bucket.
+func (s *Storage) DeleteObject(ctx context.Context, bucket, ref string) error {
+	obj := s.getObject(s.getBucket(bucket), ref)
+	return objectDeleteInterface(ctx, obj)
+}
+
 // GSCClient returns a new StorageClient.
 func GSCClient(ctx context.Context) StorageClient {
 	client, _ := storage.NewClient(ctx)
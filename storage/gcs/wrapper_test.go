@@ -2,6 +2,7 @@ package gcs
 
 import (
 	"context"
+	"errors"
 	"io"
 	"reflect"
 	"testing"
@@ -31,6 +32,40 @@ func (m mockObject) NewReader(ctx context.Context) (*storage.Reader, error) {
 	return &storage.Reader{}, nil
 }
 
+func (m mockObject) Attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	return &storage.ObjectAttrs{}, nil
+}
+
+func (m mockObject) Delete(ctx context.Context) error {
+	return nil
+}
+
+// missingObject is an object that doesn't exist.
+type missingObject struct {
+	mockObject
+}
+
+func (m missingObject) Attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	return nil, storage.ErrObjectNotExist
+}
+
+func (m missingObject) Delete(ctx context.Context) error {
+	return storage.ErrObjectNotExist
+}
+
+// failingObject is an object that can't be checked or deleted.
+type failingObject struct {
+	mockObject
+}
+
+func (m failingObject) Attrs(ctx context.Context) (*storage.ObjectAttrs, error) {
+	return nil, errors.New("forbidden")
+}
+
+func (m failingObject) Delete(ctx context.Context) error {
+	return errors.New("forbidden")
+}
+
 type mockIO struct {
 	readError  error
 	writeError error
@@ -300,6 +335,60 @@ func Test_objectWriter(t *testing.T) {
 	}
 }
 
+func Test_objectWriterWithOptions(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           WriteOptions
+		wantChunkSize  int
+		wantKMSKeyName string
+		wantCRC32C     uint32
+		wantMetadata   map[string]string
+	}{
+		{
+			"Defaults",
+			WriteOptions{},
+			0,
+			"",
+			0,
+			map[string]string{"x-goog-acl": "public-read"},
+		},
+		{
+			"Options",
+			WriteOptions{
+				Metadata:   map[string]string{"audit-type": "lighthouse"},
+				ChunkSize:  256 * 1024,
+				KMSKeyName: "projects/tide/locations/global/keyRings/reports/cryptoKeys/raw",
+				CRC32C:     42,
+			},
+			256 * 1024,
+			"projects/tide/locations/global/keyRings/reports/cryptoKeys/raw",
+			42,
+			map[string]string{"x-goog-acl": "public-read", "audit-type": "lighthouse"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := objectWriterWithOptions(context.Background(), &mockObject{}, tt.opts)
+			w := got.(*storage.Writer)
+
+			if w.ChunkSize != tt.wantChunkSize || w.KMSKeyName != tt.wantKMSKeyName {
+				t.Errorf("objectWriterWithOptions() chunk size %v, key %v, want %v, %v", w.ChunkSize, w.KMSKeyName, tt.wantChunkSize, tt.wantKMSKeyName)
+			}
+			if w.CRC32C != tt.wantCRC32C || w.SendCRC32C != (tt.wantCRC32C != 0) {
+				t.Errorf("objectWriterWithOptions() CRC32C %v, sent %v, want %v", w.CRC32C, w.SendCRC32C, tt.wantCRC32C)
+			}
+			if !reflect.DeepEqual(w.Metadata, tt.wantMetadata) {
+				t.Errorf("objectWriterWithOptions() metadata = %v, want %v", w.Metadata, tt.wantMetadata)
+			}
+		})
+	}
+
+	got, _ := objectWriterWithOptions(context.Background(), &mockObject{}, WriteOptions{ContentEncoding: "gzip"})
+	if w := got.(*storage.Writer); w.ContentEncoding != "gzip" || w.ContentType != "application/json" {
+		t.Errorf("objectWriterWithOptions() content type %v, encoding %v, want application/json, gzip", w.ContentType, w.ContentEncoding)
+	}
+}
+
 func Test_objectReader(t *testing.T) {
 	type args struct {
 		ctx context.Context
@@ -334,3 +423,30 @@ func Test_objectReader(t *testing.T) {
 		})
 	}
 }
+
+func Test_objectExists(t *testing.T) {
+	tests := []struct {
+		name       string
+		obj        objectHandle
+		want       bool
+		wantErr    bool
+		wantDelErr bool
+	}{
+		{"Exists", &mockObject{}, true, false, false},
+		{"Missing", &missingObject{}, false, false, false},
+		{"Error", &failingObject{}, false, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := objectExists(context.Background(), tt.obj)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("objectExists() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+
+			// Deleting a missing object is not an error.
+			if err := objectDelete(context.Background(), tt.obj); (err != nil) != tt.wantDelErr {
+				t.Errorf("objectDelete() error = %v, wantErr %v", err, tt.wantDelErr)
+			}
+		})
+	}
+}
@@ -1,8 +1,16 @@
 package local
 
 import (
+	"compress/gzip"
+	"context"
+	"errors"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/wptide/pkg/storage"
 )
 
 var (
@@ -10,7 +18,18 @@ var (
 	fileOpen   = os.Open
 )
 
-// Provider is a local storage provider.
+// gzipSuffix is appended to the files of references that are uploaded gzipped, i.e. with the
+// storage.MetadataContentEncoding of storage.EncodingGzip, see storage.WithCompression.
+const gzipSuffix = ".gz"
+
+// Provider is a local storage provider, e.g. for development or air-gapped installs.
+//
+// References are keys like those of cloud providers, e.g. "<checksum>-phpcs_wordpress-files/
+// plugin.json", and are stored as files under the root. Files are written to a temporary file
+// first and renamed, so readers never see a partially written file.
+//
+// Gzipped uploads are stored as "<reference>.gz" and are decompressed when they are read, like
+// HTTP clients do for the Content-Encoding of cloud providers.
 type Provider struct {
 	serverPath string
 	localPath  string
@@ -28,36 +47,196 @@ func (p Provider) CollectionRef() string {
 
 // UploadFile copies the file to a destination.
 func (p Provider) UploadFile(filename, reference string) error {
+	return p.UploadFileContext(context.Background(), filename, reference)
+}
+
+// UploadFileContext copies the file to a destination and stops copying when the context is done.
+func (p Provider) UploadFileContext(ctx context.Context, filename, reference string) error {
 	// Copy to "uploads" folder.
-	dest := p.serverPath + "/" + reference
-	return copyFile(filename, dest)
+	dest, err := p.path(reference)
+	if err != nil {
+		return err
+	}
+
+	source, err := fileOpen(filename)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	// References can be nested, e.g. the files of paginated reports.
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	stored, stale := dest, dest+gzipSuffix
+	if storage.MetadataFromContext(ctx)[storage.MetadataContentEncoding] == storage.EncodingGzip {
+		stored, stale = stale, stored
+	}
+	if err := write(ctx, source, stored); err != nil {
+		return err
+	}
+
+	// The file may have been stored with the other encoding before.
+	if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
 }
 
 // DownloadFile copies the file from the storage provider.
 func (p Provider) DownloadFile(reference, filename string) error {
+	return p.DownloadFileContext(context.Background(), reference, filename)
+}
+
+// DownloadFileContext copies the file from the storage provider and stops copying when the
+// context is done.
+func (p Provider) DownloadFileContext(ctx context.Context, reference, filename string) error {
 	// Copy from "uploads" folder.
-	src := p.serverPath + "/" + reference
-	return copyFile(src, filename)
+	source, err := p.Open(reference)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	return copyFile(ctx, source, filename)
+}
+
+// Open returns the content of the file with the reference, decompressed if it was uploaded
+// gzipped.
+func (p Provider) Open(reference string) (io.ReadCloser, error) {
+	path, err := p.path(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := fileOpen(path)
+	if err == nil {
+		return file, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	file, err = fileOpen(path + gzipSuffix)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipFile{gz, file}, nil
+}
+
+// ReadFile returns the content of the file with the reference, see Open.
+func (p Provider) ReadFile(reference string) ([]byte, error) {
+	r, err := p.Open(reference)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// Exists implements storage.Checker.
+func (p Provider) Exists(reference string) (bool, error) {
+	path, err := p.path(reference)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range []string{path, path + gzipSuffix} {
+		if ok, err := isFile(name); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// Delete implements storage.Deleter. Files are deleted whatever their encoding.
+func (p Provider) Delete(reference string) error {
+	path, err := p.path(reference)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{path, path + gzipSuffix} {
+		ok, err := isFile(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 // NewLocalStorage returns a local storage provider.
 func NewLocalStorage(storagePath string, localPath string) *Provider {
 	return &Provider{
-		storagePath,
-		localPath,
+		serverPath: storagePath,
+		localPath:  localPath,
 	}
 }
 
-func copyFile(src, dst string) error {
-
-	// Open source file to copy from.
-	sourceFile, err := fileOpen(src)
+// path returns the path of the file of a reference. References are relative to the root, "."
+// and ".." segments are not allowed so that files can't be stored outside of it.
+func (p Provider) path(reference string) (string, error) {
+	key := strings.TrimPrefix(reference, "/")
+	if key == "" || strings.HasSuffix(key, "/") {
+		return "", errors.New("invalid storage reference: " + reference)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", errors.New("invalid storage reference: " + reference)
+		}
+	}
+	return filepath.Join(p.serverPath, filepath.FromSlash(key)), nil
+}
 
-	// Error if file cannot be opened.
+// write writes the source to the path through a temporary file in the same directory, which is
+// renamed once it is complete.
+func write(ctx context.Context, source io.Reader, path string) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
 	if err != nil {
 		return err
 	}
-	defer sourceFile.Close()
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, storage.Reader(ctx, source)); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+
+	// Temporary files are only readable by their owner.
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// isFile reports whether the path is a file. Directories of nested references aren't files.
+func isFile(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+func copyFile(ctx context.Context, source io.Reader, dst string) error {
 
 	// Create destination file for writing.
 	destFile, err := fileCreate(dst)
@@ -66,9 +245,26 @@ func copyFile(src, dst string) error {
 	if err != nil {
 		return err
 	}
-	defer destFile.Close()
 
-	io.Copy(destFile, sourceFile)
+	if _, err := io.Copy(destFile, storage.Reader(ctx, source)); err != nil {
+		destFile.Close()
+		return err
+	}
 
-	return nil
+	return destFile.Close()
+}
+
+// gzipFile reads a compressed file and closes both the reader and the file.
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+// Close implements io.Closer.
+func (g gzipFile) Close() error {
+	err := g.Reader.Close()
+	if fErr := g.file.Close(); err == nil {
+		err = fErr
+	}
+	return err
 }
@@ -1,8 +1,15 @@
 package local
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+
+	"github.com/wptide/pkg/storage"
+	"github.com/wptide/pkg/storage/storagetest"
 )
 
 func TestProvider_Kind(t *testing.T) {
@@ -23,8 +30,8 @@ func TestProvider_CollectionRef(t *testing.T) {
 		{
 			"Collection Reference",
 			Provider{
-				"./testdata",
-				"subdir",
+				serverPath: "./testdata",
+				localPath:  "subdir",
 			},
 			"subdir",
 		},
@@ -52,8 +59,8 @@ func TestProvider_UploadFile(t *testing.T) {
 		{
 			"Test Upload - upload.txt",
 			Provider{
-				"./testdata/dest_bucket",
-				"subdir",
+				serverPath: "./testdata/dest_bucket",
+				localPath:  "subdir",
 			},
 			args{
 				"./testdata/source_bucket/upload.txt",
@@ -64,8 +71,8 @@ func TestProvider_UploadFile(t *testing.T) {
 		{
 			"Test Upload Bucket Error",
 			Provider{
-				"./testdata/dest_bucket",
-				"subdir",
+				serverPath: "./testdata/dest_bucket",
+				localPath:  "subdir",
 			},
 			args{
 				"does_not_exist.txt",
@@ -76,8 +83,8 @@ func TestProvider_UploadFile(t *testing.T) {
 		{
 			"Test File Create Error",
 			Provider{
-				"./testdata/test_bucket",
-				"subdir",
+				serverPath: "./testdata/test_bucket",
+				localPath:  "subdir",
 			},
 			args{
 				"./testdata/source_bucket/upload.txt",
@@ -109,8 +116,8 @@ func TestProvider_DownloadFile(t *testing.T) {
 		{
 			"Test Upload - upload.txt",
 			Provider{
-				"./testdata/dest_bucket",
-				"subdir",
+				serverPath: "./testdata/dest_bucket",
+				localPath:  "subdir",
 			},
 			args{
 				"upload.txt",
@@ -145,8 +152,8 @@ func TestNewLocalStorage(t *testing.T) {
 				"subdir",
 			},
 			&Provider{
-				"./testdata/dest_bucket",
-				"subdir",
+				serverPath: "./testdata/dest_bucket",
+				localPath:  "subdir",
 			},
 		},
 	}
@@ -158,3 +165,117 @@ func TestNewLocalStorage(t *testing.T) {
 		})
 	}
 }
+
+func TestProvider_Conformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	storagetest.Run(t, NewLocalStorage(dir, "uploads"))
+
+	storagetest.Run(t, storage.WithCompression(NewLocalStorage(filepath.Join(dir, "compressed"), "uploads")))
+}
+
+func TestProvider_TransferContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := NewLocalStorage(dir, "uploads")
+
+	if err := p.UploadFileContext(context.Background(), "./testdata/source_bucket/upload.txt", "upload.txt"); err != nil {
+		t.Errorf("Provider.UploadFileContext() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := p.UploadFileContext(ctx, "./testdata/source_bucket/upload.txt", "cancelled.txt"); err != context.Canceled {
+		t.Errorf("Provider.UploadFileContext() error = %v, want %v", err, context.Canceled)
+	}
+	if err := p.DownloadFileContext(ctx, "upload.txt", dir+"/download.txt"); err != context.Canceled {
+		t.Errorf("Provider.DownloadFileContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestProvider_Compress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	want, _ := ioutil.ReadFile("./testdata/source_bucket/upload.txt")
+
+	tests := []struct {
+		name     string
+		compress bool
+		stored   string
+		stale    string
+	}{
+		{"Compressed", true, "report.json.gz", "report.json"},
+		{"Uncompressed", false, "report.json", "report.json.gz"},
+		{"Recompressed", true, "report.json.gz", "report.json"},
+	}
+	p := NewLocalStorage(dir, "uploads")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var uploader storage.Provider = p
+			if tt.compress {
+				uploader = storage.WithCompression(p)
+			}
+
+			if err := uploader.UploadFile("./testdata/source_bucket/upload.txt", "audits/report.json"); err != nil {
+				t.Fatalf("Provider.UploadFile() error = %v", err)
+			}
+
+			if _, err := os.Stat(filepath.Join(dir, "audits", tt.stored)); err != nil {
+				t.Errorf("Provider.UploadFile() did not store %v: %v", tt.stored, err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "audits", tt.stale)); !os.IsNotExist(err) {
+				t.Errorf("Provider.UploadFile() kept %v", tt.stale)
+			}
+
+			// Gzipped uploads are decompressed when they are read.
+			got, err := p.ReadFile("/audits/report.json")
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("Provider.ReadFile() = %q, %v, want %q", got, err, want)
+			}
+
+			// No temporary files are left behind.
+			files, _ := ioutil.ReadDir(filepath.Join(dir, "audits"))
+			if len(files) != 1 {
+				t.Errorf("Provider.UploadFile() left %v files, want 1", len(files))
+			}
+		})
+	}
+}
+
+func TestProvider_InvalidReference(t *testing.T) {
+	dir, err := ioutil.TempDir("", "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := NewLocalStorage(filepath.Join(dir, "root"), "uploads")
+
+	for _, reference := range []string{"", "/", "../upload.txt", "a/../../upload.txt", "a/./upload.txt", "a//upload.txt", "audits/"} {
+		t.Run(reference, func(t *testing.T) {
+			if err := p.UploadFile("./testdata/source_bucket/upload.txt", reference); err == nil {
+				t.Errorf("Provider.UploadFile() error = nil for reference %q", reference)
+			}
+			if _, err := p.ReadFile(reference); err == nil {
+				t.Errorf("Provider.ReadFile() error = nil for reference %q", reference)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "upload.txt")); !os.IsNotExist(err) {
+		t.Errorf("Provider.UploadFile() stored a file outside of the root")
+	}
+}
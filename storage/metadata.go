@@ -0,0 +1,52 @@
+package storage
+
+import "context"
+
+// Keys of the Metadata of uploaded reports.
+const (
+	MetadataChecksum    = "checksum"     // SHA256 of the file, set by the provider.
+	MetadataAuditType   = "audit-type"   // Kind of the audit of a report, e.g. "phpcs_wordpress" or "lighthouse".
+	MetadataProjectSlug = "project-slug" // Slug of the audited project.
+
+	// MetadataContentEncoding is the encoding of the uploaded file, e.g. EncodingGzip. Providers
+	// store it as the Content-Encoding of the object, not as custom metadata.
+	MetadataContentEncoding = "content-encoding"
+)
+
+// Metadata describes an uploaded file, e.g. the audit and project of a report. Providers that
+// support object metadata store it with the file, the others ignore it.
+type Metadata map[string]string
+
+// metadataKey is the context key of the Metadata of uploads.
+type metadataKey struct{}
+
+// WithMetadata returns a context whose uploads carry the metadata, in addition to the metadata
+// of the parent context. Uploads of a ContextProvider receive the metadata through the context,
+// see WithContext.
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	merged := MetadataFromContext(ctx)
+	if merged == nil {
+		merged = make(Metadata, len(md))
+	}
+	for key, value := range md {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, metadataKey{}, merged)
+}
+
+// MetadataFromContext returns a copy of the metadata of the context, or nil if it has none.
+func MetadataFromContext(ctx context.Context) Metadata {
+	if ctx == nil {
+		return nil
+	}
+	md, ok := ctx.Value(metadataKey{}).(Metadata)
+	if !ok {
+		return nil
+	}
+
+	copied := make(Metadata, len(md))
+	for key, value := range md {
+		copied[key] = value
+	}
+	return copied
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestWithMetadata(t *testing.T) {
+	parent := WithMetadata(context.Background(), Metadata{MetadataAuditType: "phpcs_wordpress", MetadataProjectSlug: "akismet"})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want Metadata
+	}{
+		{"None", context.Background(), nil},
+		{"Metadata", parent, Metadata{"audit-type": "phpcs_wordpress", "project-slug": "akismet"}},
+		{
+			"Merged",
+			WithMetadata(parent, Metadata{MetadataAuditType: "lighthouse", "locale": "de_DE"}),
+			Metadata{"audit-type": "lighthouse", "project-slug": "akismet", "locale": "de_DE"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MetadataFromContext(tt.ctx)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MetadataFromContext() = %v, want %v", got, tt.want)
+			}
+
+			// Changing the metadata doesn't change the context.
+			if got != nil {
+				got["changed"] = "yes"
+				if _, ok := MetadataFromContext(tt.ctx)["changed"]; ok {
+					t.Errorf("MetadataFromContext() returned the metadata of the context")
+				}
+			}
+		})
+	}
+
+	// Children don't change the metadata of their parent.
+	if got := MetadataFromContext(parent)[MetadataAuditType]; got != "phpcs_wordpress" {
+		t.Errorf("WithMetadata() changed the parent audit type to %v", got)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build integration
+// +build integration
+
+package s3
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/wptide/pkg/storage/storagetest"
+	"github.com/wptide/pkg/testenv"
+)
+
+func TestProvider_Conformance(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint func(t *testing.T) string
+	}{
+		{"LocalStack", func(t *testing.T) string { return testenv.LocalStack(t, "s3") }},
+		{"MinIO", testenv.MinIO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := testenv.AWSSession(t, tt.endpoint(t))
+
+			bucket := "tide-reports"
+			if _, err := s3.New(sess).CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(bucket)}); err != nil {
+				t.Fatalf("could not create bucket: %v", err)
+			}
+
+			storagetest.Run(t, NewS3ProviderWithSession(sess, bucket))
+		})
+	}
+}
@@ -1,14 +1,21 @@
 package s3
 
 import (
+	"context"
+	"errors"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/wptide/pkg/storage"
 )
 
 var (
@@ -16,12 +23,31 @@ var (
 	fileOpen   = os.Open
 )
 
+// Server-side encryption of uploaded reports, see Provider.ServerSideEncryption.
+const (
+	EncryptionAES256 = s3.ServerSideEncryptionAes256 // Keys managed by S3 (SSE-S3).
+	EncryptionKMS    = s3.ServerSideEncryptionAwsKms // Keys managed by KMS (SSE-KMS).
+)
+
+// maxPresignExpiry is the longest a presigned URL can be valid.
+const maxPresignExpiry = 7 * 24 * time.Hour
+
 // Provider describes a new S3 storage provider.
+//
+// Files are uploaded in parts, so large reports are uploaded in parallel and failed parts are
+// retried on their own. Files smaller than the part size are uploaded at once.
 type Provider struct {
 	session    *session.Session
+	client     s3iface.S3API
 	uploader   s3manageriface.UploaderAPI
 	downloader s3manageriface.DownloaderAPI
 	bucket     string
+
+	Prefix               string // (Optional) Prefix of the object keys, e.g. "reports/".
+	PartSize             int64  // (Optional) Size of the parts of an upload in bytes, at least 5 MiB. Defaults to s3manager.DefaultUploadPartSize.
+	Concurrency          int    // (Optional) Parts uploaded at once. Defaults to s3manager.DefaultUploadConcurrency.
+	ServerSideEncryption string // (Optional) Encryption of uploaded files, EncryptionAES256 or EncryptionKMS.
+	KMSKeyID             string // (Optional) KMS key of EncryptionKMS. Defaults to the AWS managed key of S3.
 }
 
 // Kind returns the provider kind.
@@ -29,8 +55,12 @@ func (s3p Provider) Kind() string {
 	return "s3"
 }
 
-// CollectionRef gets the bucket reference.
+// CollectionRef gets the bucket reference, followed by the prefix of the object keys if there is
+// one, so that the references of uploaded files are found under it.
 func (s3p Provider) CollectionRef() string {
+	if prefix := strings.Trim(s3p.Prefix, "/"); prefix != "" {
+		return s3p.bucket + "/" + prefix
+	}
 	return s3p.bucket
 }
 
@@ -47,11 +77,7 @@ func (s3p Provider) UploadFile(filename, reference string) error {
 	defer file.Close()
 
 	// Use the upload manager to write to S3.
-	_, err = s3p.uploader.Upload(&s3manager.UploadInput{
-		Bucket: aws.String(s3p.bucket),
-		Key:    aws.String(reference),
-		Body:   file,
-	})
+	_, err = s3p.uploader.Upload(s3p.uploadInput(context.Background(), reference, file), s3p.uploadOptions)
 
 	// Error if file cannot be uploaded.
 	if err != nil {
@@ -78,7 +104,7 @@ func (s3p Provider) DownloadFile(reference, filename string) error {
 	_, err = s3p.downloader.Download(file,
 		&s3.GetObjectInput{
 			Bucket: aws.String(s3p.bucket),
-			Key:    aws.String(reference),
+			Key:    aws.String(s3p.key(reference)),
 		})
 
 	// Error on failed download.
@@ -89,15 +115,137 @@ func (s3p Provider) DownloadFile(reference, filename string) error {
 	return nil
 }
 
+// UploadFileContext puts a file in the relevant bucket and aborts the upload when the context
+// is done.
+func (s3p Provider) UploadFileContext(ctx context.Context, filename, reference string) error {
+	file, err := fileOpen(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = s3p.uploader.UploadWithContext(ctx, s3p.uploadInput(ctx, reference, file), s3p.uploadOptions)
+	return err
+}
+
+// DownloadFileContext gets the file from an S3 bucket and aborts the download when the context
+// is done.
+func (s3p Provider) DownloadFileContext(ctx context.Context, reference, filename string) error {
+	file, err := fileCreate(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = s3p.downloader.DownloadWithContext(ctx, file,
+		&s3.GetObjectInput{
+			Bucket: aws.String(s3p.bucket),
+			Key:    aws.String(s3p.key(reference)),
+		})
+	return err
+}
+
+// PresignURL returns a URL to download the file for the duration, e.g. to link a report
+// without making the bucket public. URLs are valid for 7 days at most.
+func (s3p Provider) PresignURL(reference string, expires time.Duration) (string, error) {
+	if s3p.client == nil {
+		return "", errors.New("s3: no client to presign URLs")
+	}
+	if expires <= 0 || expires > maxPresignExpiry {
+		return "", errors.New("s3: presigned URLs expire within 7 days")
+	}
+
+	req, _ := s3p.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+	})
+	return req.Presign(expires)
+}
+
+// Exists implements storage.Checker.
+func (s3p Provider) Exists(reference string) (bool, error) {
+	if s3p.client == nil {
+		return false, storage.ErrNotSupported
+	}
+
+	_, err := s3p.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+	})
+	if aErr, ok := err.(awserr.Error); ok && (aErr.Code() == "NotFound" || aErr.Code() == s3.ErrCodeNoSuchKey) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete implements storage.Deleter.
+func (s3p Provider) Delete(reference string) error {
+	if s3p.client == nil {
+		return storage.ErrNotSupported
+	}
+
+	_, err := s3p.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+	})
+	return err
+}
+
+// key returns the object key of a reference.
+func (s3p Provider) key(reference string) string {
+	if s3p.Prefix == "" {
+		return reference
+	}
+	return strings.TrimSuffix(s3p.Prefix, "/") + "/" + strings.TrimPrefix(reference, "/")
+}
+
+// uploadInput returns the input to upload the file to the reference, with the encryption of
+// the provider and the content encoding of the context, see storage.MetadataContentEncoding.
+func (s3p Provider) uploadInput(ctx context.Context, reference string, file *os.File) *s3manager.UploadInput {
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(s3p.bucket),
+		Key:    aws.String(s3p.key(reference)),
+		Body:   file,
+	}
+
+	if s3p.ServerSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(s3p.ServerSideEncryption)
+	}
+	if s3p.ServerSideEncryption == EncryptionKMS && s3p.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s3p.KMSKeyID)
+	}
+	if encoding := storage.MetadataFromContext(ctx)[storage.MetadataContentEncoding]; encoding != "" {
+		input.ContentEncoding = aws.String(encoding)
+	}
+	return input
+}
+
+// uploadOptions sets the part size and concurrency of an upload.
+func (s3p Provider) uploadOptions(u *s3manager.Uploader) {
+	if s3p.PartSize > 0 {
+		u.PartSize = s3p.PartSize
+	}
+	if s3p.Concurrency > 0 {
+		u.Concurrency = s3p.Concurrency
+	}
+}
+
 // NewS3Provider is a convenience method to return a new *Provider instance.
 func NewS3Provider(region, key, secret, bucket string) *Provider {
 
 	sess, _ := getSession(region, key, secret)
+	return NewS3ProviderWithSession(sess, bucket)
+}
+
+// NewS3ProviderWithSession returns a new *Provider instance for a session, e.g. one with the
+// endpoint of an S3 compatible service such as MinIO.
+func NewS3ProviderWithSession(sess *session.Session, bucket string) *Provider {
 	uploader := s3manager.NewUploader(sess)
 	downloader := s3manager.NewDownloader(sess)
 
 	return &Provider{
 		session:    sess,
+		client:     s3.New(sess),
 		uploader:   uploader,
 		downloader: downloader,
 		bucket:     bucket,
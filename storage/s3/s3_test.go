@@ -1,16 +1,24 @@
 package s3
 
 import (
+	"context"
 	"errors"
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager/s3manageriface"
+	"github.com/wptide/pkg/storage"
 )
 
 type mockS3 struct {
@@ -27,8 +35,11 @@ func (m mockS3) Upload(input *s3manager.UploadInput, _ ...func(*s3manager.Upload
 	}
 }
 
-func (m mockS3) UploadWithContext(aws.Context, *s3manager.UploadInput, ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
-	return nil, nil
+func (m mockS3) UploadWithContext(ctx aws.Context, input *s3manager.UploadInput, _ ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.Upload(input)
 }
 
 func (m mockS3) Download(_ io.WriterAt, input *s3.GetObjectInput, _ ...func(*s3manager.Downloader)) (int64, error) {
@@ -40,8 +51,27 @@ func (m mockS3) Download(_ io.WriterAt, input *s3.GetObjectInput, _ ...func(*s3m
 	}
 }
 
-func (m mockS3) DownloadWithContext(aws.Context, io.WriterAt, *s3.GetObjectInput, ...func(*s3manager.Downloader)) (int64, error) {
-	return 0, nil
+func (m mockS3) DownloadWithContext(ctx aws.Context, w io.WriterAt, input *s3.GetObjectInput, _ ...func(*s3manager.Downloader)) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return m.Download(w, input)
+}
+
+// recordingUploader records the input of the last upload and the uploader it was made with.
+type recordingUploader struct {
+	s3manageriface.UploaderAPI
+	input    *s3manager.UploadInput
+	uploader s3manager.Uploader
+}
+
+func (r *recordingUploader) Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	r.input = input
+	r.uploader = s3manager.Uploader{PartSize: s3manager.DefaultUploadPartSize, Concurrency: s3manager.DefaultUploadConcurrency}
+	for _, option := range options {
+		option(&r.uploader)
+	}
+	return &s3manager.UploadOutput{}, nil
 }
 
 func mockFileOpen(name string) (*os.File, error) {
@@ -194,6 +224,251 @@ func TestS3Provider_DownloadFile(t *testing.T) {
 	}
 }
 
+func TestS3Provider_TransferContext(t *testing.T) {
+	fileOpen = mockFileOpen
+	fileCreate = mockFileCreate
+	defer func() {
+		fileOpen = os.Open
+		fileCreate = os.Create
+	}()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		bucket  string
+		wantErr bool
+	}{
+		{"Active Context", context.Background(), "test_bucket", false},
+		{"Cancelled Context", cancelled, "test_bucket", true},
+		{"Bucket Error", context.Background(), "error_bucket", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3p := Provider{uploader: &mockS3{}, downloader: &mockS3{}, bucket: tt.bucket}
+
+			if err := s3p.UploadFileContext(tt.ctx, "upload.txt", "upload.txt"); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.UploadFileContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.bucket != "test_bucket" {
+				return
+			}
+			if err := s3p.DownloadFileContext(tt.ctx, "download.txt", "download.txt"); (err != nil) != tt.wantErr {
+				t.Errorf("Provider.DownloadFileContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3Provider_UploadOptions(t *testing.T) {
+	fileOpen = mockFileOpen
+	defer func() { fileOpen = os.Open }()
+
+	tests := []struct {
+		name            string
+		s3p             Provider
+		wantKey         string
+		wantEncryption  *string
+		wantKMSKeyID    *string
+		wantPartSize    int64
+		wantConcurrency int
+	}{
+		{
+			"Defaults",
+			Provider{},
+			"report.json",
+			nil,
+			nil,
+			s3manager.DefaultUploadPartSize,
+			s3manager.DefaultUploadConcurrency,
+		},
+		{
+			"Prefix and Multipart",
+			Provider{Prefix: "reports/", PartSize: 64 * 1024 * 1024, Concurrency: 2},
+			"reports/report.json",
+			nil,
+			nil,
+			64 * 1024 * 1024,
+			2,
+		},
+		{
+			"SSE-S3",
+			Provider{ServerSideEncryption: EncryptionAES256, KMSKeyID: "ignored"},
+			"report.json",
+			aws.String("AES256"),
+			nil,
+			s3manager.DefaultUploadPartSize,
+			s3manager.DefaultUploadConcurrency,
+		},
+		{
+			"SSE-KMS",
+			Provider{Prefix: "tide", ServerSideEncryption: EncryptionKMS, KMSKeyID: "alias/tide"},
+			"tide/report.json",
+			aws.String("aws:kms"),
+			aws.String("alias/tide"),
+			s3manager.DefaultUploadPartSize,
+			s3manager.DefaultUploadConcurrency,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploader := &recordingUploader{}
+			tt.s3p.uploader = uploader
+			tt.s3p.bucket = "test_bucket"
+
+			if err := tt.s3p.UploadFile("report.json", "report.json"); err != nil {
+				t.Fatalf("Provider.UploadFile() error = %v", err)
+			}
+			if got := *uploader.input.Key; got != tt.wantKey {
+				t.Errorf("Provider.UploadFile() key = %v, want %v", got, tt.wantKey)
+			}
+			// Uploaded reports are referenced by the collection and the reference, see storage.Provider.
+			if got, want := tt.s3p.CollectionRef()+"/report.json", "test_bucket/"+tt.wantKey; got != want {
+				t.Errorf("Provider.UploadFile() is referenced as %v, want %v", got, want)
+			}
+			if got := uploader.input.ServerSideEncryption; !reflect.DeepEqual(got, tt.wantEncryption) {
+				t.Errorf("Provider.UploadFile() encryption = %v, want %v", aws.StringValue(got), aws.StringValue(tt.wantEncryption))
+			}
+			if got := uploader.input.SSEKMSKeyId; !reflect.DeepEqual(got, tt.wantKMSKeyID) {
+				t.Errorf("Provider.UploadFile() KMS key = %v, want %v", aws.StringValue(got), aws.StringValue(tt.wantKMSKeyID))
+			}
+			if uploader.uploader.PartSize != tt.wantPartSize || uploader.uploader.Concurrency != tt.wantConcurrency {
+				t.Errorf("Provider.UploadFile() parts of %v bytes, %v at once, want %v bytes, %v at once",
+					uploader.uploader.PartSize, uploader.uploader.Concurrency, tt.wantPartSize, tt.wantConcurrency)
+			}
+		})
+	}
+}
+
+func TestS3Provider_uploadInput(t *testing.T) {
+	gzipped := storage.WithMetadata(context.Background(), storage.Metadata{storage.MetadataContentEncoding: storage.EncodingGzip})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want *string
+	}{
+		{"No Encoding", context.Background(), nil},
+		{"Gzip", gzipped, aws.String("gzip")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := Provider{bucket: "test_bucket"}.uploadInput(tt.ctx, "report.json", nil)
+			if !reflect.DeepEqual(input.ContentEncoding, tt.want) {
+				t.Errorf("Provider.uploadInput() encoding = %v, want %v", aws.StringValue(input.ContentEncoding), aws.StringValue(tt.want))
+			}
+		})
+	}
+}
+
+func TestS3Provider_PresignURL(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-west-2"),
+		Credentials: credentials.NewStaticCredentials("random-key", "so-secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3p := NewS3ProviderWithSession(sess, "the-bucket")
+	s3p.Prefix = "reports"
+
+	tests := []struct {
+		name    string
+		s3p     Provider
+		expires time.Duration
+		want    []string
+		wantErr bool
+	}{
+		{"Presigned", *s3p, 15 * time.Minute, []string{"the-bucket", "/reports/report.json", "X-Amz-Expires=900", "X-Amz-Signature="}, false},
+		{"Too Long", *s3p, 8 * 24 * time.Hour, nil, true},
+		{"Expired", *s3p, 0, nil, true},
+		{"No Client", Provider{bucket: "the-bucket"}, time.Minute, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s3p.PresignURL("report.json", tt.expires)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Provider.PresignURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Provider.PresignURL() = %v, want it to contain %v", got, want)
+				}
+			}
+		})
+	}
+}
+
+// objectClient keeps the keys of its objects.
+type objectClient struct {
+	s3iface.S3API
+	keys map[string]bool
+	err  error
+}
+
+func (o *objectClient) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjectOutput, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	if !o.keys[*input.Key] {
+		return nil, awserr.New("NotFound", "Not Found", nil)
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func (o *objectClient) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	delete(o.keys, *input.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestS3Provider_Exists(t *testing.T) {
+	tests := []struct {
+		name      string
+		client    s3iface.S3API
+		reference string
+		want      bool
+		wantErr   error
+	}{
+		{"Exists", &objectClient{keys: map[string]bool{"reports/report.json": true}}, "report.json", true, nil},
+		{"Missing", &objectClient{keys: map[string]bool{}}, "report.json", false, nil},
+		{"Client Error", &objectClient{err: errors.New("access denied")}, "report.json", false, errors.New("access denied")},
+		{"No Client", nil, "report.json", false, storage.ErrNotSupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3p := Provider{client: tt.client, bucket: "the-bucket", Prefix: "reports"}
+
+			got, err := s3p.Exists(tt.reference)
+			if got != tt.want || !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("Provider.Exists() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3Provider_Delete(t *testing.T) {
+	client := &objectClient{keys: map[string]bool{"reports/report.json": true}}
+	s3p := Provider{client: client, bucket: "the-bucket", Prefix: "reports"}
+
+	if err := s3p.Delete("report.json"); err != nil || client.keys["reports/report.json"] {
+		t.Errorf("Provider.Delete() error = %v, deleted %v", err, !client.keys["reports/report.json"])
+	}
+
+	client.err = errors.New("access denied")
+	if err := s3p.Delete("report.json"); err == nil {
+		t.Errorf("Provider.Delete() error = nil, want the client error")
+	}
+
+	if err := (Provider{bucket: "the-bucket"}).Delete("report.json"); err != storage.ErrNotSupported {
+		t.Errorf("Provider.Delete() error = %v, want %v", err, storage.ErrNotSupported)
+	}
+}
+
 func TestNewS3Provider(t *testing.T) {
 	type args struct {
 		region string
@@ -229,6 +504,7 @@ func TestNewS3Provider(t *testing.T) {
 func TestS3Provider_CollectionRef(t *testing.T) {
 	type fields struct {
 		bucket string
+		prefix string
 	}
 	tests := []struct {
 		name   string
@@ -239,14 +515,24 @@ func TestS3Provider_CollectionRef(t *testing.T) {
 			"Collection Reference",
 			fields{
 				"test_bucket",
+				"",
 			},
 			"test_bucket",
 		},
+		{
+			"Prefix",
+			fields{
+				"test_bucket",
+				"/reports/",
+			},
+			"test_bucket/reports",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			s3p := Provider{
 				bucket: tt.fields.bucket,
+				Prefix: tt.fields.prefix,
 			}
 			if got := s3p.CollectionRef(); got != tt.want {
 				t.Errorf("Provider.CollectionRef() = %v, want %v", got, tt.want)
@@ -1,5 +1,14 @@
 package storage
 
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNotSupported is returned for operations the provider doesn't support, see Exists and Delete.
+var ErrNotSupported = errors.New("storage: operation not supported by the provider")
+
 // Provider interface describes the methods required to upload or download files from a storage provider.
 type Provider interface {
 	Kind() string
@@ -7,3 +16,117 @@ type Provider interface {
 	UploadFile(filename, reference string) error
 	DownloadFile(reference, filename string) error
 }
+
+// ContextProvider is implemented by providers that abort a transfer when the context is done,
+// e.g. when the pipeline is cancelled while a large report is uploaded.
+type ContextProvider interface {
+	UploadFileContext(ctx context.Context, filename, reference string) error
+	DownloadFileContext(ctx context.Context, reference, filename string) error
+}
+
+// Checker is implemented by providers that can check whether a file exists, e.g. to skip an
+// audit whose report was uploaded before.
+type Checker interface {
+	Exists(reference string) (bool, error)
+}
+
+// Deleter is implemented by providers that can delete files, e.g. to clean up superseded
+// reports. Deleting a missing file is not an error.
+type Deleter interface {
+	Delete(reference string) error
+}
+
+// Exists reports whether the provider has a file with the reference. It returns ErrNotSupported
+// if the provider is not a Checker.
+func Exists(provider Provider, reference string) (bool, error) {
+	c, ok := provider.(Checker)
+	if !ok {
+		return false, ErrNotSupported
+	}
+	return c.Exists(reference)
+}
+
+// Delete deletes the file with the reference from the provider. It returns ErrNotSupported if
+// the provider is not a Deleter.
+func Delete(provider Provider, reference string) error {
+	d, ok := provider.(Deleter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return d.Delete(reference)
+}
+
+// contextProvider transfers the files of a provider in a context.
+type contextProvider struct {
+	Provider
+	ctx context.Context
+}
+
+// WithContext returns a provider that transfers files in the context. Transfers of a
+// ContextProvider are aborted when the context is done, other providers don't start new
+// transfers once the context is done. The provider is a Checker and a Deleter, whose
+// operations return ErrNotSupported if the wrapped provider doesn't support them.
+func WithContext(ctx context.Context, provider Provider) Provider {
+	if provider == nil || ctx == nil {
+		return provider
+	}
+	return contextProvider{Provider: provider, ctx: ctx}
+}
+
+// UploadFile implements Provider.
+func (p contextProvider) UploadFile(filename, reference string) error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	if cp, ok := p.Provider.(ContextProvider); ok {
+		return cp.UploadFileContext(p.ctx, filename, reference)
+	}
+	return p.Provider.UploadFile(filename, reference)
+}
+
+// DownloadFile implements Provider.
+func (p contextProvider) DownloadFile(reference, filename string) error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	if cp, ok := p.Provider.(ContextProvider); ok {
+		return cp.DownloadFileContext(p.ctx, reference, filename)
+	}
+	return p.Provider.DownloadFile(reference, filename)
+}
+
+// Exists implements Checker.
+func (p contextProvider) Exists(reference string) (bool, error) {
+	if err := p.ctx.Err(); err != nil {
+		return false, err
+	}
+	return Exists(p.Provider, reference)
+}
+
+// Delete implements Deleter.
+func (p contextProvider) Delete(reference string) error {
+	if err := p.ctx.Err(); err != nil {
+		return err
+	}
+	return Delete(p.Provider, reference)
+}
+
+// contextReader stops reading when its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Reader returns a reader that fails with the error of the context once the context is done,
+// e.g. to abort copying a file in a ContextProvider.
+func Reader(ctx context.Context, r io.Reader) io.Reader {
+	return contextReader{ctx: ctx, r: r}
+}
+
+// Read implements io.Reader.
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
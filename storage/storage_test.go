@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+// managedProvider is a memoryProvider that can check and delete files.
+type managedProvider struct {
+	*memoryProvider
+}
+
+func (m managedProvider) Exists(reference string) (bool, error) {
+	_, ok := m.files[reference]
+	return ok, nil
+}
+
+func (m managedProvider) Delete(reference string) error {
+	delete(m.files, reference)
+	return nil
+}
+
+func TestExists(t *testing.T) {
+	files := map[string][]byte{"report.json": []byte("{}")}
+	managed := managedProvider{&memoryProvider{files: files}}
+	plain := &memoryProvider{files: files}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		provider  Provider
+		reference string
+		want      bool
+		wantErr   error
+	}{
+		{"Exists", managed, "report.json", true, nil},
+		{"Missing", managed, "missing.json", false, nil},
+		{"Not Supported", plain, "report.json", false, ErrNotSupported},
+		{"Context", WithContext(context.Background(), managed), "report.json", true, nil},
+		{"Context Not Supported", WithContext(context.Background(), plain), "report.json", false, ErrNotSupported},
+		{"Context Cancelled", WithContext(cancelled, managed), "report.json", false, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Exists(tt.provider, tt.reference)
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("Exists() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDelete(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name        string
+		provider    func(m *memoryProvider) Provider
+		wantErr     error
+		wantDeleted bool
+	}{
+		{"Delete", func(m *memoryProvider) Provider { return managedProvider{m} }, nil, true},
+		{"Not Supported", func(m *memoryProvider) Provider { return m }, ErrNotSupported, false},
+		{"Context", func(m *memoryProvider) Provider { return WithContext(context.Background(), managedProvider{m}) }, nil, true},
+		{"Context Not Supported", func(m *memoryProvider) Provider { return WithContext(context.Background(), m) }, ErrNotSupported, false},
+		{"Context Cancelled", func(m *memoryProvider) Provider { return WithContext(cancelled, managedProvider{m}) }, context.Canceled, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &memoryProvider{files: map[string][]byte{"report.json": []byte("{}")}}
+
+			if err := Delete(tt.provider(m), "report.json"); err != tt.wantErr {
+				t.Errorf("Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, ok := m.files["report.json"]; ok == tt.wantDeleted {
+				t.Errorf("Delete() deleted = %v, want %v", !ok, tt.wantDeleted)
+			}
+
+			// Deleting a missing file is not an error.
+			if tt.wantDeleted {
+				if err := Delete(tt.provider(m), "report.json"); err != nil {
+					t.Errorf("Delete() of a missing file error = %v", err)
+				}
+			}
+		})
+	}
+}
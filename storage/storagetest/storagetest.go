@@ -0,0 +1,136 @@
+// Package storagetest is a conformance suite for storage providers. Every storage.Provider
+// runs the same suite, so that providers can't ship with subtly different semantics, e.g.
+// for nested references or overwritten files.
+package storagetest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/wptide/pkg/storage"
+)
+
+// Run runs the conformance suite against the provider. References are prefixed with a unique
+// name, so that the suite can run against a shared bucket.
+func Run(t *testing.T, provider storage.Provider) {
+	dir, err := ioutil.TempDir("", "storagetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := fmt.Sprintf("storagetest-%d-", time.Now().UnixNano())
+
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// roundTrip uploads the data and checks that the same data is downloaded.
+	roundTrip := func(t *testing.T, reference string, data []byte) {
+		if err := provider.UploadFile(write("upload", data), reference); err != nil {
+			t.Fatalf("%s.UploadFile() error = %v", provider.Kind(), err)
+		}
+
+		download := filepath.Join(dir, "download")
+		if err := provider.DownloadFile(reference, download); err != nil {
+			t.Fatalf("%s.DownloadFile() error = %v", provider.Kind(), err)
+		}
+		got, err := ioutil.ReadFile(download)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s.DownloadFile() = %d bytes, want %d bytes", provider.Kind(), len(got), len(data))
+		}
+	}
+
+	t.Run("Kind", func(t *testing.T) {
+		if provider.Kind() == "" || provider.CollectionRef() == "" {
+			t.Errorf("Kind() = %q, CollectionRef() = %q, want both", provider.Kind(), provider.CollectionRef())
+		}
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		roundTrip(t, prefix+"report.json", []byte(`{"totals":{"errors":1}}`))
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		roundTrip(t, prefix+"overwrite.json", []byte(`{"version":1,"padding":"longer than the second version"}`))
+		roundTrip(t, prefix+"overwrite.json", []byte(`{"version":2}`))
+	})
+
+	t.Run("Nested Reference", func(t *testing.T) {
+		// Paginated reports are uploaded as e.g. "<checksum>-phpcs_wordpress-files/<key>.json".
+		roundTrip(t, prefix+"files/nested/report.json", []byte(`{"file":"plugin.php"}`))
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		data := make([]byte, 1<<20)
+		for i := range data {
+			data[i] = byte(i * 7)
+		}
+		roundTrip(t, prefix+"chunk.bin", data)
+	})
+
+	t.Run("Empty File", func(t *testing.T) {
+		roundTrip(t, prefix+"empty.json", []byte{})
+	})
+
+	t.Run("Missing Reference", func(t *testing.T) {
+		if err := provider.DownloadFile(prefix+"missing.json", filepath.Join(dir, "missing")); err == nil {
+			t.Errorf("%s.DownloadFile() expected an error for a missing reference", provider.Kind())
+		}
+	})
+
+	t.Run("Missing File", func(t *testing.T) {
+		if err := provider.UploadFile(filepath.Join(dir, "does-not-exist"), prefix+"missing-file.json"); err == nil {
+			t.Errorf("%s.UploadFile() expected an error for a missing file", provider.Kind())
+		}
+	})
+
+	// Checking and deleting files are optional, see storage.Checker and storage.Deleter.
+	t.Run("Exists", func(t *testing.T) {
+		roundTrip(t, prefix+"exists/report.json", []byte(`{}`))
+
+		for reference, want := range map[string]bool{
+			prefix + "exists/report.json":  true,
+			prefix + "exists/missing.json": false,
+			prefix + "exists":              false,
+		} {
+			got, err := storage.Exists(provider, reference)
+			if err == storage.ErrNotSupported {
+				t.Skipf("%s does not check files", provider.Kind())
+			}
+			if err != nil || got != want {
+				t.Errorf("%s.Exists(%q) = %v, %v, want %v", provider.Kind(), reference, got, err, want)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		roundTrip(t, prefix+"delete.json", []byte(`{}`))
+
+		err := storage.Delete(provider, prefix+"delete.json")
+		if err == storage.ErrNotSupported {
+			t.Skipf("%s does not delete files", provider.Kind())
+		}
+		if err != nil {
+			t.Fatalf("%s.Delete() error = %v", provider.Kind(), err)
+		}
+		if err := provider.DownloadFile(prefix+"delete.json", filepath.Join(dir, "deleted")); err == nil {
+			t.Errorf("%s.Delete() did not delete the file", provider.Kind())
+		}
+		if err := storage.Delete(provider, prefix+"delete.json"); err != nil {
+			t.Errorf("%s.Delete() of a missing file error = %v", provider.Kind(), err)
+		}
+	})
+}
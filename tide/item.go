@@ -9,19 +9,98 @@ type ResultSet struct {
 
 // Item describes an item in a result.
 type Item struct {
-	Title         string                 `json:"title"`
-	Description   string                 `json:"content"`
-	Version       string                 `json:"version"`
-	Checksum      string                 `json:"checksum"`
-	Visibility    string                 `json:"visibility"`
-	ProjectType   string                 `json:"project_type"`
-	SourceURL     string                 `json:"source_url"`
-	SourceType    string                 `json:"source_type"`
-	CodeInfo      CodeInfo               `json:"code_info,omitempty"`
-	Reports       map[string]AuditResult `json:"reports,omitempty"`
-	Standards     []string               `json:"standards,omitempty"`      // Will potentially be overriden in API and should not be relied upon.
-	RequestClient string                 `json:"request_client,omitempty"` // Will be converted to a user.
-	Project       []string               `json:"project,omitempty"`        // Has to be an array of string because of how taxonomies work in WordPress.
+	Title           string                 `json:"title"`
+	Description     string                 `json:"content"`
+	Version         string                 `json:"version"`
+	Checksum        string                 `json:"checksum"`
+	Visibility      string                 `json:"visibility"`
+	ProjectType     string                 `json:"project_type"`
+	SourceURL       string                 `json:"source_url"`
+	SourceType      string                 `json:"source_type"`
+	CodeInfo        CodeInfo               `json:"code_info,omitempty"`
+	Reports         map[string]AuditResult `json:"reports,omitempty"`
+	Standards       []string               `json:"standards,omitempty"`        // Will potentially be overriden in API and should not be relied upon.
+	RequestClient   string                 `json:"request_client,omitempty"`   // Will be converted to a user.
+	Project         []string               `json:"project,omitempty"`          // Has to be an array of string because of how taxonomies work in WordPress.
+	Verdict         *Verdict               `json:"verdict,omitempty"`          // Outcome of the audit policy, if one is configured.
+	Failures        []Failure              `json:"failures,omitempty"`         // Reasons why audits failed, if any.
+	Environment     *Environment           `json:"environment,omitempty"`      // Runtime environment of the audit.
+	ChecksumExclude []string               `json:"checksum_exclude,omitempty"` // Patterns of files left out of the checksum.
+	Trend           *Trend                 `json:"trend,omitempty"`            // Changes since the previous audited version, if the history is tracked.
+}
+
+// Verdicts of an audit policy.
+const (
+	VerdictPass = "pass"
+	VerdictFail = "fail"
+)
+
+// Verdict is the outcome of evaluating an audit policy over the audit results.
+type Verdict struct {
+	Result  string   `json:"result"`
+	Policy  string   `json:"policy,omitempty"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// Failure codes describe why an audit failed.
+const (
+	FailureSourceUnreachable = "SOURCE_UNREACHABLE" // The source, or a custom ruleset, could not be downloaded.
+	FailureArchiveInvalid    = "ARCHIVE_INVALID"    // The source could not be extracted.
+	FailureArchiveRejected   = "ARCHIVE_REJECTED"   // The source is unsafe to extract, e.g. a zip bomb.
+	FailurePhpcsTimeout      = "PHPCS_TIMEOUT"      // PHPCS did not finish in time.
+	FailureStorage           = "STORAGE_FAILURE"    // A report could not be stored.
+	FailureStandardMissing   = "STANDARD_MISSING"   // The requested standard or version is not installed.
+	FailureMalwareDetected   = "MALWARE_DETECTED"   // The source was quarantined because a scanner found malware.
+	FailureScanFailed        = "SCAN_FAILED"        // The source could not be scanned for malware.
+	FailureRulesetInvalid    = "RULESET_INVALID"    // A custom ruleset is not a valid PHPCS ruleset.
+	FailureUnknown           = "UNKNOWN"            // Any other failure.
+)
+
+// Failure is a machine-readable reason for a failed audit.
+type Failure struct {
+	Code    string `json:"code"`
+	Process string `json:"process,omitempty"`
+	Message string `json:"message"`
+}
+
+// Environment describes the runtime environment that produced a result, so that results
+// can be compared across worker upgrades.
+type Environment struct {
+	Phpcs       string                       `json:"phpcs,omitempty"`     // PHP_CodeSniffer version.
+	Standards   map[string]map[string]string `json:"standards,omitempty"` // Installed component versions per standard.
+	Php         string                       `json:"php,omitempty"`       // PHP CLI version.
+	Image       string                       `json:"image,omitempty"`     // Worker image tag.
+	OS          string                       `json:"os"`
+	Fingerprint string                       `json:"fingerprint"` // Hash of the other fields.
+}
+
+// Trend directions.
+const (
+	TrendNew        = "new" // There are no results of an earlier version.
+	TrendImproving  = "improving"
+	TrendRegressing = "regressing"
+	TrendUnchanged  = "unchanged"
+)
+
+// Trend describes how the results of a project changed since its previous audited version.
+type Trend struct {
+	Direction     string             `json:"direction"`
+	Previous      *TrendPoint        `json:"previous,omitempty"`
+	ErrorsDelta   int                `json:"errors_delta"`
+	WarningsDelta int                `json:"warnings_delta"`
+	ScoreDeltas   map[string]float32 `json:"score_deltas,omitempty"` // Lighthouse score changes by category.
+	History       []TrendPoint       `json:"history,omitempty"`      // Earlier versions, oldest first.
+}
+
+// TrendPoint is the summary of the results of a version of a project.
+type TrendPoint struct {
+	Version  string             `json:"version"`
+	Checksum string             `json:"checksum"`
+	Errors   int                `json:"errors"`            // PHPCS errors, except PHPCompatibility errors.
+	Warnings int                `json:"warnings"`          // PHPCS warnings, except PHPCompatibility warnings.
+	Scores   map[string]float32 `json:"scores,omitempty"`  // Lighthouse scores by category.
+	MinPHP   string             `json:"min_php,omitempty"` // Lowest compatible PHP version.
+	Verdict  string             `json:"verdict,omitempty"` // Result of the audit policy.
 }
 
 // CodeInfo contains the details about the files being processed.
@@ -57,16 +136,28 @@ type ClocResult struct {
 	NFiles  int `json:"n_files"`
 }
 
+// Statuses of audits that did not complete.
+const (
+	AuditStatusSkipped = "skipped" // The audit was not run because it does not apply.
+	AuditStatusTimeout = "timeout" // The audit was stopped because it did not finish in time.
+)
+
 // AuditResult contain results about an audit.
 type AuditResult struct {
-	Raw                  AuditDetails           `json:"raw,omitempty"`
-	Parsed               AuditDetails           `json:"parsed,omitempty"`
-	Summary              AuditSummary           `json:"summary,omitempty"`
-	CompatibleVersions   []string               `json:"compatible_versions,omitempty"`
-	IncompatibleVersions []string               `json:"incompatible_versions,omitempty"`
-	PhpcsVersions        map[string]string      `json:"phpcs_versions,omitempty"`
-	Error                string                 `json:"error,omitempty"`
-	Extra                map[string]interface{} `json:"extra,omitempty"`
+	Status               string                  `json:"status,omitempty"` // AuditStatusSkipped if the audit was not run, AuditStatusTimeout if it was stopped.
+	Reason               string                  `json:"reason,omitempty"` // Why the audit was skipped, e.g. "no PHP sources".
+	Raw                  AuditDetails            `json:"raw,omitempty"`
+	Parsed               AuditDetails            `json:"parsed,omitempty"`
+	Index                *AuditDetails           `json:"index,omitempty"`   // Index of the paginated report, see the report/paged package.
+	Reports              map[string]AuditDetails `json:"reports,omitempty"` // The report in the formats requested by the audit options, by format, see the report package.
+	Summary              AuditSummary            `json:"summary,omitempty"`
+	CompatibleVersions   []string                `json:"compatible_versions,omitempty"`
+	IncompatibleVersions []string                `json:"incompatible_versions,omitempty"`
+	PhpcsVersions        map[string]string       `json:"phpcs_versions,omitempty"`
+	Overview             *PhpcsOverview          `json:"overview,omitempty"`
+	Error                string                  `json:"error,omitempty"`
+	CacheHit             bool                    `json:"cache_hit,omitempty"` // The raw report of an earlier audit of the same sources and standards was reused.
+	Extra                map[string]interface{}  `json:"extra,omitempty"`
 }
 
 // PhpcsResults contains the results from a phpcs audit.
@@ -98,6 +189,8 @@ type AuditDetails struct {
 	Type     string `json:"type,omitempty"`
 	FileName string `json:"filename,omitempty"`
 	Path     string `json:"path,omitempty"`
+	Chunked  bool   `json:"chunked,omitempty"`  // FileName references a chunk manifest, see storage.UploadChunked.
+	Encoding string `json:"encoding,omitempty"` // Content encoding of the report, e.g. "gzip". Chunks are parts of the encoded report.
 	*PhpcsResults
 	*LighthouseResults
 }
@@ -119,6 +212,26 @@ type PhpcsSummary struct {
 	WarningsCount int `json:"warnings_count"`
 }
 
+// PhpcsOverview lists the biggest problems in `phpcs` results, with the totals of the messages.
+type PhpcsOverview struct {
+	Sources        []PhpcsCount `json:"sources"`         // Sniff sources with the most messages.
+	Files          []PhpcsCount `json:"files"`           // Files with the most errors.
+	FixablePercent float64      `json:"fixable_percent"` // Percentage of messages that phpcbf can fix.
+	FilesCount     int          `json:"files_count"`     // Number of files in the report, with or without messages.
+	Errors         int          `json:"errors"`
+	Warnings       int          `json:"warnings"`
+	Fixable        int          `json:"fixable"` // Messages that phpcbf can fix.
+}
+
+// PhpcsCount is the number of messages for a sniff source or file, i.e. of errors for a file.
+type PhpcsCount struct {
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+	Errors   int    `json:"errors,omitempty"`
+	Warnings int    `json:"warnings,omitempty"`
+	Fixable  int    `json:"fixable,omitempty"` // Messages that phpcbf can fix.
+}
+
 // LighthouseResults is a simplified version of `lighthouse` results.
 // TODO: Define this later.
 type LighthouseResults struct{}
@@ -0,0 +1,220 @@
+// Package trend tracks the results of a project across its versions, so that dashboards can
+// show whether the quality of a plugin or theme is improving or regressing.
+//
+// The package has no adapter for a results store, since this repository has no API to read
+// results back. Callers implement Store for the store of their deployment.
+package trend
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wptide/pkg/tide"
+)
+
+// compatibilityReport is the report that determines the compatible PHP versions.
+const compatibilityReport = "phpcs_phpcompatibility"
+
+// Store returns the stored result documents of a project, e.g. from the results store of the Tide API.
+type Store interface {
+	Items(slug string) ([]tide.Item, error)
+}
+
+// Tracker returns the history and trend of projects from a results store.
+type Tracker struct {
+	Store Store
+	Limit int // (Optional) Most earlier versions added to a trend. Unlimited if 0.
+}
+
+// History returns a point for every version of the project in the results store, ordered by
+// version. If a version was audited more than once, the last stored result is used.
+func (t Tracker) History(slug string) ([]tide.TrendPoint, error) {
+	if t.Store == nil {
+		return nil, errors.New("no results store to track trends")
+	}
+	if slug == "" {
+		return nil, errors.New("no slug to track trends")
+	}
+
+	items, err := t.Store.Items(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	var points []tide.TrendPoint
+	index := make(map[string]int)
+	for _, item := range items {
+		point := Point(item)
+		if i, ok := index[point.Version]; ok {
+			points[i] = point
+			continue
+		}
+		index[point.Version] = len(points)
+		points = append(points, point)
+	}
+
+	sort.SliceStable(points, func(i, j int) bool {
+		return CompareVersions(points[i].Version, points[j].Version) < 0
+	})
+
+	return points, nil
+}
+
+// Trend returns the changes of the item since the previous version of the project. Results of
+// the same or later versions, e.g. of a re-audit, are not part of the trend.
+func (t Tracker) Trend(slug string, item tide.Item) (*tide.Trend, error) {
+	history, err := t.History(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	current := Point(item)
+
+	var earlier []tide.TrendPoint
+	for _, point := range history {
+		if point.Checksum != current.Checksum && CompareVersions(point.Version, current.Version) < 0 {
+			earlier = append(earlier, point)
+		}
+	}
+	if t.Limit > 0 && len(earlier) > t.Limit {
+		earlier = earlier[len(earlier)-t.Limit:]
+	}
+
+	if len(earlier) == 0 {
+		return &tide.Trend{Direction: tide.TrendNew}, nil
+	}
+
+	previous := earlier[len(earlier)-1]
+	trend := &tide.Trend{
+		Previous:      &previous,
+		ErrorsDelta:   current.Errors - previous.Errors,
+		WarningsDelta: current.Warnings - previous.Warnings,
+		History:       earlier,
+	}
+
+	var scoreDelta float32
+	for category, score := range current.Scores {
+		before, ok := previous.Scores[category]
+		if !ok {
+			continue
+		}
+		if trend.ScoreDeltas == nil {
+			trend.ScoreDeltas = make(map[string]float32)
+		}
+		trend.ScoreDeltas[category] = score - before
+		scoreDelta += score - before
+	}
+
+	trend.Direction = direction(trend.ErrorsDelta, trend.WarningsDelta, scoreDelta)
+
+	return trend, nil
+}
+
+// direction decides the direction of a trend by the change of errors, then warnings, then
+// the total change of the Lighthouse scores.
+func direction(errorsDelta, warningsDelta int, scoreDelta float32) string {
+	switch {
+	case errorsDelta < 0:
+		return tide.TrendImproving
+	case errorsDelta > 0:
+		return tide.TrendRegressing
+	case warningsDelta < 0:
+		return tide.TrendImproving
+	case warningsDelta > 0:
+		return tide.TrendRegressing
+	case scoreDelta > 0:
+		return tide.TrendImproving
+	case scoreDelta < 0:
+		return tide.TrendRegressing
+	}
+	return tide.TrendUnchanged
+}
+
+// Point returns the summary of the results of an item.
+func Point(item tide.Item) tide.TrendPoint {
+	point := tide.TrendPoint{
+		Version:  item.Version,
+		Checksum: item.Checksum,
+	}
+	if item.Verdict != nil {
+		point.Verdict = item.Verdict.Result
+	}
+
+	for kind, report := range item.Reports {
+		if kind == compatibilityReport {
+			point.MinPHP = lowestVersion(report.CompatibleVersions)
+			continue
+		}
+
+		if summary := report.Summary.PhpcsSummary; summary != nil {
+			point.Errors += summary.ErrorsCount
+			point.Warnings += summary.WarningsCount
+		}
+
+		if summary := report.Summary.LighthouseSummary; summary != nil {
+			for category, result := range summary.Categories {
+				if point.Scores == nil {
+					point.Scores = make(map[string]float32)
+				}
+				point.Scores[category] = result.Score
+			}
+		}
+	}
+
+	return point
+}
+
+// lowestVersion returns the lowest of the versions.
+func lowestVersion(versions []string) string {
+	lowest := ""
+	for _, version := range versions {
+		if lowest == "" || CompareVersions(version, lowest) < 0 {
+			lowest = version
+		}
+	}
+	return lowest
+}
+
+// CompareVersions compares two dotted versions, e.g. "1.10.0" and "1.9", part by part. Numeric
+// parts are compared as numbers, other parts as strings. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aPart, bPart string
+		if i < len(aParts) {
+			aPart = aParts[i]
+		}
+		if i < len(bParts) {
+			bPart = bParts[i]
+		}
+
+		aNum, aErr := strconv.Atoi(aPart)
+		bNum, bErr := strconv.Atoi(bPart)
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				return compareInts(aNum, bNum)
+			}
+		case aPart == "":
+			// Missing parts are lower, e.g. "1.0" < "1.0.1".
+			return -1
+		case bPart == "":
+			return 1
+		case aPart != bPart:
+			return strings.Compare(aPart, bPart)
+		}
+	}
+
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	}
+	return 1
+}
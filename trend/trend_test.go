@@ -0,0 +1,181 @@
+package trend
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/wptide/pkg/tide"
+)
+
+type mockStore struct {
+	items map[string][]tide.Item
+	err   error
+}
+
+func (m mockStore) Items(slug string) ([]tide.Item, error) {
+	return m.items[slug], m.err
+}
+
+// testItem returns an item of the version with the PHPCS errors and warnings and a Lighthouse
+// performance score.
+func testItem(version string, errors, warnings int, score float32, compatible ...string) tide.Item {
+	item := tide.Item{
+		Version:  version,
+		Checksum: "checksum-" + version,
+		Reports: map[string]tide.AuditResult{
+			"phpcs_wordpress": {
+				Summary: tide.AuditSummary{PhpcsSummary: &tide.PhpcsSummary{ErrorsCount: errors, WarningsCount: warnings}},
+			},
+			"lighthouse": {
+				Summary: tide.AuditSummary{LighthouseSummary: &tide.LighthouseSummary{
+					Categories: map[string]tide.LighthouseCategory{"performance": {Score: score}},
+				}},
+			},
+		},
+	}
+	if len(compatible) != 0 {
+		item.Reports["phpcs_phpcompatibility"] = tide.AuditResult{
+			CompatibleVersions: compatible,
+			Summary:            tide.AuditSummary{PhpcsSummary: &tide.PhpcsSummary{ErrorsCount: 100}},
+		}
+	}
+	return item
+}
+
+func TestTracker_History(t *testing.T) {
+	rerun := testItem("1.2", 1, 1, 0.9)
+	rerun.Checksum = "rerun"
+
+	store := mockStore{items: map[string][]tide.Item{
+		"akismet": {testItem("1.10", 2, 0, 0.8), testItem("1.2", 5, 3, 0.5, "7.4", "5.6", "8.0"), testItem("1.9.1", 4, 1, 0.7), rerun},
+	}}
+
+	tests := []struct {
+		name    string
+		tracker Tracker
+		slug    string
+		want    []string // Checksums in order.
+		wantErr bool
+	}{
+		{"Ordered By Version", Tracker{Store: store}, "akismet", []string{"rerun", "checksum-1.9.1", "checksum-1.10"}, false},
+		{"Unknown Slug", Tracker{Store: store}, "hello-dolly", nil, false},
+		{"No Slug", Tracker{Store: store}, "", nil, true},
+		{"No Store", Tracker{}, "akismet", nil, true},
+		{"Store Error", Tracker{Store: mockStore{err: errors.New("unavailable")}}, "akismet", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.tracker.History(tt.slug)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Tracker.History() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var checksums []string
+			for _, point := range got {
+				checksums = append(checksums, point.Checksum)
+			}
+			if !reflect.DeepEqual(checksums, tt.want) {
+				t.Errorf("Tracker.History() = %v, want %v", checksums, tt.want)
+			}
+		})
+	}
+}
+
+func TestTracker_Trend(t *testing.T) {
+	store := mockStore{items: map[string][]tide.Item{
+		"akismet": {testItem("1.0", 5, 3, 0.5, "5.6", "7.4"), testItem("1.1", 4, 3, 0.6, "7.0", "7.4"), testItem("2.0", 0, 0, 1)},
+	}}
+
+	tests := []struct {
+		name         string
+		tracker      Tracker
+		item         tide.Item
+		wantDir      string
+		wantPrevious string
+		wantHistory  int
+		wantErrors   int
+		wantScore    float32
+	}{
+		{"Fewer Errors", Tracker{Store: store}, testItem("1.2", 2, 5, 0.6), tide.TrendImproving, "1.1", 2, -2, 0},
+		{"More Errors", Tracker{Store: store}, testItem("1.2", 6, 0, 0.9), tide.TrendRegressing, "1.1", 2, 2, 0.3},
+		{"Fewer Warnings", Tracker{Store: store}, testItem("1.2", 4, 1, 0.6), tide.TrendImproving, "1.1", 2, 0, 0},
+		{"Lower Score", Tracker{Store: store}, testItem("1.2", 4, 3, 0.4), tide.TrendRegressing, "1.1", 2, 0, -0.2},
+		{"Unchanged", Tracker{Store: store}, testItem("1.2", 4, 3, 0.6), tide.TrendUnchanged, "1.1", 2, 0, 0},
+		{"Limit", Tracker{Store: store, Limit: 1}, testItem("1.2", 4, 3, 0.6), tide.TrendUnchanged, "1.1", 1, 0, 0},
+		{"Re-audit", Tracker{Store: store}, testItem("1.1", 4, 3, 0.6), tide.TrendImproving, "1.0", 1, -1, 0.1},
+		{"First Version", Tracker{Store: store}, testItem("0.9", 4, 3, 0.6), tide.TrendNew, "", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.tracker.Trend("akismet", tt.item)
+			if err != nil {
+				t.Fatalf("Tracker.Trend() error = %v", err)
+			}
+
+			if got.Direction != tt.wantDir {
+				t.Errorf("Tracker.Trend() direction = %v, want %v", got.Direction, tt.wantDir)
+			}
+			if len(got.History) != tt.wantHistory {
+				t.Errorf("Tracker.Trend() history = %v, want %v points", got.History, tt.wantHistory)
+			}
+			if tt.wantPrevious == "" {
+				if got.Previous != nil {
+					t.Errorf("Tracker.Trend() previous = %v", got.Previous)
+				}
+				return
+			}
+			if got.Previous == nil || got.Previous.Version != tt.wantPrevious {
+				t.Fatalf("Tracker.Trend() previous = %v, want %v", got.Previous, tt.wantPrevious)
+			}
+			if got.ErrorsDelta != tt.wantErrors {
+				t.Errorf("Tracker.Trend() errors delta = %v, want %v", got.ErrorsDelta, tt.wantErrors)
+			}
+			if delta := got.ScoreDeltas["performance"]; delta-tt.wantScore > 0.001 || tt.wantScore-delta > 0.001 {
+				t.Errorf("Tracker.Trend() score delta = %v, want %v", delta, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestPoint(t *testing.T) {
+	item := testItem("1.0", 5, 3, 0.5, "7.4", "5.6", "8.0")
+	item.Verdict = &tide.Verdict{Result: tide.VerdictFail}
+
+	want := tide.TrendPoint{
+		Version:  "1.0",
+		Checksum: "checksum-1.0",
+		Errors:   5, // PHPCompatibility errors are not counted.
+		Warnings: 3,
+		Scores:   map[string]float32{"performance": 0.5},
+		MinPHP:   "5.6",
+		Verdict:  tide.VerdictFail,
+	}
+
+	if got := Point(item); !reflect.DeepEqual(got, want) {
+		t.Errorf("Point() = %v, want %v", got, want)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.9", "1.10", -1},
+		{"2.0", "1.10.3", 1},
+		{"1.0", "1.0.1", -1},
+		{"1.0.1", "1.0", 1},
+		{"1.0-beta", "1.0-rc", -1},
+		{"", "1.0", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+" vs "+tt.b, func(t *testing.T) {
+			if got := CompareVersions(tt.a, tt.b); got != tt.want {
+				t.Errorf("CompareVersions(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}